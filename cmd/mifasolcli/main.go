@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"github.com/jypelle/mifasol/internal/cli"
+	"github.com/jypelle/mifasol/internal/cli/download"
 	"github.com/jypelle/mifasol/internal/version"
 	"math/rand"
 	"os"
@@ -48,6 +49,7 @@ func main() {
 		fmt.Printf("  ui        Launch the console interface\n")
 		fmt.Printf("  import    Import every flac, mp3 and ogg files from current folder to mifasol server\n")
 		fmt.Printf("  filesync  Sync a folder with favorite mifasol server content\n")
+		fmt.Printf("  download  Download an album or a playlist as a zip archive\n")
 		fmt.Printf("  version   Show the version number\n")
 		fmt.Printf("\nRun '%s COMMAND --help' for more information on a command.\n", mainCommand)
 	}
@@ -56,6 +58,7 @@ func main() {
 	configCmd := flag.NewFlagSet("config", flag.ExitOnError)
 	configServerHostname := configCmd.String("hostname", "", "Set server host name")
 	configServerPort := configCmd.Int64("n", 0, "Set server port number")
+	configServerBasePath := configCmd.String("base-path", "", "Set server base path, when served behind a reverse proxy under a sub-path (\"/\" for root)")
 	configUsername := configCmd.String("u", "", "Set username")
 	configPassword := configCmd.String("p", "", "Set password")
 	configClearCachedSelfSignedServerCertificate := configCmd.Bool("clear-sscrt", false, "Clear cached self-signed server certificate")
@@ -117,6 +120,17 @@ func main() {
 		fmt.Printf("\nSynchronize folder with favorite mifasol server content\n")
 	}
 
+	// download command
+	downloadCmd := flag.NewFlagSet("download", flag.ExitOnError)
+	downloadOutputFile := downloadCmd.String("o", "", "Location of the zip archive (default: album or playlist name in current folder)")
+
+	downloadCmd.Usage = func() {
+		fmt.Printf("\nUsage: %s download [OPTIONS] album|playlist [Name or id of the album or playlist]\n", mainCommand)
+		fmt.Printf("\nDownload an album or a playlist (with its m3u8 file) as a zip archive\n")
+		fmt.Printf("\nOptions:\n")
+		downloadCmd.PrintDefaults()
+	}
+
 	// version command
 	versionCmd := flag.NewFlagSet("version", flag.ExitOnError)
 
@@ -190,6 +204,20 @@ func main() {
 			fileSyncCmd.Usage()
 			os.Exit(1)
 		}
+	case "download":
+		downloadCmd.Parse(flag.Args()[1:])
+		if downloadCmd.NArg() != 2 {
+			fmt.Printf("\n\"%s %s\" need an item type and the name or id of the item to download\n", mainCommand, flag.Arg(0))
+			downloadCmd.Usage()
+			os.Exit(1)
+		}
+		switch download.ItemType(downloadCmd.Arg(0)) {
+		case download.ItemTypeAlbum, download.ItemTypePlaylist:
+		default:
+			fmt.Printf("\n%s is not a downloadable item type\n", downloadCmd.Arg(0))
+			downloadCmd.Usage()
+			os.Exit(1)
+		}
 	case "version":
 		versionCmd.Parse(flag.Args()[1:])
 		if versionCmd.NArg() > 0 {
@@ -239,6 +267,7 @@ func main() {
 		clientApp.Config(
 			*configServerHostname,
 			*configServerPort,
+			*configServerBasePath,
 			configServerSSL,
 			configServerSelfSignedCertificate,
 			*configUsername,
@@ -274,6 +303,11 @@ func main() {
 			}
 		}
 
+		if downloadCmd.Parsed() {
+			// Download album or playlist archive
+			clientApp.Download(download.ItemType(downloadCmd.Arg(0)), downloadCmd.Arg(1), *downloadOutputFile)
+		}
+
 	}
 
 }
@@ -47,8 +47,13 @@ func main() {
 		fmt.Printf("\nOptions:\n")
 		flag.PrintDefaults()
 		fmt.Printf("\nCommands:\n")
+		fmt.Printf("  backup    Backup server data\n")
+		fmt.Printf("  check     Check database and song files consistency\n")
 		fmt.Printf("  config    Configure server\n")
+		fmt.Printf("  import    Import every flac and mp3 files from a folder\n")
+		fmt.Printf("  restore   Restore server data\n")
 		fmt.Printf("  run       Run server\n")
+		fmt.Printf("  storage   Manage song files storage\n")
 		fmt.Printf("  version   Show the version number\n")
 		fmt.Printf("\nRun '%s COMMAND --help' for more information on a command.\n", mainCommand)
 	}
@@ -57,9 +62,59 @@ func main() {
 	configCmd := flag.NewFlagSet("config", flag.ExitOnError)
 	configHostnames := configCmd.String("hostnames", "", "Set comma separated hostname list used to generate self-signed certificate")
 	configPort := configCmd.Int64("n", 0, "Set port number")
-	//configRenewSelfSignedCertificate := configCmd.Bool("renew-sscrt", false, "Renew self-signed certificate")
+	configBindAddress := configCmd.String("bind-address", "", "Listen only on this IP address")
+	configAllInterfaces := configCmd.Bool("all-interfaces", false, "Listen on every network interface")
+	configUnixSocket := configCmd.String("unix-socket", "", "Listen on this unix socket file instead of the tcp port (for a reverse proxy on the same host)")
+	configUnixSocketDisabled := configCmd.Bool("disable-unix-socket", false, "Listen on the tcp port instead of the unix socket")
+	configBasePath := configCmd.String("base-path", "", "Set url path prefix, when served behind a reverse proxy under a sub-path (\"/\" for root)")
+	configTrustedProxies := configCmd.String("trusted-proxies", "", "Set comma separated IP address or CIDR list of the reverse proxies allowed to send X-Forwarded-* headers")
+	configTrustedProxiesDisabled := configCmd.Bool("disable-trusted-proxies", false, "Ignore X-Forwarded-* headers")
+	configRenewSelfSignedCertificate := configCmd.Bool("renew-sscrt", false, "Renew self-signed certificate")
 	configSslEnabled := configCmd.Bool("enable-ssl", false, "Enable SSL with self-signed certificate (client should use https to connect to server)")
 	configSslDisabled := configCmd.Bool("disable-ssl", false, "Disable SSL (client should use http to connect to server)")
+	configCertFile := configCmd.String("cert-file", "", "Use this externally managed certificate chain file instead of the self-signed certificate (reloaded when renewed)")
+	configKeyFile := configCmd.String("key-file", "", "Use this externally managed private key file instead of the self-signed certificate key")
+	configSelfSignedCert := configCmd.Bool("self-signed-cert", false, "Use the self-signed certificate instead of the externally managed one")
+	configHttpRedirectPort := configCmd.Int64("http-redirect-port", 0, "Redirect the plain http requests received on this port number to https")
+	configHttpRedirectDisabled := configCmd.Bool("disable-http-redirect", false, "Disable plain http redirect to https")
+	configTrashRetentionDays := configCmd.Int64("trash-retention", 0, "Set number of days before deleted items are purged from the trash")
+	configAuditRetentionDays := configCmd.Int64("audit-retention", 0, "Set number of days before audit trail entries are purged")
+	configS3Endpoint := configCmd.String("s3-endpoint", "", "Set S3 compatible object storage url (like https://s3.eu-west-3.amazonaws.com or http://localhost:9000)")
+	configS3Region := configCmd.String("s3-region", "", "Set S3 region")
+	configS3Bucket := configCmd.String("s3-bucket", "", "Set S3 bucket storing the song files")
+	configS3Prefix := configCmd.String("s3-prefix", "", "Set S3 object key prefix of the song files (\"/\" for none)")
+	configS3AccessKey := configCmd.String("s3-access-key", "", "Set S3 access key")
+	configS3SecretKey := configCmd.String("s3-secret-key", "", "Set S3 secret key")
+	configS3PresignedRedirectEnabled := configCmd.Bool("enable-s3-presigned-redirect", false, "Redirect song streams to presigned S3 urls, instead of relaying them")
+	configS3PresignedRedirectDisabled := configCmd.Bool("disable-s3-presigned-redirect", false, "Relay song streams read from S3")
+	configBackupDir := configCmd.String("backup-dir", "", "Enable scheduled backup into this folder")
+	configBackupDisabled := configCmd.Bool("disable-backup", false, "Disable scheduled backup")
+	configBackupInterval := configCmd.Int64("backup-interval", 0, "Set number of hours between two scheduled backups")
+	configInboxDir := configCmd.String("inbox-dir", "", "Enable automatic import of song files dropped into this folder")
+	configInboxDisabled := configCmd.Bool("disable-inbox", false, "Disable inbox folder")
+	configInboxInterval := configCmd.Int64("inbox-interval", 0, "Set number of seconds between two inbox folder scans")
+	configInboxKeepImported := configCmd.Bool("inbox-keep-imported", false, "Move imported inbox files to the .imported subfolder")
+	configInboxDeleteImported := configCmd.Bool("inbox-delete-imported", false, "Delete imported inbox files")
+	configMpdEnabled := configCmd.Bool("enable-mpd", false, "Enable MPD server (MPD clients should use \"username:password\" as password)")
+	configMpdDisabled := configCmd.Bool("disable-mpd", false, "Disable MPD server")
+	configMpdPort := configCmd.Int64("mpd-port", 0, "Set MPD server port number")
+	configUpnpEnabled := configCmd.Bool("enable-upnp", false, "Enable UPnP/DLNA media server (every song is readable without authentication from the local network)")
+	configUpnpDisabled := configCmd.Bool("disable-upnp", false, "Disable UPnP/DLNA media server")
+	configUpnpPort := configCmd.Int64("upnp-port", 0, "Set UPnP/DLNA media server port number")
+	configUpnpName := configCmd.String("upnp-name", "", "Set UPnP/DLNA media server name")
+	configFfmpegPath := configCmd.String("ffmpeg-path", "", "Set ffmpeg executable path used by radio stations to transcode songs")
+	configMetricsEnabled := configCmd.Bool("enable-metrics", false, "Enable Prometheus metrics endpoint /metrics")
+	configMetricsDisabled := configCmd.Bool("disable-metrics", false, "Disable Prometheus metrics endpoint")
+	configMetricsAddress := configCmd.String("metrics-address", "", "Serve metrics endpoint on this address (host:port) instead of the server port")
+	configMetricsMainPort := configCmd.Bool("metrics-main-port", false, "Serve metrics endpoint on the server port")
+	configMetricsToken := configCmd.String("metrics-token", "", "Require this bearer token to read metrics")
+	configMetricsTokenDisabled := configCmd.Bool("disable-metrics-token", false, "Read metrics without token")
+	configLoginMaxFailures := configCmd.Int64("login-max-failures", 0, "Set number of failed logins, per IP and per username, before the lockout")
+	configLoginLockout := configCmd.Int64("login-lockout", 0, "Set number of minutes of a login lockout")
+	configApiRateLimit := configCmd.Int64("api-rate-limit", 0, "Set number of api calls per minute allowed to each user")
+	configApiRateLimitDisabled := configCmd.Bool("disable-api-rate-limit", false, "Disable api rate limit")
+	configStreamLimit := configCmd.Int64("stream-limit", 0, "Set number of concurrent song streams allowed to each user")
+	configStreamLimitDisabled := configCmd.Bool("disable-stream-limit", false, "Disable concurrent song streams limit")
 
 	configCmd.Usage = func() {
 		fmt.Printf("\nUsage: %s config\n", mainCommand)
@@ -68,6 +123,57 @@ func main() {
 		configCmd.PrintDefaults()
 	}
 
+	// backup command
+	backupCmd := flag.NewFlagSet("backup", flag.ExitOnError)
+
+	backupCmd.Usage = func() {
+		fmt.Printf("\nUsage: %s backup [Location of backup folder]\n", mainCommand)
+		fmt.Printf("\nBackup database, config and song files (only new or modified song files are copied into an existing backup folder)\n")
+	}
+
+	// check command
+	checkCmd := flag.NewFlagSet("check", flag.ExitOnError)
+	checkRepair := checkCmd.Bool("repair", false, "Repair found issues (rewrite song tags, update song sizes, remove dangling links and orphan files)")
+	checkJson := checkCmd.Bool("json", false, "Print the report in JSON format")
+
+	checkCmd.Usage = func() {
+		fmt.Printf("\nUsage: %s check\n", mainCommand)
+		fmt.Printf("\nCheck database and song files consistency (mifasol server should be stopped to repair)\n")
+		fmt.Printf("\nOptions:\n")
+		checkCmd.PrintDefaults()
+	}
+
+	// import command
+	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+	importOneFolderPerAlbumDisabled := importCmd.Bool("disable-one-folder-per-album", false, "Don't use folder name changes to differentiate homonym albums")
+
+	importCmd.Usage = func() {
+		fmt.Printf("\nUsage: %s import [OPTIONS] [Location of music folder to import]\n", mainCommand)
+		fmt.Printf("\nImport every flac and mp3 files from a folder into the (incoming) playlist (mifasol server should be stopped, otherwise use the inbox folder)\n")
+		fmt.Printf("\nOptions:\n")
+		importCmd.PrintDefaults()
+	}
+
+	// restore command
+	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
+
+	restoreCmd.Usage = func() {
+		fmt.Printf("\nUsage: %s restore [Location of backup folder]\n", mainCommand)
+		fmt.Printf("\nRestore database, config and song files from a backup folder (mifasol server should be stopped)\n")
+	}
+
+	// storage command
+	storageMigrateCmd := flag.NewFlagSet("storage migrate", flag.ExitOnError)
+	storageMigrateTo := storageMigrateCmd.String("to", "", "Set destination storage: local or s3 (configured with the config command)")
+	storageMigrateDelete := storageMigrateCmd.Bool("delete", false, "Delete the song files from the source storage once migrated")
+
+	storageMigrateCmd.Usage = func() {
+		fmt.Printf("\nUsage: %s storage migrate -to STORAGE [OPTIONS]\n", mainCommand)
+		fmt.Printf("\nCopy every song file into another storage then use it (mifasol server should be stopped)\n")
+		fmt.Printf("\nOptions:\n")
+		storageMigrateCmd.PrintDefaults()
+	}
+
 	// run command
 	runCmd := flag.NewFlagSet("run", flag.ExitOnError)
 
@@ -107,6 +213,46 @@ func main() {
 			configCmd.Usage()
 			os.Exit(1)
 		}
+	case "backup":
+		backupCmd.Parse(flag.Args()[1:])
+		if backupCmd.NArg() != 1 {
+			fmt.Printf("\n\"%s %s\" requires exactly one argument\n", mainCommand, flag.Arg(0))
+			backupCmd.Usage()
+			os.Exit(1)
+		}
+	case "check":
+		checkCmd.Parse(flag.Args()[1:])
+		if checkCmd.NArg() > 0 {
+			fmt.Printf("\n\"%s %s\" accepts no arguments\n", mainCommand, flag.Arg(0))
+			checkCmd.Usage()
+			os.Exit(1)
+		}
+	case "import":
+		importCmd.Parse(flag.Args()[1:])
+		if importCmd.NArg() != 1 {
+			fmt.Printf("\n\"%s %s\" need a folder to import\n", mainCommand, flag.Arg(0))
+			importCmd.Usage()
+			os.Exit(1)
+		}
+	case "restore":
+		restoreCmd.Parse(flag.Args()[1:])
+		if restoreCmd.NArg() != 1 {
+			fmt.Printf("\n\"%s %s\" requires exactly one argument\n", mainCommand, flag.Arg(0))
+			restoreCmd.Usage()
+			os.Exit(1)
+		}
+	case "storage":
+		if flag.NArg() < 2 || flag.Arg(1) != "migrate" {
+			fmt.Printf("\n\"%s %s\" requires the migrate subcommand\n", mainCommand, flag.Arg(0))
+			storageMigrateCmd.Usage()
+			os.Exit(1)
+		}
+		storageMigrateCmd.Parse(flag.Args()[2:])
+		if storageMigrateCmd.NArg() > 0 || *storageMigrateTo == "" {
+			fmt.Printf("\n\"%s %s %s\" requires the destination storage and accepts no arguments\n", mainCommand, flag.Arg(0), flag.Arg(1))
+			storageMigrateCmd.Usage()
+			os.Exit(1)
+		}
 	case "run":
 		runCmd.Parse(flag.Args()[1:])
 		if runCmd.NArg() > 0 {
@@ -134,6 +280,15 @@ func main() {
 		logrus.Printf("Debug mode activated")
 	}
 
+	// Restore must be done before opening the database
+	if restoreCmd.Parsed() {
+		err := srv.RestoreBackup(*configDir, restoreCmd.Arg(0))
+		if err != nil {
+			logrus.Fatalf("Unable to restore the backup: %v", err)
+		}
+		return
+	}
+
 	// Create mifasol server
 	serverApp := srv.NewServerApp(*configDir, *debugMode)
 
@@ -149,13 +304,227 @@ func main() {
 			configSsl = &falseVar
 		}
 
-		hostnames := strings.Split(strings.ReplaceAll(*configHostnames, " ", ""), ",")
+		var bindAddress *string = nil
+		if *configBindAddress != "" {
+			bindAddress = configBindAddress
+		}
+		if *configAllInterfaces {
+			emptyVar := ""
+			bindAddress = &emptyVar
+		}
+
+		var unixSocket *string = nil
+		if *configUnixSocket != "" {
+			unixSocket = configUnixSocket
+		}
+		if *configUnixSocketDisabled {
+			emptyVar := ""
+			unixSocket = &emptyVar
+		}
+
+		var basePath *string = nil
+		if *configBasePath != "" {
+			basePath = configBasePath
+		}
+
+		var trustedProxies *[]string = nil
+		if *configTrustedProxies != "" {
+			trustedProxiesVar := strings.Split(strings.ReplaceAll(*configTrustedProxies, " ", ""), ",")
+			trustedProxies = &trustedProxiesVar
+		}
+		if *configTrustedProxiesDisabled {
+			emptyVar := []string{}
+			trustedProxies = &emptyVar
+		}
+
+		var certFile *string = nil
+		if *configCertFile != "" {
+			certFile = configCertFile
+		}
+		var keyFile *string = nil
+		if *configKeyFile != "" {
+			keyFile = configKeyFile
+		}
+		if *configSelfSignedCert {
+			emptyVar := ""
+			certFile = &emptyVar
+			keyFile = &emptyVar
+		}
+
+		var httpRedirectPort *int64 = nil
+		if *configHttpRedirectPort > 0 {
+			httpRedirectPort = configHttpRedirectPort
+		}
+		if *configHttpRedirectDisabled {
+			var zeroVar int64 = 0
+			httpRedirectPort = &zeroVar
+		}
+
+		var s3PresignedRedirect *bool = nil
+		if *configS3PresignedRedirectEnabled {
+			trueVar := true
+			s3PresignedRedirect = &trueVar
+		}
+		if *configS3PresignedRedirectDisabled {
+			falseVar := false
+			s3PresignedRedirect = &falseVar
+		}
+
+		var backupDir *string = nil
+		if *configBackupDir != "" {
+			backupDir = configBackupDir
+		}
+		if *configBackupDisabled {
+			emptyVar := ""
+			backupDir = &emptyVar
+		}
+
+		var inboxDir *string = nil
+		if *configInboxDir != "" {
+			inboxDir = configInboxDir
+		}
+		if *configInboxDisabled {
+			emptyVar := ""
+			inboxDir = &emptyVar
+		}
+
+		var inboxKeepImported *bool = nil
+		if *configInboxKeepImported {
+			trueVar := true
+			inboxKeepImported = &trueVar
+		}
+		if *configInboxDeleteImported {
+			falseVar := false
+			inboxKeepImported = &falseVar
+		}
+
+		var mpdEnabled *bool = nil
+		if *configMpdEnabled {
+			trueVar := true
+			mpdEnabled = &trueVar
+		}
+		if *configMpdDisabled {
+			falseVar := false
+			mpdEnabled = &falseVar
+		}
+
+		var upnpEnabled *bool = nil
+		if *configUpnpEnabled {
+			trueVar := true
+			upnpEnabled = &trueVar
+		}
+		if *configUpnpDisabled {
+			falseVar := false
+			upnpEnabled = &falseVar
+		}
+
+		var metricsEnabled *bool = nil
+		if *configMetricsEnabled {
+			trueVar := true
+			metricsEnabled = &trueVar
+		}
+		if *configMetricsDisabled {
+			falseVar := false
+			metricsEnabled = &falseVar
+		}
+
+		var metricsAddress *string = nil
+		if *configMetricsAddress != "" {
+			metricsAddress = configMetricsAddress
+		}
+		if *configMetricsMainPort {
+			emptyVar := ""
+			metricsAddress = &emptyVar
+		}
+
+		var metricsToken *string = nil
+		if *configMetricsToken != "" {
+			metricsToken = configMetricsToken
+		}
+		if *configMetricsTokenDisabled {
+			emptyVar := ""
+			metricsToken = &emptyVar
+		}
+
+		apiRateLimit := *configApiRateLimit
+		if *configApiRateLimitDisabled {
+			apiRateLimit = -1
+		}
+
+		streamLimit := *configStreamLimit
+		if *configStreamLimitDisabled {
+			streamLimit = -1
+		}
+
+		var hostnames []string
+		if *configHostnames != "" {
+			hostnames = strings.Split(strings.ReplaceAll(*configHostnames, " ", ""), ",")
+		}
 
 		serverApp.Config(
 			hostnames,
 			*configPort,
-			configSsl)
+			bindAddress,
+			unixSocket,
+			basePath,
+			trustedProxies,
+			configSsl,
+			certFile,
+			keyFile,
+			*configRenewSelfSignedCertificate,
+			httpRedirectPort,
+			*configTrashRetentionDays,
+			*configAuditRetentionDays,
+			*configS3Endpoint,
+			*configS3Region,
+			*configS3Bucket,
+			*configS3Prefix,
+			*configS3AccessKey,
+			*configS3SecretKey,
+			s3PresignedRedirect,
+			backupDir,
+			*configBackupInterval,
+			inboxDir,
+			*configInboxInterval,
+			inboxKeepImported,
+			mpdEnabled,
+			*configMpdPort,
+			upnpEnabled,
+			*configUpnpPort,
+			*configUpnpName,
+			*configFfmpegPath,
+			metricsEnabled,
+			metricsAddress,
+			metricsToken,
+			*configLoginMaxFailures,
+			*configLoginLockout,
+			apiRateLimit,
+			streamLimit)
 
+	} else if backupCmd.Parsed() {
+		// Backup mifasol server
+		err := serverApp.Backup(backupCmd.Arg(0))
+		if err != nil {
+			logrus.Fatalf("Unable to backup the server: %v", err)
+		}
+	} else if checkCmd.Parsed() {
+		// Check mifasol server
+		err := serverApp.Check(*checkRepair, *checkJson)
+		if err != nil {
+			logrus.Fatalf("Unable to check the server: %v", err)
+		}
+	} else if storageMigrateCmd.Parsed() {
+		// Migrate song files storage
+		err := serverApp.MigrateStorage(*storageMigrateTo, *storageMigrateDelete)
+		if err != nil {
+			logrus.Fatalf("Unable to migrate the song files: %v", err)
+		}
+	} else if importCmd.Parsed() {
+		// Import music folder
+		err := serverApp.Import(importCmd.Arg(0), *importOneFolderPerAlbumDisabled)
+		if err != nil {
+			logrus.Fatalf("Unable to import the folder: %v", err)
+		}
 	} else if versionCmd.Parsed() {
 		fmt.Printf("Version %s\n", version.AppVersion.String())
 	} else {
@@ -164,11 +533,17 @@ func main() {
 			serverApp.Start()
 			defer serverApp.Stop()
 
-			// Listen stop signal
-			ch := make(chan os.Signal)
+			// Listen stop signal, SIGHUP reloading the certificate
+			ch := make(chan os.Signal, 1)
 			signal.Notify(ch, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGABRT, syscall.SIGHUP)
-			sig := <-ch
-			logrus.Printf("Received signal: %v", sig)
+			for {
+				sig := <-ch
+				logrus.Printf("Received signal: %v", sig)
+				if sig != syscall.SIGHUP {
+					break
+				}
+				serverApp.ReloadCertificate()
+			}
 		}
 	}
 
@@ -9,6 +9,7 @@ require (
 	github.com/gdamore/tcell/v2 v2.4.1-0.20210828201608-73703f7ed490
 	github.com/go-flac/flacvorbis v0.1.0
 	github.com/go-flac/go-flac v0.3.1
+	github.com/felixge/httpsnoop v1.0.1
 	github.com/gorilla/handlers v1.5.1
 	github.com/gorilla/mux v1.8.0
 	github.com/jmoiron/sqlx v1.3.4
@@ -25,7 +26,6 @@ require (
 	code.rocketnine.space/tslocum/cbind v0.1.5 // indirect
 	github.com/VividCortex/ewma v1.2.0 // indirect
 	github.com/acarl005/stripansi v0.0.0-20180116102854-5a71ef0e047d // indirect
-	github.com/felixge/httpsnoop v1.0.1 // indirect
 	github.com/gdamore/encoding v1.0.0 // indirect
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/hajimehoshi/go-mp3 v0.3.0 // indirect
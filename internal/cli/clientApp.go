@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/jypelle/mifasol/internal/cli/config"
+	"github.com/jypelle/mifasol/internal/cli/download"
 	"github.com/jypelle/mifasol/internal/cli/fileSync"
 	"github.com/jypelle/mifasol/internal/cli/imp"
 	"github.com/jypelle/mifasol/internal/cli/ui"
@@ -113,6 +114,12 @@ func (c *ClientApp) Import(importDir string, importOneFolderPerAlbumDisabled boo
 	importApp.Start()
 }
 
+func (c *ClientApp) Download(itemType download.ItemType, item string, outputFile string) {
+
+	downloadApp := download.NewApp(c.config, c.restClient, itemType, item, outputFile)
+	downloadApp.Start()
+}
+
 func (c *ClientApp) UI() {
 	uiApp := ui.NewApp(c.config, c.restClient)
 	uiApp.Start()
@@ -2,11 +2,13 @@ package cli
 
 import (
 	"fmt"
+	"github.com/jypelle/mifasol/internal/tool"
 )
 
 func (c *ClientApp) Config(
 	serverHostname string,
 	serverPort int64,
+	serverBasePath string,
 	serverSsl *bool,
 	serverSelfSignedCertificate *bool,
 	username string,
@@ -26,6 +28,12 @@ func (c *ClientApp) Config(
 		fmt.Println("Server port updated")
 	}
 
+	if serverBasePath != "" {
+		c.config.ClientEditableConfig.ServerBasePath = tool.NormalizeBasePath(serverBasePath)
+		shouldSaveConfig = true
+		fmt.Println("Server base path updated")
+	}
+
 	if serverSsl != nil {
 		c.config.ClientEditableConfig.ServerSsl = *serverSsl
 		shouldSaveConfig = true
@@ -0,0 +1,155 @@
+package download
+
+import (
+	"fmt"
+	"github.com/jypelle/mifasol/internal/cli/config"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restClientV1"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"strings"
+)
+
+type ItemType string
+
+const (
+	ItemTypeAlbum    ItemType = "album"
+	ItemTypePlaylist ItemType = "playlist"
+)
+
+type App struct {
+	config.ClientConfig
+	restClient *restClientV1.RestClient
+
+	itemType ItemType
+	// Id or name of the album or playlist
+	item       string
+	outputFile string
+}
+
+func NewApp(clientConfig config.ClientConfig, restClient *restClientV1.RestClient, itemType ItemType, item string, outputFile string) *App {
+	app := &App{
+		ClientConfig: clientConfig,
+		restClient:   restClient,
+		itemType:     itemType,
+		item:         item,
+		outputFile:   outputFile,
+	}
+
+	return app
+}
+
+func (a *App) Start() {
+	var archive io.ReadCloser
+	var name string
+	var cliErr restClientV1.ClientError
+
+	switch a.itemType {
+	case ItemTypeAlbum:
+		album := a.findAlbum()
+		name = album.Name
+		archive, cliErr = a.restClient.ReadAlbumArchive(album.Id)
+	case ItemTypePlaylist:
+		playlist := a.findPlaylist()
+		name = playlist.Name
+		archive, cliErr = a.restClient.ReadPlaylistArchive(playlist.Id)
+	default:
+		logrus.Fatalf("Unknown item type: %s", a.itemType)
+	}
+	if cliErr != nil {
+		logrus.Fatalf("Unable to download %s \"%s\": %v", a.itemType, name, cliErr)
+	}
+	defer archive.Close()
+
+	outputFile := a.outputFile
+	if outputFile == "" {
+		outputFile = tool.SanitizeFilename(name) + ".zip"
+	}
+
+	fmt.Printf("Downloading %s \"%s\" to %s\n", a.itemType, name, outputFile)
+
+	file, err := os.Create(outputFile + ".tmp")
+	if err != nil {
+		logrus.Fatalf("Unable to create file \"%s\": %v", outputFile+".tmp", err)
+	}
+
+	size, err := io.Copy(file, archive)
+	file.Close()
+	if err != nil {
+		os.Remove(outputFile + ".tmp")
+		logrus.Fatalf("Unable to download %s \"%s\": %v", a.itemType, name, err)
+	}
+
+	err = os.Rename(outputFile+".tmp", outputFile)
+	if err != nil {
+		logrus.Fatalf("Unable to rename file \"%s\": %v", outputFile+".tmp", err)
+	}
+
+	fmt.Printf("%d bytes downloaded\n", size)
+}
+
+// findAlbum returns the album identified by its id or its name
+func (a *App) findAlbum() *restApiV1.Album {
+	albums, cliErr := a.restClient.ReadAlbums(&restApiV1.AlbumFilter{})
+	if cliErr != nil {
+		logrus.Fatalf("Unable to retrieve albums: %v", cliErr)
+	}
+
+	var matchingAlbums []restApiV1.Album
+	for _, album := range albums {
+		if string(album.Id) == a.item {
+			return &album
+		}
+		if strings.EqualFold(album.Name, a.item) {
+			matchingAlbums = append(matchingAlbums, album)
+		}
+	}
+
+	switch len(matchingAlbums) {
+	case 0:
+		logrus.Fatalf("Unable to find album \"%s\"", a.item)
+	case 1:
+	default:
+		fmt.Printf("Several albums are named \"%s\", use the id of the album to download:\n", a.item)
+		for _, album := range matchingAlbums {
+			fmt.Printf("  %s\n", album.Id)
+		}
+		os.Exit(1)
+	}
+
+	return &matchingAlbums[0]
+}
+
+// findPlaylist returns the playlist identified by its id or its name
+func (a *App) findPlaylist() *restApiV1.Playlist {
+	playlists, cliErr := a.restClient.ReadPlaylists(&restApiV1.PlaylistFilter{})
+	if cliErr != nil {
+		logrus.Fatalf("Unable to retrieve playlists: %v", cliErr)
+	}
+
+	var matchingPlaylists []restApiV1.Playlist
+	for _, playlist := range playlists {
+		if string(playlist.Id) == a.item {
+			return &playlist
+		}
+		if strings.EqualFold(playlist.Name, a.item) {
+			matchingPlaylists = append(matchingPlaylists, playlist)
+		}
+	}
+
+	switch len(matchingPlaylists) {
+	case 0:
+		logrus.Fatalf("Unable to find playlist \"%s\"", a.item)
+	case 1:
+	default:
+		fmt.Printf("Several playlists are named \"%s\", use the id of the playlist to download:\n", a.item)
+		for _, playlist := range matchingPlaylists {
+			fmt.Printf("  %s\n", playlist.Id)
+		}
+		os.Exit(1)
+	}
+
+	return &matchingPlaylists[0]
+}
@@ -35,7 +35,7 @@ func NewApp(clientConfig config.ClientConfig, restClient *restClientV1.RestClien
 		restClient:          restClient,
 		fileSyncMusicFolder: strings.Replace(fileSyncMusicFolder, "\\", "/", -1),
 		fileSyncConfig: FileSyncConfig{
-			LastFileSyncTs:         0,
+			LastFileSyncRevision:   0,
 			FileSyncLocalSongs:     make(map[restApiV1.SongId]*FileSyncLocalSong),
 			FileSyncLocalPlaylists: make(map[restApiV1.PlaylistId]*FileSyncLocalPlaylist),
 		},
@@ -100,7 +100,7 @@ func (a *App) sync() {
 	}
 
 	// Read file sync report
-	fileSyncReport, cliErr := a.restClient.ReadFileSyncReport(a.fileSyncConfig.LastFileSyncTs, a.restClient.UserId())
+	fileSyncReport, cliErr := a.restClient.ReadFileSyncReport(a.fileSyncConfig.LastFileSyncRevision, a.restClient.UserId())
 	if cliErr != nil {
 		logrus.Fatalf("Unable to retrieve songs data: %v\n", cliErr)
 	}
@@ -387,7 +387,7 @@ func (a *App) sync() {
 	//	progressContainer.Wait()
 
 	if songSyncErrors == 0 && playlistSyncErrors == 0 && !synchroAborded {
-		a.fileSyncConfig.LastFileSyncTs = fileSyncReport.SyncTs
+		a.fileSyncConfig.LastFileSyncRevision = fileSyncReport.SyncRevision
 	}
 	a.saveFileSyncConfig()
 
@@ -5,7 +5,8 @@ import "github.com/jypelle/mifasol/restApiV1"
 const FileSyncFilename = ".mifasolFileSync.json"
 
 type FileSyncConfig struct {
-	LastFileSyncTs         int64                                           `json:"lastFileSyncTs"`
+	// Saved under its former name: the timestamp of the folders synchronized before the revisions is understood by the server
+	LastFileSyncRevision   int64                                           `json:"lastFileSyncTs"`
 	FileSyncLocalSongs     map[restApiV1.SongId]*FileSyncLocalSong         `json:"localSongs"`
 	FileSyncLocalPlaylists map[restApiV1.PlaylistId]*FileSyncLocalPlaylist `json:"localPlaylists"`
 }
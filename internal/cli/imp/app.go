@@ -66,7 +66,6 @@ func (a *App) start() {
 	fmt.Printf("Scanning folder \"%s\"\n", a.importDir)
 
 	var filesNameToImport []string
-	var filesSongFormatToImport []restApiV1.SongFormat
 	var filesSize []int64
 
 	// Identify every song files to import
@@ -97,7 +96,6 @@ func (a *App) start() {
 
 				if songFormat != restApiV1.SongFormatUnknown {
 					filesNameToImport = append(filesNameToImport, path)
-					filesSongFormatToImport = append(filesSongFormatToImport, songFormat)
 					filesSize = append(filesSize, info.Size())
 				}
 			}
@@ -150,8 +148,6 @@ func (a *App) start() {
 					lastAlbumId = restApiV1.UnknownAlbumId
 				}
 
-				songFormat := filesSongFormatToImport[key]
-
 				var err error
 				var reader *os.File
 				reader, err = os.Open(fileName)
@@ -172,15 +168,18 @@ func (a *App) start() {
 						mpb.BarRemoveOnComplete(),
 					)
 
-					proxyReader := songBar.ProxyReader(reader)
+					// The upload resumes after a network failure: follow the bytes received by the server
+					progress := func(offset int64) {
+						songBar.SetCurrent(offset)
+					}
 
 					var apiErr restClientV1.ClientError
 					var song *restApiV1.Song
 
 					if a.importOneFolderPerAlbumDisabled {
-						song, apiErr = a.restClient.CreateSongContent(songFormat, proxyReader)
+						song, apiErr = a.restClient.CreateSongContent(reader, filesSize[key], progress)
 					} else {
-						song, apiErr = a.restClient.CreateSongContentForAlbum(songFormat, proxyReader, lastAlbumId)
+						song, apiErr = a.restClient.CreateSongContentForAlbum(reader, filesSize[key], lastAlbumId, progress)
 					}
 					if apiErr == nil {
 						importedSongs++
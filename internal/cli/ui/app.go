@@ -6,12 +6,20 @@ import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/jypelle/mifasol/internal/cli/config"
 	"github.com/jypelle/mifasol/internal/localdb"
+	"github.com/jypelle/mifasol/internal/remote"
+	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
 	"github.com/jypelle/mifasol/restClientV1"
 	"github.com/sirupsen/logrus"
+	"os"
+	"reflect"
 	"strconv"
+	"time"
 )
 
+// Delay between two saves of the play queue and reports of the device state while playing
+const playQueueSaveInterval = 15 * time.Second
+
 type App struct {
 	config.ClientConfig
 	restClient *restClientV1.RestClient
@@ -19,6 +27,9 @@ type App struct {
 	// In memory db
 	localDb *localdb.LocalDb
 
+	// Player remotely controlled by other devices
+	device *remote.Device
+
 	// region View component
 	cviewApp         *cview.Application
 	mainLayout       *cview.Flex
@@ -32,6 +43,12 @@ type App struct {
 	// endregion
 
 	showHelp bool
+
+	// Play queue saved on the server, once the user has chosen to resume it or not
+	playQueueSaveFg       bool
+	savedPlayQueueMeta    *restApiV1.PlayQueueMeta
+	playQueueMetaToSaveCh chan *restApiV1.PlayQueueMeta
+	playQueueSaverDoneCh  chan struct{}
 }
 
 func NewApp(clientConfig config.ClientConfig, restClient *restClientV1.RestClient) *App {
@@ -39,8 +56,21 @@ func NewApp(clientConfig config.ClientConfig, restClient *restClientV1.RestClien
 		ClientConfig: clientConfig,
 		restClient:   restClient,
 		localDb:      localdb.NewLocalDb(restClient, clientConfig.Collator()),
+
+		playQueueMetaToSaveCh: make(chan *restApiV1.PlayQueueMeta, 1),
+		playQueueSaverDoneCh:  make(chan struct{}),
 	}
 
+	hostname, _ := os.Hostname()
+	app.device = remote.NewDevice(
+		restClient,
+		restApiV1.DeviceId(tool.CreateUlid()),
+		restApiV1.DeviceMeta{
+			Name:         "Console " + hostname,
+			Capabilities: deviceCapabilities,
+		},
+	)
+
 	app.cviewApp = cview.NewApplication()
 
 	app.libraryComponent = NewLibraryComponent(app)
@@ -101,6 +131,12 @@ func NewApp(clientConfig config.ClientConfig, restClient *restClientV1.RestClien
 				case 'p':
 					app.playerComponent.PauseResume()
 					return nil
+				case 'o':
+					app.OpenRemoteControl()
+					return nil
+				case 'g':
+					OpenAuditComponent(app, app.cviewApp.GetFocus())
+					return nil
 				case '+':
 					app.playerComponent.VolumeUp()
 					return nil
@@ -151,9 +187,32 @@ func NewApp(clientConfig config.ClientConfig, restClient *restClientV1.RestClien
 func (a *App) Start() {
 	logrus.Debugf("Starting console user interface ...")
 
-	// Refresh Db from Server
+	// Refresh Db from Server, then propose to resume the play queue
 	fmt.Println("Syncing...")
-	a.cviewApp.QueueUpdateDraw(a.Reload)
+	a.cviewApp.QueueUpdateDraw(func() {
+		a.reload(a.proposePlayQueueResume)
+	})
+
+	// Refresh Db on server changes
+	go a.localDb.WatchEvents(nil, a.autoReload)
+
+	// Execute the commands sent by other devices
+	go a.device.Listen(nil, func(command *restApiV1.DeviceCommand) {
+		a.cviewApp.QueueUpdateDraw(func() {
+			a.playerComponent.ExecuteCommand(command)
+		})
+	})
+
+	// Save play queue and report device state
+	go a.playQueueSaver()
+	go func() {
+		for range time.Tick(playQueueSaveInterval) {
+			a.cviewApp.QueueUpdate(func() {
+				a.SavePlayQueue()
+				a.playerComponent.ReportState()
+			})
+		}
+	}()
 
 	// Start event loop
 	a.cviewApp.SetFocus(a.libraryComponent)
@@ -171,6 +230,7 @@ func (a *App) ConfirmExit() {
 	modal.AddButtons([]string{"Quit", "Cancel"})
 	modal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
 		if buttonLabel == "Quit" {
+			a.stopPlayQueueSaver()
 			a.cviewApp.Stop()
 		} else {
 			a.pagesComponent.HidePage("exitConfirm")
@@ -430,14 +490,22 @@ func (a *App) ClientErrorMessage(message string, cliErr restClientV1.ClientError
 }
 
 func (a *App) Reload() {
+	a.reload(nil)
+}
+
+// reload refreshes the in memory Db and calls onSuccess once views are refreshed
+func (a *App) reload(onSuccess func()) {
 
 	mfModal := a.OpenModalMessage("Syncing...")
 
 	go func() {
-		defer mfModal.Close()
-
 		// Refresh In memory Db
-		cliErr := a.localDb.Refresh()
+		cliErr := a.localDb.Refresh(func(changeCount int64, totalChangeCount int64) {
+			a.cviewApp.QueueUpdateDraw(func() {
+				mfModal.SetText(fmt.Sprintf("Syncing... %d/%d changes", changeCount, totalChangeCount))
+			})
+		})
+		mfModal.Close()
 		if cliErr != nil {
 			a.ClientErrorMessage("Unable to load data from mifasolsrv", cliErr)
 			return
@@ -447,17 +515,129 @@ func (a *App) Reload() {
 		a.currentComponent.RefreshView()
 
 		a.Message(strconv.Itoa(len(a.localDb.Songs)) + " songs, " + strconv.Itoa(len(a.localDb.Artists)) + " artists, " + strconv.Itoa(len(a.localDb.Albums)) + " albums, " + strconv.Itoa(len(a.localDb.Playlists)) + " playlists ready to be played for " + strconv.Itoa(len(a.localDb.Users)) + " users.")
+
+		if onSuccess != nil {
+			onSuccess()
+		}
 	}()
 }
 
+// autoReload silently refreshes the in memory Db with the changes made by other clients
+func (a *App) autoReload() {
+	cliErr := a.localDb.Refresh(nil)
+	if cliErr != nil {
+		a.ClientErrorMessage("Unable to load data from mifasolsrv", cliErr)
+		return
+	}
+
+	a.cviewApp.QueueUpdateDraw(func() {
+		a.libraryComponent.RefreshView()
+		a.currentComponent.RefreshView()
+	})
+}
+
 func (a *App) LocalDb() *localdb.LocalDb {
 	return a.localDb
 }
 
 func (a *App) Play(songId restApiV1.SongId) {
+	a.currentComponent.ForgetPlayingSong()
 	a.playerComponent.Play(songId)
 }
 
+// proposePlayQueueResume asks the user to resume the play queue saved by a previous session
+func (a *App) proposePlayQueueResume() {
+	playQueue, cliErr := a.restClient.ReadPlayQueue()
+	if cliErr != nil {
+		a.ClientErrorMessage("Unable to load the play queue", cliErr)
+		return
+	}
+
+	a.cviewApp.QueueUpdateDraw(func() {
+		if len(playQueue.SongIds) == 0 {
+			a.enablePlayQueueSave()
+			return
+		}
+
+		text := fmt.Sprintf("Do you want to resume where you left off (%d songs) ?", len(playQueue.SongIds))
+		if playQueue.CurrentIndex >= 0 {
+			if song, ok := a.localDb.Songs[playQueue.SongIds[playQueue.CurrentIndex]]; ok {
+				text = fmt.Sprintf("Do you want to resume \"%s\" where you left off (%d songs) ?", song.Name, len(playQueue.SongIds))
+			}
+		}
+
+		currentFocus := a.cviewApp.GetFocus()
+		modal := cview.NewModal()
+		modal.SetText(text)
+		modal.AddButtons([]string{"Resume", "No"})
+		modal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			a.pagesComponent.HidePage("playQueueResume")
+			a.pagesComponent.RemovePage("playQueueResume")
+			a.cviewApp.SetFocus(currentFocus)
+
+			if buttonLabel == "Resume" {
+				a.currentComponent.LoadPlayQueue(playQueue)
+				a.enablePlayQueueSave()
+				if playQueue.CurrentIndex >= 0 {
+					songId := playQueue.SongIds[playQueue.CurrentIndex]
+					a.playerComponent.PlayAt(songId, time.Duration(playQueue.Position*float64(time.Second)))
+				}
+			} else {
+				a.enablePlayQueueSave()
+			}
+		})
+		a.pagesComponent.AddPage("playQueueResume", modal, false, true)
+	})
+}
+
+// enablePlayQueueSave starts saving the play queue on the server, from its current state
+func (a *App) enablePlayQueueSave() {
+	a.savedPlayQueueMeta = a.currentComponent.PlayQueueMeta(a.playerComponent.Position())
+	a.playQueueSaveFg = true
+}
+
+// SavePlayQueue asynchronously saves the play queue on the server when it has changed
+func (a *App) SavePlayQueue() {
+	if !a.playQueueSaveFg {
+		return
+	}
+
+	playQueueMeta := a.currentComponent.PlayQueueMeta(a.playerComponent.Position())
+	if reflect.DeepEqual(playQueueMeta, a.savedPlayQueueMeta) {
+		return
+	}
+	a.savedPlayQueueMeta = playQueueMeta
+
+	// Only the last version of the play queue needs to be saved
+	select {
+	case <-a.playQueueMetaToSaveCh:
+	default:
+	}
+	a.playQueueMetaToSaveCh <- playQueueMeta
+}
+
+func (a *App) playQueueSaver() {
+	for playQueueMeta := range a.playQueueMetaToSaveCh {
+		_, cliErr := a.restClient.UpdatePlayQueue(playQueueMeta)
+		if cliErr != nil {
+			a.ClientErrorMessage("Unable to save the play queue", cliErr)
+		}
+	}
+	close(a.playQueueSaverDoneCh)
+}
+
+// stopPlayQueueSaver saves the last version of the play queue before exiting
+func (a *App) stopPlayQueueSaver() {
+	a.SavePlayQueue()
+	a.playQueueSaveFg = false
+	close(a.playQueueMetaToSaveCh)
+
+	select {
+	case <-a.playQueueSaverDoneCh:
+	case <-time.After(5 * time.Second):
+	}
+}
+
 func (a *App) CurrentComponent() *CurrentComponent {
 	return a.currentComponent
 }
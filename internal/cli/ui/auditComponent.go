@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"code.rocketnine.space/tslocum/cview"
+	"github.com/gdamore/tcell/v2"
+	"github.com/jypelle/mifasol/internal/cli/ui/color"
+	"github.com/jypelle/mifasol/restApiV1"
+	"strconv"
+	"time"
+)
+
+type AuditComponent struct {
+	*cview.Flex
+	form               *cview.Form
+	userDropDown       *cview.DropDown
+	entityTypeDropDown *cview.DropDown
+	entityIdInputField *cview.InputField
+	fromDateInputField *cview.InputField
+	toDateInputField   *cview.InputField
+	entries            *cview.TextView
+	uiApp              *App
+	users              []*restApiV1.User
+	originPrimitive    cview.Primitive
+}
+
+const auditDateLayout = "2006-01-02"
+
+func OpenAuditComponent(uiApp *App, originPrimitive cview.Primitive) {
+
+	// Only admin can read the audit trail
+	if !uiApp.IsConnectedUserAdmin() {
+		uiApp.WarningMessage("Only administrator can read the audit trail")
+		return
+	}
+
+	c := &AuditComponent{
+		uiApp:           uiApp,
+		users:           uiApp.localDb.OrderedUsers,
+		originPrimitive: originPrimitive,
+	}
+
+	// User
+	c.userDropDown = cview.NewDropDown()
+	c.userDropDown.SetLabel("User")
+	c.userDropDown.AddOptionsSimple("(All)")
+	for _, user := range c.users {
+		c.userDropDown.AddOptionsSimple(user.Name)
+	}
+	c.userDropDown.SetCurrentOption(0)
+
+	// Entity
+	c.entityTypeDropDown = cview.NewDropDown()
+	c.entityTypeDropDown.SetLabel("Entity")
+	c.entityTypeDropDown.AddOptionsSimple("(All)")
+	for _, entityType := range restApiV1.AuditEntityTypes {
+		c.entityTypeDropDown.AddOptionsSimple(string(entityType))
+	}
+	c.entityTypeDropDown.SetCurrentOption(0)
+
+	c.entityIdInputField = cview.NewInputField()
+	c.entityIdInputField.SetLabel("Entity id")
+	c.entityIdInputField.SetFieldWidth(26)
+
+	// Dates
+	c.fromDateInputField = cview.NewInputField()
+	c.fromDateInputField.SetLabel("From (YYYY-MM-DD)")
+	c.fromDateInputField.SetFieldWidth(10)
+
+	c.toDateInputField = cview.NewInputField()
+	c.toDateInputField.SetLabel("To (YYYY-MM-DD)")
+	c.toDateInputField.SetFieldWidth(10)
+
+	c.form = cview.NewForm()
+	c.form.SetFieldTextColorFocused(cview.Styles.PrimitiveBackgroundColor)
+	c.form.SetFieldBackgroundColorFocused(cview.Styles.PrimaryTextColor)
+
+	c.form.AddFormItem(c.userDropDown)
+	c.form.AddFormItem(c.entityTypeDropDown)
+	c.form.AddFormItem(c.entityIdInputField)
+	c.form.AddFormItem(c.fromDateInputField)
+	c.form.AddFormItem(c.toDateInputField)
+
+	c.form.AddButton("Search", c.search)
+	c.form.AddButton("Close", c.close)
+	c.form.SetBorder(true)
+	c.form.SetTitle("Audit trail")
+
+	// Entries, scrollable once searched, until <ESC> or <TAB> gives the focus back to the form
+	c.entries = cview.NewTextView()
+	c.entries.SetDynamicColors(true)
+	c.entries.SetScrollable(true)
+	c.entries.SetBorder(true)
+	c.entries.SetDoneFunc(func(key tcell.Key) {
+		c.uiApp.cviewApp.SetFocus(c.form)
+	})
+
+	c.Flex = cview.NewFlex()
+	c.Flex.SetDirection(cview.FlexRow)
+	c.Flex.AddItem(c.form, 15, 0, true)
+	c.Flex.AddItem(c.entries, 0, 1, false)
+
+	uiApp.pagesComponent.AddAndSwitchToPage("audit", c, true)
+
+	c.search()
+}
+
+func (c *AuditComponent) search() {
+	var auditEntryFilter restApiV1.AuditEntryFilter
+
+	selectedUserInd, _ := c.userDropDown.GetCurrentOption()
+	if selectedUserInd > 0 {
+		auditEntryFilter.UserId = &c.users[selectedUserInd-1].Id
+	}
+	selectedEntityTypeInd, _ := c.entityTypeDropDown.GetCurrentOption()
+	if selectedEntityTypeInd > 0 {
+		auditEntryFilter.EntityType = &restApiV1.AuditEntityTypes[selectedEntityTypeInd-1]
+	}
+	if entityId := c.entityIdInputField.GetText(); entityId != "" {
+		auditEntryFilter.EntityId = &entityId
+	}
+	if fromDate, err := time.ParseInLocation(auditDateLayout, c.fromDateInputField.GetText(), time.Local); err == nil {
+		fromTs := fromDate.UnixNano()
+		auditEntryFilter.FromTs = &fromTs
+	}
+	// The whole last day is included
+	if toDate, err := time.ParseInLocation(auditDateLayout, c.toDateInputField.GetText(), time.Local); err == nil {
+		toTs := toDate.AddDate(0, 0, 1).UnixNano()
+		auditEntryFilter.ToTs = &toTs
+	}
+
+	go func() {
+		auditEntries, cliErr := c.uiApp.restClient.ReadAuditEntries(&auditEntryFilter)
+		if cliErr != nil {
+			c.uiApp.ClientErrorMessage("Unable to retrieve the audit trail", cliErr)
+			return
+		}
+
+		c.uiApp.cviewApp.QueueUpdateDraw(func() {
+			c.entries.SetTitle(strconv.Itoa(len(auditEntries)) + " entries")
+			c.entries.SetText(c.auditEntriesText(auditEntries))
+			c.entries.ScrollToBeginning()
+			c.uiApp.cviewApp.SetFocus(c.entries)
+		})
+	}()
+}
+
+func (c *AuditComponent) auditEntriesText(auditEntries []restApiV1.AuditEntry) string {
+	if len(auditEntries) == 0 {
+		return "No audit entry"
+	}
+
+	text := ""
+	for _, auditEntry := range auditEntries {
+		text += time.Unix(0, auditEntry.Ts).Format("2006-01-02 15:04:05") + " " +
+			"[" + color.ColorUserStr + "]" + cview.Escape(auditEntry.UserName) + "[" + color.ColorWhiteStr + "]" +
+			" (" + cview.Escape(auditEntry.ClientIp) + ") " +
+			string(auditEntry.Action) + " " + string(auditEntry.EntityType) + " " +
+			"[" + color.ColorSongStr + "::b]" + cview.Escape(auditEntry.EntityName) + "[" + color.ColorWhiteStr + "::-]" +
+			" " + cview.Escape(auditEntry.EntityId) + "\n"
+		for _, change := range auditEntry.Changes {
+			text += "    " + cview.Escape(change.Field) + ": " + cview.Escape(auditChangeValue(change.Before)) + " -> " + cview.Escape(auditChangeValue(change.After)) + "\n"
+		}
+	}
+
+	return text
+}
+
+func (c *AuditComponent) close() {
+	c.uiApp.pagesComponent.RemovePage("audit")
+	c.uiApp.cviewApp.SetFocus(c.originPrimitive)
+}
+
+// auditChangeValue returns the JSON value of a changed field, as displayed
+func auditChangeValue(value []byte) string {
+	if len(value) == 0 {
+		return "null"
+	}
+	return string(value)
+}
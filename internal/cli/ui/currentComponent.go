@@ -18,13 +18,20 @@ type CurrentComponent struct {
 	srcPlaylistId *restApiV1.PlaylistId
 	modified      bool
 
+	// Index of the playing song, -1 when the playing song doesn't come from the current playlist
+	playingIdx int
+	shuffleFg  bool
+	repeatMode restApiV1.RepeatMode
+
 	uiApp *App
 }
 
 func NewCurrentComponent(uiApp *App) *CurrentComponent {
 
 	c := &CurrentComponent{
-		uiApp: uiApp,
+		uiApp:      uiApp,
+		playingIdx: -1,
+		repeatMode: restApiV1.RepeatModeOff,
 	}
 
 	c.title = cview.NewTextView()
@@ -86,7 +93,9 @@ func NewCurrentComponent(uiApp *App) *CurrentComponent {
 				// Shuffle songs list
 				rand.Shuffle(len(c.songIds), func(i, j int) {
 					c.songIds[i], c.songIds[j] = c.songIds[j], c.songIds[i]
+					c.swapPlayingIdx(i, j)
 				})
+				c.shuffleFg = true
 				c.SetModified(true)
 				c.RefreshView()
 
@@ -95,6 +104,11 @@ func NewCurrentComponent(uiApp *App) *CurrentComponent {
 					oldIndex := c.list.GetCurrentItem()
 					c.list.RemoveItem(oldIndex)
 					c.songIds = append(c.songIds[:oldIndex], c.songIds[oldIndex+1:]...)
+					if oldIndex == c.playingIdx {
+						c.playingIdx = -1
+					} else if oldIndex < c.playingIdx {
+						c.playingIdx--
+					}
 					c.SetModified(true)
 				}
 			case '8':
@@ -109,6 +123,7 @@ func NewCurrentComponent(uiApp *App) *CurrentComponent {
 
 						c.songIds[srcIndex-1] = c.songIds[srcIndex]
 						c.songIds[srcIndex] = songIdToMove
+						c.swapPlayingIdx(srcIndex-1, srcIndex)
 						c.SetModified(true)
 					}
 				}
@@ -124,14 +139,27 @@ func NewCurrentComponent(uiApp *App) *CurrentComponent {
 
 						c.songIds[srcIndex+1] = c.songIds[srcIndex]
 						c.songIds[srcIndex] = songIdToMove
+						c.swapPlayingIdx(srcIndex+1, srcIndex)
 						c.SetModified(true)
 					}
 
 				}
+			case 't':
+				// Switch repeat mode
+				switch c.repeatMode {
+				case restApiV1.RepeatModeOff:
+					c.repeatMode = restApiV1.RepeatModeAll
+				case restApiV1.RepeatModeAll:
+					c.repeatMode = restApiV1.RepeatModeOne
+				default:
+					c.repeatMode = restApiV1.RepeatModeOff
+				}
+				c.SetModified(c.modified)
 			}
 		case event.Key() == tcell.KeyEnter:
 			if len(c.songIds) > 0 {
-				songId := c.songIds[c.list.GetCurrentItem()]
+				c.playingIdx = c.list.GetCurrentItem()
+				songId := c.songIds[c.playingIdx]
 				c.uiApp.playerComponent.Play(songId)
 				return nil
 			}
@@ -165,6 +193,13 @@ func (c *CurrentComponent) SetModified(modified bool) {
 	if c.modified {
 		title += " *"
 	}
+
+	switch c.repeatMode {
+	case restApiV1.RepeatModeAll:
+		title += " (repeat all)"
+	case restApiV1.RepeatModeOne:
+		title += " (repeat one)"
+	}
 	c.title.SetText(title)
 }
 
@@ -268,26 +303,124 @@ func (c *CurrentComponent) LoadSongsFromPlaylist(playlist *restApiV1.Playlist) {
 	c.SetModified(false)
 }
 
+// GetNextSong returns the song to play when the playing one has ended
 func (c *CurrentComponent) GetNextSong() *restApiV1.SongId {
+	if c.repeatMode == restApiV1.RepeatModeOne && c.playingIdx >= 0 {
+		c.list.SetCurrentItem(c.playingIdx)
+		return &c.songIds[c.playingIdx]
+	}
+
+	return c.SkipToNextSong()
+}
+
+// SkipToNextSong returns the song following the highlighted one
+func (c *CurrentComponent) SkipToNextSong() *restApiV1.SongId {
 	nextPosition := c.list.GetCurrentItem() + 1
+	if nextPosition >= len(c.songIds) && c.repeatMode == restApiV1.RepeatModeAll {
+		nextPosition = 0
+	}
 	if nextPosition < len(c.songIds) {
 		c.list.SetCurrentItem(nextPosition)
+		c.playingIdx = nextPosition
 		return &c.songIds[nextPosition]
 	}
 
+	c.playingIdx = -1
 	return nil
 }
 
+// ForgetPlayingSong notifies that the playing song doesn't come from the current playlist
+func (c *CurrentComponent) ForgetPlayingSong() {
+	c.playingIdx = -1
+}
+
+func (c *CurrentComponent) swapPlayingIdx(i, j int) {
+	if c.playingIdx == i {
+		c.playingIdx = j
+	} else if c.playingIdx == j {
+		c.playingIdx = i
+	}
+}
+
+// PlayQueueMeta returns the current playlist as a play queue, position being the elapsed time in the playing song
+func (c *CurrentComponent) PlayQueueMeta(position float64) *restApiV1.PlayQueueMeta {
+	playQueueMeta := &restApiV1.PlayQueueMeta{
+		SongIds:      append([]restApiV1.SongId{}, c.songIds...),
+		CurrentIndex: int64(c.playingIdx),
+		ShuffleFg:    c.shuffleFg,
+		RepeatMode:   c.repeatMode,
+	}
+	if c.playingIdx >= 0 {
+		playQueueMeta.Position = position
+	}
+	if c.srcPlaylistId != nil {
+		srcPlaylistId := *c.srcPlaylistId
+		playQueueMeta.SrcPlaylistId = &srcPlaylistId
+	}
+
+	return playQueueMeta
+}
+
+// LoadPlayQueue replaces the current playlist by a play queue saved on the server
+func (c *CurrentComponent) LoadPlayQueue(playQueue *restApiV1.PlayQueue) {
+	c.Clear()
+
+	for idx, songId := range playQueue.SongIds {
+		if _, ok := c.uiApp.localDb.Songs[songId]; !ok {
+			continue
+		}
+		if int64(idx) == playQueue.CurrentIndex {
+			c.playingIdx = len(c.songIds)
+		}
+		c.songIds = append(c.songIds, songId)
+		c.list.AddItem(c.getMainTextSong(songId, -1))
+	}
+	c.shuffleFg = playQueue.ShuffleFg
+	c.repeatMode = playQueue.RepeatMode
+
+	modified := true
+	if playQueue.SrcPlaylistId != nil {
+		if playlist, ok := c.uiApp.localDb.Playlists[*playQueue.SrcPlaylistId]; ok {
+			srcPlaylistId := playlist.Id
+			c.srcPlaylistId = &srcPlaylistId
+			modified = !sameSongIds(c.songIds, playlist.SongIds)
+		}
+	}
+	c.SetModified(modified)
+
+	if c.playingIdx >= 0 {
+		c.list.SetCurrentItem(c.playingIdx)
+	}
+}
+
+func sameSongIds(songIds1 []restApiV1.SongId, songIds2 []restApiV1.SongId) bool {
+	if len(songIds1) != len(songIds2) {
+		return false
+	}
+	for idx := range songIds1 {
+		if songIds1[idx] != songIds2[idx] {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *CurrentComponent) RefreshView() {
 	oldIndex := c.list.GetCurrentItem()
 	oldSongIds := c.songIds
 	oldSrcPlaylistId := c.srcPlaylistId
+	oldPlayingIdx := c.playingIdx
+	oldShuffleFg := c.shuffleFg
 	c.Clear()
+	c.shuffleFg = oldShuffleFg
 
 	// Remove deleted songId
-	for _, songId := range oldSongIds {
+	for idx, songId := range oldSongIds {
 
 		if _, ok := c.uiApp.localDb.Songs[songId]; ok {
+			if idx == oldPlayingIdx {
+				c.playingIdx = len(c.songIds)
+			}
 			c.songIds = append(c.songIds, songId)
 			c.list.AddItem(c.getMainTextSong(songId, -1))
 		}
@@ -310,5 +443,7 @@ func (c *CurrentComponent) Clear() {
 	c.list.Clear()
 	c.songIds = []restApiV1.SongId{}
 	c.srcPlaylistId = nil
+	c.playingIdx = -1
+	c.shuffleFg = false
 	c.list.SetCurrentItem(0)
 }
@@ -28,6 +28,8 @@ func NewHelpComponent(uiApp *App) *HelpComponent {
 
 'h'          : Show / Hide this sideview
 'p'          : Play / Pause
+'o'          : Remote control another device
+'g'          : Show audit trail (admin)
 '+'          : Increase volume
 '-'          : Decrease Volume
 <CTL>+<LEFT> : Go forward (5s)
@@ -39,11 +41,13 @@ func NewHelpComponent(uiApp *App) *HelpComponent {
 [` + color.ColorHelpTitle2Str + `::u]"Library" shortcuts[-::-]
 
 'c'    : Create album / artist
-'e'    : Edit song / album / artist / playlist
+'e'    : Edit song / album / artist / playlist (or selected songs)
 'd'    : Delete song / album / artist / playlist
 'a'    : Add song / album / artist / playlist to current playlist
 'l'    : Load song / album / artist / playlist to current playlist
 'f'    : Add to / Remove from favorite songs / playlists
+'s'    : Select / Unselect song for batch editing
+'u'    : Unselect all songs
 '/'    : Filter by song / album / artist name
 <LEFT> : Previous item
 <RIGHT>: Next item
@@ -59,6 +63,7 @@ func NewHelpComponent(uiApp *App) *HelpComponent {
 'z'    : Save to existing or new playlist
 '8'    : Move up highlighted song
 '2'    : Move down highlighted song
+'t'    : Switch repeat mode (off / all / one)
 <ENTER>: Play song
 `,
 	)
@@ -23,6 +23,7 @@ type LibraryComponent struct {
 	albums               []*restApiV1.Album
 	artists              []*restApiV1.Artist
 	playlists            []*restApiV1.Playlist
+	selectedSongIds      map[restApiV1.SongId]struct{}
 }
 
 type libraryFilter struct {
@@ -153,7 +154,8 @@ var libraryMenus = []libraryMenu{
 func NewLibraryComponent(uiApp *App) *LibraryComponent {
 
 	c := &LibraryComponent{
-		uiApp: uiApp,
+		uiApp:           uiApp,
+		selectedSongIds: make(map[restApiV1.SongId]struct{}),
 	}
 
 	c.title = cview.NewTextView()
@@ -301,7 +303,31 @@ func NewLibraryComponent(uiApp *App) *LibraryComponent {
 				}
 				return nil
 
+			case 's':
+				if c.list.GetItemCount() > 0 && currentFilter.libraryType == libraryTypeSongs {
+					song := c.songs[c.list.GetCurrentItem()]
+					if _, ok := c.selectedSongIds[song.Id]; ok {
+						delete(c.selectedSongIds, song.Id)
+					} else {
+						c.selectedSongIds[song.Id] = struct{}{}
+					}
+					c.RefreshList()
+					c.list.SetCurrentItem(c.list.GetCurrentItem() + 1)
+				}
+				return nil
+
+			case 'u':
+				if len(c.selectedSongIds) > 0 {
+					c.ClearSelectedSongs()
+					c.RefreshList()
+				}
+				return nil
+
 			case 'e':
+				if currentFilter.libraryType == libraryTypeSongs && len(c.selectedSongIds) > 0 {
+					OpenSongsEditComponent(c.uiApp, c.SelectedSongIds(), c)
+					return nil
+				}
 				if c.list.GetItemCount() > 0 && currentFilter.libraryType != libraryTypeMenu {
 					switch currentFilter.libraryType {
 					case libraryTypeArtists:
@@ -630,6 +656,13 @@ func (c *LibraryComponent) RefreshList() {
 		}
 	}
 
+	// Unselect deleted songs
+	for songId := range c.selectedSongIds {
+		if _, ok := c.uiApp.LocalDb().Songs[songId]; !ok {
+			delete(c.selectedSongIds, songId)
+		}
+	}
+
 	currentFilter = c.currentFilter()
 	c.list.Clear()
 	oldIndex := currentFilter.index
@@ -754,6 +787,9 @@ func (c *LibraryComponent) RefreshList() {
 			c.songs = filteredSongs
 		}
 		c.loadSongs(c.songs, currentFilter.albumId, currentFilter.artistId)
+		if len(c.selectedSongIds) > 0 {
+			title += " (" + strconv.Itoa(len(c.selectedSongIds)) + " selected)"
+		}
 	case libraryTypeUsers:
 		for _, user := range c.uiApp.LocalDb().OrderedUsers {
 			c.list.AddItem(c.getMainTextUser(user))
@@ -805,6 +841,12 @@ func (c *LibraryComponent) getMainTextSong(song *restApiV1.Song, fromAlbumId *re
 	currentPosition := 0
 	text := ""
 
+	if _, ok := c.selectedSongIds[song.Id]; ok {
+		text += "✓ "
+	} else {
+		text += "  "
+	}
+
 	myFavoriteSongIds := c.uiApp.LocalDb().UserFavoriteSongIds[c.uiApp.ConnectedUserId()]
 	if _, ok := myFavoriteSongIds[song.Id]; ok {
 		text += "■ "
@@ -1000,6 +1042,19 @@ func (c *LibraryComponent) getMainTextUser(user *restApiV1.User) string {
 	return userName
 }
 
+// SelectedSongIds returns the ids of the songs selected for batch editing
+func (c *LibraryComponent) SelectedSongIds() []restApiV1.SongId {
+	songIds := make([]restApiV1.SongId, 0, len(c.selectedSongIds))
+	for songId := range c.selectedSongIds {
+		songIds = append(songIds, songId)
+	}
+	return songIds
+}
+
+func (c *LibraryComponent) ClearSelectedSongs() {
+	c.selectedSongIds = make(map[restApiV1.SongId]struct{})
+}
+
 func (c *LibraryComponent) Focus(delegate func(cview.Primitive)) {
 	delegate(c.list)
 }
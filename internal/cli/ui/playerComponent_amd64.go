@@ -17,6 +17,16 @@ import (
 	"time"
 )
 
+// Commands the player can execute for other devices
+var deviceCapabilities = []restApiV1.DeviceCommandType{
+	restApiV1.PlayDeviceCommandType,
+	restApiV1.PauseDeviceCommandType,
+	restApiV1.NextDeviceCommandType,
+	restApiV1.SeekDeviceCommandType,
+	restApiV1.VolumeDeviceCommandType,
+	restApiV1.QueueDeviceCommandType,
+}
+
 type PlayerComponent struct {
 	*cview.Flex
 	titleBox    *cview.TextView
@@ -106,6 +116,7 @@ func (c *PlayerComponent) SetVolume(volume int) {
 	} else {
 		c.volumeBox.SetText("[" + color.ColorTitleStr + "]🔉" + fmt.Sprintf("%3d", c.volume) + "%")
 	}
+	c.ReportState()
 }
 
 func (c *PlayerComponent) PauseResume() {
@@ -120,6 +131,8 @@ func (c *PlayerComponent) PauseResume() {
 		} else {
 			c.titleBox.SetText("[" + color.ColorTitleStr + "]Playing: " + c.getCompleteMainTextSong(c.playingSong))
 		}
+		c.uiApp.SavePlayQueue()
+		c.ReportState()
 	}
 }
 
@@ -147,6 +160,7 @@ func (c *PlayerComponent) GoBackward() {
 	}
 	speaker.Unlock()
 	c.refreshProgress()
+	c.ReportState()
 }
 
 func (c *PlayerComponent) GoForward() {
@@ -161,9 +175,29 @@ func (c *PlayerComponent) GoForward() {
 	}
 	speaker.Unlock()
 	c.refreshProgress()
+	c.ReportState()
+}
+
+// SeekTo goes to a position in the playing song
+func (c *PlayerComponent) SeekTo(position time.Duration) {
+	speaker.Lock()
+	if c.musicStreamer != nil {
+		newPosition := c.musicFormat.SampleRate.N(position)
+		if newPosition < c.musicStreamer.Len() {
+			c.musicStreamer.Seek(newPosition)
+		}
+	}
+	speaker.Unlock()
+	c.refreshProgress()
+	c.ReportState()
 }
 
 func (c *PlayerComponent) Play(songId restApiV1.SongId) {
+	c.PlayAt(songId, 0)
+}
+
+// PlayAt plays a song from a given position
+func (c *PlayerComponent) PlayAt(songId restApiV1.SongId, position time.Duration) {
 	song, ok := c.uiApp.localDb.Songs[songId]
 	if !ok {
 		c.uiApp.WarningMessage("Unknown song id: " + string(songId))
@@ -208,6 +242,13 @@ func (c *PlayerComponent) Play(songId restApiV1.SongId) {
 		return
 	}
 
+	if position > 0 {
+		newPosition := c.musicFormat.SampleRate.N(position)
+		if newPosition < c.musicStreamer.Len() {
+			c.musicStreamer.Seek(newPosition)
+		}
+	}
+
 	if c.musicFormat.SampleRate == 44100 {
 		c.controlStreamer = &beep.Ctrl{Streamer: c.musicStreamer, Paused: false}
 	} else {
@@ -235,6 +276,8 @@ func (c *PlayerComponent) Play(songId restApiV1.SongId) {
 						nextSongId := c.uiApp.currentComponent.GetNextSong()
 						if nextSongId != nil {
 							c.Play(*nextSongId)
+						} else {
+							c.ReportState()
 						}
 					})
 				},
@@ -245,6 +288,70 @@ func (c *PlayerComponent) Play(songId restApiV1.SongId) {
 	c.titleBox.SetText("[" + color.ColorTitleStr + "]Playing: " + c.getCompleteMainTextSong(c.playingSong))
 	c.uiApp.cviewApp.Draw()
 
+	c.uiApp.SavePlayQueue()
+	c.ReportState()
+}
+
+// ExecuteCommand executes a command sent by another device
+func (c *PlayerComponent) ExecuteCommand(command *restApiV1.DeviceCommand) {
+	switch command.Type {
+	case restApiV1.PlayDeviceCommandType:
+		if c.controlStreamer != nil {
+			if c.controlStreamer.Paused {
+				c.PauseResume()
+			}
+		} else if c.playingSong != nil {
+			c.Play(c.playingSong.Id)
+		}
+	case restApiV1.PauseDeviceCommandType:
+		if c.controlStreamer != nil && !c.controlStreamer.Paused {
+			c.PauseResume()
+		}
+	case restApiV1.NextDeviceCommandType:
+		nextSongId := c.uiApp.currentComponent.SkipToNextSong()
+		if nextSongId != nil {
+			c.Play(*nextSongId)
+		}
+	case restApiV1.SeekDeviceCommandType:
+		c.SeekTo(time.Duration(command.Position * float64(time.Second)))
+	case restApiV1.VolumeDeviceCommandType:
+		c.SetVolume(int(command.Volume))
+	case restApiV1.QueueDeviceCommandType:
+		c.uiApp.currentComponent.LoadPlayQueue(&restApiV1.PlayQueue{
+			PlayQueueMeta: restApiV1.PlayQueueMeta{
+				SongIds:      command.SongIds,
+				CurrentIndex: command.CurrentIndex,
+				RepeatMode:   c.uiApp.currentComponent.repeatMode,
+			},
+		})
+		c.Play(command.SongIds[command.CurrentIndex])
+	}
+}
+
+// ReportState sends the "now playing" state to the server, for the devices remotely controlling this one
+func (c *PlayerComponent) ReportState() {
+	state := &restApiV1.DeviceState{
+		Volume: int64(c.volume),
+	}
+	if c.playingSong != nil && c.controlStreamer != nil {
+		songId := c.playingSong.Id
+		state.SongId = &songId
+		state.Position = c.Position()
+		speaker.Lock()
+		state.PausedFg = c.controlStreamer.Paused
+		speaker.Unlock()
+	}
+	c.uiApp.device.ReportState(state)
+}
+
+// Position returns the elapsed time in the playing song, in seconds
+func (c *PlayerComponent) Position() float64 {
+	speaker.Lock()
+	defer speaker.Unlock()
+	if c.musicStreamer != nil {
+		return c.musicFormat.SampleRate.D(c.musicStreamer.Position()).Seconds()
+	}
+	return 0
 }
 
 func (c *PlayerComponent) getMainTextSong(song *restApiV1.Song) string {
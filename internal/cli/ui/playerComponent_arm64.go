@@ -8,6 +8,9 @@ import (
 	"time"
 )
 
+// Commands the player can execute for other devices
+var deviceCapabilities = []restApiV1.DeviceCommandType{}
+
 type PlayerComponent struct {
 	*cview.Flex
 	titleBox    *cview.TextView
@@ -71,3 +74,19 @@ func (c *PlayerComponent) GoForward() {
 
 func (c *PlayerComponent) Play(songId restApiV1.SongId) {
 }
+
+func (c *PlayerComponent) PlayAt(songId restApiV1.SongId, position time.Duration) {
+}
+
+func (c *PlayerComponent) Position() float64 {
+	return 0
+}
+
+func (c *PlayerComponent) SeekTo(position time.Duration) {
+}
+
+func (c *PlayerComponent) ExecuteCommand(command *restApiV1.DeviceCommand) {
+}
+
+func (c *PlayerComponent) ReportState() {
+}
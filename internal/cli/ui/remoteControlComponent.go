@@ -0,0 +1,174 @@
+package ui
+
+import (
+	"code.rocketnine.space/tslocum/cview"
+	"fmt"
+	"github.com/jypelle/mifasol/restApiV1"
+	"time"
+)
+
+// Volume step of the remote control
+const remoteVolumeStep = 10
+
+// OpenRemoteControl lets the user choose another device to control
+func (a *App) OpenRemoteControl() {
+	go func() {
+		devices, cliErr := a.restClient.ReadDevices()
+		if cliErr != nil {
+			a.ClientErrorMessage("Unable to retrieve devices", cliErr)
+			return
+		}
+
+		var otherDevices []restApiV1.Device
+		for _, device := range devices {
+			if device.Id != a.device.Id {
+				otherDevices = append(otherDevices, device)
+			}
+		}
+
+		a.cviewApp.QueueUpdateDraw(func() {
+			if len(otherDevices) == 0 {
+				a.WarningMessage("No other device connected")
+				return
+			}
+
+			currentFocus := a.cviewApp.GetFocus()
+
+			var buttons []string
+			for _, device := range otherDevices {
+				buttons = append(buttons, device.Name)
+			}
+			buttons = append(buttons, "Cancel")
+
+			modal := cview.NewModal()
+			modal.SetText("Which device do you want to control ?")
+			modal.AddButtons(buttons)
+			modal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				a.pagesComponent.HidePage("remoteDeviceChoice")
+				a.pagesComponent.RemovePage("remoteDeviceChoice")
+				a.cviewApp.SetFocus(currentFocus)
+
+				if buttonIndex >= 0 && buttonIndex < len(otherDevices) {
+					a.openRemoteDeviceControl(otherDevices[buttonIndex])
+				}
+			})
+			a.pagesComponent.AddPage("remoteDeviceChoice", modal, false, true)
+		})
+	}()
+}
+
+// openRemoteDeviceControl shows the "now playing" state of a device and sends it commands
+func (a *App) openRemoteDeviceControl(device restApiV1.Device) {
+	currentFocus := a.cviewApp.GetFocus()
+	deviceId := device.Id
+	deviceName := device.Name
+
+	var buttons []string
+	if device.HasCapability(restApiV1.PlayDeviceCommandType) {
+		buttons = append(buttons, "Play")
+	}
+	if device.HasCapability(restApiV1.PauseDeviceCommandType) {
+		buttons = append(buttons, "Pause")
+	}
+	if device.HasCapability(restApiV1.NextDeviceCommandType) {
+		buttons = append(buttons, "Next")
+	}
+	if device.HasCapability(restApiV1.VolumeDeviceCommandType) {
+		buttons = append(buttons, "Vol -", "Vol +")
+	}
+	if device.HasCapability(restApiV1.QueueDeviceCommandType) {
+		buttons = append(buttons, "Send playlist")
+	}
+	buttons = append(buttons, "Close")
+
+	modal := cview.NewModal()
+	modal.SetText(a.remoteDeviceText(&device))
+	modal.AddButtons(buttons)
+	modal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+		var command *restApiV1.DeviceCommand
+
+		switch buttonLabel {
+		case "Play":
+			command = &restApiV1.DeviceCommand{Type: restApiV1.PlayDeviceCommandType}
+		case "Pause":
+			command = &restApiV1.DeviceCommand{Type: restApiV1.PauseDeviceCommandType}
+		case "Next":
+			command = &restApiV1.DeviceCommand{Type: restApiV1.NextDeviceCommandType}
+		case "Vol -":
+			command = &restApiV1.DeviceCommand{Type: restApiV1.VolumeDeviceCommandType, Volume: device.State.Volume - remoteVolumeStep}
+		case "Vol +":
+			command = &restApiV1.DeviceCommand{Type: restApiV1.VolumeDeviceCommandType, Volume: device.State.Volume + remoteVolumeStep}
+		case "Send playlist":
+			if len(a.currentComponent.songIds) == 0 {
+				a.WarningMessage("Current playlist is empty")
+				return
+			}
+			command = &restApiV1.DeviceCommand{
+				Type:         restApiV1.QueueDeviceCommandType,
+				SongIds:      append([]restApiV1.SongId{}, a.currentComponent.songIds...),
+				CurrentIndex: int64(a.currentComponent.list.GetCurrentItem()),
+			}
+		default:
+			a.pagesComponent.HidePage("remoteDeviceControl")
+			a.pagesComponent.RemovePage("remoteDeviceControl")
+			a.cviewApp.SetFocus(currentFocus)
+			return
+		}
+
+		if command.Type == restApiV1.VolumeDeviceCommandType {
+			if command.Volume < 0 {
+				command.Volume = 0
+			} else if command.Volume > 100 {
+				command.Volume = 100
+			}
+		}
+
+		go func() {
+			_, cliErr := a.restClient.CreateDeviceCommand(deviceId, command)
+			if cliErr != nil {
+				a.ClientErrorMessage("Unable to send the command to \""+deviceName+"\"", cliErr)
+				return
+			}
+
+			// Let the device report its new state
+			time.Sleep(time.Second)
+
+			devices, cliErr := a.restClient.ReadDevices()
+			if cliErr != nil {
+				a.ClientErrorMessage("Unable to retrieve devices", cliErr)
+				return
+			}
+			for _, refreshedDevice := range devices {
+				if refreshedDevice.Id == deviceId {
+					refreshedDevice := refreshedDevice
+					a.cviewApp.QueueUpdateDraw(func() {
+						device = refreshedDevice
+						modal.SetText(a.remoteDeviceText(&device))
+					})
+				}
+			}
+		}()
+	})
+	a.pagesComponent.AddPage("remoteDeviceControl", modal, false, true)
+}
+
+func (a *App) remoteDeviceText(device *restApiV1.Device) string {
+	text := device.Name + "\n\n"
+
+	if device.State.SongId == nil {
+		text += "Stopped"
+	} else {
+		songName := "Unknown song"
+		if song, ok := a.localDb.Songs[*device.State.SongId]; ok {
+			songName = song.Name
+		}
+		position := time.Duration(device.State.Position) * time.Second
+		if device.State.PausedFg {
+			text += fmt.Sprintf("Paused: %s (%02d:%02d)", songName, position/time.Minute, (position%time.Minute)/time.Second)
+		} else {
+			text += fmt.Sprintf("Playing: %s (%02d:%02d)", songName, position/time.Minute, (position%time.Minute)/time.Second)
+		}
+	}
+
+	return text + fmt.Sprintf("\nVolume: %d%%", device.State.Volume)
+}
@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"code.rocketnine.space/tslocum/cview"
+	"github.com/jypelle/mifasol/restApiV1"
+	"strconv"
+)
+
+type SongsEditComponent struct {
+	*cview.Form
+	publicationYearInputField *cview.InputField
+	albumDropDown             *cview.DropDown
+	explicitFgDropDown        *cview.DropDown
+	addArtistDropDown         *cview.DropDown
+	removeArtistDropDown      *cview.DropDown
+	uiApp                     *App
+	songIds                   []restApiV1.SongId
+	originPrimitive           cview.Primitive
+}
+
+func OpenSongsEditComponent(uiApp *App, songIds []restApiV1.SongId, originPrimitive cview.Primitive) {
+
+	// Only admin can edit songs
+	if !uiApp.IsConnectedUserAdmin() {
+		uiApp.WarningMessage("Only administrator can edit songs")
+		return
+	}
+
+	c := &SongsEditComponent{
+		uiApp:           uiApp,
+		songIds:         songIds,
+		originPrimitive: originPrimitive,
+	}
+
+	// Publication year
+	c.publicationYearInputField = cview.NewInputField()
+	c.publicationYearInputField.SetLabel("Publication year (0 to remove)")
+	c.publicationYearInputField.SetFieldWidth(4)
+
+	// Album
+	c.albumDropDown = cview.NewDropDown()
+	c.albumDropDown.SetLabel("Album")
+	c.albumDropDown.AddOptionsSimple("(Unchanged)")
+	for ind, album := range uiApp.localDb.OrderedAlbums {
+		if ind == 0 {
+			c.albumDropDown.AddOptionsSimple("(Unknown album)")
+		} else {
+			c.albumDropDown.AddOptionsSimple(album.Name)
+		}
+	}
+	c.albumDropDown.SetCurrentOption(0)
+
+	// Explicit flag
+	c.explicitFgDropDown = cview.NewDropDown()
+	c.explicitFgDropDown.SetLabel("Explicit")
+	c.explicitFgDropDown.AddOptionsSimple("(Unchanged)")
+	c.explicitFgDropDown.AddOptionsSimple("Yes")
+	c.explicitFgDropDown.AddOptionsSimple("No")
+	c.explicitFgDropDown.SetCurrentOption(0)
+
+	// Artists
+	c.addArtistDropDown = c.newArtistDropDown("Add artist")
+	c.removeArtistDropDown = c.newArtistDropDown("Remove artist")
+
+	c.Form = cview.NewForm()
+	c.Form.SetFieldTextColorFocused(cview.Styles.PrimitiveBackgroundColor)
+	c.Form.SetFieldBackgroundColorFocused(cview.Styles.PrimaryTextColor)
+
+	c.Form.AddFormItem(c.publicationYearInputField)
+	c.Form.AddFormItem(c.albumDropDown)
+	c.Form.AddFormItem(c.explicitFgDropDown)
+	c.Form.AddFormItem(c.addArtistDropDown)
+	c.Form.AddFormItem(c.removeArtistDropDown)
+
+	c.Form.AddButton("Save", c.save)
+	c.Form.AddButton("Cancel", c.cancel)
+	c.Form.SetBorder(true)
+	c.Form.SetTitle("Edit " + strconv.Itoa(len(songIds)) + " songs")
+	uiApp.pagesComponent.AddAndSwitchToPage("songsEdit", c, true)
+
+}
+
+func (c *SongsEditComponent) newArtistDropDown(label string) *cview.DropDown {
+	artistDropDown := cview.NewDropDown()
+	artistDropDown.SetLabel(label)
+	for ind, artist := range c.uiApp.localDb.OrderedArtists {
+		if ind == 0 {
+			artistDropDown.AddOptionsSimple("(None)")
+		} else {
+			artistDropDown.AddOptionsSimple(artist.Name)
+		}
+	}
+	artistDropDown.SetCurrentOption(0)
+	return artistDropDown
+}
+
+func (c *SongsEditComponent) save() {
+	songsPatch := restApiV1.SongsPatch{SongIds: c.songIds}
+
+	// Publication year
+	if c.publicationYearInputField.GetText() != "" {
+		publicationYear, err := strconv.ParseInt(c.publicationYearInputField.GetText(), 10, 64)
+		if err == nil {
+			songsPatch.PublicationYear = &publicationYear
+		}
+	}
+
+	// Album
+	selectedAlbumInd, _ := c.albumDropDown.GetCurrentOption()
+	if selectedAlbumInd == 1 {
+		songsPatch.AlbumId = &restApiV1.UnknownAlbumId
+	} else if selectedAlbumInd > 1 {
+		songsPatch.AlbumId = &c.uiApp.localDb.OrderedAlbums[selectedAlbumInd-1].Id
+	}
+
+	// Explicit flag
+	selectedExplicitFgInd, _ := c.explicitFgDropDown.GetCurrentOption()
+	if selectedExplicitFgInd > 0 {
+		explicitFg := selectedExplicitFgInd == 1
+		songsPatch.ExplicitFg = &explicitFg
+	}
+
+	// Artists
+	selectedArtistInd, _ := c.addArtistDropDown.GetCurrentOption()
+	if selectedArtistInd > 0 {
+		songsPatch.AddArtistIds = []restApiV1.ArtistId{c.uiApp.localDb.OrderedArtists[selectedArtistInd].Id}
+	}
+	selectedArtistInd, _ = c.removeArtistDropDown.GetCurrentOption()
+	if selectedArtistInd > 0 {
+		songsPatch.RemoveArtistIds = []restApiV1.ArtistId{c.uiApp.localDb.OrderedArtists[selectedArtistInd].Id}
+	}
+
+	if !songsPatch.IsEmpty() {
+		_, cliErr := c.uiApp.restClient.UpdateSongs(&songsPatch)
+		if cliErr != nil {
+			c.uiApp.ClientErrorMessage("Unable to update the songs", cliErr)
+			return
+		}
+	}
+
+	c.close()
+	c.uiApp.libraryComponent.ClearSelectedSongs()
+	c.uiApp.Reload()
+}
+
+func (c *SongsEditComponent) cancel() {
+	c.close()
+}
+
+func (c *SongsEditComponent) close() {
+	c.uiApp.pagesComponent.RemovePage("songsEdit")
+	c.uiApp.cviewApp.SetFocus(c.originPrimitive)
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/jypelle/mifasol/internal/cliwa/jst"
 	"github.com/jypelle/mifasol/internal/cliwa/templates"
 	"github.com/jypelle/mifasol/internal/localdb"
+	"github.com/jypelle/mifasol/internal/remote"
+	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/internal/version"
 	"github.com/jypelle/mifasol/restApiV1"
 	"github.com/jypelle/mifasol/restClientV1"
@@ -14,12 +16,20 @@ import (
 	"net/url"
 	"strconv"
 	"syscall/js"
+	"time"
 )
 
+// Delay between two reports of the device state while playing
+const deviceStateReportInterval = 15 * time.Second
+
 type App struct {
 	config     config.ClientConfig
 	restClient *restClientV1.RestClient
 	localDb    *localdb.LocalDb
+	// Player remotely controlled by other devices
+	device *remote.Device
+	// Closed to stop watching server events, remote commands and saving the play queue
+	watchStopCh chan struct{}
 
 	templateHelpers template.FuncMap
 
@@ -151,10 +161,12 @@ func (a *App) AddEventFuncPreventDefault(fn func()) js.Func {
 }
 
 func (a *App) retrieveServerCredentials() {
-	rawUrl := js.Global().Get("window").Get("location").Get("href").String()
+	// The base uri follows the <base> tag of the main page, so it contains the server base path
+	rawUrl := js.Global().Get("document").Get("baseURI").String()
 	baseUrl, _ := url.Parse(rawUrl)
 
 	a.config.ServerHostname = baseUrl.Hostname()
+	a.config.ServerBasePath = tool.NormalizeBasePath(baseUrl.Path)
 	a.config.ServerPort, _ = strconv.ParseInt(baseUrl.Port(), 10, 64)
 	a.config.ServerSsl = baseUrl.Scheme == "https"
 	if a.config.ServerPort == 0 {
@@ -207,12 +219,80 @@ func (c *App) ConnectAction() {
 	c.StartComponent = nil
 	c.Render()
 	c.HomeComponent.Reload()
+
+	// Refresh in memory Db on server changes
+	localDb := c.localDb
+	c.watchStopCh = make(chan struct{})
+	go localDb.WatchEvents(c.watchStopCh, func() {
+		c.eventFunc <- func() {
+			if c.HomeComponent != nil && c.localDb == localDb {
+				c.HomeComponent.AutoReload()
+			}
+		}
+	})
+
+	// Execute the commands sent by other devices, each browser tab being a device
+	var deviceId string
+	if storedDeviceId := jst.SessionStorage.Get("mifasolDeviceId"); storedDeviceId.Truthy() {
+		deviceId = storedDeviceId.String()
+	} else {
+		deviceId = tool.CreateUlid()
+		jst.SessionStorage.Set("mifasolDeviceId", deviceId)
+	}
+	c.device = remote.NewDevice(
+		c.restClient,
+		restApiV1.DeviceId(deviceId),
+		restApiV1.DeviceMeta{
+			Name: "Web browser " + js.Global().Get("navigator").Get("platform").String(),
+			Capabilities: []restApiV1.DeviceCommandType{
+				restApiV1.PlayDeviceCommandType,
+				restApiV1.PauseDeviceCommandType,
+				restApiV1.NextDeviceCommandType,
+				restApiV1.SeekDeviceCommandType,
+				restApiV1.VolumeDeviceCommandType,
+				restApiV1.QueueDeviceCommandType,
+			},
+		},
+	)
+	go c.device.Listen(c.watchStopCh, func(command *restApiV1.DeviceCommand) {
+		c.eventFunc <- func() {
+			if c.HomeComponent != nil && c.localDb == localDb {
+				c.HomeComponent.PlayerComponent.RemoteCommandAction(command)
+			}
+		}
+	})
+	c.HomeComponent.PlayerComponent.ReportStateAction()
+	watchStopCh := c.watchStopCh
+	go func() {
+		ticker := time.NewTicker(deviceStateReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.eventFunc <- func() {
+					if c.HomeComponent != nil && c.localDb == localDb {
+						c.HomeComponent.PlayerComponent.ReportStateAction()
+					}
+				}
+			case <-watchStopCh:
+				return
+			}
+		}
+	}()
+
+	// Propose to resume the play queue of a previous session
+	c.HomeComponent.ProposePlayQueueResume(c.watchStopCh)
 }
 
 func (c *App) DisconnectAction() {
 	jst.LocalStorage.Set("mifasolUsername", "")
 	jst.LocalStorage.Set("mifasolPassword", "")
+	if c.watchStopCh != nil {
+		close(c.watchStopCh)
+		c.watchStopCh = nil
+	}
 	c.restClient = nil
+	c.device = nil
 	c.localDb = nil
 	c.HomeComponent = nil
 	c.StartComponent = NewStartComponent(c)
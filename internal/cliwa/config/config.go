@@ -23,6 +23,7 @@ func (c *ClientConfig) Collator() *collate.Collator {
 type ClientEditableConfig struct {
 	ServerHostname   string `json:"serverHostname"`
 	ServerPort       int64  `json:"serverPort"`
+	ServerBasePath   string `json:"serverBasePath"`
 	ServerSsl        bool   `json:"serverSsl"`
 	ServerSelfSigned bool   `json:"serverSelfSigned"`
 	SortLanguage     string `json:"sortLanguage"`
@@ -38,6 +39,7 @@ func NewClientEditableConfig(draftClientEditableConfig *ClientEditableConfig) *C
 		clientEditableConfig = ClientEditableConfig{
 			ServerHostname:   restClientV1.DefaultServerHostname,
 			ServerPort:       restClientV1.DefaultServerPort,
+			ServerBasePath:   restClientV1.DefaultServerBasePath,
 			ServerSsl:        restClientV1.DefaultServerSsl,
 			ServerSelfSigned: restClientV1.DefaultServerSelfSigned,
 			SortLanguage:     restClientV1.DefaultSortLanguage,
@@ -49,6 +51,7 @@ func NewClientEditableConfig(draftClientEditableConfig *ClientEditableConfig) *C
 		clientEditableConfig = *draftClientEditableConfig
 
 		// Check config values
+		clientEditableConfig.ServerBasePath = tool.NormalizeBasePath(clientEditableConfig.ServerBasePath)
 		if _, ok := tool.LocaleTags[clientEditableConfig.SortLanguage]; !ok {
 			clientEditableConfig.SortLanguage = restClientV1.DefaultSortLanguage
 		}
@@ -80,6 +83,10 @@ func (c *ClientConfig) GetServerPort() int64 {
 	return c.ServerPort
 }
 
+func (c *ClientConfig) GetServerBasePath() string {
+	return c.ServerBasePath
+}
+
 func (c *ClientConfig) GetServerSsl() bool {
 	return c.ServerSsl
 }
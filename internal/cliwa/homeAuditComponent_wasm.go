@@ -0,0 +1,141 @@
+package cliwa
+
+import (
+	"github.com/jypelle/mifasol/internal/cliwa/jst"
+	"github.com/jypelle/mifasol/restApiV1"
+	"time"
+)
+
+type HomeAuditComponent struct {
+	app    *App
+	closed bool
+}
+
+func NewHomeAuditComponent(app *App) *HomeAuditComponent {
+	c := &HomeAuditComponent{
+		app: app,
+	}
+
+	return c
+}
+
+func (c *HomeAuditComponent) Render() {
+	type UserItem struct {
+		UserId   restApiV1.UserId
+		UserName string
+	}
+
+	auditItem := struct {
+		Users       []UserItem
+		EntityTypes []restApiV1.AuditEntityType
+	}{
+		EntityTypes: restApiV1.AuditEntityTypes,
+	}
+
+	for _, user := range c.app.localDb.OrderedUsers {
+		auditItem.Users = append(auditItem.Users, UserItem{UserId: user.Id, UserName: user.Name})
+	}
+
+	div := jst.Id("homeMainModal")
+	div.Set("innerHTML", c.app.RenderTemplate(
+		&auditItem, "home/audit/index"),
+	)
+
+	form := jst.Id("auditForm")
+	form.Call("addEventListener", "submit", c.app.AddEventFuncPreventDefault(c.RefreshView))
+	closeButton := jst.Id("auditCloseButton")
+	closeButton.Call("addEventListener", "click", c.app.AddEventFunc(c.closeAction))
+
+	c.RefreshView()
+}
+
+func (c *HomeAuditComponent) RefreshView() {
+	if c.closed {
+		return
+	}
+
+	var auditEntryFilter restApiV1.AuditEntryFilter
+
+	userId := restApiV1.UserId(jst.Id("auditUser").Get("value").String())
+	if userId != "" {
+		auditEntryFilter.UserId = &userId
+	}
+	entityType := restApiV1.AuditEntityType(jst.Id("auditEntityType").Get("value").String())
+	if entityType != "" {
+		auditEntryFilter.EntityType = &entityType
+	}
+	entityId := jst.Id("auditEntityId").Get("value").String()
+	if entityId != "" {
+		auditEntryFilter.EntityId = &entityId
+	}
+	if fromDate, err := time.ParseInLocation("2006-01-02", jst.Id("auditFromDate").Get("value").String(), time.Local); err == nil {
+		fromTs := fromDate.UnixNano()
+		auditEntryFilter.FromTs = &fromTs
+	}
+	// The whole last day is included
+	if toDate, err := time.ParseInLocation("2006-01-02", jst.Id("auditToDate").Get("value").String(), time.Local); err == nil {
+		toTs := toDate.AddDate(0, 0, 1).UnixNano()
+		auditEntryFilter.ToTs = &toTs
+	}
+
+	auditEntries, cliErr := c.app.restClient.ReadAuditEntries(&auditEntryFilter)
+	if cliErr != nil {
+		c.app.HomeComponent.MessageComponent.ClientErrorMessage("Unable to retrieve the audit trail", cliErr)
+		return
+	}
+
+	type ChangeItem struct {
+		Field  string
+		Before string
+		After  string
+	}
+	type AuditEntryItem struct {
+		Date       string
+		UserName   string
+		ClientIp   string
+		Action     string
+		EntityType string
+		EntityId   string
+		EntityName string
+		Changes    []ChangeItem
+	}
+
+	var auditEntryItemList []AuditEntryItem
+	for _, auditEntry := range auditEntries {
+		auditEntryItem := AuditEntryItem{
+			Date:       time.Unix(0, auditEntry.Ts).Format("2006-01-02 15:04:05"),
+			UserName:   auditEntry.UserName,
+			ClientIp:   auditEntry.ClientIp,
+			Action:     string(auditEntry.Action),
+			EntityType: string(auditEntry.EntityType),
+			EntityId:   auditEntry.EntityId,
+			EntityName: auditEntry.EntityName,
+		}
+		for _, change := range auditEntry.Changes {
+			auditEntryItem.Changes = append(auditEntryItem.Changes, ChangeItem{
+				Field:  change.Field,
+				Before: auditChangeValue(change.Before),
+				After:  auditChangeValue(change.After),
+			})
+		}
+		auditEntryItemList = append(auditEntryItemList, auditEntryItem)
+	}
+
+	jst.Id("auditList").Set("innerHTML", c.app.RenderTemplate(auditEntryItemList, "home/audit/auditList"))
+}
+
+func (c *HomeAuditComponent) closeAction() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.app.HomeComponent.CloseModal()
+}
+
+// auditChangeValue returns the JSON value of a changed field, as displayed
+func auditChangeValue(value []byte) string {
+	if len(value) == 0 {
+		return "null"
+	}
+	return string(value)
+}
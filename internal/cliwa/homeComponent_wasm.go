@@ -46,6 +46,27 @@ func (c *HomeComponent) uploadSongsAction() {
 	component.Render()
 }
 
+func (c *HomeComponent) devicesAction() {
+	component := NewHomeDevicesComponent(c.app)
+
+	c.OpenModal()
+	component.Render()
+}
+
+func (c *HomeComponent) sharesAction() {
+	component := NewHomeSharesComponent(c.app)
+
+	c.OpenModal()
+	component.Render()
+}
+
+func (c *HomeComponent) auditAction() {
+	component := NewHomeAuditComponent(c.app)
+
+	c.OpenModal()
+	component.Render()
+}
+
 func (c *HomeComponent) refreshAction() {
 	c.Reload()
 }
@@ -57,7 +78,9 @@ func (c *HomeComponent) Reload() {
 	c.app.ShowLoader("Syncing...")
 	defer c.app.HideLoader()
 	// Refresh In memory Db
-	err := c.app.localDb.Refresh()
+	err := c.app.localDb.Refresh(func(changeCount int64, totalChangeCount int64) {
+		c.app.ShowLoader("Syncing... " + strconv.FormatInt(changeCount, 10) + "/" + strconv.FormatInt(totalChangeCount, 10) + " changes")
+	})
 	if err != nil {
 		c.MessageComponent.Message("Unable to load data from mifasolsrv")
 		return
@@ -70,6 +93,37 @@ func (c *HomeComponent) Reload() {
 	c.MessageComponent.Message(strconv.Itoa(len(c.app.localDb.Songs)) + " songs, " + strconv.Itoa(len(c.app.localDb.Artists)) + " artists, " + strconv.Itoa(len(c.app.localDb.Albums)) + " albums, " + strconv.Itoa(len(c.app.localDb.Playlists)) + " playlists ready to be played for " + strconv.Itoa(len(c.app.localDb.Users)) + " users.")
 }
 
+// AutoReload silently refreshes the in memory Db with the changes made by other clients
+func (c *HomeComponent) AutoReload() {
+	err := c.app.localDb.Refresh(nil)
+	if err != nil {
+		c.MessageComponent.Message("Unable to load data from mifasolsrv")
+		return
+	}
+
+	c.HeaderButtonsComponent.RefreshView()
+	c.LibraryComponent.RefreshView()
+	c.CurrentComponent.RemoveDeletedSongsOrPlaylist()
+}
+
+// ProposePlayQueueResume asks the user to resume the play queue saved on the server, then saves it until stopCh is closed
+func (c *HomeComponent) ProposePlayQueueResume(stopCh <-chan struct{}) {
+	playQueue, cliErr := c.app.restClient.ReadPlayQueue()
+	if cliErr != nil {
+		c.MessageComponent.ClientErrorMessage("Unable to load the play queue", cliErr)
+		return
+	}
+
+	if len(playQueue.SongIds) == 0 {
+		c.CurrentComponent.StartPlayQueueSave(stopCh)
+		return
+	}
+
+	component := NewHomePlayQueueResumeComponent(c.app, playQueue, stopCh)
+	c.OpenModal()
+	component.Render()
+}
+
 func (c *HomeComponent) CloseModal() {
 	homeMainMaster := jst.Id("homeMainMaster")
 	homeMainMaster.Get("style").Set("display", "flex")
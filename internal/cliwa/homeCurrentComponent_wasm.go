@@ -7,10 +7,15 @@ import (
 	"github.com/sirupsen/logrus"
 	"html"
 	"math/rand"
+	"reflect"
 	"strconv"
 	"syscall/js"
+	"time"
 )
 
+// Delay between two saves of the play queue
+const playQueueSaveInterval = 15 * time.Second
+
 type HomeCurrentComponent struct {
 	app *App
 
@@ -18,8 +23,15 @@ type HomeCurrentComponent struct {
 	currentSongIdx int
 	srcPlaylistId  *restApiV1.PlaylistId
 	modified       bool
+	shuffleFg      bool
+	repeatMode     restApiV1.RepeatMode
 
 	displayedPage int
+
+	// Play queue saved on the server, once the user has chosen to resume it or not
+	playQueueSaveFg       bool
+	savedPlayQueueMeta    *restApiV1.PlayQueueMeta
+	playQueueMetaToSaveCh chan *restApiV1.PlayQueueMeta
 }
 
 func NewHomeCurrentComponent(app *App) *HomeCurrentComponent {
@@ -27,6 +39,7 @@ func NewHomeCurrentComponent(app *App) *HomeCurrentComponent {
 		app:            app,
 		modified:       true,
 		currentSongIdx: -1,
+		repeatMode:     restApiV1.RepeatModeOff,
 	}
 
 	return c
@@ -43,6 +56,7 @@ func (c *HomeCurrentComponent) Render() {
 		c.songIds = nil
 		c.srcPlaylistId = nil
 		c.modified = true
+		c.shuffleFg = false
 		c.currentSongIdx = -1
 		c.displayedPage = 0
 		c.RefreshView(0, true)
@@ -51,10 +65,23 @@ func (c *HomeCurrentComponent) Render() {
 	currentShuffleButton.Call("addEventListener", "click", c.app.AddEventFunc(func() {
 		rand.Shuffle(len(c.songIds), func(i, j int) { c.songIds[i], c.songIds[j] = c.songIds[j], c.songIds[i] })
 		c.modified = true
+		c.shuffleFg = true
 		c.currentSongIdx = -1
 		c.displayedPage = 0
 		c.RefreshView(0, true)
 	}))
+	currentRepeatButton := jst.Id("currentRepeatButton")
+	currentRepeatButton.Call("addEventListener", "click", c.app.AddEventFunc(func() {
+		switch c.repeatMode {
+		case restApiV1.RepeatModeOff:
+			c.repeatMode = restApiV1.RepeatModeAll
+		case restApiV1.RepeatModeAll:
+			c.repeatMode = restApiV1.RepeatModeOne
+		default:
+			c.repeatMode = restApiV1.RepeatModeOff
+		}
+		c.RefreshView(0, false)
+	}))
 	currentSaveButton := jst.Id("currentSaveButton")
 	currentSaveButton.Call("addEventListener", "click", c.app.AddEventFunc(func() {
 		if c.modified {
@@ -146,12 +173,157 @@ func (c *HomeCurrentComponent) PlayNextSongAction() {
 	if c.currentSongIdx != -1 && c.currentSongIdx < len(c.songIds)-1 {
 		c.currentSongIdx++
 		c.app.HomeComponent.PlayerComponent.PlaySongAction(c.songIds[c.currentSongIdx])
+	} else if c.currentSongIdx != -1 && c.repeatMode == restApiV1.RepeatModeAll {
+		c.currentSongIdx = 0
+		c.app.HomeComponent.PlayerComponent.PlaySongAction(c.songIds[c.currentSongIdx])
 	} else {
 		c.currentSongIdx = -1
 	}
 	c.RefreshView(0, false)
 }
 
+// SongEndedAction plays the next song, or the same one again when repeating one song
+func (c *HomeCurrentComponent) SongEndedAction() {
+	if c.currentSongIdx != -1 && c.repeatMode == restApiV1.RepeatModeOne {
+		c.app.HomeComponent.PlayerComponent.PlaySongAction(c.songIds[c.currentSongIdx])
+		return
+	}
+	c.PlayNextSongAction()
+}
+
+// PlayQueueMeta returns the current playlist as a play queue, position being the elapsed time in the playing song
+func (c *HomeCurrentComponent) PlayQueueMeta(position float64) *restApiV1.PlayQueueMeta {
+	playQueueMeta := &restApiV1.PlayQueueMeta{
+		SongIds:      append([]restApiV1.SongId{}, c.songIds...),
+		CurrentIndex: int64(c.currentSongIdx),
+		ShuffleFg:    c.shuffleFg,
+		RepeatMode:   c.repeatMode,
+	}
+	if c.currentSongIdx != -1 {
+		playQueueMeta.Position = position
+	}
+	if c.srcPlaylistId != nil {
+		srcPlaylistId := *c.srcPlaylistId
+		playQueueMeta.SrcPlaylistId = &srcPlaylistId
+	}
+
+	return playQueueMeta
+}
+
+// LoadPlayQueueAction replaces the current playlist by a play queue saved on the server
+func (c *HomeCurrentComponent) LoadPlayQueueAction(playQueue *restApiV1.PlayQueue) {
+	c.songIds = []restApiV1.SongId{}
+	c.srcPlaylistId = nil
+	c.currentSongIdx = -1
+	for idx, songId := range playQueue.SongIds {
+		if _, ok := c.app.localDb.Songs[songId]; !ok {
+			continue
+		}
+		if int64(idx) == playQueue.CurrentIndex {
+			c.currentSongIdx = len(c.songIds)
+		}
+		c.songIds = append(c.songIds, songId)
+	}
+	c.shuffleFg = playQueue.ShuffleFg
+	c.repeatMode = playQueue.RepeatMode
+
+	c.modified = true
+	if playQueue.SrcPlaylistId != nil {
+		if playlist, ok := c.app.localDb.Playlists[*playQueue.SrcPlaylistId]; ok {
+			srcPlaylistId := playlist.Id
+			c.srcPlaylistId = &srcPlaylistId
+			c.modified = !sameSongIds(c.songIds, playlist.SongIds)
+		}
+	}
+
+	c.displayedPage = 0
+	if c.currentSongIdx != -1 {
+		c.displayedPage = c.currentSongIdx / LibraryPageSize
+	}
+	c.RefreshView(0, true)
+
+	if c.currentSongIdx != -1 {
+		c.app.HomeComponent.PlayerComponent.PlaySongAtAction(c.songIds[c.currentSongIdx], playQueue.Position)
+	}
+}
+
+// StartPlayQueueSave starts saving the play queue on the server, from its current state, until stopCh is closed
+func (c *HomeCurrentComponent) StartPlayQueueSave(stopCh <-chan struct{}) {
+	c.savedPlayQueueMeta = c.PlayQueueMeta(c.playingSongPosition())
+	c.playQueueSaveFg = true
+	c.playQueueMetaToSaveCh = make(chan *restApiV1.PlayQueueMeta, 1)
+
+	restClient := c.app.restClient
+	playQueueMetaToSaveCh := c.playQueueMetaToSaveCh
+	go func() {
+		for {
+			select {
+			case playQueueMeta := <-playQueueMetaToSaveCh:
+				_, cliErr := restClient.UpdatePlayQueue(playQueueMeta)
+				if cliErr != nil {
+					logrus.Warnf("Unable to save the play queue: %v", cliErr)
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(playQueueSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.app.eventFunc <- c.SavePlayQueue
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// playingSongPosition returns the elapsed time in the current song, when it's the one played
+func (c *HomeCurrentComponent) playingSongPosition() float64 {
+	playerComponent := c.app.HomeComponent.PlayerComponent
+	if c.currentSongIdx != -1 && playerComponent.PlayingSongId() == c.songIds[c.currentSongIdx] {
+		return playerComponent.Position()
+	}
+	return 0
+}
+
+func sameSongIds(songIds1 []restApiV1.SongId, songIds2 []restApiV1.SongId) bool {
+	if len(songIds1) != len(songIds2) {
+		return false
+	}
+	for idx := range songIds1 {
+		if songIds1[idx] != songIds2[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// SavePlayQueue asynchronously saves the play queue on the server when it has changed
+func (c *HomeCurrentComponent) SavePlayQueue() {
+	if !c.playQueueSaveFg || c.app.HomeComponent == nil {
+		return
+	}
+
+	playQueueMeta := c.PlayQueueMeta(c.playingSongPosition())
+	if reflect.DeepEqual(playQueueMeta, c.savedPlayQueueMeta) {
+		return
+	}
+	c.savedPlayQueueMeta = playQueueMeta
+
+	// Only the last version of the play queue needs to be saved
+	select {
+	case <-c.playQueueMetaToSaveCh:
+	default:
+	}
+	c.playQueueMetaToSaveCh <- playQueueMeta
+}
+
 func (c *HomeCurrentComponent) RefreshView(direction int, resetPosition bool) {
 	// Update current playlist title
 	titleSpan := jst.Id("currentTitle")
@@ -166,6 +338,18 @@ func (c *HomeCurrentComponent) RefreshView(direction int, resetPosition bool) {
 	}
 	titleSpan.Set("innerHTML", title)
 
+	// Update repeat button
+	repeatButton := jst.Id("currentRepeatButton")
+	repeatButton.Set("title", "Repeat: "+string(c.repeatMode))
+	switch c.repeatMode {
+	case restApiV1.RepeatModeAll:
+		repeatButton.Set("innerHTML", `<i class="fas fa-redo"></i>`)
+	case restApiV1.RepeatModeOne:
+		repeatButton.Set("innerHTML", `<i class="fas fa-redo"></i>1`)
+	default:
+		repeatButton.Set("innerHTML", `<i class="fas fa-redo" style="color: #444;"></i>`)
+	}
+
 	listDiv := jst.Id("currentList")
 
 	if resetPosition {
@@ -261,6 +445,7 @@ func (c *HomeCurrentComponent) LoadSongsFromPlaylistAction(playlistId restApiV1.
 		c.tryToAppendSong(c.app.localDb.Songs[songId])
 	}
 	c.modified = false
+	c.shuffleFg = false
 	c.currentSongIdx = -1
 	c.displayedPage = 0
 	c.RefreshView(0, true)
@@ -0,0 +1,172 @@
+package cliwa
+
+import (
+	"fmt"
+	"github.com/jypelle/mifasol/internal/cliwa/jst"
+	"github.com/jypelle/mifasol/restApiV1"
+	"html"
+	"syscall/js"
+	"time"
+)
+
+// Volume step of the remote control
+const remoteVolumeStep = 10
+
+type HomeDevicesComponent struct {
+	app     *App
+	devices map[restApiV1.DeviceId]restApiV1.Device
+	closed  bool
+}
+
+func NewHomeDevicesComponent(app *App) *HomeDevicesComponent {
+	c := &HomeDevicesComponent{
+		app: app,
+	}
+
+	return c
+}
+
+func (c *HomeDevicesComponent) Render() {
+	div := jst.Id("homeMainModal")
+	div.Set("innerHTML", c.app.RenderTemplate(
+		nil, "home/devices/index"),
+	)
+
+	refreshButton := jst.Id("devicesRefreshButton")
+	refreshButton.Call("addEventListener", "click", c.app.AddEventFunc(c.RefreshView))
+	closeButton := jst.Id("devicesCloseButton")
+	closeButton.Call("addEventListener", "click", c.app.AddEventFunc(c.closeAction))
+
+	listDiv := jst.Id("devicesList")
+	listDiv.Call("addEventListener", "click", c.app.AddRichEventFunc(func(this js.Value, i []js.Value) {
+		button := i[0].Get("target").Call("closest", ".deviceCommandButton")
+		if !button.Truthy() {
+			return
+		}
+		dataset := button.Get("dataset")
+		c.sendCommandAction(restApiV1.DeviceId(dataset.Get("deviceid").String()), dataset.Get("command").String())
+	}))
+
+	c.RefreshView()
+}
+
+func (c *HomeDevicesComponent) RefreshView() {
+	if c.closed {
+		return
+	}
+
+	devices, cliErr := c.app.restClient.ReadDevices()
+	if cliErr != nil {
+		c.app.HomeComponent.MessageComponent.ClientErrorMessage("Unable to retrieve devices", cliErr)
+		return
+	}
+
+	type DeviceItem struct {
+		DeviceId   string
+		DeviceName string
+		SongName   string
+		PausedFg   bool
+		Position   string
+		Volume     int64
+		PlayFg     bool
+		PauseFg    bool
+		NextFg     bool
+		VolumeFg   bool
+		QueueFg    bool
+	}
+
+	c.devices = make(map[restApiV1.DeviceId]restApiV1.Device)
+	var deviceItemList []DeviceItem
+	for _, device := range devices {
+		if c.app.device != nil && device.Id == c.app.device.Id {
+			continue
+		}
+		c.devices[device.Id] = device
+
+		deviceItem := DeviceItem{
+			DeviceId:   string(device.Id),
+			DeviceName: device.Name,
+			PausedFg:   device.State.PausedFg,
+			Volume:     device.State.Volume,
+			PlayFg:     device.HasCapability(restApiV1.PlayDeviceCommandType),
+			PauseFg:    device.HasCapability(restApiV1.PauseDeviceCommandType),
+			NextFg:     device.HasCapability(restApiV1.NextDeviceCommandType),
+			VolumeFg:   device.HasCapability(restApiV1.VolumeDeviceCommandType),
+			QueueFg:    device.HasCapability(restApiV1.QueueDeviceCommandType),
+		}
+		if device.State.SongId != nil {
+			deviceItem.SongName = "Unknown song"
+			if song, ok := c.app.localDb.Songs[*device.State.SongId]; ok {
+				deviceItem.SongName = song.Name
+			}
+			position := int(device.State.Position)
+			deviceItem.Position = fmt.Sprintf("%d:%02d", position/60, position%60)
+		}
+		deviceItemList = append(deviceItemList, deviceItem)
+	}
+
+	jst.Id("devicesList").Set("innerHTML", c.app.RenderTemplate(deviceItemList, "home/devices/deviceList"))
+}
+
+func (c *HomeDevicesComponent) sendCommandAction(deviceId restApiV1.DeviceId, commandName string) {
+	device, ok := c.devices[deviceId]
+	if !ok {
+		return
+	}
+
+	var command *restApiV1.DeviceCommand
+	switch commandName {
+	case "play":
+		command = &restApiV1.DeviceCommand{Type: restApiV1.PlayDeviceCommandType}
+	case "pause":
+		command = &restApiV1.DeviceCommand{Type: restApiV1.PauseDeviceCommandType}
+	case "next":
+		command = &restApiV1.DeviceCommand{Type: restApiV1.NextDeviceCommandType}
+	case "volumeDown", "volumeUp":
+		volume := device.State.Volume - remoteVolumeStep
+		if commandName == "volumeUp" {
+			volume = device.State.Volume + remoteVolumeStep
+		}
+		if volume < 0 {
+			volume = 0
+		} else if volume > 100 {
+			volume = 100
+		}
+		command = &restApiV1.DeviceCommand{Type: restApiV1.VolumeDeviceCommandType, Volume: volume}
+	case "queue":
+		currentComponent := c.app.HomeComponent.CurrentComponent
+		if len(currentComponent.songIds) == 0 {
+			c.app.HomeComponent.MessageComponent.WarningMessage("Current playlist is empty")
+			return
+		}
+		command = &restApiV1.DeviceCommand{
+			Type:    restApiV1.QueueDeviceCommandType,
+			SongIds: currentComponent.songIds,
+		}
+		if currentComponent.currentSongIdx != -1 {
+			command.CurrentIndex = int64(currentComponent.currentSongIdx)
+		}
+	default:
+		return
+	}
+
+	_, cliErr := c.app.restClient.CreateDeviceCommand(deviceId, command)
+	if cliErr != nil {
+		c.app.HomeComponent.MessageComponent.ClientErrorMessage("Unable to send the command to "+html.EscapeString(device.Name), cliErr)
+		return
+	}
+
+	// Let the device report its new state
+	go func() {
+		time.Sleep(time.Second)
+		c.app.eventFunc <- c.RefreshView
+	}()
+}
+
+func (c *HomeDevicesComponent) closeAction() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.app.HomeComponent.CloseModal()
+}
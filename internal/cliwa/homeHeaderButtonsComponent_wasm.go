@@ -25,17 +25,26 @@ func (c *HomeHeaderButtonsComponent) Render() {
 	// Set buttons
 	uploadSongsButton := jst.Id("uploadSongsButton")
 	uploadSongsButton.Call("addEventListener", "click", c.app.AddEventFunc(c.app.HomeComponent.uploadSongsAction))
+	auditButton := jst.Id("auditButton")
+	auditButton.Call("addEventListener", "click", c.app.AddEventFunc(c.app.HomeComponent.auditAction))
 	logOutButton := jst.Id("logOutButton")
 	logOutButton.Call("addEventListener", "click", c.app.AddEventFunc(c.app.DisconnectAction))
+	devicesButton := jst.Id("devicesButton")
+	devicesButton.Call("addEventListener", "click", c.app.AddEventFunc(c.app.HomeComponent.devicesAction))
+	sharesButton := jst.Id("sharesButton")
+	sharesButton.Call("addEventListener", "click", c.app.AddEventFunc(c.app.HomeComponent.sharesAction))
 	refreshButton := jst.Id("refreshButton")
 	refreshButton.Call("addEventListener", "click", c.app.AddEventFunc(c.app.HomeComponent.refreshAction))
 }
 
 func (c *HomeHeaderButtonsComponent) RefreshView() {
 	uploadSongsButton := jst.Id("uploadSongsButton")
+	auditButton := jst.Id("auditButton")
 	if c.app.IsConnectedUserAdmin() {
 		uploadSongsButton.Set("style", "display:block;")
+		auditButton.Set("style", "display:block;")
 	} else {
 		uploadSongsButton.Set("style", "display:none;")
+		auditButton.Set("style", "display:none;")
 	}
 }
@@ -58,8 +58,9 @@ func (s *libraryState) cachedSize() int {
 }
 
 type LibraryComponent struct {
-	app          *App
-	libraryState libraryState
+	app             *App
+	libraryState    libraryState
+	selectedSongIds map[restApiV1.SongId]struct{}
 }
 
 func NewHomeLibraryComponent(app *App) *LibraryComponent {
@@ -68,6 +69,7 @@ func NewHomeLibraryComponent(app *App) *LibraryComponent {
 		libraryState: libraryState{
 			libraryType: LibraryTypeArtists,
 		},
+		selectedSongIds: make(map[restApiV1.SongId]struct{}),
 	}
 
 	return c
@@ -91,6 +93,8 @@ func (c *LibraryComponent) Render() {
 	libraryUsersButton.Call("addEventListener", "click", c.app.AddEventFunc(c.ShowUsersAction))
 	libraryAddToPlaylistButton := jst.Id("libraryAddToPlaylistButton")
 	libraryAddToPlaylistButton.Call("addEventListener", "click", c.app.AddEventFunc(c.AddToPlaylistAction))
+	libraryEditSelectedSongsButton := jst.Id("libraryEditSelectedSongsButton")
+	libraryEditSelectedSongsButton.Call("addEventListener", "click", c.app.AddEventFunc(c.EditSelectedSongsAction))
 	libraryCreateButton := jst.Id("libraryCreateButton")
 	libraryCreateButton.Call("addEventListener", "click", c.app.AddEventFunc(func() {
 		if c.libraryState.libraryType == LibraryTypeUsers {
@@ -127,9 +131,9 @@ func (c *LibraryComponent) Render() {
 	libraryList.Call("addEventListener", "click", c.app.AddRichEventFunc(func(this js.Value, i []js.Value) {
 		link := i[0].Get("target").Call("closest",
 			".artistLink, .artistEditLink, .artistDeleteLink, .artistAddToPlaylistLink, "+
-				".albumLink, .albumEditLink, .albumDeleteLink, .albumAddToPlaylistLink, "+
-				".playlistLink, .playlistEditLink, .playlistDeleteLink, .playlistFavoriteLink, .playlistAddToPlaylistLink, .playlistLoadToPlaylistLink, "+
-				".songEditLink, .songDeleteLink, .songFavoriteLink, .songAddToPlaylistLink, .songPlayNowLink, .songDownloadLink, "+
+				".albumLink, .albumEditLink, .albumDeleteLink, .albumDownloadLink, .albumShareLink, .albumAddToPlaylistLink, "+
+				".playlistLink, .playlistEditLink, .playlistShareLink, .playlistDeleteLink, .playlistDownloadLink, .playlistFavoriteLink, .playlistAddToPlaylistLink, .playlistLoadToPlaylistLink, "+
+				".songSelectLink, .songEditLink, .songDeleteLink, .songFavoriteLink, .songAddToPlaylistLink, .songPlayNowLink, .songDownloadLink, .songShareLink, "+
 				".userEditLink, .userDeleteLink")
 		if !link.Truthy() {
 			return
@@ -175,6 +179,14 @@ func (c *LibraryComponent) Render() {
 			c.app.HomeComponent.OpenModal()
 			component.Render()
 
+		case "albumDownloadLink":
+			albumId := restApiV1.AlbumId(dataset.Get("albumid").String())
+			c.download("api/v1/albums/"+string(albumId)+"/archive", c.app.localDb.Albums[albumId].Name+".zip")
+		case "albumShareLink":
+			albumId := restApiV1.AlbumId(dataset.Get("albumid").String())
+			component := NewHomeShareCreateComponent(c.app, restApiV1.ShareItemTypeAlbum, string(albumId), c.app.localDb.Albums[albumId].Name)
+			c.app.HomeComponent.OpenModal()
+			component.Render()
 		case "albumAddToPlaylistLink":
 			albumId := restApiV1.AlbumId(dataset.Get("albumid").String())
 			c.app.HomeComponent.CurrentComponent.AddSongsFromAlbumAction(albumId)
@@ -186,6 +198,14 @@ func (c *LibraryComponent) Render() {
 			component := NewHomePlaylistEditComponent(c.app, playlistId, &c.app.localDb.Playlists[playlistId].PlaylistMeta)
 			c.app.HomeComponent.OpenModal()
 			component.Render()
+		case "playlistShareLink":
+			playlistId := restApiV1.PlaylistId(dataset.Get("playlistid").String())
+			component := NewHomeShareCreateComponent(c.app, restApiV1.ShareItemTypePlaylist, string(playlistId), c.app.localDb.Playlists[playlistId].Name)
+			c.app.HomeComponent.OpenModal()
+			component.Render()
+		case "playlistDownloadLink":
+			playlistId := restApiV1.PlaylistId(dataset.Get("playlistid").String())
+			c.download("api/v1/playlists/"+string(playlistId)+"/archive", c.app.localDb.Playlists[playlistId].Name+".zip")
 		case "playlistDeleteLink":
 			playlistId := restApiV1.PlaylistId(dataset.Get("playlistid").String())
 			component := NewHomeConfirmDeleteComponent(c.app, playlistId)
@@ -228,6 +248,16 @@ func (c *LibraryComponent) Render() {
 		case "playlistLoadToPlaylistLink":
 			playlistId := restApiV1.PlaylistId(dataset.Get("playlistid").String())
 			c.app.HomeComponent.CurrentComponent.LoadSongsFromPlaylistAction(playlistId)
+		case "songSelectLink":
+			songId := restApiV1.SongId(dataset.Get("songid").String())
+			if _, ok := c.selectedSongIds[songId]; ok {
+				delete(c.selectedSongIds, songId)
+				link.Set("innerHTML", `<i class="far fa-square"></i>`)
+			} else {
+				c.selectedSongIds[songId] = struct{}{}
+				link.Set("innerHTML", `<i class="fas fa-check-square"></i>`)
+			}
+			c.updateEditSelectedSongsButton()
 		case "songEditLink":
 			songId := restApiV1.SongId(dataset.Get("songid").String())
 			component := NewHomeSongEditComponent(c.app, songId, &c.app.localDb.Songs[songId].SongMeta)
@@ -277,19 +307,13 @@ func (c *LibraryComponent) Render() {
 		case "songDownloadLink":
 			songId := restApiV1.SongId(dataset.Get("songid").String())
 			song := c.app.localDb.Songs[songId]
+			c.download("api/v1/songContents/"+string(songId)+"?download=true", song.Name+song.Format.Extension())
 
-			token, cliErr := c.app.restClient.GetToken()
-			if cliErr != nil {
-				return
-			}
-
-			anchor := jst.Document.Call("createElement", "a")
-			anchor.Set("href", "/api/v1/songContents/"+string(songId)+"?bearer="+token.AccessToken)
-			anchor.Set("download", song.Name+song.Format.Extension())
-			jst.Document.Get("body").Call("appendChild", anchor)
-			anchor.Call("click")
-			jst.Document.Get("body").Call("removeChild", anchor)
-
+		case "songShareLink":
+			songId := restApiV1.SongId(dataset.Get("songid").String())
+			component := NewHomeShareCreateComponent(c.app, restApiV1.ShareItemTypeSong, string(songId), c.app.localDb.Songs[songId].Name)
+			c.app.HomeComponent.OpenModal()
+			component.Render()
 		case "songAddToPlaylistLink":
 			songId := restApiV1.SongId(dataset.Get("songid").String())
 			c.app.HomeComponent.CurrentComponent.AddSongAction(songId)
@@ -338,6 +362,13 @@ func (c *LibraryComponent) RefreshView() {
 	libraryList.Set("innerHTML", "Loading...")
 	c.libraryState.displayedPage = 0
 
+	// Unselect deleted songs
+	for songId := range c.selectedSongIds {
+		if _, ok := c.app.localDb.Songs[songId]; !ok {
+			delete(c.selectedSongIds, songId)
+		}
+	}
+
 	c.computeCache()
 
 	// Update library title
@@ -356,6 +387,7 @@ func (c *LibraryComponent) RefreshView() {
 	} else {
 		libraryAddToPlaylistButton.Set("disabled", true)
 	}
+	c.updateEditSelectedSongsButton()
 	libraryCreateButton := jst.Id("libraryCreateButton")
 	if c.libraryState.libraryType == LibraryTypeUsers {
 		libraryCreateButton.Set("disabled", false)
@@ -698,7 +730,8 @@ func (c *LibraryComponent) renderAlbumItemList(albumList []*restApiV1.Album) str
 			ArtistId   string
 			ArtistName string
 		}
-		IsEditable bool
+		IsEditable  bool
+		IsShareable bool
 	}
 
 	var albumItemList = make([]AlbumItem, len(albumList))
@@ -723,17 +756,39 @@ func (c *LibraryComponent) renderAlbumItemList(albumList []*restApiV1.Album) str
 				})
 			}
 			albumItemList[albumIdx].IsEditable = c.app.IsConnectedUserAdmin()
+			albumItemList[albumIdx].IsShareable = true
 		}
 	}
 
 	return c.app.RenderTemplate(albumItemList, "home/library/albumItemList")
 }
 
+// download lets the browser save an authenticated api resource
+func (c *LibraryComponent) download(path string, filename string) {
+	token, cliErr := c.app.restClient.GetToken()
+	if cliErr != nil {
+		return
+	}
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	anchor := jst.Document.Call("createElement", "a")
+	anchor.Set("href", path+separator+"bearer="+token.AccessToken)
+	anchor.Set("download", filename)
+	jst.Document.Get("body").Call("appendChild", anchor)
+	anchor.Call("click")
+	jst.Document.Get("body").Call("removeChild", anchor)
+}
+
 func (c *LibraryComponent) renderSongItemList(songList []*restApiV1.Song) string {
 
 	type SongItem struct {
 		SongId    string
 		Favorite  bool
+		Selected  bool
 		SongName  string
 		AlbumId   *string
 		AlbumName string
@@ -752,6 +807,7 @@ func (c *LibraryComponent) renderSongItemList(songList []*restApiV1.Song) string
 
 		songItemList[songIdx].SongId = string(song.Id)
 		songItemList[songIdx].Favorite = favorite
+		_, songItemList[songIdx].Selected = c.selectedSongIds[song.Id]
 		songItemList[songIdx].SongName = song.Name
 		songItemList[songIdx].ExplicitFg = song.ExplicitFg
 		songItemList[songIdx].IsEditable = c.app.IsConnectedUserAdmin()
@@ -881,6 +937,33 @@ func (c *LibraryComponent) AddToPlaylistAction() {
 	}
 }
 
+func (c *LibraryComponent) EditSelectedSongsAction() {
+	if len(c.selectedSongIds) > 0 {
+		songIds := make([]restApiV1.SongId, 0, len(c.selectedSongIds))
+		for songId := range c.selectedSongIds {
+			songIds = append(songIds, songId)
+		}
+		component := NewHomeSongsEditComponent(c.app, songIds)
+		c.app.HomeComponent.OpenModal()
+		component.Render()
+	}
+}
+
+func (c *LibraryComponent) ClearSelectedSongs() {
+	c.selectedSongIds = make(map[restApiV1.SongId]struct{})
+}
+
+func (c *LibraryComponent) updateEditSelectedSongsButton() {
+	libraryEditSelectedSongsButton := jst.Id("libraryEditSelectedSongsButton")
+	if len(c.selectedSongIds) > 0 {
+		libraryEditSelectedSongsButton.Set("disabled", false)
+		libraryEditSelectedSongsButton.Set("title", fmt.Sprintf("Edit %d selected songs", len(c.selectedSongIds)))
+	} else {
+		libraryEditSelectedSongsButton.Set("disabled", true)
+		libraryEditSelectedSongsButton.Set("title", "Edit selected songs")
+	}
+}
+
 func (c *LibraryComponent) OpenAlbumAction(albumId restApiV1.AlbumId) {
 	c.libraryState = libraryState{
 		libraryType: LibraryTypeSongs,
@@ -0,0 +1,77 @@
+package cliwa
+
+import (
+	"fmt"
+	"github.com/jypelle/mifasol/internal/cliwa/jst"
+	"github.com/jypelle/mifasol/restApiV1"
+	"html"
+	"html/template"
+)
+
+type HomePlayQueueResumeComponent struct {
+	app       *App
+	playQueue *restApiV1.PlayQueue
+	stopCh    <-chan struct{}
+	closed    bool
+}
+
+func NewHomePlayQueueResumeComponent(
+	app *App,
+	playQueue *restApiV1.PlayQueue,
+	stopCh <-chan struct{},
+) *HomePlayQueueResumeComponent {
+	c := &HomePlayQueueResumeComponent{
+		app:       app,
+		playQueue: playQueue,
+		stopCh:    stopCh,
+	}
+
+	return c
+}
+
+func (c *HomePlayQueueResumeComponent) Render() {
+	question := template.HTML(fmt.Sprintf("Do you want to resume where you left off (%d songs) ?", len(c.playQueue.SongIds)))
+	if c.playQueue.CurrentIndex >= 0 {
+		if song, ok := c.app.localDb.Songs[c.playQueue.SongIds[c.playQueue.CurrentIndex]]; ok {
+			question = template.HTML(fmt.Sprintf(
+				"Do you want to resume <span class=\"songLink\">%s</span> where you left off (%d songs) ?",
+				html.EscapeString(song.Name),
+				len(c.playQueue.SongIds),
+			))
+		}
+	}
+
+	div := jst.Id("homeMainModal")
+	div.Set("innerHTML", c.app.RenderTemplate(
+		question, "home/playQueueResume/index"),
+	)
+
+	form := jst.Id("playQueueResumeForm")
+	form.Call("addEventListener", "submit", c.app.AddEventFuncPreventDefault(c.resumeAction))
+	cancelButton := jst.Id("playQueueResumeCancelButton")
+	cancelButton.Call("addEventListener", "click", c.app.AddEventFunc(c.cancelAction))
+}
+
+func (c *HomePlayQueueResumeComponent) resumeAction() {
+	if c.closed {
+		return
+	}
+	c.close()
+
+	c.app.HomeComponent.CurrentComponent.LoadPlayQueueAction(c.playQueue)
+	c.app.HomeComponent.CurrentComponent.StartPlayQueueSave(c.stopCh)
+}
+
+func (c *HomePlayQueueResumeComponent) cancelAction() {
+	if c.closed {
+		return
+	}
+	c.close()
+
+	c.app.HomeComponent.CurrentComponent.StartPlayQueueSave(c.stopCh)
+}
+
+func (c *HomePlayQueueResumeComponent) close() {
+	c.closed = true
+	c.app.HomeComponent.CloseModal()
+}
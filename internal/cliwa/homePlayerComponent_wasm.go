@@ -13,6 +13,10 @@ type HomePlayerComponent struct {
 	volume                float64
 	muted                 bool
 	autoRefreshSeekSlider bool
+
+	playingSongId restApiV1.SongId
+	// Position to reach once the song is loaded, in seconds
+	startPosition float64
 }
 
 func NewHomePlayerComponent(app *App) *HomePlayerComponent {
@@ -36,14 +40,27 @@ func (c *HomePlayerComponent) Render() {
 	playerMuteButton := jst.Id("playerMuteButton")
 	playerVolumeSlider := jst.Id("playerVolumeSlider")
 
-	playerAudio.Call("addEventListener", "ended", c.app.AddEventFunc(c.app.HomeComponent.CurrentComponent.PlayNextSongAction))
+	playerAudio.Call("addEventListener", "ended", c.app.AddEventFunc(c.app.HomeComponent.CurrentComponent.SongEndedAction))
 	playerAudio.Call("addEventListener", "loadedmetadata", c.app.AddEventFunc(func() {
 		duration := playerAudio.Get("duration").Int()
 		logrus.Infof("duration: %d", duration)
 		playerDuration.Set("innerHTML", fmt.Sprintf("%d:%02d", duration/60, duration%60))
 		playerSeekSlider.Set("max", duration)
 		playerSeekSlider.Set("value", 0)
+		if c.startPosition > 0 {
+			if c.startPosition < float64(duration) {
+				playerAudio.Set("currentTime", c.startPosition)
+			}
+			c.startPosition = 0
+		}
+	}))
+	playerAudio.Call("addEventListener", "pause", c.app.AddEventFunc(func() {
+		c.app.HomeComponent.CurrentComponent.SavePlayQueue()
+		c.ReportStateAction()
 	}))
+	playerAudio.Call("addEventListener", "play", c.app.AddEventFunc(c.ReportStateAction))
+	playerAudio.Call("addEventListener", "seeked", c.app.AddEventFunc(c.ReportStateAction))
+	playerAudio.Call("addEventListener", "volumechange", c.app.AddEventFunc(c.ReportStateAction))
 	playerAudio.Call("addEventListener", "timeupdate", c.app.AddEventFunc(func() {
 		currentTime := playerAudio.Get("currentTime").Int()
 		playerCurrentTime.Set("innerHTML", fmt.Sprintf("%d:%02d", currentTime/60, currentTime%60))
@@ -98,22 +115,102 @@ func (c *HomePlayerComponent) Render() {
 }
 
 func (c *HomePlayerComponent) PlaySongAction(songId restApiV1.SongId) {
+	c.PlaySongAtAction(songId, 0)
+}
+
+// PlaySongAtAction plays a song from a given position, in seconds
+func (c *HomePlayerComponent) PlaySongAtAction(songId restApiV1.SongId, position float64) {
 	token, cliErr := c.app.restClient.GetToken()
 
 	if cliErr != nil {
 		return
 	}
 
+	c.playingSongId = songId
+	c.startPosition = position
+
 	playerPlayButton := jst.Id("playerPlayButton")
 	playerPlayButton.Set("innerHTML", `<i class="fas fa-pause"></i>`)
 
 	player := jst.Id("playerAudio")
-	player.Set("src", "/api/v1/songContents/"+string(songId)+"?bearer="+token.AccessToken)
+	player.Set("src", "api/v1/songContents/"+string(songId)+"?bearer="+token.AccessToken)
 	player.Call("play")
 
 	c.app.HomeComponent.MessageComponent.Message(`Playing ` + c.InlineSong(songId))
 
-	return
+	c.app.HomeComponent.CurrentComponent.SavePlayQueue()
+}
+
+// RemoteCommandAction executes a command sent by another device
+func (c *HomePlayerComponent) RemoteCommandAction(command *restApiV1.DeviceCommand) {
+	playerAudio := jst.Id("playerAudio")
+
+	switch command.Type {
+	case restApiV1.PlayDeviceCommandType:
+		if c.playingSongId != "" && playerAudio.Get("paused").Bool() {
+			c.ResumeSongAction()
+		}
+	case restApiV1.PauseDeviceCommandType:
+		if c.playingSongId != "" && !playerAudio.Get("paused").Bool() {
+			c.PauseSongAction()
+		}
+	case restApiV1.NextDeviceCommandType:
+		c.app.HomeComponent.CurrentComponent.PlayNextSongAction()
+	case restApiV1.SeekDeviceCommandType:
+		if c.playingSongId != "" {
+			playerAudio.Set("currentTime", command.Position)
+		}
+	case restApiV1.VolumeDeviceCommandType:
+		c.volume = float64(command.Volume) / 100
+		if c.muted {
+			jst.Id("playerMuteButton").Set("innerHTML", `<i class="fas fa-volume-off"></i>`)
+			c.muted = false
+		}
+		jst.Id("playerVolumeSlider").Set("value", c.volume)
+		playerAudio.Set("volume", c.volume)
+	case restApiV1.QueueDeviceCommandType:
+		c.app.HomeComponent.CurrentComponent.LoadPlayQueueAction(&restApiV1.PlayQueue{
+			PlayQueueMeta: restApiV1.PlayQueueMeta{
+				SongIds:      command.SongIds,
+				CurrentIndex: command.CurrentIndex,
+				RepeatMode:   c.app.HomeComponent.CurrentComponent.repeatMode,
+			},
+		})
+	}
+}
+
+// ReportStateAction sends the "now playing" state to the server, for the devices remotely controlling this one
+func (c *HomePlayerComponent) ReportStateAction() {
+	if c.app.device == nil {
+		return
+	}
+
+	state := &restApiV1.DeviceState{
+		Volume: int64(c.volume * 100),
+	}
+	if c.muted {
+		state.Volume = 0
+	}
+	if c.playingSongId != "" {
+		songId := c.playingSongId
+		state.SongId = &songId
+		state.Position = c.Position()
+		state.PausedFg = jst.Id("playerAudio").Get("paused").Bool()
+	}
+	c.app.device.ReportState(state)
+}
+
+func (c *HomePlayerComponent) PlayingSongId() restApiV1.SongId {
+	return c.playingSongId
+}
+
+// Position returns the elapsed time in the playing song, in seconds
+func (c *HomePlayerComponent) Position() float64 {
+	if c.startPosition > 0 {
+		// Song not loaded yet
+		return c.startPosition
+	}
+	return jst.Id("playerAudio").Get("currentTime").Float()
 }
 
 func (c *HomePlayerComponent) InlineSong(songId restApiV1.SongId) string {
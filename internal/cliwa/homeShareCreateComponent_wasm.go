@@ -0,0 +1,109 @@
+package cliwa
+
+import (
+	"github.com/jypelle/mifasol/internal/cliwa/jst"
+	"github.com/jypelle/mifasol/restApiV1"
+	"strconv"
+	"syscall/js"
+	"time"
+)
+
+type HomeShareCreateComponent struct {
+	app      *App
+	itemType restApiV1.ShareItemType
+	itemId   string
+	itemName string
+	closed   bool
+}
+
+func NewHomeShareCreateComponent(app *App, itemType restApiV1.ShareItemType, itemId string, itemName string) *HomeShareCreateComponent {
+	c := &HomeShareCreateComponent{
+		app:      app,
+		itemType: itemType,
+		itemId:   itemId,
+		itemName: itemName,
+	}
+
+	return c
+}
+
+func (c *HomeShareCreateComponent) Render() {
+	div := jst.Id("homeMainModal")
+	div.Set("innerHTML", c.app.RenderTemplate(
+		struct {
+			ItemType string
+			ItemName string
+		}{
+			ItemType: string(c.itemType),
+			ItemName: c.itemName,
+		}, "home/shareCreate/index"),
+	)
+
+	form := jst.Id("shareCreateForm")
+	form.Call("addEventListener", "submit", c.app.AddEventFuncPreventDefault(c.createAction))
+	cancelButton := jst.Id("shareCreateCancelButton")
+	cancelButton.Call("addEventListener", "click", c.app.AddEventFunc(c.cancelAction))
+}
+
+func (c *HomeShareCreateComponent) createAction() {
+	if c.closed {
+		return
+	}
+
+	shareNew := restApiV1.ShareNew{
+		ShareMeta: restApiV1.ShareMeta{
+			ItemType:   c.itemType,
+			ItemId:     c.itemId,
+			DownloadFg: jst.Id("shareCreateDownloadFg").Get("checked").Bool(),
+		},
+		Password: jst.Id("shareCreatePassword").Get("value").String(),
+	}
+
+	// Validity in days, void for a share which never expires
+	validity, err := strconv.ParseInt(jst.Id("shareCreateValidity").Get("value").String(), 10, 64)
+	if err == nil && validity > 0 {
+		expirationTs := time.Now().Add(time.Duration(validity) * 24 * time.Hour).UnixNano()
+		shareNew.ExpirationTs = &expirationTs
+	}
+
+	share, cliErr := c.app.restClient.CreateShare(&shareNew)
+	if cliErr != nil {
+		c.app.HomeComponent.MessageComponent.ClientErrorMessage("Unable to share "+string(c.itemType), cliErr)
+		c.close()
+		return
+	}
+
+	div := jst.Id("homeMainModal")
+	div.Set("innerHTML", c.app.RenderTemplate(
+		struct {
+			ItemName string
+			Link     string
+		}{
+			ItemName: c.itemName,
+			Link:     ShareLink(share.Id),
+		}, "home/shareCreate/created"),
+	)
+
+	linkInput := jst.Id("shareCreateLink")
+	linkInput.Call("select")
+	closeButton := jst.Id("shareCreateCloseButton")
+	closeButton.Call("addEventListener", "click", c.app.AddEventFunc(c.cancelAction))
+}
+
+func (c *HomeShareCreateComponent) cancelAction() {
+	if c.closed {
+		return
+	}
+	c.close()
+}
+
+func (c *HomeShareCreateComponent) close() {
+	c.closed = true
+	c.app.HomeComponent.CloseModal()
+}
+
+// ShareLink returns the address of the page opening a share
+func ShareLink(shareId restApiV1.ShareId) string {
+	// The base uri ends with the server base path followed by a slash
+	return js.Global().Get("document").Get("baseURI").String() + "share/" + string(shareId)
+}
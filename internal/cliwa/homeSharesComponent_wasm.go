@@ -0,0 +1,124 @@
+package cliwa
+
+import (
+	"github.com/jypelle/mifasol/internal/cliwa/jst"
+	"github.com/jypelle/mifasol/restApiV1"
+	"syscall/js"
+	"time"
+)
+
+type HomeSharesComponent struct {
+	app    *App
+	closed bool
+}
+
+func NewHomeSharesComponent(app *App) *HomeSharesComponent {
+	c := &HomeSharesComponent{
+		app: app,
+	}
+
+	return c
+}
+
+func (c *HomeSharesComponent) Render() {
+	div := jst.Id("homeMainModal")
+	div.Set("innerHTML", c.app.RenderTemplate(
+		nil, "home/shares/index"),
+	)
+
+	closeButton := jst.Id("sharesCloseButton")
+	closeButton.Call("addEventListener", "click", c.app.AddEventFunc(c.closeAction))
+
+	listDiv := jst.Id("sharesList")
+	listDiv.Call("addEventListener", "click", c.app.AddRichEventFunc(func(this js.Value, i []js.Value) {
+		button := i[0].Get("target").Call("closest", ".shareDeleteButton")
+		if !button.Truthy() {
+			return
+		}
+		c.deleteAction(restApiV1.ShareId(button.Get("dataset").Get("shareid").String()))
+	}))
+
+	c.RefreshView()
+}
+
+func (c *HomeSharesComponent) RefreshView() {
+	if c.closed {
+		return
+	}
+
+	shares, cliErr := c.app.restClient.ReadShares()
+	if cliErr != nil {
+		c.app.HomeComponent.MessageComponent.ClientErrorMessage("Unable to retrieve shares", cliErr)
+		return
+	}
+
+	type ShareItem struct {
+		ShareId    string
+		ItemType   string
+		ItemName   string
+		OwnerName  string
+		Expiration string
+		ExpiredFg  bool
+		PasswordFg bool
+		DownloadFg bool
+		Link       string
+	}
+
+	now := time.Now().UnixNano()
+	var shareItemList []ShareItem
+	for _, share := range shares {
+		shareItem := ShareItem{
+			ShareId:    string(share.Id),
+			ItemType:   string(share.ItemType),
+			ItemName:   "(Deleted " + string(share.ItemType) + ")",
+			ExpiredFg:  share.IsExpired(now),
+			PasswordFg: share.PasswordFg,
+			DownloadFg: share.DownloadFg,
+			Link:       ShareLink(share.Id),
+		}
+		switch share.ItemType {
+		case restApiV1.ShareItemTypePlaylist:
+			if playlist, ok := c.app.localDb.Playlists[restApiV1.PlaylistId(share.ItemId)]; ok {
+				shareItem.ItemName = playlist.Name
+			}
+		case restApiV1.ShareItemTypeAlbum:
+			if album, ok := c.app.localDb.Albums[restApiV1.AlbumId(share.ItemId)]; ok {
+				shareItem.ItemName = album.Name
+			}
+		case restApiV1.ShareItemTypeSong:
+			if song, ok := c.app.localDb.Songs[restApiV1.SongId(share.ItemId)]; ok {
+				shareItem.ItemName = song.Name
+			}
+		}
+		if share.OwnerUserId != c.app.ConnectedUserId() {
+			shareItem.OwnerName = "Unknown user"
+			if user, ok := c.app.localDb.Users[share.OwnerUserId]; ok {
+				shareItem.OwnerName = user.Name
+			}
+		}
+		if share.ExpirationTs != nil {
+			shareItem.Expiration = time.Unix(0, *share.ExpirationTs).Format("2006-01-02 15:04")
+		}
+		shareItemList = append(shareItemList, shareItem)
+	}
+
+	jst.Id("sharesList").Set("innerHTML", c.app.RenderTemplate(shareItemList, "home/shares/shareList"))
+}
+
+func (c *HomeSharesComponent) deleteAction(shareId restApiV1.ShareId) {
+	_, cliErr := c.app.restClient.DeleteShare(shareId)
+	if cliErr != nil {
+		c.app.HomeComponent.MessageComponent.ClientErrorMessage("Unable to revoke the share", cliErr)
+		return
+	}
+
+	c.RefreshView()
+}
+
+func (c *HomeSharesComponent) closeAction() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.app.HomeComponent.CloseModal()
+}
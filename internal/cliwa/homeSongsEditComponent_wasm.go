@@ -0,0 +1,137 @@
+package cliwa
+
+import (
+	"github.com/jypelle/mifasol/internal/cliwa/jst"
+	"github.com/jypelle/mifasol/restApiV1"
+	"strconv"
+)
+
+type HomeSongsEditComponent struct {
+	app     *App
+	songIds []restApiV1.SongId
+	closed  bool
+}
+
+func NewHomeSongsEditComponent(app *App, songIds []restApiV1.SongId) *HomeSongsEditComponent {
+	c := &HomeSongsEditComponent{
+		app:     app,
+		songIds: songIds,
+	}
+
+	return c
+}
+
+func (c *HomeSongsEditComponent) Render() {
+	type AlbumItem struct {
+		AlbumId   restApiV1.AlbumId
+		AlbumName string
+	}
+	type ArtistItem struct {
+		ArtistId   restApiV1.ArtistId
+		ArtistName string
+	}
+
+	songsItem := struct {
+		SongCount int
+		Albums    []AlbumItem
+		Artists   []ArtistItem
+	}{
+		SongCount: len(c.songIds),
+	}
+
+	for _, album := range c.app.localDb.OrderedAlbums {
+		if album == nil {
+			songsItem.Albums = append(songsItem.Albums, AlbumItem{AlbumId: restApiV1.UnknownAlbumId, AlbumName: "(Unknown album)"})
+		} else {
+			songsItem.Albums = append(songsItem.Albums, AlbumItem{AlbumId: album.Id, AlbumName: album.Name})
+		}
+	}
+	for _, artist := range c.app.localDb.OrderedArtists {
+		if artist != nil {
+			songsItem.Artists = append(songsItem.Artists, ArtistItem{ArtistId: artist.Id, ArtistName: artist.Name})
+		}
+	}
+
+	div := jst.Id("homeMainModal")
+	div.Set("innerHTML", c.app.RenderTemplate(
+		&songsItem, "home/songsEdit/index"),
+	)
+
+	form := jst.Id("songsEditForm")
+	form.Call("addEventListener", "submit", c.app.AddEventFuncPreventDefault(c.saveAction))
+	cancelButton := jst.Id("songsEditCancelButton")
+	cancelButton.Call("addEventListener", "click", c.app.AddEventFunc(c.cancelAction))
+}
+
+func (c *HomeSongsEditComponent) saveAction() {
+	if c.closed {
+		return
+	}
+
+	defer func() {
+		// Close save pop-up and reload
+		c.close()
+		c.app.HomeComponent.Reload()
+		c.app.HideLoader()
+	}()
+
+	c.app.ShowLoader("Updating songs")
+
+	songsPatch := restApiV1.SongsPatch{SongIds: c.songIds}
+
+	// Publication year
+	publicationYearStr := jst.Id("songsEditPublicationYear").Get("value").String()
+	if publicationYearStr != "" {
+		publicationYear, err := strconv.ParseInt(publicationYearStr, 10, 64)
+		if err == nil {
+			songsPatch.PublicationYear = &publicationYear
+		}
+	}
+
+	// Album
+	albumId := restApiV1.AlbumId(jst.Id("songsEditAlbum").Get("value").String())
+	if albumId != "" {
+		songsPatch.AlbumId = &albumId
+	}
+
+	// Artists
+	addArtistId := restApiV1.ArtistId(jst.Id("songsEditAddArtist").Get("value").String())
+	if addArtistId != "" {
+		songsPatch.AddArtistIds = []restApiV1.ArtistId{addArtistId}
+	}
+	removeArtistId := restApiV1.ArtistId(jst.Id("songsEditRemoveArtist").Get("value").String())
+	if removeArtistId != "" {
+		songsPatch.RemoveArtistIds = []restApiV1.ArtistId{removeArtistId}
+	}
+
+	// Explicit flag
+	explicitFgStr := jst.Id("songsEditExplicitFg").Get("value").String()
+	if explicitFgStr != "" {
+		explicitFg := explicitFgStr == "true"
+		songsPatch.ExplicitFg = &explicitFg
+	}
+
+	if songsPatch.IsEmpty() {
+		return
+	}
+
+	_, cliErr := c.app.restClient.UpdateSongs(&songsPatch)
+	if cliErr != nil {
+		c.app.HomeComponent.MessageComponent.ClientErrorMessage("Unable to update the songs", cliErr)
+		return
+	}
+
+	c.app.HomeComponent.LibraryComponent.ClearSelectedSongs()
+}
+
+func (c *HomeSongsEditComponent) cancelAction() {
+	if c.closed {
+		return
+	}
+	c.close()
+}
+
+func (c *HomeSongsEditComponent) close() {
+	c.closed = true
+	c.app.HomeComponent.CloseModal()
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/jypelle/mifasol/internal/cliwa/jst"
-	"github.com/jypelle/mifasol/restApiV1"
 	"html"
 	"strings"
 	"syscall/js"
@@ -99,21 +98,22 @@ func (c *HomeUploadSongComponent) uploadSongAction(this js.Value, args []js.Valu
 	content := make([]byte, songFile.Get("size").Int())
 	js.CopyBytesToGo(content, jscontent)
 
-	lowerName := strings.ToLower(songFile.Get("name").String())
-	var songFormat restApiV1.SongFormat
-	if strings.HasSuffix(lowerName, ".flac") {
-		songFormat = restApiV1.SongFormatFlac
-	} else {
-		songFormat = restApiV1.SongFormatMp3
+	// The upload resumes by itself after a network failure
+	uploadSongUploadingProgressbar := jst.Id("uploadSongUploadingProgressbar")
+	setProgress := func(offset int64) {
+		songProgress := 1.0
+		if len(content) > 0 {
+			songProgress = float64(offset) / float64(len(content))
+		}
+		uploadSongUploadingProgressbar.Get("style").Set("width", fmt.Sprintf("%f%%", 100.0*(float64(c.songFilesIdx)+songProgress)/float64(len(c.songFiles))))
 	}
 
-	_, cliErr := c.app.restClient.CreateSongContent(songFormat, bytes.NewReader(content))
+	_, cliErr := c.app.restClient.CreateSongContent(bytes.NewReader(content), int64(len(content)), setProgress)
 	if cliErr != nil {
 		c.app.HomeComponent.MessageComponent.ClientErrorMessage(fmt.Sprintf("Unable to upload song %s", songFile.Get("name")), cliErr)
 	}
 
-	uploadSongUploadingProgressbar := jst.Id("uploadSongUploadingProgressbar")
-	uploadSongUploadingProgressbar.Get("style").Set("width", fmt.Sprintf("%f%%", 100.0*float64(c.songFilesIdx+1)/float64(len(c.songFiles))))
+	setProgress(int64(len(content)))
 
 	if c.songFilesIdx < len(c.songFiles)-1 {
 		c.songFilesIdx++
@@ -8,6 +8,7 @@ import (
 
 var Document = js.Global().Get("document")
 var LocalStorage = js.Global().Get("localStorage")
+var SessionStorage = js.Global().Get("sessionStorage")
 
 func Id(id string) js.Value {
 	return Document.Call("getElementById", id)
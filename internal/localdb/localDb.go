@@ -5,13 +5,23 @@ import (
 	"github.com/jypelle/mifasol/restClientV1"
 	"golang.org/x/text/collate"
 	"sort"
+	"sync"
+	"time"
 )
 
+// Delay before reconnecting to the server event stream
+const eventReconnectDelay = 5 * time.Second
+
+// Delay to gather a burst of server events into a single refresh
+const eventGatheringDelay = 500 * time.Millisecond
+
 type LocalDb struct {
 	restClient *restClientV1.RestClient
 	collator   *collate.Collator
 
-	LastSyncTs int64
+	refreshMutex sync.Mutex
+
+	LastSyncRevision int64
 
 	Albums                  map[restApiV1.AlbumId]*restApiV1.Album
 	Artists                 map[restApiV1.ArtistId]*restApiV1.Artist
@@ -77,15 +87,42 @@ func (l *LocalDb) RemovePlaylistFromMyFavorite(playlistId restApiV1.PlaylistId)
 	l.refreshUserOrderedFavoritePlaylists(l.restClient.UserId())
 }
 
-func (l *LocalDb) Refresh() restClientV1.ClientError {
+// Refresh synchronizes the library page by page, calling onProgress (if not nil) after each page with the number of
+// changes received and to receive. The ordered lists are rebuilt after the last page: when interrupted, the pages already
+// received are kept and the next refresh goes on from the last of them.
+func (l *LocalDb) Refresh(onProgress func(changeCount int64, totalChangeCount int64)) restClientV1.ClientError {
+	l.refreshMutex.Lock()
+	defer l.refreshMutex.Unlock()
+
+	continuationToken := ""
+	for {
+		// Retrieve the next part of the library content from mifasolsrv
+		syncReportPage, cliErr := l.restClient.ReadSyncReportPage(l.LastSyncRevision, continuationToken)
+		if cliErr != nil {
+			return cliErr
+		}
+
+		l.applySyncReport(&syncReportPage.SyncReport)
+		l.LastSyncRevision = syncReportPage.SyncRevision
+
+		if onProgress != nil {
+			onProgress(syncReportPage.ChangeCount, syncReportPage.TotalChangeCount)
+		}
 
-	// Retrieve library content from mifasolsrv
-	syncReport, cliErr := l.restClient.ReadSyncReport(l.LastSyncTs)
-	if cliErr != nil {
-		return cliErr
+		if syncReportPage.ContinuationToken == "" {
+			break
+		}
+		continuationToken = syncReportPage.ContinuationToken
 	}
 
-	if l.LastSyncTs == 0 {
+	l.refreshOrderedLists()
+
+	return nil
+}
+
+// applySyncReport updates the indexes with the changes of a sync report
+func (l *LocalDb) applySyncReport(syncReport *restApiV1.SyncReport) {
+	if l.LastSyncRevision == 0 {
 		// Init map on first sync
 		l.Songs = make(map[restApiV1.SongId]*restApiV1.Song, len(syncReport.Songs))
 		l.Albums = make(map[restApiV1.AlbumId]*restApiV1.Album, len(syncReport.Albums))
@@ -94,34 +131,34 @@ func (l *LocalDb) Refresh() restClientV1.ClientError {
 		l.Users = make(map[restApiV1.UserId]*restApiV1.User, len(syncReport.Users))
 		l.UserFavoritePlaylistIds = make(map[restApiV1.UserId]map[restApiV1.PlaylistId]struct{}, len(syncReport.Users))
 		l.UserFavoriteSongIds = make(map[restApiV1.UserId]map[restApiV1.SongId]struct{}, len(syncReport.Users))
-	} else {
-		// Remove deleted items
-		for _, songId := range syncReport.DeletedSongIds {
-			delete(l.Songs, songId)
-		}
-		for _, albumId := range syncReport.DeletedAlbumIds {
-			delete(l.Albums, albumId)
-		}
-		for _, artistId := range syncReport.DeletedArtistIds {
-			delete(l.Artists, artistId)
-		}
-		for _, playlistId := range syncReport.DeletedPlaylistIds {
-			delete(l.Playlists, playlistId)
-		}
-		for _, userId := range syncReport.DeletedUserIds {
-			delete(l.Users, userId)
-			delete(l.UserFavoritePlaylistIds, userId)
-			delete(l.UserFavoriteSongIds, userId)
-		}
-		for _, favoritePlaylistId := range syncReport.DeletedFavoritePlaylistIds {
-			if favoritePlaylistIds, ok := l.UserFavoritePlaylistIds[favoritePlaylistId.UserId]; ok {
-				delete(favoritePlaylistIds, favoritePlaylistId.PlaylistId)
-			}
+	}
+
+	// Remove deleted items
+	for _, songId := range syncReport.DeletedSongIds {
+		delete(l.Songs, songId)
+	}
+	for _, albumId := range syncReport.DeletedAlbumIds {
+		delete(l.Albums, albumId)
+	}
+	for _, artistId := range syncReport.DeletedArtistIds {
+		delete(l.Artists, artistId)
+	}
+	for _, playlistId := range syncReport.DeletedPlaylistIds {
+		delete(l.Playlists, playlistId)
+	}
+	for _, userId := range syncReport.DeletedUserIds {
+		delete(l.Users, userId)
+		delete(l.UserFavoritePlaylistIds, userId)
+		delete(l.UserFavoriteSongIds, userId)
+	}
+	for _, favoritePlaylistId := range syncReport.DeletedFavoritePlaylistIds {
+		if favoritePlaylistIds, ok := l.UserFavoritePlaylistIds[favoritePlaylistId.UserId]; ok {
+			delete(favoritePlaylistIds, favoritePlaylistId.PlaylistId)
 		}
-		for _, favoriteSongId := range syncReport.DeletedFavoriteSongIds {
-			if favoriteSongIds, ok := l.UserFavoriteSongIds[favoriteSongId.UserId]; ok {
-				delete(favoriteSongIds, favoriteSongId.SongId)
-			}
+	}
+	for _, favoriteSongId := range syncReport.DeletedFavoriteSongIds {
+		if favoriteSongIds, ok := l.UserFavoriteSongIds[favoriteSongId.UserId]; ok {
+			delete(favoriteSongIds, favoriteSongId.SongId)
 		}
 	}
 
@@ -163,18 +200,27 @@ func (l *LocalDb) Refresh() restClientV1.ClientError {
 		}
 	}
 
-	// Indexing favorite playlists
+	// Indexing favorite playlists, whose user may come in a later page
 	for idx := range syncReport.FavoritePlaylists {
 		favoritePlaylist := &syncReport.FavoritePlaylists[idx]
+		if _, ok := l.UserFavoritePlaylistIds[favoritePlaylist.Id.UserId]; !ok {
+			l.UserFavoritePlaylistIds[favoritePlaylist.Id.UserId] = make(map[restApiV1.PlaylistId]struct{}, 2)
+		}
 		l.UserFavoritePlaylistIds[favoritePlaylist.Id.UserId][favoritePlaylist.Id.PlaylistId] = struct{}{}
 	}
 
-	// Indexing favorite songs
+	// Indexing favorite songs, whose user may come in a later page
 	for idx := range syncReport.FavoriteSongs {
 		favoriteSong := &syncReport.FavoriteSongs[idx]
+		if _, ok := l.UserFavoriteSongIds[favoriteSong.Id.UserId]; !ok {
+			l.UserFavoriteSongIds[favoriteSong.Id.UserId] = make(map[restApiV1.SongId]struct{}, 2)
+		}
 		l.UserFavoriteSongIds[favoriteSong.Id.UserId][favoriteSong.Id.SongId] = struct{}{}
 	}
+}
 
+// refreshOrderedLists rebuilds the ordered lists from the indexes
+func (l *LocalDb) refreshOrderedLists() {
 	// OrderedSongs
 	l.OrderedSongs = make([]*restApiV1.Song, 0, len(l.Songs))
 	for _, song := range l.Songs {
@@ -348,11 +394,72 @@ func (l *LocalDb) Refresh() restClientV1.ClientError {
 	for _, user := range l.Users {
 		l.refreshUserOrderedFavoriteSongs(user.Id)
 	}
+}
 
-	// Remember new sync timestamp
-	l.LastSyncTs = syncReport.SyncTs
+// WatchEvents listens to the server change events until stopCh is closed, calling onChange after each burst of new changes
+// and after each reconnection, so the client can refresh its content
+func (l *LocalDb) WatchEvents(stopCh <-chan struct{}, onChange func()) {
+	connected := false
+	for {
+		eventStream, cliErr := l.restClient.OpenEventStream()
+		if cliErr == nil {
+			// Changes may have been missed while disconnected
+			if connected {
+				onChange()
+			}
+			connected = true
 
-	return nil
+			if !l.readEvents(eventStream, stopCh, onChange) {
+				return
+			}
+		}
+
+		select {
+		case <-time.After(eventReconnectDelay):
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// readEvents calls onChange for the events of the stream until it's closed, returning false when stopCh is closed
+func (l *LocalDb) readEvents(eventStream *restClientV1.EventStream, stopCh <-chan struct{}, onChange func()) bool {
+	defer eventStream.Close()
+
+	eventCh := make(chan *restApiV1.Event)
+	go func() {
+		defer close(eventCh)
+		for {
+			event, cliErr := eventStream.Next()
+			if cliErr != nil {
+				return
+			}
+			select {
+			case eventCh <- event:
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	var gatheringTimer <-chan time.Time
+	for {
+		select {
+		case event, ok := <-eventCh:
+			if !ok {
+				return true
+			}
+			// Skip changes already retrieved by the last refresh
+			if event.Revision > l.LastSyncRevision && gatheringTimer == nil {
+				gatheringTimer = time.After(eventGatheringDelay)
+			}
+		case <-gatheringTimer:
+			gatheringTimer = nil
+			onChange()
+		case <-stopCh:
+			return false
+		}
+	}
 }
 
 func (l *LocalDb) refreshUserOrderedFavoritePlaylists(userId restApiV1.UserId) {
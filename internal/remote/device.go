@@ -0,0 +1,125 @@
+package remote
+
+import (
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restClientV1"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// Delay before reconnecting to the command stream
+const commandReconnectDelay = 5 * time.Second
+
+// Device announces a player to the server, receives its remote commands and reports its "now playing" state
+type Device struct {
+	restClient *restClientV1.RestClient
+	Id         restApiV1.DeviceId
+	meta       restApiV1.DeviceMeta
+
+	stateToReportCh chan *restApiV1.DeviceState
+	connectedCh     chan struct{}
+}
+
+func NewDevice(restClient *restClientV1.RestClient, id restApiV1.DeviceId, meta restApiV1.DeviceMeta) *Device {
+	return &Device{
+		restClient:      restClient,
+		Id:              id,
+		meta:            meta,
+		stateToReportCh: make(chan *restApiV1.DeviceState, 1),
+		connectedCh:     make(chan struct{}, 1),
+	}
+}
+
+// Listen keeps the device connected to the server and calls onCommand for each remote command, until stopCh is closed
+func (d *Device) Listen(stopCh <-chan struct{}, onCommand func(command *restApiV1.DeviceCommand)) {
+	go d.stateReporter(stopCh)
+
+	for {
+		commandStream, cliErr := d.restClient.OpenDeviceCommandStream(d.Id, &d.meta)
+		if cliErr == nil {
+			// The server forgets the state of disconnected devices
+			select {
+			case d.connectedCh <- struct{}{}:
+			default:
+			}
+
+			if !d.readCommands(commandStream, stopCh, onCommand) {
+				return
+			}
+		} else {
+			logrus.Debugf("Unable to connect the device to the server: %v", cliErr)
+		}
+
+		select {
+		case <-time.After(commandReconnectDelay):
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// readCommands calls onCommand for the commands of the stream until it's closed, returning false when stopCh is closed
+func (d *Device) readCommands(commandStream *restClientV1.DeviceCommandStream, stopCh <-chan struct{}, onCommand func(command *restApiV1.DeviceCommand)) bool {
+	defer commandStream.Close()
+
+	commandCh := make(chan *restApiV1.DeviceCommand)
+	go func() {
+		defer close(commandCh)
+		for {
+			command, cliErr := commandStream.Next()
+			if cliErr != nil {
+				return
+			}
+			select {
+			case commandCh <- command:
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case command, ok := <-commandCh:
+			if !ok {
+				return true
+			}
+			onCommand(command)
+		case <-stopCh:
+			return false
+		}
+	}
+}
+
+// ReportState asynchronously sends the "now playing" state of the device to the server
+func (d *Device) ReportState(state *restApiV1.DeviceState) {
+	// Only the last state needs to be reported
+	select {
+	case <-d.stateToReportCh:
+	default:
+	}
+	select {
+	case d.stateToReportCh <- state:
+	default:
+	}
+}
+
+func (d *Device) stateReporter(stopCh <-chan struct{}) {
+	var lastState *restApiV1.DeviceState
+	for {
+		select {
+		case lastState = <-d.stateToReportCh:
+		case <-d.connectedCh:
+			if lastState == nil {
+				continue
+			}
+		case <-stopCh:
+			return
+		}
+
+		_, cliErr := d.restClient.UpdateDeviceState(d.Id, lastState)
+		if cliErr != nil {
+			logrus.Debugf("Unable to report the device state: %v", cliErr)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package srv
+
+import (
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const auditPurgeInterval = time.Hour
+
+// autoPurgeAudit periodically removes audit trail entries older than the configured retention
+func (s *ServerApp) autoPurgeAudit() {
+	defer s.backgroundTasks.Done()
+
+	ticker := time.NewTicker(auditPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		beforeTs := time.Now().Add(-time.Duration(s.AuditRetentionDays) * 24 * time.Hour).UnixNano()
+		count, err := s.store.PurgeAuditEntries(nil, beforeTs)
+		if err != nil {
+			logrus.Warningf("Unable to purge the audit trail: %v", err)
+		} else if count > 0 {
+			logrus.Printf("%d audit trail entries purged", count)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.stopCh:
+			return
+		}
+	}
+}
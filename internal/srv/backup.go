@@ -0,0 +1,506 @@
+package srv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/srv/store"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/internal/version"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const backupManifestFilename = "manifest.json"
+
+type BackupManifest struct {
+	AppVersion string               `json:"appVersion"`
+	CreationTs int64                `json:"creationTs"`
+	Files      []BackupManifestFile `json:"files"`
+	// Song files referenced by the database but missing from the storage, not saved
+	MissingFiles []string `json:"missingFiles,omitempty"`
+}
+
+type BackupManifestFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	ModTs    int64  `json:"modTs"`
+	Checksum string `json:"checksum"`
+}
+
+// Backup writes a consistent copy of the database, the config files and the song files of the config folder into destDir.
+// Song files already saved by a previous backup into destDir are only copied again if their checksum changed.
+func (s *ServerApp) Backup(destDir string) error {
+	logrus.Printf("Backup to %s ...", destDir)
+
+	err := os.MkdirAll(destDir, 0770)
+	if err != nil {
+		return err
+	}
+
+	// Retrieve previous backup content
+	previousFiles := make(map[string]BackupManifestFile)
+	previousManifest, err := readBackupManifest(destDir)
+	if err == nil {
+		for _, manifestFile := range previousManifest.Files {
+			previousFiles[manifestFile.Path] = manifestFile
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	manifest := BackupManifest{
+		AppVersion: version.AppVersion.String(),
+		CreationTs: time.Now().UnixNano(),
+	}
+	copiedCount := 0
+
+	// Database, the changes being only locked during its copy
+	dbPath := relativeConfigPath(s.ConfigDir, s.GetCompleteConfigDbFilename())
+	dbFilename := filepath.Join(destDir, filepath.FromSlash(dbPath))
+	err = s.store.Snapshot(dbFilename)
+	if err != nil {
+		return err
+	}
+	manifestFile, err := newBackupManifestFile(destDir, dbPath)
+	if err != nil {
+		return err
+	}
+	manifest.Files = append(manifest.Files, *manifestFile)
+
+	// Config files
+	for _, filename := range []string{s.GetCompleteConfigFilename(), s.GetCompleteConfigKeyFilename(), s.GetCompleteConfigCertFilename()} {
+		exists, err := tool.IsFileExists(filename)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		manifestFile, err := copyBackupFile(filename, filepath.Join(destDir, filepath.Base(filename)))
+		if err != nil {
+			return err
+		}
+		manifestFile.Path = relativeConfigPath(s.ConfigDir, filename)
+		manifest.Files = append(manifest.Files, *manifestFile)
+	}
+
+	// Song files referenced by the database copy, read through the storage and saved with the layout of the local data folder
+	songFiles, err := store.SnapshotSongFiles(dbFilename)
+	if err != nil {
+		return err
+	}
+
+	storage := s.store.Storage()
+	for _, songFile := range songFiles {
+		path := relativeConfigPath(s.ConfigDir, filepath.Join(s.GetCompleteConfigDataDirName(), filepath.FromSlash(songFile.Key)))
+		destFilename := filepath.Join(destDir, filepath.FromSlash(path))
+
+		manifestFile, copied, err := backupSongFile(storage, songFile, destFilename, previousFiles[path])
+		if err != nil {
+			if os.IsNotExist(err) {
+				logrus.Warningf("Missing song file: %s", songFile.Key)
+				manifest.MissingFiles = append(manifest.MissingFiles, path)
+				continue
+			}
+			return err
+		}
+		manifestFile.Path = path
+		manifest.Files = append(manifest.Files, *manifestFile)
+		if copied {
+			copiedCount++
+		}
+	}
+
+	// Remove files deleted since previous backup
+	err = removeUnlistedFiles(destDir, filepath.Join(destDir, filepath.Base(s.GetCompleteConfigDataDirName())), &manifest)
+	if err != nil {
+		return err
+	}
+
+	// Manifest is written last: its presence means the backup is complete
+	err = writeBackupManifest(destDir, &manifest)
+	if err != nil {
+		return err
+	}
+
+	if len(manifest.MissingFiles) > 0 {
+		logrus.Warningf("Backup done: %d files saved, %d data files copied, %d song files missing from %s", len(manifest.Files), copiedCount, len(manifest.MissingFiles), storage.Name())
+	} else {
+		logrus.Printf("Backup done: %d files saved, %d data files copied", len(manifest.Files), copiedCount)
+	}
+
+	return nil
+}
+
+// backupSongFile saves a song file from the storage into destFilename, unless the previous backup already saved it.
+// The song may have been moved to or restored from the trash since the database copy.
+func backupSongFile(storage store.Storage, songFile store.SnapshotSongFile, destFilename string, previousFile BackupManifestFile) (*BackupManifestFile, bool, error) {
+	key := songFile.Key
+	size, modTime, err := storage.Stat(key)
+	if os.IsNotExist(err) {
+		key = songFile.MovedKey
+		size, modTime, err = storage.Stat(key)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Skip unchanged files
+	if previousFile.Path != "" && previousFile.Size == size && isFileSize(destFilename, size) {
+		checksum := previousFile.Checksum
+		if previousFile.ModTs != modTime.UnixNano() {
+			err = storage.View(key, func(filename string) error {
+				checksum, err = fileChecksum(filename)
+				return err
+			})
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		if checksum == previousFile.Checksum {
+			previousFile.ModTs = modTime.UnixNano()
+			return &previousFile, false, nil
+		}
+	}
+
+	var manifestFile *BackupManifestFile
+	err = storage.View(key, func(filename string) error {
+		manifestFile, err = copyBackupFile(filename, destFilename)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The local copy of a remote file is as old as its download
+	manifestFile.ModTs = modTime.UnixNano()
+
+	return manifestFile, true, nil
+}
+
+// RestoreBackup replaces the content of configDir with the backup stored in srcDir.
+// Mifasol server should be stopped: the restoration is refused while the database is in use.
+func RestoreBackup(configDir string, srcDir string) error {
+	logrus.Printf("Restore from %s ...", srcDir)
+
+	serverConfig := config.ServerConfig{ConfigDir: configDir}
+
+	err := store.CheckDatabaseUnused(serverConfig.GetCompleteConfigDbFilename())
+	if err != nil {
+		return err
+	}
+
+	manifest, err := readBackupManifest(srcDir)
+	if err != nil {
+		return fmt.Errorf("Unable to read backup manifest: %w", err)
+	}
+
+	// Check backup integrity before touching anything
+	for _, manifestFile := range manifest.Files {
+		checksum, err := fileChecksum(filepath.Join(srcDir, filepath.FromSlash(manifestFile.Path)))
+		if err != nil {
+			return err
+		}
+		if checksum != manifestFile.Checksum {
+			return fmt.Errorf("Corrupted backup file: %s", manifestFile.Path)
+		}
+	}
+
+	// Remove database journal files
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		err = os.Remove(serverConfig.GetCompleteConfigDbFilename() + suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// Copy backup files
+	copiedCount := 0
+	for _, manifestFile := range manifest.Files {
+		destFilename := filepath.Join(configDir, filepath.FromSlash(manifestFile.Path))
+
+		if isFileSize(destFilename, manifestFile.Size) {
+			checksum, err := fileChecksum(destFilename)
+			if err != nil {
+				return err
+			}
+			if checksum == manifestFile.Checksum {
+				continue
+			}
+		}
+
+		_, err = copyBackupFile(filepath.Join(srcDir, filepath.FromSlash(manifestFile.Path)), destFilename)
+		if err != nil {
+			return err
+		}
+		copiedCount++
+	}
+
+	// Remove data files missing from backup
+	err = removeUnlistedFiles(configDir, serverConfig.GetCompleteConfigDataDirName(), manifest)
+	if err != nil {
+		return err
+	}
+
+	if len(manifest.MissingFiles) > 0 {
+		logrus.Warningf("%d song files were missing from the storage when the backup was done, they are not restored", len(manifest.MissingFiles))
+	}
+
+	// Song files stored outside of the config folder are written back into the storage
+	rawConfig, err := os.ReadFile(serverConfig.GetCompleteConfigFilename())
+	if err != nil {
+		return err
+	}
+	draftServerEditableConfig := &config.ServerEditableConfig{}
+	err = json.Unmarshal(rawConfig, draftServerEditableConfig)
+	if err != nil {
+		return err
+	}
+	serverConfig.ServerEditableConfig = config.NewServerEditableConfig(draftServerEditableConfig)
+	if serverConfig.Storage != config.StorageLocal {
+		err = restoreStorageSongFiles(&serverConfig, manifest)
+		if err != nil {
+			return err
+		}
+	}
+
+	logrus.Printf("Restore done: %d files copied", copiedCount)
+
+	return nil
+}
+
+// restoreStorageSongFiles moves the restored song files from the local data folder into the configured storage
+func restoreStorageSongFiles(serverConfig *config.ServerConfig, manifest *BackupManifest) error {
+	storage, err := store.NewStorage(serverConfig, serverConfig.Storage)
+	if err != nil {
+		return err
+	}
+
+	dataPath := relativeConfigPath(serverConfig.ConfigDir, serverConfig.GetCompleteConfigDataDirName()) + "/"
+	writtenCount := 0
+	for _, manifestFile := range manifest.Files {
+		if !strings.HasPrefix(manifestFile.Path, dataPath) {
+			continue
+		}
+		filename := filepath.Join(serverConfig.ConfigDir, filepath.FromSlash(manifestFile.Path))
+
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		err = storage.Write(strings.TrimPrefix(manifestFile.Path, dataPath), file, manifestFile.Size)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		err = os.Remove(filename)
+		if err != nil {
+			return err
+		}
+		writtenCount++
+	}
+
+	logrus.Printf("%d song files written into %s", writtenCount, storage.Name())
+
+	return nil
+}
+
+// scheduledBackup periodically backups the server into the configured backup folder
+func (s *ServerApp) scheduledBackup() {
+	defer s.backgroundTasks.Done()
+
+	interval := time.Duration(s.BackupInterval) * time.Hour
+
+	// Wait for the end of the interval since last backup
+	var delay time.Duration
+	if manifest, err := readBackupManifest(s.BackupDir); err == nil {
+		delay = time.Until(time.Unix(0, manifest.CreationTs).Add(interval))
+	}
+
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-s.stopCh:
+			timer.Stop()
+			return
+		}
+
+		err := s.Backup(s.BackupDir)
+		if err != nil {
+			logrus.Warningf("Unable to backup the server: %v", err)
+		}
+
+		delay = interval
+	}
+}
+
+func readBackupManifest(dir string) (*BackupManifest, error) {
+	rawManifest, err := os.ReadFile(filepath.Join(dir, backupManifestFilename))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest BackupManifest
+	err = json.Unmarshal(rawManifest, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+func writeBackupManifest(dir string, manifest *BackupManifest) error {
+	rawManifest, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmpFilename := filepath.Join(dir, backupManifestFilename+".tmp")
+	err = os.WriteFile(tmpFilename, rawManifest, 0660)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilename, filepath.Join(dir, backupManifestFilename))
+}
+
+// newBackupManifestFile describes a file already written into the backup folder
+func newBackupManifestFile(dir string, path string) (*BackupManifestFile, error) {
+	filename := filepath.Join(dir, filepath.FromSlash(path))
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, err := fileChecksum(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BackupManifestFile{
+		Path:     path,
+		Size:     info.Size(),
+		ModTs:    info.ModTime().UnixNano(),
+		Checksum: checksum,
+	}, nil
+}
+
+// copyBackupFile copies srcFilename to destFilename, keeping its modification time, and computes its checksum
+func copyBackupFile(srcFilename string, destFilename string) (*BackupManifestFile, error) {
+	srcFile, err := os.Open(srcFilename)
+	if err != nil {
+		return nil, err
+	}
+	defer srcFile.Close()
+
+	info, err := srcFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(destFilename), 0770)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpFilename := destFilename + ".tmp"
+	tmpFile, err := os.OpenFile(tmpFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(tmpFile, io.TeeReader(srcFile, hash))
+	if err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFilename)
+		return nil, err
+	}
+	err = tmpFile.Close()
+	if err != nil {
+		os.Remove(tmpFilename)
+		return nil, err
+	}
+
+	err = os.Rename(tmpFilename, destFilename)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chtimes(destFilename, info.ModTime(), info.ModTime())
+	if err != nil {
+		return nil, err
+	}
+
+	return &BackupManifestFile{
+		Size:     info.Size(),
+		ModTs:    info.ModTime().UnixNano(),
+		Checksum: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// removeUnlistedFiles removes files from dataDir which are not listed in the manifest
+func removeUnlistedFiles(rootDir string, dataDir string, manifest *BackupManifest) error {
+	listedPaths := make(map[string]struct{}, len(manifest.Files))
+	for _, manifestFile := range manifest.Files {
+		listedPaths[manifestFile.Path] = struct{}{}
+	}
+
+	err := filepath.WalkDir(dataDir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if _, ok := listedPaths[relativeConfigPath(rootDir, filename)]; !ok {
+			logrus.Debugf("Remove %s", filename)
+			return os.Remove(filename)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func fileChecksum(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func isFileSize(filename string, size int64) bool {
+	info, err := os.Stat(filename)
+	return err == nil && info.Size() == size
+}
+
+// relativeConfigPath returns the slash separated path of filename relative to rootDir
+func relativeConfigPath(rootDir string, filename string) string {
+	path, err := filepath.Rel(rootDir, filename)
+	if err != nil {
+		return filepath.ToSlash(filename)
+	}
+	return filepath.ToSlash(path)
+}
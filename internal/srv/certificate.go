@@ -0,0 +1,129 @@
+package srv
+
+import (
+	"crypto/tls"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+const certificateCheckInterval = time.Minute
+
+// certificateLoader serves the SSL certificate to the http server, so that a renewed certificate is used
+// by the new connections without restarting the server
+type certificateLoader struct {
+	certFilename string
+	keyFilename  string
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newCertificateLoader(certFilename string, keyFilename string) (*certificateLoader, error) {
+	loader := &certificateLoader{
+		certFilename: certFilename,
+		keyFilename:  keyFilename,
+	}
+
+	err := loader.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return loader, nil
+}
+
+// GetCertificate returns the current certificate, as expected by tls.Config
+func (l *certificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.certificate, nil
+}
+
+// load reads the certificate chain and key files, keeping the current certificate on failure
+func (l *certificateLoader) load() error {
+	certModTime, keyModTime := l.modTimes()
+
+	certificate, err := tls.LoadX509KeyPair(l.certFilename, l.keyFilename)
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.certificate = &certificate
+	l.certModTime = certModTime
+	l.keyModTime = keyModTime
+
+	return nil
+}
+
+// changed tells if the certificate or key file has been modified since the last load
+func (l *certificateLoader) changed() bool {
+	certModTime, keyModTime := l.modTimes()
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return !certModTime.Equal(l.certModTime) || !keyModTime.Equal(l.keyModTime)
+}
+
+func (l *certificateLoader) modTimes() (certModTime time.Time, keyModTime time.Time) {
+	if fileInfo, err := os.Stat(l.certFilename); err == nil {
+		certModTime = fileInfo.ModTime()
+	}
+	if fileInfo, err := os.Stat(l.keyFilename); err == nil {
+		keyModTime = fileInfo.ModTime()
+	}
+	return certModTime, keyModTime
+}
+
+// ReloadCertificate reads again the certificate files, on SIGHUP
+func (s *ServerApp) ReloadCertificate() {
+	if s.certificateLoader == nil {
+		logrus.Printf("No certificate to reload, SSL being disabled")
+		return
+	}
+
+	err := s.certificateLoader.load()
+	if err != nil {
+		logrus.Warningf("Unable to reload the certificate, keeping the previous one: %v", err)
+		return
+	}
+	logrus.Printf("Certificate %s reloaded", s.certificateLoader.certFilename)
+}
+
+// watchCertificate reloads the certificate files when they change, like after a certbot renewal
+func (s *ServerApp) watchCertificate() {
+	defer s.backgroundTasks.Done()
+
+	ticker := time.NewTicker(certificateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.certificateLoader.changed() {
+				s.ReloadCertificate()
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// generateSelfSignedCertificate (re)creates the self-signed cert and key files for the server hostnames
+func (s *ServerApp) generateSelfSignedCertificate() error {
+	return tool.GenerateTlsCertificate(
+		"Mifasol",
+		"Mifasol Server",
+		s.GetCompleteConfigKeyFilename(),
+		s.GetCompleteConfigCertFilename(),
+		s.Hostnames)
+}
@@ -0,0 +1,41 @@
+package srv
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Check verifies the consistency between database and song files, optionally repairs found issues
+// and prints the report on standard output
+func (s *ServerApp) Check(repair bool, jsonOutput bool) error {
+	report, err := s.store.Check(repair)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(report)
+	}
+
+	fmt.Printf("%d songs and %d song files checked\n", report.SongCount, report.FileCount)
+	if len(report.Issues) == 0 {
+		fmt.Println("No issue found")
+		return nil
+	}
+
+	repairedCount := 0
+	for _, issue := range report.Issues {
+		status := ""
+		if issue.Repaired {
+			status = " (repaired)"
+			repairedCount++
+		}
+		fmt.Printf("[%s] %s%s\n", issue.Type, issue.Description, status)
+	}
+	fmt.Printf("%d issues found, %d repaired\n", len(report.Issues), repairedCount)
+
+	return nil
+}
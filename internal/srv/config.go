@@ -1,24 +1,62 @@
 package srv
 
 import (
+	"crypto/tls"
 	"fmt"
-	"os"
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/sirupsen/logrus"
+	"net"
+	"strings"
 )
 
 func (s *ServerApp) Config(
 	hostnames []string,
 	port int64,
-	ssl *bool) {
+	bindAddress *string,
+	unixSocket *string,
+	basePath *string,
+	trustedProxies *[]string,
+	ssl *bool,
+	certFile *string,
+	keyFile *string,
+	renewSelfSignedCertificate bool,
+	httpRedirectPort *int64,
+	trashRetentionDays int64,
+	auditRetentionDays int64,
+	s3Endpoint string,
+	s3Region string,
+	s3Bucket string,
+	s3Prefix string,
+	s3AccessKey string,
+	s3SecretKey string,
+	s3PresignedRedirect *bool,
+	backupDir *string,
+	backupInterval int64,
+	inboxDir *string,
+	inboxInterval int64,
+	inboxKeepImported *bool,
+	mpdEnabled *bool,
+	mpdPort int64,
+	upnpEnabled *bool,
+	upnpPort int64,
+	upnpName string,
+	ffmpegPath string,
+	metricsEnabled *bool,
+	metricsAddress *string,
+	metricsToken *string,
+	loginMaxFailures int64,
+	loginLockoutMinutes int64,
+	apiRateLimit int64,
+	streamLimit int64) {
 
 	shouldSaveConfig := false
 
 	if len(hostnames) > 0 {
 		s.ServerEditableConfig.Hostnames = hostnames
-		os.Remove(s.GetCompleteConfigKeyFilename())
-		os.Remove(s.GetCompleteConfigCertFilename())
 		shouldSaveConfig = true
 		fmt.Println("Server hostnames updated")
-		fmt.Println("Self-signed certificate will be regenerated, clients should accept the new one")
+		renewSelfSignedCertificate = true
 	}
 
 	if port > 0 {
@@ -27,6 +65,48 @@ func (s *ServerApp) Config(
 		fmt.Println("Server port updated")
 	}
 
+	if bindAddress != nil {
+		s.ServerEditableConfig.BindAddress = *bindAddress
+		shouldSaveConfig = true
+		if *bindAddress != "" {
+			fmt.Println("Server bind address updated")
+			if net.ParseIP(*bindAddress) == nil {
+				fmt.Printf("Warning: %s is not an IP address\n", *bindAddress)
+			}
+		} else {
+			fmt.Println("Server listens on every network interface")
+		}
+	}
+
+	if unixSocket != nil {
+		s.ServerEditableConfig.UnixSocket = *unixSocket
+		shouldSaveConfig = true
+		if *unixSocket != "" {
+			fmt.Println("Server listens on the unix socket " + *unixSocket + " instead of the tcp port")
+		} else {
+			fmt.Println("Server listens on the tcp port")
+		}
+	}
+
+	if basePath != nil {
+		s.ServerEditableConfig.BasePath = tool.NormalizeBasePath(*basePath)
+		shouldSaveConfig = true
+		fmt.Println("Server base path updated")
+	}
+
+	if trustedProxies != nil {
+		s.ServerEditableConfig.TrustedProxies = *trustedProxies
+		shouldSaveConfig = true
+		if len(*trustedProxies) > 0 {
+			fmt.Println("Trusted proxies updated")
+			if _, err := tool.ParseNetworks(*trustedProxies); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		} else {
+			fmt.Println("X-Forwarded-* headers will be ignored")
+		}
+	}
+
 	if ssl != nil {
 		s.ServerEditableConfig.Ssl = *ssl
 		shouldSaveConfig = true
@@ -37,6 +117,262 @@ func (s *ServerApp) Config(
 		}
 	}
 
+	if certFile != nil {
+		s.ServerEditableConfig.CertFile = *certFile
+		shouldSaveConfig = true
+	}
+
+	if keyFile != nil {
+		s.ServerEditableConfig.KeyFile = *keyFile
+		shouldSaveConfig = true
+	}
+
+	if certFile != nil || keyFile != nil {
+		if s.IsSelfSigned() {
+			fmt.Println("SSL uses the self-signed certificate")
+		} else {
+			fmt.Println("SSL uses the certificate " + s.CertFile)
+			if _, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile); err != nil {
+				fmt.Printf("Warning: unable to load this certificate: %v\n", err)
+			}
+		}
+	}
+
+	if renewSelfSignedCertificate {
+		err := s.generateSelfSignedCertificate()
+		if err != nil {
+			logrus.Fatalf("Unable to generate cert and key files : %v\n", err)
+		}
+		fmt.Println("Self-signed certificate regenerated, clients should accept the new one")
+		if !s.IsSelfSigned() {
+			fmt.Println("Warning: the self-signed certificate is unused while the certificate " + s.CertFile + " is configured")
+		}
+	}
+
+	if httpRedirectPort != nil {
+		s.ServerEditableConfig.HttpRedirectPort = *httpRedirectPort
+		shouldSaveConfig = true
+		if *httpRedirectPort > 0 {
+			fmt.Println("Http requests on this port will be redirected to https")
+		} else {
+			fmt.Println("Http redirect disabled")
+		}
+	}
+
+	if trashRetentionDays > 0 {
+		s.ServerEditableConfig.TrashRetentionDays = trashRetentionDays
+		shouldSaveConfig = true
+		fmt.Println("Trash retention updated")
+	}
+
+	if auditRetentionDays > 0 {
+		s.ServerEditableConfig.AuditRetentionDays = auditRetentionDays
+		shouldSaveConfig = true
+		fmt.Println("Audit trail retention updated")
+	}
+
+	if s3Endpoint != "" {
+		s.ServerEditableConfig.S3Endpoint = strings.TrimSuffix(s3Endpoint, "/")
+		shouldSaveConfig = true
+		fmt.Println("S3 endpoint updated")
+	}
+
+	if s3Region != "" {
+		s.ServerEditableConfig.S3Region = s3Region
+		shouldSaveConfig = true
+		fmt.Println("S3 region updated")
+	}
+
+	if s3Bucket != "" {
+		s.ServerEditableConfig.S3Bucket = s3Bucket
+		shouldSaveConfig = true
+		fmt.Println("S3 bucket updated")
+	}
+
+	if s3Prefix != "" {
+		s.ServerEditableConfig.S3Prefix = strings.Trim(s3Prefix, "/")
+		shouldSaveConfig = true
+		fmt.Println("S3 prefix updated")
+	}
+
+	if s3AccessKey != "" {
+		s.ServerEditableConfig.S3AccessKey = s3AccessKey
+		shouldSaveConfig = true
+		fmt.Println("S3 access key updated")
+	}
+
+	if s3SecretKey != "" {
+		s.ServerEditableConfig.S3SecretKey = s3SecretKey
+		shouldSaveConfig = true
+		fmt.Println("S3 secret key updated")
+	}
+
+	if s3Endpoint != "" || s3Region != "" || s3Bucket != "" || s3Prefix != "" || s3AccessKey != "" || s3SecretKey != "" {
+		if s.Storage == config.StorageS3 {
+			fmt.Println("Warning: song files already stored in S3 are not copied to the new location")
+		} else {
+			fmt.Println("Use \"mifasolsrv storage migrate -to s3\" to move the song files into S3")
+		}
+	}
+
+	if s3PresignedRedirect != nil {
+		s.ServerEditableConfig.S3PresignedRedirect = *s3PresignedRedirect
+		shouldSaveConfig = true
+		if *s3PresignedRedirect {
+			fmt.Println("Song streams will be redirected to presigned S3 urls")
+		} else {
+			fmt.Println("Song streams read from S3 will be relayed")
+		}
+	}
+
+	if backupDir != nil {
+		s.ServerEditableConfig.BackupDir = *backupDir
+		shouldSaveConfig = true
+		if *backupDir != "" {
+			fmt.Println("Backup folder updated")
+		} else {
+			fmt.Println("Scheduled backup disabled")
+		}
+	}
+
+	if backupInterval > 0 {
+		s.ServerEditableConfig.BackupInterval = backupInterval
+		shouldSaveConfig = true
+		fmt.Println("Backup interval updated")
+	}
+
+	if inboxDir != nil {
+		s.ServerEditableConfig.InboxDir = *inboxDir
+		shouldSaveConfig = true
+		if *inboxDir != "" {
+			fmt.Println("Inbox folder updated")
+		} else {
+			fmt.Println("Inbox folder disabled")
+		}
+	}
+
+	if inboxInterval > 0 {
+		s.ServerEditableConfig.InboxInterval = inboxInterval
+		shouldSaveConfig = true
+		fmt.Println("Inbox interval updated")
+	}
+
+	if inboxKeepImported != nil {
+		s.ServerEditableConfig.InboxKeepImported = *inboxKeepImported
+		shouldSaveConfig = true
+		if *inboxKeepImported {
+			fmt.Println("Imported inbox files will be moved to the " + inboxImportedDirName + " subfolder")
+		} else {
+			fmt.Println("Imported inbox files will be deleted")
+		}
+	}
+
+	if mpdEnabled != nil {
+		s.ServerEditableConfig.MpdEnabled = *mpdEnabled
+		shouldSaveConfig = true
+		if *mpdEnabled {
+			fmt.Println("MPD server enabled")
+		} else {
+			fmt.Println("MPD server disabled")
+		}
+	}
+
+	if mpdPort > 0 {
+		s.ServerEditableConfig.MpdPort = mpdPort
+		shouldSaveConfig = true
+		fmt.Println("MPD server port updated")
+	}
+
+	if upnpEnabled != nil {
+		s.ServerEditableConfig.UpnpEnabled = *upnpEnabled
+		shouldSaveConfig = true
+		if *upnpEnabled {
+			fmt.Println("UPnP media server enabled")
+		} else {
+			fmt.Println("UPnP media server disabled")
+		}
+	}
+
+	if upnpPort > 0 {
+		s.ServerEditableConfig.UpnpPort = upnpPort
+		shouldSaveConfig = true
+		fmt.Println("UPnP media server port updated")
+	}
+
+	if upnpName != "" {
+		s.ServerEditableConfig.UpnpName = upnpName
+		shouldSaveConfig = true
+		fmt.Println("UPnP media server name updated")
+	}
+
+	if ffmpegPath != "" {
+		s.ServerEditableConfig.FfmpegPath = ffmpegPath
+		shouldSaveConfig = true
+		fmt.Println("ffmpeg path updated")
+	}
+
+	if metricsEnabled != nil {
+		s.ServerEditableConfig.MetricsEnabled = *metricsEnabled
+		shouldSaveConfig = true
+		if *metricsEnabled {
+			fmt.Println("Metrics endpoint enabled")
+		} else {
+			fmt.Println("Metrics endpoint disabled")
+		}
+	}
+
+	if metricsAddress != nil {
+		s.ServerEditableConfig.MetricsAddress = *metricsAddress
+		shouldSaveConfig = true
+		if *metricsAddress != "" {
+			fmt.Println("Metrics endpoint address updated")
+		} else {
+			fmt.Println("Metrics endpoint served on the server port")
+		}
+	}
+
+	if metricsToken != nil {
+		s.ServerEditableConfig.MetricsToken = *metricsToken
+		shouldSaveConfig = true
+		if *metricsToken != "" {
+			fmt.Println("Metrics token updated")
+		} else {
+			fmt.Println("Metrics readable without token")
+		}
+	}
+
+	if loginMaxFailures > 0 {
+		s.ServerEditableConfig.LoginMaxFailures = loginMaxFailures
+		shouldSaveConfig = true
+		fmt.Println("Login lockout threshold updated")
+	}
+
+	if loginLockoutMinutes > 0 {
+		s.ServerEditableConfig.LoginLockoutMinutes = loginLockoutMinutes
+		shouldSaveConfig = true
+		fmt.Println("Login lockout duration updated")
+	}
+
+	if apiRateLimit != 0 {
+		s.ServerEditableConfig.ApiRateLimit = apiRateLimit
+		shouldSaveConfig = true
+		if apiRateLimit > 0 {
+			fmt.Println("Api rate limit updated")
+		} else {
+			fmt.Println("Api rate limit disabled")
+		}
+	}
+
+	if streamLimit != 0 {
+		s.ServerEditableConfig.StreamLimit = streamLimit
+		shouldSaveConfig = true
+		if streamLimit > 0 {
+			fmt.Println("Concurrent song streams limit updated")
+		} else {
+			fmt.Println("Concurrent song streams limit disabled")
+		}
+	}
+
 	if shouldSaveConfig {
 		s.ServerConfig.Save()
 	}
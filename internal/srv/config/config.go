@@ -2,9 +2,11 @@ package config
 
 import (
 	"encoding/json"
+	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/sirupsen/logrus"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 )
 
 const configFilename = "config.json"
@@ -15,6 +17,8 @@ const configDataDirName = "data"
 const configSongsDirName = "songs"
 const configAlbumsDirName = "albums"
 const configAuthorsDirName = "authors"
+const configTrashDirName = "trash"
+const configUploadsDirName = "uploads"
 
 const configKeyFilename = "key.pem"
 const configCertFilename = "cert.pem"
@@ -22,6 +26,24 @@ const configCertFilename = "cert.pem"
 const DefaultPort = 6620
 const DefaultSsl = true
 const DefaultTimeout = 600
+const DefaultTrashRetentionDays = 30
+const DefaultAuditRetentionDays = 365
+const DefaultLoginMaxFailures = 10
+const DefaultLoginLockoutMinutes = 15
+const DefaultInboxInterval = 30
+const DefaultMpdPort = 6600
+const DefaultUpnpPort = 6610
+const DefaultUpnpName = "Mifasol"
+
+// Song files storage backends
+const StorageLocal = "local"
+const StorageS3 = "s3"
+const DefaultStorage = StorageLocal
+const DefaultS3Region = "us-east-1"
+
+// Api calls per minute and concurrent song streams per client, a negative limit meaning unlimited
+const DefaultApiRateLimit = 600
+const DefaultStreamLimit = 5
 
 type ServerConfig struct {
 	ConfigDir string
@@ -31,10 +53,45 @@ type ServerConfig struct {
 }
 
 type ServerEditableConfig struct {
-	Hostnames []string `json:"hostnames"`
-	Port      int64    `json:"port"`
-	Ssl       bool     `json:"ssl"`
-	Timeout   int64    `json:"timeout"`
+	Hostnames           []string `json:"hostnames"`
+	Port                int64    `json:"port"`
+	BindAddress         string   `json:"bindAddress"`
+	UnixSocket          string   `json:"unixSocket"`
+	BasePath            string   `json:"basePath"`
+	TrustedProxies      []string `json:"trustedProxies"`
+	Ssl                 bool     `json:"ssl"`
+	CertFile            string   `json:"certFile"`
+	KeyFile             string   `json:"keyFile"`
+	HttpRedirectPort    int64    `json:"httpRedirectPort"`
+	Timeout             int64    `json:"timeout"`
+	TrashRetentionDays  int64    `json:"trashRetentionDays"`
+	AuditRetentionDays  int64    `json:"auditRetentionDays"`
+	Storage             string   `json:"storage"`
+	S3Endpoint          string   `json:"s3Endpoint"`
+	S3Region            string   `json:"s3Region"`
+	S3Bucket            string   `json:"s3Bucket"`
+	S3Prefix            string   `json:"s3Prefix"`
+	S3AccessKey         string   `json:"s3AccessKey"`
+	S3SecretKey         string   `json:"s3SecretKey"`
+	S3PresignedRedirect bool     `json:"s3PresignedRedirect"`
+	BackupDir           string   `json:"backupDir"`
+	BackupInterval      int64    `json:"backupInterval"`
+	InboxDir            string   `json:"inboxDir"`
+	InboxInterval       int64    `json:"inboxInterval"`
+	InboxKeepImported   bool     `json:"inboxKeepImported"`
+	MpdEnabled          bool     `json:"mpdEnabled"`
+	MpdPort             int64    `json:"mpdPort"`
+	UpnpEnabled         bool     `json:"upnpEnabled"`
+	UpnpPort            int64    `json:"upnpPort"`
+	UpnpName            string   `json:"upnpName"`
+	FfmpegPath          string   `json:"ffmpegPath"`
+	MetricsEnabled      bool     `json:"metricsEnabled"`
+	MetricsAddress      string   `json:"metricsAddress"`
+	MetricsToken        string   `json:"metricsToken"`
+	LoginMaxFailures    int64    `json:"loginMaxFailures"`
+	LoginLockoutMinutes int64    `json:"loginLockoutMinutes"`
+	ApiRateLimit        int64    `json:"apiRateLimit"`
+	StreamLimit         int64    `json:"streamLimit"`
 }
 
 func (sc ServerConfig) GetCompleteConfigFilename() string {
@@ -53,6 +110,10 @@ func (sc ServerConfig) GetCompleteConfigDbDirName() string {
 	return filepath.Join(sc.ConfigDir, configDbDirName)
 }
 
+func (sc ServerConfig) GetCompleteConfigDataDirName() string {
+	return filepath.Join(sc.ConfigDir, configDataDirName)
+}
+
 func (sc ServerConfig) GetCompleteConfigSongsDirName() string {
 	return filepath.Join(sc.ConfigDir, configDataDirName, configSongsDirName)
 }
@@ -65,6 +126,14 @@ func (sc ServerConfig) GetCompleteConfigAuthorsDirName() string {
 	return filepath.Join(sc.ConfigDir, configDataDirName, configAuthorsDirName)
 }
 
+func (sc ServerConfig) GetCompleteConfigTrashDirName() string {
+	return filepath.Join(sc.ConfigDir, configDataDirName, configTrashDirName)
+}
+
+func (sc ServerConfig) GetCompleteConfigUploadsDirName() string {
+	return filepath.Join(sc.ConfigDir, configDataDirName, configUploadsDirName)
+}
+
 func (sc ServerConfig) GetCompleteConfigKeyFilename() string {
 	return filepath.Join(sc.ConfigDir, configKeyFilename)
 }
@@ -73,15 +142,48 @@ func (sc ServerConfig) GetCompleteConfigCertFilename() string {
 	return filepath.Join(sc.ConfigDir, configCertFilename)
 }
 
+// IsSelfSigned tells if the server uses its self-signed certificate, rather than an externally managed one
+func (sc ServerConfig) IsSelfSigned() bool {
+	return sc.CertFile == "" || sc.KeyFile == ""
+}
+
+// GetCertFilename returns the certificate chain file served with SSL
+func (sc ServerConfig) GetCertFilename() string {
+	if sc.IsSelfSigned() {
+		return sc.GetCompleteConfigCertFilename()
+	}
+	return sc.CertFile
+}
+
+// GetKeyFilename returns the private key file of the certificate served with SSL
+func (sc ServerConfig) GetKeyFilename() string {
+	if sc.IsSelfSigned() {
+		return sc.GetCompleteConfigKeyFilename()
+	}
+	return sc.KeyFile
+}
+
 func NewServerEditableConfig(draftServerEditableConfig *ServerEditableConfig) *ServerEditableConfig {
 	var serverEditableConfig ServerEditableConfig
 
 	if draftServerEditableConfig == nil {
 		serverEditableConfig = ServerEditableConfig{
-			Hostnames: []string{"localhost"},
-			Port:      DefaultPort,
-			Ssl:       DefaultSsl,
-			Timeout:   DefaultTimeout,
+			Hostnames:           []string{"localhost"},
+			Port:                DefaultPort,
+			Ssl:                 DefaultSsl,
+			Timeout:             DefaultTimeout,
+			TrashRetentionDays:  DefaultTrashRetentionDays,
+			AuditRetentionDays:  DefaultAuditRetentionDays,
+			Storage:             DefaultStorage,
+			S3Region:            DefaultS3Region,
+			LoginMaxFailures:    DefaultLoginMaxFailures,
+			LoginLockoutMinutes: DefaultLoginLockoutMinutes,
+			ApiRateLimit:        DefaultApiRateLimit,
+			StreamLimit:         DefaultStreamLimit,
+			InboxInterval:       DefaultInboxInterval,
+			MpdPort:             DefaultMpdPort,
+			UpnpPort:            DefaultUpnpPort,
+			UpnpName:            DefaultUpnpName,
 		}
 	} else {
 		serverEditableConfig = *draftServerEditableConfig
@@ -93,6 +195,63 @@ func NewServerEditableConfig(draftServerEditableConfig *ServerEditableConfig) *S
 			serverEditableConfig.Timeout = 3600
 		}
 
+		if serverEditableConfig.TrashRetentionDays <= 0 {
+			serverEditableConfig.TrashRetentionDays = DefaultTrashRetentionDays
+		}
+
+		if serverEditableConfig.AuditRetentionDays <= 0 {
+			serverEditableConfig.AuditRetentionDays = DefaultAuditRetentionDays
+		}
+
+		if serverEditableConfig.Storage != StorageLocal && serverEditableConfig.Storage != StorageS3 {
+			serverEditableConfig.Storage = DefaultStorage
+		}
+
+		if serverEditableConfig.S3Region == "" {
+			serverEditableConfig.S3Region = DefaultS3Region
+		}
+
+		serverEditableConfig.S3Endpoint = strings.TrimSuffix(serverEditableConfig.S3Endpoint, "/")
+		serverEditableConfig.S3Prefix = strings.Trim(serverEditableConfig.S3Prefix, "/")
+
+		if serverEditableConfig.BackupInterval < 0 {
+			serverEditableConfig.BackupInterval = 0
+		}
+
+		if serverEditableConfig.InboxInterval <= 0 {
+			serverEditableConfig.InboxInterval = DefaultInboxInterval
+		}
+
+		if serverEditableConfig.MpdPort <= 0 {
+			serverEditableConfig.MpdPort = DefaultMpdPort
+		}
+
+		if serverEditableConfig.UpnpPort <= 0 {
+			serverEditableConfig.UpnpPort = DefaultUpnpPort
+		}
+
+		if serverEditableConfig.UpnpName == "" {
+			serverEditableConfig.UpnpName = DefaultUpnpName
+		}
+
+		serverEditableConfig.BasePath = tool.NormalizeBasePath(serverEditableConfig.BasePath)
+
+		if serverEditableConfig.LoginMaxFailures <= 0 {
+			serverEditableConfig.LoginMaxFailures = DefaultLoginMaxFailures
+		}
+
+		if serverEditableConfig.LoginLockoutMinutes <= 0 {
+			serverEditableConfig.LoginLockoutMinutes = DefaultLoginLockoutMinutes
+		}
+
+		if serverEditableConfig.ApiRateLimit == 0 {
+			serverEditableConfig.ApiRateLimit = DefaultApiRateLimit
+		}
+
+		if serverEditableConfig.StreamLimit == 0 {
+			serverEditableConfig.StreamLimit = DefaultStreamLimit
+		}
+
 	}
 
 	return &serverEditableConfig
@@ -8,6 +8,7 @@ type AlbumEntity struct {
 	AlbumId    restApiV1.AlbumId `db:"album_id"`
 	CreationTs int64             `db:"creation_ts"`
 	UpdateTs   int64             `db:"update_ts"`
+	Revision   int64             `db:"revision"`
 	Name       string            `db:"name"`
 }
 
@@ -27,4 +28,5 @@ func (e *AlbumEntity) LoadMeta(s *restApiV1.AlbumMeta) {
 type DeletedAlbumEntity struct {
 	AlbumId  restApiV1.AlbumId `db:"album_id"`
 	DeleteTs int64             `db:"delete_ts"`
+	Revision int64             `db:"revision"`
 }
@@ -8,6 +8,7 @@ type ArtistEntity struct {
 	ArtistId   restApiV1.ArtistId `db:"artist_id" json:"artist_id"`
 	CreationTs int64              `db:"creation_ts" json:"creation_ts"`
 	UpdateTs   int64              `db:"update_ts" json:"update_ts"`
+	Revision   int64              `db:"revision" json:"revision"`
 	Name       string             `db:"name" json:"name"`
 }
 
@@ -32,4 +33,5 @@ type ArtistSongEntity struct {
 type DeletedArtistEntity struct {
 	ArtistId restApiV1.ArtistId `db:"artist_id"`
 	DeleteTs int64              `db:"delete_ts"`
+	Revision int64              `db:"revision"`
 }
@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"encoding/json"
+	"github.com/jypelle/mifasol/restApiV1"
+)
+
+// Audit
+
+type AuditEntryEntity struct {
+	AuditEntryId restApiV1.AuditEntryId    `db:"audit_entry_id"`
+	Ts           int64                     `db:"ts"`
+	UserId       restApiV1.UserId          `db:"user_id"`
+	UserName     string                    `db:"user_name"`
+	ClientIp     string                    `db:"client_ip"`
+	Action       restApiV1.AuditAction     `db:"action"`
+	EntityType   restApiV1.AuditEntityType `db:"entity_type"`
+	EntityId     string                    `db:"entity_id"`
+	EntityName   string                    `db:"entity_name"`
+	Changes      string                    `db:"changes"`
+}
+
+func (e *AuditEntryEntity) Fill(a *restApiV1.AuditEntry) {
+	a.Id = e.AuditEntryId
+	a.Ts = e.Ts
+	a.UserId = e.UserId
+	a.UserName = e.UserName
+	a.ClientIp = e.ClientIp
+	a.Action = e.Action
+	a.EntityType = e.EntityType
+	a.EntityId = e.EntityId
+	a.EntityName = e.EntityName
+	a.Changes = []restApiV1.AuditChange{}
+	json.Unmarshal([]byte(e.Changes), &a.Changes)
+}
+
+func (e *AuditEntryEntity) Load(a *restApiV1.AuditEntry) {
+	if a != nil {
+		e.UserId = a.UserId
+		e.UserName = a.UserName
+		e.ClientIp = a.ClientIp
+		e.Action = a.Action
+		e.EntityType = a.EntityType
+		e.EntityId = a.EntityId
+		e.EntityName = a.EntityName
+		changes := a.Changes
+		if changes == nil {
+			changes = []restApiV1.AuditChange{}
+		}
+		encodedChanges, _ := json.Marshal(changes)
+		e.Changes = string(encodedChanges)
+	}
+}
@@ -9,6 +9,7 @@ type FavoritePlaylistEntity struct {
 	UserId     restApiV1.UserId     `db:"user_id"`
 	PlaylistId restApiV1.PlaylistId `db:"playlist_id"`
 	UpdateTs   int64                `db:"update_ts"`
+	Revision   int64                `db:"revision"`
 }
 
 func (e *FavoritePlaylistEntity) Fill(s *restApiV1.FavoritePlaylist) {
@@ -27,6 +28,7 @@ type DeletedFavoritePlaylistEntity struct {
 	UserId     restApiV1.UserId     `db:"user_id"`
 	PlaylistId restApiV1.PlaylistId `db:"playlist_id"`
 	DeleteTs   int64                `db:"delete_ts"`
+	Revision   int64                `db:"revision"`
 }
 
 func NewDeletedFavoritePlaylistEntity(favoritePlaylistId restApiV1.FavoritePlaylistId) *DeletedFavoritePlaylistEntity {
@@ -9,6 +9,7 @@ type FavoriteSongEntity struct {
 	UserId   restApiV1.UserId `db:"user_id"`
 	SongId   restApiV1.SongId `db:"song_id"`
 	UpdateTs int64            `db:"update_ts"`
+	Revision int64            `db:"revision"`
 }
 
 func (e *FavoriteSongEntity) Fill(s *restApiV1.FavoriteSong) {
@@ -27,6 +28,7 @@ type DeletedFavoriteSongEntity struct {
 	UserId   restApiV1.UserId `db:"user_id"`
 	SongId   restApiV1.SongId `db:"song_id"`
 	DeleteTs int64            `db:"delete_ts"`
+	Revision int64            `db:"revision"`
 }
 
 func NewDeletedFavoriteSongEntity(favoriteSongId restApiV1.FavoriteSongId) *DeletedFavoriteSongEntity {
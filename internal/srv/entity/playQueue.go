@@ -0,0 +1,41 @@
+package entity
+
+import "github.com/jypelle/mifasol/restApiV1"
+
+// Play queue
+
+type PlayQueueEntity struct {
+	UserId        restApiV1.UserId      `db:"user_id"`
+	UpdateTs      int64                 `db:"update_ts"`
+	CurrentIndex  int64                 `db:"current_index"`
+	Position      float64               `db:"position"`
+	ShuffleFg     bool                  `db:"shuffle_fg"`
+	RepeatMode    restApiV1.RepeatMode  `db:"repeat_mode"`
+	SrcPlaylistId *restApiV1.PlaylistId `db:"src_playlist_id"`
+}
+
+func (e *PlayQueueEntity) Fill(s *restApiV1.PlayQueue) {
+	s.UserId = e.UserId
+	s.UpdateTs = e.UpdateTs
+	s.CurrentIndex = e.CurrentIndex
+	s.Position = e.Position
+	s.ShuffleFg = e.ShuffleFg
+	s.RepeatMode = e.RepeatMode
+	s.SrcPlaylistId = e.SrcPlaylistId
+}
+
+func (e *PlayQueueEntity) LoadMeta(s *restApiV1.PlayQueueMeta) {
+	if s != nil {
+		e.CurrentIndex = s.CurrentIndex
+		e.Position = s.Position
+		e.ShuffleFg = s.ShuffleFg
+		e.RepeatMode = s.RepeatMode
+		e.SrcPlaylistId = s.SrcPlaylistId
+	}
+}
+
+type PlayQueueSongEntity struct {
+	UserId   restApiV1.UserId `db:"user_id"`
+	Position int64            `db:"position"`
+	SongId   restApiV1.SongId `db:"song_id"`
+}
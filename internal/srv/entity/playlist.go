@@ -10,6 +10,7 @@ type PlaylistEntity struct {
 	PlaylistId      restApiV1.PlaylistId `db:"playlist_id"`
 	CreationTs      int64                `db:"creation_ts"`
 	UpdateTs        int64                `db:"update_ts"`
+	Revision        int64                `db:"revision"`
 	ContentUpdateTs int64                `db:"content_update_ts"`
 	Name            string               `db:"name"`
 }
@@ -57,4 +58,5 @@ func NewPlaylistOwnedUserEntity(userId restApiV1.UserId, playlistId restApiV1.Pl
 type DeletedPlaylistEntity struct {
 	PlaylistId restApiV1.PlaylistId `db:"playlist_id"`
 	DeleteTs   int64                `db:"delete_ts"`
+	Revision   int64                `db:"revision"`
 }
@@ -0,0 +1,31 @@
+package entity
+
+import "github.com/jypelle/mifasol/restApiV1"
+
+// Radio
+
+type RadioStationEntity struct {
+	PlaylistId restApiV1.PlaylistId         `db:"playlist_id"`
+	CreationTs int64                        `db:"creation_ts"`
+	UpdateTs   int64                        `db:"update_ts"`
+	Format     restApiV1.RadioStationFormat `db:"format"`
+	Bitrate    int64                        `db:"bitrate"`
+	ShuffleFg  bool                         `db:"shuffle_fg"`
+}
+
+func (e *RadioStationEntity) Fill(s *restApiV1.RadioStation) {
+	s.PlaylistId = e.PlaylistId
+	s.CreationTs = e.CreationTs
+	s.UpdateTs = e.UpdateTs
+	s.Format = e.Format
+	s.Bitrate = e.Bitrate
+	s.ShuffleFg = e.ShuffleFg
+}
+
+func (e *RadioStationEntity) LoadMeta(s *restApiV1.RadioStationMeta) {
+	if s != nil {
+		e.Format = s.Format
+		e.Bitrate = s.Bitrate
+		e.ShuffleFg = s.ShuffleFg
+	}
+}
@@ -0,0 +1,36 @@
+package entity
+
+import "github.com/jypelle/mifasol/restApiV1"
+
+// Share
+
+type ShareEntity struct {
+	ShareId      restApiV1.ShareId       `db:"share_id"`
+	CreationTs   int64                   `db:"creation_ts"`
+	OwnerUserId  restApiV1.UserId        `db:"owner_user_id"`
+	ItemType     restApiV1.ShareItemType `db:"item_type"`
+	ItemId       string                  `db:"item_id"`
+	ExpirationTs *int64                  `db:"expiration_ts"`
+	PasswordHash string                  `db:"password_hash"`
+	DownloadFg   bool                    `db:"download_fg"`
+}
+
+func (e *ShareEntity) Fill(s *restApiV1.Share) {
+	s.Id = e.ShareId
+	s.CreationTs = e.CreationTs
+	s.OwnerUserId = e.OwnerUserId
+	s.PasswordFg = e.PasswordHash != ""
+	s.ItemType = e.ItemType
+	s.ItemId = e.ItemId
+	s.ExpirationTs = e.ExpirationTs
+	s.DownloadFg = e.DownloadFg
+}
+
+func (e *ShareEntity) LoadMeta(s *restApiV1.ShareMeta) {
+	if s != nil {
+		e.ItemType = s.ItemType
+		e.ItemId = s.ItemId
+		e.ExpirationTs = s.ExpirationTs
+		e.DownloadFg = s.DownloadFg
+	}
+}
@@ -11,6 +11,7 @@ type SongEntity struct {
 	SongId          restApiV1.SongId       `db:"song_id"`
 	CreationTs      int64                  `db:"creation_ts"`
 	UpdateTs        int64                  `db:"update_ts"`
+	Revision        int64                  `db:"revision"`
 	Name            string                 `db:"name"`
 	Format          restApiV1.SongFormat   `db:"format"`
 	Size            int64                  `db:"size"`
@@ -69,4 +70,5 @@ func (e *SongEntity) LoadMeta(s *restApiV1.SongMeta) {
 type DeletedSongEntity struct {
 	SongId   restApiV1.SongId `db:"song_id"`
 	DeleteTs int64            `db:"delete_ts"`
+	Revision int64            `db:"revision"`
 }
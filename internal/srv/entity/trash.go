@@ -0,0 +1,22 @@
+package entity
+
+import "github.com/jypelle/mifasol/restApiV1"
+
+// Trash
+
+type TrashItemEntity struct {
+	TrashItemId restApiV1.TrashItemId   `db:"trash_item_id"`
+	DeleteTs    int64                   `db:"delete_ts"`
+	ItemType    restApiV1.TrashItemType `db:"item_type"`
+	ItemId      string                  `db:"item_id"`
+	Name        string                  `db:"name"`
+	Snapshot    string                  `db:"snapshot"`
+}
+
+func (e *TrashItemEntity) Fill(s *restApiV1.TrashItem) {
+	s.Id = e.TrashItemId
+	s.DeleteTs = e.DeleteTs
+	s.ItemType = e.ItemType
+	s.ItemId = e.ItemId
+	s.Name = e.Name
+}
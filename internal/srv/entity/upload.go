@@ -0,0 +1,32 @@
+package entity
+
+import "github.com/jypelle/mifasol/restApiV1"
+
+// Upload
+
+type UploadEntity struct {
+	UploadId    restApiV1.UploadId `db:"upload_id"`
+	CreationTs  int64              `db:"creation_ts"`
+	UpdateTs    int64              `db:"update_ts"`
+	UserId      restApiV1.UserId   `db:"user_id"`
+	Size        int64              `db:"size"`
+	LastAlbumId restApiV1.AlbumId  `db:"last_album_id"`
+	SongId      *restApiV1.SongId  `db:"song_id"`
+}
+
+func (e *UploadEntity) Fill(u *restApiV1.Upload) {
+	u.Id = e.UploadId
+	u.CreationTs = e.CreationTs
+	u.UpdateTs = e.UpdateTs
+	u.UserId = e.UserId
+	u.Size = e.Size
+	u.LastAlbumId = e.LastAlbumId
+	u.SongId = e.SongId
+}
+
+func (e *UploadEntity) LoadMeta(u *restApiV1.UploadMeta) {
+	if u != nil {
+		e.Size = u.Size
+		e.LastAlbumId = u.LastAlbumId
+	}
+}
@@ -8,6 +8,7 @@ type UserEntity struct {
 	UserId         restApiV1.UserId `db:"user_id"`
 	CreationTs     int64            `db:"creation_ts"`
 	UpdateTs       int64            `db:"update_ts"`
+	Revision       int64            `db:"revision"`
 	Name           string           `db:"name"`
 	HideExplicitFg bool             `db:"hide_explicit_fg"`
 	AdminFg        bool             `db:"admin_fg"`
@@ -35,4 +36,5 @@ func (e *UserEntity) LoadMeta(s *restApiV1.UserMeta) {
 type DeletedUserEntity struct {
 	UserId   restApiV1.UserId `db:"user_id"`
 	DeleteTs int64            `db:"delete_ts"`
+	Revision int64            `db:"revision"`
 }
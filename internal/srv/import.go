@@ -0,0 +1,225 @@
+package srv
+
+import (
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/sirupsen/logrus"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const importMinFileSize = 20000
+
+const inboxImportedDirName = ".imported"
+const inboxFailedDirName = ".failed"
+
+type importFile struct {
+	filename string
+	size     int64
+	modTs    int64
+}
+
+// Import adds every flac and mp3 files of dir to the (incoming) playlist.
+// Unless oneFolderPerAlbumDisabled is set, folder name changes are used to differentiate homonym albums.
+func (s *ServerApp) Import(dir string, oneFolderPerAlbumDisabled bool) error {
+	logrus.Printf("Scanning folder %s ...", dir)
+
+	files, err := findImportFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		logrus.Printf("No files to import")
+		return nil
+	}
+
+	logrus.Printf("Trying to import %d songs", len(files))
+
+	importedCount := 0
+	s.importFiles(files, oneFolderPerAlbumDisabled, func(file importFile, song *restApiV1.Song, err error) bool {
+		if err != nil {
+			logrus.Warnf("Unable to import file %s: %v", file.filename, err)
+		} else {
+			logrus.Debugf("File %s imported as song %s", file.filename, song.Id)
+			importedCount++
+		}
+		return true
+	})
+
+	logrus.Printf("Import done: %d/%d songs imported", importedCount, len(files))
+
+	return nil
+}
+
+// watchInbox periodically imports the song files dropped into the configured inbox folder
+func (s *ServerApp) watchInbox() {
+	defer s.backgroundTasks.Done()
+
+	ticker := time.NewTicker(time.Duration(s.InboxInterval) * time.Second)
+	defer ticker.Stop()
+
+	pendingFiles := make(map[string]importFile)
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stopCh:
+			return
+		}
+
+		pendingFiles = s.importInbox(pendingFiles)
+	}
+}
+
+// importInbox imports the inbox files unchanged since the previous scan and returns the files still being written
+func (s *ServerApp) importInbox(previousFiles map[string]importFile) map[string]importFile {
+	files, err := findImportFiles(s.InboxDir)
+	if err != nil {
+		logrus.Warnf("Unable to scan inbox folder: %v", err)
+		return previousFiles
+	}
+
+	var stableFiles []importFile
+	pendingFiles := make(map[string]importFile)
+	for _, file := range files {
+		if previousFile, ok := previousFiles[file.filename]; ok && previousFile == file {
+			stableFiles = append(stableFiles, file)
+		} else {
+			pendingFiles[file.filename] = file
+		}
+	}
+
+	s.importFiles(stableFiles, false, func(file importFile, song *restApiV1.Song, err error) bool {
+		destDirName := inboxImportedDirName
+		if err != nil {
+			logrus.Warnf("Unable to import inbox file %s: %v", file.filename, err)
+			destDirName = inboxFailedDirName
+		} else {
+			logrus.Printf("Inbox file %s imported", file.filename)
+		}
+
+		if err == nil && !s.InboxKeepImported {
+			err = os.Remove(file.filename)
+		} else {
+			err = moveInboxFile(s.InboxDir, file.filename, destDirName)
+		}
+		if err != nil {
+			logrus.Warnf("Unable to clean inbox file %s: %v", file.filename, err)
+		}
+		removeEmptyParentDirs(s.InboxDir, file.filename)
+
+		select {
+		case <-s.stopCh:
+			return false
+		default:
+			return true
+		}
+	})
+
+	return pendingFiles
+}
+
+// importFiles creates a song for each file and calls fn after each import until it returns false.
+// Unless oneFolderPerAlbumDisabled is set, the album of the previous song is reused if the song is in the same folder with the same album name.
+func (s *ServerApp) importFiles(files []importFile, oneFolderPerAlbumDisabled bool, fn func(file importFile, song *restApiV1.Song, err error) bool) {
+	var lastFolder string
+	var lastAlbumId restApiV1.AlbumId = restApiV1.UnknownAlbumId
+
+	for _, file := range files {
+		folder := filepath.Dir(file.filename)
+
+		// Reset last album id on new folder
+		if oneFolderPerAlbumDisabled || lastFolder != folder {
+			lastAlbumId = restApiV1.UnknownAlbumId
+		}
+		lastFolder = folder
+
+		song, err := s.importFile(file.filename, lastAlbumId)
+		if err == nil {
+			lastAlbumId = song.AlbumId
+		}
+
+		if !fn(file, song, err) {
+			return
+		}
+	}
+}
+
+func (s *ServerApp) importFile(filename string, lastAlbumId restApiV1.AlbumId) (*restApiV1.Song, error) {
+	reader, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return s.store.CreateSongFromRawContent(nil, reader, lastAlbumId)
+}
+
+// findImportFiles identifies every flac and mp3 files of dir, ignoring inbox imported and failed folders
+func findImportFiles(dir string) ([]importFile, error) {
+	var files []importFile
+
+	err := filepath.WalkDir(dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if filename != dir && (d.Name() == inboxImportedDirName || d.Name() == inboxFailedDirName) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		lowerCaseFilename := strings.ToLower(filename)
+		if !strings.HasSuffix(lowerCaseFilename, ".flac") && !strings.HasSuffix(lowerCaseFilename, ".mp3") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() <= importMinFileSize {
+			return nil
+		}
+
+		files = append(files, importFile{
+			filename: filename,
+			size:     info.Size(),
+			modTs:    info.ModTime().UnixNano(),
+		})
+
+		return nil
+	})
+
+	return files, err
+}
+
+// moveInboxFile moves filename into the destDirName subfolder of inboxDir, keeping its relative path
+func moveInboxFile(inboxDir string, filename string, destDirName string) error {
+	path, err := filepath.Rel(inboxDir, filename)
+	if err != nil {
+		return err
+	}
+
+	destFilename := filepath.Join(inboxDir, destDirName, path)
+	err = os.MkdirAll(filepath.Dir(destFilename), 0770)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(filename, destFilename)
+}
+
+// removeEmptyParentDirs removes the empty folders containing filename, up to rootDir (excluded)
+func removeEmptyParentDirs(rootDir string, filename string) {
+	rootDir = filepath.Clean(rootDir)
+	for dir := filepath.Dir(filename); dir != rootDir && strings.HasPrefix(dir, rootDir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package limiter
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/srv/metrics"
+	"github.com/sirupsen/logrus"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Number of failed logins without delay, before the backoff starts
+const loginFreeFailures = 3
+
+// Delay after the first delayed failed login, doubled by each following failure
+const loginBaseDelay = time.Second
+
+// Longest delay between two failed logins, before the lockout
+const loginMaxDelay = time.Minute
+
+// Delay suggested to a client refused a song stream
+const streamRetryAfter = 10 * time.Second
+
+// Interval between two removals of the forgotten clients
+const sweepInterval = time.Minute
+
+// Security events
+const (
+	EventLoginFailure = "login_failure"
+	EventLoginLockout = "login_lockout"
+	EventRateLimited  = "rate_limited"
+	EventStreamLimit  = "stream_limit"
+)
+
+var securityEvents = metrics.NewCounter(
+	"mifasol_security_events_total",
+	"Number of security events, per event.",
+	"event",
+)
+
+// Limiter protects the servers against password guessing and abusive clients:
+//
+// - failed logins are delayed with an exponential backoff, per IP and per account, then locked out
+//
+// - api calls are limited per client, with a token bucket
+//
+// - concurrent song streams are limited per client
+type Limiter struct {
+	loginMaxFailures int64
+	loginLockout     time.Duration
+	apiRateLimit     int64
+	streamLimit      int64
+
+	mutex     sync.Mutex
+	logins    map[string]*loginState
+	buckets   map[string]*bucket
+	streams   map[string]int64
+	lastSweep time.Time
+
+	log *logrus.Entry
+}
+
+type loginState struct {
+	failures     int64
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+type bucket struct {
+	tokens    float64
+	last      time.Time
+	limitedFg bool
+}
+
+func NewLimiter(serverConfig *config.ServerConfig) *Limiter {
+	return &Limiter{
+		loginMaxFailures: serverConfig.LoginMaxFailures,
+		loginLockout:     time.Duration(serverConfig.LoginLockoutMinutes) * time.Minute,
+		apiRateLimit:     serverConfig.ApiRateLimit,
+		streamLimit:      serverConfig.StreamLimit,
+		logins:           make(map[string]*loginState),
+		buckets:          make(map[string]*bucket),
+		streams:          make(map[string]int64),
+		lastSweep:        time.Now(),
+		log:              logrus.WithField("origin", "security"),
+	}
+}
+
+// loginKeys identifies the failed logins from ip and those on account, a username or a password protected share
+func loginKeys(ip string, account string) []string {
+	return []string{"ip:" + ip, "account:" + account}
+}
+
+// CheckLogin returns how long a login attempt from ip on account should wait, 0 if allowed
+func (l *Limiter) CheckLogin(ip string, account string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range loginKeys(ip, account) {
+		if state, ok := l.logins[key]; ok && state.blockedUntil.After(now) {
+			if wait := state.blockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	return retryAfter
+}
+
+// LoginFailed records a failed login from ip on account, origin being the server which received it
+func (l *Limiter) LoginFailed(origin string, ip string, account string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	securityEvents.Inc(EventLoginFailure)
+	l.log.Warningf("Failed login on %q from %s (%s)", account, ip, origin)
+
+	for _, key := range loginKeys(ip, account) {
+		state, ok := l.logins[key]
+		if !ok {
+			state = &loginState{}
+			l.logins[key] = state
+		}
+		state.failures++
+		state.lastFailure = now
+
+		switch {
+		case state.failures >= l.loginMaxFailures:
+			state.blockedUntil = now.Add(l.loginLockout)
+			// Log the lockout once, not each refused attempt
+			if state.failures == l.loginMaxFailures {
+				securityEvents.Inc(EventLoginLockout)
+				l.log.Warningf("Logins locked out for %s during %v after %d failures", key, l.loginLockout, state.failures)
+			}
+		case state.failures > loginFreeFailures:
+			delay := loginBaseDelay << uint(state.failures-loginFreeFailures-1)
+			if delay > loginMaxDelay || delay <= 0 {
+				delay = loginMaxDelay
+			}
+			state.blockedUntil = now.Add(delay)
+		}
+	}
+}
+
+// LoginSucceeded forgets the failed logins from ip on account
+func (l *Limiter) LoginSucceeded(ip string, account string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, key := range loginKeys(ip, account) {
+		delete(l.logins, key)
+	}
+}
+
+// AllowRequest consumes a request of the client identified by key and returns how long it should wait when refused
+func (l *Limiter) AllowRequest(origin string, key string) (bool, time.Duration) {
+	if l.apiRateLimit <= 0 {
+		return true, 0
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	// The bucket holds up to a minute of requests and refills continuously
+	rate := float64(l.apiRateLimit) / 60
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.apiRateLimit), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.apiRateLimit), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.limitedFg = false
+		return true, 0
+	}
+
+	if !b.limitedFg {
+		b.limitedFg = true
+		securityEvents.Inc(EventRateLimited)
+		l.log.Warningf("Requests of %s limited to %d per minute (%s)", key, l.apiRateLimit, origin)
+	}
+
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// AcquireStream reserves a song stream for the client identified by key.
+// When allowed, release should be called at the end of the stream, otherwise the client should retry after retryAfter.
+func (l *Limiter) AcquireStream(origin string, key string) (release func(), retryAfter time.Duration, ok bool) {
+	if l.streamLimit <= 0 {
+		return func() {}, 0, true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.streams[key] >= l.streamLimit {
+		securityEvents.Inc(EventStreamLimit)
+		l.log.Warningf("Stream refused to %s, already %d streams (%s)", key, l.streams[key], origin)
+		return nil, streamRetryAfter, false
+	}
+	l.streams[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+
+			l.streams[key]--
+			if l.streams[key] <= 0 {
+				delete(l.streams, key)
+			}
+		})
+	}, 0, true
+}
+
+// sweep forgets the clients without recent failed login and the full buckets
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, state := range l.logins {
+		if state.blockedUntil.Before(now) && now.Sub(state.lastFailure) > l.loginLockout {
+			delete(l.logins, key)
+		}
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last).Seconds()*float64(l.apiRateLimit)/60+b.tokens >= float64(l.apiRateLimit) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RetryAfter formats a delay for the Retry-After header, in whole seconds
+func RetryAfter(delay time.Duration) string {
+	seconds := int64(math.Ceil(delay.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
@@ -0,0 +1,63 @@
+package srv
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/metrics"
+	"github.com/jypelle/mifasol/internal/srv/store"
+	"github.com/sirupsen/logrus"
+)
+
+// registerMetrics adds the metrics collected when the metrics are read
+func (s *ServerApp) registerMetrics() {
+	metrics.NewGaugeFunc(
+		"mifasol_active_sessions",
+		"Number of access tokens delivered by the REST API since the server start.",
+		nil,
+		func(observe func(value float64, labelValues ...string)) {
+			observe(float64(s.restSrvV1.SessionCount()))
+		},
+	)
+
+	libraryStats := func() *store.LibraryStats {
+		stats, err := s.store.ReadLibraryStats(nil)
+		if err != nil {
+			logrus.Warningf("Unable to read library stats: %v", err)
+			return &store.LibraryStats{}
+		}
+		return stats
+	}
+
+	metrics.NewGaugeFunc(
+		"mifasol_library_songs",
+		"Number of songs in the library.",
+		nil,
+		func(observe func(value float64, labelValues ...string)) {
+			observe(float64(libraryStats().SongCount))
+		},
+	)
+	metrics.NewGaugeFunc(
+		"mifasol_library_albums",
+		"Number of albums in the library.",
+		nil,
+		func(observe func(value float64, labelValues ...string)) {
+			observe(float64(libraryStats().AlbumCount))
+		},
+	)
+	metrics.NewGaugeFunc(
+		"mifasol_library_artists",
+		"Number of artists in the library.",
+		nil,
+		func(observe func(value float64, labelValues ...string)) {
+			observe(float64(libraryStats().ArtistCount))
+		},
+	)
+	metrics.NewGaugeFunc(
+		"mifasol_library_bytes",
+		"Total size of the song files, per format.",
+		[]string{"format"},
+		func(observe func(value float64, labelValues ...string)) {
+			for _, formatStats := range libraryStats().Formats {
+				observe(float64(formatStats.Size), formatStats.Format.String())
+			}
+		},
+	)
+}
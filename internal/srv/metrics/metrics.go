@@ -0,0 +1,306 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metrics of the server, exposed with the Prometheus text format
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+var registry struct {
+	mutex   sync.Mutex
+	metrics []metric
+}
+
+func register(m metric) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.metrics = append(registry.metrics, m)
+}
+
+// WriteTo writes every metric with the Prometheus text format
+func WriteTo(w io.Writer) error {
+	registry.mutex.Lock()
+	metrics := registry.metrics
+	registry.mutex.Unlock()
+
+	bufWriter := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bufWriter)
+	}
+	return bufWriter.Flush()
+}
+
+// DefaultBuckets are the upper bounds, in seconds, of the duration histograms
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// vec holds a value for each combination of label values
+type vec struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+
+	mutex   sync.Mutex
+	samples map[string]*sample
+}
+
+func newVec(name string, help string, metricType string, labelNames []string) *vec {
+	return &vec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		samples:    make(map[string]*sample),
+	}
+}
+
+func (v *vec) add(value float64, labelValues []string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.sample(labelValues).value += value
+}
+
+func (v *vec) set(value float64, labelValues []string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.sample(labelValues).value = value
+}
+
+func (v *vec) sample(labelValues []string) *sample {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{labelValues: labelValues}
+		v.samples[key] = s
+	}
+	return s
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.mutex.Lock()
+	samples := make([]sample, 0, len(v.samples))
+	for _, s := range v.samples {
+		samples = append(samples, *s)
+	}
+	v.mutex.Unlock()
+
+	writeSamples(w, v.name, v.help, v.metricType, v.labelNames, samples)
+}
+
+// Counter is a value which only goes up, like a number of requests
+type Counter struct {
+	*vec
+}
+
+func NewCounter(name string, help string, labelNames ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, "counter", labelNames)}
+	register(c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+func (c *Counter) Add(value float64, labelValues ...string) {
+	c.add(value, labelValues)
+}
+
+// Gauge is a value which goes up and down, like a number of active streams
+type Gauge struct {
+	*vec
+}
+
+func NewGauge(name string, help string, labelNames ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labelNames)}
+	register(g)
+	return g
+}
+
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.add(value, labelValues)
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.set(value, labelValues)
+}
+
+// GaugeFunc is a gauge whose values are collected when the metrics are read
+type GaugeFunc struct {
+	name       string
+	help       string
+	labelNames []string
+	collect    func(observe func(value float64, labelValues ...string))
+}
+
+func NewGaugeFunc(name string, help string, labelNames []string, collect func(observe func(value float64, labelValues ...string))) *GaugeFunc {
+	g := &GaugeFunc{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		collect:    collect,
+	}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	var samples []sample
+	g.collect(func(value float64, labelValues ...string) {
+		samples = append(samples, sample{labelValues: labelValues, value: value})
+	})
+
+	writeSamples(w, g.name, g.help, "gauge", g.labelNames, samples)
+}
+
+// Histogram counts observations, like request durations, in buckets
+type Histogram struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+
+	mutex      sync.Mutex
+	histograms map[string]*histogramSample
+}
+
+type histogramSample struct {
+	labelValues  []string
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+func NewHistogram(name string, help string, buckets []float64, labelNames ...string) *Histogram {
+	h := &Histogram{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    buckets,
+		histograms: make(map[string]*histogramSample),
+	}
+	register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	hs, ok := h.histograms[key]
+	if !ok {
+		hs = &histogramSample{labelValues: labelValues, bucketCounts: make([]uint64, len(h.buckets))}
+		h.histograms[key] = hs
+	}
+
+	for ind, upperBound := range h.buckets {
+		if value <= upperBound {
+			hs.bucketCounts[ind]++
+		}
+	}
+	hs.count++
+	hs.sum += value
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	histograms := make([]histogramSample, 0, len(h.histograms))
+	for _, hs := range h.histograms {
+		histogram := *hs
+		histogram.bucketCounts = append([]uint64(nil), hs.bucketCounts...)
+		histograms = append(histograms, histogram)
+	}
+	h.mutex.Unlock()
+
+	sort.Slice(histograms, func(i, j int) bool {
+		return lessLabelValues(histograms[i].labelValues, histograms[j].labelValues)
+	})
+
+	writeHeader(w, h.name, h.help, "histogram")
+	bucketLabelNames := append(append([]string(nil), h.labelNames...), "le")
+	for _, hs := range histograms {
+		for ind, upperBound := range h.buckets {
+			writeSample(w, h.name+"_bucket", bucketLabelNames, append(append([]string(nil), hs.labelValues...), formatValue(upperBound)), float64(hs.bucketCounts[ind]))
+		}
+		writeSample(w, h.name+"_bucket", bucketLabelNames, append(append([]string(nil), hs.labelValues...), "+Inf"), float64(hs.count))
+		writeSample(w, h.name+"_sum", h.labelNames, hs.labelValues, hs.sum)
+		writeSample(w, h.name+"_count", h.labelNames, hs.labelValues, float64(hs.count))
+	}
+}
+
+func writeSamples(w *bufio.Writer, name string, help string, metricType string, labelNames []string, samples []sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return lessLabelValues(samples[i].labelValues, samples[j].labelValues)
+	})
+
+	writeHeader(w, name, help, metricType)
+	for _, s := range samples {
+		writeSample(w, name, labelNames, s.labelValues, s.value)
+	}
+}
+
+func writeHeader(w *bufio.Writer, name string, help string, metricType string) {
+	w.WriteString("# HELP " + name + " " + strings.NewReplacer("\\", `\\`, "\n", `\n`).Replace(help) + "\n")
+	w.WriteString("# TYPE " + name + " " + metricType + "\n")
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames []string, labelValues []string, value float64) {
+	w.WriteString(name)
+	if len(labelNames) > 0 {
+		w.WriteString("{")
+		for ind, labelName := range labelNames {
+			if ind > 0 {
+				w.WriteString(",")
+			}
+			labelValue := ""
+			if ind < len(labelValues) {
+				labelValue = labelValues[ind]
+			}
+			w.WriteString(labelName + `="` + strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`).Replace(labelValue) + `"`)
+		}
+		w.WriteString("}")
+	}
+	w.WriteString(" " + formatValue(value) + "\n")
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	// Keep counts and sizes readable
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func lessLabelValues(a []string, b []string) bool {
+	for ind := 0; ind < len(a) && ind < len(b); ind++ {
+		if a[ind] != b[ind] {
+			return a[ind] < b[ind]
+		}
+	}
+	return len(a) < len(b)
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"github.com/felixge/httpsnoop"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	HttpRequests = NewCounter(
+		"mifasol_http_requests_total",
+		"Number of REST API requests, per route and status.",
+		"route", "method", "status",
+	)
+	HttpRequestDuration = NewHistogram(
+		"mifasol_http_request_duration_seconds",
+		"Duration of the REST API requests, per route.",
+		DefaultBuckets,
+		"route", "method",
+	)
+	ActiveStreams = NewGauge(
+		"mifasol_active_streams",
+		"Number of song contents being streamed, per origin.",
+		"origin",
+	)
+	StreamedBytes = NewCounter(
+		"mifasol_streamed_bytes_total",
+		"Number of song content bytes streamed, per origin.",
+		"origin",
+	)
+	Imports = NewCounter(
+		"mifasol_imports_total",
+		"Number of song files imported, per result.",
+		"result",
+	)
+	StoreOperationDuration = NewHistogram(
+		"mifasol_store_operation_duration_seconds",
+		"Duration of the database transactions of the store operations.",
+		DefaultBuckets,
+		"operation",
+	)
+)
+
+// Import results
+const (
+	ImportSuccess = "success"
+	ImportFailure = "failure"
+)
+
+// Handler serves the metrics, only to the bearers of token when token is not void
+func Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			reqToken := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer"))
+			if subtle.ConstantTimeCompare([]byte(reqToken), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteTo(w)
+	})
+}
+
+// RequestHandler records the count and the duration of the requests handled by h, labelled by route
+func RequestHandler(h http.Handler, route func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := httpsnoop.CaptureMetrics(h, w, r)
+
+		routeName := route(r)
+		HttpRequests.Inc(routeName, r.Method, strconv.Itoa(m.Code))
+		HttpRequestDuration.Observe(m.Duration.Seconds(), routeName, r.Method)
+	})
+}
+
+// TrackStream counts w as an active stream of origin and the bytes written to it, until the returned func is called
+func TrackStream(w http.ResponseWriter, origin string) (http.ResponseWriter, func()) {
+	ActiveStreams.Add(1, origin)
+
+	trackedWriter := httpsnoop.Wrap(w, httpsnoop.Hooks{
+		Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+			return func(b []byte) (int, error) {
+				n, err := next(b)
+				StreamedBytes.Add(float64(n), origin)
+				return n, err
+			}
+		},
+		ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+			return func(src io.Reader) (int64, error) {
+				n, err := next(src)
+				StreamedBytes.Add(float64(n), origin)
+				return n, err
+			}
+		},
+	})
+
+	return trackedWriter, func() {
+		ActiveStreams.Add(-1, origin)
+	}
+}
+
+// ObserveStoreOperation records the duration of a store operation started at start
+func ObserveStoreOperation(start time.Time, operation string) {
+	StoreOperationDuration.Observe(time.Since(start).Seconds(), operation)
+}
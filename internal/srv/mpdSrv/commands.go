@@ -0,0 +1,211 @@
+package mpdSrv
+
+import (
+	"sort"
+	"strings"
+)
+
+type command struct {
+	handler       func(s *session, args []string) *ackError
+	minArgs       int
+	maxArgs       int // -1 for unlimited
+	authenticated bool
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		// Connection
+		"close":       {cmdClose, 0, 0, false},
+		"password":    {cmdPassword, 1, 1, false},
+		"ping":        {cmdNoop, 0, 0, false},
+		"binarylimit": {cmdNoop, 1, 1, false},
+		"tagtypes":    {cmdTagtypes, 0, -1, false},
+
+		// Reflection
+		"commands":    {cmdCommands, 0, 0, false},
+		"notcommands": {cmdNotcommands, 0, 0, false},
+		"urlhandlers": {cmdUrlhandlers, 0, 0, false},
+		"decoders":    {cmdNoop, 0, 0, false},
+
+		// Status
+		"status":      {cmdStatus, 0, 0, true},
+		"stats":       {cmdStats, 0, 0, true},
+		"currentsong": {cmdCurrentsong, 0, 0, true},
+		"clearerror":  {cmdNoop, 0, 0, true},
+		"idle":        {cmdIdle, 0, -1, true},
+		"noidle":      {cmdNoop, 0, 0, true},
+
+		// Playback options
+		"repeat":             {playbackOptionCommand(func(q *playQueue) *bool { return &q.repeat }), 1, 1, true},
+		"random":             {playbackOptionCommand(func(q *playQueue) *bool { return &q.random }), 1, 1, true},
+		"single":             {playbackOptionCommand(func(q *playQueue) *bool { return &q.single }), 1, 1, true},
+		"consume":            {playbackOptionCommand(func(q *playQueue) *bool { return &q.consume }), 1, 1, true},
+		"crossfade":          {cmdNoop, 1, 1, true},
+		"mixrampdb":          {cmdNoop, 1, 1, true},
+		"mixrampdelay":       {cmdNoop, 1, 1, true},
+		"setvol":             {cmdNoop, 1, 1, true},
+		"volume":             {cmdNoop, 1, 1, true},
+		"replay_gain_mode":   {cmdNoop, 1, 1, true},
+		"replay_gain_status": {cmdReplayGainStatus, 0, 0, true},
+
+		// Playback
+		"play":     {cmdPlay, 0, 1, true},
+		"playid":   {cmdPlayid, 0, 1, true},
+		"pause":    {cmdPause, 0, 1, true},
+		"stop":     {cmdStop, 0, 0, true},
+		"next":     {cmdNext, 0, 0, true},
+		"previous": {cmdPrevious, 0, 0, true},
+		"seek":     {cmdSeek, 2, 2, true},
+		"seekid":   {cmdSeekid, 2, 2, true},
+		"seekcur":  {cmdSeekcur, 1, 1, true},
+
+		// Queue
+		"add":            {cmdAdd, 1, 2, true},
+		"addid":          {cmdAddid, 1, 2, true},
+		"clear":          {cmdClear, 0, 0, true},
+		"delete":         {cmdDelete, 0, 1, true},
+		"deleteid":       {cmdDeleteid, 1, 1, true},
+		"move":           {cmdMove, 2, 2, true},
+		"moveid":         {cmdMoveid, 2, 2, true},
+		"shuffle":        {cmdShuffle, 0, 1, true},
+		"playlist":       {cmdPlaylist, 0, 0, true},
+		"playlistinfo":   {cmdPlaylistinfo, 0, 1, true},
+		"playlistid":     {cmdPlaylistid, 0, 1, true},
+		"playlistfind":   {cmdPlaylistfind, 2, -1, true},
+		"playlistsearch": {cmdPlaylistsearch, 2, -1, true},
+		"plchanges":      {cmdPlchanges, 1, 2, true},
+		"plchangesposid": {cmdPlchangesposid, 1, 2, true},
+
+		// Stored playlists
+		"listplaylists":    {cmdListplaylists, 0, 0, true},
+		"listplaylist":     {cmdListplaylist, 1, 1, true},
+		"listplaylistinfo": {cmdListplaylistinfo, 1, 1, true},
+		"load":             {cmdLoad, 1, 2, true},
+		"save":             {cmdSave, 1, 1, true},
+		"playlistadd":      {cmdPlaylistadd, 2, 2, true},
+		"playlistclear":    {cmdPlaylistclear, 1, 1, true},
+		"playlistdelete":   {cmdPlaylistdelete, 2, 2, true},
+		"playlistmove":     {cmdPlaylistmove, 3, 3, true},
+		"rename":           {cmdRename, 2, 2, true},
+		"rm":               {cmdRm, 1, 1, true},
+
+		// Music database
+		"count":       {cmdCount, 0, -1, true},
+		"find":        {cmdFind, 0, -1, true},
+		"findadd":     {cmdFindadd, 0, -1, true},
+		"search":      {cmdSearch, 0, -1, true},
+		"searchadd":   {cmdSearchadd, 0, -1, true},
+		"list":        {cmdList, 1, -1, true},
+		"listall":     {cmdListall, 0, 1, true},
+		"listallinfo": {cmdListallinfo, 0, 1, true},
+		"lsinfo":      {cmdLsinfo, 0, 1, true},
+		"update":      {cmdUpdate, 0, 1, true},
+		"rescan":      {cmdUpdate, 0, 1, true},
+
+		// Audio outputs
+		"outputs":       {cmdOutputs, 0, 0, true},
+		"enableoutput":  {cmdNoop, 1, 1, true},
+		"disableoutput": {cmdNoop, 1, 1, true},
+		"toggleoutput":  {cmdNoop, 1, 1, true},
+
+		// Client to client
+		"channels":     {cmdNoop, 0, 0, true},
+		"readmessages": {cmdNoop, 0, 0, true},
+		"subscribe":    {cmdNoop, 1, 1, true},
+		"unsubscribe":  {cmdNoop, 1, 1, true},
+	}
+}
+
+func cmdNoop(s *session, args []string) *ackError {
+	return nil
+}
+
+func cmdClose(s *session, args []string) *ackError {
+	return errClose
+}
+
+// cmdPassword authenticates the connection: the password should be "username:password"
+func cmdPassword(s *session, args []string) *ackError {
+	return s.login(args[0])
+}
+
+func cmdTagtypes(s *session, args []string) *ackError {
+	// Tag types selection is not supported: every tags are always sent
+	if len(args) > 0 {
+		return nil
+	}
+
+	var names []string
+	for _, name := range tagNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.writeKV("tagtype", name)
+	}
+	return nil
+}
+
+func cmdCommands(s *session, args []string) *ackError {
+	for _, name := range sortedCommandNames() {
+		if !commands[name].authenticated || s.user != nil {
+			s.writeKV("command", name)
+		}
+	}
+	return nil
+}
+
+func cmdNotcommands(s *session, args []string) *ackError {
+	for _, name := range sortedCommandNames() {
+		if commands[name].authenticated && s.user == nil {
+			s.writeKV("command", name)
+		}
+	}
+	return nil
+}
+
+func sortedCommandNames() []string {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func cmdUrlhandlers(s *session, args []string) *ackError {
+	s.writeKV("handler", "http://")
+	s.writeKV("handler", "https://")
+	return nil
+}
+
+func cmdIdle(s *session, args []string) *ackError {
+	var subsystems []string
+	for _, arg := range args {
+		subsystems = append(subsystems, strings.ToLower(arg))
+	}
+	return s.idle(subsystems)
+}
+
+func cmdReplayGainStatus(s *session, args []string) *ackError {
+	s.writeKV("replay_gain_mode", "off")
+	return nil
+}
+
+// cmdUpdate does nothing as the mifasol library is always up to date
+func cmdUpdate(s *session, args []string) *ackError {
+	s.writeKV("updating_db", "1")
+	return nil
+}
+
+// cmdOutputs describes the http output playing the queue, whose url is given as attribute
+func cmdOutputs(s *session, args []string) *ackError {
+	s.writeKV("outputid", "0")
+	s.writeKV("outputname", "Mifasol stream")
+	s.writeKV("plugin", "httpd")
+	s.writeKV("outputenabled", "1")
+	s.writeKV("attribute", "url="+s.server.baseUrl()+"/mpd/output?token="+s.streamToken)
+	return nil
+}
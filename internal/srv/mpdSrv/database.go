@@ -0,0 +1,512 @@
+package mpdSrv
+
+import (
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Songs without album are listed in this directory
+const unknownAlbumDirectory = "(Unknown album)"
+
+// Supported tags, indexed by their lower case name
+var tagNames = map[string]string{
+	"artist":      "Artist",
+	"albumartist": "AlbumArtist",
+	"album":       "Album",
+	"title":       "Title",
+	"track":       "Track",
+	"date":        "Date",
+}
+
+// library is a snapshot of the songs visible by the connected user.
+// The virtual directory tree contains one directory per album name.
+type library struct {
+	songs        map[restApiV1.SongId]*restApiV1.Song
+	orderedSongs []*restApiV1.Song
+	albums       map[restApiV1.AlbumId]*restApiV1.Album
+	artists      map[restApiV1.ArtistId]*restApiV1.Artist
+}
+
+func (s *session) readLibrary() *library {
+	lib := &library{
+		songs:   make(map[restApiV1.SongId]*restApiV1.Song),
+		albums:  make(map[restApiV1.AlbumId]*restApiV1.Album),
+		artists: make(map[restApiV1.ArtistId]*restApiV1.Artist),
+	}
+
+	albums, err := s.server.store.ReadAlbums(nil, &restApiV1.AlbumFilter{})
+	if err != nil {
+		s.server.log.Panicf("Unable to read albums: %v", err)
+	}
+	for ind := range albums {
+		lib.albums[albums[ind].Id] = &albums[ind]
+	}
+
+	artists, err := s.server.store.ReadArtists(nil, &restApiV1.ArtistFilter{})
+	if err != nil {
+		s.server.log.Panicf("Unable to read artists: %v", err)
+	}
+	for ind := range artists {
+		lib.artists[artists[ind].Id] = &artists[ind]
+	}
+
+	songs, err := s.server.store.ReadSongs(nil, &restApiV1.SongFilter{})
+	if err != nil {
+		s.server.log.Panicf("Unable to read songs: %v", err)
+	}
+	for ind := range songs {
+		song := &songs[ind]
+		if s.user.HideExplicitFg && song.ExplicitFg {
+			continue
+		}
+		lib.songs[song.Id] = song
+		lib.orderedSongs = append(lib.orderedSongs, song)
+	}
+
+	// Order songs by directory, track number and name
+	sort.SliceStable(lib.orderedSongs, func(i, j int) bool {
+		songI := lib.orderedSongs[i]
+		songJ := lib.orderedSongs[j]
+		directoryI := lib.directory(songI)
+		directoryJ := lib.directory(songJ)
+		if directoryI != directoryJ {
+			return directoryI < directoryJ
+		}
+		if songI.AlbumId != songJ.AlbumId {
+			return songI.AlbumId < songJ.AlbumId
+		}
+		trackI := int64(0)
+		if songI.TrackNumber != nil {
+			trackI = *songI.TrackNumber
+		}
+		trackJ := int64(0)
+		if songJ.TrackNumber != nil {
+			trackJ = *songJ.TrackNumber
+		}
+		if trackI != trackJ {
+			return trackI < trackJ
+		}
+		return songI.Name < songJ.Name
+	})
+
+	return lib
+}
+
+// directory returns the virtual directory of the song
+func (l *library) directory(song *restApiV1.Song) string {
+	if album, ok := l.albums[song.AlbumId]; ok && song.AlbumId != restApiV1.UnknownAlbumId {
+		return album.Name
+	}
+	return unknownAlbumDirectory
+}
+
+// directories returns the sorted virtual directories
+func (l *library) directories() []string {
+	var directories []string
+	for _, song := range l.orderedSongs {
+		directory := l.directory(song)
+		if len(directories) == 0 || directories[len(directories)-1] != directory {
+			directories = append(directories, directory)
+		}
+	}
+	return directories
+}
+
+// tagValues returns the values of a tag (lower case name) for the song
+func (l *library) tagValues(song *restApiV1.Song, tag string) []string {
+	switch tag {
+	case "artist":
+		return l.artistNames(song.ArtistIds)
+	case "albumartist":
+		if album, ok := l.albums[song.AlbumId]; ok && len(album.ArtistIds) > 0 {
+			return l.artistNames(album.ArtistIds)
+		}
+		return l.artistNames(song.ArtistIds)
+	case "album":
+		if album, ok := l.albums[song.AlbumId]; ok && song.AlbumId != restApiV1.UnknownAlbumId {
+			return []string{album.Name}
+		}
+	case "title":
+		return []string{song.Name}
+	case "track":
+		if song.TrackNumber != nil {
+			return []string{strconv.FormatInt(*song.TrackNumber, 10)}
+		}
+	case "date":
+		if song.PublicationYear != nil {
+			return []string{strconv.FormatInt(*song.PublicationYear, 10)}
+		}
+	case "file":
+		return []string{string(song.Id)}
+	case "any":
+		var values []string
+		for tagName := range tagNames {
+			values = append(values, l.tagValues(song, tagName)...)
+		}
+		return values
+	}
+	return nil
+}
+
+func (l *library) artistNames(artistIds []restApiV1.ArtistId) []string {
+	var names []string
+	for _, artistId := range artistIds {
+		if artist, ok := l.artists[artistId]; ok {
+			names = append(names, artist.Name)
+		}
+	}
+	return names
+}
+
+// songUri returns the stream url of a song, authenticated with the opaque stream token of the user
+func (s *session) songUri(songId restApiV1.SongId) string {
+	return s.server.baseUrl() + "/mpd/songs/" + url.PathEscape(string(songId)) + "?token=" + s.streamToken
+}
+
+// songIdFromUri extracts the song id from a stream url, the former Subsonic stream urls included, or a raw song id
+func songIdFromUri(lib *library, uri string) (restApiV1.SongId, bool) {
+	if u, err := url.Parse(uri); err == nil && (u.Query().Get("id") != "" || strings.Contains(u.Path, "/mpd/songs/")) {
+		songId := restApiV1.SongId(u.Query().Get("id"))
+		if songId == "" {
+			songId = restApiV1.SongId(path.Base(u.Path))
+		}
+		_, ok := lib.songs[songId]
+		return songId, ok
+	}
+	_, ok := lib.songs[restApiV1.SongId(uri)]
+	return restApiV1.SongId(uri), ok
+}
+
+// resolveUri returns the song ids of a song uri or of a directory
+func resolveUri(lib *library, uri string) ([]restApiV1.SongId, *ackError) {
+	if songId, ok := songIdFromUri(lib, uri); ok {
+		return []restApiV1.SongId{songId}, nil
+	}
+
+	var songIds []restApiV1.SongId
+	directory := strings.Trim(uri, "/")
+	for _, song := range lib.orderedSongs {
+		if directory == "" || lib.directory(song) == directory {
+			songIds = append(songIds, song.Id)
+		}
+	}
+	if len(songIds) == 0 {
+		return nil, newAckError(ackErrorNoExist, "No such directory")
+	}
+	return songIds, nil
+}
+
+func (s *session) writeSong(lib *library, songId restApiV1.SongId) {
+	s.writeKV("file", s.songUri(songId))
+
+	song, ok := lib.songs[songId]
+	if !ok {
+		return
+	}
+
+	s.writeKV("Last-Modified", time.Unix(0, song.UpdateTs).UTC().Format(time.RFC3339))
+	s.writeKV("Format", song.Format.String())
+	for _, tag := range []string{"artist", "albumartist", "album", "title", "track", "date"} {
+		for _, value := range lib.tagValues(song, tag) {
+			s.writeKV(tagNames[tag], value)
+		}
+	}
+}
+
+func cmdLsinfo(s *session, args []string) *ackError {
+	lib := s.readLibrary()
+
+	uri := ""
+	if len(args) > 0 {
+		uri = strings.Trim(args[0], "/")
+	}
+
+	// Root folder
+	if uri == "" {
+		for _, directory := range lib.directories() {
+			s.writeKV("directory", directory)
+		}
+		return s.writePlaylists()
+	}
+
+	// Song
+	if songId, ok := songIdFromUri(lib, uri); ok {
+		s.writeSong(lib, songId)
+		return nil
+	}
+
+	// Album directory
+	found := false
+	for _, song := range lib.orderedSongs {
+		if lib.directory(song) == uri {
+			s.writeSong(lib, song.Id)
+			found = true
+		}
+	}
+	if !found {
+		return newAckError(ackErrorNoExist, "No such directory")
+	}
+	return nil
+}
+
+func cmdListall(s *session, args []string) *ackError {
+	return s.listAll(args, false)
+}
+
+func cmdListallinfo(s *session, args []string) *ackError {
+	return s.listAll(args, true)
+}
+
+func (s *session) listAll(args []string, info bool) *ackError {
+	lib := s.readLibrary()
+
+	uri := ""
+	if len(args) > 0 {
+		uri = strings.Trim(args[0], "/")
+	}
+
+	found := false
+	lastDirectory := ""
+	for _, song := range lib.orderedSongs {
+		directory := lib.directory(song)
+		if uri != "" && directory != uri {
+			continue
+		}
+		found = true
+		if directory != lastDirectory {
+			s.writeKV("directory", directory)
+			lastDirectory = directory
+		}
+		if info {
+			s.writeSong(lib, song.Id)
+		} else {
+			s.writeKV("file", s.songUri(song.Id))
+		}
+	}
+	if uri != "" && !found {
+		return newAckError(ackErrorNoExist, "No such directory")
+	}
+	return nil
+}
+
+func cmdFind(s *session, args []string) *ackError {
+	return s.find(args, false, false)
+}
+
+func cmdSearch(s *session, args []string) *ackError {
+	return s.find(args, true, false)
+}
+
+func cmdFindadd(s *session, args []string) *ackError {
+	return s.find(args, false, true)
+}
+
+func cmdSearchadd(s *session, args []string) *ackError {
+	return s.find(args, true, true)
+}
+
+// find writes (or adds to the queue) the songs matching the filter, case insensitively for a search
+func (s *session) find(args []string, search bool, add bool) *ackError {
+	lib := s.readLibrary()
+
+	f, args, err := parseFilter(args, search)
+	if err != nil {
+		return err
+	}
+
+	var songs []*restApiV1.Song
+	for _, song := range lib.orderedSongs {
+		if f.match(lib, song) {
+			songs = append(songs, song)
+		}
+	}
+
+	// Sort and window options
+	for len(args) >= 2 {
+		switch strings.ToLower(args[0]) {
+		case "sort":
+			tag := strings.ToLower(strings.TrimPrefix(args[1], "-"))
+			descending := strings.HasPrefix(args[1], "-")
+			sort.SliceStable(songs, func(i, j int) bool {
+				valueI := strings.Join(lib.tagValues(songs[i], tag), ",")
+				valueJ := strings.Join(lib.tagValues(songs[j], tag), ",")
+				if descending {
+					return valueI > valueJ
+				}
+				return valueI < valueJ
+			})
+		case "window":
+			start, end, err := parseRange(args[1], len(songs))
+			if err != nil {
+				return err
+			}
+			songs = songs[start:end]
+		default:
+			return newAckError(ackErrorArg, "Unknown option: %s", args[0])
+		}
+		args = args[2:]
+	}
+	if len(args) > 0 {
+		return newAckError(ackErrorArg, "Incorrect arguments")
+	}
+
+	if add {
+		var songIds []restApiV1.SongId
+		for _, song := range songs {
+			songIds = append(songIds, song.Id)
+		}
+		s.queue.mutex.Lock()
+		defer s.queue.mutex.Unlock()
+		s.queue.add(songIds, -1)
+		return nil
+	}
+
+	for _, song := range songs {
+		s.writeSong(lib, song.Id)
+	}
+	return nil
+}
+
+func cmdCount(s *session, args []string) *ackError {
+	lib := s.readLibrary()
+
+	f, args, err := parseFilter(args, false)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return newAckError(ackErrorArg, "Incorrect arguments")
+	}
+
+	count := 0
+	for _, song := range lib.orderedSongs {
+		if f.match(lib, song) {
+			count++
+		}
+	}
+
+	s.writeKV("songs", strconv.Itoa(count))
+	s.writeKV("playtime", "0")
+	return nil
+}
+
+// cmdList writes the distinct values of a tag for the songs matching the filter, optionally grouped by other tags
+func cmdList(s *session, args []string) *ackError {
+	lib := s.readLibrary()
+
+	tag := strings.ToLower(args[0])
+	if _, ok := tagNames[tag]; !ok && tag != "file" {
+		return newAckError(ackErrorArg, "Unknown tag type: %s", args[0])
+	}
+	args = args[1:]
+
+	// Legacy syntax: list album <artist>
+	if tag == "album" && len(args) == 1 && !strings.HasPrefix(args[0], "(") {
+		args = []string{"artist", args[0]}
+	}
+
+	// Extract group options
+	var groupTags []string
+	for ind := 0; ind < len(args); ind++ {
+		if strings.ToLower(args[ind]) == "group" && ind+1 < len(args) {
+			groupTag := strings.ToLower(args[ind+1])
+			if _, ok := tagNames[groupTag]; !ok {
+				return newAckError(ackErrorArg, "Unknown tag type: %s", args[ind+1])
+			}
+			groupTags = append(groupTags, groupTag)
+			args = append(args[:ind:ind], args[ind+2:]...)
+			ind--
+		}
+	}
+
+	f, args, err := parseFilter(args, false)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return newAckError(ackErrorArg, "Incorrect arguments")
+	}
+
+	// Collect values
+	type entry struct {
+		groups []string
+		value  string
+	}
+	var entries []entry
+	known := make(map[string]bool)
+	for _, song := range lib.orderedSongs {
+		if !f.match(lib, song) {
+			continue
+		}
+
+		var groups []string
+		for _, groupTag := range groupTags {
+			groups = append(groups, strings.Join(lib.tagValues(song, groupTag), ", "))
+		}
+
+		var values []string
+		if tag == "file" {
+			values = []string{s.songUri(song.Id)}
+		} else {
+			values = lib.tagValues(song, tag)
+		}
+		for _, value := range values {
+			key := strings.Join(groups, "\x00") + "\x00" + value
+			if !known[key] {
+				known[key] = true
+				entries = append(entries, entry{groups: groups, value: value})
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		for ind := range groupTags {
+			if entries[i].groups[ind] != entries[j].groups[ind] {
+				return entries[i].groups[ind] < entries[j].groups[ind]
+			}
+		}
+		return entries[i].value < entries[j].value
+	})
+
+	tagName := "file"
+	if tag != "file" {
+		tagName = tagNames[tag]
+	}
+	var lastGroups []string
+	for _, e := range entries {
+		for ind, groupTag := range groupTags {
+			if lastGroups == nil || lastGroups[ind] != e.groups[ind] {
+				s.writeKV(tagNames[groupTag], e.groups[ind])
+			}
+		}
+		lastGroups = e.groups
+		s.writeKV(tagName, e.value)
+	}
+
+	return nil
+}
+
+func cmdStats(s *session, args []string) *ackError {
+	lib := s.readLibrary()
+
+	var dbUpdateTs int64
+	for _, song := range lib.orderedSongs {
+		if song.UpdateTs > dbUpdateTs {
+			dbUpdateTs = song.UpdateTs
+		}
+	}
+
+	s.writeKV("artists", strconv.Itoa(len(lib.artists)))
+	s.writeKV("albums", strconv.Itoa(len(lib.albums)))
+	s.writeKV("songs", strconv.Itoa(len(lib.songs)))
+	s.writeKV("uptime", strconv.FormatInt(int64(time.Since(s.server.startTime).Seconds()), 10))
+	s.writeKV("playtime", "0")
+	s.writeKV("db_playtime", "0")
+	s.writeKV("db_update", strconv.FormatInt(time.Unix(0, dbUpdateTs).Unix(), 10))
+	return nil
+}
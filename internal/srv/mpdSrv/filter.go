@@ -0,0 +1,317 @@
+package mpdSrv
+
+import (
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type filter interface {
+	match(lib *library, song *restApiV1.Song) bool
+}
+
+// tagFilter compares the values of a tag
+type tagFilter struct {
+	tag      string
+	operator string
+	value    string
+	regexp   *regexp.Regexp
+	foldCase bool
+}
+
+func (f *tagFilter) match(lib *library, song *restApiV1.Song) bool {
+	values := lib.tagValues(song, f.tag)
+
+	switch f.operator {
+	case "!=":
+		return !f.matchValues(values, "==")
+	case "!~":
+		return !f.matchValues(values, "=~")
+	default:
+		return f.matchValues(values, f.operator)
+	}
+}
+
+func (f *tagFilter) matchValues(values []string, operator string) bool {
+	if len(values) == 0 {
+		values = []string{""}
+	}
+
+	for _, value := range values {
+		expected := f.value
+		if f.foldCase {
+			value = strings.ToLower(value)
+			expected = strings.ToLower(expected)
+		}
+		switch operator {
+		case "==":
+			if value == expected {
+				return true
+			}
+		case "contains":
+			if strings.Contains(value, expected) {
+				return true
+			}
+		case "starts_with":
+			if strings.HasPrefix(value, expected) {
+				return true
+			}
+		case "=~":
+			if f.regexp.MatchString(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// baseFilter selects the songs of a directory
+type baseFilter struct {
+	directory string
+}
+
+func (f *baseFilter) match(lib *library, song *restApiV1.Song) bool {
+	return f.directory == "" || lib.directory(song) == f.directory
+}
+
+type notFilter struct {
+	filter filter
+}
+
+func (f *notFilter) match(lib *library, song *restApiV1.Song) bool {
+	return !f.filter.match(lib, song)
+}
+
+type andFilter struct {
+	filters []filter
+}
+
+func (f *andFilter) match(lib *library, song *restApiV1.Song) bool {
+	for _, subFilter := range f.filters {
+		if !subFilter.match(lib, song) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFilter reads a filter expression or legacy "tag value" pairs, and returns the remaining arguments (sort, window, ...)
+func parseFilter(args []string, foldCase bool) (filter, []string, *ackError) {
+	f := &andFilter{}
+
+	// Filter expression
+	if len(args) > 0 && strings.HasPrefix(args[0], "(") {
+		p := &expressionParser{expression: args[0], foldCase: foldCase}
+		expression, err := p.parse()
+		if err != nil {
+			return nil, nil, err
+		}
+		f.filters = append(f.filters, expression)
+		return f, args[1:], nil
+	}
+
+	// Legacy tag value pairs
+	operator := "=="
+	if foldCase {
+		operator = "contains"
+	}
+	for len(args) > 0 {
+		tag := strings.ToLower(args[0])
+		if tag == "sort" || tag == "window" || tag == "group" {
+			break
+		}
+		if len(args) < 2 {
+			return nil, nil, newAckError(ackErrorArg, "Incorrect number of filter arguments")
+		}
+
+		switch tag {
+		case "base":
+			f.filters = append(f.filters, &baseFilter{directory: strings.Trim(args[1], "/")})
+		default:
+			if _, ok := tagNames[tag]; !ok && tag != "any" && tag != "file" {
+				return nil, nil, newAckError(ackErrorArg, "Unknown filter type: %s", args[0])
+			}
+			f.filters = append(f.filters, newTagFilter(tag, operator, args[1], foldCase))
+		}
+		args = args[2:]
+	}
+
+	return f, args, nil
+}
+
+func newTagFilter(tag string, operator string, value string, foldCase bool) *tagFilter {
+	// Files are matched through their song id
+	if tag == "file" {
+		if u, err := url.Parse(value); err == nil && u.Query().Get("id") != "" {
+			value = u.Query().Get("id")
+		}
+	}
+	return &tagFilter{tag: tag, operator: operator, value: value, foldCase: foldCase}
+}
+
+// expressionParser reads filter expressions like ((artist == 'x') AND (album contains 'y'))
+type expressionParser struct {
+	expression string
+	position   int
+	foldCase   bool
+}
+
+func (p *expressionParser) parse() (filter, *ackError) {
+	f, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.position < len(p.expression) {
+		return nil, newAckError(ackErrorArg, "Unparsed garbage after expression")
+	}
+	return f, nil
+}
+
+func (p *expressionParser) parseExpression() (filter, *ackError) {
+	p.skipSpaces()
+	if !p.consume("(") {
+		return nil, newAckError(ackErrorArg, "Expected '('")
+	}
+	p.skipSpaces()
+
+	// Negation
+	if p.consume("!") {
+		subFilter, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if !p.consume(")") {
+			return nil, newAckError(ackErrorArg, "Expected ')'")
+		}
+		return &notFilter{filter: subFilter}, nil
+	}
+
+	// Conjunction
+	if p.peek() == '(' {
+		f := &andFilter{}
+		for {
+			subFilter, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			f.filters = append(f.filters, subFilter)
+
+			p.skipSpaces()
+			if p.consume(")") {
+				return f, nil
+			}
+			if !p.consume("AND") {
+				return nil, newAckError(ackErrorArg, "Expected 'AND' or ')'")
+			}
+		}
+	}
+
+	// Tag comparison
+	tag := strings.ToLower(p.readWord())
+	p.skipSpaces()
+
+	var f filter
+	if tag == "base" {
+		value, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		f = &baseFilter{directory: strings.Trim(value, "/")}
+	} else {
+		if _, ok := tagNames[tag]; !ok && tag != "any" && tag != "file" {
+			return nil, newAckError(ackErrorArg, "Unknown filter type: %s", tag)
+		}
+
+		operator := p.readWord()
+		foldCase := p.foldCase
+		switch operator {
+		case "eq_cs":
+			operator, foldCase = "==", false
+		case "eq_ci":
+			operator, foldCase = "==", true
+		case "==", "!=", "contains", "starts_with", "=~", "!~":
+		default:
+			return nil, newAckError(ackErrorArg, "Unknown filter operator: %s", operator)
+		}
+		p.skipSpaces()
+
+		value, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		tf := newTagFilter(tag, operator, value, foldCase)
+		if operator == "=~" || operator == "!~" {
+			if foldCase {
+				value = "(?i)" + value
+			}
+			tf.regexp, _ = regexp.Compile(value)
+			if tf.regexp == nil {
+				return nil, newAckError(ackErrorArg, "Invalid regular expression")
+			}
+		}
+		f = tf
+	}
+
+	p.skipSpaces()
+	if !p.consume(")") {
+		return nil, newAckError(ackErrorArg, "Expected ')'")
+	}
+	return f, nil
+}
+
+func (p *expressionParser) skipSpaces() {
+	for p.position < len(p.expression) && p.expression[p.position] == ' ' {
+		p.position++
+	}
+}
+
+func (p *expressionParser) peek() byte {
+	if p.position < len(p.expression) {
+		return p.expression[p.position]
+	}
+	return 0
+}
+
+func (p *expressionParser) consume(token string) bool {
+	if strings.HasPrefix(p.expression[p.position:], token) {
+		p.position += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *expressionParser) readWord() string {
+	start := p.position
+	for p.position < len(p.expression) && p.expression[p.position] != ' ' && p.expression[p.position] != ')' {
+		p.position++
+	}
+	return p.expression[start:p.position]
+}
+
+// readString reads a single or double quoted string with backslash escapes
+func (p *expressionParser) readString() (string, *ackError) {
+	quote := p.peek()
+	if quote != '\'' && quote != '"' {
+		return "", newAckError(ackErrorArg, "Quoted string expected")
+	}
+	p.position++
+
+	var value strings.Builder
+	for p.position < len(p.expression) {
+		c := p.expression[p.position]
+		p.position++
+		if c == '\\' && p.position < len(p.expression) {
+			value.WriteByte(p.expression[p.position])
+			p.position++
+		} else if c == quote {
+			return value.String(), nil
+		} else {
+			value.WriteByte(c)
+		}
+	}
+	return "", newAckError(ackErrorArg, "Closing quote not found")
+}
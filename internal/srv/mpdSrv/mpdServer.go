@@ -0,0 +1,159 @@
+package mpdSrv
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/srv/radioSrv"
+	"github.com/jypelle/mifasol/internal/srv/store"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/sirupsen/logrus"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const protocolVersion = "0.21.0"
+
+// MpdServer exposes the library through the MPD protocol (https://mpd.readthedocs.io/en/latest/protocol.html).
+// Songs are handed out as stream URLs, so the clients play them by themselves,
+// and the play queue of each user is also broadcast by an http output.
+type MpdServer struct {
+	store        *store.Store
+	limiter      *limiter.Limiter
+	radioSrv     *radioSrv.RadioServer
+	serverConfig *config.ServerConfig
+	subRouter    *mux.Router
+	listener     net.Listener
+	startTime    time.Time
+
+	queuesMutex sync.Mutex
+	queues      map[restApiV1.UserId]*playQueue
+
+	sessionsMutex sync.Mutex
+	sessions      map[*session]struct{}
+	sessionsGroup sync.WaitGroup
+	stopped       bool
+
+	// Opaque tokens of the song and output stream urls
+	streamTokensMutex sync.Mutex
+	streamTokens      map[string]streamAccess
+
+	log *logrus.Entry
+}
+
+func NewMpdServer(store *store.Store, limiter *limiter.Limiter, radioServer *radioSrv.RadioServer, serverConfig *config.ServerConfig, subRouter *mux.Router) *MpdServer {
+	mpdServer := &MpdServer{
+		store:        store,
+		limiter:      limiter,
+		radioSrv:     radioServer,
+		serverConfig: serverConfig,
+		subRouter:    subRouter,
+		queues:       make(map[restApiV1.UserId]*playQueue),
+		sessions:     make(map[*session]struct{}),
+		streamTokens: make(map[string]streamAccess),
+		log:          logrus.WithField("origin", "mpd"),
+	}
+
+	mpdServer.subRouter.HandleFunc("/songs/{songId}", mpdServer.readSongContent).Methods("GET", "HEAD")
+	mpdServer.subRouter.HandleFunc("/output", mpdServer.listenOutput).Methods("GET")
+
+	return mpdServer
+}
+
+// Start listens to the configured MPD port and serves the incoming connections
+func (s *MpdServer) Start() error {
+	listener, err := net.Listen("tcp", net.JoinHostPort(s.serverConfig.BindAddress, strconv.FormatInt(s.serverConfig.MpdPort, 10)))
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.startTime = time.Now()
+
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Temporary() {
+					s.log.Warnf("Unable to accept connection: %v", err)
+					continue
+				}
+				return
+			}
+
+			sess := newSession(s, conn)
+
+			s.sessionsMutex.Lock()
+			if s.stopped {
+				s.sessionsMutex.Unlock()
+				conn.Close()
+				return
+			}
+			s.sessions[sess] = struct{}{}
+			s.sessionsGroup.Add(1)
+			s.sessionsMutex.Unlock()
+
+			go func() {
+				defer s.sessionsGroup.Done()
+				sess.serve()
+
+				s.sessionsMutex.Lock()
+				delete(s.sessions, sess)
+				s.sessionsMutex.Unlock()
+			}()
+		}
+	}()
+
+	return nil
+}
+
+// Stop closes the listener and every opened connection
+func (s *MpdServer) Stop() {
+	s.listener.Close()
+
+	s.sessionsMutex.Lock()
+	s.stopped = true
+	for sess := range s.sessions {
+		sess.conn.Close()
+	}
+	s.sessionsMutex.Unlock()
+
+	s.sessionsGroup.Wait()
+}
+
+// notifyAll wakes up every idle connection
+func (s *MpdServer) notifyAll(subsystems ...string) {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	for sess := range s.sessions {
+		sess.notify(subsystems...)
+	}
+}
+
+// queue returns the play queue shared by every connection of the user
+func (s *MpdServer) queue(userId restApiV1.UserId) *playQueue {
+	s.queuesMutex.Lock()
+	defer s.queuesMutex.Unlock()
+
+	queue, ok := s.queues[userId]
+	if !ok {
+		queue = newPlayQueue()
+		s.queues[userId] = queue
+	}
+	return queue
+}
+
+// baseUrl returns the url of the mifasol http server
+func (s *MpdServer) baseUrl() string {
+	scheme := "http"
+	if s.serverConfig.Ssl {
+		scheme = "https"
+	}
+	hostname := "localhost"
+	if len(s.serverConfig.Hostnames) > 0 {
+		hostname = s.serverConfig.Hostnames[0]
+	}
+	return scheme + "://" + net.JoinHostPort(hostname, strconv.FormatInt(s.serverConfig.Port, 10)) + s.serverConfig.BasePath
+}
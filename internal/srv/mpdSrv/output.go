@@ -0,0 +1,93 @@
+package mpdSrv
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/radioSrv"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"time"
+)
+
+// Format of the http outputs: the songs in other formats are transcoded when ffmpeg is available, skipped otherwise
+const (
+	outputFormat  = restApiV1.RadioStationFormatMp3
+	outputBitrate = 192
+)
+
+// output broadcasts the play queue of a user to its http listeners, through a radio stream.
+// While it's listened, the output plays the queue: it follows the player state and moves to the next song at the end of the current one.
+type output struct {
+	server        *MpdServer
+	queue         *playQueue
+	stream        *radioSrv.Stream
+	listenerCount int
+}
+
+// NextSong returns the current song of the queue and its elapsed time, while playing
+func (o *output) NextSong() (*restApiV1.Song, time.Duration) {
+	q := o.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	// Songs which can't be broadcast are skipped
+	for skipped := 0; q.state == playerStatePlay && q.current >= 0; skipped++ {
+		if skipped >= len(q.entries) {
+			q.stop()
+			break
+		}
+
+		songId := q.entries[q.current].songId
+		song, err := o.server.store.ReadSong(nil, songId)
+		if err == nil && o.server.radioSrv.CanBroadcast(song, outputFormat) {
+			return song, q.elapsedTime()
+		}
+		if err != nil && err != storeerror.ErrNotFound {
+			o.server.log.Warnf("Unable to read song %s: %v", songId, err)
+		}
+		q.playNext()
+	}
+
+	return nil, 0
+}
+
+// SongEnded moves the queue after the song broadcast until its end, unless the player has changed meanwhile
+func (o *output) SongEnded(song *restApiV1.Song) {
+	q := o.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.state != playerStatePlay || q.current < 0 || q.entries[q.current].songId != song.Id {
+		return
+	}
+	q.advance()
+}
+
+// openOutput returns the http output of the queue, started for its first listener
+func (q *playQueue) openOutput(server *MpdServer, user *restApiV1.User) *output {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.output == nil {
+		o := &output{server: server, queue: q}
+		o.stream = server.radioSrv.NewStream("mpd output of "+user.Name, o, outputFormat, outputBitrate)
+		q.output = o
+	}
+	q.output.listenerCount++
+
+	return q.output
+}
+
+// closeOutput stops the http output after its last listener, to let the clients play the queue by themselves
+func (q *playQueue) closeOutput(o *output) {
+	q.mutex.Lock()
+	o.listenerCount--
+	last := o.listenerCount == 0
+	if last && q.output == o {
+		q.output = nil
+	}
+	q.mutex.Unlock()
+
+	// The stream asks its songs to the queue
+	if last {
+		o.stream.Stop()
+	}
+}
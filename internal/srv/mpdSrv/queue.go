@@ -0,0 +1,804 @@
+package mpdSrv
+
+import (
+	"fmt"
+	"github.com/jypelle/mifasol/restApiV1"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	playerStateStop  = "stop"
+	playerStatePlay  = "play"
+	playerStatePause = "pause"
+)
+
+type queueEntry struct {
+	id      int
+	songId  restApiV1.SongId
+	version int64
+}
+
+// playQueue is the queue and the player state of a user, shared by all its connections.
+// The clients play the stream urls and drive the player state, unless the http output of the queue is listened.
+type playQueue struct {
+	mutex sync.Mutex
+
+	entries []queueEntry
+	nextId  int
+	version int64
+
+	state     string
+	current   int
+	elapsed   time.Duration
+	playStart time.Time
+
+	repeat  bool
+	random  bool
+	single  bool
+	consume bool
+
+	sessions map[*session]struct{}
+
+	// Http output, while listened
+	output *output
+}
+
+func newPlayQueue() *playQueue {
+	return &playQueue{
+		version:  1,
+		state:    playerStateStop,
+		current:  -1,
+		sessions: make(map[*session]struct{}),
+	}
+}
+
+func (q *playQueue) subscribe(s *session) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.sessions[s] = struct{}{}
+}
+
+func (q *playQueue) unsubscribe(s *session) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	delete(q.sessions, s)
+}
+
+// notify wakes up the idle connections of the user, and restarts the output on player changes (mutex should be held)
+func (q *playQueue) notify(subsystems ...string) {
+	for s := range q.sessions {
+		s.notify(subsystems...)
+	}
+	if q.output != nil {
+		for _, subsystem := range subsystems {
+			if subsystem == "player" {
+				q.output.stream.Restart()
+				break
+			}
+		}
+	}
+}
+
+// touch marks the entries from position as changed in a new queue version (mutex should be held)
+func (q *playQueue) touch(position int) {
+	q.version++
+	for ind := position; ind < len(q.entries); ind++ {
+		q.entries[ind].version = q.version
+	}
+	q.notify("playlist")
+}
+
+// add inserts songs at position (or at the end when position is negative) and returns their ids (mutex should be held)
+func (q *playQueue) add(songIds []restApiV1.SongId, position int) []int {
+	if position < 0 || position > len(q.entries) {
+		position = len(q.entries)
+	}
+
+	var ids []int
+	var newEntries []queueEntry
+	for _, songId := range songIds {
+		q.nextId++
+		ids = append(ids, q.nextId)
+		newEntries = append(newEntries, queueEntry{id: q.nextId, songId: songId})
+	}
+
+	entries := append([]queueEntry{}, q.entries[:position]...)
+	entries = append(entries, newEntries...)
+	q.entries = append(entries, q.entries[position:]...)
+
+	if q.current >= position {
+		q.current += len(newEntries)
+	}
+
+	q.touch(position)
+	return ids
+}
+
+// remove deletes the entries between start (included) and end (excluded) (mutex should be held)
+func (q *playQueue) remove(start int, end int) {
+	q.entries = append(q.entries[:start], q.entries[end:]...)
+
+	if q.current >= end {
+		q.current -= end - start
+	} else if q.current >= start {
+		// The current song has been removed: continue with the next one
+		if start < len(q.entries) {
+			q.setCurrent(start)
+		} else {
+			q.current = -1
+			q.stop()
+		}
+	}
+
+	q.touch(start)
+}
+
+// move moves the entries between start and end to position (mutex should be held)
+func (q *playQueue) move(start int, end int, position int) {
+	var currentId int
+	if q.current >= 0 {
+		currentId = q.entries[q.current].id
+	}
+
+	moved := append([]queueEntry{}, q.entries[start:end]...)
+	remaining := append(append([]queueEntry{}, q.entries[:start]...), q.entries[end:]...)
+	entries := append([]queueEntry{}, remaining[:position]...)
+	entries = append(entries, moved...)
+	q.entries = append(entries, remaining[position:]...)
+
+	if q.current >= 0 {
+		q.current = q.position(currentId)
+	}
+
+	touched := start
+	if position < touched {
+		touched = position
+	}
+	q.touch(touched)
+}
+
+// position returns the position of the entry id, or -1 (mutex should be held)
+func (q *playQueue) position(id int) int {
+	for ind, entry := range q.entries {
+		if entry.id == id {
+			return ind
+		}
+	}
+	return -1
+}
+
+func (q *playQueue) elapsedTime() time.Duration {
+	if q.state == playerStatePlay {
+		return q.elapsed + time.Since(q.playStart)
+	}
+	return q.elapsed
+}
+
+func (q *playQueue) setCurrent(position int) {
+	q.current = position
+	q.elapsed = 0
+	q.playStart = time.Now()
+	q.notify("player")
+}
+
+func (q *playQueue) play(position int) {
+	q.setCurrent(position)
+	q.state = playerStatePlay
+}
+
+func (q *playQueue) stop() {
+	q.state = playerStateStop
+	q.elapsed = 0
+	q.notify("player")
+}
+
+func (q *playQueue) pause(pause bool) {
+	if q.state == playerStateStop {
+		return
+	}
+	if pause && q.state == playerStatePlay {
+		q.elapsed = q.elapsedTime()
+		q.state = playerStatePause
+	} else if !pause && q.state == playerStatePause {
+		q.playStart = time.Now()
+		q.state = playerStatePlay
+	}
+	q.notify("player")
+}
+
+// playNext plays the song following the current one, which is removed in consume mode (mutex should be held)
+func (q *playQueue) playNext() {
+	next := q.nextPosition()
+	if q.consume && q.current >= 0 {
+		q.remove(q.current, q.current+1)
+		if next > q.current {
+			next--
+		}
+	}
+	if next < 0 {
+		q.stop()
+		return
+	}
+	q.play(next)
+}
+
+// advance follows the end of the current song, played by the output (mutex should be held)
+func (q *playQueue) advance() {
+	if q.single {
+		if q.repeat {
+			q.play(q.current)
+			return
+		}
+		q.stop()
+		return
+	}
+	q.playNext()
+}
+
+// nextPosition returns the position of the song following the current one, or -1
+func (q *playQueue) nextPosition() int {
+	if q.current < 0 || len(q.entries) == 0 {
+		return -1
+	}
+	if q.random {
+		return rand.Intn(len(q.entries))
+	}
+	if q.current+1 < len(q.entries) {
+		return q.current + 1
+	}
+	if q.repeat {
+		return 0
+	}
+	return -1
+}
+
+func (q *playQueue) seek(elapsed time.Duration) {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	q.elapsed = elapsed
+	q.playStart = time.Now()
+	q.notify("player")
+}
+
+// parseRange reads a position ("3") or a range ("3:5", "3:") within a list of length elements
+func parseRange(arg string, length int) (int, int, *ackError) {
+	parts := strings.SplitN(arg, ":", 2)
+
+	start, err := strconv.Atoi(parts[0])
+	if err != nil || start < 0 {
+		return 0, 0, newAckError(ackErrorArg, "Number expected: %s", arg)
+	}
+	end := start + 1
+	if len(parts) == 2 {
+		if parts[1] == "" {
+			end = length
+		} else {
+			end, err = strconv.Atoi(parts[1])
+			if err != nil || end < start {
+				return 0, 0, newAckError(ackErrorArg, "Bad range: %s", arg)
+			}
+		}
+	}
+	if start > length || end > length || (len(parts) == 1 && start >= length) {
+		return 0, 0, newAckError(ackErrorArg, "Bad song index")
+	}
+	return start, end, nil
+}
+
+func parseSeconds(arg string) (time.Duration, *ackError) {
+	seconds, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return 0, newAckError(ackErrorArg, "Number expected: %s", arg)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func parseBool(arg string) (bool, *ackError) {
+	switch arg {
+	case "0":
+		return false, nil
+	case "1":
+		return true, nil
+	}
+	return false, newAckError(ackErrorArg, "Boolean (0/1) expected: %s", arg)
+}
+
+func (s *session) writeQueueEntry(lib *library, position int, entry queueEntry) {
+	s.writeSong(lib, entry.songId)
+	s.writeKV("Pos", strconv.Itoa(position))
+	s.writeKV("Id", strconv.Itoa(entry.id))
+}
+
+func cmdAdd(s *session, args []string) *ackError {
+	_, err := s.addUri(args)
+	return err
+}
+
+func cmdAddid(s *session, args []string) *ackError {
+	ids, err := s.addUri(args)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		s.writeKV("Id", strconv.Itoa(ids[0]))
+	}
+	return nil
+}
+
+func (s *session) addUri(args []string) ([]int, *ackError) {
+	lib := s.readLibrary()
+
+	songIds, err := resolveUri(lib, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	position := -1
+	if len(args) > 1 {
+		var convErr error
+		position, convErr = strconv.Atoi(args[1])
+		if convErr != nil || position < 0 || position > len(s.queue.entries) {
+			return nil, newAckError(ackErrorArg, "Bad song index")
+		}
+	}
+
+	return s.queue.add(songIds, position), nil
+}
+
+func cmdClear(s *session, args []string) *ackError {
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	s.queue.remove(0, len(s.queue.entries))
+	return nil
+}
+
+func cmdDelete(s *session, args []string) *ackError {
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	start, end := 0, len(s.queue.entries)
+	if len(args) > 0 {
+		var err *ackError
+		start, end, err = parseRange(args[0], len(s.queue.entries))
+		if err != nil {
+			return err
+		}
+	}
+	s.queue.remove(start, end)
+	return nil
+}
+
+func cmdDeleteid(s *session, args []string) *ackError {
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	position, err := s.queue.idPosition(args[0])
+	if err != nil {
+		return err
+	}
+	s.queue.remove(position, position+1)
+	return nil
+}
+
+func cmdMove(s *session, args []string) *ackError {
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	start, end, err := parseRange(args[0], len(s.queue.entries))
+	if err != nil {
+		return err
+	}
+	position, convErr := strconv.Atoi(args[1])
+	if convErr != nil || position < 0 || position > len(s.queue.entries)-(end-start) {
+		return newAckError(ackErrorArg, "Bad song index")
+	}
+	s.queue.move(start, end, position)
+	return nil
+}
+
+func cmdMoveid(s *session, args []string) *ackError {
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	start, err := s.queue.idPosition(args[0])
+	if err != nil {
+		return err
+	}
+	position, convErr := strconv.Atoi(args[1])
+	if convErr != nil || position < 0 || position >= len(s.queue.entries) {
+		return newAckError(ackErrorArg, "Bad song index")
+	}
+	s.queue.move(start, start+1, position)
+	return nil
+}
+
+func cmdShuffle(s *session, args []string) *ackError {
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	start, end := 0, len(s.queue.entries)
+	if len(args) > 0 {
+		var err *ackError
+		start, end, err = parseRange(args[0], len(s.queue.entries))
+		if err != nil {
+			return err
+		}
+	}
+
+	var currentId int
+	if s.queue.current >= 0 {
+		currentId = s.queue.entries[s.queue.current].id
+	}
+	rand.Shuffle(end-start, func(i, j int) {
+		s.queue.entries[start+i], s.queue.entries[start+j] = s.queue.entries[start+j], s.queue.entries[start+i]
+	})
+	if s.queue.current >= 0 {
+		s.queue.current = s.queue.position(currentId)
+	}
+	s.queue.touch(start)
+	return nil
+}
+
+// idPosition returns the position of the entry whose id is given as argument (mutex should be held)
+func (q *playQueue) idPosition(arg string) (int, *ackError) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, newAckError(ackErrorArg, "Number expected: %s", arg)
+	}
+	position := q.position(id)
+	if position < 0 {
+		return 0, newAckError(ackErrorNoExist, "No such song")
+	}
+	return position, nil
+}
+
+func cmdPlaylistinfo(s *session, args []string) *ackError {
+	lib := s.readLibrary()
+
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	start, end := 0, len(s.queue.entries)
+	if len(args) > 0 {
+		var err *ackError
+		start, end, err = parseRange(args[0], len(s.queue.entries))
+		if err != nil {
+			return err
+		}
+	}
+	for position := start; position < end; position++ {
+		s.writeQueueEntry(lib, position, s.queue.entries[position])
+	}
+	return nil
+}
+
+func cmdPlaylistid(s *session, args []string) *ackError {
+	lib := s.readLibrary()
+
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	if len(args) > 0 {
+		position, err := s.queue.idPosition(args[0])
+		if err != nil {
+			return err
+		}
+		s.writeQueueEntry(lib, position, s.queue.entries[position])
+		return nil
+	}
+	for position, entry := range s.queue.entries {
+		s.writeQueueEntry(lib, position, entry)
+	}
+	return nil
+}
+
+// cmdPlaylist is the deprecated version of playlistinfo
+func cmdPlaylist(s *session, args []string) *ackError {
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	for position, entry := range s.queue.entries {
+		s.writeKV(strconv.Itoa(position)+":file", s.songUri(entry.songId))
+	}
+	return nil
+}
+
+func cmdPlaylistfind(s *session, args []string) *ackError {
+	return s.findInQueue(args, false)
+}
+
+func cmdPlaylistsearch(s *session, args []string) *ackError {
+	return s.findInQueue(args, true)
+}
+
+func (s *session) findInQueue(args []string, search bool) *ackError {
+	lib := s.readLibrary()
+
+	f, args, err := parseFilter(args, search)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return newAckError(ackErrorArg, "Incorrect arguments")
+	}
+
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	for position, entry := range s.queue.entries {
+		if song, ok := lib.songs[entry.songId]; ok && f.match(lib, song) {
+			s.writeQueueEntry(lib, position, entry)
+		}
+	}
+	return nil
+}
+
+func cmdPlchanges(s *session, args []string) *ackError {
+	return s.queueChanges(args, true)
+}
+
+func cmdPlchangesposid(s *session, args []string) *ackError {
+	return s.queueChanges(args, false)
+}
+
+func (s *session) queueChanges(args []string, info bool) *ackError {
+	version, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return newAckError(ackErrorArg, "Number expected: %s", args[0])
+	}
+
+	lib := s.readLibrary()
+
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	for position, entry := range s.queue.entries {
+		if entry.version <= version {
+			continue
+		}
+		if info {
+			s.writeQueueEntry(lib, position, entry)
+		} else {
+			s.writeKV("cpos", strconv.Itoa(position))
+			s.writeKV("Id", strconv.Itoa(entry.id))
+		}
+	}
+	return nil
+}
+
+func cmdStatus(s *session, args []string) *ackError {
+	q := s.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	s.writeKV("volume", "-1")
+	s.writeKV("repeat", boolString(q.repeat))
+	s.writeKV("random", boolString(q.random))
+	s.writeKV("single", boolString(q.single))
+	s.writeKV("consume", boolString(q.consume))
+	s.writeKV("playlist", strconv.FormatInt(q.version, 10))
+	s.writeKV("playlistlength", strconv.Itoa(len(q.entries)))
+	s.writeKV("mixrampdb", "0.000000")
+	s.writeKV("state", q.state)
+	if q.current >= 0 {
+		s.writeKV("song", strconv.Itoa(q.current))
+		s.writeKV("songid", strconv.Itoa(q.entries[q.current].id))
+		if q.state != playerStateStop {
+			elapsed := q.elapsedTime()
+			s.writeKV("time", strconv.Itoa(int(elapsed.Seconds()))+":0")
+			s.writeKV("elapsed", fmt.Sprintf("%.3f", elapsed.Seconds()))
+		}
+		if next := q.nextPosition(); next >= 0 && !q.random {
+			s.writeKV("nextsong", strconv.Itoa(next))
+			s.writeKV("nextsongid", strconv.Itoa(q.entries[next].id))
+		}
+	}
+	return nil
+}
+
+func cmdCurrentsong(s *session, args []string) *ackError {
+	lib := s.readLibrary()
+
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	if s.queue.current >= 0 {
+		s.writeQueueEntry(lib, s.queue.current, s.queue.entries[s.queue.current])
+	}
+	return nil
+}
+
+func cmdPlay(s *session, args []string) *ackError {
+	q := s.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(args) == 0 {
+		if q.state == playerStatePause {
+			q.pause(false)
+			return nil
+		}
+		if len(q.entries) == 0 {
+			return nil
+		}
+		position := q.current
+		if position < 0 {
+			position = 0
+		}
+		q.play(position)
+		return nil
+	}
+
+	position, err := strconv.Atoi(args[0])
+	if err != nil || position < 0 || position >= len(q.entries) {
+		return newAckError(ackErrorArg, "Bad song index")
+	}
+	q.play(position)
+	return nil
+}
+
+func cmdPlayid(s *session, args []string) *ackError {
+	if len(args) == 0 {
+		return cmdPlay(s, nil)
+	}
+
+	q := s.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	position, err := q.idPosition(args[0])
+	if err != nil {
+		return err
+	}
+	q.play(position)
+	return nil
+}
+
+func cmdPause(s *session, args []string) *ackError {
+	q := s.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	pause := q.state == playerStatePlay
+	if len(args) > 0 {
+		var err *ackError
+		pause, err = parseBool(args[0])
+		if err != nil {
+			return err
+		}
+	}
+	q.pause(pause)
+	return nil
+}
+
+func cmdStop(s *session, args []string) *ackError {
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	s.queue.stop()
+	return nil
+}
+
+func cmdNext(s *session, args []string) *ackError {
+	q := s.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.state == playerStateStop {
+		return nil
+	}
+	q.playNext()
+	return nil
+}
+
+func cmdPrevious(s *session, args []string) *ackError {
+	q := s.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.state == playerStateStop || q.current < 0 {
+		return nil
+	}
+	previous := q.current - 1
+	if previous < 0 {
+		if !q.repeat {
+			previous = 0
+		} else {
+			previous = len(q.entries) - 1
+		}
+	}
+	q.play(previous)
+	return nil
+}
+
+func cmdSeek(s *session, args []string) *ackError {
+	q := s.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	position, err := strconv.Atoi(args[0])
+	if err != nil || position < 0 || position >= len(q.entries) {
+		return newAckError(ackErrorArg, "Bad song index")
+	}
+	return q.seekTo(position, args[1])
+}
+
+func cmdSeekid(s *session, args []string) *ackError {
+	q := s.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	position, err := q.idPosition(args[0])
+	if err != nil {
+		return err
+	}
+	return q.seekTo(position, args[1])
+}
+
+func (q *playQueue) seekTo(position int, time string) *ackError {
+	elapsed, err := parseSeconds(time)
+	if err != nil {
+		return err
+	}
+	if position != q.current || q.state == playerStateStop {
+		q.play(position)
+	}
+	q.seek(elapsed)
+	return nil
+}
+
+func cmdSeekcur(s *session, args []string) *ackError {
+	q := s.queue
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.state == playerStateStop {
+		return newAckError(ackErrorArg, "Not playing")
+	}
+	elapsed, err := parseSeconds(args[0])
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(args[0], "+") || strings.HasPrefix(args[0], "-") {
+		elapsed += q.elapsedTime()
+	}
+	q.seek(elapsed)
+	return nil
+}
+
+// playbackOptionCommand returns the handler of a boolean playback option (repeat, random, ...)
+func playbackOptionCommand(option func(q *playQueue) *bool) func(s *session, args []string) *ackError {
+	return func(s *session, args []string) *ackError {
+		value, err := parseBool(args[0])
+		if err != nil {
+			// "single" and "consume" also accept "oneshot"
+			if args[0] != "oneshot" {
+				return err
+			}
+			value = true
+		}
+
+		s.queue.mutex.Lock()
+		defer s.queue.mutex.Unlock()
+
+		*option(s.queue) = value
+		s.queue.notify("options")
+		return nil
+	}
+}
+
+func boolString(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,316 @@
+package mpdSrv
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Protocol error codes
+const (
+	ackErrorNotList    = 1
+	ackErrorArg        = 2
+	ackErrorPassword   = 3
+	ackErrorPermission = 4
+	ackErrorUnknown    = 5
+	ackErrorNoExist    = 50
+	ackErrorExist      = 56
+)
+
+type ackError struct {
+	code    int
+	message string
+}
+
+func (e *ackError) Error() string {
+	return e.message
+}
+
+func newAckError(code int, format string, a ...interface{}) *ackError {
+	return &ackError{code: code, message: fmt.Sprintf(format, a...)}
+}
+
+var errClose = &ackError{message: "close"}
+
+// session is a client connection
+type session struct {
+	server *MpdServer
+	conn   net.Conn
+	writer *bufio.Writer
+	lines  chan string
+
+	user        *restApiV1.User
+	queue       *playQueue
+	streamToken string
+
+	// Pending idle events
+	eventsMutex sync.Mutex
+	events      map[string]bool
+	eventCh     chan struct{}
+}
+
+func newSession(server *MpdServer, conn net.Conn) *session {
+	return &session{
+		server:  server,
+		conn:    conn,
+		writer:  bufio.NewWriter(conn),
+		lines:   make(chan string),
+		events:  make(map[string]bool),
+		eventCh: make(chan struct{}, 1),
+	}
+}
+
+func (s *session) serve() {
+	defer s.logout()
+
+	s.server.log.Debugf("New connection from %s", s.conn.RemoteAddr())
+
+	go func() {
+		defer close(s.lines)
+		scanner := bufio.NewScanner(s.conn)
+		for scanner.Scan() {
+			s.lines <- scanner.Text()
+		}
+	}()
+	// Unblock the reading goroutine when leaving
+	defer func() {
+		s.conn.Close()
+		for range s.lines {
+		}
+	}()
+
+	s.writer.WriteString("OK MPD " + protocolVersion + "\n")
+	s.writer.Flush()
+
+	var commandList []string
+	inCommandList := false
+	commandListOk := false
+
+	for line := range s.lines {
+		switch {
+		case line == "command_list_begin" || line == "command_list_ok_begin":
+			inCommandList = true
+			commandListOk = line == "command_list_ok_begin"
+			commandList = nil
+			continue
+		case inCommandList && line != "command_list_end":
+			commandList = append(commandList, line)
+			continue
+		case inCommandList:
+			inCommandList = false
+		default:
+			commandList = []string{line}
+			commandListOk = false
+		}
+
+		closed := false
+		failed := false
+		for listNum, commandLine := range commandList {
+			err := s.execute(commandLine)
+			if err == errClose {
+				closed = true
+				break
+			}
+			if err != nil {
+				commandName, _, _ := parseCommandLine(commandLine)
+				s.writer.WriteString(fmt.Sprintf("ACK [%d@%d] {%s} %s\n", err.code, listNum, commandName, err.message))
+				failed = true
+				break
+			}
+			if commandListOk {
+				s.writer.WriteString("list_OK\n")
+			}
+		}
+		if closed {
+			s.writer.Flush()
+			return
+		}
+		if !failed {
+			s.writer.WriteString("OK\n")
+		}
+		s.writer.Flush()
+	}
+}
+
+func (s *session) execute(commandLine string) (ackErr *ackError) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.server.log.Warnf("Recovering MPD command %s: %v", commandLine, r)
+			ackErr = newAckError(ackErrorUnknown, "Internal error")
+		}
+	}()
+
+	commandName, args, err := parseCommandLine(commandLine)
+	if err != nil {
+		return newAckError(ackErrorArg, "%v", err)
+	}
+
+	s.server.log.Debugf("Command: %s %v", commandName, args)
+
+	cmd, ok := commands[commandName]
+	if !ok {
+		return newAckError(ackErrorUnknown, "unknown command \"%s\"", commandName)
+	}
+	if cmd.authenticated && s.user == nil {
+		return newAckError(ackErrorPermission, "you don't have permission for \"%s\"", commandName)
+	}
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		return newAckError(ackErrorArg, "wrong number of arguments for \"%s\"", commandName)
+	}
+
+	return cmd.handler(s, args)
+}
+
+// parseCommandLine splits a command line into its name and its (optionally quoted) arguments
+func parseCommandLine(line string) (string, []string, error) {
+	var tokens []string
+
+	line = strings.TrimSpace(line)
+	for len(line) > 0 {
+		if line[0] == '"' {
+			var token strings.Builder
+			closed := false
+			i := 1
+			for ; i < len(line); i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+					token.WriteByte(line[i])
+				} else if line[i] == '"' {
+					closed = true
+					break
+				} else {
+					token.WriteByte(line[i])
+				}
+			}
+			if !closed {
+				return "", nil, fmt.Errorf("missing closing '\"'")
+			}
+			tokens = append(tokens, token.String())
+			line = strings.TrimLeft(line[i+1:], " \t")
+		} else {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			tokens = append(tokens, line[:end])
+			line = strings.TrimLeft(line[end:], " \t")
+		}
+	}
+
+	if len(tokens) == 0 {
+		return "", nil, fmt.Errorf("no command given")
+	}
+
+	return tokens[0], tokens[1:], nil
+}
+
+// login authenticates the connection with a "username:password" password
+func (s *session) login(password string) *ackError {
+	credentials := strings.SplitN(password, ":", 2)
+	if len(credentials) != 2 {
+		return newAckError(ackErrorPassword, "incorrect password")
+	}
+
+	ip := tool.HostIp(s.conn.RemoteAddr().String())
+	if retryAfter := s.server.limiter.CheckLogin(ip, credentials[0]); retryAfter > 0 {
+		return newAckError(ackErrorPassword, "too many failed logins, retry after "+limiter.RetryAfter(retryAfter)+"s")
+	}
+
+	user, err := s.server.store.ReadUserByUserName(nil, credentials[0])
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.server.limiter.LoginFailed("mpd", ip, credentials[0])
+			return newAckError(ackErrorPassword, "incorrect password")
+		}
+		s.server.log.Panicf("Unable to read user: %v", err)
+	}
+	if user.Password != credentials[1] {
+		s.server.limiter.LoginFailed("mpd", ip, credentials[0])
+		return newAckError(ackErrorPassword, "incorrect password")
+	}
+	s.server.limiter.LoginSucceeded(ip, credentials[0])
+
+	s.logout()
+
+	s.user = user
+	s.queue = s.server.queue(user.Id)
+	s.queue.subscribe(s)
+	s.streamToken = s.server.streamToken(user)
+
+	return nil
+}
+
+func (s *session) logout() {
+	if s.queue != nil {
+		s.queue.unsubscribe(s)
+	}
+	s.user = nil
+	s.queue = nil
+	s.streamToken = ""
+}
+
+// notify records a change of the subsystem for the next idle command
+func (s *session) notify(subsystems ...string) {
+	s.eventsMutex.Lock()
+	for _, subsystem := range subsystems {
+		s.events[subsystem] = true
+	}
+	s.eventsMutex.Unlock()
+
+	select {
+	case s.eventCh <- struct{}{}:
+	default:
+	}
+}
+
+// popEvents returns and forgets the pending events matching the subsystems (every events when empty)
+func (s *session) popEvents(subsystems []string) []string {
+	s.eventsMutex.Lock()
+	defer s.eventsMutex.Unlock()
+
+	var events []string
+	for event := range s.events {
+		if len(subsystems) == 0 || tool.Contains(subsystems, event) {
+			events = append(events, event)
+			delete(s.events, event)
+		}
+	}
+	return events
+}
+
+// idle waits for a change in one of the subsystems, or for the noidle command
+func (s *session) idle(subsystems []string) *ackError {
+	s.writer.Flush()
+
+	for {
+		events := s.popEvents(subsystems)
+		if len(events) > 0 {
+			for _, event := range events {
+				s.writeKV("changed", event)
+			}
+			return nil
+		}
+
+		select {
+		case <-s.eventCh:
+		case line, ok := <-s.lines:
+			if !ok {
+				return errClose
+			}
+			if line != "noidle" {
+				return newAckError(ackErrorArg, "only \"noidle\" is allowed during idle")
+			}
+			return nil
+		}
+	}
+}
+
+func (s *session) writeKV(key string, value string) {
+	s.writer.WriteString(key + ": " + strings.NewReplacer("\n", " ", "\r", " ").Replace(value) + "\n")
+}
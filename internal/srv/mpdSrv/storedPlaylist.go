@@ -0,0 +1,279 @@
+package mpdSrv
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Stored playlists are mifasol playlists, identified by their name
+
+func (s *session) readPlaylists() []restApiV1.Playlist {
+	playlists, err := s.server.store.ReadPlaylists(nil, &restApiV1.PlaylistFilter{})
+	if err != nil {
+		s.server.log.Panicf("Unable to read playlists: %v", err)
+	}
+	sort.SliceStable(playlists, func(i, j int) bool {
+		return playlists[i].Name < playlists[j].Name
+	})
+	return playlists
+}
+
+// findPlaylist returns the playlist with the given name, preferring the ones owned by the connected user
+func (s *session) findPlaylist(name string) (*restApiV1.Playlist, *ackError) {
+	var found *restApiV1.Playlist
+
+	playlists := s.readPlaylists()
+	for ind := range playlists {
+		if playlists[ind].Name != name {
+			continue
+		}
+		if isPlaylistOwner(s.user, &playlists[ind]) {
+			return &playlists[ind], nil
+		}
+		if found == nil {
+			found = &playlists[ind]
+		}
+	}
+
+	if found == nil {
+		return nil, newAckError(ackErrorNoExist, "No such playlist")
+	}
+	return found, nil
+}
+
+// findEditablePlaylist returns the playlist with the given name if the connected user owns it or is administrator
+func (s *session) findEditablePlaylist(name string) (*restApiV1.Playlist, *ackError) {
+	playlist, err := s.findPlaylist(name)
+	if err != nil {
+		return nil, err
+	}
+	if !s.user.AdminFg && !isPlaylistOwner(s.user, playlist) {
+		return nil, newAckError(ackErrorPermission, "Only playlist owners can update the playlist")
+	}
+	return playlist, nil
+}
+
+func isPlaylistOwner(user *restApiV1.User, playlist *restApiV1.Playlist) bool {
+	for _, ownerUserId := range playlist.OwnerUserIds {
+		if ownerUserId == user.Id {
+			return true
+		}
+	}
+	return false
+}
+
+// updatePlaylist saves the playlist and notifies every connection
+func (s *session) updatePlaylist(playlist *restApiV1.Playlist) {
+	_, err := s.server.store.UpdatePlaylist(nil, playlist.Id, &playlist.PlaylistMeta, false)
+	if err != nil {
+		s.server.log.Panicf("Unable to update the playlist: %v", err)
+	}
+	s.server.notifyAll("stored_playlist")
+}
+
+func (s *session) writePlaylists() *ackError {
+	for _, playlist := range s.readPlaylists() {
+		s.writeKV("playlist", playlist.Name)
+		s.writeKV("Last-Modified", time.Unix(0, playlist.ContentUpdateTs).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func cmdListplaylists(s *session, args []string) *ackError {
+	return s.writePlaylists()
+}
+
+func cmdListplaylist(s *session, args []string) *ackError {
+	return s.listPlaylist(args, false)
+}
+
+func cmdListplaylistinfo(s *session, args []string) *ackError {
+	return s.listPlaylist(args, true)
+}
+
+func (s *session) listPlaylist(args []string, info bool) *ackError {
+	playlist, err := s.findPlaylist(args[0])
+	if err != nil {
+		return err
+	}
+
+	lib := s.readLibrary()
+	for _, songId := range playlist.SongIds {
+		if _, ok := lib.songs[songId]; !ok {
+			continue
+		}
+		if info {
+			s.writeSong(lib, songId)
+		} else {
+			s.writeKV("file", s.songUri(songId))
+		}
+	}
+	return nil
+}
+
+func cmdLoad(s *session, args []string) *ackError {
+	playlist, err := s.findPlaylist(args[0])
+	if err != nil {
+		return err
+	}
+
+	lib := s.readLibrary()
+	var songIds []restApiV1.SongId
+	for _, songId := range playlist.SongIds {
+		if _, ok := lib.songs[songId]; ok {
+			songIds = append(songIds, songId)
+		}
+	}
+
+	if len(args) > 1 {
+		start, end, err := parseRange(args[1], len(songIds))
+		if err != nil {
+			return err
+		}
+		songIds = songIds[start:end]
+	}
+
+	s.queue.mutex.Lock()
+	defer s.queue.mutex.Unlock()
+
+	s.queue.add(songIds, -1)
+	return nil
+}
+
+// cmdSave creates a playlist owned by the connected user with the songs of the queue
+func cmdSave(s *session, args []string) *ackError {
+	for _, playlist := range s.readPlaylists() {
+		if playlist.Name == args[0] && isPlaylistOwner(s.user, &playlist) {
+			return newAckError(ackErrorExist, "Playlist already exists")
+		}
+	}
+
+	s.queue.mutex.Lock()
+	songIds := []restApiV1.SongId{}
+	for _, entry := range s.queue.entries {
+		songIds = append(songIds, entry.songId)
+	}
+	s.queue.mutex.Unlock()
+
+	_, err := s.server.store.CreatePlaylist(nil, &restApiV1.PlaylistMeta{
+		Name:         args[0],
+		SongIds:      songIds,
+		OwnerUserIds: []restApiV1.UserId{s.user.Id},
+	}, false)
+	if err != nil {
+		s.server.log.Panicf("Unable to create the playlist: %v", err)
+	}
+	s.server.notifyAll("stored_playlist")
+	return nil
+}
+
+// cmdPlaylistadd adds songs to a playlist, creating it when it doesn't exist
+func cmdPlaylistadd(s *session, args []string) *ackError {
+	lib := s.readLibrary()
+
+	songIds, err := resolveUri(lib, args[1])
+	if err != nil {
+		return err
+	}
+
+	playlist, err := s.findEditablePlaylist(args[0])
+	if err != nil && err.code != ackErrorNoExist {
+		return err
+	}
+	if playlist == nil {
+		_, storeErr := s.server.store.CreatePlaylist(nil, &restApiV1.PlaylistMeta{
+			Name:         args[0],
+			SongIds:      songIds,
+			OwnerUserIds: []restApiV1.UserId{s.user.Id},
+		}, false)
+		if storeErr != nil {
+			s.server.log.Panicf("Unable to create the playlist: %v", storeErr)
+		}
+		s.server.notifyAll("stored_playlist")
+		return nil
+	}
+
+	playlist.SongIds = append(playlist.SongIds, songIds...)
+	s.updatePlaylist(playlist)
+	return nil
+}
+
+func cmdPlaylistclear(s *session, args []string) *ackError {
+	playlist, err := s.findEditablePlaylist(args[0])
+	if err != nil {
+		return err
+	}
+
+	playlist.SongIds = []restApiV1.SongId{}
+	s.updatePlaylist(playlist)
+	return nil
+}
+
+func cmdPlaylistdelete(s *session, args []string) *ackError {
+	playlist, err := s.findEditablePlaylist(args[0])
+	if err != nil {
+		return err
+	}
+
+	start, end, err := parseRange(args[1], len(playlist.SongIds))
+	if err != nil {
+		return err
+	}
+
+	playlist.SongIds = append(playlist.SongIds[:start], playlist.SongIds[end:]...)
+	s.updatePlaylist(playlist)
+	return nil
+}
+
+func cmdPlaylistmove(s *session, args []string) *ackError {
+	playlist, err := s.findEditablePlaylist(args[0])
+	if err != nil {
+		return err
+	}
+
+	from, convErr := strconv.Atoi(args[1])
+	if convErr != nil || from < 0 || from >= len(playlist.SongIds) {
+		return newAckError(ackErrorArg, "Bad song index")
+	}
+	to, convErr := strconv.Atoi(args[2])
+	if convErr != nil || to < 0 || to >= len(playlist.SongIds) {
+		return newAckError(ackErrorArg, "Bad song index")
+	}
+
+	songId := playlist.SongIds[from]
+	songIds := append(append([]restApiV1.SongId{}, playlist.SongIds[:from]...), playlist.SongIds[from+1:]...)
+	playlist.SongIds = append(append(append([]restApiV1.SongId{}, songIds[:to]...), songId), songIds[to:]...)
+	s.updatePlaylist(playlist)
+	return nil
+}
+
+func cmdRename(s *session, args []string) *ackError {
+	playlist, err := s.findEditablePlaylist(args[0])
+	if err != nil {
+		return err
+	}
+
+	playlist.Name = args[1]
+	s.updatePlaylist(playlist)
+	return nil
+}
+
+func cmdRm(s *session, args []string) *ackError {
+	playlist, err := s.findEditablePlaylist(args[0])
+	if err != nil {
+		return err
+	}
+	if playlist.Id == restApiV1.IncomingPlaylistId {
+		return newAckError(ackErrorPermission, "Incoming playlist can't be deleted")
+	}
+
+	_, storeErr := s.server.store.DeletePlaylist(nil, playlist.Id)
+	if storeErr != nil && storeErr != storeerror.ErrNotFound {
+		s.server.log.Panicf("Unable to delete the playlist: %v", storeErr)
+	}
+	s.server.notifyAll("stored_playlist")
+	return nil
+}
@@ -0,0 +1,147 @@
+package mpdSrv
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/srv/metrics"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"time"
+)
+
+// streamAccess is the user given access by a stream token, until the user is updated or deleted
+type streamAccess struct {
+	userId       restApiV1.UserId
+	userUpdateTs int64
+}
+
+// streamToken returns the opaque token of the stream urls of user, created on first use
+func (s *MpdServer) streamToken(user *restApiV1.User) string {
+	s.streamTokensMutex.Lock()
+	defer s.streamTokensMutex.Unlock()
+
+	for token, access := range s.streamTokens {
+		if access.userId == user.Id {
+			if access.userUpdateTs == user.UpdateTs {
+				return token
+			}
+			delete(s.streamTokens, token)
+		}
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := fmt.Sprintf("%x", b)
+	s.streamTokens[token] = streamAccess{userId: user.Id, userUpdateTs: user.UpdateTs}
+
+	return token
+}
+
+// streamTokenUser returns the user of a stream token, or nil when the token is unknown or revoked
+func (s *MpdServer) streamTokenUser(token string) *restApiV1.User {
+	s.streamTokensMutex.Lock()
+	access, ok := s.streamTokens[token]
+	s.streamTokensMutex.Unlock()
+	if !ok {
+		return nil
+	}
+
+	user, err := s.store.ReadUser(nil, access.userId)
+	if err != nil && err != storeerror.ErrNotFound {
+		s.log.Panicf("Unable to read user: %v", err)
+	}
+	if user == nil || user.UpdateTs != access.userUpdateTs {
+		// Revoke the token of a deleted or updated user
+		s.streamTokensMutex.Lock()
+		delete(s.streamTokens, token)
+		s.streamTokensMutex.Unlock()
+		return nil
+	}
+
+	return user
+}
+
+// readSongContent sends the original song file of a song url
+func (s *MpdServer) readSongContent(w http.ResponseWriter, r *http.Request) {
+	user := s.streamTokenUser(r.URL.Query().Get("token"))
+	if user == nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	songId := restApiV1.SongId(mux.Vars(r)["songId"])
+
+	s.log.Debugf("Read song content: %s", songId)
+
+	song, err := s.store.ReadSong(nil, songId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		s.log.Panicf("Unable to read song: %v", err)
+	}
+	if user.HideExplicitFg && song.ExplicitFg {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Let the client read the song content directly from the storage
+	songContentUrl, err := s.store.ReadSongContentUrl(song, "")
+	if err != nil {
+		s.log.Panicf("Unable to presign song content url: %v", err)
+	}
+	if songContentUrl != "" {
+		http.Redirect(w, r, songContentUrl, http.StatusTemporaryRedirect)
+		return
+	}
+
+	if r.Method != http.MethodHead {
+		release, retryAfter, ok := s.limiter.AcquireStream("mpd", "user:"+string(user.Id))
+		if !ok {
+			w.Header().Set("Retry-After", limiter.RetryAfter(retryAfter))
+			http.Error(w, "Too many concurrent streams", http.StatusTooManyRequests)
+			return
+		}
+		defer release()
+	}
+
+	songContent, err := s.store.ReadSongContent(song)
+	if err != nil {
+		s.log.Panicf("Unable to read song content: %v", err)
+	}
+	defer songContent.Close()
+
+	w.Header().Set("Content-Type", song.Format.MimeType())
+	streamWriter, streamDone := metrics.TrackStream(w, "mpd")
+	defer streamDone()
+	http.ServeContent(streamWriter, r, "", time.Unix(0, song.UpdateTs), songContent)
+}
+
+// listenOutput sends the http output playing the queue of the user
+func (s *MpdServer) listenOutput(w http.ResponseWriter, r *http.Request) {
+	user := s.streamTokenUser(r.URL.Query().Get("token"))
+	if user == nil {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	s.log.Debugf("Listen output of %s", user.Name)
+
+	release, retryAfter, ok := s.limiter.AcquireStream("mpd", "user:"+string(user.Id))
+	if !ok {
+		w.Header().Set("Retry-After", limiter.RetryAfter(retryAfter))
+		http.Error(w, "Too many concurrent streams", http.StatusTooManyRequests)
+		return
+	}
+	defer release()
+
+	queue := s.queue(user.Id)
+	o := queue.openOutput(s, user)
+	defer queue.closeOutput(o)
+
+	o.stream.Listen(w, r, "Mifasol - "+user.Name)
+}
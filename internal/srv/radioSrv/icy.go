@@ -0,0 +1,66 @@
+package radioSrv
+
+import (
+	"io"
+	"strings"
+)
+
+// icyWriter inserts an ICY metadata block with the current title every icyMetaInt bytes of audio data
+type icyWriter struct {
+	writer       io.Writer
+	station      *station
+	withMetadata bool
+	remaining    int
+	lastTitle    string
+}
+
+func (w *icyWriter) Write(data []byte) (int, error) {
+	if !w.withMetadata {
+		return w.writer.Write(data)
+	}
+
+	written := 0
+	for len(data) > 0 {
+		size := len(data)
+		if size > w.remaining {
+			size = w.remaining
+		}
+		n, err := w.writer.Write(data[:size])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		data = data[size:]
+		w.remaining -= size
+
+		if w.remaining == 0 {
+			_, err = w.writer.Write(w.metadataBlock())
+			if err != nil {
+				return written, err
+			}
+			w.remaining = icyMetaInt
+		}
+	}
+	return written, nil
+}
+
+// metadataBlock returns the title when it has changed, or an empty block
+func (w *icyWriter) metadataBlock() []byte {
+	title := w.station.currentTitle()
+	if title == w.lastTitle {
+		return []byte{0}
+	}
+	w.lastTitle = title
+
+	// Quotes delimit the title
+	metadata := "StreamTitle='" + strings.ReplaceAll(title, "'", "’") + "';"
+	if len(metadata) > 255*16 {
+		metadata = metadata[:255*16]
+	}
+
+	length := (len(metadata) + 15) / 16
+	block := make([]byte, 1+length*16)
+	block[0] = byte(length)
+	copy(block[1:], metadata)
+	return block
+}
@@ -0,0 +1,60 @@
+package radioSrv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const oggPageHeaderSize = 27
+
+// oggPageReader splits an Ogg stream into pages
+type oggPageReader struct {
+	reader *bufio.Reader
+}
+
+func newOggPageReader(reader io.Reader) *oggPageReader {
+	return &oggPageReader{reader: bufio.NewReader(reader)}
+}
+
+// next returns the next complete page, or io.EOF at the end of the stream
+func (r *oggPageReader) next() ([]byte, error) {
+	header := make([]byte, oggPageHeaderSize)
+	_, err := io.ReadFull(r.reader, header)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	if string(header[0:4]) != "OggS" {
+		return nil, errors.New("invalid ogg page")
+	}
+
+	segmentTable := make([]byte, header[26])
+	_, err = io.ReadFull(r.reader, segmentTable)
+	if err != nil {
+		return nil, err
+	}
+
+	bodySize := 0
+	for _, segmentSize := range segmentTable {
+		bodySize += int(segmentSize)
+	}
+
+	page := make([]byte, oggPageHeaderSize+len(segmentTable)+bodySize)
+	copy(page, header)
+	copy(page[oggPageHeaderSize:], segmentTable)
+	_, err = io.ReadFull(r.reader, page[oggPageHeaderSize+len(segmentTable):])
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// oggPageGranulePosition returns the granule position of a page, which is 0 for the header pages
+func oggPageGranulePosition(page []byte) int64 {
+	return int64(binary.LittleEndian.Uint64(page[6:14]))
+}
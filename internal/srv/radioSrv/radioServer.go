@@ -0,0 +1,205 @@
+package radioSrv
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/srv/store"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// Number of audio bytes between two ICY metadata blocks
+const icyMetaInt = 16000
+
+// RadioServer broadcasts playlists as endless Icecast/SHOUTcast compatible streams.
+// Songs are transcoded with ffmpeg when available, otherwise only the songs already encoded in the station format are broadcast.
+type RadioServer struct {
+	store        *store.Store
+	serverConfig *config.ServerConfig
+	subRouter    *mux.Router
+	ffmpegPath   string
+
+	stationsMutex sync.Mutex
+	stations      map[restApiV1.PlaylistId]*station
+	streams       map[*Stream]struct{}
+
+	log *logrus.Entry
+}
+
+func NewRadioServer(store *store.Store, serverConfig *config.ServerConfig, subRouter *mux.Router) *RadioServer {
+	radioServer := &RadioServer{
+		store:        store,
+		serverConfig: serverConfig,
+		subRouter:    subRouter,
+		stations:     make(map[restApiV1.PlaylistId]*station),
+		streams:      make(map[*Stream]struct{}),
+		log:          logrus.WithField("origin", "radio"),
+	}
+
+	radioServer.subRouter.HandleFunc("/{playlistId}", radioServer.listen).Methods("GET")
+
+	return radioServer
+}
+
+// Start launches the broadcast of every radio station
+func (s *RadioServer) Start() error {
+	ffmpegPath := s.serverConfig.FfmpegPath
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	var err error
+	s.ffmpegPath, err = exec.LookPath(ffmpegPath)
+	if err != nil {
+		s.ffmpegPath = ""
+	}
+
+	radioStations, err := s.store.ReadRadioStations(nil)
+	if err != nil {
+		return err
+	}
+
+	if len(radioStations) > 0 && s.ffmpegPath == "" {
+		s.log.Warnf("ffmpeg not found: radio stations only broadcast the songs already encoded in their format")
+	}
+
+	for ind := range radioStations {
+		s.StartStation(&radioStations[ind])
+	}
+
+	return nil
+}
+
+// Stop ends the broadcast of every radio station and stream, and disconnects their listeners
+func (s *RadioServer) Stop() {
+	s.stationsMutex.Lock()
+	defer s.stationsMutex.Unlock()
+
+	for playlistId, st := range s.stations {
+		st.stop()
+		delete(s.stations, playlistId)
+	}
+	for stream := range s.streams {
+		stream.station.stop()
+		delete(s.streams, stream)
+	}
+}
+
+// CanBroadcast tells if song can be broadcast in format: the songs encoded in another format need ffmpeg
+func (s *RadioServer) CanBroadcast(song *restApiV1.Song, format restApiV1.RadioStationFormat) bool {
+	return s.ffmpegPath != "" || song.Format == format.SongFormat()
+}
+
+// StartStation launches the broadcast of a radio station, restarting it when it's already on air
+func (s *RadioServer) StartStation(radioStation *restApiV1.RadioStation) {
+	s.stationsMutex.Lock()
+	defer s.stationsMutex.Unlock()
+
+	if st, ok := s.stations[radioStation.PlaylistId]; ok {
+		st.stop()
+	}
+
+	s.log.Infof("Start radio station %s", radioStation.PlaylistId)
+	st := newStation(s, "radio station "+string(radioStation.PlaylistId), &playlistSource{server: s, radioStation: *radioStation}, radioStation.Format, radioStation.Bitrate)
+	s.stations[radioStation.PlaylistId] = st
+	go st.run()
+}
+
+// StopStation ends the broadcast of a radio station
+func (s *RadioServer) StopStation(playlistId restApiV1.PlaylistId) {
+	s.stationsMutex.Lock()
+	defer s.stationsMutex.Unlock()
+
+	if st, ok := s.stations[playlistId]; ok {
+		s.log.Infof("Stop radio station %s", playlistId)
+		st.stop()
+		delete(s.stations, playlistId)
+	}
+}
+
+// FillState sets the broadcast state of a radio station
+func (s *RadioServer) FillState(radioStation *restApiV1.RadioStation) {
+	st := s.station(radioStation.PlaylistId)
+	if st == nil {
+		return
+	}
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if st.currentSong != nil {
+		songId := st.currentSong.Id
+		radioStation.CurrentSongId = &songId
+	}
+	radioStation.ListenerCount = int64(len(st.listeners))
+}
+
+func (s *RadioServer) station(playlistId restApiV1.PlaylistId) *station {
+	s.stationsMutex.Lock()
+	defer s.stationsMutex.Unlock()
+
+	return s.stations[playlistId]
+}
+
+// listen sends the radio station stream, with ICY metadata when the listener asks for it
+func (s *RadioServer) listen(w http.ResponseWriter, r *http.Request) {
+	playlistId := restApiV1.PlaylistId(mux.Vars(r)["playlistId"])
+
+	s.log.Debugf("Listen radio station: %s", playlistId)
+
+	st := s.station(playlistId)
+	if st == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := "Mifasol"
+	playlist, err := s.store.ReadPlaylist(nil, playlistId)
+	if err == nil {
+		name = playlist.Name
+	}
+
+	st.serve(w, r, name)
+}
+
+// serve sends the broadcast to a new listener until it disconnects, with ICY metadata when the listener asks for it
+func (st *station) serve(w http.ResponseWriter, r *http.Request, name string) {
+	withMetadata := r.Header.Get("Icy-MetaData") == "1"
+
+	w.Header().Set("Content-Type", st.format.MimeType())
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	w.Header().Set("icy-name", name)
+	w.Header().Set("icy-br", strconv.FormatInt(st.bitrate, 10))
+	w.Header().Set("icy-pub", "0")
+	if withMetadata {
+		w.Header().Set("icy-metaint", strconv.Itoa(icyMetaInt))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	l := st.addListener()
+	defer st.removeListener(l)
+
+	writer := &icyWriter{writer: w, station: st, withMetadata: withMetadata, remaining: icyMetaInt}
+	flusher, _ := w.(http.Flusher)
+
+	for {
+		select {
+		case data, ok := <-l.data:
+			if !ok {
+				return
+			}
+			_, err := writer.Write(data)
+			if err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package radioSrv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/mp3"
+	"github.com/faiface/beep/vorbis"
+	"github.com/jypelle/mifasol/internal/srv/store"
+	"github.com/jypelle/mifasol/restApiV1"
+	"io"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// openSource returns the song content encoded in the radio station format from offset, read at playback speed until ctx is done
+func (st *station) openSource(ctx context.Context, song *restApiV1.Song, offset time.Duration) (io.ReadCloser, error) {
+	if st.server.ffmpegPath != "" {
+		return st.openTranscodedSource(ctx, song, offset)
+	}
+	return st.openRelayedSource(ctx, song, offset)
+}
+
+// commandReader reads the standard output of a command, killing it when closed
+type commandReader struct {
+	io.ReadCloser
+	cmd     *exec.Cmd
+	content store.SongContent
+	cancel  context.CancelFunc
+}
+
+func (r *commandReader) Close() error {
+	r.cancel()
+	r.ReadCloser.Close()
+	r.cmd.Wait()
+	return r.content.Close()
+}
+
+// openTranscodedSource encodes the song at the radio station bitrate with ffmpeg, which also paces the output (-re)
+func (st *station) openTranscodedSource(ctx context.Context, song *restApiV1.Song, offset time.Duration) (io.ReadCloser, error) {
+	content, err := st.server.store.ReadSongContent(song)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-re"}
+	if offset > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64))
+	}
+	args = append(args,
+		"-i", "pipe:0",
+		"-vn", "-map_metadata", "-1",
+		"-ac", "2", "-ar", "44100",
+		"-b:a", strconv.FormatInt(st.bitrate, 10)+"k",
+	)
+	switch st.format {
+	case restApiV1.RadioStationFormatOgg:
+		args = append(args, "-c:a", "libvorbis", "-metadata", "title="+st.currentTitle(), "-f", "ogg")
+	default:
+		args = append(args, "-c:a", "libmp3lame", "-id3v2_version", "0", "-write_xing", "0", "-f", "mp3")
+	}
+	args = append(args, "pipe:1")
+
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(ctx, st.server.ffmpegPath, args...)
+	cmd.Stdin = content
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		content.Close()
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		cancel()
+		content.Close()
+		return nil, err
+	}
+
+	return &commandReader{ReadCloser: stdout, cmd: cmd, content: content, cancel: cancel}, nil
+}
+
+// openRelayedSource sends the original song content from offset, paced according to the song duration
+func (st *station) openRelayedSource(ctx context.Context, song *restApiV1.Song, offset time.Duration) (io.ReadCloser, error) {
+	content, err := st.server.store.ReadSongContent(song)
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := songDuration(content, song.Format)
+	if err != nil {
+		content.Close()
+		return nil, err
+	}
+
+	// Skip ID3 tags to keep a continuous mp3 stream
+	start, end, err := audioBounds(content, song.Format)
+	if err != nil {
+		content.Close()
+		return nil, err
+	}
+
+	bytesPerSecond := float64(end-start) / duration.Seconds()
+	var reader io.Reader = io.NewSectionReader(content, start, end-start)
+	if offset > 0 {
+		reader, err = audioFrom(content, song.Format, start, end, start+int64(offset.Seconds()*bytesPerSecond))
+		if err != nil {
+			content.Close()
+			return nil, err
+		}
+	}
+
+	return &pacedReader{
+		ctx:            ctx,
+		reader:         reader,
+		closer:         content,
+		bytesPerSecond: bytesPerSecond,
+		startTime:      time.Now(),
+	}, nil
+}
+
+// nopCloseFile prevents the decoders from closing the song file
+type nopCloseFile struct {
+	store.SongContent
+}
+
+func (f nopCloseFile) Close() error {
+	return nil
+}
+
+// songDuration decodes the song to compute its duration
+func songDuration(content store.SongContent, format restApiV1.SongFormat) (time.Duration, error) {
+	var streamer beep.StreamSeekCloser
+	var beepFormat beep.Format
+	var err error
+
+	switch format {
+	case restApiV1.SongFormatMp3:
+		streamer, beepFormat, err = mp3.Decode(nopCloseFile{content})
+	case restApiV1.SongFormatOgg:
+		streamer, beepFormat, err = vorbis.Decode(nopCloseFile{content})
+	default:
+		return 0, errors.New("unsupported song format")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	duration := beepFormat.SampleRate.D(streamer.Len())
+	if duration <= 0 {
+		return 0, errors.New("unknown song duration")
+	}
+	return duration, nil
+}
+
+// audioBounds returns the position of the audio data in the song file, without the ID3v2 and ID3v1 tags of mp3 files
+func audioBounds(content store.SongContent, format restApiV1.SongFormat) (int64, int64, error) {
+	start := int64(0)
+	end := content.Size()
+
+	if format != restApiV1.SongFormatMp3 {
+		return start, end, nil
+	}
+
+	header := make([]byte, 10)
+	if n, _ := content.ReadAt(header, 0); n == 10 && string(header[0:3]) == "ID3" {
+		// Syncsafe tag size
+		start = 10 + (int64(header[6]&0x7f)<<21 | int64(header[7]&0x7f)<<14 | int64(header[8]&0x7f)<<7 | int64(header[9]&0x7f))
+		if header[5]&0x10 != 0 {
+			start += 10
+		}
+	}
+
+	trailer := make([]byte, 3)
+	if n, _ := content.ReadAt(trailer, end-128); n == 3 && string(trailer) == "TAG" {
+		end -= 128
+	}
+
+	if start >= end {
+		return 0, 0, errors.New("no audio data")
+	}
+	return start, end, nil
+}
+
+// audioFrom returns the audio data of the song from position, moved to the next page of Ogg files whose header pages are read first
+func audioFrom(content store.SongContent, format restApiV1.SongFormat, start int64, end int64, position int64) (io.Reader, error) {
+	if position >= end {
+		return io.NewSectionReader(content, end, 0), nil
+	}
+	if format != restApiV1.SongFormatOgg {
+		return io.NewSectionReader(content, position, end-position), nil
+	}
+
+	headerEnd := start
+	pageReader := newOggPageReader(io.NewSectionReader(content, start, end-start))
+	for {
+		page, err := pageReader.next()
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("no audio data")
+			}
+			return nil, err
+		}
+		if oggPageGranulePosition(page) != 0 {
+			break
+		}
+		headerEnd += int64(len(page))
+	}
+	if position < headerEnd {
+		position = headerEnd
+	}
+
+	buffer := make([]byte, 64*1024)
+	n, _ := content.ReadAt(buffer, position)
+	if ind := bytes.Index(buffer[:n], []byte("OggS")); ind >= 0 {
+		position += int64(ind)
+	} else {
+		position = end
+	}
+
+	return io.MultiReader(io.NewSectionReader(content, start, headerEnd-start), io.NewSectionReader(content, position, end-position)), nil
+}
+
+// pacedReader reads at a constant byte rate
+type pacedReader struct {
+	ctx            context.Context
+	reader         io.Reader
+	closer         io.Closer
+	bytesPerSecond float64
+	startTime      time.Time
+	readBytes      int64
+}
+
+func (r *pacedReader) Read(p []byte) (int, error) {
+	// Read at most 100ms of audio at once
+	maxSize := int(r.bytesPerSecond / 10)
+	if maxSize < 512 {
+		maxSize = 512
+	}
+	if len(p) > maxSize {
+		p = p[:maxSize]
+	}
+
+	wait := time.Until(r.startTime.Add(time.Duration(float64(r.readBytes) / r.bytesPerSecond * float64(time.Second))))
+	if wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+	}
+
+	n, err := r.reader.Read(p)
+	r.readBytes += int64(n)
+	return n, err
+}
+
+func (r *pacedReader) Close() error {
+	return r.closer.Close()
+}
@@ -0,0 +1,346 @@
+package radioSrv
+
+import (
+	"context"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delay before looking again for songs to broadcast when the playlist is empty
+const idleDelay = 10 * time.Second
+
+// Number of audio chunks buffered for each listener: slower listeners are disconnected
+const listenerBufferSize = 256
+
+// Maximum number of bytes of the current song sent at once to new listeners, to quickly fill their buffer
+const burstSize = 64 * 1024
+
+// SongSource chooses the songs broadcast by a station
+type SongSource interface {
+	// NextSong returns the song to broadcast and the position to start from, or nil when there is nothing to broadcast
+	NextSong() (*restApiV1.Song, time.Duration)
+	// SongEnded tells that the broadcast of song has reached its end, or has failed
+	SongEnded(song *restApiV1.Song)
+}
+
+// station broadcasts the songs chosen by its source to every listener at the same position
+type station struct {
+	server      *RadioServer
+	description string
+	source      SongSource
+	format      restApiV1.RadioStationFormat
+	bitrate     int64
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        chan struct{}
+	// Signaled to restart the broadcast with the song chosen by the source
+	restartCh chan struct{}
+
+	mutex       sync.Mutex
+	listeners   map[*listener]struct{}
+	currentSong *restApiV1.Song
+	title       string
+	// Ogg header pages of the current song, sent first to the new listeners
+	oggHeaders [][]byte
+	// Last audio chunks of the current song
+	burst     [][]byte
+	burstSize int
+	// Interrupts the broadcast of the current song
+	songCancel context.CancelFunc
+}
+
+type listener struct {
+	data chan []byte
+}
+
+func newStation(server *RadioServer, description string, source SongSource, format restApiV1.RadioStationFormat, bitrate int64) *station {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &station{
+		server:      server,
+		description: description,
+		source:      source,
+		format:      format,
+		bitrate:     bitrate,
+		ctx:         ctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		restartCh:   make(chan struct{}, 1),
+		listeners:   make(map[*listener]struct{}),
+	}
+}
+
+func (st *station) run() {
+	defer close(st.done)
+
+	for st.ctx.Err() == nil {
+		// The restarts asked until now are fulfilled by the song chosen now
+		select {
+		case <-st.restartCh:
+		default:
+		}
+
+		song, offset := st.source.NextSong()
+		if song == nil {
+			st.setCurrentSong(nil)
+			select {
+			case <-time.After(idleDelay):
+			case <-st.restartCh:
+			case <-st.ctx.Done():
+			}
+			continue
+		}
+
+		songCtx, songCancel := context.WithCancel(st.ctx)
+		st.mutex.Lock()
+		st.songCancel = songCancel
+		st.mutex.Unlock()
+		select {
+		case <-st.restartCh:
+			songCancel()
+			continue
+		default:
+		}
+
+		err := st.play(songCtx, song, offset)
+		interrupted := songCtx.Err() != nil
+		songCancel()
+		if interrupted {
+			continue
+		}
+		if err != nil {
+			st.server.log.Warnf("Unable to broadcast song %s on %s: %v", song.Id, st.description, err)
+		}
+		st.source.SongEnded(song)
+		if err != nil {
+			// Avoid looping on broken songs
+			select {
+			case <-time.After(time.Second):
+			case <-st.ctx.Done():
+			}
+		}
+	}
+}
+
+// restart interrupts the current song to broadcast the song chosen by the source
+func (st *station) restart() {
+	select {
+	case st.restartCh <- struct{}{}:
+	default:
+	}
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if st.songCancel != nil {
+		st.songCancel()
+	}
+}
+
+// stop ends the broadcast and disconnects the listeners
+func (st *station) stop() {
+	st.cancel()
+	<-st.done
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	for l := range st.listeners {
+		close(l.data)
+		delete(st.listeners, l)
+	}
+}
+
+// playlistSource broadcasts endlessly the songs of the playlist of a radio station
+type playlistSource struct {
+	server       *RadioServer
+	radioStation restApiV1.RadioStation
+
+	// Next songs to broadcast
+	queue []restApiV1.SongId
+}
+
+// NextSong returns the next song of the playlist which can be broadcast
+func (p *playlistSource) NextSong() (*restApiV1.Song, time.Duration) {
+	refilled := false
+	for {
+		if len(p.queue) == 0 {
+			if refilled {
+				return nil, 0
+			}
+			p.queue = p.readPlaylistSongIds()
+			refilled = true
+			if len(p.queue) == 0 {
+				return nil, 0
+			}
+		}
+
+		songId := p.queue[0]
+		p.queue = p.queue[1:]
+
+		song, err := p.server.store.ReadSong(nil, songId)
+		if err != nil {
+			if err != storeerror.ErrNotFound {
+				p.server.log.Warnf("Unable to read song %s: %v", songId, err)
+			}
+			continue
+		}
+		if !p.server.CanBroadcast(song, p.radioStation.Format) {
+			continue
+		}
+		return song, 0
+	}
+}
+
+func (p *playlistSource) SongEnded(song *restApiV1.Song) {
+}
+
+// readPlaylistSongIds returns the songs of the playlist, in the broadcast order
+func (p *playlistSource) readPlaylistSongIds() []restApiV1.SongId {
+	playlist, err := p.server.store.ReadPlaylist(nil, p.radioStation.PlaylistId)
+	if err != nil {
+		if err != storeerror.ErrNotFound {
+			p.server.log.Warnf("Unable to read playlist %s: %v", p.radioStation.PlaylistId, err)
+		}
+		return nil
+	}
+
+	songIds := append([]restApiV1.SongId{}, playlist.SongIds...)
+	if p.radioStation.ShuffleFg {
+		rand.Shuffle(len(songIds), func(i, j int) {
+			songIds[i], songIds[j] = songIds[j], songIds[i]
+		})
+	}
+	return songIds
+}
+
+func (st *station) play(ctx context.Context, song *restApiV1.Song, offset time.Duration) error {
+	st.setCurrentSong(song)
+
+	source, err := st.openSource(ctx, song, offset)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	if st.format == restApiV1.RadioStationFormatOgg {
+		// Ogg streams are broadcast page by page, so new listeners can start with the header pages
+		pageReader := newOggPageReader(source)
+		headerPhase := true
+		for {
+			page, err := pageReader.next()
+			if err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			headerPhase = headerPhase && oggPageGranulePosition(page) == 0
+			st.broadcast(page, headerPhase)
+		}
+	}
+
+	for {
+		buffer := make([]byte, 4096)
+		n, err := source.Read(buffer)
+		if n > 0 {
+			st.broadcast(buffer[:n], false)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// setCurrentSong updates the song on air and its ICY title
+func (st *station) setCurrentSong(song *restApiV1.Song) {
+	title := ""
+	if song != nil {
+		title = song.Name
+		var artistNames []string
+		for _, artistId := range song.ArtistIds {
+			artist, err := st.server.store.ReadArtist(nil, artistId)
+			if err == nil {
+				artistNames = append(artistNames, artist.Name)
+			}
+		}
+		if len(artistNames) > 0 {
+			title = strings.Join(artistNames, ", ") + " - " + title
+		}
+	}
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	st.currentSong = song
+	st.title = title
+	st.oggHeaders = nil
+	st.burst = nil
+	st.burstSize = 0
+}
+
+func (st *station) currentTitle() string {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	return st.title
+}
+
+// broadcast sends a chunk of audio data to every listener
+func (st *station) broadcast(data []byte, oggHeader bool) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if oggHeader {
+		st.oggHeaders = append(st.oggHeaders, data)
+	} else {
+		st.burst = append(st.burst, data)
+		st.burstSize += len(data)
+		for len(st.burst) > 1 && st.burstSize-len(st.burst[0]) >= burstSize {
+			st.burstSize -= len(st.burst[0])
+			st.burst = st.burst[1:]
+		}
+	}
+
+	for l := range st.listeners {
+		select {
+		case l.data <- data:
+		default:
+			st.server.log.Debugf("Disconnect slow listener of %s", st.description)
+			close(l.data)
+			delete(st.listeners, l)
+		}
+	}
+}
+
+func (st *station) addListener() *listener {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	l := &listener{data: make(chan []byte, listenerBufferSize)}
+	for _, chunk := range append(append([][]byte{}, st.oggHeaders...), st.burst...) {
+		if len(l.data) < cap(l.data) {
+			l.data <- chunk
+		}
+	}
+	st.listeners[l] = struct{}{}
+
+	return l
+}
+
+func (st *station) removeListener(l *listener) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	if _, ok := st.listeners[l]; ok {
+		close(l.data)
+		delete(st.listeners, l)
+	}
+}
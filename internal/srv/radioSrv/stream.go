@@ -0,0 +1,44 @@
+package radioSrv
+
+import (
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+)
+
+// Stream broadcasts the songs chosen by a SongSource like a radio station, the source being able to restart it at any time
+type Stream struct {
+	station *station
+}
+
+// NewStream launches the broadcast in format of the songs chosen by source, until Stop is called
+func (s *RadioServer) NewStream(description string, source SongSource, format restApiV1.RadioStationFormat, bitrate int64) *Stream {
+	stream := &Stream{station: newStation(s, description, source, format, bitrate)}
+
+	s.stationsMutex.Lock()
+	s.streams[stream] = struct{}{}
+	s.stationsMutex.Unlock()
+
+	go stream.station.run()
+
+	return stream
+}
+
+// Restart interrupts the current song to broadcast the song chosen now by the source
+func (stream *Stream) Restart() {
+	stream.station.restart()
+}
+
+// Stop ends the broadcast and disconnects the listeners
+func (stream *Stream) Stop() {
+	s := stream.station.server
+	s.stationsMutex.Lock()
+	delete(s.streams, stream)
+	s.stationsMutex.Unlock()
+
+	stream.station.stop()
+}
+
+// Listen sends the broadcast to a new listener until it disconnects or the stream stops
+func (stream *Stream) Listen(w http.ResponseWriter, r *http.Request, name string) {
+	stream.station.serve(w, r, name)
+}
@@ -3,6 +3,7 @@ package restSrvV1
 import (
 	"encoding/json"
 	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
 	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
@@ -58,7 +59,14 @@ func (s *RestServer) createAlbum(w http.ResponseWriter, r *http.Request) {
 	// Check credential
 	// TODO
 
-	album, err := s.store.CreateAlbum(nil, &albumMeta)
+	var album *restApiV1.Album
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		album, err = s.store.CreateAlbum(txn, &albumMeta)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeAlbum, string(album.Id), nil, &album.AlbumMeta))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to create the album: %v", err)
 	}
@@ -80,12 +88,28 @@ func (s *RestServer) updateAlbum(w http.ResponseWriter, r *http.Request) {
 		s.log.Panicf("Unable to interpret data to update the album: %v", err)
 	}
 
-	album, err := s.store.UpdateAlbum(nil, albumId, &albumMeta)
+	var updatedAlbum *restApiV1.Album
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		album, err := s.store.ReadAlbum(txn, albumId)
+		if err != nil {
+			return err
+		}
+
+		updatedAlbum, err = s.store.UpdateAlbum(txn, albumId, &albumMeta)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypeAlbum, string(albumId), &album.AlbumMeta, &updatedAlbum.AlbumMeta))
+	})
 	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
 		s.log.Panicf("Unable to update the album: %v", err)
 	}
 
-	tool.WriteJsonResponse(w, album)
+	tool.WriteJsonResponse(w, updatedAlbum)
 
 }
 
@@ -96,7 +120,15 @@ func (s *RestServer) deleteAlbum(w http.ResponseWriter, r *http.Request) {
 
 	s.log.Debugf("Delete album: %s", albumId)
 
-	album, err := s.store.DeleteAlbum(nil, albumId)
+	var album *restApiV1.Album
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		album, err = s.store.DeleteAlbum(txn, albumId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeAlbum, string(album.Id), &album.AlbumMeta, nil))
+	})
 	if err != nil {
 		if err == storeerror.ErrDeleteAlbumWithSongs {
 			s.apiErrorCodeResponse(w, restApiV1.DeleteAlbumWithSongsErrorCode)
@@ -0,0 +1,195 @@
+package restSrvV1
+
+import (
+	"archive/zip"
+	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (s *RestServer) readAlbumArchive(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	albumId := restApiV1.AlbumId(vars["id"])
+
+	s.log.Debugf("Read album archive: %s", albumId)
+
+	if albumId == restApiV1.UnknownAlbumId {
+		s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+		return
+	}
+
+	album, err := s.store.ReadAlbum(nil, albumId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read album: %v", err)
+	}
+
+	songs, err := s.store.ReadAlbumSongs(nil, albumId)
+	if err != nil {
+		s.log.Panicf("Unable to read album songs: %v", err)
+	}
+
+	archive := newSongArchive(s.ConnectedUser(r))
+	for ind := range songs {
+		archive.addSong(&songs[ind])
+	}
+
+	s.writeArchive(w, tool.SanitizeFilename(album.Name)+".zip", "", archive)
+}
+
+func (s *RestServer) readPlaylistArchive(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	playlistId := restApiV1.PlaylistId(vars["id"])
+
+	s.log.Debugf("Read playlist archive: %s", playlistId)
+
+	playlist, err := s.store.ReadPlaylist(nil, playlistId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read playlist: %v", err)
+	}
+
+	archive := newSongArchive(s.ConnectedUser(r))
+	for _, songId := range playlist.SongIds {
+		song, err := s.store.ReadSong(nil, songId)
+		if err != nil {
+			if err == storeerror.ErrNotFound {
+				continue
+			}
+			s.log.Panicf("Unable to read song: %v", err)
+		}
+		archive.addSong(song)
+	}
+
+	s.writeArchive(w, tool.SanitizeFilename(playlist.Name)+".zip", tool.SanitizeFilename(playlist.Name)+".m3u8", archive)
+}
+
+// songArchive lists the songs of an archive, in playlist order, without duplicates
+type songArchive struct {
+	user  *restApiV1.User
+	songs []*restApiV1.Song
+	added map[restApiV1.SongId]struct{}
+	// Song order, with duplicates, for the m3u8 playlist
+	songIds []restApiV1.SongId
+}
+
+func newSongArchive(user *restApiV1.User) *songArchive {
+	return &songArchive{
+		user:  user,
+		added: make(map[restApiV1.SongId]struct{}),
+	}
+}
+
+func (a *songArchive) addSong(song *restApiV1.Song) {
+	if a.user.HideExplicitFg && song.ExplicitFg {
+		return
+	}
+	a.songIds = append(a.songIds, song.Id)
+	if _, ok := a.added[song.Id]; ok {
+		return
+	}
+	a.added[song.Id] = struct{}{}
+	a.songs = append(a.songs, song)
+}
+
+// writeArchive streams a zip of the songs, named like the file sync songs.
+// With a playlist filename, songs are stored in songs/ and the m3u8 playlist in playlists/, like file sync does.
+func (s *RestServer) writeArchive(w http.ResponseWriter, archiveFilename string, playlistFilename string, archive *songArchive) {
+
+	// Give an unique file path to each song
+	songFilepaths := make(map[restApiV1.SongId]string)
+	usedFilepaths := make(map[string]struct{})
+	for _, song := range archive.songs {
+		songFilepath, err := s.store.SongFilepath(nil, song)
+		if err != nil {
+			s.log.Panicf("Unable to name song file: %v", err)
+		}
+		if playlistFilename != "" {
+			songFilepath = "songs/" + songFilepath
+		}
+		ext := path.Ext(songFilepath)
+		base := strings.TrimSuffix(songFilepath, ext)
+		for ind := 2; ; ind++ {
+			if _, ok := usedFilepaths[strings.ToLower(songFilepath)]; !ok {
+				break
+			}
+			songFilepath = base + " (" + strconv.Itoa(ind) + ")" + ext
+		}
+		usedFilepaths[strings.ToLower(songFilepath)] = struct{}{}
+		songFilepaths[song.Id] = songFilepath
+	}
+
+	// Songs are already compressed: store them as is, with an unknown final size
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archiveFilename}))
+
+	zipWriter := zip.NewWriter(w)
+
+	for _, song := range archive.songs {
+		songContent, err := s.store.ReadSongContent(song)
+		if err != nil {
+			s.log.Warnf("Unable to read song content %s: %v", song.Id, err)
+			delete(songFilepaths, song.Id)
+			continue
+		}
+
+		fileWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:     songFilepaths[song.Id],
+			Method:   zip.Store,
+			Modified: time.Unix(0, song.UpdateTs),
+		})
+		if err == nil {
+			_, err = io.Copy(fileWriter, songContent)
+		}
+		songContent.Close()
+		if err != nil {
+			// Client is gone
+			s.log.Debugf("Unable to write archive: %v", err)
+			return
+		}
+	}
+
+	if playlistFilename != "" {
+		fileWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:     "playlists/" + playlistFilename,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			s.log.Debugf("Unable to write archive: %v", err)
+			return
+		}
+		for _, songId := range archive.songIds {
+			songFilepath, ok := songFilepaths[songId]
+			if !ok {
+				continue
+			}
+			_, err = io.WriteString(fileWriter, "../"+songFilepath+"\n")
+			if err != nil {
+				s.log.Debugf("Unable to write archive: %v", err)
+				return
+			}
+		}
+	}
+
+	err := zipWriter.Close()
+	if err != nil {
+		s.log.Debugf("Unable to write archive: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package restSrvV1
 import (
 	"encoding/json"
 	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
 	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
@@ -55,7 +56,14 @@ func (s *RestServer) createArtist(w http.ResponseWriter, r *http.Request) {
 		s.log.Panicf("Unable to interpret data to create the artist: %v", err)
 	}
 
-	artist, err := s.store.CreateArtist(nil, &artistMeta)
+	var artist *restApiV1.Artist
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		artist, err = s.store.CreateArtist(txn, &artistMeta)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeArtist, string(artist.Id), nil, &artist.ArtistMeta))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to create the artist: %v", err)
 	}
@@ -76,12 +84,28 @@ func (s *RestServer) updateArtist(w http.ResponseWriter, r *http.Request) {
 		s.log.Panicf("Unable to interpret data to update the artist: %v", err)
 	}
 
-	artist, err := s.store.UpdateArtist(nil, artistId, &artistMeta)
+	var updatedArtist *restApiV1.Artist
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		artist, err := s.store.ReadArtist(txn, artistId)
+		if err != nil {
+			return err
+		}
+
+		updatedArtist, err = s.store.UpdateArtist(txn, artistId, &artistMeta)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypeArtist, string(artistId), &artist.ArtistMeta, &updatedArtist.ArtistMeta))
+	})
 	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
 		s.log.Panicf("Unable to update the artist: %v", err)
 	}
 
-	tool.WriteJsonResponse(w, artist)
+	tool.WriteJsonResponse(w, updatedArtist)
 
 }
 
@@ -92,7 +116,15 @@ func (s *RestServer) deleteArtist(w http.ResponseWriter, r *http.Request) {
 
 	s.log.Debugf("Delete artist: %s", artistId)
 
-	artist, err := s.store.DeleteArtist(nil, artistId)
+	var artist *restApiV1.Artist
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		artist, err = s.store.DeleteArtist(txn, artistId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeArtist, string(artist.Id), &artist.ArtistMeta, nil))
+	})
 	if err != nil {
 		if err == storeerror.ErrDeleteArtistWithSongs {
 			s.apiErrorCodeResponse(w, restApiV1.DeleteArtistWithSongsErrorCode)
@@ -0,0 +1,125 @@
+package restSrvV1
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"sort"
+)
+
+// Meta fields whose values are never recorded
+var auditRedactedFields = map[string]bool{
+	"password": true,
+}
+
+func (s *RestServer) readAuditEntries(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read audit entries")
+
+	if !s.ConnectedUser(r).AdminFg {
+		s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+		return
+	}
+
+	var auditEntryFilter restApiV1.AuditEntryFilter
+	err := json.NewDecoder(r.Body).Decode(&auditEntryFilter)
+	if err != nil {
+		s.log.Panicf("Unable to interpret data to read the audit entries: %v", err)
+	}
+
+	auditEntries, err := s.store.ReadAuditEntries(nil, &auditEntryFilter)
+	if err != nil {
+		s.log.Panicf("Unable to read audit entries: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, auditEntries)
+}
+
+// Audit completes the entry with the connected user and appends it to the audit trail, in the transaction
+// of the audited action: the action is rolled back when it can't be recorded
+func (s *RestServer) Audit(txn *sqlx.Tx, r *http.Request, auditEntry *restApiV1.AuditEntry) error {
+	connectedUser := s.ConnectedUser(r)
+	auditEntry.UserId = connectedUser.Id
+	auditEntry.UserName = connectedUser.Name
+	auditEntry.ClientIp = tool.ClientIp(r)
+
+	_, err := s.store.CreateAuditEntry(txn, auditEntry)
+	return err
+}
+
+// NewAuditEntry returns the entry of an action on an entity, with the meta fields changed from before to after.
+// before is nil for a creation and after is nil for a deletion.
+func NewAuditEntry(action restApiV1.AuditAction, entityType restApiV1.AuditEntityType, entityId string, before interface{}, after interface{}) *restApiV1.AuditEntry {
+	beforeFields := auditFields(before)
+	afterFields := auditFields(after)
+
+	return &restApiV1.AuditEntry{
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityId,
+		EntityName: auditEntityName(beforeFields, afterFields),
+		Changes:    auditChanges(beforeFields, afterFields),
+	}
+}
+
+// auditFields returns the JSON values of the meta fields
+func auditFields(meta interface{}) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	if meta == nil {
+		return fields
+	}
+
+	encodedMeta, err := json.Marshal(meta)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(encodedMeta, &fields)
+
+	for field := range fields {
+		if auditRedactedFields[field] {
+			// A void password means unchanged
+			if string(fields[field]) == `""` {
+				delete(fields, field)
+			} else {
+				fields[field] = json.RawMessage(`"***"`)
+			}
+		}
+	}
+
+	return fields
+}
+
+func auditEntityName(beforeFields map[string]json.RawMessage, afterFields map[string]json.RawMessage) string {
+	var name string
+	if encodedName, ok := afterFields["name"]; ok {
+		json.Unmarshal(encodedName, &name)
+	} else if encodedName, ok := beforeFields["name"]; ok {
+		json.Unmarshal(encodedName, &name)
+	}
+	return name
+}
+
+// auditChanges returns the fields whose value differs, sorted by name
+func auditChanges(beforeFields map[string]json.RawMessage, afterFields map[string]json.RawMessage) []restApiV1.AuditChange {
+	changes := []restApiV1.AuditChange{}
+
+	for field, beforeValue := range beforeFields {
+		afterValue, ok := afterFields[field]
+		if !ok || !bytes.Equal(beforeValue, afterValue) {
+			changes = append(changes, restApiV1.AuditChange{Field: field, Before: beforeValue, After: afterValue})
+		}
+	}
+	for field, afterValue := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes = append(changes, restApiV1.AuditChange{Field: field, After: afterValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes
+}
@@ -0,0 +1,239 @@
+package restSrvV1
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of commands buffered for each device
+const deviceCommandBufferSize = 16
+
+type connectedDevice struct {
+	device   restApiV1.Device
+	commands chan restApiV1.DeviceCommand
+}
+
+// deviceRegistry keeps the devices connected to their command stream
+type deviceRegistry struct {
+	mutex   sync.Mutex
+	devices map[restApiV1.DeviceId]*connectedDevice
+}
+
+func (s *RestServer) readDevices(w http.ResponseWriter, r *http.Request) {
+	user := s.ConnectedUser(r)
+
+	s.log.Debugf("Read devices: %s", user.Id)
+
+	s.deviceRegistry.mutex.Lock()
+	devices := []restApiV1.Device{}
+	for _, connectedDevice := range s.deviceRegistry.devices {
+		if connectedDevice.device.UserId == user.Id {
+			devices = append(devices, connectedDevice.device)
+		}
+	}
+	s.deviceRegistry.mutex.Unlock()
+
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Name != devices[j].Name {
+			return devices[i].Name < devices[j].Name
+		}
+		return devices[i].Id < devices[j].Id
+	})
+
+	tool.WriteJsonResponse(w, devices)
+}
+
+// readDeviceCommands registers the device and streams its remote commands as server-sent events, until the device disconnects
+func (s *RestServer) readDeviceCommands(w http.ResponseWriter, r *http.Request) {
+	user := s.ConnectedUser(r)
+	vars := mux.Vars(r)
+	deviceId := restApiV1.DeviceId(vars["id"])
+
+	s.log.Debugf("Read device commands: %s", deviceId)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.log.Panicf("Unable to stream device commands: flush not supported")
+	}
+
+	deviceMeta := restApiV1.DeviceMeta{
+		Name:         strings.TrimSpace(r.URL.Query().Get("name")),
+		Capabilities: []restApiV1.DeviceCommandType{},
+	}
+	if deviceMeta.Name == "" {
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+	for _, capability := range strings.Split(r.URL.Query().Get("capabilities"), ",") {
+		switch commandType := restApiV1.DeviceCommandType(capability); commandType {
+		case restApiV1.PlayDeviceCommandType,
+			restApiV1.PauseDeviceCommandType,
+			restApiV1.NextDeviceCommandType,
+			restApiV1.SeekDeviceCommandType,
+			restApiV1.VolumeDeviceCommandType,
+			restApiV1.QueueDeviceCommandType:
+			deviceMeta.Capabilities = append(deviceMeta.Capabilities, commandType)
+		}
+	}
+
+	newConnectedDevice := &connectedDevice{
+		device: restApiV1.Device{
+			Id:           deviceId,
+			UserId:       user.Id,
+			ConnectionTs: time.Now().UnixNano(),
+			DeviceMeta:   deviceMeta,
+		},
+		commands: make(chan restApiV1.DeviceCommand, deviceCommandBufferSize),
+	}
+
+	// Register the device, replacing its previous connection
+	s.deviceRegistry.mutex.Lock()
+	if oldConnectedDevice, ok := s.deviceRegistry.devices[deviceId]; ok {
+		if oldConnectedDevice.device.UserId != user.Id {
+			s.deviceRegistry.mutex.Unlock()
+			s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+			return
+		}
+		newConnectedDevice.device.State = oldConnectedDevice.device.State
+		close(oldConnectedDevice.commands)
+	}
+	s.deviceRegistry.devices[deviceId] = newConnectedDevice
+	s.deviceRegistry.mutex.Unlock()
+
+	defer func() {
+		s.deviceRegistry.mutex.Lock()
+		if s.deviceRegistry.devices[deviceId] == newConnectedDevice {
+			delete(s.deviceRegistry.devices, deviceId)
+		}
+		s.deviceRegistry.mutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	keepAliveTicker := time.NewTicker(eventKeepAliveInterval)
+	defer keepAliveTicker.Stop()
+
+	for {
+		select {
+		case command, ok := <-newConnectedDevice.commands:
+			if !ok {
+				// Replaced by a new connection of the device
+				return
+			}
+			data, _ := json.Marshal(command)
+			_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", command.Type, data)
+			if err != nil {
+				return
+			}
+		case <-keepAliveTicker.C:
+			_, err := fmt.Fprintf(w, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.stopCh:
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// createDeviceCommand relays a remote command to a device
+func (s *RestServer) createDeviceCommand(w http.ResponseWriter, r *http.Request) {
+	user := s.ConnectedUser(r)
+	vars := mux.Vars(r)
+	deviceId := restApiV1.DeviceId(vars["id"])
+
+	s.log.Debugf("Create device command: %s", deviceId)
+
+	var command restApiV1.DeviceCommand
+	err := json.NewDecoder(r.Body).Decode(&command)
+	if err != nil {
+		s.log.Panicf("Unable to interpret data to create the device command: %v", err)
+	}
+
+	switch command.Type {
+	case restApiV1.SeekDeviceCommandType:
+		if command.Position < 0 {
+			s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+			return
+		}
+	case restApiV1.VolumeDeviceCommandType:
+		if command.Volume < 0 || command.Volume > 100 {
+			s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+			return
+		}
+	case restApiV1.QueueDeviceCommandType:
+		if len(command.SongIds) == 0 || command.CurrentIndex < 0 || command.CurrentIndex >= int64(len(command.SongIds)) {
+			s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+			return
+		}
+	}
+
+	s.deviceRegistry.mutex.Lock()
+	defer s.deviceRegistry.mutex.Unlock()
+
+	connectedDevice, ok := s.deviceRegistry.devices[deviceId]
+	if !ok || connectedDevice.device.UserId != user.Id {
+		s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+		return
+	}
+	if !connectedDevice.device.HasCapability(command.Type) {
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+
+	select {
+	case connectedDevice.commands <- command:
+	default:
+		// The device doesn't read its commands anymore
+		s.apiErrorCodeResponse(w, restApiV1.InternalErrorCode)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	tool.WriteJsonResponse(w, connectedDevice.device)
+}
+
+// updateDeviceState stores the "now playing" state reported by a device
+func (s *RestServer) updateDeviceState(w http.ResponseWriter, r *http.Request) {
+	user := s.ConnectedUser(r)
+	vars := mux.Vars(r)
+	deviceId := restApiV1.DeviceId(vars["id"])
+
+	s.log.Debugf("Update device state: %s", deviceId)
+
+	var deviceState restApiV1.DeviceState
+	err := json.NewDecoder(r.Body).Decode(&deviceState)
+	if err != nil {
+		s.log.Panicf("Unable to interpret data to update the device state: %v", err)
+	}
+	deviceState.UpdateTs = time.Now().UnixNano()
+
+	s.deviceRegistry.mutex.Lock()
+	defer s.deviceRegistry.mutex.Unlock()
+
+	connectedDevice, ok := s.deviceRegistry.devices[deviceId]
+	if !ok || connectedDevice.device.UserId != user.Id {
+		s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+		return
+	}
+	connectedDevice.device.State = deviceState
+
+	tool.WriteJsonResponse(w, connectedDevice.device)
+}
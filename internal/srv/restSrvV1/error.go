@@ -2,8 +2,10 @@ package restSrvV1
 
 import (
 	"encoding/json"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
 	"github.com/jypelle/mifasol/restApiV1"
 	"net/http"
+	"time"
 )
 
 func (s *RestServer) apiErrorCodeResponse(w http.ResponseWriter, apiErrorCode restApiV1.ErrorCode) {
@@ -18,3 +20,9 @@ func (s *RestServer) apiErrorResponse(w http.ResponseWriter, apiError restApiV1.
 	w.WriteHeader(apiError.ErrorCode.StatusCode())
 	json.NewEncoder(w).Encode(apiError)
 }
+
+// tooManyRequestsResponse refuses a request, telling the client when to retry
+func (s *RestServer) tooManyRequestsResponse(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", limiter.RetryAfter(retryAfter))
+	s.apiErrorCodeResponse(w, restApiV1.TooManyRequestsErrorCode)
+}
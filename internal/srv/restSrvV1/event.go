@@ -0,0 +1,61 @@
+package restSrvV1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Delay between two keep-alive comments on idle event streams
+const eventKeepAliveInterval = 30 * time.Second
+
+// readEvents streams the library change events as server-sent events
+func (s *RestServer) readEvents(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read events")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.log.Panicf("Unable to stream events: flush not supported")
+	}
+
+	subscription := s.store.SubscribeEvents()
+	defer s.store.UnsubscribeEvents(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Ask the clients to wait a few seconds before reconnecting
+	fmt.Fprintf(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	keepAliveTicker := time.NewTicker(eventKeepAliveInterval)
+	defer keepAliveTicker.Stop()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// Too slow: the client reconnects and refreshes its whole content
+				return
+			}
+			data, _ := json.Marshal(event)
+			_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			if err != nil {
+				return
+			}
+		case <-keepAliveTicker.C:
+			_, err := fmt.Fprintf(w, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.stopCh:
+			return
+		}
+		flusher.Flush()
+	}
+}
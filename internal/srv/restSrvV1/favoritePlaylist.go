@@ -3,6 +3,7 @@ package restSrvV1
 import (
 	"encoding/json"
 	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
 	"net/http"
@@ -28,7 +29,14 @@ func (s *RestServer) createFavoritePlaylist(w http.ResponseWriter, r *http.Reque
 		s.log.Panicf("Unable to interpret data to create the favorite playlist: %v", err)
 	}
 
-	favoritePlaylist, err := s.store.CreateFavoritePlaylist(nil, &favoritePlaylistMeta, true)
+	var favoritePlaylist *restApiV1.FavoritePlaylist
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		favoritePlaylist, err = s.store.CreateFavoritePlaylist(txn, &favoritePlaylistMeta, true)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeFavoritePlaylist, string(favoritePlaylist.Id.PlaylistId), nil, &favoritePlaylist.FavoritePlaylistMeta))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to create the favorite playlist: %v", err)
 	}
@@ -46,7 +54,15 @@ func (s *RestServer) deleteFavoritePlaylist(w http.ResponseWriter, r *http.Reque
 
 	s.log.Debugf("Delete favorite playlist: %v", favoritePlaylistId)
 
-	favoritePlaylist, err := s.store.DeleteFavoritePlaylist(nil, favoritePlaylistId)
+	var favoritePlaylist *restApiV1.FavoritePlaylist
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		favoritePlaylist, err = s.store.DeleteFavoritePlaylist(txn, favoritePlaylistId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeFavoritePlaylist, string(favoritePlaylist.Id.PlaylistId), &favoritePlaylist.FavoritePlaylistMeta, nil))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to delete favorite playlist: %v", err)
 	}
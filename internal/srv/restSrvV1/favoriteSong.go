@@ -3,6 +3,7 @@ package restSrvV1
 import (
 	"encoding/json"
 	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
 	"net/http"
@@ -28,7 +29,14 @@ func (s *RestServer) createFavoriteSong(w http.ResponseWriter, r *http.Request)
 		s.log.Panicf("Unable to interpret data to create the favorite song: %v", err)
 	}
 
-	favoriteSong, err := s.store.CreateFavoriteSong(nil, &favoriteSongMeta, true)
+	var favoriteSong *restApiV1.FavoriteSong
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		favoriteSong, err = s.store.CreateFavoriteSong(txn, &favoriteSongMeta, true)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeFavoriteSong, string(favoriteSong.Id.SongId), nil, &favoriteSong.FavoriteSongMeta))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to create the favorite song: %v", err)
 	}
@@ -46,7 +54,15 @@ func (s *RestServer) deleteFavoriteSong(w http.ResponseWriter, r *http.Request)
 
 	s.log.Debugf("Delete favorite song: %v", favoriteSongId)
 
-	favoriteSong, err := s.store.DeleteFavoriteSong(nil, favoriteSongId)
+	var favoriteSong *restApiV1.FavoriteSong
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		favoriteSong, err = s.store.DeleteFavoriteSong(txn, favoriteSongId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeFavoriteSong, string(favoriteSong.Id.SongId), &favoriteSong.FavoriteSongMeta, nil))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to delete favorite song: %v", err)
 	}
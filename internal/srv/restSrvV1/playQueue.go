@@ -0,0 +1,57 @@
+package restSrvV1
+
+import (
+	"encoding/json"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+)
+
+func (s *RestServer) readPlayQueue(w http.ResponseWriter, r *http.Request) {
+	user := s.ConnectedUser(r)
+
+	s.log.Debugf("Read play queue: %s", user.Id)
+
+	playQueue, err := s.store.ReadPlayQueue(nil, user.Id)
+	if err != nil {
+		s.log.Panicf("Unable to read play queue: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, playQueue)
+}
+
+func (s *RestServer) updatePlayQueue(w http.ResponseWriter, r *http.Request) {
+	user := s.ConnectedUser(r)
+
+	s.log.Debugf("Update play queue: %s", user.Id)
+
+	var playQueueMeta restApiV1.PlayQueueMeta
+	err := json.NewDecoder(r.Body).Decode(&playQueueMeta)
+	if err != nil {
+		s.log.Panicf("Unable to interpret data to update the play queue: %v", err)
+	}
+
+	if playQueueMeta.RepeatMode == "" {
+		playQueueMeta.RepeatMode = restApiV1.RepeatModeOff
+	}
+	switch playQueueMeta.RepeatMode {
+	case restApiV1.RepeatModeOff, restApiV1.RepeatModeAll, restApiV1.RepeatModeOne:
+	default:
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+	if playQueueMeta.CurrentIndex < -1 || playQueueMeta.CurrentIndex >= int64(len(playQueueMeta.SongIds)) || playQueueMeta.Position < 0 {
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+	if playQueueMeta.SongIds == nil {
+		playQueueMeta.SongIds = []restApiV1.SongId{}
+	}
+
+	playQueue, err := s.store.UpdatePlayQueue(nil, user.Id, &playQueueMeta)
+	if err != nil {
+		s.log.Panicf("Unable to update the play queue: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, playQueue)
+}
@@ -3,6 +3,7 @@ package restSrvV1
 import (
 	"encoding/json"
 	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
 	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
@@ -55,7 +56,14 @@ func (s *RestServer) createPlaylist(w http.ResponseWriter, r *http.Request) {
 		s.log.Panicf("Unable to interpret data to create the playlist: %v", err)
 	}
 
-	playlist, err := s.store.CreatePlaylist(nil, &playlistMeta, true)
+	var playlist *restApiV1.Playlist
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		playlist, err = s.store.CreatePlaylist(txn, &playlistMeta, true)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypePlaylist, string(playlist.Id), nil, &playlist.PlaylistMeta))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to create the playlist: %v", err)
 	}
@@ -77,12 +85,28 @@ func (s *RestServer) updatePlaylist(w http.ResponseWriter, r *http.Request) {
 		s.log.Panicf("Unable to interpret data to update the playlist: %v", err)
 	}
 
-	playlist, err := s.store.UpdatePlaylist(nil, playlistId, &playlistMeta, true)
+	var updatedPlaylist *restApiV1.Playlist
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		playlist, err := s.store.ReadPlaylist(txn, playlistId)
+		if err != nil {
+			return err
+		}
+
+		updatedPlaylist, err = s.store.UpdatePlaylist(txn, playlistId, &playlistMeta, true)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypePlaylist, string(playlistId), &playlist.PlaylistMeta, &updatedPlaylist.PlaylistMeta))
+	})
 	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
 		s.log.Panicf("Unable to update the playlist: %v", err)
 	}
 
-	tool.WriteJsonResponse(w, playlist)
+	tool.WriteJsonResponse(w, updatedPlaylist)
 
 }
 
@@ -93,7 +117,15 @@ func (s *RestServer) deletePlaylist(w http.ResponseWriter, r *http.Request) {
 
 	s.log.Debugf("Delete playlist: %s", playlistId)
 
-	playlist, err := s.store.DeletePlaylist(nil, playlistId)
+	var playlist *restApiV1.Playlist
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		playlist, err = s.store.DeletePlaylist(txn, playlistId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypePlaylist, string(playlist.Id), &playlist.PlaylistMeta, nil))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to delete playlist: %v", err)
 	}
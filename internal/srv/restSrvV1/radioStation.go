@@ -0,0 +1,114 @@
+package restSrvV1
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+)
+
+func (s *RestServer) readRadioStations(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read radio stations")
+
+	radioStations, err := s.store.ReadRadioStations(nil)
+	if err != nil {
+		s.log.Panicf("Unable to read radio stations: %v", err)
+	}
+
+	for ind := range radioStations {
+		s.radioSrv.FillState(&radioStations[ind])
+	}
+
+	tool.WriteJsonResponse(w, radioStations)
+}
+
+func (s *RestServer) createRadioStation(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Create radio station")
+
+	if !s.ConnectedUser(r).AdminFg {
+		s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+		return
+	}
+
+	var radioStationNew restApiV1.RadioStationNew
+	err := json.NewDecoder(r.Body).Decode(&radioStationNew)
+	if err != nil {
+		s.log.Panicf("Unable to interpret data to create the radio station: %v", err)
+	}
+
+	switch radioStationNew.Format {
+	case "":
+		radioStationNew.Format = restApiV1.RadioStationFormatMp3
+	case restApiV1.RadioStationFormatMp3, restApiV1.RadioStationFormatOgg:
+	default:
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+
+	if radioStationNew.Bitrate == 0 {
+		radioStationNew.Bitrate = restApiV1.RadioStationDefaultBitrate
+	}
+	if radioStationNew.Bitrate < restApiV1.RadioStationMinBitrate || radioStationNew.Bitrate > restApiV1.RadioStationMaxBitrate {
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+
+	var radioStation *restApiV1.RadioStation
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		radioStation, err = s.store.CreateRadioStation(txn, &radioStationNew)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeRadioStation, string(radioStation.PlaylistId), nil, &radioStation.RadioStationMeta))
+	})
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to create the radio station: %v", err)
+	}
+
+	s.radioSrv.StartStation(radioStation)
+	s.radioSrv.FillState(radioStation)
+
+	w.WriteHeader(http.StatusCreated)
+	tool.WriteJsonResponse(w, radioStation)
+}
+
+func (s *RestServer) deleteRadioStation(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	playlistId := restApiV1.PlaylistId(vars["playlistId"])
+
+	s.log.Debugf("Delete radio station: %s", playlistId)
+
+	if !s.ConnectedUser(r).AdminFg {
+		s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+		return
+	}
+
+	var radioStation *restApiV1.RadioStation
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		radioStation, err = s.store.DeleteRadioStation(txn, playlistId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeRadioStation, string(radioStation.PlaylistId), &radioStation.RadioStationMeta, nil))
+	})
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to delete radio station: %v", err)
+	}
+
+	s.radioSrv.StopStation(playlistId)
+
+	tool.WriteJsonResponse(w, radioStation)
+}
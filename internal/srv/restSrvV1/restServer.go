@@ -1,9 +1,15 @@
 package restSrvV1
 
 import (
+	"context"
+	"crypto/rand"
 	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/srv/metrics"
+	"github.com/jypelle/mifasol/internal/srv/radioSrv"
 	"github.com/jypelle/mifasol/internal/srv/store"
 	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
 	"github.com/sirupsen/logrus"
 	"net/http"
@@ -11,28 +17,57 @@ import (
 	"sync"
 )
 
+type contextKey int
+
+const contextKeyUser contextKey = iota
+
+// Routes reachable without access token: the token generation and the shares opened without account
+var publicRouteNames = map[string]bool{
+	"apiToken":            true,
+	"apiShareAccess":      true,
+	"apiShareContent":     true,
+	"apiShareSongContent": true,
+}
+
 type RestServer struct {
 	store     *store.Store
+	radioSrv  *radioSrv.RadioServer
+	limiter   *limiter.Limiter
 	subRouter *mux.Router
 
 	sessionMap sync.Map
 
+	// Signs the share access tokens, which are lost on restart
+	shareAccessKey []byte
+
+	deviceRegistry deviceRegistry
+
+	// Closed to end the event streams
+	stopCh chan struct{}
+
 	log *logrus.Entry
 }
 
-func NewRestServer(store *store.Store, subRouter *mux.Router) *RestServer {
+func NewRestServer(store *store.Store, radioServer *radioSrv.RadioServer, limiter *limiter.Limiter, subRouter *mux.Router) *RestServer {
 
 	restServer := &RestServer{
 		store:     store,
+		radioSrv:  radioServer,
+		limiter:   limiter,
 		subRouter: subRouter,
+		stopCh:    make(chan struct{}),
 		log:       logrus.WithField("origin", "rest"),
 	}
+	restServer.deviceRegistry.devices = make(map[restApiV1.DeviceId]*connectedDevice)
+	restServer.shareAccessKey = make([]byte, 32)
+	rand.Read(restServer.shareAccessKey)
 
-	restServer.subRouter.HandleFunc("/token", restServer.generateToken).Methods("POST")
+	restServer.subRouter.HandleFunc("/token", restServer.GenerateToken).Methods("POST").Name("apiToken")
 
 	restServer.subRouter.HandleFunc("/albums", restServer.readAlbums).Methods("GET")
 	restServer.subRouter.HandleFunc("/albums", restServer.readAlbums).Methods("POST").Headers("x-http-method-override", "GET")
 	restServer.subRouter.HandleFunc("/albums/{id}", restServer.readAlbum).Methods("GET")
+	restServer.subRouter.HandleFunc("/albums/{id}/archive", restServer.readAlbumArchive).Methods("GET")
 	restServer.subRouter.HandleFunc("/albums", restServer.createAlbum).Methods("POST")
 	restServer.subRouter.HandleFunc("/albums/{id}", restServer.updateAlbum).Methods("PUT")
 	restServer.subRouter.HandleFunc("/albums/{id}", restServer.deleteAlbum).Methods("DELETE")
@@ -47,6 +82,7 @@ func NewRestServer(store *store.Store, subRouter *mux.Router) *RestServer {
 	restServer.subRouter.HandleFunc("/playlists", restServer.readPlaylists).Methods("GET")
 	restServer.subRouter.HandleFunc("/playlists", restServer.readPlaylists).Methods("POST").Headers("x-http-method-override", "GET")
 	restServer.subRouter.HandleFunc("/playlists/{id}", restServer.readPlaylist).Methods("GET")
+	restServer.subRouter.HandleFunc("/playlists/{id}/archive", restServer.readPlaylistArchive).Methods("GET")
 	restServer.subRouter.HandleFunc("/playlists", restServer.createPlaylist).Methods("POST")
 	restServer.subRouter.HandleFunc("/playlists/{id}", restServer.updatePlaylist).Methods("PUT")
 	restServer.subRouter.HandleFunc("/playlists/{id}", restServer.deletePlaylist).Methods("DELETE")
@@ -54,13 +90,20 @@ func NewRestServer(store *store.Store, subRouter *mux.Router) *RestServer {
 	restServer.subRouter.HandleFunc("/songs", restServer.readSongs).Methods("GET")
 	restServer.subRouter.HandleFunc("/songs", restServer.readSongs).Methods("POST").Headers("x-http-method-override", "GET")
 	restServer.subRouter.HandleFunc("/songs/{id}", restServer.readSong).Methods("GET")
-	restServer.subRouter.HandleFunc("/songContents/{id}", restServer.readSongContent).Methods("GET")
+	restServer.subRouter.HandleFunc("/songContents/{id}", restServer.ReadSongContent).Methods("GET")
 	restServer.subRouter.HandleFunc("/songContents", restServer.createSongContent).Methods("POST")
 	restServer.subRouter.HandleFunc("/songContentsForAlbum/{id}", restServer.createSongContentForAlbum).Methods("POST")
 	restServer.subRouter.HandleFunc("/songWithContents", restServer.createSongWithContent).Methods("POST")
 	restServer.subRouter.HandleFunc("/songs/{id}", restServer.updateSong).Methods("PUT")
+	restServer.subRouter.HandleFunc("/songs", restServer.updateSongs).Methods("PATCH")
 	restServer.subRouter.HandleFunc("/songs/{id}", restServer.deleteSong).Methods("DELETE")
 
+	restServer.subRouter.HandleFunc("/uploads/{id}", restServer.readUpload).Methods("GET")
+	restServer.subRouter.HandleFunc("/uploads", restServer.createUpload).Methods("POST")
+	restServer.subRouter.HandleFunc("/uploads/{id}/content", restServer.updateUploadContent).Methods("PUT")
+	restServer.subRouter.HandleFunc("/uploads/{id}/song", restServer.createUploadSong).Methods("POST")
+	restServer.subRouter.HandleFunc("/uploads/{id}", restServer.deleteUpload).Methods("DELETE")
+
 	restServer.subRouter.HandleFunc("/users", restServer.readUsers).Methods("GET")
 	restServer.subRouter.HandleFunc("/users", restServer.readUsers).Methods("POST").Headers("x-http-method-override", "GET")
 	restServer.subRouter.HandleFunc("/users/{id}", restServer.readUser).Methods("GET")
@@ -78,8 +121,38 @@ func NewRestServer(store *store.Store, subRouter *mux.Router) *RestServer {
 	restServer.subRouter.HandleFunc("/favoriteSongs", restServer.createFavoriteSong).Methods("POST")
 	restServer.subRouter.HandleFunc("/favoriteSongs/{userId}/{songId}", restServer.deleteFavoriteSong).Methods("DELETE")
 
-	restServer.subRouter.HandleFunc("/syncReport/{fromTs}", restServer.readSyncReport).Methods("GET")
-	restServer.subRouter.HandleFunc("/fileSyncReport/{fromTs}/{userId}", restServer.readFileSyncReport).Methods("GET")
+	restServer.subRouter.HandleFunc("/playQueue", restServer.readPlayQueue).Methods("GET")
+	restServer.subRouter.HandleFunc("/playQueue", restServer.updatePlayQueue).Methods("PUT")
+
+	restServer.subRouter.HandleFunc("/devices", restServer.readDevices).Methods("GET")
+	restServer.subRouter.HandleFunc("/devices/{id}/commands", restServer.readDeviceCommands).Methods("GET")
+	restServer.subRouter.HandleFunc("/devices/{id}/commands", restServer.createDeviceCommand).Methods("POST")
+	restServer.subRouter.HandleFunc("/devices/{id}/state", restServer.updateDeviceState).Methods("PUT")
+
+	restServer.subRouter.HandleFunc("/shares", restServer.readShares).Methods("GET")
+	restServer.subRouter.HandleFunc("/shares", restServer.createShare).Methods("POST")
+	restServer.subRouter.HandleFunc("/shares/{id}", restServer.deleteShare).Methods("DELETE")
+	restServer.subRouter.HandleFunc("/share/{id}/access", restServer.createShareAccess).Methods("POST").Name("apiShareAccess")
+	restServer.subRouter.HandleFunc("/share/{id}", restServer.readShareContent).Methods("GET").Name("apiShareContent")
+	restServer.subRouter.HandleFunc("/share/{id}/songContents/{songId}", restServer.readShareSongContent).Methods("GET").Name("apiShareSongContent")
+
+	restServer.subRouter.HandleFunc("/trashItems", restServer.readTrashItems).Methods("GET")
+	restServer.subRouter.HandleFunc("/trashItems/{id}", restServer.readTrashItem).Methods("GET")
+	restServer.subRouter.HandleFunc("/trashItems/{id}/restore", restServer.restoreTrashItem).Methods("POST")
+	restServer.subRouter.HandleFunc("/trashItems/{id}", restServer.deleteTrashItem).Methods("DELETE")
+
+	restServer.subRouter.HandleFunc("/radioStations", restServer.readRadioStations).Methods("GET")
+	restServer.subRouter.HandleFunc("/radioStations", restServer.createRadioStation).Methods("POST")
+	restServer.subRouter.HandleFunc("/radioStations/{playlistId}", restServer.deleteRadioStation).Methods("DELETE")
+
+	restServer.subRouter.HandleFunc("/auditEntries", restServer.readAuditEntries).Methods("GET")
+	restServer.subRouter.HandleFunc("/auditEntries", restServer.readAuditEntries).Methods("POST").Headers("x-http-method-override", "GET")
+
+	restServer.subRouter.HandleFunc("/events", restServer.readEvents).Methods("GET")
+
+	restServer.subRouter.HandleFunc("/syncReport/{fromRevision}", restServer.readSyncReport).Methods("GET")
+	restServer.subRouter.HandleFunc("/syncReportPage/{fromRevision}", restServer.readSyncReportPage).Methods("GET")
+	restServer.subRouter.HandleFunc("/fileSyncReport/{fromRevision}/{userId}", restServer.readFileSyncReport).Methods("GET")
 
 	restServer.subRouter.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		restServer.apiErrorCodeResponse(w, restApiV1.MethodNotAllowedErrorCode)
@@ -88,6 +161,21 @@ func NewRestServer(store *store.Store, subRouter *mux.Router) *RestServer {
 		restServer.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
 	})
 
+	// Compress the responses of the clients accepting it
+	restServer.subRouter.Use(tool.CompressHandler)
+
+	// Count the requests per route
+	restServer.subRouter.Use(func(handler http.Handler) http.Handler {
+		return metrics.RequestHandler(handler, func(r *http.Request) string {
+			route := mux.CurrentRoute(r)
+			if route == nil {
+				return ""
+			}
+			pathTemplate, _ := route.GetPathTemplate()
+			return pathTemplate
+		})
+	})
+
 	restServer.subRouter.Use(func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
@@ -97,48 +185,24 @@ func NewRestServer(store *store.Store, subRouter *mux.Router) *RestServer {
 				}
 			}()
 
-			// Check Token
-			if r.URL.Path != "/api/v1/token" {
-				var accessToken string
-
-				reqToken := r.Header.Get("Authorization")
-				if reqToken != "" {
-					splitToken := strings.Split(reqToken, "Bearer")
-					if len(splitToken) == 2 {
-						accessToken = strings.Trim(splitToken[1], " ")
-					}
-				} else {
-					reqTokens, ok := r.URL.Query()["bearer"]
-					if ok || len(reqTokens) == 1 {
-						accessToken = reqTokens[0]
-					}
-				}
-
-				if accessToken == "" {
-					restServer.apiErrorCodeResponse(w, restApiV1.InvalidTokenErrorCode)
-					return
-				}
-
-				restServer.log.Debugln("Check token " + accessToken + " for " + r.URL.Path)
+			// Requests are limited per user, or per IP without account
+			rateLimitKey := "ip:" + tool.ClientIp(r)
 
-				ses, ok := restServer.sessionMap.Load(accessToken)
-				if ok != true {
+			// Check Token, except for the public routes
+			if route := mux.CurrentRoute(r); route == nil || !publicRouteNames[route.GetName()] {
+				var user *restApiV1.User
+				r, user = restServer.Authenticate(r)
+				if user == nil {
 					restServer.apiErrorCodeResponse(w, restApiV1.InvalidTokenErrorCode)
 					return
 				}
+				rateLimitKey = "user:" + string(user.Id)
 
-				user, err := restServer.store.ReadUser(nil, ses.(*session).userId)
-				if err != nil {
-					if err == storeerror.ErrNotFound {
-						restServer.apiErrorCodeResponse(w, restApiV1.InvalidTokenErrorCode)
-						return
-					}
-					restServer.apiErrorCodeResponse(w, restApiV1.InternalErrorCode)
-					return
-				}
-				restServer.log.Debugln("User: " + user.Name)
-				//			context.WithValue(r.Context(), contextKeyUser, user)
+			}
 
+			if ok, retryAfter := restServer.limiter.AllowRequest("rest", rateLimitKey); !ok {
+				restServer.tooManyRequestsResponse(w, retryAfter)
+				return
 			}
 
 			handler.ServeHTTP(w, r)
@@ -147,3 +211,64 @@ func NewRestServer(store *store.Store, subRouter *mux.Router) *RestServer {
 
 	return restServer
 }
+
+// Stop ends the event streams, which would otherwise delay the http server shutdown
+func (s *RestServer) Stop() {
+	close(s.stopCh)
+}
+
+// SessionCount returns the number of access tokens delivered since the server start
+func (s *RestServer) SessionCount() int {
+	count := 0
+	s.sessionMap.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// Authenticate checks the access token sent in the Authorization header or the bearer query parameter.
+// It returns the request carrying the authenticated user, or a nil user when the token is missing or invalid.
+func (s *RestServer) Authenticate(r *http.Request) (*http.Request, *restApiV1.User) {
+	var accessToken string
+
+	reqToken := r.Header.Get("Authorization")
+	if reqToken != "" {
+		splitToken := strings.Split(reqToken, "Bearer")
+		if len(splitToken) == 2 {
+			accessToken = strings.Trim(splitToken[1], " ")
+		}
+	} else {
+		reqTokens, ok := r.URL.Query()["bearer"]
+		if ok || len(reqTokens) == 1 {
+			accessToken = reqTokens[0]
+		}
+	}
+
+	if accessToken == "" {
+		return r, nil
+	}
+
+	s.log.Debugln("Check token " + accessToken + " for " + r.URL.Path)
+
+	ses, ok := s.sessionMap.Load(accessToken)
+	if ok != true {
+		return r, nil
+	}
+
+	user, err := s.store.ReadUser(nil, ses.(*session).userId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			return r, nil
+		}
+		s.log.Panicf("Unable to read user: %v", err)
+	}
+	s.log.Debugln("User: " + user.Name)
+
+	return r.WithContext(context.WithValue(r.Context(), contextKeyUser, user)), user
+}
+
+// ConnectedUser returns the user authenticated by the access token
+func (s *RestServer) ConnectedUser(r *http.Request) *restApiV1.User {
+	return r.Context().Value(contextKeyUser).(*restApiV1.User)
+}
@@ -0,0 +1,372 @@
+package restSrvV1
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/metrics"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	shareAccessDuration   = time.Hour
+	shareAccessCookieName = "mifasolShareAccess"
+)
+
+func (s *RestServer) readShares(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read shares")
+
+	// Admin can list every share
+	var filter restApiV1.ShareFilter
+	connectedUser := s.ConnectedUser(r)
+	if !connectedUser.AdminFg {
+		filter.OwnerUserId = &connectedUser.Id
+	}
+
+	shares, err := s.store.ReadShares(nil, &filter)
+	if err != nil {
+		s.log.Panicf("Unable to read shares: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, shares)
+}
+
+func (s *RestServer) createShare(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Create share")
+
+	var shareNew restApiV1.ShareNew
+	err := json.NewDecoder(r.Body).Decode(&shareNew)
+	if err != nil {
+		s.log.Panicf("Unable to interpret data to create the share: %v", err)
+	}
+
+	if shareNew.ExpirationTs != nil && *shareNew.ExpirationTs <= time.Now().UnixNano() {
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+
+	connectedUser := s.ConnectedUser(r)
+
+	switch shareNew.ItemType {
+	case restApiV1.ShareItemTypePlaylist:
+		// Only the owners of a playlist can share it
+		playlist, err := s.store.ReadPlaylist(nil, restApiV1.PlaylistId(shareNew.ItemId))
+		if err != nil {
+			if err == storeerror.ErrNotFound {
+				s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+				return
+			}
+			s.log.Panicf("Unable to read the playlist: %v", err)
+		}
+		if !connectedUser.AdminFg && !playlist.IsOwnedBy(connectedUser.Id) {
+			s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+			return
+		}
+	case restApiV1.ShareItemTypeAlbum:
+		if restApiV1.AlbumId(shareNew.ItemId) == restApiV1.UnknownAlbumId {
+			s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+			return
+		}
+	case restApiV1.ShareItemTypeSong:
+	default:
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+
+	var share *restApiV1.Share
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		share, err = s.store.CreateShare(txn, connectedUser.Id, &shareNew)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeShare, string(share.Id), nil, &share.ShareMeta))
+	})
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to create the share: %v", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	tool.WriteJsonResponse(w, share)
+}
+
+func (s *RestServer) deleteShare(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	shareId := restApiV1.ShareId(vars["id"])
+
+	s.log.Debugf("Delete share: %s", shareId)
+
+	share, err := s.store.ReadShare(nil, shareId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read share: %v", err)
+	}
+
+	connectedUser := s.ConnectedUser(r)
+	if !connectedUser.AdminFg && share.OwnerUserId != connectedUser.Id {
+		s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+		return
+	}
+
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		share, err = s.store.DeleteShare(txn, shareId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeShare, string(share.Id), &share.ShareMeta, nil))
+	})
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to delete share: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, share)
+}
+
+// createShareAccess checks the password of a share, sent in the request body, and delivers a short-lived access token
+func (s *RestServer) createShareAccess(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	shareId := restApiV1.ShareId(vars["id"])
+
+	s.log.Debugf("Create share access: %s", shareId)
+
+	share, err := s.store.ReadShare(nil, shareId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read share: %v", err)
+	}
+
+	if share.IsExpired(time.Now().UnixNano()) {
+		s.apiErrorCodeResponse(w, restApiV1.ExpiredShareErrorCode)
+		return
+	}
+
+	// The password is never read from the query string
+	var shareAccessNew restApiV1.ShareAccessNew
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		err = json.NewDecoder(r.Body).Decode(&shareAccessNew)
+	} else {
+		err = r.ParseForm()
+		shareAccessNew.Password = r.PostForm.Get("password")
+	}
+	if err != nil {
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+
+	if share.PasswordFg {
+		ip := tool.ClientIp(r)
+		account := "share:" + string(shareId)
+		if retryAfter := s.limiter.CheckLogin(ip, account); retryAfter > 0 {
+			s.tooManyRequestsResponse(w, retryAfter)
+			return
+		}
+
+		ok, err := s.store.CheckSharePassword(nil, shareId, shareAccessNew.Password)
+		if err != nil {
+			s.log.Panicf("Unable to check share password: %v", err)
+		}
+		if !ok {
+			s.limiter.LoginFailed("share", ip, account)
+			s.apiErrorCodeResponse(w, restApiV1.InvalidSharePasswordErrorCode)
+			return
+		}
+		s.limiter.LoginSucceeded(ip, account)
+	}
+
+	shareAccess := restApiV1.ShareAccess{ExpirationTs: time.Now().Add(shareAccessDuration).UnixNano()}
+	shareAccess.AccessToken = s.shareAccessToken(shareId, shareAccess.ExpirationTs)
+
+	// Browsers send the token back in a cookie restricted to the share urls, the audio players being unable to set headers
+	cookie := &http.Cookie{
+		Name:     shareAccessCookieName,
+		Value:    shareAccess.AccessToken,
+		MaxAge:   int(shareAccessDuration / time.Second),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+	if shareUrl, err := s.subRouter.Get("apiShareContent").URLPath("id", string(shareId)); err == nil {
+		cookie.Path = shareUrl.Path
+	}
+	http.SetCookie(w, cookie)
+
+	tool.WriteJsonResponse(w, shareAccess)
+}
+
+// shareAccessToken signs the access to shareId until expirationTs
+func (s *RestServer) shareAccessToken(shareId restApiV1.ShareId, expirationTs int64) string {
+	mac := hmac.New(sha256.New, s.shareAccessKey)
+	mac.Write([]byte(string(shareId) + "." + strconv.FormatInt(expirationTs, 10)))
+	return strconv.FormatInt(expirationTs, 10) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkShareAccessToken tells if accessToken still gives access to shareId
+func (s *RestServer) checkShareAccessToken(shareId restApiV1.ShareId, accessToken string) bool {
+	expiration := strings.SplitN(accessToken, ".", 2)[0]
+	expirationTs, err := strconv.ParseInt(expiration, 10, 64)
+	if err != nil || expirationTs <= time.Now().UnixNano() {
+		return false
+	}
+	return hmac.Equal([]byte(accessToken), []byte(s.shareAccessToken(shareId, expirationTs)))
+}
+
+// readShareContent lists the shared songs for an anonymous listener
+func (s *RestServer) readShareContent(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	shareId := restApiV1.ShareId(vars["id"])
+
+	s.log.Debugf("Read share content: %s", shareId)
+
+	shareContent, ok := s.openShare(w, r, shareId)
+	if !ok {
+		return
+	}
+
+	tool.WriteJsonResponse(w, shareContent)
+}
+
+// readShareSongContent streams a shared song for an anonymous listener
+func (s *RestServer) readShareSongContent(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	shareId := restApiV1.ShareId(vars["id"])
+	songId := restApiV1.SongId(vars["songId"])
+
+	s.log.Debugf("Read share song content: %s / %s", shareId, songId)
+
+	shareContent, ok := s.openShare(w, r, shareId)
+	if !ok {
+		return
+	}
+
+	// Only the shared songs are reachable
+	shared := false
+	for _, shareSong := range shareContent.Songs {
+		if shareSong.Id == songId {
+			shared = true
+			break
+		}
+	}
+	if !shared {
+		s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+		return
+	}
+
+	song, err := s.store.ReadSong(nil, songId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read song: %v", err)
+	}
+
+	contentDisposition := ""
+	if r.URL.Query().Get("download") == "true" {
+		if !shareContent.DownloadFg {
+			s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+			return
+		}
+		contentDisposition = mime.FormatMediaType("attachment", map[string]string{"filename": song.Name + song.Format.Extension()})
+		w.Header().Set("Content-Disposition", contentDisposition)
+	}
+
+	// Let the listener read the song content directly from the storage
+	songContentUrl, err := s.store.ReadSongContentUrl(song, contentDisposition)
+	if err != nil {
+		s.log.Panicf("Unable to presign song content url: %v", err)
+	}
+	if songContentUrl != "" {
+		http.Redirect(w, r, songContentUrl, http.StatusTemporaryRedirect)
+		return
+	}
+
+	release, retryAfter, ok := s.limiter.AcquireStream("share", "ip:"+tool.ClientIp(r))
+	if !ok {
+		s.tooManyRequestsResponse(w, retryAfter)
+		return
+	}
+	defer release()
+
+	songContent, err := s.store.ReadSongContent(song)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read song content: %v", err)
+	}
+
+	w.Header().Set("Content-Type", song.Format.MimeType())
+	streamWriter, streamDone := metrics.TrackStream(w, "share")
+	defer streamDone()
+	http.ServeContent(streamWriter, r, "", time.Unix(0, song.UpdateTs), songContent)
+	songContent.Close()
+}
+
+// openShare checks the share expiration and access token, then returns the shared content
+func (s *RestServer) openShare(w http.ResponseWriter, r *http.Request, shareId restApiV1.ShareId) (*restApiV1.ShareContent, bool) {
+	share, err := s.store.ReadShare(nil, shareId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return nil, false
+		}
+		s.log.Panicf("Unable to read share: %v", err)
+	}
+
+	if share.IsExpired(time.Now().UnixNano()) {
+		s.apiErrorCodeResponse(w, restApiV1.ExpiredShareErrorCode)
+		return nil, false
+	}
+
+	// A share protected by a password is opened with the access token given by createShareAccess
+	if share.PasswordFg {
+		accessToken := r.URL.Query().Get("accessToken")
+		if cookie, err := r.Cookie(shareAccessCookieName); err == nil && accessToken == "" {
+			accessToken = cookie.Value
+		}
+		if !s.checkShareAccessToken(shareId, accessToken) {
+			s.apiErrorCodeResponse(w, restApiV1.InvalidSharePasswordErrorCode)
+			return nil, false
+		}
+	}
+
+	shareContent, err := s.store.ReadShareContent(nil, shareId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return nil, false
+		}
+		s.log.Panicf("Unable to read share content: %v", err)
+	}
+
+	return shareContent, true
+}
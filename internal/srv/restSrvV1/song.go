@@ -3,9 +3,12 @@ package restSrvV1
 import (
 	"encoding/json"
 	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/metrics"
 	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
+	"mime"
 	"net/http"
 	"time"
 )
@@ -46,7 +49,8 @@ func (s *RestServer) readSong(w http.ResponseWriter, r *http.Request) {
 	tool.WriteJsonResponse(w, song)
 }
 
-func (s *RestServer) readSongContent(w http.ResponseWriter, r *http.Request) {
+// ReadSongContent streams the content of the song given by the id route variable
+func (s *RestServer) ReadSongContent(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	songId := restApiV1.SongId(vars["id"])
@@ -62,6 +66,28 @@ func (s *RestServer) readSongContent(w http.ResponseWriter, r *http.Request) {
 		s.log.Panicf("Unable to read song: %v", err)
 	}
 
+	contentDisposition := ""
+	if r.URL.Query().Get("download") == "true" {
+		contentDisposition = mime.FormatMediaType("attachment", map[string]string{"filename": song.Name + song.Format.Extension()})
+	}
+
+	// Let the client read the song content directly from the storage
+	songContentUrl, err := s.store.ReadSongContentUrl(song, contentDisposition)
+	if err != nil {
+		s.log.Panicf("Unable to presign song content url: %v", err)
+	}
+	if songContentUrl != "" {
+		http.Redirect(w, r, songContentUrl, http.StatusTemporaryRedirect)
+		return
+	}
+
+	release, retryAfter, ok := s.limiter.AcquireStream("rest", "user:"+string(s.ConnectedUser(r).Id))
+	if !ok {
+		s.tooManyRequestsResponse(w, retryAfter)
+		return
+	}
+	defer release()
+
 	songContent, err := s.store.ReadSongContent(song)
 	if err != nil {
 		if err == storeerror.ErrNotFound {
@@ -72,15 +98,27 @@ func (s *RestServer) readSongContent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", song.Format.MimeType())
-	http.ServeContent(w, r, "", time.Unix(0, song.UpdateTs), songContent)
+	if contentDisposition != "" {
+		w.Header().Set("Content-Disposition", contentDisposition)
+	}
+	streamWriter, streamDone := metrics.TrackStream(w, "rest")
+	defer streamDone()
+	http.ServeContent(streamWriter, r, "", time.Unix(0, song.UpdateTs), songContent)
 	songContent.Close()
 }
 
 func (s *RestServer) createSongContent(w http.ResponseWriter, r *http.Request) {
 	s.log.Debugf("Create song from raw content")
 
-	song, err := s.store.CreateSongFromRawContent(nil, r.Body, restApiV1.UnknownAlbumId)
-
+	var song *restApiV1.Song
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		song, err = s.store.CreateSongFromRawContent(txn, r.Body, restApiV1.UnknownAlbumId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeSong, string(song.Id), nil, &song.SongMeta))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to create the song: %v", err)
 	}
@@ -95,8 +133,15 @@ func (s *RestServer) createSongContentForAlbum(w http.ResponseWriter, r *http.Re
 	vars := mux.Vars(r)
 	lastAlbumId := restApiV1.AlbumId(vars["id"])
 
-	song, err := s.store.CreateSongFromRawContent(nil, r.Body, lastAlbumId)
-
+	var song *restApiV1.Song
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		song, err = s.store.CreateSongFromRawContent(txn, r.Body, lastAlbumId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeSong, string(song.Id), nil, &song.SongMeta))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to create the song: %v", err)
 	}
@@ -123,15 +168,76 @@ func (s *RestServer) updateSong(w http.ResponseWriter, r *http.Request) {
 		s.log.Panicf("Unable to interpret data to update the song: %v", err)
 	}
 
-	song, err := s.store.UpdateSong(nil, songId, &songMeta, nil, true)
+	var updatedSong *restApiV1.Song
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		song, err := s.store.ReadSong(txn, songId)
+		if err != nil {
+			return err
+		}
+
+		updatedSong, err = s.store.UpdateSong(txn, songId, &songMeta, nil, true)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypeSong, string(songId), &song.SongMeta, &updatedSong.SongMeta))
+	})
 	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
 		s.log.Panicf("Unable to update the song: %v", err)
 	}
 
-	tool.WriteJsonResponse(w, song)
+	tool.WriteJsonResponse(w, updatedSong)
 
 }
 
+func (s *RestServer) updateSongs(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Update songs")
+
+	var songsPatch restApiV1.SongsPatch
+	err := json.NewDecoder(r.Body).Decode(&songsPatch)
+	if err != nil {
+		s.log.Panicf("Unable to interpret data to update the songs: %v", err)
+	}
+
+	// Read the songs before their update and record the audit entries in the same transaction
+	var songs []restApiV1.Song
+	songMetas := make(map[restApiV1.SongId]*restApiV1.SongMeta)
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		for _, songId := range songsPatch.SongIds {
+			song, err := s.store.ReadSong(txn, songId)
+			if err != nil {
+				return err
+			}
+			songMetas[songId] = &song.SongMeta
+		}
+
+		songs, err = s.store.UpdateSongs(txn, &songsPatch)
+		if err != nil {
+			return err
+		}
+
+		for ind := range songs {
+			err = s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypeSong, string(songs[ind].Id), songMetas[songs[ind].Id], &songs[ind].SongMeta))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to update the songs: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, songs)
+}
+
 func (s *RestServer) deleteSong(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
@@ -139,7 +245,15 @@ func (s *RestServer) deleteSong(w http.ResponseWriter, r *http.Request) {
 
 	s.log.Debugf("Delete song: %s", songId)
 
-	song, err := s.store.DeleteSong(nil, songId)
+	var song *restApiV1.Song
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		song, err = s.store.DeleteSong(txn, songId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeSong, string(song.Id), &song.SongMeta, nil))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to delete song: %v", err)
 	}
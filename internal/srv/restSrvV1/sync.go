@@ -2,6 +2,7 @@ package restSrvV1
 
 import (
 	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
 	"net/http"
@@ -12,14 +13,14 @@ func (s *RestServer) readSyncReport(w http.ResponseWriter, r *http.Request) {
 	s.log.Debugf("Read sync report")
 
 	vars := mux.Vars(r)
-	fromTs, err := strconv.ParseInt(vars["fromTs"], 10, 64)
+	fromRevision, err := strconv.ParseInt(vars["fromRevision"], 10, 64)
 	if err != nil {
-		s.log.Warningf("Unable to interpret timestamp: %v", err)
+		s.log.Warningf("Unable to interpret revision: %v", err)
 		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
 		return
 	}
 
-	syncReport, err := s.store.ReadSyncReport(fromTs)
+	syncReport, err := s.store.ReadSyncReport(fromRevision)
 	if err != nil {
 		s.log.Panicf("Unable to read sync report: %v", err)
 	}
@@ -31,18 +32,52 @@ func (s *RestServer) readFileSyncReport(w http.ResponseWriter, r *http.Request)
 	s.log.Debugf("Read file sync report")
 
 	vars := mux.Vars(r)
-	fromTs, err := strconv.ParseInt(vars["fromTs"], 10, 64)
+	fromRevision, err := strconv.ParseInt(vars["fromRevision"], 10, 64)
 	if err != nil {
-		s.log.Warningf("Unable to interpret timestamp: %v", err)
+		s.log.Warningf("Unable to interpret revision: %v", err)
 		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
 		return
 	}
 	userId := restApiV1.UserId(vars["userId"])
 
-	fileSyncReport, err := s.store.ReadFileSyncReport(fromTs, userId)
+	fileSyncReport, err := s.store.ReadFileSyncReport(fromRevision, userId)
 	if err != nil {
 		s.log.Panicf("Unable to read sync report: %v", err)
 	}
 
 	tool.WriteJsonResponse(w, fileSyncReport)
 }
+
+func (s *RestServer) readSyncReportPage(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read sync report page")
+
+	vars := mux.Vars(r)
+	fromRevision, err := strconv.ParseInt(vars["fromRevision"], 10, 64)
+	if err != nil {
+		s.log.Warningf("Unable to interpret revision: %v", err)
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+
+	size := int64(restApiV1.SyncReportPageDefaultSize)
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		size, err = strconv.ParseInt(limit, 10, 64)
+		if err != nil || size < 1 || size > restApiV1.SyncReportPageMaxSize {
+			s.log.Warningf("Unable to interpret limit: %s", limit)
+			s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+			return
+		}
+	}
+
+	syncReportPage, err := s.store.ReadSyncReportPage(fromRevision, r.URL.Query().Get("continuationToken"), size)
+	if err != nil {
+		if err == storeerror.ErrInvalidContinuationToken {
+			s.log.Warningf("Unable to interpret continuation token: %v", err)
+			s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read sync report page: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, syncReportPage)
+}
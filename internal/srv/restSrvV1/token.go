@@ -15,14 +15,20 @@ type session struct {
 	creationTs int64
 }
 
-func (s *RestServer) generateToken(w http.ResponseWriter, r *http.Request) {
+// GenerateToken delivers an access token, shared by the api versions
+func (s *RestServer) GenerateToken(w http.ResponseWriter, r *http.Request) {
 	s.log.Debugf("Generate token")
 
-	values := r.URL.Query()
+	// Credentials are expected in the form body, the query string being still accepted from older clients
+	err := r.ParseForm()
+	if err != nil {
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
 
-	grantType := values.Get("grant_type")
-	name := values.Get("username")
-	password := values.Get("password")
+	grantType := r.Form.Get("grant_type")
+	name := r.Form.Get("username")
+	password := r.Form.Get("password")
 
 	if grantType == "" || name == "" || password == "" {
 		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
@@ -34,9 +40,16 @@ func (s *RestServer) generateToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := tool.ClientIp(r)
+	if retryAfter := s.limiter.CheckLogin(ip, name); retryAfter > 0 {
+		s.tooManyRequestsResponse(w, retryAfter)
+		return
+	}
+
 	user, err := s.store.ReadUserByUserName(nil, name)
 	if err != nil {
 		if err == storeerror.ErrNotFound {
+			s.limiter.LoginFailed("rest", ip, name)
 			s.apiErrorCodeResponse(w, restApiV1.InvalideGrantErrorCode)
 			return
 		}
@@ -44,9 +57,11 @@ func (s *RestServer) generateToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if user.Password != password {
+		s.limiter.LoginFailed("rest", ip, name)
 		s.apiErrorCodeResponse(w, restApiV1.InvalideGrantErrorCode)
 		return
 	}
+	s.limiter.LoginSucceeded(ip, name)
 
 	b := make([]byte, 16)
 	rand.Read(b)
@@ -0,0 +1,120 @@
+package restSrvV1
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+)
+
+func (s *RestServer) readTrashItems(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read trash items")
+
+	if !s.ConnectedUser(r).AdminFg {
+		s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+		return
+	}
+
+	trashItems, err := s.store.ReadTrashItems(nil)
+	if err != nil {
+		s.log.Panicf("Unable to read trash items: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, trashItems)
+}
+
+func (s *RestServer) readTrashItem(w http.ResponseWriter, r *http.Request) {
+
+	if !s.ConnectedUser(r).AdminFg {
+		s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+		return
+	}
+
+	vars := mux.Vars(r)
+	trashItemId := restApiV1.TrashItemId(vars["id"])
+
+	s.log.Debugf("Read trash item: %s", trashItemId)
+
+	trashItem, err := s.store.ReadTrashItem(nil, trashItemId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read trash item: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, trashItem)
+}
+
+func (s *RestServer) restoreTrashItem(w http.ResponseWriter, r *http.Request) {
+
+	if !s.ConnectedUser(r).AdminFg {
+		s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+		return
+	}
+
+	vars := mux.Vars(r)
+	trashItemId := restApiV1.TrashItemId(vars["id"])
+
+	s.log.Debugf("Restore trash item: %s", trashItemId)
+
+	// The trash item types are audited entity types, the restored meta being the one recorded by the deletion
+	var trashItem *restApiV1.TrashItem
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		trashItem, err = s.store.RestoreTrashItem(txn, trashItemId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, &restApiV1.AuditEntry{
+			Action:     restApiV1.AuditActionRestore,
+			EntityType: restApiV1.AuditEntityType(trashItem.ItemType),
+			EntityId:   trashItem.ItemId,
+			EntityName: trashItem.Name,
+		})
+	})
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to restore trash item: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, trashItem)
+}
+
+func (s *RestServer) deleteTrashItem(w http.ResponseWriter, r *http.Request) {
+
+	if !s.ConnectedUser(r).AdminFg {
+		s.apiErrorCodeResponse(w, restApiV1.ForbiddenErrorCode)
+		return
+	}
+
+	vars := mux.Vars(r)
+	trashItemId := restApiV1.TrashItemId(vars["id"])
+
+	s.log.Debugf("Purge trash item: %s", trashItemId)
+
+	var trashItem *restApiV1.TrashItem
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		trashItem, err = s.store.DeleteTrashItem(txn, trashItemId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeTrashItem, string(trashItem.Id), trashItem, nil))
+	})
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to purge trash item: %v", err)
+	}
+
+	tool.WriteJsonResponse(w, trashItem)
+}
@@ -0,0 +1,197 @@
+package restSrvV1
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"strconv"
+)
+
+func (s *RestServer) readUpload(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	uploadId := restApiV1.UploadId(vars["id"])
+
+	s.log.Debugf("Read upload: %s", uploadId)
+
+	upload, ok := s.openUpload(w, r, uploadId)
+	if !ok {
+		return
+	}
+
+	tool.WriteJsonResponse(w, upload)
+}
+
+func (s *RestServer) createUpload(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Create upload")
+
+	var uploadMeta restApiV1.UploadMeta
+	err := json.NewDecoder(r.Body).Decode(&uploadMeta)
+	if err != nil {
+		s.log.Panicf("Unable to interpret data to create the upload: %v", err)
+	}
+
+	if uploadMeta.Size <= 0 {
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+	if uploadMeta.LastAlbumId == "" {
+		uploadMeta.LastAlbumId = restApiV1.UnknownAlbumId
+	}
+
+	upload, err := s.store.CreateUpload(nil, s.ConnectedUser(r).Id, &uploadMeta)
+	if err != nil {
+		s.log.Panicf("Unable to create the upload: %v", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	tool.WriteJsonResponse(w, upload)
+}
+
+// updateUploadContent appends a chunk to the upload
+func (s *RestServer) updateUploadContent(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	uploadId := restApiV1.UploadId(vars["id"])
+
+	s.log.Debugf("Update upload content: %s", uploadId)
+
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+
+	upload, ok := s.openUpload(w, r, uploadId)
+	if !ok {
+		return
+	}
+
+	if r.ContentLength > upload.Size-offset {
+		s.apiErrorCodeResponse(w, restApiV1.InvalideRequestErrorCode)
+		return
+	}
+
+	upload, err = s.store.AppendUploadContent(uploadId, offset, r.Body)
+	if err != nil {
+		switch err {
+		case storeerror.ErrNotFound:
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+		case storeerror.ErrUploadOffsetMismatch:
+			s.apiErrorCodeResponse(w, restApiV1.UploadOffsetMismatchErrorCode)
+		case storeerror.ErrUploadBusy:
+			s.apiErrorCodeResponse(w, restApiV1.UploadBusyErrorCode)
+		default:
+			// Client is gone, the received bytes are kept
+			s.log.Debugf("Unable to receive the whole upload chunk: %v", err)
+			if upload == nil {
+				s.apiErrorCodeResponse(w, restApiV1.InternalErrorCode)
+				return
+			}
+			tool.WriteJsonResponse(w, upload)
+		}
+		return
+	}
+
+	tool.WriteJsonResponse(w, upload)
+}
+
+// createUploadSong creates the song from the completed upload
+func (s *RestServer) createUploadSong(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	uploadId := restApiV1.UploadId(vars["id"])
+
+	s.log.Debugf("Create song from upload: %s", uploadId)
+
+	_, ok := s.openUpload(w, r, uploadId)
+	if !ok {
+		return
+	}
+
+	var song *restApiV1.Song
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		upload, err := s.store.ReadUpload(txn, uploadId)
+		if err != nil {
+			return err
+		}
+
+		song, err = s.store.CompleteUpload(txn, uploadId)
+		if err != nil {
+			return err
+		}
+
+		// A repeated call returns the song created by the first one
+		if upload.SongId != nil {
+			return nil
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeSong, string(song.Id), nil, &song.SongMeta))
+	})
+	if err != nil {
+		switch err {
+		case storeerror.ErrNotFound:
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+		case storeerror.ErrUploadIncomplete:
+			s.apiErrorCodeResponse(w, restApiV1.UploadIncompleteErrorCode)
+		case storeerror.ErrUploadBusy:
+			s.apiErrorCodeResponse(w, restApiV1.UploadBusyErrorCode)
+		default:
+			s.log.Panicf("Unable to create the song: %v", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	tool.WriteJsonResponse(w, song)
+}
+
+func (s *RestServer) deleteUpload(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	uploadId := restApiV1.UploadId(vars["id"])
+
+	s.log.Debugf("Delete upload: %s", uploadId)
+
+	_, ok := s.openUpload(w, r, uploadId)
+	if !ok {
+		return
+	}
+
+	upload, err := s.store.DeleteUpload(nil, uploadId)
+	if err != nil {
+		switch err {
+		case storeerror.ErrNotFound:
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+		case storeerror.ErrUploadBusy:
+			s.apiErrorCodeResponse(w, restApiV1.UploadBusyErrorCode)
+		default:
+			s.log.Panicf("Unable to delete upload: %v", err)
+		}
+		return
+	}
+
+	tool.WriteJsonResponse(w, upload)
+}
+
+// openUpload returns the upload, which is only reachable by its owner
+func (s *RestServer) openUpload(w http.ResponseWriter, r *http.Request, uploadId restApiV1.UploadId) (*restApiV1.Upload, bool) {
+	upload, err := s.store.ReadUpload(nil, uploadId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return nil, false
+		}
+		s.log.Panicf("Unable to read upload: %v", err)
+	}
+
+	if upload.UserId != s.ConnectedUser(r).Id {
+		s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+		return nil, false
+	}
+
+	return upload, true
+}
@@ -3,6 +3,7 @@ package restSrvV1
 import (
 	"encoding/json"
 	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
 	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
@@ -49,7 +50,14 @@ func (s *RestServer) createUser(w http.ResponseWriter, r *http.Request) {
 		s.log.Panicf("Unable to interpret data to create the user: %v", err)
 	}
 
-	user, err := s.store.CreateUser(nil, &userMetaComplete)
+	var user *restApiV1.User
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		user, err = s.store.CreateUser(txn, &userMetaComplete)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeUser, string(user.Id), nil, &restApiV1.UserMetaComplete{UserMeta: user.UserMeta, Password: userMetaComplete.Password}))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to create the user: %v", err)
 	}
@@ -71,12 +79,28 @@ func (s *RestServer) updateUser(w http.ResponseWriter, r *http.Request) {
 		s.log.Panicf("Unable to interpret data to update the user: %v", err)
 	}
 
-	user, err := s.store.UpdateUser(nil, userId, &userMetaComplete)
+	var updatedUser *restApiV1.User
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		user, err := s.store.ReadUser(txn, userId)
+		if err != nil {
+			return err
+		}
+
+		updatedUser, err = s.store.UpdateUser(txn, userId, &userMetaComplete)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypeUser, string(userId), &user.UserMeta, &restApiV1.UserMetaComplete{UserMeta: updatedUser.UserMeta, Password: userMetaComplete.Password}))
+	})
 	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV1.NotFoundErrorCode)
+			return
+		}
 		s.log.Panicf("Unable to update the user: %v", err)
 	}
 
-	tool.WriteJsonResponse(w, user)
+	tool.WriteJsonResponse(w, updatedUser)
 
 }
 
@@ -87,7 +111,15 @@ func (s *RestServer) deleteUser(w http.ResponseWriter, r *http.Request) {
 
 	s.log.Debugf("Delete user: %s", userId)
 
-	user, err := s.store.DeleteUser(nil, userId)
+	var user *restApiV1.User
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		user, err = s.store.DeleteUser(txn, userId)
+		if err != nil {
+			return err
+		}
+		return s.Audit(txn, r, NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeUser, string(user.Id), &user.UserMeta, nil))
+	})
 	if err != nil {
 		s.log.Panicf("Unable to delete user: %v", err)
 	}
@@ -0,0 +1,160 @@
+package restSrvV2
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/restSrvV1"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+	"path"
+)
+
+func (s *RestServer) readAlbums(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read albums")
+
+	q := newQueryReader(r)
+	albumFilter := restApiV1.AlbumFilter{
+		FromTs:       q.int64("fromTs"),
+		FromRevision: q.int64("fromRevision"),
+		Name:         q.string("name"),
+		OrderBy:      (*restApiV1.AlbumFilterOrderBy)(q.enum("orderBy", string(restApiV1.AlbumFilterOrderByName))),
+	}
+	albumPage := restApiV2.AlbumPage{Page: q.page()}
+	if !q.check(w, s) {
+		return
+	}
+
+	albums, err := s.store.ReadAlbums(nil, &albumFilter)
+	if err != nil {
+		s.log.Panicf("Unable to read albums: %v", err)
+	}
+
+	start, end := pageBounds(&albumPage.Page, len(albums))
+	albumPage.Items = append([]restApiV1.Album{}, albums[start:end]...)
+
+	s.writeJsonResponse(w, r, http.StatusOK, albumPage)
+}
+
+func (s *RestServer) readAlbum(w http.ResponseWriter, r *http.Request) {
+	albumId := restApiV1.AlbumId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Read album: %s", albumId)
+
+	album, err := s.store.ReadAlbum(nil, albumId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read album: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, album)
+}
+
+func (s *RestServer) createAlbum(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Create album")
+
+	var albumMeta restApiV1.AlbumMeta
+	if !s.decodeBody(w, r, &albumMeta) || !s.checkAlbumMeta(w, &albumMeta) {
+		return
+	}
+
+	var album *restApiV1.Album
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		album, err = s.store.CreateAlbum(txn, &albumMeta)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeAlbum, string(album.Id), nil, &album.AlbumMeta))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to create the album: %v", err)
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, string(album.Id)))
+	s.writeJsonResponse(w, r, http.StatusCreated, album)
+}
+
+func (s *RestServer) updateAlbum(w http.ResponseWriter, r *http.Request) {
+	albumId := restApiV1.AlbumId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Update album: %s", albumId)
+
+	var albumMeta restApiV1.AlbumMeta
+	if !s.decodeBody(w, r, &albumMeta) {
+		return
+	}
+
+	album, err := s.store.ReadAlbum(nil, albumId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read album: %v", err)
+	}
+
+	if !s.checkIfMatch(w, r, album) || !s.checkAlbumMeta(w, &albumMeta) {
+		return
+	}
+
+	var updatedAlbum *restApiV1.Album
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		updatedAlbum, err = s.store.UpdateAlbum(txn, albumId, &albumMeta)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypeAlbum, string(albumId), &album.AlbumMeta, &updatedAlbum.AlbumMeta))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to update the album: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, updatedAlbum)
+}
+
+func (s *RestServer) deleteAlbum(w http.ResponseWriter, r *http.Request) {
+	albumId := restApiV1.AlbumId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Delete album: %s", albumId)
+
+	album, err := s.store.ReadAlbum(nil, albumId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read album: %v", err)
+	}
+
+	if !s.checkIfMatch(w, r, album) {
+		return
+	}
+
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		album, err = s.store.DeleteAlbum(txn, albumId)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeAlbum, string(album.Id), &album.AlbumMeta, nil))
+	})
+	if err != nil {
+		if err == storeerror.ErrDeleteAlbumWithSongs {
+			s.apiErrorCodeResponse(w, restApiV2.DeleteAlbumWithSongsErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to delete album: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, album)
+}
+
+func (s *RestServer) checkAlbumMeta(w http.ResponseWriter, albumMeta *restApiV1.AlbumMeta) bool {
+	var fieldErrors fieldErrors
+	fieldErrors.checkName(albumMeta.Name)
+	return fieldErrors.check(w, s)
+}
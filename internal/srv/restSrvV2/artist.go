@@ -0,0 +1,161 @@
+package restSrvV2
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/restSrvV1"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+	"path"
+)
+
+func (s *RestServer) readArtists(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read artists")
+
+	q := newQueryReader(r)
+	artistFilter := restApiV1.ArtistFilter{
+		FromTs:       q.int64("fromTs"),
+		FromRevision: q.int64("fromRevision"),
+		Name:         q.string("name"),
+		SongId:       (*restApiV1.SongId)(q.string("songId")),
+		OrderBy:      (*restApiV1.ArtistFilterOrderBy)(q.enum("orderBy", string(restApiV1.ArtistFilterOrderByName))),
+	}
+	artistPage := restApiV2.ArtistPage{Page: q.page()}
+	if !q.check(w, s) {
+		return
+	}
+
+	artists, err := s.store.ReadArtists(nil, &artistFilter)
+	if err != nil {
+		s.log.Panicf("Unable to read artists: %v", err)
+	}
+
+	start, end := pageBounds(&artistPage.Page, len(artists))
+	artistPage.Items = append([]restApiV1.Artist{}, artists[start:end]...)
+
+	s.writeJsonResponse(w, r, http.StatusOK, artistPage)
+}
+
+func (s *RestServer) readArtist(w http.ResponseWriter, r *http.Request) {
+	artistId := restApiV1.ArtistId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Read artist: %s", artistId)
+
+	artist, err := s.store.ReadArtist(nil, artistId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read artist: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, artist)
+}
+
+func (s *RestServer) createArtist(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Create artist")
+
+	var artistMeta restApiV1.ArtistMeta
+	if !s.decodeBody(w, r, &artistMeta) || !s.checkArtistMeta(w, &artistMeta) {
+		return
+	}
+
+	var artist *restApiV1.Artist
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		artist, err = s.store.CreateArtist(txn, &artistMeta)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeArtist, string(artist.Id), nil, &artist.ArtistMeta))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to create the artist: %v", err)
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, string(artist.Id)))
+	s.writeJsonResponse(w, r, http.StatusCreated, artist)
+}
+
+func (s *RestServer) updateArtist(w http.ResponseWriter, r *http.Request) {
+	artistId := restApiV1.ArtistId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Update artist: %s", artistId)
+
+	var artistMeta restApiV1.ArtistMeta
+	if !s.decodeBody(w, r, &artistMeta) {
+		return
+	}
+
+	artist, err := s.store.ReadArtist(nil, artistId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read artist: %v", err)
+	}
+
+	if !s.checkIfMatch(w, r, artist) || !s.checkArtistMeta(w, &artistMeta) {
+		return
+	}
+
+	var updatedArtist *restApiV1.Artist
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		updatedArtist, err = s.store.UpdateArtist(txn, artistId, &artistMeta)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypeArtist, string(artistId), &artist.ArtistMeta, &updatedArtist.ArtistMeta))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to update the artist: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, updatedArtist)
+}
+
+func (s *RestServer) deleteArtist(w http.ResponseWriter, r *http.Request) {
+	artistId := restApiV1.ArtistId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Delete artist: %s", artistId)
+
+	artist, err := s.store.ReadArtist(nil, artistId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read artist: %v", err)
+	}
+
+	if !s.checkIfMatch(w, r, artist) {
+		return
+	}
+
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		artist, err = s.store.DeleteArtist(txn, artistId)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeArtist, string(artist.Id), &artist.ArtistMeta, nil))
+	})
+	if err != nil {
+		if err == storeerror.ErrDeleteArtistWithSongs {
+			s.apiErrorCodeResponse(w, restApiV2.DeleteArtistWithSongsErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to delete artist: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, artist)
+}
+
+func (s *RestServer) checkArtistMeta(w http.ResponseWriter, artistMeta *restApiV1.ArtistMeta) bool {
+	var fieldErrors fieldErrors
+	fieldErrors.checkName(artistMeta.Name)
+	return fieldErrors.check(w, s)
+}
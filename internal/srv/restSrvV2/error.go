@@ -0,0 +1,30 @@
+package restSrvV2
+
+import (
+	"encoding/json"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+	"time"
+)
+
+func (s *RestServer) apiErrorCodeResponse(w http.ResponseWriter, apiErrorCode restApiV2.ErrorCode) {
+	s.apiErrorResponse(w, restApiV2.ApiError{ErrorCode: apiErrorCode})
+}
+
+func (s *RestServer) apiErrorResponse(w http.ResponseWriter, apiError restApiV2.ApiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiError.ErrorCode.StatusCode())
+	json.NewEncoder(w).Encode(apiError)
+}
+
+// validationErrorResponse refuses a request, listing the invalid query parameters or body fields
+func (s *RestServer) validationErrorResponse(w http.ResponseWriter, fieldErrors []restApiV2.FieldError) {
+	s.apiErrorResponse(w, restApiV2.ApiError{ErrorCode: restApiV2.ValidationErrorCode, Fields: fieldErrors})
+}
+
+// tooManyRequestsResponse refuses a request, telling the client when to retry
+func (s *RestServer) tooManyRequestsResponse(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", limiter.RetryAfter(retryAfter))
+	s.apiErrorCodeResponse(w, restApiV2.TooManyRequestsErrorCode)
+}
@@ -0,0 +1,97 @@
+package restSrvV2
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/restSrvV1"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+)
+
+func (s *RestServer) readFavoritePlaylists(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read favorite playlists")
+
+	q := newQueryReader(r)
+	favoritePlaylistFilter := restApiV1.FavoritePlaylistFilter{
+		FromTs:       q.int64("fromTs"),
+		FromRevision: q.int64("fromRevision"),
+		UserId:       (*restApiV1.UserId)(q.string("userId")),
+		PlaylistId:   (*restApiV1.PlaylistId)(q.string("playlistId")),
+	}
+	favoritePlaylistPage := restApiV2.FavoritePlaylistPage{Page: q.page()}
+	if !q.check(w, s) {
+		return
+	}
+
+	favoritePlaylists, err := s.store.ReadFavoritePlaylists(nil, &favoritePlaylistFilter)
+	if err != nil {
+		s.log.Panicf("Unable to read favorite playlists: %v", err)
+	}
+
+	start, end := pageBounds(&favoritePlaylistPage.Page, len(favoritePlaylists))
+	favoritePlaylistPage.Items = append([]restApiV1.FavoritePlaylist{}, favoritePlaylists[start:end]...)
+
+	s.writeJsonResponse(w, r, http.StatusOK, favoritePlaylistPage)
+}
+
+func (s *RestServer) createFavoritePlaylist(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Create favorite playlist")
+
+	var favoritePlaylistMeta restApiV1.FavoritePlaylistMeta
+	if !s.decodeBody(w, r, &favoritePlaylistMeta) {
+		return
+	}
+
+	var fieldErrors fieldErrors
+	fieldErrors.checkUserId(s, "id.userId", favoritePlaylistMeta.Id.UserId)
+	fieldErrors.checkPlaylistId(s, "id.playlistId", favoritePlaylistMeta.Id.PlaylistId)
+	if !fieldErrors.check(w, s) {
+		return
+	}
+
+	var favoritePlaylist *restApiV1.FavoritePlaylist
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		favoritePlaylist, err = s.store.CreateFavoritePlaylist(txn, &favoritePlaylistMeta, true)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeFavoritePlaylist, string(favoritePlaylist.Id.PlaylistId), nil, &favoritePlaylist.FavoritePlaylistMeta))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to create the favorite playlist: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusCreated, favoritePlaylist)
+}
+
+func (s *RestServer) deleteFavoritePlaylist(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	favoritePlaylistId := restApiV1.FavoritePlaylistId{
+		UserId:     restApiV1.UserId(vars["userId"]),
+		PlaylistId: restApiV1.PlaylistId(vars["playlistId"]),
+	}
+
+	s.log.Debugf("Delete favorite playlist: %v", favoritePlaylistId)
+
+	var favoritePlaylist *restApiV1.FavoritePlaylist
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		favoritePlaylist, err = s.store.DeleteFavoritePlaylist(txn, favoritePlaylistId)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeFavoritePlaylist, string(favoritePlaylist.Id.PlaylistId), &favoritePlaylist.FavoritePlaylistMeta, nil))
+	})
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to delete favorite playlist: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, favoritePlaylist)
+}
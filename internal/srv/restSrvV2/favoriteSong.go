@@ -0,0 +1,106 @@
+package restSrvV2
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/restSrvV1"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+)
+
+func (s *RestServer) readFavoriteSongs(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read favorite songs")
+
+	q := newQueryReader(r)
+	favoriteSongFilter := restApiV1.FavoriteSongFilter{
+		FromTs:       q.int64("fromTs"),
+		FromRevision: q.int64("fromRevision"),
+	}
+	userId := q.string("userId")
+	favoriteSongPage := restApiV2.FavoriteSongPage{Page: q.page()}
+	if !q.check(w, s) {
+		return
+	}
+
+	favoriteSongs, err := s.store.ReadFavoriteSongs(nil, &favoriteSongFilter)
+	if err != nil {
+		s.log.Panicf("Unable to read favorite songs: %v", err)
+	}
+
+	if userId != nil {
+		var userFavoriteSongs []restApiV1.FavoriteSong
+		for _, favoriteSong := range favoriteSongs {
+			if favoriteSong.Id.UserId == restApiV1.UserId(*userId) {
+				userFavoriteSongs = append(userFavoriteSongs, favoriteSong)
+			}
+		}
+		favoriteSongs = userFavoriteSongs
+	}
+
+	start, end := pageBounds(&favoriteSongPage.Page, len(favoriteSongs))
+	favoriteSongPage.Items = append([]restApiV1.FavoriteSong{}, favoriteSongs[start:end]...)
+
+	s.writeJsonResponse(w, r, http.StatusOK, favoriteSongPage)
+}
+
+func (s *RestServer) createFavoriteSong(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Create favorite song")
+
+	var favoriteSongMeta restApiV1.FavoriteSongMeta
+	if !s.decodeBody(w, r, &favoriteSongMeta) {
+		return
+	}
+
+	var fieldErrors fieldErrors
+	fieldErrors.checkUserId(s, "id.userId", favoriteSongMeta.Id.UserId)
+	fieldErrors.checkSongId(s, "id.songId", favoriteSongMeta.Id.SongId)
+	if !fieldErrors.check(w, s) {
+		return
+	}
+
+	var favoriteSong *restApiV1.FavoriteSong
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		favoriteSong, err = s.store.CreateFavoriteSong(txn, &favoriteSongMeta, true)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeFavoriteSong, string(favoriteSong.Id.SongId), nil, &favoriteSong.FavoriteSongMeta))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to create the favorite song: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusCreated, favoriteSong)
+}
+
+func (s *RestServer) deleteFavoriteSong(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	favoriteSongId := restApiV1.FavoriteSongId{
+		UserId: restApiV1.UserId(vars["userId"]),
+		SongId: restApiV1.SongId(vars["songId"]),
+	}
+
+	s.log.Debugf("Delete favorite song: %v", favoriteSongId)
+
+	var favoriteSong *restApiV1.FavoriteSong
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		favoriteSong, err = s.store.DeleteFavoriteSong(txn, favoriteSongId)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeFavoriteSong, string(favoriteSong.Id.SongId), &favoriteSong.FavoriteSongMeta, nil))
+	})
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to delete favorite song: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, favoriteSong)
+}
@@ -0,0 +1,16 @@
+package restSrvV2
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:embed openapi.json
+var openApiDocument []byte
+
+// readOpenApi serves the OpenAPI description of the api v2, to keep in line with the routes
+func (s *RestServer) readOpenApi(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read OpenAPI document")
+
+	writeJsonBody(w, r, http.StatusOK, openApiDocument)
+}
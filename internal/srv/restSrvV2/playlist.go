@@ -0,0 +1,160 @@
+package restSrvV2
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/restSrvV1"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+	"path"
+)
+
+func (s *RestServer) readPlaylists(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read playlists")
+
+	q := newQueryReader(r)
+	playlistFilter := restApiV1.PlaylistFilter{
+		FromTs:               q.int64("fromTs"),
+		FromRevision:         q.int64("fromRevision"),
+		FavoriteUserId:       (*restApiV1.UserId)(q.string("favoriteUserId")),
+		FavoriteFromTs:       q.int64("favoriteFromTs"),
+		FavoriteFromRevision: q.int64("favoriteFromRevision"),
+		OrderBy:              (*restApiV1.PlaylistFilterOrderBy)(q.enum("orderBy", string(restApiV1.PlaylistFilterOrderByName))),
+	}
+	playlistPage := restApiV2.PlaylistPage{Page: q.page()}
+	if !q.check(w, s) {
+		return
+	}
+
+	playlists, err := s.store.ReadPlaylists(nil, &playlistFilter)
+	if err != nil {
+		s.log.Panicf("Unable to read playlists: %v", err)
+	}
+
+	start, end := pageBounds(&playlistPage.Page, len(playlists))
+	playlistPage.Items = append([]restApiV1.Playlist{}, playlists[start:end]...)
+
+	s.writeJsonResponse(w, r, http.StatusOK, playlistPage)
+}
+
+func (s *RestServer) readPlaylist(w http.ResponseWriter, r *http.Request) {
+	playlistId := restApiV1.PlaylistId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Read playlist: %s", playlistId)
+
+	playlist, err := s.store.ReadPlaylist(nil, playlistId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read playlist: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, playlist)
+}
+
+func (s *RestServer) createPlaylist(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Create playlist")
+
+	var playlistMeta restApiV1.PlaylistMeta
+	if !s.decodeBody(w, r, &playlistMeta) || !s.checkPlaylistMeta(w, &playlistMeta) {
+		return
+	}
+
+	var playlist *restApiV1.Playlist
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		playlist, err = s.store.CreatePlaylist(txn, &playlistMeta, true)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypePlaylist, string(playlist.Id), nil, &playlist.PlaylistMeta))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to create the playlist: %v", err)
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, string(playlist.Id)))
+	s.writeJsonResponse(w, r, http.StatusCreated, playlist)
+}
+
+func (s *RestServer) updatePlaylist(w http.ResponseWriter, r *http.Request) {
+	playlistId := restApiV1.PlaylistId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Update playlist: %s", playlistId)
+
+	var playlistMeta restApiV1.PlaylistMeta
+	if !s.decodeBody(w, r, &playlistMeta) {
+		return
+	}
+
+	playlist, err := s.store.ReadPlaylist(nil, playlistId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read playlist: %v", err)
+	}
+
+	if !s.checkIfMatch(w, r, playlist) || !s.checkPlaylistMeta(w, &playlistMeta) {
+		return
+	}
+
+	var updatedPlaylist *restApiV1.Playlist
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		updatedPlaylist, err = s.store.UpdatePlaylist(txn, playlistId, &playlistMeta, true)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypePlaylist, string(playlistId), &playlist.PlaylistMeta, &updatedPlaylist.PlaylistMeta))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to update the playlist: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, updatedPlaylist)
+}
+
+func (s *RestServer) deletePlaylist(w http.ResponseWriter, r *http.Request) {
+	playlistId := restApiV1.PlaylistId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Delete playlist: %s", playlistId)
+
+	playlist, err := s.store.ReadPlaylist(nil, playlistId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read playlist: %v", err)
+	}
+
+	if !s.checkIfMatch(w, r, playlist) {
+		return
+	}
+
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		playlist, err = s.store.DeletePlaylist(txn, playlistId)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypePlaylist, string(playlist.Id), &playlist.PlaylistMeta, nil))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to delete playlist: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, playlist)
+}
+
+func (s *RestServer) checkPlaylistMeta(w http.ResponseWriter, playlistMeta *restApiV1.PlaylistMeta) bool {
+	var fieldErrors fieldErrors
+	fieldErrors.checkName(playlistMeta.Name)
+	fieldErrors.checkSongIds(s, "songIds", playlistMeta.SongIds)
+	fieldErrors.checkUserIds(s, "ownerUserIds", playlistMeta.OwnerUserIds)
+	return fieldErrors.check(w, s)
+}
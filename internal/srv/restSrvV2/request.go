@@ -0,0 +1,186 @@
+package restSrvV2
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/jypelle/mifasol/restApiV2"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query parameters accepted by every route
+var commonQueryParameters = map[string]bool{
+	"bearer": true,
+}
+
+// fieldErrors collects the invalid query parameters and body fields of a request
+type fieldErrors []restApiV2.FieldError
+
+func (f *fieldErrors) add(location restApiV2.FieldLocation, field string, message string) {
+	*f = append(*f, restApiV2.FieldError{Field: field, Location: location, Message: message})
+}
+
+// queryReader reads the query parameters of a request, collecting the errors of the invalid or unknown ones
+type queryReader struct {
+	values      url.Values
+	known       map[string]bool
+	fieldErrors fieldErrors
+}
+
+func newQueryReader(r *http.Request) *queryReader {
+	return &queryReader{
+		values: r.URL.Query(),
+		known:  make(map[string]bool),
+	}
+}
+
+// value returns the raw value of a parameter, nil when missing
+func (q *queryReader) value(name string) *string {
+	q.known[name] = true
+
+	values, ok := q.values[name]
+	if !ok {
+		return nil
+	}
+	if len(values) > 1 {
+		q.fieldErrors.add(restApiV2.FieldLocationQuery, name, "must be given once")
+		return nil
+	}
+	return &values[0]
+}
+
+func (q *queryReader) string(name string) *string {
+	value := q.value(name)
+	if value != nil && *value == "" {
+		q.fieldErrors.add(restApiV2.FieldLocationQuery, name, "must not be empty")
+		return nil
+	}
+	return value
+}
+
+func (q *queryReader) int64(name string) *int64 {
+	value := q.value(name)
+	if value == nil {
+		return nil
+	}
+	intValue, err := strconv.ParseInt(*value, 10, 64)
+	if err != nil {
+		q.fieldErrors.add(restApiV2.FieldLocationQuery, name, "must be an integer")
+		return nil
+	}
+	return &intValue
+}
+
+func (q *queryReader) bool(name string) *bool {
+	value := q.value(name)
+	if value == nil {
+		return nil
+	}
+	boolValue, err := strconv.ParseBool(*value)
+	if err != nil {
+		q.fieldErrors.add(restApiV2.FieldLocationQuery, name, "must be true or false")
+		return nil
+	}
+	return &boolValue
+}
+
+// enum returns the value of a parameter restricted to the allowed values
+func (q *queryReader) enum(name string, allowedValues ...string) *string {
+	value := q.value(name)
+	if value == nil {
+		return nil
+	}
+	for _, allowedValue := range allowedValues {
+		if *value == allowedValue {
+			return value
+		}
+	}
+	q.fieldErrors.add(restApiV2.FieldLocationQuery, name, "must be one of "+strings.Join(allowedValues, ", "))
+	return nil
+}
+
+// page returns the page requested by the offset and limit parameters
+func (q *queryReader) page() restApiV2.Page {
+	page := restApiV2.Page{Offset: 0, Limit: restApiV2.DefaultPageLimit}
+
+	if offset := q.int64("offset"); offset != nil {
+		if *offset < 0 {
+			q.fieldErrors.add(restApiV2.FieldLocationQuery, "offset", "must be positive")
+		} else {
+			page.Offset = *offset
+		}
+	}
+	if limit := q.int64("limit"); limit != nil {
+		if *limit < 1 || *limit > restApiV2.MaxPageLimit {
+			q.fieldErrors.add(restApiV2.FieldLocationQuery, "limit", "must be between 1 and "+strconv.FormatInt(restApiV2.MaxPageLimit, 10))
+		} else {
+			page.Limit = *limit
+		}
+	}
+
+	return page
+}
+
+// check reports the invalid and unknown parameters, returning false when a validation error has been sent
+func (q *queryReader) check(w http.ResponseWriter, s *RestServer) bool {
+	var unknownNames []string
+	for name := range q.values {
+		if !q.known[name] && !commonQueryParameters[name] {
+			unknownNames = append(unknownNames, name)
+		}
+	}
+	sort.Strings(unknownNames)
+	for _, name := range unknownNames {
+		q.fieldErrors.add(restApiV2.FieldLocationQuery, name, "unknown parameter")
+	}
+
+	return q.fieldErrors.check(w, s)
+}
+
+// decodeBody reads the JSON body of a request, returning false when an error has been sent
+func (s *RestServer) decodeBody(w http.ResponseWriter, r *http.Request, object interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(object)
+	if err == nil {
+		return true
+	}
+
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeError):
+		var fieldErrors fieldErrors
+		fieldErrors.add(restApiV2.FieldLocationBody, typeError.Field, "must be a JSON "+jsonTypeName(typeError.Type.Kind()))
+		s.validationErrorResponse(w, fieldErrors)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		var fieldErrors fieldErrors
+		fieldErrors.add(restApiV2.FieldLocationBody, strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`), "unknown field")
+		s.validationErrorResponse(w, fieldErrors)
+	case err == io.EOF:
+		s.apiErrorResponse(w, restApiV2.ApiError{ErrorCode: restApiV2.InvalidRequestErrorCode, ErrorDescription: "missing JSON body"})
+	default:
+		s.apiErrorResponse(w, restApiV2.ApiError{ErrorCode: restApiV2.InvalidRequestErrorCode, ErrorDescription: err.Error()})
+	}
+	return false
+}
+
+// jsonTypeName converts a go kind into the matching JSON type
+func jsonTypeName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return "number"
+}
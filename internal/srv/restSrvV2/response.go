@@ -0,0 +1,85 @@
+package restSrvV2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+	"strings"
+)
+
+// writeJsonResponse writes the JSON of object with its entity tag.
+// A GET request whose If-None-Match header holds the tag gets a not modified status instead.
+func (s *RestServer) writeJsonResponse(w http.ResponseWriter, r *http.Request, statusCode int, object interface{}) {
+	writeJsonBody(w, r, statusCode, s.encodeJson(object))
+}
+
+func writeJsonBody(w http.ResponseWriter, r *http.Request, statusCode int, body []byte) {
+	etag := bodyEntityTag(body)
+
+	w.Header().Set("ETag", etag)
+	if r.Method == http.MethodGet && entityTagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+// checkIfMatch answers a precondition failed error when the If-Match header doesn't hold the entity tag of the current entity.
+// The client uses it to avoid overwriting the changes made by someone else since it read the entity.
+func (s *RestServer) checkIfMatch(w http.ResponseWriter, r *http.Request, currentObject interface{}) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || entityTagMatch(ifMatch, bodyEntityTag(s.encodeJson(currentObject))) {
+		return true
+	}
+	s.apiErrorCodeResponse(w, restApiV2.PreconditionFailedErrorCode)
+	return false
+}
+
+func (s *RestServer) encodeJson(object interface{}) []byte {
+	body, err := json.Marshal(object)
+	if err != nil {
+		s.log.Panicf("Unable to encode the response: %v", err)
+	}
+	return append(body, '\n')
+}
+
+// bodyEntityTag returns a weak entity tag identifying the JSON content of a response body:
+// the bytes sent differ when the response is compressed
+func bodyEntityTag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// entityTagMatch checks if the entity tag is listed in an If-Match or If-None-Match header.
+// The entity tags being weak, both headers use the weak comparison, ignoring the weak indicators.
+func entityTagMatch(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, listedEtag := range strings.Split(header, ",") {
+		listedEtag = strings.TrimPrefix(strings.TrimSpace(listedEtag), "W/")
+		if listedEtag == "*" || listedEtag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// pageBounds completes the page with the count of matching items and returns the bounds of its items
+func pageBounds(page *restApiV2.Page, count int) (int, int) {
+	page.Total = int64(count)
+
+	start := page.Offset
+	if start > page.Total {
+		start = page.Total
+	}
+	end := start + page.Limit
+	if end > page.Total {
+		end = page.Total
+	}
+
+	return int(start), int(end)
+}
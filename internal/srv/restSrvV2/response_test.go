@@ -0,0 +1,65 @@
+package restSrvV2
+
+import (
+	"github.com/jypelle/mifasol/internal/tool"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressedResponseEntityTag(t *testing.T) {
+	body := []byte(`{"name":"` + strings.Repeat("a", 4096) + `"}` + "\n")
+	handler := tool.CompressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJsonBody(w, r, http.StatusOK, body)
+	}))
+
+	// Compressed response
+	request := httptest.NewRequest(http.MethodGet, "/api/v2/songs/1", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	etag := recorder.Header().Get("ETag")
+	if recorder.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Response not compressed")
+	}
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("ETag of a compressed response = %s, want a weak entity tag", etag)
+	}
+
+	// Not modified, whether compressed or not
+	for _, acceptEncoding := range []string{"gzip", ""} {
+		request = httptest.NewRequest(http.MethodGet, "/api/v2/songs/1", nil)
+		request.Header.Set("Accept-Encoding", acceptEncoding)
+		request.Header.Set("If-None-Match", etag)
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+			t.Errorf("Accept-Encoding %q: status = %d with %d bytes, want %d", acceptEncoding, recorder.Code, recorder.Body.Len(), http.StatusNotModified)
+		}
+	}
+}
+
+func TestEntityTagMatch(t *testing.T) {
+	etag := bodyEntityTag([]byte("{}\n"))
+	strongEtag := strings.TrimPrefix(etag, "W/")
+
+	tests := []struct {
+		header string
+		match  bool
+	}{
+		{etag, true},
+		{strongEtag, true},
+		{`"other", ` + etag, true},
+		{"*", true},
+		{`"other"`, false},
+		{`W/"other"`, false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if match := entityTagMatch(test.header, etag); match != test.match {
+			t.Errorf("entityTagMatch(%q) = %v, want %v", test.header, match, test.match)
+		}
+	}
+}
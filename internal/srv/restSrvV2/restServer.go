@@ -0,0 +1,142 @@
+package restSrvV2
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/srv/metrics"
+	"github.com/jypelle/mifasol/internal/srv/restSrvV1"
+	"github.com/jypelle/mifasol/internal/srv/store"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restApiV2"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// Routes reachable without access token: the token generation and the api description
+var publicRouteNames = map[string]bool{
+	"apiV2Token":   true,
+	"apiV2OpenApi": true,
+}
+
+// RestServer serves the api v2, sharing the access tokens, the audit trail and the song streaming of the api v1
+type RestServer struct {
+	store     *store.Store
+	restSrvV1 *restSrvV1.RestServer
+	limiter   *limiter.Limiter
+	subRouter *mux.Router
+
+	log *logrus.Entry
+}
+
+func NewRestServer(store *store.Store, restServerV1 *restSrvV1.RestServer, limiter *limiter.Limiter, subRouter *mux.Router) *RestServer {
+
+	restServer := &RestServer{
+		store:     store,
+		restSrvV1: restServerV1,
+		limiter:   limiter,
+		subRouter: subRouter,
+		log:       logrus.WithField("origin", "rest2"),
+	}
+
+	restServer.subRouter.HandleFunc("/openapi.json", restServer.readOpenApi).Methods("GET").Name("apiV2OpenApi")
+	restServer.subRouter.HandleFunc("/token", restServer.restSrvV1.GenerateToken).Methods("POST").Name("apiV2Token")
+
+	restServer.subRouter.HandleFunc("/albums", restServer.readAlbums).Methods("GET")
+	restServer.subRouter.HandleFunc("/albums/{id}", restServer.readAlbum).Methods("GET")
+	restServer.subRouter.HandleFunc("/albums", restServer.createAlbum).Methods("POST")
+	restServer.subRouter.HandleFunc("/albums/{id}", restServer.updateAlbum).Methods("PUT")
+	restServer.subRouter.HandleFunc("/albums/{id}", restServer.deleteAlbum).Methods("DELETE")
+
+	restServer.subRouter.HandleFunc("/artists", restServer.readArtists).Methods("GET")
+	restServer.subRouter.HandleFunc("/artists/{id}", restServer.readArtist).Methods("GET")
+	restServer.subRouter.HandleFunc("/artists", restServer.createArtist).Methods("POST")
+	restServer.subRouter.HandleFunc("/artists/{id}", restServer.updateArtist).Methods("PUT")
+	restServer.subRouter.HandleFunc("/artists/{id}", restServer.deleteArtist).Methods("DELETE")
+
+	restServer.subRouter.HandleFunc("/playlists", restServer.readPlaylists).Methods("GET")
+	restServer.subRouter.HandleFunc("/playlists/{id}", restServer.readPlaylist).Methods("GET")
+	restServer.subRouter.HandleFunc("/playlists", restServer.createPlaylist).Methods("POST")
+	restServer.subRouter.HandleFunc("/playlists/{id}", restServer.updatePlaylist).Methods("PUT")
+	restServer.subRouter.HandleFunc("/playlists/{id}", restServer.deletePlaylist).Methods("DELETE")
+
+	restServer.subRouter.HandleFunc("/songs", restServer.readSongs).Methods("GET")
+	restServer.subRouter.HandleFunc("/songs/{id}", restServer.readSong).Methods("GET")
+	restServer.subRouter.HandleFunc("/songs/{id}/content", restServer.restSrvV1.ReadSongContent).Methods("GET")
+	restServer.subRouter.HandleFunc("/songs/{id}", restServer.updateSong).Methods("PUT")
+	restServer.subRouter.HandleFunc("/songs/{id}", restServer.deleteSong).Methods("DELETE")
+
+	restServer.subRouter.HandleFunc("/users", restServer.readUsers).Methods("GET")
+	restServer.subRouter.HandleFunc("/users/{id}", restServer.readUser).Methods("GET")
+	restServer.subRouter.HandleFunc("/users", restServer.createUser).Methods("POST")
+	restServer.subRouter.HandleFunc("/users/{id}", restServer.updateUser).Methods("PUT")
+	restServer.subRouter.HandleFunc("/users/{id}", restServer.deleteUser).Methods("DELETE")
+
+	restServer.subRouter.HandleFunc("/favoritePlaylists", restServer.readFavoritePlaylists).Methods("GET")
+	restServer.subRouter.HandleFunc("/favoritePlaylists", restServer.createFavoritePlaylist).Methods("POST")
+	restServer.subRouter.HandleFunc("/favoritePlaylists/{userId}/{playlistId}", restServer.deleteFavoritePlaylist).Methods("DELETE")
+
+	restServer.subRouter.HandleFunc("/favoriteSongs", restServer.readFavoriteSongs).Methods("GET")
+	restServer.subRouter.HandleFunc("/favoriteSongs", restServer.createFavoriteSong).Methods("POST")
+	restServer.subRouter.HandleFunc("/favoriteSongs/{userId}/{songId}", restServer.deleteFavoriteSong).Methods("DELETE")
+
+	restServer.subRouter.HandleFunc("/syncReport", restServer.readSyncReport).Methods("GET")
+	restServer.subRouter.HandleFunc("/syncReportPage", restServer.readSyncReportPage).Methods("GET")
+	restServer.subRouter.HandleFunc("/fileSyncReport", restServer.readFileSyncReport).Methods("GET")
+
+	restServer.subRouter.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		restServer.apiErrorCodeResponse(w, restApiV2.MethodNotAllowedErrorCode)
+	})
+	restServer.subRouter.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		restServer.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+	})
+
+	// Compress the responses of the clients accepting it
+	restServer.subRouter.Use(tool.CompressHandler)
+
+	// Count the requests per route
+	restServer.subRouter.Use(func(handler http.Handler) http.Handler {
+		return metrics.RequestHandler(handler, func(r *http.Request) string {
+			route := mux.CurrentRoute(r)
+			if route == nil {
+				return ""
+			}
+			pathTemplate, _ := route.GetPathTemplate()
+			return pathTemplate
+		})
+	})
+
+	restServer.subRouter.Use(func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					restServer.log.Warningln("Recovering API Call...")
+					restServer.apiErrorCodeResponse(w, restApiV2.InternalErrorCode)
+				}
+			}()
+
+			// Requests are limited per user, or per IP without account, sharing the api v1 quota
+			rateLimitKey := "ip:" + tool.ClientIp(r)
+
+			// Check Token, except for the public routes
+			if route := mux.CurrentRoute(r); route == nil || !publicRouteNames[route.GetName()] {
+				var user *restApiV1.User
+				r, user = restServer.restSrvV1.Authenticate(r)
+				if user == nil {
+					restServer.apiErrorCodeResponse(w, restApiV2.InvalidTokenErrorCode)
+					return
+				}
+				rateLimitKey = "user:" + string(user.Id)
+			}
+
+			if ok, retryAfter := restServer.limiter.AllowRequest("rest", rateLimitKey); !ok {
+				restServer.tooManyRequestsResponse(w, retryAfter)
+				return
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	})
+
+	return restServer
+}
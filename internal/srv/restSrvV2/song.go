@@ -0,0 +1,155 @@
+package restSrvV2
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/restSrvV1"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+)
+
+func (s *RestServer) readSongs(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read songs")
+
+	q := newQueryReader(r)
+	songFilter := restApiV1.SongFilter{
+		FromTs:       q.int64("fromTs"),
+		FromRevision: q.int64("fromRevision"),
+		AlbumId:      (*restApiV1.AlbumId)(q.string("albumId")),
+		ArtistId:     (*restApiV1.ArtistId)(q.string("artistId")),
+		OrderBy:      (*restApiV1.SongFilterOrderBy)(q.enum("orderBy", string(restApiV1.SongFilterOrderByName))),
+	}
+	favoriteUserId := q.string("favoriteUserId")
+	favoriteFromTs := q.int64("favoriteFromTs")
+	favoriteFromRevision := q.int64("favoriteFromRevision")
+	if favoriteUserId != nil {
+		songFilter.Favorite = &restApiV1.SongFilterFavorite{UserId: restApiV1.UserId(*favoriteUserId), FromRevision: favoriteFromRevision}
+		if favoriteFromTs != nil {
+			songFilter.Favorite.FromTs = *favoriteFromTs
+		}
+	} else {
+		if favoriteFromTs != nil {
+			q.fieldErrors.add(restApiV2.FieldLocationQuery, "favoriteFromTs", "requires favoriteUserId")
+		}
+		if favoriteFromRevision != nil {
+			q.fieldErrors.add(restApiV2.FieldLocationQuery, "favoriteFromRevision", "requires favoriteUserId")
+		}
+	}
+	songPage := restApiV2.SongPage{Page: q.page()}
+	if !q.check(w, s) {
+		return
+	}
+
+	songs, err := s.store.ReadSongs(nil, &songFilter)
+	if err != nil {
+		s.log.Panicf("Unable to read songs: %v", err)
+	}
+
+	start, end := pageBounds(&songPage.Page, len(songs))
+	songPage.Items = append([]restApiV1.Song{}, songs[start:end]...)
+
+	s.writeJsonResponse(w, r, http.StatusOK, songPage)
+}
+
+func (s *RestServer) readSong(w http.ResponseWriter, r *http.Request) {
+	songId := restApiV1.SongId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Read song: %s", songId)
+
+	song, err := s.store.ReadSong(nil, songId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read song: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, song)
+}
+
+func (s *RestServer) updateSong(w http.ResponseWriter, r *http.Request) {
+	songId := restApiV1.SongId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Update song: %s", songId)
+
+	var songMeta restApiV1.SongMeta
+	if !s.decodeBody(w, r, &songMeta) {
+		return
+	}
+
+	song, err := s.store.ReadSong(nil, songId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read song: %v", err)
+	}
+
+	if !s.checkIfMatch(w, r, song) || !s.checkSongMeta(w, &songMeta) {
+		return
+	}
+
+	var updatedSong *restApiV1.Song
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		updatedSong, err = s.store.UpdateSong(txn, songId, &songMeta, nil, true)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypeSong, string(songId), &song.SongMeta, &updatedSong.SongMeta))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to update the song: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, updatedSong)
+}
+
+func (s *RestServer) deleteSong(w http.ResponseWriter, r *http.Request) {
+	songId := restApiV1.SongId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Delete song: %s", songId)
+
+	song, err := s.store.ReadSong(nil, songId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read song: %v", err)
+	}
+
+	if !s.checkIfMatch(w, r, song) {
+		return
+	}
+
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		song, err = s.store.DeleteSong(txn, songId)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeSong, string(song.Id), &song.SongMeta, nil))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to delete song: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, song)
+}
+
+func (s *RestServer) checkSongMeta(w http.ResponseWriter, songMeta *restApiV1.SongMeta) bool {
+	var fieldErrors fieldErrors
+	fieldErrors.checkName(songMeta.Name)
+	fieldErrors.checkAlbumId(s, "albumId", songMeta.AlbumId)
+	fieldErrors.checkArtistIds(s, "artistIds", songMeta.ArtistIds)
+	if songMeta.TrackNumber != nil && *songMeta.TrackNumber < 1 {
+		fieldErrors.add(restApiV2.FieldLocationBody, "trackNumber", "must be positive")
+	}
+	if songMeta.PublicationYear != nil && *songMeta.PublicationYear < 1 {
+		fieldErrors.add(restApiV2.FieldLocationBody, "publicationYear", "must be positive")
+	}
+	return fieldErrors.check(w, s)
+}
@@ -0,0 +1,97 @@
+package restSrvV2
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+	"strconv"
+)
+
+func (s *RestServer) readSyncReport(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read sync report")
+
+	q := newQueryReader(r)
+	fromRevision := q.int64("fromRevision")
+	if !q.check(w, s) {
+		return
+	}
+
+	var syncFromRevision int64
+	if fromRevision != nil {
+		syncFromRevision = *fromRevision
+	}
+
+	syncReport, err := s.store.ReadSyncReport(syncFromRevision)
+	if err != nil {
+		s.log.Panicf("Unable to read sync report: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, restApiV2.NewSyncReport(syncReport))
+}
+
+func (s *RestServer) readSyncReportPage(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read sync report page")
+
+	q := newQueryReader(r)
+	fromRevision := q.int64("fromRevision")
+	continuationToken := q.string("continuationToken")
+	limit := q.int64("limit")
+	if limit != nil && (*limit < 1 || *limit > restApiV1.SyncReportPageMaxSize) {
+		q.fieldErrors.add(restApiV2.FieldLocationQuery, "limit", "must be between 1 and "+strconv.FormatInt(restApiV1.SyncReportPageMaxSize, 10))
+	}
+	if !q.check(w, s) {
+		return
+	}
+
+	var syncFromRevision int64
+	if fromRevision != nil {
+		syncFromRevision = *fromRevision
+	}
+	var syncContinuationToken string
+	if continuationToken != nil {
+		syncContinuationToken = *continuationToken
+	}
+	size := int64(restApiV1.SyncReportPageDefaultSize)
+	if limit != nil {
+		size = *limit
+	}
+
+	syncReportPage, err := s.store.ReadSyncReportPage(syncFromRevision, syncContinuationToken, size)
+	if err != nil {
+		if err == storeerror.ErrInvalidContinuationToken {
+			q.fieldErrors.add(restApiV2.FieldLocationQuery, "continuationToken", "is invalid")
+			q.fieldErrors.check(w, s)
+			return
+		}
+		s.log.Panicf("Unable to read sync report page: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, restApiV2.NewSyncReportPage(syncReportPage))
+}
+
+func (s *RestServer) readFileSyncReport(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read file sync report")
+
+	q := newQueryReader(r)
+	fromRevision := q.int64("fromRevision")
+	userId := q.string("userId")
+	if _, ok := q.values["userId"]; !ok {
+		q.fieldErrors.add(restApiV2.FieldLocationQuery, "userId", "is required")
+	}
+	if !q.check(w, s) {
+		return
+	}
+
+	var syncFromRevision int64
+	if fromRevision != nil {
+		syncFromRevision = *fromRevision
+	}
+
+	fileSyncReport, err := s.store.ReadFileSyncReport(syncFromRevision, restApiV1.UserId(*userId))
+	if err != nil {
+		s.log.Panicf("Unable to read file sync report: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, fileSyncReport)
+}
@@ -0,0 +1,174 @@
+package restSrvV2
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/restSrvV1"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+	"path"
+)
+
+func (s *RestServer) readUsers(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read users")
+
+	q := newQueryReader(r)
+	userFilter := restApiV1.UserFilter{
+		FromTs:       q.int64("fromTs"),
+		FromRevision: q.int64("fromRevision"),
+		AdminFg:      q.bool("admin"),
+	}
+	userPage := restApiV2.UserPage{Page: q.page()}
+	if !q.check(w, s) {
+		return
+	}
+
+	users, err := s.store.ReadUsers(nil, &userFilter)
+	if err != nil {
+		s.log.Panicf("Unable to read users: %v", err)
+	}
+
+	start, end := pageBounds(&userPage.Page, len(users))
+	userPage.Items = []restApiV2.User{}
+	for ind := start; ind < end; ind++ {
+		userPage.Items = append(userPage.Items, restApiV2.NewUser(&users[ind]))
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, userPage)
+}
+
+func (s *RestServer) readUser(w http.ResponseWriter, r *http.Request) {
+	userId := restApiV1.UserId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Read user: %s", userId)
+
+	user, err := s.store.ReadUser(nil, userId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read user: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, restApiV2.NewUser(user))
+}
+
+func (s *RestServer) createUser(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Create user")
+
+	var userMetaComplete restApiV1.UserMetaComplete
+	if !s.decodeBody(w, r, &userMetaComplete) || !s.checkUserMetaComplete(w, nil, &userMetaComplete) {
+		return
+	}
+
+	var user *restApiV1.User
+	err := s.store.Transaction(func(txn *sqlx.Tx) error {
+		var err error
+		user, err = s.store.CreateUser(txn, &userMetaComplete)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionCreate, restApiV1.AuditEntityTypeUser, string(user.Id), nil, &restApiV1.UserMetaComplete{UserMeta: user.UserMeta, Password: userMetaComplete.Password}))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to create the user: %v", err)
+	}
+
+	w.Header().Set("Location", path.Join(r.URL.Path, string(user.Id)))
+	s.writeJsonResponse(w, r, http.StatusCreated, restApiV2.NewUser(user))
+}
+
+func (s *RestServer) updateUser(w http.ResponseWriter, r *http.Request) {
+	userId := restApiV1.UserId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Update user: %s", userId)
+
+	var userMetaComplete restApiV1.UserMetaComplete
+	if !s.decodeBody(w, r, &userMetaComplete) {
+		return
+	}
+
+	user, err := s.store.ReadUser(nil, userId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read user: %v", err)
+	}
+
+	if !s.checkIfMatch(w, r, restApiV2.NewUser(user)) || !s.checkUserMetaComplete(w, &userId, &userMetaComplete) {
+		return
+	}
+
+	var updatedUser *restApiV1.User
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		updatedUser, err = s.store.UpdateUser(txn, userId, &userMetaComplete)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionUpdate, restApiV1.AuditEntityTypeUser, string(userId), &user.UserMeta, &restApiV1.UserMetaComplete{UserMeta: updatedUser.UserMeta, Password: userMetaComplete.Password}))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to update the user: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, restApiV2.NewUser(updatedUser))
+}
+
+func (s *RestServer) deleteUser(w http.ResponseWriter, r *http.Request) {
+	userId := restApiV1.UserId(mux.Vars(r)["id"])
+
+	s.log.Debugf("Delete user: %s", userId)
+
+	user, err := s.store.ReadUser(nil, userId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.apiErrorCodeResponse(w, restApiV2.NotFoundErrorCode)
+			return
+		}
+		s.log.Panicf("Unable to read user: %v", err)
+	}
+
+	if !s.checkIfMatch(w, r, restApiV2.NewUser(user)) {
+		return
+	}
+
+	err = s.store.Transaction(func(txn *sqlx.Tx) error {
+		user, err = s.store.DeleteUser(txn, userId)
+		if err != nil {
+			return err
+		}
+		return s.restSrvV1.Audit(txn, r, restSrvV1.NewAuditEntry(restApiV1.AuditActionDelete, restApiV1.AuditEntityTypeUser, string(user.Id), &user.UserMeta, nil))
+	})
+	if err != nil {
+		s.log.Panicf("Unable to delete user: %v", err)
+	}
+
+	s.writeJsonResponse(w, r, http.StatusOK, restApiV2.NewUser(user))
+}
+
+// checkUserMetaComplete checks the user to create, or to update when userId is given
+func (s *RestServer) checkUserMetaComplete(w http.ResponseWriter, userId *restApiV1.UserId, userMetaComplete *restApiV1.UserMetaComplete) bool {
+	var fieldErrors fieldErrors
+	fieldErrors.checkName(userMetaComplete.Name)
+
+	sameNameUser, err := s.store.ReadUserByUserName(nil, userMetaComplete.Name)
+	if err == nil {
+		if userId == nil || sameNameUser.Id != *userId {
+			fieldErrors.add(restApiV2.FieldLocationBody, "name", "already used")
+		}
+	} else if err != storeerror.ErrNotFound {
+		s.log.Panicf("Unable to read user: %v", err)
+	}
+
+	// A void password keeps the current one
+	if userId == nil && userMetaComplete.Password == "" {
+		fieldErrors.add(restApiV2.FieldLocationBody, "password", "must not be empty")
+	}
+
+	return fieldErrors.check(w, s)
+}
@@ -0,0 +1,76 @@
+package restSrvV2
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/jypelle/mifasol/restApiV2"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (f *fieldErrors) checkName(name string) {
+	if strings.TrimSpace(name) == "" {
+		f.add(restApiV2.FieldLocationBody, "name", "must not be empty")
+	}
+}
+
+// checkReference adds an error when the referenced entity doesn't exist
+func (f *fieldErrors) checkReference(s *RestServer, field string, err error) {
+	if err == storeerror.ErrNotFound {
+		f.add(restApiV2.FieldLocationBody, field, "unknown id")
+	} else if err != nil {
+		s.log.Panicf("Unable to check %s: %v", field, err)
+	}
+}
+
+func (f *fieldErrors) checkAlbumId(s *RestServer, field string, albumId restApiV1.AlbumId) {
+	if albumId == restApiV1.UnknownAlbumId {
+		return
+	}
+	_, err := s.store.ReadAlbum(nil, albumId)
+	f.checkReference(s, field, err)
+}
+
+func (f *fieldErrors) checkArtistIds(s *RestServer, field string, artistIds []restApiV1.ArtistId) {
+	for ind, artistId := range artistIds {
+		_, err := s.store.ReadArtist(nil, artistId)
+		f.checkReference(s, field+"["+strconv.Itoa(ind)+"]", err)
+	}
+}
+
+func (f *fieldErrors) checkSongId(s *RestServer, field string, songId restApiV1.SongId) {
+	_, err := s.store.ReadSong(nil, songId)
+	f.checkReference(s, field, err)
+}
+
+func (f *fieldErrors) checkSongIds(s *RestServer, field string, songIds []restApiV1.SongId) {
+	for ind, songId := range songIds {
+		f.checkSongId(s, field+"["+strconv.Itoa(ind)+"]", songId)
+	}
+}
+
+func (f *fieldErrors) checkPlaylistId(s *RestServer, field string, playlistId restApiV1.PlaylistId) {
+	_, err := s.store.ReadPlaylist(nil, playlistId)
+	f.checkReference(s, field, err)
+}
+
+func (f *fieldErrors) checkUserId(s *RestServer, field string, userId restApiV1.UserId) {
+	_, err := s.store.ReadUser(nil, userId)
+	f.checkReference(s, field, err)
+}
+
+func (f *fieldErrors) checkUserIds(s *RestServer, field string, userIds []restApiV1.UserId) {
+	for ind, userId := range userIds {
+		f.checkUserId(s, field+"["+strconv.Itoa(ind)+"]", userId)
+	}
+}
+
+// check answers a validation error listing the invalid fields, returning false when there is one
+func (f fieldErrors) check(w http.ResponseWriter, s *RestServer) bool {
+	if len(f) > 0 {
+		s.validationErrorResponse(w, f)
+		return false
+	}
+	return true
+}
@@ -2,29 +2,58 @@ package srv
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/srv/metrics"
+	"github.com/jypelle/mifasol/internal/srv/mpdSrv"
+	"github.com/jypelle/mifasol/internal/srv/radioSrv"
 	"github.com/jypelle/mifasol/internal/srv/restSrvV1"
+	"github.com/jypelle/mifasol/internal/srv/restSrvV2"
 	"github.com/jypelle/mifasol/internal/srv/store"
+	"github.com/jypelle/mifasol/internal/srv/subsonicSrv"
+	"github.com/jypelle/mifasol/internal/srv/upnpSrv"
 	"github.com/jypelle/mifasol/internal/srv/webSrv"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/internal/version"
 	"github.com/sirupsen/logrus"
 	_ "modernc.org/sqlite"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type ServerApp struct {
 	config.ServerConfig
-	store      *store.Store
-	restSrvV1  *restSrvV1.RestServer
-	webSrv     *webSrv.WebServer
-	httpServer *http.Server
+	store       *store.Store
+	limiter     *limiter.Limiter
+	radioSrv    *radioSrv.RadioServer
+	restSrvV1   *restSrvV1.RestServer
+	restSrvV2   *restSrvV2.RestServer
+	subsonicSrv *subsonicSrv.SubsonicServer
+	webSrv      *webSrv.WebServer
+	mpdSrv      *mpdSrv.MpdServer
+	upnpSrv     *upnpSrv.UpnpServer
+	httpServer  *http.Server
+
+	// Certificate served with SSL, reloaded when renewed
+	certificateLoader *certificateLoader
+
+	// Plain http server redirecting to https, if any
+	redirectServer *http.Server
+
+	// Separate http server of the metrics endpoint, if any
+	metricsServer *http.Server
+
+	stopCh          chan struct{}
+	backgroundTasks sync.WaitGroup
 }
 
 func NewServerApp(configDir string, debugMode bool) *ServerApp {
@@ -36,6 +65,7 @@ func NewServerApp(configDir string, debugMode bool) *ServerApp {
 			ConfigDir: configDir,
 			DebugMode: debugMode,
 		},
+		stopCh: make(chan struct{}),
 	}
 
 	// Check Configuration folder
@@ -53,6 +83,8 @@ func NewServerApp(configDir string, debugMode bool) *ServerApp {
 			os.MkdirAll(app.ServerConfig.GetCompleteConfigAlbumsDirName(), 0770)
 			logrus.Printf("Creation of authors folder: %s", app.ServerConfig.GetCompleteConfigAuthorsDirName())
 			os.MkdirAll(app.ServerConfig.GetCompleteConfigAuthorsDirName(), 0770)
+			logrus.Printf("Creation of trash folder: %s", app.ServerConfig.GetCompleteConfigTrashDirName())
+			os.MkdirAll(app.ServerConfig.GetCompleteConfigTrashDirName(), 0770)
 
 		} else {
 			logrus.Fatalf("Unable to access config folder: %s", app.ConfigDir)
@@ -76,7 +108,7 @@ func NewServerApp(configDir string, debugMode bool) *ServerApp {
 
 	app.ServerConfig.Save()
 
-	if app.Ssl {
+	if app.Ssl && app.IsSelfSigned() {
 		existServerCert, err := tool.IsFileExists(app.GetCompleteConfigCertFilename())
 		if err != nil {
 			logrus.Fatalf("Unable to access %s: %v\n", app.GetCompleteConfigCertFilename(), err)
@@ -88,12 +120,7 @@ func NewServerApp(configDir string, debugMode bool) *ServerApp {
 
 		if !existServerCert || !existServerKey {
 			logrus.Info("Missing cert and key files, trying to generate them...")
-			err = tool.GenerateTlsCertificate(
-				"Mifasol",
-				"Mifasol Server",
-				app.GetCompleteConfigKeyFilename(),
-				app.GetCompleteConfigCertFilename(),
-				app.Hostnames)
+			err = app.generateSelfSignedCertificate()
 			if err != nil {
 				logrus.Fatalf("Unable to generate cert and key files : %v\n", err)
 			}
@@ -105,15 +132,36 @@ func NewServerApp(configDir string, debugMode bool) *ServerApp {
 	// Create store
 	app.store = store.NewStore(&app.ServerConfig)
 
-	// Create router
-	rooter := mux.NewRouter()
+	// Create limiter, shared by the servers checking passwords
+	app.limiter = limiter.NewLimiter(&app.ServerConfig)
+
+	// Create router, serving every route under the base path when behind a reverse proxy
+	mainRooter := mux.NewRouter()
+	rooter := mainRooter
+	if app.BasePath != "" {
+		mainRooter.Handle(app.BasePath, http.RedirectHandler(app.BasePath+"/", http.StatusMovedPermanently))
+		rooter = mainRooter.PathPrefix(app.BasePath).Subrouter()
+	}
+
+	// Create radio Server
+	app.radioSrv = radioSrv.NewRadioServer(app.store, &app.ServerConfig, rooter.PathPrefix("/radio").Subrouter())
 
 	// Create REST Server
-	app.restSrvV1 = restSrvV1.NewRestServer(app.store, rooter.PathPrefix("/api/v1").Subrouter())
+	app.restSrvV1 = restSrvV1.NewRestServer(app.store, app.radioSrv, app.limiter, rooter.PathPrefix("/api/v1").Subrouter())
+	app.restSrvV2 = restSrvV2.NewRestServer(app.store, app.restSrvV1, app.limiter, rooter.PathPrefix("/api/v2").Subrouter())
+
+	// Create Subsonic Server
+	app.subsonicSrv = subsonicSrv.NewSubsonicServer(app.store, app.limiter, rooter.PathPrefix("/rest").Subrouter())
 
 	// Create WEB Server
 	app.webSrv = webSrv.NewWebServer(app.store, rooter, &app.ServerConfig)
 
+	// Create MPD Server
+	app.mpdSrv = mpdSrv.NewMpdServer(app.store, app.limiter, app.radioSrv, &app.ServerConfig, rooter.PathPrefix("/mpd").Subrouter())
+
+	// Create UPnP Server
+	app.upnpSrv = upnpSrv.NewUpnpServer(app.store, app.limiter, &app.ServerConfig)
+
 	// Create server check endpoint
 	rooter.HandleFunc("/isalive",
 		func(w http.ResponseWriter, r *http.Request) {
@@ -121,17 +169,48 @@ func NewServerApp(configDir string, debugMode bool) *ServerApp {
 			tool.WriteJsonResponse(w, true)
 		}).Methods("GET")
 
+	// Create metrics endpoint, on the main port or on its own address
+	if app.MetricsEnabled {
+		app.registerMetrics()
+		if app.MetricsAddress == "" {
+			rooter.Handle("/metrics", metrics.Handler(app.MetricsToken)).Methods("GET")
+		} else {
+			metricsRooter := mux.NewRouter()
+			metricsRooter.Handle("/metrics", metrics.Handler(app.MetricsToken)).Methods("GET")
+			app.metricsServer = &http.Server{
+				Addr:        app.MetricsAddress,
+				Handler:     metricsRooter,
+				ReadTimeout: time.Duration(app.Timeout) * time.Second,
+			}
+		}
+	}
+
 	// Tell the browser that it's OK for JS to communicate with the server
 	headersOk := handlers.AllowedHeaders([]string{"Authorization"})
 	originsOk := handlers.AllowedOrigins([]string{"*"})
-	methodsOk := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	methodsOk := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"})
+
+	// Trust the forwarded headers of the reverse proxies
+	trustedProxies, err := tool.ParseNetworks(app.TrustedProxies)
+	if err != nil {
+		logrus.Warningf("Ignoring the trusted proxies: %v", err)
+		trustedProxies = nil
+	}
 
 	app.httpServer = &http.Server{
-		Addr:        ":" + strconv.FormatInt(app.Port, 10),
-		Handler:     handlers.CORS(originsOk, headersOk, methodsOk)(app.recoverHandler(rooter)),
+		Addr:        net.JoinHostPort(app.BindAddress, strconv.FormatInt(app.Port, 10)),
+		Handler:     tool.ProxyHeadersHandler(trustedProxies, handlers.CORS(originsOk, headersOk, methodsOk)(app.recoverHandler(mainRooter))),
 		ReadTimeout: time.Duration(app.Timeout) * time.Second,
 	}
 
+	if app.Ssl && app.HttpRedirectPort > 0 {
+		app.redirectServer = &http.Server{
+			Addr:        net.JoinHostPort(app.BindAddress, strconv.FormatInt(app.HttpRedirectPort, 10)),
+			Handler:     http.HandlerFunc(app.redirectToHttps),
+			ReadTimeout: time.Duration(app.Timeout) * time.Second,
+		}
+	}
+
 	logrus.Debugln("Server created")
 
 	return app
@@ -141,34 +220,154 @@ func (s *ServerApp) Start() {
 	logrus.Printf("Starting mifasol server ...")
 
 	// Start serving REST request
+	listener, location := s.listen()
 	if s.Ssl {
-		logrus.Printf("Server listening on https://localhost" + s.httpServer.Addr + " using a self-signed certificate")
+		var err error
+		s.certificateLoader, err = newCertificateLoader(s.GetCertFilename(), s.GetKeyFilename())
+		if err != nil {
+			logrus.Fatalf("Unable to load the certificate: %v", err)
+		}
+		s.httpServer.TLSConfig = &tls.Config{GetCertificate: s.certificateLoader.GetCertificate}
+
+		if s.IsSelfSigned() {
+			logrus.Printf("Server listening on " + location + " using a self-signed certificate")
+		} else {
+			logrus.Printf("Server listening on " + location + " using the certificate " + s.CertFile)
+		}
 		go func() {
-			err := s.httpServer.ListenAndServeTLS(s.GetCompleteConfigCertFilename(), s.GetCompleteConfigKeyFilename())
+			err := s.httpServer.ServeTLS(listener, "", "")
 			if err != nil && err != http.ErrServerClosed {
 				logrus.Fatalf("Unable start the server: %v", err)
 			}
 		}()
 
+		// Reload the renewed certificate
+		s.backgroundTasks.Add(1)
+		go s.watchCertificate()
+
+		// Start redirecting http to https
+		if s.redirectServer != nil {
+			logrus.Printf("Redirecting http://" + net.JoinHostPort(s.listenedHost(), strconv.FormatInt(s.HttpRedirectPort, 10)) + " to https")
+			go func() {
+				err := s.redirectServer.ListenAndServe()
+				if err != nil && err != http.ErrServerClosed {
+					logrus.Fatalf("Unable to start the http redirect server: %v", err)
+				}
+			}()
+		}
+
 	} else {
-		logrus.Printf("Server listening on http://localhost" + s.httpServer.Addr)
+		logrus.Printf("Server listening on " + location)
 		go func() {
-			err := s.httpServer.ListenAndServe()
+			err := s.httpServer.Serve(listener)
 			if err != nil && err != http.ErrServerClosed {
 				logrus.Fatalf("Unable start the server: %v", err)
 			}
 		}()
 	}
 
+	// Start serving metrics request
+	if s.metricsServer != nil {
+		logrus.Printf("Metrics served on http://" + s.metricsServer.Addr + "/metrics")
+		go func() {
+			err := s.metricsServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				logrus.Fatalf("Unable to start the metrics server: %v", err)
+			}
+		}()
+	}
+
+	// Start broadcasting radio stations
+	err := s.radioSrv.Start()
+	if err != nil {
+		logrus.Fatalf("Unable to start the radio stations: %v", err)
+	}
+
+	// Start serving MPD request
+	if s.MpdEnabled {
+		err := s.mpdSrv.Start()
+		if err != nil {
+			logrus.Fatalf("Unable to start the MPD server: %v", err)
+		}
+		logrus.Printf("MPD server listening on %s", net.JoinHostPort(s.listenedHost(), strconv.FormatInt(s.MpdPort, 10)))
+	}
+
+	// Start serving UPnP request
+	if s.UpnpEnabled {
+		err := s.upnpSrv.Start()
+		if err != nil {
+			logrus.Fatalf("Unable to start the UPnP media server: %v", err)
+		}
+		logrus.Printf("UPnP media server listening on %s", net.JoinHostPort(s.listenedHost(), strconv.FormatInt(s.UpnpPort, 10)))
+	}
+
+	// Start trash auto purge
+	s.backgroundTasks.Add(1)
+	go s.autoPurgeTrash()
+
+	// Start idle uploads auto purge
+	s.backgroundTasks.Add(1)
+	go s.autoPurgeUploads()
+
+	// Start audit trail auto purge
+	s.backgroundTasks.Add(1)
+	go s.autoPurgeAudit()
+
+	// Start scheduled backup
+	if s.BackupDir != "" && s.BackupInterval > 0 {
+		logrus.Printf("Backup scheduled every %d hours into %s", s.BackupInterval, s.BackupDir)
+		s.backgroundTasks.Add(1)
+		go s.scheduledBackup()
+	}
+
+	// Start inbox folder watching
+	if s.InboxDir != "" {
+		err := os.MkdirAll(s.InboxDir, 0770)
+		if err != nil {
+			logrus.Fatalf("Unable to create inbox folder: %v", err)
+		}
+		logrus.Printf("Inbox folder %s checked every %d seconds", s.InboxDir, s.InboxInterval)
+		s.backgroundTasks.Add(1)
+		go s.watchInbox()
+	}
+
 }
 
 func (s *ServerApp) Stop() {
 	logrus.Printf("Stopping mifasol server ...")
 
+	// Stop radio stations and event streams, disconnecting their listeners
+	s.radioSrv.Stop()
+	s.restSrvV1.Stop()
+
 	// Stop listening REST request
 	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
 	s.httpServer.Shutdown(ctx)
 
+	// Stop listening metrics request
+	if s.metricsServer != nil {
+		s.metricsServer.Shutdown(ctx)
+	}
+
+	// Stop redirecting http to https
+	if s.redirectServer != nil {
+		s.redirectServer.Shutdown(ctx)
+	}
+
+	// Stop listening MPD request
+	if s.MpdEnabled {
+		s.mpdSrv.Stop()
+	}
+
+	// Stop listening UPnP request
+	if s.UpnpEnabled {
+		s.upnpSrv.Stop()
+	}
+
+	// Stop background tasks
+	close(s.stopCh)
+	s.backgroundTasks.Wait()
+
 	// Close store
 	err := s.store.Close()
 	if err != nil {
@@ -178,6 +377,52 @@ func (s *ServerApp) Stop() {
 	logrus.Printf("Server stopped")
 }
 
+// listen opens the unix socket or the tcp port of the http server, and describes where the server is reachable
+func (s *ServerApp) listen() (net.Listener, string) {
+	if s.UnixSocket != "" {
+		// Remove the socket left by a previous run
+		if fileInfo, err := os.Stat(s.UnixSocket); err == nil && fileInfo.Mode()&os.ModeSocket != 0 {
+			os.Remove(s.UnixSocket)
+		}
+		listener, err := net.Listen("unix", s.UnixSocket)
+		if err != nil {
+			logrus.Fatalf("Unable start the server: %v", err)
+		}
+		// Let the reverse proxy connect, as it would to a local port
+		os.Chmod(s.UnixSocket, 0666)
+		return listener, "unix socket " + s.UnixSocket
+	}
+
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		logrus.Fatalf("Unable start the server: %v", err)
+	}
+	return listener, tool.TernStr(s.Ssl, "https", "http") + "://" + net.JoinHostPort(s.listenedHost(), strconv.FormatInt(s.Port, 10)) + s.BasePath
+}
+
+// listenedHost returns the host of the listened tcp ports, for the logs
+func (s *ServerApp) listenedHost() string {
+	if s.BindAddress == "" {
+		return "localhost"
+	}
+	return s.BindAddress
+}
+
+// redirectToHttps sends the plain http requests to the same url on the https port
+func (s *ServerApp) redirectToHttps(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = strings.Trim(r.Host, "[]")
+	}
+	if s.Port != 443 {
+		host = net.JoinHostPort(host, strconv.FormatInt(s.Port, 10))
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
 func (s *ServerApp) recoverHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
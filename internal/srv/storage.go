@@ -0,0 +1,73 @@
+package srv
+
+import (
+	"errors"
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/srv/store"
+	"github.com/sirupsen/logrus"
+)
+
+// MigrateStorage copies the song files into another storage and switches to it.
+// Mifasol server should be stopped.
+func (s *ServerApp) MigrateStorage(destStorageType string, deleteSource bool) error {
+	if destStorageType != config.StorageLocal && destStorageType != config.StorageS3 {
+		return errors.New("unknown storage " + destStorageType)
+	}
+	if destStorageType == s.Storage {
+		return errors.New("song files are already stored in " + s.store.Storage().Name())
+	}
+
+	srcStorage := s.store.Storage()
+	destStorage, err := store.NewStorage(&s.ServerConfig, destStorageType)
+	if err != nil {
+		return err
+	}
+
+	logrus.Printf("Migrate song files from %s to %s ...", srcStorage.Name(), destStorage.Name())
+
+	copiedCount := 0
+	for _, dir := range store.StorageDirs {
+		err = srcStorage.Walk(dir, func(key string, size int64) error {
+			content, err := srcStorage.Open(key)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+
+			err = destStorage.Write(key, content, size)
+			if err != nil {
+				return err
+			}
+
+			copiedCount++
+			if copiedCount%100 == 0 {
+				logrus.Printf("%d song files copied", copiedCount)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Switch to the new storage
+	s.Storage = destStorageType
+	s.ServerConfig.Save()
+	logrus.Printf("Migration done: %d song files copied, song files are now stored in %s", copiedCount, destStorage.Name())
+
+	if deleteSource {
+		removedCount := 0
+		for _, dir := range store.StorageDirs {
+			err = srcStorage.Walk(dir, func(key string, size int64) error {
+				removedCount++
+				return srcStorage.Remove(key)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		logrus.Printf("%d song files deleted from %s", removedCount, srcStorage.Name())
+	}
+
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
+	"sort"
 	"time"
 )
 
@@ -18,16 +19,14 @@ func (s *Store) ReadAlbums(externalTrn *sqlx.Tx, filter *restApiV1.AlbumFilter)
 		ArtistName sql.NullString `db:"artist_name"`
 	}
 
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "ReadAlbums")
-	}
+	defer s.timeTrack(time.Now(), "ReadAlbums")
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -37,7 +36,14 @@ func (s *Store) ReadAlbums(externalTrn *sqlx.Tx, filter *restApiV1.AlbumFilter)
 	queryArgs := make(map[string]interface{})
 	if filter.FromTs != nil {
 		queryArgs["from_ts"] = *filter.FromTs
-	} else if filter.Name != nil {
+	}
+	if filter.FromRevision != nil {
+		queryArgs["from_revision"] = *filter.FromRevision
+	}
+	if filter.ToRevision != nil {
+		queryArgs["to_revision"] = *filter.ToRevision
+	}
+	if filter.Name != nil {
 		queryArgs["name"] = *filter.Name
 	}
 
@@ -59,6 +65,8 @@ func (s *Store) ReadAlbums(externalTrn *sqlx.Tx, filter *restApiV1.AlbumFilter)
 			FROM album a
 			WHERE 1>0
 			`+tool.TernStr(filter.FromTs != nil, "AND a.update_ts >= :from_ts ", "")+`
+			`+tool.TernStr(filter.FromRevision != nil, "AND a.revision > :from_revision ", "")+`
+			`+tool.TernStr(filter.ToRevision != nil, "AND a.revision <= :to_revision ", "")+`
 			`+tool.TernStr(filter.Name != nil, "AND a.name LIKE :name ", "")+`
 			UNION ALL
 			SELECT
@@ -79,6 +87,8 @@ func (s *Store) ReadAlbums(externalTrn *sqlx.Tx, filter *restApiV1.AlbumFilter)
 				LEFT JOIN song ss using(album_id)
 				WHERE 1>0
 				`+tool.TernStr(filter.FromTs != nil, "AND aa.update_ts >= :from_ts ", "")+`
+				`+tool.TernStr(filter.FromRevision != nil, "AND aa.revision > :from_revision ", "")+`
+				`+tool.TernStr(filter.ToRevision != nil, "AND aa.revision <= :to_revision ", "")+`
 				`+tool.TernStr(filter.Name != nil, "AND aa.name LIKE :name ", "")+`
 				GROUP BY
 					aa.album_id,
@@ -146,7 +156,7 @@ func (s *Store) ReadAlbum(externalTrn *sqlx.Tx, albumId restApiV1.AlbumId) (*res
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -190,6 +200,25 @@ func (s *Store) ReadAlbum(externalTrn *sqlx.Tx, albumId restApiV1.AlbumId) (*res
 	return &album, nil
 }
 
+// ReadAlbumSongs returns the songs of an album ordered by track number
+func (s *Store) ReadAlbumSongs(externalTrn *sqlx.Tx, albumId restApiV1.AlbumId) ([]restApiV1.Song, error) {
+	orderBy := restApiV1.SongFilterOrderByName
+	songs, err := s.ReadSongs(externalTrn, &restApiV1.SongFilter{AlbumId: &albumId, OrderBy: &orderBy})
+	if err != nil {
+		return nil, err
+	}
+
+	// Songs without track number last
+	sort.SliceStable(songs, func(i, j int) bool {
+		if songs[i].TrackNumber == nil || songs[j].TrackNumber == nil {
+			return songs[i].TrackNumber != nil && songs[j].TrackNumber == nil
+		}
+		return *songs[i].TrackNumber < *songs[j].TrackNumber
+	})
+
+	return songs, nil
+}
+
 func (s *Store) CreateAlbum(externalTrn *sqlx.Tx, albumMeta *restApiV1.AlbumMeta) (*restApiV1.Album, error) {
 	var err error
 
@@ -200,7 +229,7 @@ func (s *Store) CreateAlbum(externalTrn *sqlx.Tx, albumMeta *restApiV1.AlbumMeta
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	// Store album
@@ -235,11 +264,21 @@ func (s *Store) CreateAlbum(externalTrn *sqlx.Tx, albumMeta *restApiV1.AlbumMeta
 	var album restApiV1.Album
 	albumEntity.Fill(&album)
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	s.publishEvent(externalTrn, restApiV1.AlbumCreatedEventType, string(album.Id), "", album.UpdateTs, revision)
+
 	return &album, nil
 }
 
@@ -253,7 +292,7 @@ func (s *Store) UpdateAlbum(externalTrn *sqlx.Tx, albumId restApiV1.AlbumId, alb
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 	var albumEntity entity.AlbumEntity
 	err = txn.Get(&albumEntity, `SELECT * FROM album WHERE album_id = ?`, albumId)
@@ -293,11 +332,21 @@ func (s *Store) UpdateAlbum(externalTrn *sqlx.Tx, albumId restApiV1.AlbumId, alb
 	var album restApiV1.Album
 	albumEntity.Fill(&album)
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	s.publishEvent(externalTrn, restApiV1.AlbumUpdatedEventType, string(album.Id), "", album.UpdateTs, revision)
+
 	return &album, nil
 }
 
@@ -311,7 +360,7 @@ func (s *Store) DeleteAlbum(externalTrn *sqlx.Tx, albumId restApiV1.AlbumId) (*r
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	deleteTs := time.Now().UnixNano()
@@ -337,6 +386,15 @@ func (s *Store) DeleteAlbum(externalTrn *sqlx.Tx, albumId restApiV1.AlbumId) (*r
 		return nil, err
 	}
 
+	// Move album to trash
+	var album restApiV1.Album
+	albumEntity.Fill(&album)
+
+	err = s.createTrashItem(txn, deleteTs, restApiV1.TrashItemTypeAlbum, string(albumId), album.Name, &trashSnapshot{Album: &album})
+	if err != nil {
+		return nil, err
+	}
+
 	// Archive albumId
 	_, err = txn.NamedExec(`
 			INSERT INTO	deleted_album (
@@ -352,28 +410,33 @@ func (s *Store) DeleteAlbum(externalTrn *sqlx.Tx, albumId restApiV1.AlbumId) (*r
 		return nil, err
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	var album restApiV1.Album
-	albumEntity.Fill(&album)
+	s.publishEvent(externalTrn, restApiV1.AlbumDeletedEventType, string(albumId), "", deleteTs, revision)
 
 	return &album, nil
 }
 
-func (s *Store) GetDeletedAlbumIds(externalTrn *sqlx.Tx, fromTs int64) ([]restApiV1.AlbumId, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "GetDeletedAlbumIds")
-	}
+func (s *Store) GetDeletedAlbumIds(externalTrn *sqlx.Tx, fromRevision int64, toRevision int64) ([]restApiV1.AlbumId, error) {
+	defer s.timeTrack(time.Now(), "GetDeletedAlbumIds")
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -381,13 +444,14 @@ func (s *Store) GetDeletedAlbumIds(externalTrn *sqlx.Tx, fromTs int64) ([]restAp
 	}
 
 	queryArgs := make(map[string]interface{})
-	queryArgs["from_ts"] = fromTs
+	queryArgs["from_revision"] = fromRevision
+	queryArgs["to_revision"] = toRevision
 	rows, err := txn.NamedQuery(
 		`SELECT
 				a.*
 			FROM deleted_album a
-			WHERE a.delete_ts >= :from_ts
-			ORDER BY a.delete_ts
+			WHERE a.revision > :from_revision AND a.revision <= :to_revision
+			ORDER BY a.revision
 		`,
 		queryArgs,
 	)
@@ -425,7 +489,7 @@ func (s *Store) getAlbumIdFromAlbumName(externalTrn *sqlx.Tx, albumName string,
 			if err != nil {
 				return restApiV1.UnknownAlbumId, err
 			}
-			defer txn.Rollback()
+			defer s.rollbackTransaction(txn)
 		}
 
 		var albums []restApiV1.Album
@@ -463,7 +527,10 @@ func (s *Store) getAlbumIdFromAlbumName(externalTrn *sqlx.Tx, albumName string,
 
 		// Commit transaction
 		if externalTrn == nil {
-			txn.Commit()
+			err = s.commitTransaction(txn)
+			if err != nil {
+				return restApiV1.UnknownAlbumId, err
+			}
 		}
 
 	}
@@ -12,16 +12,14 @@ import (
 )
 
 func (s *Store) ReadArtists(externalTrn *sqlx.Tx, filter *restApiV1.ArtistFilter) ([]restApiV1.Artist, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "ReadArtists")
-	}
+	defer s.timeTrack(time.Now(), "ReadArtists")
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -32,6 +30,12 @@ func (s *Store) ReadArtists(externalTrn *sqlx.Tx, filter *restApiV1.ArtistFilter
 	if filter.FromTs != nil {
 		queryArgs["from_ts"] = *filter.FromTs
 	}
+	if filter.FromRevision != nil {
+		queryArgs["from_revision"] = *filter.FromRevision
+	}
+	if filter.ToRevision != nil {
+		queryArgs["to_revision"] = *filter.ToRevision
+	}
 	if filter.Name != nil {
 		queryArgs["name"] = *filter.Name
 	}
@@ -53,6 +57,8 @@ func (s *Store) ReadArtists(externalTrn *sqlx.Tx, filter *restApiV1.ArtistFilter
 			`+tool.TernStr(filter.SongId != nil, "JOIN artist_song asg ON asg.artist_id = a.artist_id AND asg.song_id = :song_id ", "")+`
 			WHERE 1>0
 			`+tool.TernStr(filter.FromTs != nil, "AND a.update_ts >= :from_ts ", "")+`
+			`+tool.TernStr(filter.FromRevision != nil, "AND a.revision > :from_revision ", "")+`
+			`+tool.TernStr(filter.ToRevision != nil, "AND a.revision <= :to_revision ", "")+`
 			`+tool.TernStr(filter.Name != nil, "AND a.name LIKE :name ", "")+`
 			ORDER BY `+orderBy,
 		queryArgs,
@@ -87,7 +93,7 @@ func (s *Store) ReadArtist(externalTrn *sqlx.Tx, artistId restApiV1.ArtistId) (*
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -120,7 +126,7 @@ func (s *Store) CreateArtist(externalTrn *sqlx.Tx, artistMeta *restApiV1.ArtistM
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	// Store artist
@@ -152,14 +158,24 @@ func (s *Store) CreateArtist(externalTrn *sqlx.Tx, artistMeta *restApiV1.ArtistM
 		return nil, err
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var artist restApiV1.Artist
 	artistEntity.Fill(&artist)
 
+	s.publishEvent(externalTrn, restApiV1.ArtistCreatedEventType, string(artist.Id), "", artist.UpdateTs, revision)
+
 	return &artist, nil
 
 }
@@ -174,7 +190,7 @@ func (s *Store) UpdateArtist(externalTrn *sqlx.Tx, artistId restApiV1.ArtistId,
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	var artistEntity entity.ArtistEntity
@@ -211,21 +227,29 @@ func (s *Store) UpdateArtist(externalTrn *sqlx.Tx, artistId restApiV1.ArtistId,
 		}
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var artist restApiV1.Artist
 	artistEntity.Fill(&artist)
 
+	s.publishEvent(externalTrn, restApiV1.ArtistUpdatedEventType, string(artist.Id), "", artist.UpdateTs, revision)
+
 	return &artist, nil
 }
 
 func (s *Store) DeleteArtist(externalTrn *sqlx.Tx, artistId restApiV1.ArtistId) (*restApiV1.Artist, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "DeleteArtist")
-	}
+	defer s.timeTrack(time.Now(), "DeleteArtist")
 
 	var err error
 
@@ -236,7 +260,7 @@ func (s *Store) DeleteArtist(externalTrn *sqlx.Tx, artistId restApiV1.ArtistId)
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	deleteTs := time.Now().UnixNano()
@@ -262,6 +286,15 @@ func (s *Store) DeleteArtist(externalTrn *sqlx.Tx, artistId restApiV1.ArtistId)
 		return nil, err
 	}
 
+	// Move artist to trash
+	var artist restApiV1.Artist
+	artistEntity.Fill(&artist)
+
+	err = s.createTrashItem(txn, deleteTs, restApiV1.TrashItemTypeArtist, string(artistId), artist.Name, &trashSnapshot{Artist: &artist})
+	if err != nil {
+		return nil, err
+	}
+
 	// Archive artistId
 	_, err = txn.NamedExec(`
 			INSERT INTO	deleted_artist (
@@ -277,28 +310,33 @@ func (s *Store) DeleteArtist(externalTrn *sqlx.Tx, artistId restApiV1.ArtistId)
 		return nil, err
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	var artist restApiV1.Artist
-	artistEntity.Fill(&artist)
+	s.publishEvent(externalTrn, restApiV1.ArtistDeletedEventType, string(artistId), "", deleteTs, revision)
 
 	return &artist, nil
 }
 
-func (s *Store) GetDeletedArtistIds(externalTrn *sqlx.Tx, fromTs int64) ([]restApiV1.ArtistId, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "GetDeletedArtistIds")
-	}
+func (s *Store) GetDeletedArtistIds(externalTrn *sqlx.Tx, fromRevision int64, toRevision int64) ([]restApiV1.ArtistId, error) {
+	defer s.timeTrack(time.Now(), "GetDeletedArtistIds")
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -306,13 +344,14 @@ func (s *Store) GetDeletedArtistIds(externalTrn *sqlx.Tx, fromTs int64) ([]restA
 	}
 
 	queryArgs := make(map[string]interface{})
-	queryArgs["from_ts"] = fromTs
+	queryArgs["from_revision"] = fromRevision
+	queryArgs["to_revision"] = toRevision
 	rows, err := txn.NamedQuery(
 		`SELECT
 				a.*
 			FROM deleted_artist a
-			WHERE a.delete_ts >= :from_ts
-			ORDER BY a.delete_ts ASC
+			WHERE a.revision > :from_revision AND a.revision <= :to_revision
+			ORDER BY a.revision ASC
 		`,
 		queryArgs,
 	)
@@ -346,7 +385,7 @@ func (s *Store) getArtistIdsFromArtistNames(externalTrn *sqlx.Tx, artistNames []
 		if e != nil {
 			return nil, e
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	var artistIds []restApiV1.ArtistId
@@ -378,7 +417,10 @@ func (s *Store) getArtistIdsFromArtistNames(externalTrn *sqlx.Tx, artistNames []
 
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		e = s.commitTransaction(txn)
+		if e != nil {
+			return nil, e
+		}
 	}
 
 	return artistIds, nil
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"strconv"
+	"time"
+)
+
+func (s *Store) ReadAuditEntries(externalTrn *sqlx.Tx, filter *restApiV1.AuditEntryFilter) ([]restApiV1.AuditEntry, error) {
+	defer s.timeTrack(time.Now(), "ReadAuditEntries")
+
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	queryArgs := make(map[string]interface{})
+	if filter.UserId != nil {
+		queryArgs["user_id"] = *filter.UserId
+	}
+	if filter.EntityType != nil {
+		queryArgs["entity_type"] = *filter.EntityType
+	}
+	if filter.EntityId != nil {
+		queryArgs["entity_id"] = *filter.EntityId
+	}
+	if filter.FromTs != nil {
+		queryArgs["from_ts"] = *filter.FromTs
+	}
+	if filter.ToTs != nil {
+		queryArgs["to_ts"] = *filter.ToTs
+	}
+
+	rows, err := txn.NamedQuery(
+		`SELECT
+				a.*
+			FROM audit_entry a
+			WHERE 1>0
+			`+tool.TernStr(filter.UserId != nil, "AND a.user_id = :user_id ", "")+`
+			`+tool.TernStr(filter.EntityType != nil, "AND a.entity_type = :entity_type ", "")+`
+			`+tool.TernStr(filter.EntityId != nil, "AND a.entity_id = :entity_id ", "")+`
+			`+tool.TernStr(filter.FromTs != nil, "AND a.ts >= :from_ts ", "")+`
+			`+tool.TernStr(filter.ToTs != nil, "AND a.ts < :to_ts ", "")+`
+			ORDER BY a.ts DESC, a.audit_entry_id DESC
+			LIMIT `+strconv.Itoa(restApiV1.AuditMaxEntries)+`
+		`,
+		queryArgs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	auditEntries := []restApiV1.AuditEntry{}
+	for rows.Next() {
+		var auditEntryEntity entity.AuditEntryEntity
+		err = rows.StructScan(&auditEntryEntity)
+		if err != nil {
+			return nil, err
+		}
+
+		var auditEntry restApiV1.AuditEntry
+		auditEntryEntity.Fill(&auditEntry)
+		auditEntries = append(auditEntries, auditEntry)
+	}
+
+	return auditEntries, nil
+}
+
+// CreateAuditEntry appends an entry to the audit trail, entries are never updated
+func (s *Store) CreateAuditEntry(externalTrn *sqlx.Tx, auditEntry *restApiV1.AuditEntry) (*restApiV1.AuditEntry, error) {
+	defer s.timeTrack(time.Now(), "CreateAuditEntry")
+
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	auditEntryEntity := entity.AuditEntryEntity{
+		AuditEntryId: restApiV1.AuditEntryId(tool.CreateUlid()),
+		Ts:           time.Now().UnixNano(),
+	}
+	auditEntryEntity.Load(auditEntry)
+
+	_, err = txn.NamedExec(`
+			INSERT INTO	audit_entry (
+				audit_entry_id,
+				ts,
+				user_id,
+				user_name,
+				client_ip,
+				action,
+				entity_type,
+				entity_id,
+				entity_name,
+				changes
+			)
+			VALUES (
+				:audit_entry_id,
+				:ts,
+				:user_id,
+				:user_name,
+				:client_ip,
+				:action,
+				:entity_type,
+				:entity_id,
+				:entity_name,
+				:changes
+			)
+	`, &auditEntryEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var createdAuditEntry restApiV1.AuditEntry
+	auditEntryEntity.Fill(&createdAuditEntry)
+
+	return &createdAuditEntry, nil
+}
+
+// PurgeAuditEntries deletes the audit entries recorded before beforeTs and returns the number of deleted entries
+func (s *Store) PurgeAuditEntries(externalTrn *sqlx.Tx, beforeTs int64) (int64, error) {
+	defer s.timeTrack(time.Now(), "PurgeAuditEntries")
+
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return 0, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	result, err := txn.Exec("DELETE FROM audit_entry WHERE ts < ?", beforeTs)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
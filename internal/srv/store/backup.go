@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"os"
+)
+
+// SnapshotSongFile is a song file referenced by a database snapshot
+type SnapshotSongFile struct {
+	// Storage key of the file in the state of the snapshot
+	Key string
+	// Storage key of the file once moved to or restored from the trash after the snapshot
+	MovedKey string
+}
+
+// Snapshot writes a consistent copy of the database into dbFilename.
+// The changes are only locked during the copy: the song files are then read from the list of the snapshot.
+func (s *Store) Snapshot(dbFilename string) error {
+	ctx := context.Background()
+
+	// Only one write connection is available: holding it blocks every change, the reads going on
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// VACUUM INTO refuses to overwrite an existing file
+	err = os.Remove(dbFilename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "VACUUM INTO ?", dbFilename)
+	return err
+}
+
+// SnapshotSongFiles lists the song files, in use or in the trash, referenced by the database snapshot written into dbFilename
+func SnapshotSongFiles(dbFilename string) ([]SnapshotSongFile, error) {
+	db, err := sqlx.Open("sqlite", databaseDsn(dbFilename, "mode=ro"))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var songFiles []SnapshotSongFile
+
+	// Songs
+	var songEntities []entity.SongEntity
+	err = db.Select(&songEntities, "SELECT * FROM song")
+	if err != nil {
+		return nil, err
+	}
+	for _, songEntity := range songEntities {
+		songFiles = append(songFiles, SnapshotSongFile{
+			Key:      songStorageKey(songEntity.SongId, songEntity.Format),
+			MovedKey: trashSongStorageKey(songEntity.SongId, songEntity.Format),
+		})
+	}
+
+	// Trashed songs
+	var trashItemEntities []entity.TrashItemEntity
+	err = db.Select(&trashItemEntities, "SELECT * FROM trash_item WHERE item_type = ?", restApiV1.TrashItemTypeSong)
+	if err != nil {
+		return nil, err
+	}
+	for _, trashItemEntity := range trashItemEntities {
+		var snapshot trashSnapshot
+		err = json.Unmarshal([]byte(trashItemEntity.Snapshot), &snapshot)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot.Song != nil {
+			songFiles = append(songFiles, SnapshotSongFile{
+				Key:      trashSongStorageKey(snapshot.Song.Id, snapshot.Song.Format),
+				MovedKey: songStorageKey(snapshot.Song.Id, snapshot.Song.Format),
+			})
+		}
+	}
+
+	return songFiles, nil
+}
+
+// CheckDatabaseUnused fails with storeerror.ErrDatabaseInUse while a server uses the database of dbFilename:
+// an exclusive lock can't be taken on the database until all its connections are closed
+func CheckDatabaseUnused(dbFilename string) error {
+	exists, err := tool.IsFileExists(dbFilename)
+	if err != nil || !exists {
+		return err
+	}
+
+	db, err := sqlx.Open("sqlite", databaseDsn(dbFilename, "mode=rw", "_pragma=locking_mode(EXCLUSIVE)", "_pragma=busy_timeout(0)"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("BEGIN EXCLUSIVE")
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY {
+			return storeerror.ErrDatabaseInUse
+		}
+		return err
+	}
+	_, err = db.Exec("COMMIT")
+	return err
+}
@@ -0,0 +1,427 @@
+package store
+
+import (
+	"fmt"
+	"github.com/bogem/id3v2"
+	"github.com/go-flac/flacvorbis"
+	"github.com/go-flac/go-flac"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/restApiV1"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CheckIssueType string
+
+const (
+	CheckIssueTypeMissingFile          CheckIssueType = "missing_file"
+	CheckIssueTypeOrphanFile           CheckIssueType = "orphan_file"
+	CheckIssueTypeDanglingArtistSong   CheckIssueType = "dangling_artist_song"
+	CheckIssueTypeDanglingPlaylistSong CheckIssueType = "dangling_playlist_song"
+	CheckIssueTypeDanglingSongAlbum    CheckIssueType = "dangling_song_album"
+	CheckIssueTypeSizeMismatch         CheckIssueType = "size_mismatch"
+	CheckIssueTypeTagMismatch          CheckIssueType = "tag_mismatch"
+)
+
+type CheckIssue struct {
+	Type        CheckIssueType   `json:"type"`
+	SongId      restApiV1.SongId `json:"songId,omitempty"`
+	Filename    string           `json:"filename,omitempty"`
+	Description string           `json:"description"`
+	Repaired    bool             `json:"repaired"`
+}
+
+type CheckReport struct {
+	SongCount int          `json:"songCount"`
+	FileCount int          `json:"fileCount"`
+	Issues    []CheckIssue `json:"issues"`
+}
+
+// songTag contains the song meta read from song content tags
+type songTag struct {
+	Title           string
+	AlbumName       string
+	TrackNumber     *int64
+	PublicationYear *int64
+	ArtistNames     []string
+}
+
+// Check verifies the consistency between database and song files and optionally repairs found issues
+func (s *Store) Check(repair bool) (*CheckReport, error) {
+	defer s.timeTrack(time.Now(), "Check")
+
+	report := &CheckReport{Issues: []CheckIssue{}}
+
+	txn, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer s.rollbackTransaction(txn)
+
+	now := time.Now().UnixNano()
+
+	// Dangling artists link
+	artistSongEntities := []entity.ArtistSongEntity{}
+	err = txn.Select(&artistSongEntities, `
+		SELECT asg.*
+		FROM artist_song asg
+		WHERE NOT EXISTS (SELECT 1 FROM artist a WHERE a.artist_id = asg.artist_id)
+		OR NOT EXISTS (SELECT 1 FROM song s WHERE s.song_id = asg.song_id)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for _, artistSongEntity := range artistSongEntities {
+		issue := CheckIssue{
+			Type:        CheckIssueTypeDanglingArtistSong,
+			SongId:      artistSongEntity.SongId,
+			Description: fmt.Sprintf("artist %s linked to song %s", artistSongEntity.ArtistId, artistSongEntity.SongId),
+		}
+		if repair {
+			_, err = txn.NamedExec("DELETE FROM artist_song WHERE artist_id = :artist_id AND song_id = :song_id", &artistSongEntity)
+			if err != nil {
+				return nil, err
+			}
+			_, err = txn.Exec("UPDATE song SET update_ts = ? WHERE song_id = ?", now, artistSongEntity.SongId)
+			if err != nil {
+				return nil, err
+			}
+			issue.Repaired = true
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	// Dangling playlists link
+	playlistSongEntities := []entity.PlaylistSongEntity{}
+	err = txn.Select(&playlistSongEntities, `
+		SELECT ps.*
+		FROM playlist_song ps
+		WHERE NOT EXISTS (SELECT 1 FROM playlist p WHERE p.playlist_id = ps.playlist_id)
+		OR NOT EXISTS (SELECT 1 FROM song s WHERE s.song_id = ps.song_id)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for _, playlistSongEntity := range playlistSongEntities {
+		issue := CheckIssue{
+			Type:        CheckIssueTypeDanglingPlaylistSong,
+			SongId:      playlistSongEntity.SongId,
+			Description: fmt.Sprintf("song %s at position %d of playlist %s", playlistSongEntity.SongId, playlistSongEntity.Position, playlistSongEntity.PlaylistId),
+		}
+		if repair {
+			_, err = txn.NamedExec("DELETE FROM playlist_song WHERE playlist_id = :playlist_id AND position = :position", &playlistSongEntity)
+			if err != nil {
+				return nil, err
+			}
+			_, err = txn.Exec("UPDATE playlist SET update_ts = ?, content_update_ts = ? WHERE playlist_id = ?", now, now, playlistSongEntity.PlaylistId)
+			if err != nil {
+				return nil, err
+			}
+			issue.Repaired = true
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	// Dangling album link
+	songEntities := []entity.SongEntity{}
+	err = txn.Select(&songEntities, `
+		SELECT s.*
+		FROM song s
+		WHERE s.album_id <> ?
+		AND NOT EXISTS (SELECT 1 FROM album a WHERE a.album_id = s.album_id)
+	`, restApiV1.UnknownAlbumId)
+	if err != nil {
+		return nil, err
+	}
+	for _, songEntity := range songEntities {
+		issue := CheckIssue{
+			Type:        CheckIssueTypeDanglingSongAlbum,
+			SongId:      songEntity.SongId,
+			Description: fmt.Sprintf("album %s linked to song %s", songEntity.AlbumId, songEntity.SongId),
+		}
+		if repair {
+			_, err = txn.Exec("UPDATE song SET album_id = ?, track_number = NULL, update_ts = ? WHERE song_id = ?", restApiV1.UnknownAlbumId, now, songEntity.SongId)
+			if err != nil {
+				return nil, err
+			}
+			issue.Repaired = true
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	// Song files
+	songEntities = []entity.SongEntity{}
+	err = txn.Select(&songEntities, "SELECT * FROM song ORDER BY song_id")
+	if err != nil {
+		return nil, err
+	}
+	report.SongCount = len(songEntities)
+
+	songFilenames := make(map[string]struct{}, len(songEntities))
+
+	for idx := range songEntities {
+		songEntity := &songEntities[idx]
+		songFilename := songStorageKey(songEntity.SongId, songEntity.Format)
+		songFilenames[songFilename] = struct{}{}
+
+		// Missing file
+		size, err := s.storage.Size(songFilename)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+			report.Issues = append(report.Issues, CheckIssue{
+				Type:        CheckIssueTypeMissingFile,
+				SongId:      songEntity.SongId,
+				Filename:    songFilename,
+				Description: fmt.Sprintf("song %s \"%s\" has no file", songEntity.SongId, songEntity.Name),
+			})
+			continue
+		}
+
+		// Tags mismatch
+		expectedTag, err := s.getExpectedSongTag(txn, songEntity)
+		if err != nil {
+			return nil, err
+		}
+		var actualTag *songTag
+		err = s.storage.View(songFilename, func(filename string) error {
+			actualTag, err = readSongContentTag(filename, songEntity.Format)
+			return err
+		})
+		if err != nil {
+			report.Issues = append(report.Issues, CheckIssue{
+				Type:        CheckIssueTypeTagMismatch,
+				SongId:      songEntity.SongId,
+				Filename:    songFilename,
+				Description: fmt.Sprintf("song %s \"%s\" tags are unreadable: %v", songEntity.SongId, songEntity.Name, err),
+			})
+		} else if actualTag != nil {
+			if diff := compareSongTag(expectedTag, actualTag); diff != "" {
+				issue := CheckIssue{
+					Type:        CheckIssueTypeTagMismatch,
+					SongId:      songEntity.SongId,
+					Filename:    songFilename,
+					Description: fmt.Sprintf("song %s \"%s\" tags differ: %s", songEntity.SongId, songEntity.Name, diff),
+				}
+				if repair {
+					err = s.UpdateSongContentTag(txn, songEntity)
+					if err != nil {
+						return nil, err
+					}
+					size, err = s.storage.Size(songFilename)
+					if err != nil {
+						return nil, err
+					}
+					issue.Repaired = true
+				}
+				report.Issues = append(report.Issues, issue)
+			}
+		}
+
+		// Size mismatch
+		if size != songEntity.Size {
+			issue := CheckIssue{
+				Type:        CheckIssueTypeSizeMismatch,
+				SongId:      songEntity.SongId,
+				Filename:    songFilename,
+				Description: fmt.Sprintf("song %s \"%s\" size is %d instead of %d", songEntity.SongId, songEntity.Name, size, songEntity.Size),
+			}
+			if repair {
+				_, err = txn.Exec("UPDATE song SET size = ?, update_ts = ? WHERE song_id = ?", size, now, songEntity.SongId)
+				if err != nil {
+					return nil, err
+				}
+				issue.Repaired = true
+			}
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	// Orphan files
+	err = s.storage.Walk(songsStorageDir+"/", func(filename string, size int64) error {
+		report.FileCount++
+		if _, ok := songFilenames[filename]; ok {
+			return nil
+		}
+		issue := CheckIssue{
+			Type:        CheckIssueTypeOrphanFile,
+			Filename:    filename,
+			Description: fmt.Sprintf("file %s is not linked to any song", filename),
+		}
+		if repair {
+			err = s.storage.Remove(filename)
+			if err != nil {
+				return err
+			}
+			issue.Repaired = true
+		}
+		report.Issues = append(report.Issues, issue)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if repair {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// getExpectedSongTag returns the song meta that UpdateSongContentTag writes into song content
+func (s *Store) getExpectedSongTag(txn *sqlx.Tx, songEntity *entity.SongEntity) (*songTag, error) {
+	tag := &songTag{Title: songEntity.Name}
+
+	if songEntity.AlbumId != restApiV1.UnknownAlbumId {
+		album, err := s.ReadAlbum(txn, songEntity.AlbumId)
+		if err != nil {
+			return nil, err
+		}
+		tag.AlbumName = album.Name
+		if songEntity.TrackNumber.Valid {
+			tag.TrackNumber = &songEntity.TrackNumber.Int64
+		}
+	}
+
+	if songEntity.PublicationYear.Valid {
+		tag.PublicationYear = &songEntity.PublicationYear.Int64
+	}
+
+	artists, err := s.ReadArtists(txn, &restApiV1.ArtistFilter{SongId: &songEntity.SongId})
+	if err != nil {
+		return nil, err
+	}
+	for _, artist := range artists {
+		tag.ArtistNames = append(tag.ArtistNames, splitTagArtistNames(artist.Name)...)
+	}
+
+	return tag, nil
+}
+
+// readSongContentTag returns the song meta found in song content tags, or nil for unsupported format
+func readSongContentTag(songFilename string, songFormat restApiV1.SongFormat) (*songTag, error) {
+	switch songFormat {
+	case restApiV1.SongFormatFlac:
+		return readSongContentFlacTag(songFilename)
+	case restApiV1.SongFormatMp3:
+		return readSongContentMp3Tag(songFilename)
+	}
+	return nil, nil
+}
+
+func readSongContentFlacTag(songFilename string) (*songTag, error) {
+	flacFile, err := flac.ParseFile(songFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	cmt := flacvorbis.New()
+	for _, meta := range flacFile.Meta {
+		if meta.Type == flac.VorbisComment {
+			cmt, err = flacvorbis.ParseFromMetaDataBlock(*meta)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	tag := &songTag{}
+
+	if titles, _ := cmt.Get(flacvorbis.FIELD_TITLE); len(titles) > 0 {
+		tag.Title = normalizeString(titles[0])
+	}
+	if albumNames, _ := cmt.Get(flacvorbis.FIELD_ALBUM); len(albumNames) > 0 {
+		tag.AlbumName = normalizeString(albumNames[0])
+	}
+	if trackNumbers, _ := cmt.Get(flacvorbis.FIELD_TRACKNUMBER); len(trackNumbers) > 0 {
+		tag.TrackNumber = parseTagNumber(trackNumbers[0])
+	}
+	if yearNumbers, _ := cmt.Get(flacvorbis.FIELD_DATE); len(yearNumbers) > 0 {
+		tag.PublicationYear = parseTagNumber(yearNumbers[0])
+	}
+	vorbisArtistNames, _ := cmt.Get(flacvorbis.FIELD_ARTIST)
+	for _, vorbisArtistName := range vorbisArtistNames {
+		tag.ArtistNames = append(tag.ArtistNames, splitTagArtistNames(vorbisArtistName)...)
+	}
+
+	return tag, nil
+}
+
+func readSongContentMp3Tag(songFilename string) (*songTag, error) {
+	id3Tag, err := id3v2.Open(songFilename, id3v2.Options{Parse: true})
+	if err != nil {
+		return nil, err
+	}
+	defer id3Tag.Close()
+
+	tag := &songTag{
+		Title:           normalizeString(id3Tag.Title()),
+		AlbumName:       normalizeString(id3Tag.Album()),
+		TrackNumber:     parseTagNumber(strings.Split(id3Tag.GetTextFrame(id3Tag.CommonID("Track number/Position in set")).Text, "/")[0]),
+		PublicationYear: parseTagNumber(id3Tag.Year()),
+	}
+	for _, contatArtistNames := range strings.Split(id3Tag.Artist(), " - ") {
+		tag.ArtistNames = append(tag.ArtistNames, splitTagArtistNames(contatArtistNames)...)
+	}
+
+	return tag, nil
+}
+
+func parseTagNumber(rawNumber string) *int64 {
+	number, _ := strconv.ParseInt(normalizeString(rawNumber), 10, 64)
+	if number > 0 {
+		return &number
+	}
+	return nil
+}
+
+func splitTagArtistNames(rawArtistNames string) []string {
+	var artistNames []string
+	for _, artistName := range strings.FieldsFunc(rawArtistNames, func(r rune) bool { return r == ',' || r == ';' }) {
+		artistName = normalizeString(artistName)
+		if artistName != "" {
+			artistNames = append(artistNames, artistName)
+		}
+	}
+	return artistNames
+}
+
+// compareSongTag describes differences between expected and actual tags, only checking tags written by UpdateSongContentTag
+func compareSongTag(expected *songTag, actual *songTag) string {
+	var diffs []string
+
+	if normalizeString(expected.Title) != actual.Title {
+		diffs = append(diffs, fmt.Sprintf("title \"%s\" instead of \"%s\"", actual.Title, expected.Title))
+	}
+	if expected.AlbumName != "" {
+		if expected.AlbumName != actual.AlbumName {
+			diffs = append(diffs, fmt.Sprintf("album \"%s\" instead of \"%s\"", actual.AlbumName, expected.AlbumName))
+		}
+		if expected.TrackNumber != nil && (actual.TrackNumber == nil || *expected.TrackNumber != *actual.TrackNumber) {
+			diffs = append(diffs, "wrong track number")
+		}
+	}
+	if expected.PublicationYear != nil && (actual.PublicationYear == nil || *expected.PublicationYear != *actual.PublicationYear) {
+		diffs = append(diffs, "wrong publication year")
+	}
+
+	expectedArtistNames := append([]string{}, expected.ArtistNames...)
+	actualArtistNames := append([]string{}, actual.ArtistNames...)
+	sort.Strings(expectedArtistNames)
+	sort.Strings(actualArtistNames)
+	if strings.Join(expectedArtistNames, ", ") != strings.Join(actualArtistNames, ", ") {
+		diffs = append(diffs, fmt.Sprintf("artists \"%s\" instead of \"%s\"", strings.Join(actual.ArtistNames, ", "), strings.Join(expected.ArtistNames, ", ")))
+	}
+
+	return strings.Join(diffs, ", ")
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/restApiV1"
+	"sync"
+)
+
+// Number of events buffered for each subscription: slower subscriptions are closed
+const eventSubscriptionBufferSize = 256
+
+// EventSubscription receives the change events published by the store
+type EventSubscription struct {
+	Events <-chan restApiV1.Event
+	events chan restApiV1.Event
+}
+
+type eventBroker struct {
+	mutex         sync.Mutex
+	subscriptions map[*EventSubscription]struct{}
+
+	// Events of the changes made in the transactions of the callers, held until their transaction is committed
+	pendingEvents map[*sqlx.Tx][]restApiV1.Event
+}
+
+// SubscribeEvents returns a new subscription to the change events, whose channel is closed when the subscriber is too slow
+func (s *Store) SubscribeEvents() *EventSubscription {
+	s.eventBroker.mutex.Lock()
+	defer s.eventBroker.mutex.Unlock()
+
+	events := make(chan restApiV1.Event, eventSubscriptionBufferSize)
+	subscription := &EventSubscription{Events: events, events: events}
+	s.eventBroker.subscriptions[subscription] = struct{}{}
+
+	return subscription
+}
+
+func (s *Store) UnsubscribeEvents(subscription *EventSubscription) {
+	s.eventBroker.mutex.Lock()
+	defer s.eventBroker.mutex.Unlock()
+
+	if _, ok := s.eventBroker.subscriptions[subscription]; ok {
+		close(subscription.events)
+		delete(s.eventBroker.subscriptions, subscription)
+	}
+}
+
+// publishEvent notifies a change to every subscriber, once committed when made in the externalTrn transaction:
+// the subscribers reading the library through the read connections would miss it otherwise
+func (s *Store) publishEvent(externalTrn *sqlx.Tx, eventType restApiV1.EventType, id string, userId restApiV1.UserId, ts int64, revision int64) {
+	s.eventBroker.mutex.Lock()
+	defer s.eventBroker.mutex.Unlock()
+
+	event := restApiV1.Event{Type: eventType, Id: id, UserId: userId, Ts: ts, Revision: revision}
+	if externalTrn == nil {
+		s.eventBroker.send(event)
+		return
+	}
+
+	s.eventBroker.pendingEvents[externalTrn] = append(s.eventBroker.pendingEvents[externalTrn], event)
+}
+
+// endTransactionEvents publishes the pending events of a transaction once committed, and drops them otherwise
+func (s *Store) endTransactionEvents(txn *sqlx.Tx, committed bool) {
+	s.eventBroker.mutex.Lock()
+	defer s.eventBroker.mutex.Unlock()
+
+	if committed {
+		for _, event := range s.eventBroker.pendingEvents[txn] {
+			s.eventBroker.send(event)
+		}
+	}
+	delete(s.eventBroker.pendingEvents, txn)
+}
+
+// send delivers an event to every subscriber, the broker mutex being held
+func (b *eventBroker) send(event restApiV1.Event) {
+	for subscription := range b.subscriptions {
+		select {
+		case subscription.events <- event:
+		default:
+			close(subscription.events)
+			delete(b.subscriptions, subscription)
+		}
+	}
+}
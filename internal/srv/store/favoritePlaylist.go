@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"github.com/jmoiron/sqlx"
 	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
 	"time"
@@ -15,7 +16,7 @@ func (s *Store) ReadFavoritePlaylists(externalTrn *sqlx.Tx, filter *restApiV1.Fa
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -26,6 +27,12 @@ func (s *Store) ReadFavoritePlaylists(externalTrn *sqlx.Tx, filter *restApiV1.Fa
 	if filter.FromTs != nil {
 		queryArgs["from_ts"] = *filter.FromTs
 	}
+	if filter.FromRevision != nil {
+		queryArgs["from_revision"] = *filter.FromRevision
+	}
+	if filter.ToRevision != nil {
+		queryArgs["to_revision"] = *filter.ToRevision
+	}
 	if filter.UserId != nil {
 		queryArgs["user_id"] = *filter.UserId
 	}
@@ -39,6 +46,8 @@ func (s *Store) ReadFavoritePlaylists(externalTrn *sqlx.Tx, filter *restApiV1.Fa
 			FROM favorite_playlist f
 			WHERE 1>0
 			`+tool.TernStr(filter.FromTs != nil, "AND f.update_ts >= :from_ts ", "")+`
+			`+tool.TernStr(filter.FromRevision != nil, "AND f.revision > :from_revision ", "")+`
+			`+tool.TernStr(filter.ToRevision != nil, "AND f.revision <= :to_revision ", "")+`
 			`+tool.TernStr(filter.UserId != nil, "AND f.user_id = :user_id ", "")+`
 			`+tool.TernStr(filter.PlaylistId != nil, "AND f.playlist_id = :playlist_id ", "")+`
 			ORDER BY f.update_ts ASC
@@ -77,10 +86,11 @@ func (s *Store) CreateFavoritePlaylist(externalTrn *sqlx.Tx, favoritePlaylistMet
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	var favoritePlaylistEntity entity.FavoritePlaylistEntity
+	var revision int64
 
 	err = txn.Get(&favoritePlaylistEntity, "SELECT * FROM favorite_playlist WHERE user_id = ? AND playlist_id = ?", favoritePlaylistMeta.Id.UserId, favoritePlaylistMeta.Id.PlaylistId)
 	if err != nil && err != sql.ErrNoRows {
@@ -176,15 +186,25 @@ func (s *Store) CreateFavoritePlaylist(externalTrn *sqlx.Tx, favoritePlaylistMet
 				return nil, err
 			}
 		*/
+		revision, err = s.currentRevision(txn)
+		if err != nil {
+			return nil, err
+		}
+
 		// Commit transaction
 		if externalTrn == nil {
-			txn.Commit()
+			err = s.commitTransaction(txn)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	var favoritePlaylist restApiV1.FavoritePlaylist
 	favoritePlaylistEntity.Fill(&favoritePlaylist)
 
+	s.publishEvent(externalTrn, restApiV1.FavoritePlaylistCreatedEventType, string(favoritePlaylist.Id.PlaylistId), favoritePlaylist.Id.UserId, favoritePlaylist.UpdateTs, revision)
+
 	return &favoritePlaylist, nil
 }
 
@@ -198,12 +218,15 @@ func (s *Store) DeleteFavoritePlaylist(externalTrn *sqlx.Tx, favoritePlaylistId
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	var favoritePlaylistEntity entity.FavoritePlaylistEntity
 	err = txn.Get(&favoritePlaylistEntity, "SELECT * FROM favorite_playlist WHERE user_id = ? AND playlist_id = ?", favoritePlaylistId.UserId, favoritePlaylistId.PlaylistId)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storeerror.ErrNotFound
+		}
 		return nil, err
 	}
 
@@ -235,24 +258,34 @@ func (s *Store) DeleteFavoritePlaylist(externalTrn *sqlx.Tx, favoritePlaylistId
 		return nil, err
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var favoritePlaylist restApiV1.FavoritePlaylist
 	favoritePlaylistEntity.Fill(&favoritePlaylist)
 
+	s.publishEvent(externalTrn, restApiV1.FavoritePlaylistDeletedEventType, string(favoritePlaylistId.PlaylistId), favoritePlaylistId.UserId, deleteTs, revision)
+
 	return &favoritePlaylist, nil
 }
 
-func (s *Store) GetDeletedFavoritePlaylistIds(externalTrn *sqlx.Tx, fromTs int64) ([]restApiV1.FavoritePlaylistId, error) {
+func (s *Store) GetDeletedFavoritePlaylistIds(externalTrn *sqlx.Tx, fromRevision int64, toRevision int64) ([]restApiV1.FavoritePlaylistId, error) {
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -260,13 +293,14 @@ func (s *Store) GetDeletedFavoritePlaylistIds(externalTrn *sqlx.Tx, fromTs int64
 	}
 
 	queryArgs := make(map[string]interface{})
-	queryArgs["from_ts"] = fromTs
+	queryArgs["from_revision"] = fromRevision
+	queryArgs["to_revision"] = toRevision
 	rows, err := txn.NamedQuery(
 		`SELECT
 					d.*
 				FROM deleted_favorite_playlist d
-				WHERE d.delete_ts >= :from_ts
-				ORDER BY d.delete_ts ASC
+				WHERE d.revision > :from_revision AND d.revision <= :to_revision
+				ORDER BY d.revision ASC
 			`,
 		queryArgs,
 	)
@@ -295,14 +329,14 @@ func (s *Store) GetDeletedFavoritePlaylistIds(externalTrn *sqlx.Tx, fromTs int64
 	return favoritePlaylistIds, nil
 }
 
-func (s *Store) GetDeletedUserFavoritePlaylistIds(externalTrn *sqlx.Tx, fromTs int64, userId restApiV1.UserId) ([]restApiV1.PlaylistId, error) {
+func (s *Store) GetDeletedUserFavoritePlaylistIds(externalTrn *sqlx.Tx, fromRevision int64, toRevision int64, userId restApiV1.UserId) ([]restApiV1.PlaylistId, error) {
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -311,13 +345,14 @@ func (s *Store) GetDeletedUserFavoritePlaylistIds(externalTrn *sqlx.Tx, fromTs i
 
 	queryArgs := make(map[string]interface{})
 	queryArgs["user_id"] = userId
-	queryArgs["from_ts"] = fromTs
+	queryArgs["from_revision"] = fromRevision
+	queryArgs["to_revision"] = toRevision
 	rows, err := txn.NamedQuery(
 		`SELECT
 					d.*
 				FROM deleted_favorite_playlist d
-				WHERE user_id = :user_id AND d.delete_ts >= :from_ts
-				ORDER BY d.delete_ts ASC
+				WHERE user_id = :user_id AND d.revision > :from_revision AND d.revision <= :to_revision
+				ORDER BY d.revision ASC
 			`,
 		queryArgs,
 	)
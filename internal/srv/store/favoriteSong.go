@@ -4,22 +4,21 @@ import (
 	"database/sql"
 	"github.com/jmoiron/sqlx"
 	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
 	"time"
 )
 
 func (s *Store) ReadFavoriteSongs(externalTrn *sqlx.Tx, filter *restApiV1.FavoriteSongFilter) ([]restApiV1.FavoriteSong, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "ReadFavoriteSongs")
-	}
+	defer s.timeTrack(time.Now(), "ReadFavoriteSongs")
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -30,6 +29,12 @@ func (s *Store) ReadFavoriteSongs(externalTrn *sqlx.Tx, filter *restApiV1.Favori
 	if filter.FromTs != nil {
 		queryArgs["from_ts"] = *filter.FromTs
 	}
+	if filter.FromRevision != nil {
+		queryArgs["from_revision"] = *filter.FromRevision
+	}
+	if filter.ToRevision != nil {
+		queryArgs["to_revision"] = *filter.ToRevision
+	}
 
 	rows, err := txn.NamedQuery(
 		`SELECT
@@ -37,6 +42,8 @@ func (s *Store) ReadFavoriteSongs(externalTrn *sqlx.Tx, filter *restApiV1.Favori
 			FROM favorite_song f
 			WHERE 1>0
 			`+tool.TernStr(filter.FromTs != nil, "AND f.update_ts >= :from_ts ", "")+`
+			`+tool.TernStr(filter.FromRevision != nil, "AND f.revision > :from_revision ", "")+`
+			`+tool.TernStr(filter.ToRevision != nil, "AND f.revision <= :to_revision ", "")+`
 			ORDER BY f.update_ts ASC
 		`,
 		queryArgs,
@@ -73,10 +80,11 @@ func (s *Store) CreateFavoriteSong(externalTrn *sqlx.Tx, favoriteSongMeta *restA
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	var favoriteSongEntity entity.FavoriteSongEntity
+	var revision int64
 
 	err = txn.Get(&favoriteSongEntity, "SELECT * FROM favorite_song WHERE user_id = ? AND song_id = ?", favoriteSongMeta.Id.UserId, favoriteSongMeta.Id.SongId)
 	if err != nil && err != sql.ErrNoRows {
@@ -140,15 +148,25 @@ func (s *Store) CreateFavoriteSong(externalTrn *sqlx.Tx, favoriteSongMeta *restA
 			return nil, err
 		}
 
+		revision, err = s.currentRevision(txn)
+		if err != nil {
+			return nil, err
+		}
+
 		// Commit transaction
 		if externalTrn == nil {
-			txn.Commit()
+			err = s.commitTransaction(txn)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	var favoriteSong restApiV1.FavoriteSong
 	favoriteSongEntity.Fill(&favoriteSong)
 
+	s.publishEvent(externalTrn, restApiV1.FavoriteSongCreatedEventType, string(favoriteSong.Id.SongId), favoriteSong.Id.UserId, favoriteSong.UpdateTs, revision)
+
 	return &favoriteSong, nil
 }
 
@@ -162,12 +180,15 @@ func (s *Store) DeleteFavoriteSong(externalTrn *sqlx.Tx, favoriteSongId restApiV
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	var favoriteSongEntity entity.FavoriteSongEntity
 	err = txn.Get(&favoriteSongEntity, "SELECT * FROM favorite_song WHERE user_id = ? AND song_id = ?", favoriteSongId.UserId, favoriteSongId.SongId)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storeerror.ErrNotFound
+		}
 		return nil, err
 	}
 
@@ -219,28 +240,36 @@ func (s *Store) DeleteFavoriteSong(externalTrn *sqlx.Tx, favoriteSongId restApiV
 		return nil, err
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var favoriteSong restApiV1.FavoriteSong
 	favoriteSongEntity.Fill(&favoriteSong)
 
+	s.publishEvent(externalTrn, restApiV1.FavoriteSongDeletedEventType, string(favoriteSongId.SongId), favoriteSongId.UserId, deleteTs, revision)
+
 	return &favoriteSong, nil
 }
 
-func (s *Store) GetDeletedFavoriteSongIds(externalTrn *sqlx.Tx, fromTs int64) ([]restApiV1.FavoriteSongId, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "GetDeletedFavoriteSongIds")
-	}
+func (s *Store) GetDeletedFavoriteSongIds(externalTrn *sqlx.Tx, fromRevision int64, toRevision int64) ([]restApiV1.FavoriteSongId, error) {
+	defer s.timeTrack(time.Now(), "GetDeletedFavoriteSongIds")
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -248,13 +277,14 @@ func (s *Store) GetDeletedFavoriteSongIds(externalTrn *sqlx.Tx, fromTs int64) ([
 	}
 
 	queryArgs := make(map[string]interface{})
-	queryArgs["from_ts"] = fromTs
+	queryArgs["from_revision"] = fromRevision
+	queryArgs["to_revision"] = toRevision
 	rows, err := txn.NamedQuery(
 		`SELECT
 					d.*
 				FROM deleted_favorite_song d
-				WHERE d.delete_ts >= :from_ts
-				ORDER BY d.delete_ts ASC
+				WHERE d.revision > :from_revision AND d.revision <= :to_revision
+				ORDER BY d.revision ASC
 			`,
 		queryArgs,
 	)
@@ -283,13 +313,13 @@ func (s *Store) GetDeletedFavoriteSongIds(externalTrn *sqlx.Tx, fromTs int64) ([
 	return favoriteSongIds, nil
 }
 
-func (s *Store) GetDeletedUserFavoriteSongIds(externalTrn *sqlx.Tx, fromTs int64, userId restApiV1.UserId) ([]restApiV1.SongId, error) {
+func (s *Store) GetDeletedUserFavoriteSongIds(externalTrn *sqlx.Tx, fromRevision int64, toRevision int64, userId restApiV1.UserId) ([]restApiV1.SongId, error) {
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -298,13 +328,14 @@ func (s *Store) GetDeletedUserFavoriteSongIds(externalTrn *sqlx.Tx, fromTs int64
 
 	queryArgs := make(map[string]interface{})
 	queryArgs["user_id"] = userId
-	queryArgs["from_ts"] = fromTs
+	queryArgs["from_revision"] = fromRevision
+	queryArgs["to_revision"] = toRevision
 	rows, err := txn.NamedQuery(
 		`SELECT
 					d.*
 				FROM deleted_favorite_song d
-				WHERE user_id = :user_id AND d.delete_ts >= :from_ts
-				ORDER BY d.delete_ts ASC
+				WHERE user_id = :user_id AND d.revision > :from_revision AND d.revision <= :to_revision
+				ORDER BY d.revision ASC
 			`,
 		queryArgs,
 	)
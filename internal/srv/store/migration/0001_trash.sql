@@ -0,0 +1,15 @@
+-- +migrate Up
+
+-- Trash
+
+create table trash_item
+(
+    trash_item_id text    not null primary key,
+    delete_ts     integer not null,
+    item_type     text    not null,
+    item_id       text    not null,
+    name          text    not null,
+    snapshot      text    not null
+);
+
+create index trash_item_delete_ts_index on trash_item (delete_ts);
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+-- Radio
+
+create table radio_station
+(
+    playlist_id text    not null primary key,
+    creation_ts integer not null,
+    update_ts   integer not null,
+    format      text    not null,
+    bitrate     integer not null,
+    shuffle_fg  bool    not null
+);
@@ -0,0 +1,22 @@
+-- +migrate Up
+
+-- Play queue
+
+create table play_queue
+(
+    user_id         text    not null primary key,
+    update_ts       integer not null,
+    current_index   integer not null,
+    position        real    not null,
+    shuffle_fg      bool    not null,
+    repeat_mode     text    not null,
+    src_playlist_id text    null
+);
+
+create table play_queue_song
+(
+    user_id  text    not null,
+    position integer not null,
+    song_id  text    not null,
+    primary key (user_id, position)
+);
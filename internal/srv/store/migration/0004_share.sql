@@ -0,0 +1,18 @@
+-- +migrate Up
+
+-- Share
+
+create table share
+(
+    share_id      text    not null primary key,
+    creation_ts   integer not null,
+    owner_user_id text    not null,
+    item_type     text    not null,
+    item_id       text    not null,
+    expiration_ts integer null,
+    password      text    not null,
+    download_fg   bool    not null
+);
+
+create index share_owner_user_id_index on share (owner_user_id);
+create index share_item_index on share (item_type, item_id);
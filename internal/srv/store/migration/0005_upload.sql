@@ -0,0 +1,17 @@
+-- +migrate Up
+
+-- Upload
+
+create table upload
+(
+    upload_id     text    not null primary key,
+    creation_ts   integer not null,
+    update_ts     integer not null,
+    user_id       text    not null,
+    size          integer not null,
+    last_album_id text    not null,
+    song_id       text    null
+);
+
+create index upload_user_id_index on upload (user_id);
+create index upload_update_ts_index on upload (update_ts);
@@ -0,0 +1,21 @@
+-- +migrate Up
+
+-- Audit
+
+create table audit_entry
+(
+    audit_entry_id text    not null primary key,
+    ts             integer not null,
+    user_id        text    not null,
+    user_name      text    not null,
+    client_ip      text    not null,
+    action         text    not null,
+    entity_type    text    not null,
+    entity_id      text    not null,
+    entity_name    text    not null,
+    changes        text    not null
+);
+
+create index audit_entry_ts_index on audit_entry (ts);
+create index audit_entry_user_id_index on audit_entry (user_id, ts);
+create index audit_entry_entity_index on audit_entry (entity_type, entity_id, ts);
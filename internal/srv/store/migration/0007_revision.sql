@@ -0,0 +1,385 @@
+-- +migrate Up
+
+-- Revision: database-wide change sequence, replacing the wall-clock timestamps to synchronize the clients.
+-- Each created, updated or deleted (tombstone) row of a synchronized table gets the next revision.
+
+create table revision
+(
+    value integer not null
+);
+
+-- Existing rows get a revision following the order of their timestamps
+
+create temporary table revision_ts as
+select ts, row_number() over (order by ts) as revision
+from (
+    select update_ts as ts from album
+    union
+    select delete_ts as ts from deleted_album
+    union
+    select update_ts as ts from artist
+    union
+    select delete_ts as ts from deleted_artist
+    union
+    select update_ts as ts from favorite_playlist
+    union
+    select delete_ts as ts from deleted_favorite_playlist
+    union
+    select update_ts as ts from favorite_song
+    union
+    select delete_ts as ts from deleted_favorite_song
+    union
+    select max(update_ts, content_update_ts) as ts from playlist
+    union
+    select delete_ts as ts from deleted_playlist
+    union
+    select update_ts as ts from song
+    union
+    select delete_ts as ts from deleted_song
+    union
+    select update_ts as ts from user
+    union
+    select delete_ts as ts from deleted_user
+);
+
+create index revision_ts_index on revision_ts (ts);
+
+insert into revision (value) select coalesce(max(revision), 0) from revision_ts;
+
+-- Album
+
+alter table album add column revision integer not null default 0;
+
+update album set revision = (select r.revision from revision_ts r where r.ts = update_ts);
+
+create index album_revision_index on album (revision);
+
+-- +migrate StatementBegin
+create trigger album_revision_insert after insert on album
+begin
+    update revision set value = value + 1;
+    update album set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger album_revision_update after update on album when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update album set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Deleted album
+
+alter table deleted_album add column revision integer not null default 0;
+
+update deleted_album set revision = (select r.revision from revision_ts r where r.ts = delete_ts);
+
+create index deleted_album_revision_index on deleted_album (revision);
+
+-- +migrate StatementBegin
+create trigger deleted_album_revision_insert after insert on deleted_album
+begin
+    update revision set value = value + 1;
+    update deleted_album set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger deleted_album_revision_update after update on deleted_album when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update deleted_album set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Artist
+
+alter table artist add column revision integer not null default 0;
+
+update artist set revision = (select r.revision from revision_ts r where r.ts = update_ts);
+
+create index artist_revision_index on artist (revision);
+
+-- +migrate StatementBegin
+create trigger artist_revision_insert after insert on artist
+begin
+    update revision set value = value + 1;
+    update artist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger artist_revision_update after update on artist when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update artist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Deleted artist
+
+alter table deleted_artist add column revision integer not null default 0;
+
+update deleted_artist set revision = (select r.revision from revision_ts r where r.ts = delete_ts);
+
+create index deleted_artist_revision_index on deleted_artist (revision);
+
+-- +migrate StatementBegin
+create trigger deleted_artist_revision_insert after insert on deleted_artist
+begin
+    update revision set value = value + 1;
+    update deleted_artist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger deleted_artist_revision_update after update on deleted_artist when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update deleted_artist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Favorite playlist
+
+alter table favorite_playlist add column revision integer not null default 0;
+
+update favorite_playlist set revision = (select r.revision from revision_ts r where r.ts = update_ts);
+
+create index favorite_playlist_revision_index on favorite_playlist (revision);
+
+-- +migrate StatementBegin
+create trigger favorite_playlist_revision_insert after insert on favorite_playlist
+begin
+    update revision set value = value + 1;
+    update favorite_playlist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger favorite_playlist_revision_update after update on favorite_playlist when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update favorite_playlist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Deleted favorite playlist
+
+alter table deleted_favorite_playlist add column revision integer not null default 0;
+
+update deleted_favorite_playlist set revision = (select r.revision from revision_ts r where r.ts = delete_ts);
+
+create index deleted_favorite_playlist_revision_index on deleted_favorite_playlist (revision);
+
+-- +migrate StatementBegin
+create trigger deleted_favorite_playlist_revision_insert after insert on deleted_favorite_playlist
+begin
+    update revision set value = value + 1;
+    update deleted_favorite_playlist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger deleted_favorite_playlist_revision_update after update on deleted_favorite_playlist when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update deleted_favorite_playlist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Favorite song
+
+alter table favorite_song add column revision integer not null default 0;
+
+update favorite_song set revision = (select r.revision from revision_ts r where r.ts = update_ts);
+
+create index favorite_song_revision_index on favorite_song (revision);
+
+-- +migrate StatementBegin
+create trigger favorite_song_revision_insert after insert on favorite_song
+begin
+    update revision set value = value + 1;
+    update favorite_song set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger favorite_song_revision_update after update on favorite_song when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update favorite_song set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Deleted favorite song
+
+alter table deleted_favorite_song add column revision integer not null default 0;
+
+update deleted_favorite_song set revision = (select r.revision from revision_ts r where r.ts = delete_ts);
+
+create index deleted_favorite_song_revision_index on deleted_favorite_song (revision);
+
+-- +migrate StatementBegin
+create trigger deleted_favorite_song_revision_insert after insert on deleted_favorite_song
+begin
+    update revision set value = value + 1;
+    update deleted_favorite_song set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger deleted_favorite_song_revision_update after update on deleted_favorite_song when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update deleted_favorite_song set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Playlist
+
+alter table playlist add column revision integer not null default 0;
+
+update playlist set revision = (select r.revision from revision_ts r where r.ts = max(update_ts, content_update_ts));
+
+create index playlist_revision_index on playlist (revision);
+
+-- +migrate StatementBegin
+create trigger playlist_revision_insert after insert on playlist
+begin
+    update revision set value = value + 1;
+    update playlist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger playlist_revision_update after update on playlist when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update playlist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Deleted playlist
+
+alter table deleted_playlist add column revision integer not null default 0;
+
+update deleted_playlist set revision = (select r.revision from revision_ts r where r.ts = delete_ts);
+
+create index deleted_playlist_revision_index on deleted_playlist (revision);
+
+-- +migrate StatementBegin
+create trigger deleted_playlist_revision_insert after insert on deleted_playlist
+begin
+    update revision set value = value + 1;
+    update deleted_playlist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger deleted_playlist_revision_update after update on deleted_playlist when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update deleted_playlist set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Song
+
+alter table song add column revision integer not null default 0;
+
+update song set revision = (select r.revision from revision_ts r where r.ts = update_ts);
+
+create index song_revision_index on song (revision);
+
+-- +migrate StatementBegin
+create trigger song_revision_insert after insert on song
+begin
+    update revision set value = value + 1;
+    update song set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger song_revision_update after update on song when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update song set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Deleted song
+
+alter table deleted_song add column revision integer not null default 0;
+
+update deleted_song set revision = (select r.revision from revision_ts r where r.ts = delete_ts);
+
+create index deleted_song_revision_index on deleted_song (revision);
+
+-- +migrate StatementBegin
+create trigger deleted_song_revision_insert after insert on deleted_song
+begin
+    update revision set value = value + 1;
+    update deleted_song set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger deleted_song_revision_update after update on deleted_song when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update deleted_song set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- User
+
+alter table user add column revision integer not null default 0;
+
+update user set revision = (select r.revision from revision_ts r where r.ts = update_ts);
+
+create index user_revision_index on user (revision);
+
+-- +migrate StatementBegin
+create trigger user_revision_insert after insert on user
+begin
+    update revision set value = value + 1;
+    update user set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger user_revision_update after update on user when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update user set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- Deleted user
+
+alter table deleted_user add column revision integer not null default 0;
+
+update deleted_user set revision = (select r.revision from revision_ts r where r.ts = delete_ts);
+
+create index deleted_user_revision_index on deleted_user (revision);
+
+-- +migrate StatementBegin
+create trigger deleted_user_revision_insert after insert on deleted_user
+begin
+    update revision set value = value + 1;
+    update deleted_user set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+-- +migrate StatementBegin
+create trigger deleted_user_revision_update after update on deleted_user when new.revision = old.revision
+begin
+    update revision set value = value + 1;
+    update deleted_user set revision = (select value from revision) where rowid = new.rowid;
+end;
+-- +migrate StatementEnd
+
+drop table revision_ts;
@@ -0,0 +1,26 @@
+-- +migrate Up
+
+-- Share passwords are stored hashed: the plaintext passwords of the existing shares are hashed on next start
+
+alter table share rename column password to password_hash;
+
+create table share_password_upgrade
+(
+    share_id text not null primary key
+);
+
+insert into share_password_upgrade (share_id)
+select share_id
+from share
+where password_hash <> '';
+
+-- Share creation audit entries no longer record the password
+
+update audit_entry
+set changes = (
+    select json_group_array(json(value))
+    from json_each(audit_entry.changes)
+    where json_extract(value, '$.field') <> 'password'
+)
+where entity_type = 'share'
+  and changes like '%"password"%';
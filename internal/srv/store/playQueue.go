@@ -0,0 +1,171 @@
+package store
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/restApiV1"
+	"time"
+)
+
+// ReadPlayQueue returns the play queue of a user, without the deleted songs, or an empty play queue when the user never saved one
+func (s *Store) ReadPlayQueue(externalTrn *sqlx.Tx, userId restApiV1.UserId) (*restApiV1.PlayQueue, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	var playQueueEntity entity.PlayQueueEntity
+	err = txn.Get(&playQueueEntity, "SELECT * FROM play_queue WHERE user_id = ?", userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &restApiV1.PlayQueue{
+				UserId: userId,
+				PlayQueueMeta: restApiV1.PlayQueueMeta{
+					SongIds:      []restApiV1.SongId{},
+					CurrentIndex: -1,
+					RepeatMode:   restApiV1.RepeatModeOff,
+				},
+			}, nil
+		}
+		return nil, err
+	}
+
+	var playQueue restApiV1.PlayQueue
+	playQueueEntity.Fill(&playQueue)
+
+	// Retrieve songs still available
+	var playQueueSongEntities []entity.PlayQueueSongEntity
+	err = txn.Select(&playQueueSongEntities, `
+		SELECT pqs.*
+		FROM play_queue_song pqs
+		JOIN song s USING (song_id)
+		WHERE pqs.user_id = ?
+		ORDER BY pqs.position
+	`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	playQueue.SongIds = []restApiV1.SongId{}
+	currentIndex := int64(-1)
+	for _, playQueueSongEntity := range playQueueSongEntities {
+		if playQueueSongEntity.Position == playQueue.CurrentIndex {
+			currentIndex = int64(len(playQueue.SongIds))
+		}
+		playQueue.SongIds = append(playQueue.SongIds, playQueueSongEntity.SongId)
+	}
+	if currentIndex == -1 {
+		playQueue.Position = 0
+	}
+	playQueue.CurrentIndex = currentIndex
+
+	return &playQueue, nil
+}
+
+// UpdatePlayQueue replaces the play queue of a user
+func (s *Store) UpdatePlayQueue(externalTrn *sqlx.Tx, userId restApiV1.UserId, playQueueMeta *restApiV1.PlayQueueMeta) (*restApiV1.PlayQueue, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	playQueueEntity := entity.PlayQueueEntity{
+		UserId:   userId,
+		UpdateTs: time.Now().UnixNano(),
+	}
+	playQueueEntity.LoadMeta(playQueueMeta)
+
+	_, err = txn.NamedExec(`
+			INSERT INTO	play_queue (
+			    user_id,
+			    update_ts,
+			    current_index,
+			    position,
+			    shuffle_fg,
+			    repeat_mode,
+			    src_playlist_id
+			)
+			VALUES (
+			    :user_id,
+			    :update_ts,
+			    :current_index,
+			    :position,
+			    :shuffle_fg,
+			    :repeat_mode,
+			    :src_playlist_id
+			)
+			ON CONFLICT (user_id) DO UPDATE SET
+			    update_ts = excluded.update_ts,
+			    current_index = excluded.current_index,
+			    position = excluded.position,
+			    shuffle_fg = excluded.shuffle_fg,
+			    repeat_mode = excluded.repeat_mode,
+			    src_playlist_id = excluded.src_playlist_id
+	`, &playQueueEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace songs
+	_, err = txn.Exec("DELETE FROM play_queue_song WHERE user_id = ?", userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for position, songId := range playQueueMeta.SongIds {
+		_, err = txn.NamedExec(`
+				INSERT INTO	play_queue_song (
+				    user_id,
+				    position,
+				    song_id
+				)
+				VALUES (
+				    :user_id,
+				    :position,
+				    :song_id
+				)
+		`, &entity.PlayQueueSongEntity{UserId: userId, Position: int64(position), SongId: songId})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var playQueue restApiV1.PlayQueue
+	playQueueEntity.Fill(&playQueue)
+	playQueue.SongIds = playQueueMeta.SongIds
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &playQueue, nil
+}
+
+// deletePlayQueue removes the play queue of a user
+func (s *Store) deletePlayQueue(txn *sqlx.Tx, userId restApiV1.UserId) error {
+	_, err := txn.Exec("DELETE FROM play_queue_song WHERE user_id = ?", userId)
+	if err != nil {
+		return err
+	}
+	_, err = txn.Exec("DELETE FROM play_queue WHERE user_id = ?", userId)
+	return err
+}
@@ -14,16 +14,14 @@ import (
 )
 
 func (s *Store) ReadPlaylists(externalTrn *sqlx.Tx, filter *restApiV1.PlaylistFilter) ([]restApiV1.Playlist, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "ReadPlaylists")
-	}
+	defer s.timeTrack(time.Now(), "ReadPlaylists")
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -34,12 +32,21 @@ func (s *Store) ReadPlaylists(externalTrn *sqlx.Tx, filter *restApiV1.PlaylistFi
 	if filter.FromTs != nil {
 		queryArgs["from_ts"] = *filter.FromTs
 	}
+	if filter.FromRevision != nil {
+		queryArgs["from_revision"] = *filter.FromRevision
+	}
+	if filter.ToRevision != nil {
+		queryArgs["to_revision"] = *filter.ToRevision
+	}
 	if filter.FavoriteUserId != nil {
 		queryArgs["favorite_user_id"] = *filter.FavoriteUserId
 	}
 	if filter.FavoriteFromTs != nil {
 		queryArgs["favorite_from_ts"] = *filter.FavoriteFromTs
 	}
+	if filter.FavoriteFromRevision != nil {
+		queryArgs["favorite_from_revision"] = *filter.FavoriteFromRevision
+	}
 	orderBy := "p.update_ts ASC"
 	if filter.OrderBy != nil {
 		if *filter.OrderBy == restApiV1.PlaylistFilterOrderByName {
@@ -54,7 +61,10 @@ func (s *Store) ReadPlaylists(externalTrn *sqlx.Tx, filter *restApiV1.PlaylistFi
 			`+tool.TernStr(filter.FavoriteUserId != nil, "JOIN favorite_playlist fp ON fp.playlist_id = p.playlist_id AND fp.user_id = :favorite_user_id ", "")+`
 			WHERE 1>0
 			`+tool.TernStr(filter.FromTs != nil, "AND p.update_ts >= :from_ts ", "")+`
+			`+tool.TernStr(filter.FromRevision != nil, "AND p.revision > :from_revision ", "")+`
+			`+tool.TernStr(filter.ToRevision != nil, "AND p.revision <= :to_revision ", "")+`
 			`+tool.TernStr(filter.FavoriteUserId != nil && filter.FavoriteFromTs != nil, "AND (fp.update_ts >= :favorite_from_ts OR p.content_update_ts >= :favorite_from_ts) ", "")+`
+			`+tool.TernStr(filter.FavoriteUserId != nil && filter.FavoriteFromRevision != nil, "AND (fp.revision > :favorite_from_revision OR p.revision > :favorite_from_revision) ", "")+`
 			ORDER BY `+orderBy,
 		queryArgs,
 	)
@@ -115,7 +125,7 @@ func (s *Store) ReadPlaylist(externalTrn *sqlx.Tx, playlistId restApiV1.Playlist
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -164,17 +174,15 @@ func (s *Store) ReadPlaylist(externalTrn *sqlx.Tx, playlistId restApiV1.Playlist
 	return &playlist, nil
 }
 
-func (s *Store) GetDeletedPlaylistIds(externalTrn *sqlx.Tx, fromTs int64) ([]restApiV1.PlaylistId, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "GetDeletedPlaylistIds")
-	}
+func (s *Store) GetDeletedPlaylistIds(externalTrn *sqlx.Tx, fromRevision int64, toRevision int64) ([]restApiV1.PlaylistId, error) {
+	defer s.timeTrack(time.Now(), "GetDeletedPlaylistIds")
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -182,13 +190,14 @@ func (s *Store) GetDeletedPlaylistIds(externalTrn *sqlx.Tx, fromTs int64) ([]res
 	}
 
 	queryArgs := make(map[string]interface{})
-	queryArgs["from_ts"] = fromTs
+	queryArgs["from_revision"] = fromRevision
+	queryArgs["to_revision"] = toRevision
 	rows, err := txn.NamedQuery(
 		`SELECT
 				d.*
 			FROM deleted_playlist d
-			WHERE d.delete_ts >= :from_ts
-			ORDER BY d.delete_ts ASC
+			WHERE d.revision > :from_revision AND d.revision <= :to_revision
+			ORDER BY d.revision ASC
 		`,
 		queryArgs,
 	)
@@ -226,7 +235,7 @@ func (s *Store) CreateInternalPlaylist(externalTrn *sqlx.Tx, playlistId restApiV
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	// Store playlist
@@ -343,14 +352,24 @@ func (s *Store) CreateInternalPlaylist(externalTrn *sqlx.Tx, playlistId restApiV
 		}
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var playlist restApiV1.Playlist
 	playlistEntity.Fill(&playlist)
 
+	s.publishEvent(externalTrn, restApiV1.PlaylistCreatedEventType, string(playlist.Id), "", playlist.UpdateTs, revision)
+
 	return &playlist, nil
 }
 
@@ -364,7 +383,7 @@ func (s *Store) UpdatePlaylist(externalTrn *sqlx.Tx, playlistId restApiV1.Playli
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	now := time.Now().UnixNano()
@@ -498,14 +517,24 @@ func (s *Store) UpdatePlaylist(externalTrn *sqlx.Tx, playlistId restApiV1.Playli
 		}
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var playlist restApiV1.Playlist
 	playlistEntity.Fill(&playlist)
 
+	s.publishEvent(externalTrn, restApiV1.PlaylistUpdatedEventType, string(playlist.Id), "", playlist.UpdateTs, revision)
+
 	return &playlist, nil
 }
 
@@ -519,7 +548,7 @@ func (s *Store) AddSongToPlaylist(externalTrn *sqlx.Tx, playlistId restApiV1.Pla
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	now := time.Now().UnixNano()
@@ -594,14 +623,24 @@ func (s *Store) AddSongToPlaylist(externalTrn *sqlx.Tx, playlistId restApiV1.Pla
 		return nil, err
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var playlist restApiV1.Playlist
 	playlistEntity.Fill(&playlist)
 
+	s.publishEvent(externalTrn, restApiV1.PlaylistUpdatedEventType, string(playlist.Id), "", playlist.UpdateTs, revision)
+
 	return &playlist, nil
 }
 
@@ -620,7 +659,7 @@ func (s *Store) DeletePlaylist(externalTrn *sqlx.Tx, playlistId restApiV1.Playli
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	deleteTs := time.Now().UnixNano()
@@ -631,12 +670,20 @@ func (s *Store) DeletePlaylist(externalTrn *sqlx.Tx, playlistId restApiV1.Playli
 		return nil, err
 	}
 
+	// Keep songs, owners and favorites link in trash snapshot
+	playlist, err := s.ReadPlaylist(txn, playlistId)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := trashSnapshot{Playlist: playlist}
+
 	// Delete favorite playlist link
 	favoritePlaylistEntities, err := s.ReadFavoritePlaylists(txn, &restApiV1.FavoritePlaylistFilter{PlaylistId: &playlistId})
 	if err != nil {
 		return nil, err
 	}
 	for _, favoritePlaylistEntity := range favoritePlaylistEntities {
+		snapshot.FavoriteUserIds = append(snapshot.FavoriteUserIds, favoritePlaylistEntity.Id.UserId)
 		s.DeleteFavoritePlaylist(txn, restApiV1.FavoritePlaylistId{UserId: favoritePlaylistEntity.Id.UserId, PlaylistId: favoritePlaylistEntity.Id.PlaylistId})
 	}
 
@@ -673,13 +720,26 @@ func (s *Store) DeletePlaylist(externalTrn *sqlx.Tx, playlistId restApiV1.Playli
 		return nil, err
 	}
 
+	// Move playlist to trash
+	err = s.createTrashItem(txn, deleteTs, restApiV1.TrashItemTypePlaylist, string(playlistId), playlist.Name, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	var playlist restApiV1.Playlist
-	playlistEntity.Fill(&playlist)
+	s.publishEvent(externalTrn, restApiV1.PlaylistDeletedEventType, string(playlistId), "", deleteTs, revision)
 
-	return &playlist, nil
+	return playlist, nil
 }
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"time"
+)
+
+func (s *Store) ReadRadioStations(externalTrn *sqlx.Tx) ([]restApiV1.RadioStation, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	radioStationEntities := []entity.RadioStationEntity{}
+	err = txn.Select(&radioStationEntities, "SELECT * FROM radio_station ORDER BY creation_ts ASC")
+	if err != nil {
+		return nil, err
+	}
+
+	radioStations := []restApiV1.RadioStation{}
+	for _, radioStationEntity := range radioStationEntities {
+		var radioStation restApiV1.RadioStation
+		radioStationEntity.Fill(&radioStation)
+		radioStations = append(radioStations, radioStation)
+	}
+
+	return radioStations, nil
+}
+
+func (s *Store) ReadRadioStation(externalTrn *sqlx.Tx, playlistId restApiV1.PlaylistId) (*restApiV1.RadioStation, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	var radioStationEntity entity.RadioStationEntity
+	err = txn.Get(&radioStationEntity, "SELECT * FROM radio_station WHERE playlist_id = ?", playlistId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storeerror.ErrNotFound
+		}
+		return nil, err
+	}
+
+	var radioStation restApiV1.RadioStation
+	radioStationEntity.Fill(&radioStation)
+
+	return &radioStation, nil
+}
+
+// CreateRadioStation creates the radio station of a playlist, or updates it when it already exists
+func (s *Store) CreateRadioStation(externalTrn *sqlx.Tx, radioStationNew *restApiV1.RadioStationNew) (*restApiV1.RadioStation, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	// Check playlist
+	_, err = s.ReadPlaylist(txn, radioStationNew.PlaylistId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+
+	radioStationEntity := entity.RadioStationEntity{
+		PlaylistId: radioStationNew.PlaylistId,
+		CreationTs: now,
+		UpdateTs:   now,
+	}
+	radioStationEntity.LoadMeta(&radioStationNew.RadioStationMeta)
+
+	_, err = txn.NamedExec(`
+			INSERT INTO	radio_station (
+				playlist_id,
+				creation_ts,
+				update_ts,
+				format,
+				bitrate,
+				shuffle_fg
+			)
+			VALUES (
+				:playlist_id,
+				:creation_ts,
+				:update_ts,
+				:format,
+				:bitrate,
+				:shuffle_fg
+			)
+			ON CONFLICT (playlist_id) DO UPDATE SET
+				update_ts = :update_ts,
+				format = :format,
+				bitrate = :bitrate,
+				shuffle_fg = :shuffle_fg
+	`, &radioStationEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	radioStation, err := s.ReadRadioStation(txn, radioStationNew.PlaylistId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return radioStation, nil
+}
+
+func (s *Store) DeleteRadioStation(externalTrn *sqlx.Tx, playlistId restApiV1.PlaylistId) (*restApiV1.RadioStation, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	radioStation, err := s.ReadRadioStation(txn, playlistId)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = txn.Exec("DELETE FROM radio_station WHERE playlist_id = ?", playlistId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return radioStation, nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// currentRevision returns the revision of the last change, the rows of the synchronized tables getting the next revision
+// on every creation, update or deletion (by the triggers of the revision migration).
+// The change events carry it, for the subscribers to know if they already got the change.
+func (s *Store) currentRevision(txn *sqlx.Tx) (int64, error) {
+	var revision int64
+	err := txn.Get(&revision, "SELECT value FROM revision")
+	return revision, err
+}
+
+// fromRevision returns the revision after which the changes are to be sent to a client, translating the wall-clock
+// timestamp sent by the clients synchronized before the revisions (always greater than the current revision)
+func (s *Store) fromRevision(txn *sqlx.Tx, from int64, currentRevision int64) (int64, error) {
+	if from <= currentRevision {
+		return from, nil
+	}
+
+	// Revision preceding the first change made since the timestamp
+	var revision int64
+	err := txn.Get(
+		&revision,
+		`SELECT coalesce(min(revision) - 1, ?) FROM (
+			SELECT revision FROM album WHERE update_ts >= ?
+			UNION ALL SELECT revision FROM deleted_album WHERE delete_ts >= ?
+			UNION ALL SELECT revision FROM artist WHERE update_ts >= ?
+			UNION ALL SELECT revision FROM deleted_artist WHERE delete_ts >= ?
+			UNION ALL SELECT revision FROM favorite_playlist WHERE update_ts >= ?
+			UNION ALL SELECT revision FROM deleted_favorite_playlist WHERE delete_ts >= ?
+			UNION ALL SELECT revision FROM favorite_song WHERE update_ts >= ?
+			UNION ALL SELECT revision FROM deleted_favorite_song WHERE delete_ts >= ?
+			UNION ALL SELECT revision FROM playlist WHERE update_ts >= ? OR content_update_ts >= ?
+			UNION ALL SELECT revision FROM deleted_playlist WHERE delete_ts >= ?
+			UNION ALL SELECT revision FROM song WHERE update_ts >= ?
+			UNION ALL SELECT revision FROM deleted_song WHERE delete_ts >= ?
+			UNION ALL SELECT revision FROM user WHERE update_ts >= ?
+			UNION ALL SELECT revision FROM deleted_user WHERE delete_ts >= ?
+		)`,
+		currentRevision, from, from, from, from, from, from, from, from, from, from, from, from, from, from, from,
+	)
+	return revision, err
+}
+
+// revisionChangesQuery selects the revision of every change made after :from_revision, up to :to_revision
+const revisionChangesQuery = `
+	SELECT revision FROM album WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM deleted_album WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM artist WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM deleted_artist WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM favorite_playlist WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM deleted_favorite_playlist WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM favorite_song WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM deleted_favorite_song WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM playlist WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM deleted_playlist WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM song WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM deleted_song WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM user WHERE revision > :from_revision AND revision <= :to_revision
+	UNION ALL SELECT revision FROM deleted_user WHERE revision > :from_revision AND revision <= :to_revision
+`
+
+// countChanges returns the number of changes made after the from revision, up to the to revision
+func (s *Store) countChanges(txn *sqlx.Tx, fromRevision int64, toRevision int64) (int64, error) {
+	query, args, err := sqlx.Named(
+		`SELECT count(*) FROM (`+revisionChangesQuery+`)`,
+		map[string]interface{}{"from_revision": fromRevision, "to_revision": toRevision},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err = txn.Get(&count, txn.Rebind(query), args...)
+	return count, err
+}
+
+// limitRevision returns the revision of the limit-th change made after the from revision, the to revision when fewer
+func (s *Store) limitRevision(txn *sqlx.Tx, fromRevision int64, toRevision int64, limit int64) (int64, error) {
+	query, args, err := sqlx.Named(
+		`SELECT coalesce((SELECT revision FROM (`+revisionChangesQuery+`) ORDER BY revision LIMIT 1 OFFSET :offset), :to_revision)`,
+		map[string]interface{}{"from_revision": fromRevision, "to_revision": toRevision, "offset": limit - 1},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var revision int64
+	err = txn.Get(&revision, txn.Rebind(query), args...)
+	return revision, err
+}
@@ -0,0 +1,402 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+func (s *Store) ReadShares(externalTrn *sqlx.Tx, filter *restApiV1.ShareFilter) ([]restApiV1.Share, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	queryArgs := make(map[string]interface{})
+	if filter.OwnerUserId != nil {
+		queryArgs["owner_user_id"] = *filter.OwnerUserId
+	}
+
+	rows, err := txn.NamedQuery(
+		`SELECT
+				s.*
+			FROM share s
+			WHERE 1>0
+			`+tool.TernStr(filter.OwnerUserId != nil, "AND s.owner_user_id = :owner_user_id ", "")+`
+			ORDER BY s.creation_ts ASC
+		`,
+		queryArgs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []restApiV1.Share{}
+	for rows.Next() {
+		var shareEntity entity.ShareEntity
+		err = rows.StructScan(&shareEntity)
+		if err != nil {
+			return nil, err
+		}
+
+		var share restApiV1.Share
+		shareEntity.Fill(&share)
+		shares = append(shares, share)
+	}
+
+	return shares, nil
+}
+
+func (s *Store) ReadShare(externalTrn *sqlx.Tx, shareId restApiV1.ShareId) (*restApiV1.Share, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	shareEntity, err := s.readShareEntity(txn, shareId)
+	if err != nil {
+		return nil, err
+	}
+
+	var share restApiV1.Share
+	shareEntity.Fill(&share)
+
+	return &share, nil
+}
+
+func (s *Store) readShareEntity(txn *sqlx.Tx, shareId restApiV1.ShareId) (*entity.ShareEntity, error) {
+	var shareEntity entity.ShareEntity
+	err := txn.Get(&shareEntity, "SELECT * FROM share WHERE share_id = ?", shareId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storeerror.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &shareEntity, nil
+}
+
+// CheckSharePassword checks the password giving access to a share
+func (s *Store) CheckSharePassword(externalTrn *sqlx.Tx, shareId restApiV1.ShareId, password string) (bool, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return false, err
+		}
+		defer txn.Rollback()
+	}
+
+	shareEntity, err := s.readShareEntity(txn, shareId)
+	if err != nil {
+		return false, err
+	}
+
+	return shareEntity.PasswordHash != "" && tool.CheckPasswordHash(shareEntity.PasswordHash, password), nil
+}
+
+// hashSharePasswords hashes the plaintext passwords of the shares created before the passwords were hashed
+func (s *Store) hashSharePasswords() error {
+	txn, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer s.rollbackTransaction(txn)
+
+	// Shares to upgrade are listed by the 0008 update script
+	var upgradeTableCount int64
+	err = txn.Get(&upgradeTableCount, "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'share_password_upgrade'")
+	if err != nil {
+		return err
+	}
+	if upgradeTableCount == 0 {
+		return nil
+	}
+
+	var shareEntities []entity.ShareEntity
+	err = txn.Select(&shareEntities, "SELECT s.* FROM share s JOIN share_password_upgrade u ON u.share_id = s.share_id")
+	if err != nil {
+		return err
+	}
+
+	for _, shareEntity := range shareEntities {
+		passwordHash, err := tool.HashPassword(shareEntity.PasswordHash)
+		if err != nil {
+			return err
+		}
+		_, err = txn.Exec("UPDATE share SET password_hash = ? WHERE share_id = ?", passwordHash, shareEntity.ShareId)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = txn.Exec("DROP TABLE share_password_upgrade")
+	if err != nil {
+		return err
+	}
+
+	if len(shareEntities) > 0 {
+		logrus.Infof("%d share passwords hashed", len(shareEntities))
+	}
+
+	return s.commitTransaction(txn)
+}
+
+// ReadShareContent returns the shared item with its songs
+func (s *Store) ReadShareContent(externalTrn *sqlx.Tx, shareId restApiV1.ShareId) (*restApiV1.ShareContent, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	share, err := s.ReadShare(txn, shareId)
+	if err != nil {
+		return nil, err
+	}
+
+	shareContent := restApiV1.ShareContent{
+		Id:           share.Id,
+		ItemType:     share.ItemType,
+		ExpirationTs: share.ExpirationTs,
+		DownloadFg:   share.DownloadFg,
+		Songs:        []restApiV1.ShareSong{},
+	}
+
+	var songs []restApiV1.Song
+	switch share.ItemType {
+	case restApiV1.ShareItemTypePlaylist:
+		playlist, err := s.ReadPlaylist(txn, restApiV1.PlaylistId(share.ItemId))
+		if err != nil {
+			return nil, err
+		}
+		shareContent.Name = playlist.Name
+		for _, songId := range playlist.SongIds {
+			song, err := s.ReadSong(txn, songId)
+			if err != nil {
+				return nil, err
+			}
+			songs = append(songs, *song)
+		}
+	case restApiV1.ShareItemTypeAlbum:
+		albumId := restApiV1.AlbumId(share.ItemId)
+		album, err := s.ReadAlbum(txn, albumId)
+		if err != nil {
+			return nil, err
+		}
+		shareContent.Name = album.Name
+		songs, err = s.ReadAlbumSongs(txn, albumId)
+		if err != nil {
+			return nil, err
+		}
+	case restApiV1.ShareItemTypeSong:
+		song, err := s.ReadSong(txn, restApiV1.SongId(share.ItemId))
+		if err != nil {
+			return nil, err
+		}
+		shareContent.Name = song.Name
+		songs = append(songs, *song)
+	default:
+		return nil, storeerror.ErrNotFound
+	}
+
+	for _, song := range songs {
+		shareSong := restApiV1.ShareSong{
+			Id:          song.Id,
+			Name:        song.Name,
+			Format:      song.Format,
+			TrackNumber: song.TrackNumber,
+			ArtistNames: []string{},
+		}
+		if song.AlbumId != restApiV1.UnknownAlbumId {
+			album, err := s.ReadAlbum(txn, song.AlbumId)
+			if err != nil && err != storeerror.ErrNotFound {
+				return nil, err
+			}
+			if album != nil {
+				shareSong.AlbumName = album.Name
+			}
+		}
+		for _, artistId := range song.ArtistIds {
+			artist, err := s.ReadArtist(txn, artistId)
+			if err != nil {
+				if err == storeerror.ErrNotFound {
+					continue
+				}
+				return nil, err
+			}
+			shareSong.ArtistNames = append(shareSong.ArtistNames, artist.Name)
+		}
+		shareContent.Songs = append(shareContent.Songs, shareSong)
+	}
+
+	return &shareContent, nil
+}
+
+func (s *Store) CreateShare(externalTrn *sqlx.Tx, userId restApiV1.UserId, shareNew *restApiV1.ShareNew) (*restApiV1.Share, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	// Check shared item
+	switch shareNew.ItemType {
+	case restApiV1.ShareItemTypePlaylist:
+		_, err = s.ReadPlaylist(txn, restApiV1.PlaylistId(shareNew.ItemId))
+	case restApiV1.ShareItemTypeAlbum:
+		_, err = s.ReadAlbum(txn, restApiV1.AlbumId(shareNew.ItemId))
+	case restApiV1.ShareItemTypeSong:
+		_, err = s.ReadSong(txn, restApiV1.SongId(shareNew.ItemId))
+	default:
+		err = storeerror.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The share id is the secret giving access to the shared item
+	b := make([]byte, 16)
+	_, err = rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	shareEntity := entity.ShareEntity{
+		ShareId:     restApiV1.ShareId(fmt.Sprintf("%x", b)),
+		CreationTs:  time.Now().UnixNano(),
+		OwnerUserId: userId,
+	}
+	shareEntity.LoadMeta(&shareNew.ShareMeta)
+
+	// Only the password hash is stored
+	if shareNew.Password != "" {
+		shareEntity.PasswordHash, err = tool.HashPassword(shareNew.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = txn.NamedExec(`
+			INSERT INTO	share (
+				share_id,
+				creation_ts,
+				owner_user_id,
+				item_type,
+				item_id,
+				expiration_ts,
+				password_hash,
+				download_fg
+			)
+			VALUES (
+				:share_id,
+				:creation_ts,
+				:owner_user_id,
+				:item_type,
+				:item_id,
+				:expiration_ts,
+				:password_hash,
+				:download_fg
+			)
+	`, &shareEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var share restApiV1.Share
+	shareEntity.Fill(&share)
+
+	return &share, nil
+}
+
+func (s *Store) DeleteShare(externalTrn *sqlx.Tx, shareId restApiV1.ShareId) (*restApiV1.Share, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	share, err := s.ReadShare(txn, shareId)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = txn.Exec("DELETE FROM share WHERE share_id = ?", shareId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return share, nil
+}
+
+// deleteItemShares deletes the shares of an item which no longer exists
+func (s *Store) deleteItemShares(txn *sqlx.Tx, itemType restApiV1.ShareItemType, itemId string) error {
+	_, err := txn.Exec("DELETE FROM share WHERE item_type = ? AND item_id = ?", itemType, itemId)
+	return err
+}
+
+// deleteUserShares deletes the shares created by a user
+func (s *Store) deleteUserShares(txn *sqlx.Tx, userId restApiV1.UserId) error {
+	_, err := txn.Exec("DELETE FROM share WHERE owner_user_id = ?", userId)
+	return err
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"github.com/jmoiron/sqlx"
 	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/internal/srv/metrics"
 	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
@@ -14,7 +15,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"sort"
 	"time"
 )
@@ -40,16 +40,14 @@ func (j *JsonArtists) Scan(value interface{}) error {
 }
 
 func (s *Store) ReadSongs(externalTrn *sqlx.Tx, filter *restApiV1.SongFilter) ([]restApiV1.Song, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "ReadSongs")
-	}
+	defer s.timeTrack(time.Now(), "ReadSongs")
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -60,6 +58,12 @@ func (s *Store) ReadSongs(externalTrn *sqlx.Tx, filter *restApiV1.SongFilter) ([
 	if filter.FromTs != nil {
 		queryArgs["from_ts"] = *filter.FromTs
 	}
+	if filter.FromRevision != nil {
+		queryArgs["from_revision"] = *filter.FromRevision
+	}
+	if filter.ToRevision != nil {
+		queryArgs["to_revision"] = *filter.ToRevision
+	}
 	if filter.AlbumId != nil {
 		queryArgs["album_id"] = *filter.AlbumId
 	}
@@ -69,6 +73,9 @@ func (s *Store) ReadSongs(externalTrn *sqlx.Tx, filter *restApiV1.SongFilter) ([
 	if filter.Favorite != nil {
 		queryArgs["favorite_user_id"] = filter.Favorite.UserId
 		queryArgs["favorite_from_ts"] = filter.Favorite.FromTs
+		if filter.Favorite.FromRevision != nil {
+			queryArgs["favorite_from_revision"] = *filter.Favorite.FromRevision
+		}
 	}
 
 	orderBy := "s.song_id ASC"
@@ -100,10 +107,13 @@ func (s *Store) ReadSongs(externalTrn *sqlx.Tx, filter *restApiV1.SongFilter) ([
 			FROM song s
 			`+tool.IfStr(filter.ArtistId != nil, "JOIN artist_song asg2 ON asg2.song_id = s.song_id AND asg2.artist_id = :artist_id ")+`
 			`+tool.IfStr(filter.Favorite != nil, `JOIN favorite_song fs ON fs.song_id = s.song_id AND fs.user_id = :favorite_user_id AND (fs.update_ts >= :favorite_from_ts OR s.update_ts >= :favorite_from_ts ) `)+`
+			`+tool.IfStr(filter.Favorite != nil && filter.Favorite.FromRevision != nil, `AND (fs.revision > :favorite_from_revision OR s.revision > :favorite_from_revision ) `)+`
 			LEFT JOIN artist_song asg ON asg.song_id = s.song_id
 			LEFT JOIN artist a ON a.artist_id = asg.artist_id
 			WHERE 1>0
 			`+tool.IfStr(filter.FromTs != nil, "AND s.update_ts >= :from_ts ")+`
+			`+tool.IfStr(filter.FromRevision != nil, "AND s.revision > :from_revision ")+`
+			`+tool.IfStr(filter.ToRevision != nil, "AND s.revision <= :to_revision ")+`
 			`+tool.IfStr(filter.AlbumId != nil, "AND s.album_id = :album_id ")+`
 			GROUP BY
 				s.song_id,
@@ -167,7 +177,7 @@ func (s *Store) ReadSong(externalTrn *sqlx.Tx, songId restApiV1.SongId) (*restAp
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -200,26 +210,69 @@ func (s *Store) ReadSong(externalTrn *sqlx.Tx, songId restApiV1.SongId) (*restAp
 	return &song, nil
 }
 
-func (s *Store) ReadSongContent(song *restApiV1.Song) (*os.File, error) {
-	file, err := os.Open(s.GetSongFileName(song))
-	//content, err := ioutil.ReadFile(s.GetSongFileName(song))
+func (s *Store) ReadSongContent(song *restApiV1.Song) (SongContent, error) {
+	return s.storage.Open(songStorageKey(song.Id, song.Format))
+}
+
+// ReadSongContentUrl returns a presigned url reading the song content directly from the storage,
+// "" when the storage can't presign urls or when presigned redirects are disabled
+func (s *Store) ReadSongContentUrl(song *restApiV1.Song, contentDisposition string) (string, error) {
+	if !s.serverConfig.S3PresignedRedirect {
+		return "", nil
+	}
+	return s.storage.PresignedUrl(songStorageKey(song.Id, song.Format), songContentUrlExpiry, song.Format.MimeType(), contentDisposition)
+}
+
+// writeSongContent stores a new song content, its tags being updated on a local copy beforehand.
+// The song content has to be stored before the song creation is committed, it is removed if the transaction is rolled back.
+func (s *Store) writeSongContent(externalTrn *sqlx.Tx, songEntity *entity.SongEntity, content []byte) error {
+	err := os.MkdirAll(s.serverConfig.GetCompleteConfigUploadsDirName(), 0770)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	file, err := ioutil.TempFile(s.serverConfig.GetCompleteConfigUploadsDirName(), "song-*"+songEntity.Format.Extension())
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
 
-	return file, nil
-}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
 
-func (s *Store) GetSongDirName(songId restApiV1.SongId) string {
-	return filepath.Join(s.serverConfig.GetCompleteConfigSongsDirName(), string(songId)[len(songId)-2:])
-}
+	// Update tags in song content
+	err = s.updateSongContentTagFile(externalTrn, songEntity, file.Name())
+	if err != nil {
+		return err
+	}
 
-func (s *Store) getSongFileName(songId restApiV1.SongId, songFormat restApiV1.SongFormat) string {
-	return filepath.Join(s.GetSongDirName(songId), string(songId)+songFormat.Extension())
-}
+	file, err = os.Open(file.Name())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
 
-func (s *Store) GetSongFileName(song *restApiV1.Song) string {
-	return filepath.Join(s.GetSongDirName(song.Id), string(song.Id)+song.Format.Extension())
+	storageKey := songStorageKey(songEntity.SongId, songEntity.Format)
+	err = s.storage.Write(storageKey, file, fileInfo.Size())
+	if err != nil {
+		return err
+	}
+	s.afterRollback(externalTrn, func() {
+		err := s.storage.Remove(storageKey)
+		if err != nil && !os.IsNotExist(err) {
+			logrus.Warnf("Unable to remove the content of the song %s not created, orphan file left: %v", songEntity.SongId, err)
+		}
+	})
+
+	return nil
 }
 
 func (s *Store) CreateSong(externalTrn *sqlx.Tx, songNew *restApiV1.SongNew, check bool) (*restApiV1.Song, error) {
@@ -232,7 +285,7 @@ func (s *Store) CreateSong(externalTrn *sqlx.Tx, songNew *restApiV1.SongNew, che
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	// Store song
@@ -316,21 +369,9 @@ func (s *Store) CreateSong(externalTrn *sqlx.Tx, songNew *restApiV1.SongNew, che
 		return nil, err
 	}
 
-	// Write song content
-	err = os.MkdirAll(s.GetSongDirName(songEntity.SongId), 0770)
-	if err != nil {
-		return nil, err
-	}
-	err = ioutil.WriteFile(s.getSongFileName(songEntity.SongId, songEntity.Format), songNew.Content, 0660)
-	if err != nil {
-		return nil, err
-	}
-
-	// Update tags in song content
-	err = s.UpdateSongContentTag(txn, &songEntity)
+	// Write song content with updated tags
+	err = s.writeSongContent(txn, &songEntity, songNew.Content)
 	if err != nil {
-		// If tags not updated, delete the song file
-		os.Remove(s.getSongFileName(songEntity.SongId, songEntity.Format))
 		return nil, err
 	}
 
@@ -349,19 +390,42 @@ func (s *Store) CreateSong(externalTrn *sqlx.Tx, songNew *restApiV1.SongNew, che
 		return nil, err
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var song restApiV1.Song
 	songEntity.Fill(&song)
 	song.ArtistIds = artistIds
 
+	s.publishEvent(externalTrn, restApiV1.SongCreatedEventType, string(song.Id), "", song.UpdateTs, revision)
+
 	return &song, nil
 }
 
 func (s *Store) CreateSongFromRawContent(externalTrn *sqlx.Tx, raw io.ReadCloser, lastAlbumId restApiV1.AlbumId) (*restApiV1.Song, error) {
+	defer s.timeTrack(time.Now(), "CreateSongFromRawContent")
+
+	song, err := s.createSongFromRawContent(externalTrn, raw, lastAlbumId)
+	if err != nil {
+		metrics.Imports.Inc(metrics.ImportFailure)
+	} else {
+		metrics.Imports.Inc(metrics.ImportSuccess)
+	}
+
+	return song, err
+}
+
+func (s *Store) createSongFromRawContent(externalTrn *sqlx.Tx, raw io.ReadCloser, lastAlbumId restApiV1.AlbumId) (*restApiV1.Song, error) {
 	var err error
 
 	// Check available transaction
@@ -371,7 +435,7 @@ func (s *Store) CreateSongFromRawContent(externalTrn *sqlx.Tx, raw io.ReadCloser
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	var content []byte
@@ -408,7 +472,10 @@ func (s *Store) CreateSongFromRawContent(externalTrn *sqlx.Tx, raw io.ReadCloser
 	logrus.Debugf("Commit")
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 	logrus.Debugf("End commit")
 
@@ -425,7 +492,7 @@ func (s *Store) UpdateSong(externalTrn *sqlx.Tx, songId restApiV1.SongId, songMe
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	// Retrieve song
@@ -544,10 +611,7 @@ func (s *Store) UpdateSong(externalTrn *sqlx.Tx, songId restApiV1.SongId, songMe
 	}
 
 	// Update tags in song content
-	err = s.UpdateSongContentTag(txn, &songEntity)
-	if err != nil {
-		return nil, err
-	}
+	s.updateSongContentTagOnCommit(txn, songEntity)
 
 	// Refresh album artists
 	if songEntity.AlbumId != restApiV1.UnknownAlbumId && (artistIdsChanged || updateArtistMetaArtistId != nil || songEntity.AlbumId != songOldAlbumId) {
@@ -563,22 +627,101 @@ func (s *Store) UpdateSong(externalTrn *sqlx.Tx, songId restApiV1.SongId, songMe
 		}
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var song restApiV1.Song
 	songEntity.Fill(&song)
 
+	s.publishEvent(externalTrn, restApiV1.SongUpdatedEventType, string(song.Id), "", song.UpdateTs, revision)
+
 	return &song, nil
 }
 
-func (s *Store) DeleteSong(externalTrn *sqlx.Tx, songId restApiV1.SongId) (*restApiV1.Song, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "DeleteSong")
+// UpdateSongs applies the same partial changes to every song of songsPatch in one transaction
+func (s *Store) UpdateSongs(externalTrn *sqlx.Tx, songsPatch *restApiV1.SongsPatch) ([]restApiV1.Song, error) {
+	defer s.timeTrack(time.Now(), "UpdateSongs")
+
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	// Check album and artists
+	if songsPatch.AlbumId != nil && *songsPatch.AlbumId != restApiV1.UnknownAlbumId {
+		_, err = s.ReadAlbum(txn, *songsPatch.AlbumId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, artistIds := range [][]restApiV1.ArtistId{songsPatch.ArtistIds, songsPatch.AddArtistIds} {
+		for _, artistId := range artistIds {
+			_, err = s.ReadArtist(txn, artistId)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Check songs, before updating any of them
+	songMetas := make([]*restApiV1.SongMeta, 0, len(songsPatch.SongIds))
+	for _, songId := range songsPatch.SongIds {
+		song, err := s.ReadSong(txn, songId)
+		if err != nil {
+			return nil, err
+		}
+
+		songMeta := song.SongMeta.Copy()
+		songsPatch.Apply(songMeta)
+		songMetas = append(songMetas, songMeta)
+	}
+
+	// Update songs, their tags being rewritten once the transaction is committed
+	songs := make([]restApiV1.Song, 0, len(songsPatch.SongIds))
+	for ind, songId := range songsPatch.SongIds {
+		_, err = s.UpdateSong(txn, songId, songMetas[ind], nil, false)
+		if err != nil {
+			return nil, err
+		}
+
+		song, err := s.ReadSong(txn, songId)
+		if err != nil {
+			return nil, err
+		}
+		songs = append(songs, *song)
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	return songs, nil
+}
+
+func (s *Store) DeleteSong(externalTrn *sqlx.Tx, songId restApiV1.SongId) (*restApiV1.Song, error) {
+	defer s.timeTrack(time.Now(), "DeleteSong")
+
 	var err error
 
 	// Check available transaction
@@ -588,7 +731,7 @@ func (s *Store) DeleteSong(externalTrn *sqlx.Tx, songId restApiV1.SongId) (*rest
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	deleteTs := time.Now().UnixNano()
@@ -599,6 +742,23 @@ func (s *Store) DeleteSong(externalTrn *sqlx.Tx, songId restApiV1.SongId) (*rest
 		return nil, err
 	}
 
+	// Keep playlists and favorites link in trash snapshot
+	snapshot := trashSnapshot{Song: song}
+
+	playlistSongEntities := []entity.PlaylistSongEntity{}
+	err = txn.Select(&playlistSongEntities, "SELECT * FROM playlist_song WHERE song_id = ?", songId)
+	if err != nil {
+		return nil, err
+	}
+	for _, playlistSongEntity := range playlistSongEntities {
+		snapshot.PlaylistSongs = append(snapshot.PlaylistSongs, trashPlaylistPosition{PlaylistId: playlistSongEntity.PlaylistId, Position: playlistSongEntity.Position})
+	}
+
+	err = txn.Select(&snapshot.FavoriteUserIds, "SELECT user_id FROM favorite_song WHERE song_id = ?", songId)
+	if err != nil {
+		return nil, err
+	}
+
 	// Delete playlists link
 	queryArgs := make(map[string]interface{})
 	queryArgs["delete_ts"] = deleteTs
@@ -702,31 +862,47 @@ func (s *Store) DeleteSong(externalTrn *sqlx.Tx, songId restApiV1.SongId) (*rest
 		}
 	}
 
-	// Delete song content
-	err = os.Remove(s.getSongFileName(songId, song.Format))
+	// Move song to trash
+	err = s.createTrashItem(txn, deleteTs, restApiV1.TrashItemTypeSong, string(songId), song.Name, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	// Move song content to trash, once the song deletion is committed
+	s.afterCommit(txn, func() {
+		err := s.storage.Move(songStorageKey(songId, song.Format), trashSongStorageKey(songId, song.Format))
+		if err != nil {
+			logrus.Warnf("Unable to move the content of the deleted song %s to trash, orphan file left: %v", songId, err)
+		}
+	})
+
+	revision, err := s.currentRevision(txn)
 	if err != nil {
 		return nil, err
 	}
 
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	s.publishEvent(externalTrn, restApiV1.SongDeletedEventType, string(songId), "", deleteTs, revision)
+
 	return song, nil
 }
 
-func (s *Store) GetDeletedSongIds(externalTrn *sqlx.Tx, fromTs int64) ([]restApiV1.SongId, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "GetDeletedSongIds")
-	}
+func (s *Store) GetDeletedSongIds(externalTrn *sqlx.Tx, fromRevision int64, toRevision int64) ([]restApiV1.SongId, error) {
+	defer s.timeTrack(time.Now(), "GetDeletedSongIds")
 
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -734,13 +910,14 @@ func (s *Store) GetDeletedSongIds(externalTrn *sqlx.Tx, fromTs int64) ([]restApi
 	}
 
 	queryArgs := make(map[string]interface{})
-	queryArgs["from_ts"] = fromTs
+	queryArgs["from_revision"] = fromRevision
+	queryArgs["to_revision"] = toRevision
 	rows, err := txn.NamedQuery(
 		`SELECT
 				d.*
 			FROM deleted_song d
-			WHERE d.delete_ts >= :from_ts
-			ORDER BY d.delete_ts ASC
+			WHERE d.revision > :from_revision AND d.revision <= :to_revision
+			ORDER BY d.revision ASC
 		`,
 		queryArgs,
 	)
@@ -766,14 +943,37 @@ func (s *Store) GetDeletedSongIds(externalTrn *sqlx.Tx, fromTs int64) ([]restApi
 
 // UpdateSongContentTag update tags in song content
 func (s *Store) UpdateSongContentTag(externalTrn *sqlx.Tx, songEntity *entity.SongEntity) error {
+	// Ogg tags are not written yet, no need to edit the song content
+	if songEntity.Format == restApiV1.SongFormatOgg {
+		return nil
+	}
+
+	return s.storage.Edit(songStorageKey(songEntity.SongId, songEntity.Format), func(filename string) error {
+		return s.updateSongContentTagFile(externalTrn, songEntity, filename)
+	})
+}
+
+// updateSongContentTagOnCommit update tags in song content once the transaction is committed, the song content
+// being downloaded and saved back without holding the database
+func (s *Store) updateSongContentTagOnCommit(txn *sqlx.Tx, songEntity entity.SongEntity) {
+	s.afterCommit(txn, func() {
+		err := s.UpdateSongContentTag(nil, &songEntity)
+		if err != nil {
+			logrus.Warnf("Unable to update the tags of the song %s, run the check command with -repair to fix them: %v", songEntity.SongId, err)
+		}
+	})
+}
+
+// updateSongContentTagFile update tags in a local song file
+func (s *Store) updateSongContentTagFile(externalTrn *sqlx.Tx, songEntity *entity.SongEntity, filename string) error {
 
 	switch songEntity.Format {
 	case restApiV1.SongFormatFlac:
-		return s.updateSongContentFlacTag(externalTrn, songEntity)
+		return s.updateSongContentFlacTag(externalTrn, songEntity, filename)
 	case restApiV1.SongFormatMp3:
-		return s.updateSongContentMp3Tag(externalTrn, songEntity)
+		return s.updateSongContentMp3Tag(externalTrn, songEntity, filename)
 	case restApiV1.SongFormatOgg:
-		return s.updateSongContentOggTag(externalTrn, songEntity)
+		return s.updateSongContentOggTag(externalTrn, songEntity, filename)
 	}
 	return nil
 }
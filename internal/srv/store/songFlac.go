@@ -53,7 +53,7 @@ func (s *Store) createSongNewFromFlacContent(externalTrn *sqlx.Tx, content []byt
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	// Extract bit depth
@@ -144,7 +144,7 @@ func (s *Store) createSongNewFromFlacContent(externalTrn *sqlx.Tx, content []byt
 	// Extract artists
 	var artistNames []string
 	for _, vorbisArtistName := range vorbisArtistNames {
-		artistNames = append(artistNames, strings.FieldsFunc(vorbisArtistName, func(r rune) bool { return r == ',' || r == ';' || r == ';' })...)
+		artistNames = append(artistNames, strings.FieldsFunc(vorbisArtistName, func(r rune) bool { return r == ',' || r == ';' })...)
 	}
 
 	// Find Artist IDs
@@ -173,16 +173,19 @@ func (s *Store) createSongNewFromFlacContent(externalTrn *sqlx.Tx, content []byt
 
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return songNew, nil
 }
 
-func (s *Store) updateSongContentFlacTag(externalTrn *sqlx.Tx, songEntity *entity.SongEntity) error {
+func (s *Store) updateSongContentFlacTag(externalTrn *sqlx.Tx, songEntity *entity.SongEntity, filename string) error {
 
 	// region Extract tags
-	flacFile, err := flac.ParseFile(s.getSongFileName(songEntity.SongId, songEntity.Format))
+	flacFile, err := flac.ParseFile(filename)
 	if err != nil {
 		return err
 	}
@@ -215,7 +218,7 @@ func (s *Store) updateSongContentFlacTag(externalTrn *sqlx.Tx, songEntity *entit
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return err
 		}
@@ -263,12 +266,7 @@ func (s *Store) updateSongContentFlacTag(externalTrn *sqlx.Tx, songEntity *entit
 	} else {
 		flacFile.Meta = append(flacFile.Meta, &metaDataBlock)
 	}
-	flacFile.Save(s.getSongFileName(songEntity.SongId, songEntity.Format))
-
-	// Commit transaction
-	if externalTrn == nil {
-		txn.Commit()
-	}
+	flacFile.Save(filename)
 
 	// endregion
 	return nil
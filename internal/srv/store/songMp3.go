@@ -38,7 +38,7 @@ func (s *Store) createSongNewFromMp3Content(externalTrn *sqlx.Tx, content []byte
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	// Extract title
@@ -84,7 +84,7 @@ func (s *Store) createSongNewFromMp3Content(externalTrn *sqlx.Tx, content []byte
 	// Extract artists
 	var artistNames []string
 	for _, contatArtistNames := range strings.Split(tag.Artist(), " - ") {
-		artistNames = append(artistNames, strings.FieldsFunc(contatArtistNames, func(r rune) bool { return r == ',' || r == ';' || r == ';' })...)
+		artistNames = append(artistNames, strings.FieldsFunc(contatArtistNames, func(r rune) bool { return r == ',' || r == ';' })...)
 	}
 
 	// Find Artist IDs
@@ -112,15 +112,18 @@ func (s *Store) createSongNewFromMp3Content(externalTrn *sqlx.Tx, content []byte
 
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return songNew, nil
 }
 
-func (s *Store) updateSongContentMp3Tag(externalTrn *sqlx.Tx, songEntity *entity.SongEntity) error {
+func (s *Store) updateSongContentMp3Tag(externalTrn *sqlx.Tx, songEntity *entity.SongEntity, filename string) error {
 	// Extract song meta from tags
-	tag, err := id3v2.Open(s.getSongFileName(songEntity.SongId, songEntity.Format), id3v2.Options{Parse: true})
+	tag, err := id3v2.Open(filename, id3v2.Options{Parse: true})
 	if err != nil {
 		return err
 	}
@@ -134,7 +137,7 @@ func (s *Store) updateSongContentMp3Tag(externalTrn *sqlx.Tx, songEntity *entity
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return err
 		}
@@ -183,10 +186,5 @@ func (s *Store) updateSongContentMp3Tag(externalTrn *sqlx.Tx, songEntity *entity
 	}
 	// endregion
 
-	// Commit transaction
-	if externalTrn == nil {
-		txn.Commit()
-	}
-
 	return nil
 }
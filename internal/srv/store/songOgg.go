@@ -21,7 +21,7 @@ func (s *Store) createSongNewFromOggContent(externalTrn *sqlx.Tx, content []byte
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	songNew := &restApiV1.SongNew{
@@ -40,13 +40,16 @@ func (s *Store) createSongNewFromOggContent(externalTrn *sqlx.Tx, content []byte
 
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return songNew, nil
 }
 
-func (s *Store) updateSongContentOggTag(externalTrn *sqlx.Tx, songEntity *entity.SongEntity) error {
+func (s *Store) updateSongContentOggTag(externalTrn *sqlx.Tx, songEntity *entity.SongEntity, filename string) error {
 	// TODO
 	return nil
 }
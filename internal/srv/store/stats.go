@@ -0,0 +1,59 @@
+package store
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/restApiV1"
+	"time"
+)
+
+// LibraryStats gives the size of the library
+type LibraryStats struct {
+	SongCount   int64
+	AlbumCount  int64
+	ArtistCount int64
+	Formats     []FormatStats
+}
+
+// FormatStats gives the number and the total size of the songs of a format
+type FormatStats struct {
+	Format    restApiV1.SongFormat `db:"format"`
+	SongCount int64                `db:"song_count"`
+	Size      int64                `db:"size"`
+}
+
+func (s *Store) ReadLibraryStats(externalTrn *sqlx.Tx) (*LibraryStats, error) {
+	defer s.timeTrack(time.Now(), "ReadLibraryStats")
+
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	var libraryStats LibraryStats
+
+	err = txn.Get(&libraryStats.SongCount, "SELECT count(*) FROM song")
+	if err != nil {
+		return nil, err
+	}
+	err = txn.Get(&libraryStats.AlbumCount, "SELECT count(*) FROM album")
+	if err != nil {
+		return nil, err
+	}
+	err = txn.Get(&libraryStats.ArtistCount, "SELECT count(*) FROM artist")
+	if err != nil {
+		return nil, err
+	}
+	err = txn.Select(&libraryStats.Formats, "SELECT format, count(*) AS song_count, sum(size) AS size FROM song GROUP BY format ORDER BY format")
+	if err != nil {
+		return nil, err
+	}
+
+	return &libraryStats, nil
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"errors"
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/restApiV1"
+	"io"
+	"path"
+	"time"
+)
+
+// Storage keys of the song files, mapped on the songs and trash subfolders of the local data folder
+const songsStorageDir = "songs"
+const trashStorageDir = "trash"
+
+// StorageDirs lists the key prefixes of the song files, in use or in the trash
+var StorageDirs = []string{songsStorageDir + "/", trashStorageDir + "/"}
+
+// Validity of the presigned urls sent to the clients streaming a song, long enough to seek into a paused song
+const songContentUrlExpiry = 6 * time.Hour
+
+// Storage keeps the song files, identified by slash separated keys like "songs/4Z/01FJ1Q3M2CX9T0SBC5K4Y0RT4Z.flac"
+type Storage interface {
+	// Name describes the storage for the logs
+	Name() string
+
+	// Open returns the content of a file, os.ErrNotExist if missing
+	Open(key string) (SongContent, error)
+
+	// Size returns the size of a file, os.ErrNotExist if missing
+	Size(key string) (int64, error)
+
+	// Stat returns the size and the last modification time of a file, os.ErrNotExist if missing
+	Stat(key string) (int64, time.Time, error)
+
+	// Write creates or replaces a file with the size bytes of content
+	Write(key string, content io.Reader, size int64) error
+
+	// Move renames a file
+	Move(srcKey string, destKey string) error
+
+	// Remove deletes a file
+	Remove(key string) error
+
+	// Walk calls fn with the key and the size of every file whose key starts with prefix
+	Walk(prefix string, fn func(key string, size int64) error) error
+
+	// View calls fn with the name of a local file holding the content of a file
+	View(key string, fn func(filename string) error) error
+
+	// Edit calls fn with the name of a local file holding the content of a file, saved back when fn succeeds
+	Edit(key string, fn func(filename string) error) error
+
+	// PresignedUrl returns an url reading a file without authentication until expiry, "" when unsupported
+	PresignedUrl(key string, expiry time.Duration, contentType string, contentDisposition string) (string, error)
+}
+
+// SongContent is the content of a song file, readable sequentially or at any position
+type SongContent interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+	Size() int64
+}
+
+// NewStorage opens the song files storage of the given type (local or s3)
+func NewStorage(serverConfig *config.ServerConfig, storageType string) (Storage, error) {
+	switch storageType {
+	case config.StorageLocal:
+		return newLocalStorage(serverConfig.GetCompleteConfigDataDirName()), nil
+	case config.StorageS3:
+		return newS3Storage(serverConfig)
+	}
+	return nil, errors.New("unknown storage " + storageType)
+}
+
+// Storage returns the storage of the song files
+func (s *Store) Storage() Storage {
+	return s.storage
+}
+
+func songStorageKey(songId restApiV1.SongId, songFormat restApiV1.SongFormat) string {
+	return path.Join(songsStorageDir, string(songId)[len(songId)-2:], string(songId)+songFormat.Extension())
+}
+
+func trashSongStorageKey(songId restApiV1.SongId, songFormat restApiV1.SongFormat) string {
+	return path.Join(trashStorageDir, string(songId)+songFormat.Extension())
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localStorage keeps the song files in the local data folder
+type localStorage struct {
+	rootDir string
+}
+
+type localSongContent struct {
+	*os.File
+	size int64
+}
+
+func (c *localSongContent) Size() int64 {
+	return c.size
+}
+
+func newLocalStorage(rootDir string) *localStorage {
+	return &localStorage{rootDir: rootDir}
+}
+
+func (l *localStorage) filename(key string) string {
+	return filepath.Join(l.rootDir, filepath.FromSlash(key))
+}
+
+func (l *localStorage) Name() string {
+	return "local folder " + l.rootDir
+}
+
+func (l *localStorage) Open(key string) (SongContent, error) {
+	file, err := os.Open(l.filename(key))
+	if err != nil {
+		return nil, err
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &localSongContent{File: file, size: fileInfo.Size()}, nil
+}
+
+func (l *localStorage) Size(key string) (int64, error) {
+	fileInfo, err := os.Stat(l.filename(key))
+	if err != nil {
+		return 0, err
+	}
+	return fileInfo.Size(), nil
+}
+
+func (l *localStorage) Stat(key string) (int64, time.Time, error) {
+	fileInfo, err := os.Stat(l.filename(key))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return fileInfo.Size(), fileInfo.ModTime(), nil
+}
+
+func (l *localStorage) Write(key string, content io.Reader, size int64) error {
+	filename := l.filename(key)
+	err := os.MkdirAll(filepath.Dir(filename), 0770)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(file, content, size)
+	if err != nil {
+		file.Close()
+		os.Remove(filename)
+		return err
+	}
+	return file.Close()
+}
+
+func (l *localStorage) Move(srcKey string, destKey string) error {
+	destFilename := l.filename(destKey)
+	err := os.MkdirAll(filepath.Dir(destFilename), 0770)
+	if err != nil {
+		return err
+	}
+	return os.Rename(l.filename(srcKey), destFilename)
+}
+
+func (l *localStorage) Remove(key string) error {
+	return os.Remove(l.filename(key))
+}
+
+func (l *localStorage) Walk(prefix string, fn func(key string, size int64) error) error {
+	// Walk the deepest folder containing the prefix
+	dir := prefix
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+	}
+
+	err := filepath.WalkDir(l.filename(dir), func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relativeFilename, err := filepath.Rel(l.rootDir, filename)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relativeFilename)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(key, info.Size())
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *localStorage) View(key string, fn func(filename string) error) error {
+	return fn(l.filename(key))
+}
+
+// Edit modifies the file in place
+func (l *localStorage) Edit(key string, fn func(filename string) error) error {
+	return fn(l.filename(key))
+}
+
+func (l *localStorage) PresignedUrl(key string, expiry time.Duration, contentType string, contentDisposition string) (string, error) {
+	return "", nil
+}
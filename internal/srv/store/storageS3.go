@@ -0,0 +1,547 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const s3Algorithm = "AWS4-HMAC-SHA256"
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+const s3TimeFormat = "20060102T150405Z"
+
+// Timeouts of the requests to the object storage. The whole request isn't limited, song contents being streamed
+// from the response body as long as they are played.
+const s3DialTimeout = 10 * time.Second
+const s3ResponseHeaderTimeout = 30 * time.Second
+const s3IdleConnTimeout = 90 * time.Second
+
+// s3Storage keeps the song files in a bucket of an S3 compatible object storage (AWS, MinIO, ...),
+// objects being addressed with path-style urls and requests signed with AWS signature version 4
+type s3Storage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func newS3Storage(serverConfig *config.ServerConfig) (*s3Storage, error) {
+	if serverConfig.S3Endpoint == "" || serverConfig.S3Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket should be configured")
+	}
+	endpoint, err := url.Parse(serverConfig.S3Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, errors.New("s3 endpoint should be an http or https url")
+	}
+
+	return &s3Storage{
+		endpoint:  endpoint,
+		region:    serverConfig.S3Region,
+		bucket:    serverConfig.S3Bucket,
+		prefix:    serverConfig.S3Prefix,
+		accessKey: serverConfig.S3AccessKey,
+		secretKey: serverConfig.S3SecretKey,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           (&net.Dialer{Timeout: s3DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+				TLSHandshakeTimeout:   s3DialTimeout,
+				ResponseHeaderTimeout: s3ResponseHeaderTimeout,
+				ExpectContinueTimeout: time.Second,
+				IdleConnTimeout:       s3IdleConnTimeout,
+				MaxIdleConnsPerHost:   8,
+			},
+		},
+	}, nil
+}
+
+func (s *s3Storage) Name() string {
+	return "s3 bucket " + s.endpoint.String() + "/" + path.Join(s.bucket, s.prefix)
+}
+
+// objectKey returns the object key of a storage key, inside the prefix
+func (s *s3Storage) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+// objectUrl returns the url of an object, or of the bucket for an empty object key
+func (s *s3Storage) objectUrl(objectKey string, query url.Values) *url.URL {
+	objectUrl := *s.endpoint
+	objectUrl.Path = strings.TrimSuffix(s.endpoint.Path, "/") + "/" + s.bucket + "/" + objectKey
+	objectUrl.RawPath = s3Escape(objectUrl.Path, false)
+	objectUrl.RawQuery = s3CanonicalQuery(query)
+	return &objectUrl
+}
+
+// do sends a signed request on an object, reporting unexpected status codes as errors
+func (s *s3Storage) do(method string, objectKey string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	requestUrl := s.objectUrl(objectKey, query)
+
+	request, err := http.NewRequest(method, requestUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	if body != nil {
+		request.ContentLength = size
+		if size == 0 {
+			request.Body = http.NoBody
+		}
+	}
+
+	now := time.Now().UTC()
+	request.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	request.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	// Sign host, range and x-amz-* headers
+	signedHeaders := map[string]string{"host": requestUrl.Host}
+	for name, values := range request.Header {
+		name = strings.ToLower(name)
+		if name == "range" || strings.HasPrefix(name, "x-amz-") {
+			signedHeaders[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	signature, signedHeaderNames, scope := s.sign(method, requestUrl.RawPath, requestUrl.RawQuery, signedHeaders, s3UnsignedPayload, now)
+	request.Header.Set("Authorization", s3Algorithm+" Credential="+s.accessKey+"/"+scope+", SignedHeaders="+signedHeaderNames+", Signature="+signature)
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		defer response.Body.Close()
+		return nil, s.responseError(method, objectKey, response)
+	}
+	return response, nil
+}
+
+// responseError converts an error response into os.ErrNotExist for a missing object, or into a descriptive error
+func (s *s3Storage) responseError(method string, objectKey string, response *http.Response) error {
+	var s3Err s3Error
+	rawBody, _ := ioutil.ReadAll(io.LimitReader(response.Body, 64*1024))
+	xml.Unmarshal(rawBody, &s3Err)
+
+	if response.StatusCode == http.StatusNotFound && s3Err.Code != "NoSuchBucket" {
+		return &fs.PathError{Op: strings.ToLower(method), Path: objectKey, Err: fs.ErrNotExist}
+	}
+	if s3Err.Code == "" {
+		s3Err.Code = response.Status
+	}
+	return fmt.Errorf("s3 %s %s: %s %s", method, objectKey, s3Err.Code, s3Err.Message)
+}
+
+// sign computes the AWS signature version 4 of a request
+func (s *s3Storage) sign(method string, canonicalUri string, canonicalQuery string, signedHeaders map[string]string, payloadHash string, now time.Time) (signature string, signedHeaderNames string, scope string) {
+	headerNames := make([]string, 0, len(signedHeaders))
+	for name := range signedHeaders {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + signedHeaders[name] + "\n")
+	}
+	signedHeaderNames = strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalUri,
+		canonicalQuery,
+		canonicalHeaders.String(),
+		signedHeaderNames,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	date := now.Format("20060102")
+	scope = date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := s3Algorithm + "\n" + now.Format(s3TimeFormat) + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	signingKey := s3Hmac([]byte("AWS4"+s.secretKey), date)
+	signingKey = s3Hmac(signingKey, s.region)
+	signingKey = s3Hmac(signingKey, "s3")
+	signingKey = s3Hmac(signingKey, "aws4_request")
+
+	return hex.EncodeToString(s3Hmac(signingKey, stringToSign)), signedHeaderNames, scope
+}
+
+func s3Hmac(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape encodes every byte except the unreserved characters, and the slashes of a path
+func s3Escape(value string, encodeSlash bool) string {
+	var escaped strings.Builder
+	for _, b := range []byte(value) {
+		if b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '-' || b == '.' || b == '_' || b == '~' || b == '/' && !encodeSlash {
+			escaped.WriteByte(b)
+		} else {
+			escaped.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return escaped.String()
+}
+
+// s3CanonicalQuery encodes the query parameters sorted by name
+func s3CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parameters []string
+	for _, name := range names {
+		values := append([]string{}, query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parameters = append(parameters, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parameters, "&")
+}
+
+func (s *s3Storage) Open(key string) (SongContent, error) {
+	size, err := s.Size(key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3SongContent{storage: s, objectKey: s.objectKey(key), size: size}, nil
+}
+
+func (s *s3Storage) Size(key string) (int64, error) {
+	response, err := s.do(http.MethodHead, s.objectKey(key), nil, nil, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	return response.ContentLength, nil
+}
+
+func (s *s3Storage) Stat(key string) (int64, time.Time, error) {
+	response, err := s.do(http.MethodHead, s.objectKey(key), nil, nil, nil, 0)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	response.Body.Close()
+	modTime, err := http.ParseTime(response.Header.Get("Last-Modified"))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return response.ContentLength, modTime, nil
+}
+
+func (s *s3Storage) Write(key string, content io.Reader, size int64) error {
+	response, err := s.do(http.MethodPut, s.objectKey(key), nil, nil, io.LimitReader(content, size), size)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// Move copies the object then deletes the source, S3 having no rename
+func (s *s3Storage) Move(srcKey string, destKey string) error {
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", s3Escape("/"+s.bucket+"/"+s.objectKey(srcKey), false))
+	response, err := s.do(http.MethodPut, s.objectKey(destKey), nil, header, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// A copy can fail after the 200 status code has been sent
+	rawBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	var s3Err s3Error
+	if xml.Unmarshal(rawBody, &s3Err) == nil && s3Err.Code != "" {
+		return fmt.Errorf("s3 copy %s: %s %s", srcKey, s3Err.Code, s3Err.Message)
+	}
+
+	return s.Remove(srcKey)
+}
+
+func (s *s3Storage) Remove(key string) error {
+	response, err := s.do(http.MethodDelete, s.objectKey(key), nil, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func (s *s3Storage) Walk(prefix string, fn func(key string, size int64) error) error {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", s.objectKey(prefix))
+
+	for {
+		response, err := s.do(http.MethodGet, "", query, nil, nil, 0)
+		if err != nil {
+			return err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, object := range result.Contents {
+			key := object.Key
+			if s.prefix != "" {
+				key = strings.TrimPrefix(key, s.prefix+"/")
+			}
+			err = fn(key, object.Size)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// download copies an object into a new local temporary file
+func (s *s3Storage) download(key string) (string, error) {
+	response, err := s.do(http.MethodGet, s.objectKey(key), nil, nil, nil, 0)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	file, err := ioutil.TempFile("", "mifasol-*"+path.Ext(key))
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, response.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+func (s *s3Storage) View(key string, fn func(filename string) error) error {
+	filename, err := s.download(key)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(filename)
+
+	return fn(filename)
+}
+
+// Edit modifies a downloaded copy of the object, uploaded afterwards
+func (s *s3Storage) Edit(key string, fn func(filename string) error) error {
+	filename, err := s.download(key)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(filename)
+
+	err = fn(filename)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return s.Write(key, file, fileInfo.Size())
+}
+
+func (s *s3Storage) PresignedUrl(key string, expiry time.Duration, contentType string, contentDisposition string) (string, error) {
+	now := time.Now().UTC()
+	objectKey := s.objectKey(key)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.accessKey+"/"+now.Format("20060102")+"/"+s.region+"/s3/aws4_request")
+	query.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expiry/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if contentType != "" {
+		query.Set("response-content-type", contentType)
+	}
+	if contentDisposition != "" {
+		query.Set("response-content-disposition", contentDisposition)
+	}
+
+	presignedUrl := s.objectUrl(objectKey, query)
+	signature, _, _ := s.sign(http.MethodGet, presignedUrl.RawPath, presignedUrl.RawQuery, map[string]string{"host": presignedUrl.Host}, s3UnsignedPayload, now)
+	presignedUrl.RawQuery += "&X-Amz-Signature=" + signature
+
+	return presignedUrl.String(), nil
+}
+
+// s3SongContent reads an object with range requests, keeping the response body open while reading sequentially
+type s3SongContent struct {
+	storage   *s3Storage
+	objectKey string
+	size      int64
+
+	mutex    sync.Mutex
+	position int64
+	body     io.ReadCloser
+	bodyPos  int64
+}
+
+func (c *s3SongContent) Size() int64 {
+	return c.size
+}
+
+// readAt fills p with the content found at offset, reopening the response body when not positioned at offset
+func (c *s3SongContent) readAt(p []byte, offset int64) (int, error) {
+	if offset >= c.size {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.size-offset {
+		p = p[:c.size-offset]
+	}
+
+	if c.body == nil || c.bodyPos != offset {
+		c.closeBody()
+		header := http.Header{}
+		header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		response, err := c.storage.do(http.MethodGet, c.objectKey, nil, header, nil, 0)
+		if err != nil {
+			return 0, err
+		}
+		if response.StatusCode != http.StatusPartialContent && offset > 0 {
+			response.Body.Close()
+			return 0, errors.New("s3 range request not supported")
+		}
+		c.body = response.Body
+		c.bodyPos = offset
+	}
+
+	n, err := io.ReadFull(c.body, p)
+	c.bodyPos += int64(n)
+	if err != nil {
+		c.closeBody()
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return n, err
+	}
+	if offset+int64(n) >= c.size {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (c *s3SongContent) closeBody() {
+	if c.body != nil {
+		c.body.Close()
+		c.body = nil
+	}
+}
+
+func (c *s3SongContent) Read(p []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	n, err := c.readAt(p, c.position)
+	c.position += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (c *s3SongContent) ReadAt(p []byte, offset int64) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	n, err := c.readAt(p, offset)
+	if n == len(p) && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (c *s3SongContent) Seek(offset int64, whence int) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.position
+	case io.SeekEnd:
+		offset += c.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	c.position = offset
+	return offset, nil
+}
+
+func (c *s3SongContent) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closeBody()
+	return nil
+}
@@ -3,37 +3,81 @@ package store
 import (
 	"github.com/jmoiron/sqlx"
 	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/srv/metrics"
 	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
 	"github.com/sirupsen/logrus"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
+// Number of read connections, used by the concurrent requests like the song streams or the sync reports
+const readConnectionCount = 8
+
 type Store struct {
 	db           *sqlx.DB
+	readDb       *sqlx.DB
 	serverConfig *config.ServerConfig
+	storage      Storage
+	eventBroker  eventBroker
+	uploadLocks  uploadLocks
+
+	transactionHooks transactionHooks
 }
 
 func NewStore(serverConfig *config.ServerConfig) *Store {
 
-	// Open database connection
-	db, err := sqlx.Open("sqlite", serverConfig.GetCompleteConfigDbFilename())
+	// Open the write connection: a single one, sqlite allowing only one writer at a time
+	db, err := sqlx.Open("sqlite", databaseDsn(serverConfig.GetCompleteConfigDbFilename(), "_pragma=journal_mode(wal)", "_pragma=busy_timeout(10000)"))
 	if err != nil {
 		logrus.Fatalf("Unable to connect to the database: %v", err)
 	}
-
-	// To avoid sqlite_busy errors
 	db.SetMaxOpenConns(1)
 
+	// Make sure the database is in WAL mode before opening the read connections
+	err = db.Ping()
+	if err != nil {
+		logrus.Fatalf("Unable to connect to the database: %v", err)
+	}
+
+	// Open the read connections: in WAL mode, readers don't block the writer nor each other
+	readDb, err := sqlx.Open("sqlite", databaseDsn(serverConfig.GetCompleteConfigDbFilename(), "mode=ro", "_pragma=busy_timeout(10000)"))
+	if err != nil {
+		logrus.Fatalf("Unable to connect to the database: %v", err)
+	}
+	readDb.SetMaxOpenConns(readConnectionCount)
+	readDb.SetMaxIdleConns(readConnectionCount)
+
+	// Open song files storage
+	storage, err := NewStorage(serverConfig, serverConfig.Storage)
+	if err != nil {
+		logrus.Fatalf("Unable to open the song files storage: %v", err)
+	}
+	logrus.Debugf("Song files stored in %s", storage.Name())
+
 	store := &Store{
 		db:           db,
+		readDb:       readDb,
 		serverConfig: serverConfig,
+		storage:      storage,
+		eventBroker:  eventBroker{subscriptions: make(map[*EventSubscription]struct{}), pendingEvents: make(map[*sqlx.Tx][]restApiV1.Event)},
+		uploadLocks:  uploadLocks{busy: make(map[restApiV1.UploadId]struct{})},
+		transactionHooks: transactionHooks{
+			onCommit:   make(map[*sqlx.Tx][]func()),
+			onRollback: make(map[*sqlx.Tx][]func()),
+		},
 	}
 
 	// Execute database migration scripts
 	if err := store.migrateDatabase(); err != nil {
 		logrus.Fatalf("Unable to migrate the database: %v", err)
 	}
+	if err := store.hashSharePasswords(); err != nil {
+		logrus.Fatalf("Unable to hash the share passwords: %v", err)
+	}
 
 	// Check old store
 	if _, err := os.Stat(serverConfig.GetCompleteConfigOldDbFilename()); err == nil {
@@ -85,5 +129,24 @@ func NewStore(serverConfig *config.ServerConfig) *Store {
 }
 
 func (s *Store) Close() error {
+	err := s.readDb.Close()
+	if err != nil {
+		return err
+	}
 	return s.db.Close()
 }
+
+// databaseDsn returns the uri of the sqlite database file with the given query parameters
+func databaseDsn(dbFilename string, params ...string) string {
+	// Escape the characters having a meaning in an uri
+	dbFilename = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(filepath.ToSlash(dbFilename))
+	return "file:" + dbFilename + "?" + strings.Join(params, "&")
+}
+
+// timeTrack records the duration of a store operation started at start, also logged in debug mode
+func (s *Store) timeTrack(start time.Time, name string) {
+	metrics.ObserveStoreOperation(start, name)
+	if s.serverConfig.DebugMode {
+		tool.TimeTrack(start, name)
+	}
+}
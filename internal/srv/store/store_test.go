@@ -0,0 +1,411 @@
+package store
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestStore opens a store with an empty library in a temporary config folder
+func newTestStore(tb testing.TB) *Store {
+	serverConfig := &config.ServerConfig{
+		ConfigDir:            tb.TempDir(),
+		ServerEditableConfig: config.NewServerEditableConfig(nil),
+	}
+	st := NewStore(serverConfig)
+	tb.Cleanup(func() { st.Close() })
+	return st
+}
+
+// receivedEvents returns the events already published to a subscription
+func receivedEvents(subscription *EventSubscription) []restApiV1.Event {
+	var events []restApiV1.Event
+	for {
+		select {
+		case event := <-subscription.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestEventsPublishedOnCommit(t *testing.T) {
+	st := newTestStore(t)
+	subscription := st.SubscribeEvents()
+	defer st.UnsubscribeEvents(subscription)
+
+	// Changes of a rolled back transaction
+	txn, err := st.db.Beginx()
+	if err != nil {
+		t.Fatalf("Unable to begin transaction: %v", err)
+	}
+	_, err = st.CreateArtist(txn, &restApiV1.ArtistMeta{Name: "Rolled back"})
+	if err != nil {
+		t.Fatalf("Unable to create artist: %v", err)
+	}
+	if events := receivedEvents(subscription); len(events) != 0 {
+		t.Fatalf("Events published before the end of the transaction: %v", events)
+	}
+	st.rollbackTransaction(txn)
+	if events := receivedEvents(subscription); len(events) != 0 {
+		t.Fatalf("Events published for a rolled back transaction: %v", events)
+	}
+
+	// Changes of a committed transaction
+	txn, err = st.db.Beginx()
+	if err != nil {
+		t.Fatalf("Unable to begin transaction: %v", err)
+	}
+	defer st.rollbackTransaction(txn)
+	artist, err := st.CreateArtist(txn, &restApiV1.ArtistMeta{Name: "Committed"})
+	if err != nil {
+		t.Fatalf("Unable to create artist: %v", err)
+	}
+	if events := receivedEvents(subscription); len(events) != 0 {
+		t.Fatalf("Events published before the end of the transaction: %v", events)
+	}
+	err = st.commitTransaction(txn)
+	if err != nil {
+		t.Fatalf("Unable to commit transaction: %v", err)
+	}
+	events := receivedEvents(subscription)
+	if len(events) != 1 || events[0].Type != restApiV1.ArtistCreatedEventType || events[0].Id != string(artist.Id) {
+		t.Fatalf("Events published for a committed transaction = %v, want the creation of artist %s", events, artist.Id)
+	}
+	if len(st.eventBroker.pendingEvents) != 0 {
+		t.Fatalf("Pending events left after the end of the transactions: %v", st.eventBroker.pendingEvents)
+	}
+}
+
+// checkSongContent fails unless the song content is only stored at the want key
+func checkSongContent(t *testing.T, st *Store, song *restApiV1.Song, want string) {
+	t.Helper()
+	for _, key := range []string{songStorageKey(song.Id, song.Format), trashSongStorageKey(song.Id, song.Format)} {
+		_, err := st.storage.Size(key)
+		if key == want && err != nil {
+			t.Fatalf("Song content missing at %s: %v", key, err)
+		}
+		if key != want && !os.IsNotExist(err) {
+			t.Fatalf("Song content left at %s", key)
+		}
+	}
+}
+
+func TestSongContentMovedOnCommit(t *testing.T) {
+	st := newTestStore(t)
+
+	song, err := st.CreateSong(nil, &restApiV1.SongNew{
+		SongMeta: restApiV1.SongMeta{Name: "Song", Format: restApiV1.SongFormatOgg, AlbumId: restApiV1.UnknownAlbumId},
+		Content:  []byte("content"),
+	}, false)
+	if err != nil {
+		t.Fatalf("Unable to create song: %v", err)
+	}
+	songKey := songStorageKey(song.Id, song.Format)
+	trashKey := trashSongStorageKey(song.Id, song.Format)
+
+	// Deletion rolled back
+	txn, err := st.db.Beginx()
+	if err != nil {
+		t.Fatalf("Unable to begin transaction: %v", err)
+	}
+	_, err = st.DeleteSong(txn, song.Id)
+	if err != nil {
+		t.Fatalf("Unable to delete song: %v", err)
+	}
+	checkSongContent(t, st, song, songKey)
+	st.rollbackTransaction(txn)
+	checkSongContent(t, st, song, songKey)
+
+	// Deletion committed
+	_, err = st.DeleteSong(nil, song.Id)
+	if err != nil {
+		t.Fatalf("Unable to delete song: %v", err)
+	}
+	checkSongContent(t, st, song, trashKey)
+
+	trashItems, err := st.ReadTrashItems(nil)
+	if err != nil || len(trashItems) != 1 {
+		t.Fatalf("Trash items = %v (%v), want the deleted song", trashItems, err)
+	}
+
+	// Restoration
+	_, err = st.RestoreTrashItem(nil, trashItems[0].Id)
+	if err != nil {
+		t.Fatalf("Unable to restore song: %v", err)
+	}
+	checkSongContent(t, st, song, songKey)
+
+	// Purge
+	_, err = st.DeleteSong(nil, song.Id)
+	if err != nil {
+		t.Fatalf("Unable to delete song: %v", err)
+	}
+	_, err = st.PurgeTrash(nil, time.Now().UnixNano()+1)
+	if err != nil {
+		t.Fatalf("Unable to purge trash: %v", err)
+	}
+	checkSongContent(t, st, song, "")
+
+	if len(st.transactionHooks.onCommit) != 0 || len(st.transactionHooks.onRollback) != 0 {
+		t.Fatalf("Transaction hooks left after the end of the transactions")
+	}
+}
+
+func TestUpdateSongsChecksSongsFirst(t *testing.T) {
+	st := newTestStore(t)
+
+	song, err := st.CreateSong(nil, &restApiV1.SongNew{
+		SongMeta: restApiV1.SongMeta{Name: "Song", Format: restApiV1.SongFormatOgg, AlbumId: restApiV1.UnknownAlbumId},
+		Content:  []byte("content"),
+	}, false)
+	if err != nil {
+		t.Fatalf("Unable to create song: %v", err)
+	}
+
+	subscription := st.SubscribeEvents()
+	defer st.UnsubscribeEvents(subscription)
+
+	publicationYear := int64(2000)
+	_, err = st.UpdateSongs(nil, &restApiV1.SongsPatch{
+		SongIds:       []restApiV1.SongId{song.Id, "missing"},
+		SongMetaPatch: restApiV1.SongMetaPatch{PublicationYear: &publicationYear},
+	})
+	if err != storeerror.ErrNotFound {
+		t.Fatalf("UpdateSongs with a missing song = %v, want %v", err, storeerror.ErrNotFound)
+	}
+
+	song, err = st.ReadSong(nil, song.Id)
+	if err != nil {
+		t.Fatalf("Unable to read song: %v", err)
+	}
+	if song.PublicationYear != nil {
+		t.Fatalf("Song updated by a failed UpdateSongs")
+	}
+	if events := receivedEvents(subscription); len(events) != 0 {
+		t.Fatalf("Events published by a failed UpdateSongs: %v", events)
+	}
+	if len(st.transactionHooks.onCommit) != 0 {
+		t.Fatalf("Transaction hooks left after a failed UpdateSongs")
+	}
+}
+
+func TestUserUploadsDeleted(t *testing.T) {
+	st := newTestStore(t)
+
+	user, err := st.CreateUser(nil, &restApiV1.UserMetaComplete{UserMeta: restApiV1.UserMeta{Name: "uploader"}, Password: "password"})
+	if err != nil {
+		t.Fatalf("Unable to create user: %v", err)
+	}
+	upload, err := st.CreateUpload(nil, user.Id, &restApiV1.UploadMeta{Size: 8, LastAlbumId: restApiV1.UnknownAlbumId})
+	if err != nil {
+		t.Fatalf("Unable to create upload: %v", err)
+	}
+	_, err = st.AppendUploadContent(upload.Id, 0, strings.NewReader("chunk"))
+	if err != nil {
+		t.Fatalf("Unable to append upload content: %v", err)
+	}
+
+	// A busy upload is deleted with its user, its file once the deletion is committed
+	if !st.lockUpload(upload.Id) {
+		t.Fatalf("Unable to lock upload")
+	}
+	txn, err := st.db.Beginx()
+	if err != nil {
+		t.Fatalf("Unable to begin transaction: %v", err)
+	}
+	defer st.rollbackTransaction(txn)
+	_, err = st.DeleteUser(txn, user.Id)
+	if err != nil {
+		t.Fatalf("Unable to delete user: %v", err)
+	}
+	_, err = os.Stat(st.getUploadFileName(upload.Id))
+	if err != nil {
+		t.Fatalf("Upload file removed before commit: %v", err)
+	}
+	err = st.commitTransaction(txn)
+	if err != nil {
+		t.Fatalf("Unable to commit transaction: %v", err)
+	}
+	_, err = os.Stat(st.getUploadFileName(upload.Id))
+	if !os.IsNotExist(err) {
+		t.Fatalf("Upload file left after commit")
+	}
+	st.unlockUpload(upload.Id)
+
+	// The next chunks are refused
+	_, err = st.AppendUploadContent(upload.Id, 5, strings.NewReader("end"))
+	if err != storeerror.ErrNotFound {
+		t.Fatalf("AppendUploadContent on a deleted upload = %v, want %v", err, storeerror.ErrNotFound)
+	}
+	_, err = os.Stat(st.getUploadFileName(upload.Id))
+	if !os.IsNotExist(err) {
+		t.Fatalf("Upload file created by a refused chunk")
+	}
+}
+
+// Number of songs of the benched library
+const benchSongCount = 200
+
+// Number of bytes read from a song file to start a stream
+const benchStreamStartSize = 64 * 1024
+
+// BenchmarkConcurrentReadWrite measures song stream starts while the library is changed and fully synced
+// by concurrent clients: in WAL mode, the reads shouldn't wait for the changes nor for each other
+func TestSharePasswordHashed(t *testing.T) {
+	st := newTestStore(t)
+
+	song, err := st.CreateSong(nil, &restApiV1.SongNew{
+		SongMeta: restApiV1.SongMeta{Name: "Song", Format: restApiV1.SongFormatOgg, AlbumId: restApiV1.UnknownAlbumId},
+		Content:  []byte("content"),
+	}, false)
+	if err != nil {
+		t.Fatalf("Unable to create song: %v", err)
+	}
+
+	share, err := st.CreateShare(nil, "user", &restApiV1.ShareNew{
+		ShareMeta: restApiV1.ShareMeta{ItemType: restApiV1.ShareItemTypeSong, ItemId: string(song.Id)},
+		Password:  "secret",
+	})
+	if err != nil {
+		t.Fatalf("Unable to create share: %v", err)
+	}
+
+	// Share created before the passwords were hashed
+	_, err = st.db.Exec("INSERT INTO share (share_id, creation_ts, owner_user_id, item_type, item_id, expiration_ts, password_hash, download_fg) VALUES ('old', 0, 'user', 'song', ?, NULL, 'oldsecret', 0)", song.Id)
+	if err != nil {
+		t.Fatalf("Unable to insert share: %v", err)
+	}
+	_, err = st.db.Exec("CREATE TABLE share_password_upgrade (share_id text not null primary key); INSERT INTO share_password_upgrade (share_id) VALUES ('old')")
+	if err != nil {
+		t.Fatalf("Unable to list share to upgrade: %v", err)
+	}
+	err = st.hashSharePasswords()
+	if err != nil {
+		t.Fatalf("Unable to hash share passwords: %v", err)
+	}
+
+	for shareId, password := range map[restApiV1.ShareId]string{share.Id: "secret", "old": "oldsecret"} {
+		var passwordHash string
+		err = st.db.Get(&passwordHash, "SELECT password_hash FROM share WHERE share_id = ?", shareId)
+		if err != nil {
+			t.Fatalf("Unable to read share: %v", err)
+		}
+		if strings.Contains(passwordHash, password) {
+			t.Errorf("Share %s password stored in plaintext", shareId)
+		}
+		if ok, err := st.CheckSharePassword(nil, shareId, password); err != nil || !ok {
+			t.Errorf("CheckSharePassword(%s, right password) = %v, %v", shareId, ok, err)
+		}
+		if ok, err := st.CheckSharePassword(nil, shareId, "wrong"); err != nil || ok {
+			t.Errorf("CheckSharePassword(%s, wrong password) = %v, %v", shareId, ok, err)
+		}
+	}
+}
+
+func BenchmarkConcurrentReadWrite(b *testing.B) {
+	st := newTestStore(b)
+
+	songIds := make([]restApiV1.SongId, benchSongCount)
+	for i := range songIds {
+		song, err := st.CreateSong(nil, &restApiV1.SongNew{
+			SongMeta: restApiV1.SongMeta{Name: "Song " + strconv.Itoa(i), Format: restApiV1.SongFormatOgg, AlbumId: restApiV1.UnknownAlbumId},
+			Content:  make([]byte, 2*benchStreamStartSize),
+		}, false)
+		if err != nil {
+			b.Fatalf("Unable to create song: %v", err)
+		}
+		songIds[i] = song.Id
+	}
+	playlist, err := st.CreatePlaylist(nil, &restApiV1.PlaylistMeta{Name: "Bench"}, false)
+	if err != nil {
+		b.Fatalf("Unable to create playlist: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	errCh := make(chan error, 2)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(2)
+
+	// Changes
+	go func() {
+		defer waitGroup.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+			_, err := st.UpdatePlaylist(nil, playlist.Id, &restApiV1.PlaylistMeta{Name: "Bench " + strconv.Itoa(i), SongIds: songIds[:i%benchSongCount]}, false)
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+
+	// Full syncs
+	go func() {
+		defer waitGroup.Done()
+		for {
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+			_, err := st.ReadSyncReport(0)
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			err := benchStreamStart(st, songIds[i%benchSongCount])
+			if err != nil {
+				b.Errorf("Unable to start stream: %v", err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+
+	close(stopCh)
+	waitGroup.Wait()
+	select {
+	case err := <-errCh:
+		b.Fatalf("Concurrent client failed: %v", err)
+	default:
+	}
+}
+
+// benchStreamStart reads the song and the beginning of its file, like a stream start
+func benchStreamStart(st *Store, songId restApiV1.SongId) error {
+	song, err := st.ReadSong(nil, songId)
+	if err != nil {
+		return err
+	}
+	content, err := st.ReadSongContent(song)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	_, err = io.CopyN(ioutil.Discard, content, benchStreamStartSize)
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
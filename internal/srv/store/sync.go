@@ -4,133 +4,280 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
 	"github.com/jypelle/mifasol/internal/tool"
 	"github.com/jypelle/mifasol/restApiV1"
 	"github.com/sirupsen/logrus"
-	"time"
+	"strconv"
+	"strings"
 )
 
-func (s *Store) ReadSyncReport(fromTs int64) (*restApiV1.SyncReport, error) {
+// ReadSyncReport returns the changes made after the from revision, or after the from timestamp for the clients
+// synchronized before the revisions
+func (s *Store) ReadSyncReport(from int64) (*restApiV1.SyncReport, error) {
 	var syncReport restApiV1.SyncReport
 
 	var err error
 	var txn *sqlx.Tx
-	// Force db write lock to avoid sync timestamp overlap
-	txn, err = s.db.Beginx()
+	txn, err = s.readDb.Beginx()
 	if err != nil {
 		return nil, err
 	}
 	defer txn.Rollback()
 
-	// Sync timestamp
-	syncReport.SyncTs = time.Now().UnixNano()
+	// Sync revision
+	syncReport.SyncRevision, err = s.currentRevision(txn)
+	if err != nil {
+		return nil, errors.New("Unable to read revision: " + err.Error())
+	}
+	syncReport.SyncTs = syncReport.SyncRevision
+	fromRevision, err := s.fromRevision(txn, from, syncReport.SyncRevision)
+	if err != nil {
+		return nil, errors.New("Unable to read revision: " + err.Error())
+	}
+
+	err = s.readSyncReportChanges(txn, &syncReport, fromRevision, syncReport.SyncRevision)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syncReport, nil
+}
+
+// ReadSyncReportPage returns, without continuation token, the first page of the changes made after the from revision,
+// or after the from timestamp for the clients synchronized before the revisions, otherwise the next page.
+// A page holds about size changes: the changes sharing the revision of its last change are all in the page.
+func (s *Store) ReadSyncReportPage(from int64, continuationToken string, size int64) (*restApiV1.SyncReportPage, error) {
+	var syncReportPage restApiV1.SyncReportPage
+
+	var err error
+	var txn *sqlx.Tx
+	txn, err = s.readDb.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+
+	var pageToken syncReportPageToken
+	if continuationToken == "" {
+		pageToken.toRevision, err = s.currentRevision(txn)
+		if err != nil {
+			return nil, errors.New("Unable to read revision: " + err.Error())
+		}
+		pageToken.fromRevision, err = s.fromRevision(txn, from, pageToken.toRevision)
+		if err != nil {
+			return nil, errors.New("Unable to read revision: " + err.Error())
+		}
+		pageToken.totalChangeCount, err = s.countChanges(txn, pageToken.fromRevision, pageToken.toRevision)
+		if err != nil {
+			return nil, errors.New("Unable to count changes: " + err.Error())
+		}
+	} else {
+		pageToken, err = parseSyncReportPageToken(continuationToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Revision of the last change of the page
+	pageRevision, err := s.limitRevision(txn, pageToken.fromRevision, pageToken.toRevision, size)
+	if err != nil {
+		return nil, errors.New("Unable to read revision: " + err.Error())
+	}
+
+	err = s.readSyncReportChanges(txn, &syncReportPage.SyncReport, pageToken.fromRevision, pageRevision)
+	if err != nil {
+		return nil, err
+	}
+	syncReportPage.SyncRevision = pageRevision
+	syncReportPage.SyncTs = pageRevision
+
+	// Progress, the changes made since the first page moving some rows out of the counted ones
+	pageToken.changeCount += syncReportChangeCount(&syncReportPage.SyncReport)
+	if pageToken.totalChangeCount < pageToken.changeCount || pageRevision == pageToken.toRevision {
+		pageToken.totalChangeCount = pageToken.changeCount
+	}
+	syncReportPage.ChangeCount = pageToken.changeCount
+	syncReportPage.TotalChangeCount = pageToken.totalChangeCount
+
+	if pageRevision < pageToken.toRevision {
+		pageToken.fromRevision = pageRevision
+		syncReportPage.ContinuationToken = pageToken.String()
+	}
+
+	return &syncReportPage, nil
+}
+
+// syncReportPageToken is the state of a paged synchronization, sent to the client as continuation token
+type syncReportPageToken struct {
+	fromRevision     int64
+	toRevision       int64
+	changeCount      int64
+	totalChangeCount int64
+}
+
+func (t syncReportPageToken) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", t.fromRevision, t.toRevision, t.changeCount, t.totalChangeCount)
+}
+
+func parseSyncReportPageToken(continuationToken string) (syncReportPageToken, error) {
+	var pageToken syncReportPageToken
+
+	parts := strings.Split(continuationToken, ".")
+	if len(parts) != 4 {
+		return pageToken, storeerror.ErrInvalidContinuationToken
+	}
+	values := make([]int64, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || value < 0 {
+			return pageToken, storeerror.ErrInvalidContinuationToken
+		}
+		values[i] = value
+	}
+	pageToken = syncReportPageToken{
+		fromRevision:     values[0],
+		toRevision:       values[1],
+		changeCount:      values[2],
+		totalChangeCount: values[3],
+	}
+	if pageToken.fromRevision >= pageToken.toRevision {
+		return pageToken, storeerror.ErrInvalidContinuationToken
+	}
+
+	return pageToken, nil
+}
+
+// syncReportChangeCount returns the number of changes of a sync report
+func syncReportChangeCount(syncReport *restApiV1.SyncReport) int64 {
+	return int64(len(syncReport.Songs) + len(syncReport.DeletedSongIds) +
+		len(syncReport.Albums) + len(syncReport.DeletedAlbumIds) +
+		len(syncReport.Artists) + len(syncReport.DeletedArtistIds) +
+		len(syncReport.Playlists) + len(syncReport.DeletedPlaylistIds) +
+		len(syncReport.Users) + len(syncReport.DeletedUserIds) +
+		len(syncReport.FavoritePlaylists) + len(syncReport.DeletedFavoritePlaylistIds) +
+		len(syncReport.FavoriteSongs) + len(syncReport.DeletedFavoriteSongIds))
+}
+
+// readSyncReportChanges fills the sync report with the changes made after the from revision, up to the to revision
+func (s *Store) readSyncReportChanges(txn *sqlx.Tx, syncReport *restApiV1.SyncReport, fromRevision int64, toRevision int64) error {
+	var err error
 
 	// Songs
-	syncReport.Songs, err = s.ReadSongs(txn, &restApiV1.SongFilter{FromTs: &fromTs})
+	syncReport.Songs, err = s.ReadSongs(txn, &restApiV1.SongFilter{FromRevision: &fromRevision, ToRevision: &toRevision})
 	if err != nil {
-		return nil, errors.New("Unable to read songs: " + err.Error())
+		return errors.New("Unable to read songs: " + err.Error())
 	}
-	syncReport.DeletedSongIds, err = s.GetDeletedSongIds(txn, fromTs)
+	syncReport.DeletedSongIds, err = s.GetDeletedSongIds(txn, fromRevision, toRevision)
 	if err != nil {
-		return nil, errors.New("Unable to read deleted song ids: " + err.Error())
+		return errors.New("Unable to read deleted song ids: " + err.Error())
 	}
 
 	// Albums
-	syncReport.Albums, err = s.ReadAlbums(txn, &restApiV1.AlbumFilter{FromTs: &fromTs})
+	syncReport.Albums, err = s.ReadAlbums(txn, &restApiV1.AlbumFilter{FromRevision: &fromRevision, ToRevision: &toRevision})
 	if err != nil {
-		return nil, errors.New("Unable to read albums: " + err.Error())
+		return errors.New("Unable to read albums: " + err.Error())
 	}
-	syncReport.DeletedAlbumIds, err = s.GetDeletedAlbumIds(txn, fromTs)
+	syncReport.DeletedAlbumIds, err = s.GetDeletedAlbumIds(txn, fromRevision, toRevision)
 	if err != nil {
-		return nil, errors.New("Unable to read deleted album ids: " + err.Error())
+		return errors.New("Unable to read deleted album ids: " + err.Error())
 	}
 
 	// Artists
-	syncReport.Artists, err = s.ReadArtists(txn, &restApiV1.ArtistFilter{FromTs: &fromTs})
+	syncReport.Artists, err = s.ReadArtists(txn, &restApiV1.ArtistFilter{FromRevision: &fromRevision, ToRevision: &toRevision})
 	if err != nil {
-		return nil, errors.New("Unable to read artists: " + err.Error())
+		return errors.New("Unable to read artists: " + err.Error())
 	}
-	syncReport.DeletedArtistIds, err = s.GetDeletedArtistIds(txn, fromTs)
+	syncReport.DeletedArtistIds, err = s.GetDeletedArtistIds(txn, fromRevision, toRevision)
 	if err != nil {
-		return nil, errors.New("Unable to read deleted artist ids: " + err.Error())
+		return errors.New("Unable to read deleted artist ids: " + err.Error())
 	}
 
 	// Playlists
-	syncReport.Playlists, err = s.ReadPlaylists(txn, &restApiV1.PlaylistFilter{FromTs: &fromTs})
+	syncReport.Playlists, err = s.ReadPlaylists(txn, &restApiV1.PlaylistFilter{FromRevision: &fromRevision, ToRevision: &toRevision})
 	if err != nil {
-		return nil, errors.New("Unable to read playlists: " + err.Error())
+		return errors.New("Unable to read playlists: " + err.Error())
 	}
-	syncReport.DeletedPlaylistIds, err = s.GetDeletedPlaylistIds(txn, fromTs)
+	syncReport.DeletedPlaylistIds, err = s.GetDeletedPlaylistIds(txn, fromRevision, toRevision)
 	if err != nil {
-		return nil, errors.New("Unable to read deleted playlist ids: " + err.Error())
+		return errors.New("Unable to read deleted playlist ids: " + err.Error())
 	}
 
 	// Users
-	syncReport.Users, err = s.ReadUsers(txn, &restApiV1.UserFilter{FromTs: &fromTs})
+	syncReport.Users, err = s.ReadUsers(txn, &restApiV1.UserFilter{FromRevision: &fromRevision, ToRevision: &toRevision})
 	if err != nil {
-		return nil, errors.New("Unable to read users: " + err.Error())
+		return errors.New("Unable to read users: " + err.Error())
 	}
-	syncReport.DeletedUserIds, err = s.GetDeletedUserIds(txn, fromTs)
+	syncReport.DeletedUserIds, err = s.GetDeletedUserIds(txn, fromRevision, toRevision)
 	if err != nil {
-		return nil, errors.New("Unable to read deleted user ids: " + err.Error())
+		return errors.New("Unable to read deleted user ids: " + err.Error())
 	}
 
 	// Favorite playlists
-	syncReport.FavoritePlaylists, err = s.ReadFavoritePlaylists(txn, &restApiV1.FavoritePlaylistFilter{FromTs: &fromTs})
+	syncReport.FavoritePlaylists, err = s.ReadFavoritePlaylists(txn, &restApiV1.FavoritePlaylistFilter{FromRevision: &fromRevision, ToRevision: &toRevision})
 	if err != nil {
-		return nil, errors.New("Unable to read favorite playlists: " + err.Error())
+		return errors.New("Unable to read favorite playlists: " + err.Error())
 	}
-	syncReport.DeletedFavoritePlaylistIds, err = s.GetDeletedFavoritePlaylistIds(txn, fromTs)
+	syncReport.DeletedFavoritePlaylistIds, err = s.GetDeletedFavoritePlaylistIds(txn, fromRevision, toRevision)
 	if err != nil {
-		return nil, errors.New("Unable to read deleted favorite playlist ids: " + err.Error())
+		return errors.New("Unable to read deleted favorite playlist ids: " + err.Error())
 	}
 
 	// Favorite songs
-	syncReport.FavoriteSongs, err = s.ReadFavoriteSongs(txn, &restApiV1.FavoriteSongFilter{FromTs: &fromTs})
+	syncReport.FavoriteSongs, err = s.ReadFavoriteSongs(txn, &restApiV1.FavoriteSongFilter{FromRevision: &fromRevision, ToRevision: &toRevision})
 	if err != nil {
-		return nil, errors.New("Unable to read favorite songs: " + err.Error())
+		return errors.New("Unable to read favorite songs: " + err.Error())
 	}
-	syncReport.DeletedFavoriteSongIds, err = s.GetDeletedFavoriteSongIds(txn, fromTs)
+	syncReport.DeletedFavoriteSongIds, err = s.GetDeletedFavoriteSongIds(txn, fromRevision, toRevision)
 	if err != nil {
-		return nil, errors.New("Unable to read deleted favorite song ids: " + err.Error())
+		return errors.New("Unable to read deleted favorite song ids: " + err.Error())
 	}
 
-	return &syncReport, nil
+	return nil
 }
 
-func (s *Store) ReadFileSyncReport(fromTs int64, userId restApiV1.UserId) (*restApiV1.FileSyncReport, error) {
+// ReadFileSyncReport returns the changes of the favorite songs and playlists of a user made after the from revision,
+// or after the from timestamp for the clients synchronized before the revisions
+func (s *Store) ReadFileSyncReport(from int64, userId restApiV1.UserId) (*restApiV1.FileSyncReport, error) {
 	var fileSyncReport restApiV1.FileSyncReport
 
 	var err error
 
 	var txn *sqlx.Tx
-	// Force db write lock to avoid sync timestamp overlap
-	txn, err = s.db.Beginx()
+	txn, err = s.readDb.Beginx()
 	if err != nil {
 		return nil, err
 	}
 	defer txn.Rollback()
 
-	// Sync timestamp
-	fileSyncReport.SyncTs = time.Now().UnixNano()
+	// Sync revision
+	fileSyncReport.SyncRevision, err = s.currentRevision(txn)
+	if err != nil {
+		return nil, errors.New("Unable to read revision: " + err.Error())
+	}
+	fileSyncReport.SyncTs = fileSyncReport.SyncRevision
+	fromRevision, err := s.fromRevision(txn, from, fileSyncReport.SyncRevision)
+	if err != nil {
+		return nil, errors.New("Unable to read revision: " + err.Error())
+	}
 
 	// Favorite Songs
-	fileSyncReport.FileSyncSongs, err = s.ReadFileSyncSongs(txn, fromTs, userId)
+	fileSyncReport.FileSyncSongs, err = s.ReadFileSyncSongs(txn, fromRevision, userId)
 
 	if err != nil {
 		logrus.Panicf("Unable to read songs: %v", err)
 	}
-	fileSyncReport.DeletedSongIds, err = s.GetDeletedUserFavoriteSongIds(txn, fromTs, userId)
+	fileSyncReport.DeletedSongIds, err = s.GetDeletedUserFavoriteSongIds(txn, fromRevision, fileSyncReport.SyncRevision, userId)
 	if err != nil {
 		logrus.Panicf("Unable to read deleted song ids: %v", err)
 	}
 
 	// Favorite Playlists
-	fileSyncReport.Playlists, err = s.ReadPlaylists(txn, &restApiV1.PlaylistFilter{FavoriteFromTs: &fromTs, FavoriteUserId: &userId})
+	fileSyncReport.Playlists, err = s.ReadPlaylists(txn, &restApiV1.PlaylistFilter{FavoriteFromRevision: &fromRevision, FavoriteUserId: &userId})
 	if err != nil {
 		logrus.Panicf("Unable to read playlists: %v", err)
 	}
-	fileSyncReport.DeletedPlaylistIds, err = s.GetDeletedUserFavoritePlaylistIds(txn, fromTs, userId)
+	fileSyncReport.DeletedPlaylistIds, err = s.GetDeletedUserFavoritePlaylistIds(txn, fromRevision, fileSyncReport.SyncRevision, userId)
 	if err != nil {
 		logrus.Panicf("Unable to read deleted playlist ids: %v", err)
 	}
@@ -138,20 +285,22 @@ func (s *Store) ReadFileSyncReport(fromTs int64, userId restApiV1.UserId) (*rest
 	return &fileSyncReport, nil
 }
 
-func (s *Store) ReadFileSyncSongs(externalTrn *sqlx.Tx, favoriteFromTs int64, favoriteUserId restApiV1.UserId) ([]restApiV1.FileSyncSong, error) {
+func (s *Store) ReadFileSyncSongs(externalTrn *sqlx.Tx, favoriteFromRevision int64, favoriteUserId restApiV1.UserId) ([]restApiV1.FileSyncSong, error) {
 	fileSyncSongs := []restApiV1.FileSyncSong{}
 
+	var err error
+
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, e := s.db.Beginx()
-		if e != nil {
-			return nil, e
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
 		}
 		defer txn.Rollback()
 	}
 
-	songs, err := s.ReadSongs(txn, &restApiV1.SongFilter{Favorite: &restApiV1.SongFilterFavorite{FromTs: favoriteFromTs, UserId: favoriteUserId}})
+	songs, err := s.ReadSongs(txn, &restApiV1.SongFilter{Favorite: &restApiV1.SongFilterFavorite{FromRevision: &favoriteFromRevision, UserId: favoriteUserId}})
 	if err != nil {
 		return nil, err
 	}
@@ -161,40 +310,72 @@ func (s *Store) ReadFileSyncSongs(externalTrn *sqlx.Tx, favoriteFromTs int64, fa
 
 		fileSyncSong.Id = song.Id
 		fileSyncSong.UpdateTs = song.UpdateTs
-		if song.AlbumId == restApiV1.UnknownAlbumId {
-			fileSyncSong.Filepath += tool.SanitizeFilename("(Unknown)") + "/"
-			for ind, artistId := range song.ArtistIds {
-				artist, _ := s.ReadArtist(txn, artistId)
-				if ind != 0 {
-					fileSyncSong.Filepath += ", "
-				}
-				fileSyncSong.Filepath += tool.SanitizeFilename(artist.Name)
+		fileSyncSong.Filepath, err = s.SongFilepath(txn, &song)
+		if err != nil {
+			return nil, err
+		}
+
+		fileSyncSongs = append(fileSyncSongs, fileSyncSong)
+
+	}
+
+	return fileSyncSongs, nil
+}
+
+// SongFilepath returns the relative path of a song file, named from its artists, album, track number and name
+func (s *Store) SongFilepath(externalTrn *sqlx.Tx, song *restApiV1.Song) (string, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return "", err
+		}
+		defer txn.Rollback()
+	}
+
+	var filepath string
+	if song.AlbumId == restApiV1.UnknownAlbumId {
+		filepath += tool.SanitizeFilename("(Unknown)") + "/"
+		for ind, artistId := range song.ArtistIds {
+			artist, err := s.ReadArtist(txn, artistId)
+			if err != nil {
+				return "", err
 			}
-			fileSyncSong.Filepath += " - "
-		} else {
-			album, _ := s.ReadAlbum(txn, song.AlbumId)
-			for ind, artistId := range album.ArtistIds {
-				artist, _ := s.ReadArtist(txn, artistId)
-				if ind != 0 {
-					fileSyncSong.Filepath += ", "
-				}
-				fileSyncSong.Filepath += tool.SanitizeFilename(artist.Name)
+			if ind != 0 {
+				filepath += ", "
 			}
-			if len(album.ArtistIds) > 0 {
-				fileSyncSong.Filepath += " - "
+			filepath += tool.SanitizeFilename(artist.Name)
+		}
+		filepath += " - "
+	} else {
+		album, err := s.ReadAlbum(txn, song.AlbumId)
+		if err != nil {
+			return "", err
+		}
+		for ind, artistId := range album.ArtistIds {
+			artist, err := s.ReadArtist(txn, artistId)
+			if err != nil {
+				return "", err
 			}
-
-			fileSyncSong.Filepath += tool.SanitizeFilename(album.Name) + "/"
-
-			if song.TrackNumber != nil {
-				fileSyncSong.Filepath += fmt.Sprintf("%02d - ", *song.TrackNumber)
+			if ind != 0 {
+				filepath += ", "
 			}
+			filepath += tool.SanitizeFilename(artist.Name)
+		}
+		if len(album.ArtistIds) > 0 {
+			filepath += " - "
 		}
-		fileSyncSong.Filepath += tool.SanitizeFilename(song.Name) + song.Format.Extension()
 
-		fileSyncSongs = append(fileSyncSongs, fileSyncSong)
+		filepath += tool.SanitizeFilename(album.Name) + "/"
 
+		if song.TrackNumber != nil {
+			filepath += fmt.Sprintf("%02d - ", *song.TrackNumber)
+		}
 	}
+	filepath += tool.SanitizeFilename(song.Name) + song.Format.Extension()
 
-	return fileSyncSongs, nil
+	return filepath, nil
 }
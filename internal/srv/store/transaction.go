@@ -0,0 +1,96 @@
+package store
+
+import (
+	"github.com/jmoiron/sqlx"
+	"sync"
+)
+
+// transactionHooks holds the song files changes of the transactions started by the store: the files are only moved
+// or removed once the database changes are committed, and the files written beforehand are removed on rollback
+type transactionHooks struct {
+	mutex      sync.Mutex
+	onCommit   map[*sqlx.Tx][]func()
+	onRollback map[*sqlx.Tx][]func()
+}
+
+// afterCommit registers an action to run once the transaction is committed, the write connection being released
+func (s *Store) afterCommit(txn *sqlx.Tx, action func()) {
+	s.transactionHooks.mutex.Lock()
+	defer s.transactionHooks.mutex.Unlock()
+
+	s.transactionHooks.onCommit[txn] = append(s.transactionHooks.onCommit[txn], action)
+}
+
+// afterRollback registers an action undoing a change made outside of the database, to run if the transaction is rolled back
+func (s *Store) afterRollback(txn *sqlx.Tx, action func()) {
+	s.transactionHooks.mutex.Lock()
+	defer s.transactionHooks.mutex.Unlock()
+
+	s.transactionHooks.onRollback[txn] = append(s.transactionHooks.onRollback[txn], action)
+}
+
+// takeTransactionHooks removes the actions registered for a transaction and returns them
+func (s *Store) takeTransactionHooks(txn *sqlx.Tx) (onCommit []func(), onRollback []func()) {
+	s.transactionHooks.mutex.Lock()
+	defer s.transactionHooks.mutex.Unlock()
+
+	onCommit = s.transactionHooks.onCommit[txn]
+	onRollback = s.transactionHooks.onRollback[txn]
+	delete(s.transactionHooks.onCommit, txn)
+	delete(s.transactionHooks.onRollback, txn)
+	return onCommit, onRollback
+}
+
+// Transaction runs fn in a write transaction, committed when fn succeeds and rolled back otherwise,
+// for the callers needing several store functions to see and change the same state
+func (s *Store) Transaction(fn func(txn *sqlx.Tx) error) error {
+	txn, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer s.rollbackTransaction(txn)
+
+	err = fn(txn)
+	if err != nil {
+		return err
+	}
+
+	return s.commitTransaction(txn)
+}
+
+// commitTransaction commits a transaction started by the store, then runs its after commit actions and publishes
+// the events of its changes. The rollback actions are run instead when the commit fails.
+func (s *Store) commitTransaction(txn *sqlx.Tx) error {
+	err := txn.Commit()
+
+	onCommit, onRollback := s.takeTransactionHooks(txn)
+	if err != nil {
+		runRollbackActions(onRollback)
+		s.endTransactionEvents(txn, false)
+		return err
+	}
+
+	for _, action := range onCommit {
+		action()
+	}
+	s.endTransactionEvents(txn, true)
+
+	return nil
+}
+
+// rollbackTransaction rolls back a transaction started by the store, if not committed, running its rollback actions
+// and dropping the events of its changes
+func (s *Store) rollbackTransaction(txn *sqlx.Tx) {
+	txn.Rollback()
+
+	_, onRollback := s.takeTransactionHooks(txn)
+	runRollbackActions(onRollback)
+	s.endTransactionEvents(txn, false)
+}
+
+// runRollbackActions undoes the changes in the reverse order
+func runRollbackActions(onRollback []func()) {
+	for i := len(onRollback) - 1; i >= 0; i-- {
+		onRollback[i]()
+	}
+}
@@ -0,0 +1,663 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sort"
+	"time"
+)
+
+// trashSnapshot keeps everything needed to restore a deleted item
+type trashSnapshot struct {
+	Song            *restApiV1.Song         `json:"song,omitempty"`
+	Album           *restApiV1.Album        `json:"album,omitempty"`
+	Artist          *restApiV1.Artist       `json:"artist,omitempty"`
+	Playlist        *restApiV1.Playlist     `json:"playlist,omitempty"`
+	PlaylistSongs   []trashPlaylistPosition `json:"playlistSongs,omitempty"`
+	FavoriteUserIds []restApiV1.UserId      `json:"favoriteUserIds,omitempty"`
+}
+
+type trashPlaylistPosition struct {
+	PlaylistId restApiV1.PlaylistId `json:"playlistId"`
+	Position   int64                `json:"position"`
+}
+
+func (s *Store) ReadTrashItems(externalTrn *sqlx.Tx) ([]restApiV1.TrashItem, error) {
+	defer s.timeTrack(time.Now(), "ReadTrashItems")
+
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	trashItemEntities := []entity.TrashItemEntity{}
+	err = txn.Select(&trashItemEntities, "SELECT * FROM trash_item ORDER BY delete_ts DESC")
+	if err != nil {
+		return nil, err
+	}
+
+	trashItems := []restApiV1.TrashItem{}
+	for _, trashItemEntity := range trashItemEntities {
+		var trashItem restApiV1.TrashItem
+		trashItemEntity.Fill(&trashItem)
+		trashItems = append(trashItems, trashItem)
+	}
+
+	return trashItems, nil
+}
+
+func (s *Store) ReadTrashItem(externalTrn *sqlx.Tx, trashItemId restApiV1.TrashItemId) (*restApiV1.TrashItem, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	trashItemEntity, err := s.readTrashItemEntity(txn, trashItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	var trashItem restApiV1.TrashItem
+	trashItemEntity.Fill(&trashItem)
+
+	return &trashItem, nil
+}
+
+func (s *Store) readTrashItemEntity(txn *sqlx.Tx, trashItemId restApiV1.TrashItemId) (*entity.TrashItemEntity, error) {
+	var trashItemEntity entity.TrashItemEntity
+
+	err := txn.Get(&trashItemEntity, "SELECT * FROM trash_item WHERE trash_item_id = ?", trashItemId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storeerror.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &trashItemEntity, nil
+}
+
+// createTrashItem store the snapshot of an item which is being deleted
+func (s *Store) createTrashItem(txn *sqlx.Tx, deleteTs int64, itemType restApiV1.TrashItemType, itemId string, name string, snapshot *trashSnapshot) error {
+	rawSnapshot, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	_, err = txn.NamedExec(`
+			INSERT INTO	trash_item (
+			    trash_item_id,
+				delete_ts,
+			    item_type,
+			    item_id,
+				name,
+				snapshot
+			)
+			VALUES (
+			    :trash_item_id,
+				:delete_ts,
+			    :item_type,
+			    :item_id,
+				:name,
+				:snapshot
+			)`,
+		&entity.TrashItemEntity{
+			TrashItemId: restApiV1.TrashItemId(tool.CreateUlid()),
+			DeleteTs:    deleteTs,
+			ItemType:    itemType,
+			ItemId:      itemId,
+			Name:        name,
+			Snapshot:    string(rawSnapshot),
+		})
+
+	return err
+}
+
+// RestoreTrashItem put back a deleted item with its links and remove it from the trash
+func (s *Store) RestoreTrashItem(externalTrn *sqlx.Tx, trashItemId restApiV1.TrashItemId) (*restApiV1.TrashItem, error) {
+	defer s.timeTrack(time.Now(), "RestoreTrashItem")
+
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	trashItemEntity, err := s.readTrashItemEntity(txn, trashItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot trashSnapshot
+	err = json.Unmarshal([]byte(trashItemEntity.Snapshot), &snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	restoreTs := time.Now().UnixNano()
+
+	switch trashItemEntity.ItemType {
+	case restApiV1.TrashItemTypeSong:
+		err = s.restoreTrashSong(txn, restoreTs, &snapshot)
+	case restApiV1.TrashItemTypeAlbum:
+		err = s.restoreTrashAlbum(txn, restoreTs, &snapshot)
+	case restApiV1.TrashItemTypeArtist:
+		err = s.restoreTrashArtist(txn, restoreTs, &snapshot)
+	case restApiV1.TrashItemTypePlaylist:
+		err = s.restoreTrashPlaylist(txn, restoreTs, &snapshot)
+	default:
+		err = errors.New("Unknown trash item type: " + string(trashItemEntity.ItemType))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove item from the trash
+	_, err = txn.Exec("DELETE FROM trash_item WHERE trash_item_id = ?", trashItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var trashItem restApiV1.TrashItem
+	trashItemEntity.Fill(&trashItem)
+
+	switch trashItemEntity.ItemType {
+	case restApiV1.TrashItemTypeSong:
+		s.publishEvent(externalTrn, restApiV1.SongCreatedEventType, trashItemEntity.ItemId, "", restoreTs, revision)
+	case restApiV1.TrashItemTypeAlbum:
+		s.publishEvent(externalTrn, restApiV1.AlbumCreatedEventType, trashItemEntity.ItemId, "", restoreTs, revision)
+	case restApiV1.TrashItemTypeArtist:
+		s.publishEvent(externalTrn, restApiV1.ArtistCreatedEventType, trashItemEntity.ItemId, "", restoreTs, revision)
+	case restApiV1.TrashItemTypePlaylist:
+		s.publishEvent(externalTrn, restApiV1.PlaylistCreatedEventType, trashItemEntity.ItemId, "", restoreTs, revision)
+	}
+
+	return &trashItem, nil
+}
+
+func (s *Store) restoreTrashSong(txn *sqlx.Tx, restoreTs int64, snapshot *trashSnapshot) error {
+	if snapshot.Song == nil {
+		return errors.New("Missing song in trash snapshot")
+	}
+
+	songEntity := entity.SongEntity{
+		SongId:     snapshot.Song.Id,
+		CreationTs: snapshot.Song.CreationTs,
+		UpdateTs:   restoreTs,
+	}
+	songEntity.LoadMeta(&snapshot.Song.SongMeta)
+
+	// Album and artists may have been deleted in the meantime
+	tagsChanged := false
+	if songEntity.AlbumId != restApiV1.UnknownAlbumId {
+		exists, err := isRowExists(txn, "SELECT count(*) FROM album WHERE album_id = ?", songEntity.AlbumId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			songEntity.AlbumId = restApiV1.UnknownAlbumId
+			tagsChanged = true
+		}
+	}
+
+	// Restore artists link
+	for _, artistId := range snapshot.Song.ArtistIds {
+		exists, err := isRowExists(txn, "SELECT count(*) FROM artist WHERE artist_id = ?", artistId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			tagsChanged = true
+			continue
+		}
+		_, err = txn.NamedExec(`
+			INSERT INTO	artist_song (
+			    artist_id,
+				song_id
+			)
+			VALUES (
+			    :artist_id,
+				:song_id
+			)
+		`, &entity.ArtistSongEntity{ArtistId: artistId, SongId: songEntity.SongId})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Restore song
+	_, err := txn.NamedExec(`
+			INSERT INTO	song (
+			    song_id,
+				creation_ts,
+			    update_ts,
+				name,
+				format,
+				size,
+				bit_depth,
+				publication_year,
+				album_id,
+				track_number,
+				explicit_fg
+			)
+			VALUES (
+			    :song_id,
+				:creation_ts,
+				:update_ts,
+				:name,
+				:format,
+				:size,
+				:bit_depth,
+				:publication_year,
+				:album_id,
+				:track_number,
+				:explicit_fg
+			)`,
+		&songEntity,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Remove song deletion archive
+	_, err = txn.Exec("DELETE FROM deleted_song WHERE song_id = ?", songEntity.SongId)
+	if err != nil {
+		return err
+	}
+
+	// Restore playlists link, shifting songs added since deletion
+	sort.Slice(snapshot.PlaylistSongs, func(i, j int) bool {
+		return snapshot.PlaylistSongs[i].Position < snapshot.PlaylistSongs[j].Position
+	})
+	for _, playlistSong := range snapshot.PlaylistSongs {
+		exists, err := isRowExists(txn, "SELECT count(*) FROM playlist WHERE playlist_id = ?", playlistSong.PlaylistId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		queryArgs := make(map[string]interface{})
+		queryArgs["playlist_id"] = playlistSong.PlaylistId
+		queryArgs["position"] = playlistSong.Position
+		queryArgs["song_id"] = songEntity.SongId
+		queryArgs["update_ts"] = restoreTs
+
+		// Two steps shift to avoid primary key collision
+		_, err = txn.NamedExec(`
+			UPDATE playlist_song
+			SET position = -position-1
+			WHERE playlist_id = :playlist_id AND position >= :position
+		`, queryArgs)
+		if err != nil {
+			return err
+		}
+		_, err = txn.NamedExec(`
+			UPDATE playlist_song
+			SET position = -position
+			WHERE playlist_id = :playlist_id AND position < 0
+		`, queryArgs)
+		if err != nil {
+			return err
+		}
+
+		_, err = txn.NamedExec(`
+			INSERT INTO	playlist_song (
+			    playlist_id,
+				position,
+			    song_id
+			)
+			VALUES (
+			    :playlist_id,
+				:position,
+				:song_id
+			)
+		`, queryArgs)
+		if err != nil {
+			return err
+		}
+
+		_, err = txn.NamedExec(`
+			UPDATE playlist
+			SET update_ts = :update_ts,
+				content_update_ts = :update_ts
+			WHERE playlist_id = :playlist_id
+		`, queryArgs)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Restore favorite song link
+	for _, userId := range snapshot.FavoriteUserIds {
+		exists, err := isRowExists(txn, "SELECT count(*) FROM user WHERE user_id = ?", userId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		_, err = s.CreateFavoriteSong(txn, &restApiV1.FavoriteSongMeta{Id: restApiV1.FavoriteSongId{UserId: userId, SongId: songEntity.SongId}}, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Refresh album artists
+	if songEntity.AlbumId != restApiV1.UnknownAlbumId {
+		_, err = txn.Exec(`UPDATE album SET update_ts = ? WHERE album_id = ?`, restoreTs, songEntity.AlbumId)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Restore song content and update its tags, once the song restoration is committed
+	s.afterCommit(txn, func() {
+		err := s.storage.Move(trashSongStorageKey(songEntity.SongId, songEntity.Format), songStorageKey(songEntity.SongId, songEntity.Format))
+		if err != nil {
+			logrus.Warnf("Unable to restore the content of the song %s from trash, orphan file left: %v", songEntity.SongId, err)
+			return
+		}
+		if tagsChanged {
+			err = s.UpdateSongContentTag(nil, &songEntity)
+			if err != nil {
+				logrus.Warnf("Unable to update the tags of the restored song %s: %v", songEntity.SongId, err)
+			}
+		}
+	})
+
+	return nil
+}
+
+func (s *Store) restoreTrashAlbum(txn *sqlx.Tx, restoreTs int64, snapshot *trashSnapshot) error {
+	if snapshot.Album == nil {
+		return errors.New("Missing album in trash snapshot")
+	}
+
+	albumEntity := entity.AlbumEntity{
+		AlbumId:    snapshot.Album.Id,
+		CreationTs: snapshot.Album.CreationTs,
+		UpdateTs:   restoreTs,
+	}
+	albumEntity.LoadMeta(&snapshot.Album.AlbumMeta)
+
+	_, err := txn.NamedExec(`
+			INSERT INTO	album (
+			    album_id,
+				creation_ts,
+			    update_ts,
+				name
+			)
+			VALUES (
+			    :album_id,
+				:creation_ts,
+				:update_ts,
+				:name
+			)
+	`, &albumEntity)
+	if err != nil {
+		return err
+	}
+
+	// Remove album deletion archive
+	_, err = txn.Exec("DELETE FROM deleted_album WHERE album_id = ?", albumEntity.AlbumId)
+
+	return err
+}
+
+func (s *Store) restoreTrashArtist(txn *sqlx.Tx, restoreTs int64, snapshot *trashSnapshot) error {
+	if snapshot.Artist == nil {
+		return errors.New("Missing artist in trash snapshot")
+	}
+
+	artistEntity := entity.ArtistEntity{
+		ArtistId:   snapshot.Artist.Id,
+		CreationTs: snapshot.Artist.CreationTs,
+		UpdateTs:   restoreTs,
+	}
+	artistEntity.LoadMeta(&snapshot.Artist.ArtistMeta)
+
+	_, err := txn.NamedExec(`
+			INSERT INTO	artist (
+			    artist_id,
+				creation_ts,
+			    update_ts,
+				name
+			)
+			VALUES (
+			    :artist_id,
+				:creation_ts,
+				:update_ts,
+				:name
+			)
+	`, &artistEntity)
+	if err != nil {
+		return err
+	}
+
+	// Remove artist deletion archive
+	_, err = txn.Exec("DELETE FROM deleted_artist WHERE artist_id = ?", artistEntity.ArtistId)
+
+	return err
+}
+
+func (s *Store) restoreTrashPlaylist(txn *sqlx.Tx, restoreTs int64, snapshot *trashSnapshot) error {
+	if snapshot.Playlist == nil {
+		return errors.New("Missing playlist in trash snapshot")
+	}
+
+	// Songs and owners may have been deleted in the meantime
+	playlistMeta := restApiV1.PlaylistMeta{Name: snapshot.Playlist.Name}
+	for _, songId := range snapshot.Playlist.SongIds {
+		exists, err := isRowExists(txn, "SELECT count(*) FROM song WHERE song_id = ?", songId)
+		if err != nil {
+			return err
+		}
+		if exists {
+			playlistMeta.SongIds = append(playlistMeta.SongIds, songId)
+		}
+	}
+	for _, userId := range snapshot.Playlist.OwnerUserIds {
+		exists, err := isRowExists(txn, "SELECT count(*) FROM user WHERE user_id = ?", userId)
+		if err != nil {
+			return err
+		}
+		if exists {
+			playlistMeta.OwnerUserIds = append(playlistMeta.OwnerUserIds, userId)
+		}
+	}
+
+	_, err := s.CreateInternalPlaylist(txn, snapshot.Playlist.Id, &playlistMeta, false)
+	if err != nil {
+		return err
+	}
+
+	// Keep original creation timestamp
+	_, err = txn.Exec("UPDATE playlist SET creation_ts = ? WHERE playlist_id = ?", snapshot.Playlist.CreationTs, snapshot.Playlist.Id)
+	if err != nil {
+		return err
+	}
+
+	// Restore favorite playlist link
+	for _, userId := range snapshot.FavoriteUserIds {
+		exists, err := isRowExists(txn, "SELECT count(*) FROM user WHERE user_id = ?", userId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		_, err = s.CreateFavoritePlaylist(txn, &restApiV1.FavoritePlaylistMeta{Id: restApiV1.FavoritePlaylistId{UserId: userId, PlaylistId: snapshot.Playlist.Id}}, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Remove playlist deletion archive
+	_, err = txn.Exec("DELETE FROM deleted_playlist WHERE playlist_id = ?", snapshot.Playlist.Id)
+
+	return err
+}
+
+// DeleteTrashItem definitively remove an item from the trash
+func (s *Store) DeleteTrashItem(externalTrn *sqlx.Tx, trashItemId restApiV1.TrashItemId) (*restApiV1.TrashItem, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	trashItemEntity, err := s.readTrashItemEntity(txn, trashItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.purgeTrashItemEntity(txn, trashItemEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var trashItem restApiV1.TrashItem
+	trashItemEntity.Fill(&trashItem)
+
+	return &trashItem, nil
+}
+
+// PurgeTrash definitively remove items deleted before beforeTs and return the number of purged items
+func (s *Store) PurgeTrash(externalTrn *sqlx.Tx, beforeTs int64) (int, error) {
+	defer s.timeTrack(time.Now(), "PurgeTrash")
+
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return 0, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	trashItemEntities := []entity.TrashItemEntity{}
+	err = txn.Select(&trashItemEntities, "SELECT * FROM trash_item WHERE delete_ts < ?", beforeTs)
+	if err != nil {
+		return 0, err
+	}
+
+	for idx := range trashItemEntities {
+		err = s.purgeTrashItemEntity(txn, &trashItemEntities[idx])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(trashItemEntities), nil
+}
+
+func (s *Store) purgeTrashItemEntity(txn *sqlx.Tx, trashItemEntity *entity.TrashItemEntity) error {
+	_, err := txn.Exec("DELETE FROM trash_item WHERE trash_item_id = ?", trashItemEntity.TrashItemId)
+	if err != nil {
+		return err
+	}
+
+	// The item can no longer be restored, its shares are useless
+	err = s.deleteItemShares(txn, restApiV1.ShareItemType(trashItemEntity.ItemType), trashItemEntity.ItemId)
+	if err != nil {
+		return err
+	}
+
+	// Delete song content
+	if trashItemEntity.ItemType == restApiV1.TrashItemTypeSong {
+		var snapshot trashSnapshot
+		err = json.Unmarshal([]byte(trashItemEntity.Snapshot), &snapshot)
+		if err != nil {
+			return err
+		}
+		if snapshot.Song != nil {
+			songId := snapshot.Song.Id
+			storageKey := trashSongStorageKey(songId, snapshot.Song.Format)
+			s.afterCommit(txn, func() {
+				err := s.storage.Remove(storageKey)
+				if err != nil && !os.IsNotExist(err) {
+					logrus.Warnf("Unable to remove the content of the purged song %s, orphan file left: %v", songId, err)
+				}
+			})
+		}
+	}
+
+	return nil
+}
+
+func isRowExists(txn *sqlx.Tx, query string, args ...interface{}) (bool, error) {
+	var count int64
+	err := txn.Get(&count, query, args...)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,381 @@
+package store
+
+import (
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/jypelle/mifasol/internal/srv/entity"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// uploadLocks prevents concurrent writes on the same upload, like a retried chunk still received from a lost connection
+type uploadLocks struct {
+	mutex sync.Mutex
+	busy  map[restApiV1.UploadId]struct{}
+}
+
+func (s *Store) lockUpload(uploadId restApiV1.UploadId) bool {
+	s.uploadLocks.mutex.Lock()
+	defer s.uploadLocks.mutex.Unlock()
+
+	if _, ok := s.uploadLocks.busy[uploadId]; ok {
+		return false
+	}
+	s.uploadLocks.busy[uploadId] = struct{}{}
+	return true
+}
+
+func (s *Store) unlockUpload(uploadId restApiV1.UploadId) {
+	s.uploadLocks.mutex.Lock()
+	defer s.uploadLocks.mutex.Unlock()
+
+	delete(s.uploadLocks.busy, uploadId)
+}
+
+func (s *Store) getUploadFileName(uploadId restApiV1.UploadId) string {
+	return filepath.Join(s.serverConfig.GetCompleteConfigUploadsDirName(), string(uploadId))
+}
+
+// removeUploadFileOnCommit removes the file of an upload once its deletion or its completion is committed
+func (s *Store) removeUploadFileOnCommit(txn *sqlx.Tx, uploadId restApiV1.UploadId) {
+	s.afterCommit(txn, func() {
+		err := os.Remove(s.getUploadFileName(uploadId))
+		if err != nil && !os.IsNotExist(err) {
+			logrus.Warnf("Unable to remove the file of the upload %s, orphan file left: %v", uploadId, err)
+		}
+	})
+}
+
+func (s *Store) ReadUpload(externalTrn *sqlx.Tx, uploadId restApiV1.UploadId) (*restApiV1.Upload, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.readDb.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback()
+	}
+
+	var uploadEntity entity.UploadEntity
+	err = txn.Get(&uploadEntity, "SELECT * FROM upload WHERE upload_id = ?", uploadId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storeerror.ErrNotFound
+		}
+		return nil, err
+	}
+
+	var upload restApiV1.Upload
+	uploadEntity.Fill(&upload)
+
+	// The received bytes are those of the upload file
+	if upload.SongId != nil {
+		upload.Offset = upload.Size
+	} else {
+		fileInfo, err := os.Stat(s.getUploadFileName(uploadId))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			upload.Offset = fileInfo.Size()
+		}
+	}
+
+	return &upload, nil
+}
+
+func (s *Store) CreateUpload(externalTrn *sqlx.Tx, userId restApiV1.UserId, uploadMeta *restApiV1.UploadMeta) (*restApiV1.Upload, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	now := time.Now().UnixNano()
+
+	uploadEntity := entity.UploadEntity{
+		UploadId:   restApiV1.UploadId(tool.CreateUlid()),
+		CreationTs: now,
+		UpdateTs:   now,
+		UserId:     userId,
+	}
+	uploadEntity.LoadMeta(uploadMeta)
+
+	_, err = txn.NamedExec(`
+			INSERT INTO	upload (
+				upload_id,
+				creation_ts,
+				update_ts,
+				user_id,
+				size,
+				last_album_id,
+				song_id
+			)
+			VALUES (
+				:upload_id,
+				:creation_ts,
+				:update_ts,
+				:user_id,
+				:size,
+				:last_album_id,
+				:song_id
+			)
+	`, &uploadEntity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the empty upload file
+	err = os.MkdirAll(s.serverConfig.GetCompleteConfigUploadsDirName(), 0770)
+	if err != nil {
+		return nil, err
+	}
+	err = os.WriteFile(s.getUploadFileName(uploadEntity.UploadId), nil, 0660)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var upload restApiV1.Upload
+	uploadEntity.Fill(&upload)
+
+	return &upload, nil
+}
+
+// AppendUploadContent writes a chunk received at offset at the end of the upload file.
+// No transaction is held while receiving the chunk, which may take a while.
+// The bytes received before an error are kept: the returned upload gives the offset to resume from.
+func (s *Store) AppendUploadContent(uploadId restApiV1.UploadId, offset int64, content io.Reader) (*restApiV1.Upload, error) {
+	if !s.lockUpload(uploadId) {
+		return nil, storeerror.ErrUploadBusy
+	}
+	defer s.unlockUpload(uploadId)
+
+	upload, err := s.ReadUpload(nil, uploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	if upload.SongId != nil || offset != upload.Offset {
+		return upload, storeerror.ErrUploadOffsetMismatch
+	}
+
+	uploadFile, err := os.OpenFile(s.getUploadFileName(uploadId), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0660)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bytes beyond the announced size are ignored
+	written, copyErr := io.Copy(uploadFile, io.LimitReader(content, upload.Size-upload.Offset))
+	err = uploadFile.Close()
+	if copyErr == nil {
+		copyErr = err
+	}
+	upload.Offset += written
+	upload.UpdateTs = time.Now().UnixNano()
+
+	result, err := s.db.Exec("UPDATE upload SET update_ts = ? WHERE upload_id = ?", upload.UpdateTs, uploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	// The upload has been deleted with its user while receiving the chunk
+	updatedCount, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updatedCount == 0 {
+		os.Remove(s.getUploadFileName(uploadId))
+		return nil, storeerror.ErrNotFound
+	}
+
+	return upload, copyErr
+}
+
+// CompleteUpload creates the song from the whole upload file.
+// The upload is kept with the song id, until purged, so a repeated call returns the same song.
+func (s *Store) CompleteUpload(externalTrn *sqlx.Tx, uploadId restApiV1.UploadId) (*restApiV1.Song, error) {
+	if !s.lockUpload(uploadId) {
+		return nil, storeerror.ErrUploadBusy
+	}
+	defer s.unlockUpload(uploadId)
+
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	upload, err := s.ReadUpload(txn, uploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	if upload.SongId != nil {
+		return s.ReadSong(txn, *upload.SongId)
+	}
+
+	if !upload.IsComplete() {
+		return nil, storeerror.ErrUploadIncomplete
+	}
+
+	uploadFile, err := os.Open(s.getUploadFileName(uploadId))
+	if err != nil {
+		return nil, err
+	}
+
+	song, err := s.CreateSongFromRawContent(txn, uploadFile, upload.LastAlbumId)
+	uploadFile.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = txn.Exec("UPDATE upload SET update_ts = ?, song_id = ? WHERE upload_id = ?", time.Now().UnixNano(), song.Id, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	s.removeUploadFileOnCommit(txn, uploadId)
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return song, nil
+}
+
+func (s *Store) DeleteUpload(externalTrn *sqlx.Tx, uploadId restApiV1.UploadId) (*restApiV1.Upload, error) {
+	if !s.lockUpload(uploadId) {
+		return nil, storeerror.ErrUploadBusy
+	}
+	defer s.unlockUpload(uploadId)
+
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return nil, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	upload, err := s.ReadUpload(txn, uploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = txn.Exec("DELETE FROM upload WHERE upload_id = ?", uploadId)
+	if err != nil {
+		return nil, err
+	}
+	s.removeUploadFileOnCommit(txn, uploadId)
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return upload, nil
+}
+
+// PurgeUploads deletes the uploads left idle since beforeTs
+func (s *Store) PurgeUploads(externalTrn *sqlx.Tx, beforeTs int64) (int, error) {
+	var err error
+
+	// Check available transaction
+	txn := externalTrn
+	if txn == nil {
+		txn, err = s.db.Beginx()
+		if err != nil {
+			return 0, err
+		}
+		defer s.rollbackTransaction(txn)
+	}
+
+	var uploadIds []restApiV1.UploadId
+	err = txn.Select(&uploadIds, "SELECT upload_id FROM upload WHERE update_ts < ?", beforeTs)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, uploadId := range uploadIds {
+		_, err = s.DeleteUpload(txn, uploadId)
+		if err != nil {
+			// Skip the uploads receiving a chunk
+			if err == storeerror.ErrUploadBusy {
+				continue
+			}
+			return 0, err
+		}
+		count++
+	}
+
+	// Commit transaction
+	if externalTrn == nil {
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+// deleteUserUploads deletes the uploads of a user, even those receiving a chunk: AppendUploadContent then drops
+// the chunk, the upload being gone
+func (s *Store) deleteUserUploads(txn *sqlx.Tx, userId restApiV1.UserId) error {
+	var uploadIds []restApiV1.UploadId
+	err := txn.Select(&uploadIds, "SELECT upload_id FROM upload WHERE user_id = ?", userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = txn.Exec("DELETE FROM upload WHERE user_id = ?", userId)
+	if err != nil {
+		return err
+	}
+	for _, uploadId := range uploadIds {
+		s.removeUploadFileOnCommit(txn, uploadId)
+	}
+
+	return nil
+}
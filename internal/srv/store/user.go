@@ -14,15 +14,13 @@ const DefaultUserName = "mifasol"
 const DefaultUserPassword = "mifasol"
 
 func (s *Store) ReadUsers(externalTrn *sqlx.Tx, filter *restApiV1.UserFilter) ([]restApiV1.User, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "ReadUsers")
-	}
+	defer s.timeTrack(time.Now(), "ReadUsers")
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -33,6 +31,12 @@ func (s *Store) ReadUsers(externalTrn *sqlx.Tx, filter *restApiV1.UserFilter) ([
 	if filter.FromTs != nil {
 		queryArgs["from_ts"] = *filter.FromTs
 	}
+	if filter.FromRevision != nil {
+		queryArgs["from_revision"] = *filter.FromRevision
+	}
+	if filter.ToRevision != nil {
+		queryArgs["to_revision"] = *filter.ToRevision
+	}
 	if filter.AdminFg != nil {
 		queryArgs["admin_fg"] = *filter.AdminFg
 	}
@@ -43,6 +47,8 @@ func (s *Store) ReadUsers(externalTrn *sqlx.Tx, filter *restApiV1.UserFilter) ([
 			FROM user u
 			WHERE 1>0
 			`+tool.TernStr(filter.FromTs != nil, "AND u.update_ts >= :from_ts ", "")+`
+			`+tool.TernStr(filter.FromRevision != nil, "AND u.revision > :from_revision ", "")+`
+			`+tool.TernStr(filter.ToRevision != nil, "AND u.revision <= :to_revision ", "")+`
 			`+tool.TernStr(filter.AdminFg != nil, "AND u.admin_fg = :admin_fg ", "")+`
 			ORDER BY u.name ASC
 		`,
@@ -77,7 +83,7 @@ func (s *Store) ReadUser(externalTrn *sqlx.Tx, userId restApiV1.UserId) (*restAp
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -107,7 +113,7 @@ func (s *Store) ReadUserByUserName(externalTrn *sqlx.Tx, userName string) (*rest
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -140,7 +146,7 @@ func (s *Store) CreateUser(externalTrn *sqlx.Tx, userMetaComplete *restApiV1.Use
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	// Store user
@@ -182,14 +188,24 @@ func (s *Store) CreateUser(externalTrn *sqlx.Tx, userMetaComplete *restApiV1.Use
 		return nil, err
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var user restApiV1.User
 	userEntity.Fill(&user)
 
+	s.publishEvent(externalTrn, restApiV1.UserCreatedEventType, string(user.Id), "", user.UpdateTs, revision)
+
 	return &user, nil
 }
 
@@ -203,7 +219,7 @@ func (s *Store) UpdateUser(externalTrn *sqlx.Tx, userId restApiV1.UserId, userMe
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	var userEntity entity.UserEntity
@@ -232,14 +248,24 @@ func (s *Store) UpdateUser(externalTrn *sqlx.Tx, userId restApiV1.UserId, userMe
 		WHERE user_id = :user_id
 	`, &userEntity)
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var user restApiV1.User
 	userEntity.Fill(&user)
 
+	s.publishEvent(externalTrn, restApiV1.UserUpdatedEventType, string(user.Id), "", user.UpdateTs, revision)
+
 	return &user, nil
 }
 
@@ -253,7 +279,7 @@ func (s *Store) DeleteUser(externalTrn *sqlx.Tx, userId restApiV1.UserId) (*rest
 		if err != nil {
 			return nil, err
 		}
-		defer txn.Rollback()
+		defer s.rollbackTransaction(txn)
 	}
 
 	deleteTs := time.Now().UnixNano()
@@ -325,6 +351,24 @@ func (s *Store) DeleteUser(externalTrn *sqlx.Tx, userId restApiV1.UserId) (*rest
 		return nil, err
 	}
 
+	// Delete user's play queue
+	err = s.deletePlayQueue(txn, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete user's shares
+	err = s.deleteUserShares(txn, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete user's uploads
+	err = s.deleteUserUploads(txn, userId)
+	if err != nil {
+		return nil, err
+	}
+
 	// Delete user
 	_, err = txn.Exec(`DELETE FROM user WHERE user_id = ?`, userId)
 	if err != nil {
@@ -346,27 +390,35 @@ func (s *Store) DeleteUser(externalTrn *sqlx.Tx, userId restApiV1.UserId) (*rest
 		return nil, err
 	}
 
+	revision, err := s.currentRevision(txn)
+	if err != nil {
+		return nil, err
+	}
+
 	// Commit transaction
 	if externalTrn == nil {
-		txn.Commit()
+		err = s.commitTransaction(txn)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var user restApiV1.User
 	userEntity.Fill(&user)
 
+	s.publishEvent(externalTrn, restApiV1.UserDeletedEventType, string(userId), "", deleteTs, revision)
+
 	return &user, nil
 }
 
-func (s *Store) GetDeletedUserIds(externalTrn *sqlx.Tx, fromTs int64) ([]restApiV1.UserId, error) {
-	if s.serverConfig.DebugMode {
-		defer tool.TimeTrack(time.Now(), "GetDeletedUserIds")
-	}
+func (s *Store) GetDeletedUserIds(externalTrn *sqlx.Tx, fromRevision int64, toRevision int64) ([]restApiV1.UserId, error) {
+	defer s.timeTrack(time.Now(), "GetDeletedUserIds")
 	var err error
 
 	// Check available transaction
 	txn := externalTrn
 	if txn == nil {
-		txn, err = s.db.Beginx()
+		txn, err = s.readDb.Beginx()
 		if err != nil {
 			return nil, err
 		}
@@ -374,13 +426,14 @@ func (s *Store) GetDeletedUserIds(externalTrn *sqlx.Tx, fromTs int64) ([]restApi
 	}
 
 	queryArgs := make(map[string]interface{})
-	queryArgs["from_ts"] = fromTs
+	queryArgs["from_revision"] = fromRevision
+	queryArgs["to_revision"] = toRevision
 	rows, err := txn.NamedQuery(
 		`SELECT
 				u.*
 			FROM deleted_user u
-			WHERE u.delete_ts >= :from_ts
-			ORDER BY u.delete_ts ASC
+			WHERE u.revision > :from_revision AND u.revision <= :to_revision
+			ORDER BY u.revision ASC
 		`,
 		queryArgs,
 	)
@@ -3,7 +3,12 @@ package storeerror
 import "errors"
 
 var (
-	ErrDeleteArtistWithSongs = errors.New("Unable to delete an artist linked to songs")
-	ErrDeleteAlbumWithSongs  = errors.New("Unable to delete an album linked to songs")
-	ErrNotFound              = errors.New("Unable to find the item")
+	ErrDeleteArtistWithSongs    = errors.New("Unable to delete an artist linked to songs")
+	ErrDeleteAlbumWithSongs     = errors.New("Unable to delete an album linked to songs")
+	ErrNotFound                 = errors.New("Unable to find the item")
+	ErrUploadOffsetMismatch     = errors.New("Upload offset mismatch")
+	ErrUploadBusy               = errors.New("Upload already in progress")
+	ErrUploadIncomplete         = errors.New("Upload is incomplete")
+	ErrInvalidContinuationToken = errors.New("Invalid continuation token")
+	ErrDatabaseInUse            = errors.New("Database in use by a running server")
 )
@@ -0,0 +1,208 @@
+package subsonicSrv
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"sort"
+)
+
+// Stars are mapped on favorites: starring an album or an artist stars all its songs,
+// and an album or an artist is reported as starred when all its songs are.
+
+func (s *SubsonicServer) star(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Star: %v %v %v", r.Form["id"], r.Form["albumId"], r.Form["artistId"])
+
+	user := s.connectedUser(r)
+
+	songIds, playlistIds, ok := s.readStarIds(w, r)
+	if !ok {
+		return
+	}
+
+	for _, songId := range songIds {
+		_, err := s.store.CreateFavoriteSong(nil, &restApiV1.FavoriteSongMeta{Id: restApiV1.FavoriteSongId{UserId: user.Id, SongId: songId}}, true)
+		if err != nil {
+			s.log.Panicf("Unable to create the favorite song: %v", err)
+		}
+	}
+	for _, playlistId := range playlistIds {
+		_, err := s.store.CreateFavoritePlaylist(nil, &restApiV1.FavoritePlaylistMeta{Id: restApiV1.FavoritePlaylistId{UserId: user.Id, PlaylistId: playlistId}}, true)
+		if err != nil {
+			s.log.Panicf("Unable to create the favorite playlist: %v", err)
+		}
+	}
+
+	s.okResponse(w, r, newSubsonicResponse())
+}
+
+func (s *SubsonicServer) unstar(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Unstar: %v %v %v", r.Form["id"], r.Form["albumId"], r.Form["artistId"])
+
+	user := s.connectedUser(r)
+
+	songIds, playlistIds, ok := s.readStarIds(w, r)
+	if !ok {
+		return
+	}
+
+	for _, songId := range songIds {
+		_, err := s.store.DeleteFavoriteSong(nil, restApiV1.FavoriteSongId{UserId: user.Id, SongId: songId})
+		if err != nil && err != storeerror.ErrNotFound {
+			s.log.Panicf("Unable to delete favorite song: %v", err)
+		}
+	}
+	for _, playlistId := range playlistIds {
+		_, err := s.store.DeleteFavoritePlaylist(nil, restApiV1.FavoritePlaylistId{UserId: user.Id, PlaylistId: playlistId})
+		if err != nil && err != storeerror.ErrNotFound {
+			s.log.Panicf("Unable to delete favorite playlist: %v", err)
+		}
+	}
+
+	s.okResponse(w, r, newSubsonicResponse())
+}
+
+// readStarIds resolves the id, albumId and artistId parameters into song and playlist ids, or writes an error response
+func (s *SubsonicServer) readStarIds(w http.ResponseWriter, r *http.Request) ([]restApiV1.SongId, []restApiV1.PlaylistId, bool) {
+	var songIds []restApiV1.SongId
+	var playlistIds []restApiV1.PlaylistId
+
+	// Songs or playlists
+	for _, id := range r.Form["id"] {
+		_, err := s.store.ReadSong(nil, restApiV1.SongId(id))
+		if err == nil {
+			songIds = append(songIds, restApiV1.SongId(id))
+			continue
+		}
+		if err != storeerror.ErrNotFound {
+			s.log.Panicf("Unable to read song: %v", err)
+		}
+
+		_, err = s.store.ReadPlaylist(nil, restApiV1.PlaylistId(id))
+		if err != nil {
+			if err == storeerror.ErrNotFound {
+				s.notFoundResponse(w, r, "Item not found: "+id)
+				return nil, nil, false
+			}
+			s.log.Panicf("Unable to read playlist: %v", err)
+		}
+		playlistIds = append(playlistIds, restApiV1.PlaylistId(id))
+	}
+
+	// Album songs
+	for _, id := range r.Form["albumId"] {
+		albumId := restApiV1.AlbumId(id)
+		_, err := s.store.ReadAlbum(nil, albumId)
+		if err != nil {
+			if err == storeerror.ErrNotFound {
+				s.notFoundResponse(w, r, "Album not found: "+id)
+				return nil, nil, false
+			}
+			s.log.Panicf("Unable to read album: %v", err)
+		}
+
+		songs, err := s.store.ReadSongs(nil, &restApiV1.SongFilter{AlbumId: &albumId})
+		if err != nil {
+			s.log.Panicf("Unable to read songs: %v", err)
+		}
+		for _, song := range songs {
+			songIds = append(songIds, song.Id)
+		}
+	}
+
+	// Artist songs
+	for _, id := range r.Form["artistId"] {
+		artistId := restApiV1.ArtistId(id)
+		_, err := s.store.ReadArtist(nil, artistId)
+		if err != nil {
+			if err == storeerror.ErrNotFound {
+				s.notFoundResponse(w, r, "Artist not found: "+id)
+				return nil, nil, false
+			}
+			s.log.Panicf("Unable to read artist: %v", err)
+		}
+
+		songs, err := s.store.ReadSongs(nil, &restApiV1.SongFilter{ArtistId: &artistId})
+		if err != nil {
+			s.log.Panicf("Unable to read songs: %v", err)
+		}
+		for _, song := range songs {
+			songIds = append(songIds, song.Id)
+		}
+	}
+
+	return songIds, playlistIds, true
+}
+
+func (s *SubsonicServer) getStarred2(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Get starred")
+
+	user := s.connectedUser(r)
+	lib := s.readLibrary(user)
+
+	songs, err := s.store.ReadSongs(nil, &restApiV1.SongFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read songs: %v", err)
+	}
+
+	result := &starred2{}
+
+	// Albums and artists are starred when all their songs are
+	albumStarredTs := make(map[restApiV1.AlbumId]int64)
+	artistStarredTs := make(map[restApiV1.ArtistId]int64)
+	unstarredAlbumIds := make(map[restApiV1.AlbumId]bool)
+	unstarredArtistIds := make(map[restApiV1.ArtistId]bool)
+
+	for ind := range songs {
+		song := &songs[ind]
+		if !isVisible(user, song) {
+			continue
+		}
+
+		starredTs, starred := lib.starredSongs[song.Id]
+		if starred {
+			result.Song = append(result.Song, lib.newChild(song))
+		}
+
+		if song.AlbumId != restApiV1.UnknownAlbumId {
+			if !starred {
+				unstarredAlbumIds[song.AlbumId] = true
+			} else if starredTs > albumStarredTs[song.AlbumId] {
+				albumStarredTs[song.AlbumId] = starredTs
+			}
+		}
+		for _, artistId := range song.ArtistIds {
+			if !starred {
+				unstarredArtistIds[artistId] = true
+			} else if starredTs > artistStarredTs[artistId] {
+				artistStarredTs[artistId] = starredTs
+			}
+		}
+	}
+
+	for albumId, starredTs := range albumStarredTs {
+		if album, ok := lib.albums[albumId]; ok && !unstarredAlbumIds[albumId] {
+			starredAlbum := lib.newAlbumID3(album)
+			starredAlbum.Starred = formatTs(starredTs)
+			result.Album = append(result.Album, starredAlbum)
+		}
+	}
+	sort.Slice(result.Album, func(i, j int) bool {
+		return result.Album[i].Name < result.Album[j].Name
+	})
+
+	for artistId, starredTs := range artistStarredTs {
+		if artist, ok := lib.artists[artistId]; ok && !unstarredArtistIds[artistId] {
+			starredArtist := lib.newArtistID3(artist)
+			starredArtist.Starred = formatTs(starredTs)
+			result.Artist = append(result.Artist, starredArtist)
+		}
+	}
+	sort.Slice(result.Artist, func(i, j int) bool {
+		return result.Artist[i].Name < result.Artist[j].Name
+	})
+
+	response := newSubsonicResponse()
+	response.Starred2 = result
+	s.okResponse(w, r, response)
+}
@@ -0,0 +1,157 @@
+package subsonicSrv
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+func (s *SubsonicServer) getArtists(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Get artists")
+
+	lib := s.readLibrary(s.connectedUser(r))
+
+	artists, err := s.store.ReadArtists(nil, &restApiV1.ArtistFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read artists: %v", err)
+	}
+
+	// Group artists by first letter
+	var indexes []indexID3
+	indexPositions := make(map[string]int)
+	for ind := range artists {
+		indexName := "#"
+		searchName := []rune(strings.ToUpper(tool.SearchLib(artists[ind].Name)))
+		if len(searchName) > 0 && unicode.IsLetter(searchName[0]) {
+			indexName = string(searchName[0])
+		}
+
+		position, ok := indexPositions[indexName]
+		if !ok {
+			position = len(indexes)
+			indexPositions[indexName] = position
+			indexes = append(indexes, indexID3{Name: indexName})
+		}
+		indexes[position].Artist = append(indexes[position].Artist, lib.newArtistID3(&artists[ind]))
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].Name < indexes[j].Name
+	})
+
+	response := newSubsonicResponse()
+	response.Artists = &artistsID3{Index: indexes}
+	s.okResponse(w, r, response)
+}
+
+func (s *SubsonicServer) getArtist(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.requiredParam(w, r, "id")
+	if !ok {
+		return
+	}
+	artistId := restApiV1.ArtistId(id)
+
+	s.log.Debugf("Get artist: %s", artistId)
+
+	artist, err := s.store.ReadArtist(nil, artistId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.notFoundResponse(w, r, "Artist not found")
+			return
+		}
+		s.log.Panicf("Unable to read artist: %v", err)
+	}
+
+	lib := s.readLibrary(s.connectedUser(r))
+
+	artistWithAlbums := &artistWithAlbumsID3{artistID3: lib.newArtistID3(artist)}
+	for _, album := range lib.albums {
+		for _, albumArtistId := range album.ArtistIds {
+			if albumArtistId == artistId {
+				artistWithAlbums.Album = append(artistWithAlbums.Album, lib.newAlbumID3(album))
+				break
+			}
+		}
+	}
+	sort.Slice(artistWithAlbums.Album, func(i, j int) bool {
+		return artistWithAlbums.Album[i].Name < artistWithAlbums.Album[j].Name
+	})
+
+	response := newSubsonicResponse()
+	response.Artist = artistWithAlbums
+	s.okResponse(w, r, response)
+}
+
+func (s *SubsonicServer) getAlbum(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.requiredParam(w, r, "id")
+	if !ok {
+		return
+	}
+	albumId := restApiV1.AlbumId(id)
+
+	s.log.Debugf("Get album: %s", albumId)
+
+	album, err := s.store.ReadAlbum(nil, albumId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.notFoundResponse(w, r, "Album not found")
+			return
+		}
+		s.log.Panicf("Unable to read album: %v", err)
+	}
+
+	user := s.connectedUser(r)
+	lib := s.readLibrary(user)
+
+	songs, err := s.store.ReadSongs(nil, &restApiV1.SongFilter{AlbumId: &albumId})
+	if err != nil {
+		s.log.Panicf("Unable to read songs: %v", err)
+	}
+
+	albumWithSongs := &albumWithSongsID3{albumID3: lib.newAlbumID3(album)}
+	for ind := range songs {
+		if isVisible(user, &songs[ind]) {
+			albumWithSongs.Song = append(albumWithSongs.Song, lib.newChild(&songs[ind]))
+		}
+	}
+	albumWithSongs.SongCount = len(albumWithSongs.Song)
+
+	response := newSubsonicResponse()
+	response.Album = albumWithSongs
+	s.okResponse(w, r, response)
+}
+
+func (s *SubsonicServer) getSong(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.requiredParam(w, r, "id")
+	if !ok {
+		return
+	}
+	songId := restApiV1.SongId(id)
+
+	s.log.Debugf("Get song: %s", songId)
+
+	user := s.connectedUser(r)
+
+	song, err := s.store.ReadSong(nil, songId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.notFoundResponse(w, r, "Song not found")
+			return
+		}
+		s.log.Panicf("Unable to read song: %v", err)
+	}
+	if !isVisible(user, song) {
+		s.notFoundResponse(w, r, "Song not found")
+		return
+	}
+
+	songChild := s.readLibrary(user).newChild(song)
+
+	response := newSubsonicResponse()
+	response.Song = &songChild
+	s.okResponse(w, r, response)
+}
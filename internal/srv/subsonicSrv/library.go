@@ -0,0 +1,172 @@
+package subsonicSrv
+
+import (
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// library is a snapshot of the albums and artists used to enrich songs
+type library struct {
+	albums          map[restApiV1.AlbumId]*restApiV1.Album
+	artists         map[restApiV1.ArtistId]*restApiV1.Artist
+	albumSongCounts map[restApiV1.AlbumId]int
+	starredSongs    map[restApiV1.SongId]int64
+}
+
+func (s *SubsonicServer) readLibrary(user *restApiV1.User) *library {
+	lib := &library{
+		albums:          make(map[restApiV1.AlbumId]*restApiV1.Album),
+		artists:         make(map[restApiV1.ArtistId]*restApiV1.Artist),
+		albumSongCounts: make(map[restApiV1.AlbumId]int),
+		starredSongs:    s.readStarredSongs(user),
+	}
+
+	albums, err := s.store.ReadAlbums(nil, &restApiV1.AlbumFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read albums: %v", err)
+	}
+	for ind := range albums {
+		lib.albums[albums[ind].Id] = &albums[ind]
+	}
+
+	artists, err := s.store.ReadArtists(nil, &restApiV1.ArtistFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read artists: %v", err)
+	}
+	for ind := range artists {
+		lib.artists[artists[ind].Id] = &artists[ind]
+	}
+
+	songs, err := s.store.ReadSongs(nil, &restApiV1.SongFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read songs: %v", err)
+	}
+	for _, song := range songs {
+		lib.albumSongCounts[song.AlbumId]++
+	}
+
+	return lib
+}
+
+// readStarredSongs returns the favorite songs of the user with their starring timestamp
+func (s *SubsonicServer) readStarredSongs(user *restApiV1.User) map[restApiV1.SongId]int64 {
+	favoriteSongs, err := s.store.ReadFavoriteSongs(nil, &restApiV1.FavoriteSongFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read favorite songs: %v", err)
+	}
+
+	starredSongs := make(map[restApiV1.SongId]int64)
+	for _, favoriteSong := range favoriteSongs {
+		if favoriteSong.Id.UserId == user.Id {
+			starredSongs[favoriteSong.Id.SongId] = favoriteSong.UpdateTs
+		}
+	}
+
+	return starredSongs
+}
+
+// isVisible hides explicit songs to users who asked for it
+func isVisible(user *restApiV1.User, song *restApiV1.Song) bool {
+	return !(user.HideExplicitFg && song.ExplicitFg)
+}
+
+func (l *library) newChild(song *restApiV1.Song) child {
+	c := child{
+		Id:          string(song.Id),
+		Title:       song.Name,
+		Size:        song.Size,
+		ContentType: song.Format.MimeType(),
+		Suffix:      song.Format.String(),
+		Type:        "music",
+		Created:     formatTs(song.CreationTs),
+	}
+
+	if song.AlbumId != restApiV1.UnknownAlbumId {
+		if album, ok := l.albums[song.AlbumId]; ok {
+			c.Parent = string(album.Id)
+			c.AlbumId = string(album.Id)
+			c.Album = album.Name
+		}
+	}
+
+	if len(song.ArtistIds) > 0 {
+		c.ArtistId = string(song.ArtistIds[0])
+		c.Artist = l.artistNames(song.ArtistIds)
+	}
+
+	if song.TrackNumber != nil {
+		c.Track = *song.TrackNumber
+	}
+	if song.PublicationYear != nil {
+		c.Year = *song.PublicationYear
+	}
+	if starredTs, ok := l.starredSongs[song.Id]; ok {
+		c.Starred = formatTs(starredTs)
+	}
+
+	return c
+}
+
+func (l *library) newAlbumID3(album *restApiV1.Album) albumID3 {
+	a := albumID3{
+		Id:        string(album.Id),
+		Name:      album.Name,
+		SongCount: l.albumSongCounts[album.Id],
+		Created:   formatTs(album.CreationTs),
+	}
+
+	if len(album.ArtistIds) > 0 {
+		a.ArtistId = string(album.ArtistIds[0])
+		a.Artist = l.artistNames(album.ArtistIds)
+	}
+
+	return a
+}
+
+func (l *library) newArtistID3(artist *restApiV1.Artist) artistID3 {
+	a := artistID3{
+		Id:   string(artist.Id),
+		Name: artist.Name,
+	}
+
+	for _, album := range l.albums {
+		for _, artistId := range album.ArtistIds {
+			if artistId == artist.Id {
+				a.AlbumCount++
+				break
+			}
+		}
+	}
+
+	return a
+}
+
+func (l *library) artistNames(artistIds []restApiV1.ArtistId) string {
+	var names []string
+	for _, artistId := range artistIds {
+		if artist, ok := l.artists[artistId]; ok {
+			names = append(names, artist.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func formatTs(ts int64) string {
+	return time.Unix(0, ts).UTC().Format(time.RFC3339)
+}
+
+// requiredParam returns the value of a mandatory parameter, or writes an error response when it is missing
+func (s *SubsonicServer) requiredParam(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	value := r.Form.Get(name)
+	if value == "" {
+		s.errorResponse(w, r, http.StatusOK, errorCodeMissingParameter, "Required parameter is missing: "+name)
+		return "", false
+	}
+	return value, true
+}
+
+func (s *SubsonicServer) notFoundResponse(w http.ResponseWriter, r *http.Request, message string) {
+	s.errorResponse(w, r, http.StatusOK, errorCodeNotFound, message)
+}
@@ -0,0 +1,78 @@
+package subsonicSrv
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/metrics"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// stream sends the original song file: mifasol doesn't transcode, so maxBitRate and format parameters are ignored
+func (s *SubsonicServer) stream(w http.ResponseWriter, r *http.Request) {
+	s.serveSongContent(w, r, false)
+}
+
+func (s *SubsonicServer) download(w http.ResponseWriter, r *http.Request) {
+	s.serveSongContent(w, r, true)
+}
+
+func (s *SubsonicServer) serveSongContent(w http.ResponseWriter, r *http.Request, attachment bool) {
+	id, ok := s.requiredParam(w, r, "id")
+	if !ok {
+		return
+	}
+	songId := restApiV1.SongId(id)
+
+	s.log.Debugf("Read song content: %s", songId)
+
+	song, err := s.store.ReadSong(nil, songId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.notFoundResponse(w, r, "Song not found")
+			return
+		}
+		s.log.Panicf("Unable to read song: %v", err)
+	}
+	if !isVisible(s.connectedUser(r), song) {
+		s.notFoundResponse(w, r, "Song not found")
+		return
+	}
+
+	contentDisposition := ""
+	if attachment {
+		contentDisposition = mime.FormatMediaType("attachment", map[string]string{"filename": song.Name + song.Format.Extension()})
+	}
+
+	// Let the client read the song content directly from the storage
+	songContentUrl, err := s.store.ReadSongContentUrl(song, contentDisposition)
+	if err != nil {
+		s.log.Panicf("Unable to presign song content url: %v", err)
+	}
+	if songContentUrl != "" {
+		http.Redirect(w, r, songContentUrl, http.StatusTemporaryRedirect)
+		return
+	}
+
+	release, retryAfter, ok := s.limiter.AcquireStream("subsonic", "user:"+string(s.connectedUser(r).Id))
+	if !ok {
+		s.tooManyRequestsResponse(w, r, retryAfter, "Too many concurrent streams")
+		return
+	}
+	defer release()
+
+	songContent, err := s.store.ReadSongContent(song)
+	if err != nil {
+		s.log.Panicf("Unable to read song content: %v", err)
+	}
+	defer songContent.Close()
+
+	w.Header().Set("Content-Type", song.Format.MimeType())
+	if contentDisposition != "" {
+		w.Header().Set("Content-Disposition", contentDisposition)
+	}
+	streamWriter, streamDone := metrics.TrackStream(w, "subsonic")
+	defer streamDone()
+	http.ServeContent(streamWriter, r, "", time.Unix(0, song.UpdateTs), songContent)
+}
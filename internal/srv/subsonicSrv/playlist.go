@@ -0,0 +1,255 @@
+package subsonicSrv
+
+import (
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+func (s *SubsonicServer) getPlaylists(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Get playlists")
+
+	playlistList, err := s.store.ReadPlaylists(nil, &restApiV1.PlaylistFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read playlists: %v", err)
+	}
+
+	userNames := s.readUserNames()
+
+	result := &playlists{}
+	for ind := range playlistList {
+		result.Playlist = append(result.Playlist, newPlaylist(&playlistList[ind], userNames))
+	}
+	sort.Slice(result.Playlist, func(i, j int) bool {
+		return result.Playlist[i].Name < result.Playlist[j].Name
+	})
+
+	response := newSubsonicResponse()
+	response.Playlists = result
+	s.okResponse(w, r, response)
+}
+
+func (s *SubsonicServer) getPlaylist(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.requiredParam(w, r, "id")
+	if !ok {
+		return
+	}
+	playlistId := restApiV1.PlaylistId(id)
+
+	s.log.Debugf("Get playlist: %s", playlistId)
+
+	s.playlistIdResponse(w, r, playlistId)
+}
+
+// createPlaylist creates a playlist owned by the connected user, or replaces the songs of an existing one when playlistId is provided
+func (s *SubsonicServer) createPlaylist(w http.ResponseWriter, r *http.Request) {
+	user := s.connectedUser(r)
+
+	songIds, ok := s.readSongIds(w, r, "songId")
+	if !ok {
+		return
+	}
+
+	if r.Form.Get("playlistId") != "" {
+		playlistId := restApiV1.PlaylistId(r.Form.Get("playlistId"))
+
+		s.log.Debugf("Replace playlist songs: %s", playlistId)
+
+		playlist, ok := s.readEditablePlaylist(w, r, playlistId)
+		if !ok {
+			return
+		}
+
+		playlistMeta := playlist.PlaylistMeta.Copy()
+		playlistMeta.SongIds = songIds
+		if r.Form.Get("name") != "" {
+			playlistMeta.Name = r.Form.Get("name")
+		}
+
+		_, err := s.store.UpdatePlaylist(nil, playlistId, playlistMeta, true)
+		if err != nil {
+			s.log.Panicf("Unable to update the playlist: %v", err)
+		}
+
+		s.playlistIdResponse(w, r, playlistId)
+		return
+	}
+
+	name, ok := s.requiredParam(w, r, "name")
+	if !ok {
+		return
+	}
+
+	s.log.Debugf("Create playlist: %s", name)
+
+	playlist, err := s.store.CreatePlaylist(nil, &restApiV1.PlaylistMeta{
+		Name:         name,
+		SongIds:      songIds,
+		OwnerUserIds: []restApiV1.UserId{user.Id},
+	}, true)
+	if err != nil {
+		s.log.Panicf("Unable to create the playlist: %v", err)
+	}
+
+	s.playlistIdResponse(w, r, playlist.Id)
+}
+
+func (s *SubsonicServer) updatePlaylist(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.requiredParam(w, r, "playlistId")
+	if !ok {
+		return
+	}
+	playlistId := restApiV1.PlaylistId(id)
+
+	s.log.Debugf("Update playlist: %s", playlistId)
+
+	playlist, ok := s.readEditablePlaylist(w, r, playlistId)
+	if !ok {
+		return
+	}
+
+	songIdsToAdd, ok := s.readSongIds(w, r, "songIdToAdd")
+	if !ok {
+		return
+	}
+
+	playlistMeta := playlist.PlaylistMeta.Copy()
+	if r.Form.Get("name") != "" {
+		playlistMeta.Name = r.Form.Get("name")
+	}
+
+	// Remove songs
+	removedPositions := make(map[int]bool)
+	for _, songIndex := range r.Form["songIndexToRemove"] {
+		position, err := strconv.Atoi(songIndex)
+		if err != nil || position < 0 || position >= len(playlistMeta.SongIds) {
+			s.notFoundResponse(w, r, "Song index not found: "+songIndex)
+			return
+		}
+		removedPositions[position] = true
+	}
+	songIds := []restApiV1.SongId{}
+	for position, songId := range playlistMeta.SongIds {
+		if !removedPositions[position] {
+			songIds = append(songIds, songId)
+		}
+	}
+
+	// Add songs
+	playlistMeta.SongIds = append(songIds, songIdsToAdd...)
+
+	_, err := s.store.UpdatePlaylist(nil, playlistId, playlistMeta, true)
+	if err != nil {
+		s.log.Panicf("Unable to update the playlist: %v", err)
+	}
+
+	s.okResponse(w, r, newSubsonicResponse())
+}
+
+// readEditablePlaylist returns the playlist if the connected user owns it or is administrator, or writes an error response
+func (s *SubsonicServer) readEditablePlaylist(w http.ResponseWriter, r *http.Request, playlistId restApiV1.PlaylistId) (*restApiV1.Playlist, bool) {
+	user := s.connectedUser(r)
+
+	playlist, err := s.store.ReadPlaylist(nil, playlistId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.notFoundResponse(w, r, "Playlist not found")
+			return nil, false
+		}
+		s.log.Panicf("Unable to read playlist: %v", err)
+	}
+
+	if !user.AdminFg {
+		owner := false
+		for _, ownerUserId := range playlist.OwnerUserIds {
+			if ownerUserId == user.Id {
+				owner = true
+				break
+			}
+		}
+		if !owner {
+			s.errorResponse(w, r, http.StatusOK, errorCodeNotAuthorized, "Only playlist owners can update the playlist")
+			return nil, false
+		}
+	}
+
+	return playlist, true
+}
+
+// readSongIds returns the existing song ids of the multi-valued parameter name, or writes an error response
+func (s *SubsonicServer) readSongIds(w http.ResponseWriter, r *http.Request, name string) ([]restApiV1.SongId, bool) {
+	songIds := []restApiV1.SongId{}
+	for _, id := range r.Form[name] {
+		songId := restApiV1.SongId(id)
+		_, err := s.store.ReadSong(nil, songId)
+		if err != nil {
+			if err == storeerror.ErrNotFound {
+				s.notFoundResponse(w, r, "Song not found: "+id)
+				return nil, false
+			}
+			s.log.Panicf("Unable to read song: %v", err)
+		}
+		songIds = append(songIds, songId)
+	}
+	return songIds, true
+}
+
+func (s *SubsonicServer) playlistIdResponse(w http.ResponseWriter, r *http.Request, playlistId restApiV1.PlaylistId) {
+	playlist, err := s.store.ReadPlaylist(nil, playlistId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			s.notFoundResponse(w, r, "Playlist not found")
+			return
+		}
+		s.log.Panicf("Unable to read playlist: %v", err)
+	}
+
+	user := s.connectedUser(r)
+	lib := s.readLibrary(user)
+
+	playlistWithEntries := &playlistWithSongs{playlist: newPlaylist(playlist, s.readUserNames())}
+	for _, songId := range playlist.SongIds {
+		song, err := s.store.ReadSong(nil, songId)
+		if err != nil {
+			s.log.Panicf("Unable to read song: %v", err)
+		}
+		if isVisible(user, song) {
+			playlistWithEntries.Entry = append(playlistWithEntries.Entry, lib.newChild(song))
+		}
+	}
+	playlistWithEntries.SongCount = len(playlistWithEntries.Entry)
+
+	response := newSubsonicResponse()
+	response.Playlist = playlistWithEntries
+	s.okResponse(w, r, response)
+}
+
+func (s *SubsonicServer) readUserNames() map[restApiV1.UserId]string {
+	users, err := s.store.ReadUsers(nil, &restApiV1.UserFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read users: %v", err)
+	}
+
+	userNames := make(map[restApiV1.UserId]string)
+	for _, user := range users {
+		userNames[user.Id] = user.Name
+	}
+	return userNames
+}
+
+func newPlaylist(p *restApiV1.Playlist, userNames map[restApiV1.UserId]string) playlist {
+	pl := playlist{
+		Id:        string(p.Id),
+		Name:      p.Name,
+		Public:    true,
+		SongCount: len(p.SongIds),
+		Created:   formatTs(p.CreationTs),
+		Changed:   formatTs(p.ContentUpdateTs),
+	}
+	if len(p.OwnerUserIds) > 0 {
+		pl.Owner = userNames[p.OwnerUserIds[0]]
+	}
+	return pl
+}
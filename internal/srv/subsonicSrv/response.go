@@ -0,0 +1,185 @@
+package subsonicSrv
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/version"
+	"net/http"
+	"time"
+)
+
+const apiVersion = "1.16.1"
+
+const (
+	errorCodeGeneric          = 0
+	errorCodeMissingParameter = 10
+	errorCodeWrongCredentials = 40
+	errorCodeNotAuthorized    = 50
+	errorCodeNotFound         = 70
+)
+
+type subsonicResponse struct {
+	XMLName       xml.Name `xml:"subsonic-response" json:"-"`
+	Xmlns         string   `xml:"xmlns,attr" json:"-"`
+	Status        string   `xml:"status,attr" json:"status"`
+	Version       string   `xml:"version,attr" json:"version"`
+	Type          string   `xml:"type,attr" json:"type"`
+	ServerVersion string   `xml:"serverVersion,attr" json:"serverVersion"`
+	OpenSubsonic  bool     `xml:"openSubsonic,attr" json:"openSubsonic"`
+
+	Error         *subsonicError       `xml:"error,omitempty" json:"error,omitempty"`
+	License       *license             `xml:"license,omitempty" json:"license,omitempty"`
+	Artists       *artistsID3          `xml:"artists,omitempty" json:"artists,omitempty"`
+	Artist        *artistWithAlbumsID3 `xml:"artist,omitempty" json:"artist,omitempty"`
+	Album         *albumWithSongsID3   `xml:"album,omitempty" json:"album,omitempty"`
+	Song          *child               `xml:"song,omitempty" json:"song,omitempty"`
+	SearchResult3 *searchResult3       `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+	Playlists     *playlists           `xml:"playlists,omitempty" json:"playlists,omitempty"`
+	Playlist      *playlistWithSongs   `xml:"playlist,omitempty" json:"playlist,omitempty"`
+	Starred2      *starred2            `xml:"starred2,omitempty" json:"starred2,omitempty"`
+}
+
+type subsonicError struct {
+	Code    int    `xml:"code,attr" json:"code"`
+	Message string `xml:"message,attr" json:"message"`
+}
+
+type license struct {
+	Valid bool `xml:"valid,attr" json:"valid"`
+}
+
+type artistsID3 struct {
+	IgnoredArticles string     `xml:"ignoredArticles,attr" json:"ignoredArticles"`
+	Index           []indexID3 `xml:"index" json:"index,omitempty"`
+}
+
+type indexID3 struct {
+	Name   string      `xml:"name,attr" json:"name"`
+	Artist []artistID3 `xml:"artist" json:"artist,omitempty"`
+}
+
+type artistID3 struct {
+	Id         string `xml:"id,attr" json:"id"`
+	Name       string `xml:"name,attr" json:"name"`
+	AlbumCount int    `xml:"albumCount,attr" json:"albumCount"`
+	Starred    string `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+}
+
+type artistWithAlbumsID3 struct {
+	artistID3
+	Album []albumID3 `xml:"album" json:"album,omitempty"`
+}
+
+type albumID3 struct {
+	Id        string `xml:"id,attr" json:"id"`
+	Name      string `xml:"name,attr" json:"name"`
+	Artist    string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	ArtistId  string `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	SongCount int    `xml:"songCount,attr" json:"songCount"`
+	Duration  int    `xml:"duration,attr" json:"duration"`
+	Created   string `xml:"created,attr" json:"created"`
+	Year      int64  `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Starred   string `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+}
+
+type albumWithSongsID3 struct {
+	albumID3
+	Song []child `xml:"song" json:"song,omitempty"`
+}
+
+type child struct {
+	Id          string `xml:"id,attr" json:"id"`
+	Parent      string `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	IsDir       bool   `xml:"isDir,attr" json:"isDir"`
+	Title       string `xml:"title,attr" json:"title"`
+	Album       string `xml:"album,attr,omitempty" json:"album,omitempty"`
+	Artist      string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	Track       int64  `xml:"track,attr,omitempty" json:"track,omitempty"`
+	Year        int64  `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Size        int64  `xml:"size,attr" json:"size"`
+	ContentType string `xml:"contentType,attr" json:"contentType"`
+	Suffix      string `xml:"suffix,attr" json:"suffix"`
+	AlbumId     string `xml:"albumId,attr,omitempty" json:"albumId,omitempty"`
+	ArtistId    string `xml:"artistId,attr,omitempty" json:"artistId,omitempty"`
+	Type        string `xml:"type,attr" json:"type"`
+	Created     string `xml:"created,attr" json:"created"`
+	Starred     string `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+}
+
+type searchResult3 struct {
+	Artist []artistID3 `xml:"artist" json:"artist,omitempty"`
+	Album  []albumID3  `xml:"album" json:"album,omitempty"`
+	Song   []child     `xml:"song" json:"song,omitempty"`
+}
+
+type playlists struct {
+	Playlist []playlist `xml:"playlist" json:"playlist,omitempty"`
+}
+
+type playlist struct {
+	Id        string `xml:"id,attr" json:"id"`
+	Name      string `xml:"name,attr" json:"name"`
+	Owner     string `xml:"owner,attr,omitempty" json:"owner,omitempty"`
+	Public    bool   `xml:"public,attr" json:"public"`
+	SongCount int    `xml:"songCount,attr" json:"songCount"`
+	Duration  int    `xml:"duration,attr" json:"duration"`
+	Created   string `xml:"created,attr" json:"created"`
+	Changed   string `xml:"changed,attr" json:"changed"`
+}
+
+type playlistWithSongs struct {
+	playlist
+	Entry []child `xml:"entry" json:"entry,omitempty"`
+}
+
+type starred2 struct {
+	Artist []artistID3 `xml:"artist" json:"artist,omitempty"`
+	Album  []albumID3  `xml:"album" json:"album,omitempty"`
+	Song   []child     `xml:"song" json:"song,omitempty"`
+}
+
+func newSubsonicResponse() *subsonicResponse {
+	return &subsonicResponse{
+		Xmlns:         "http://subsonic.org/restapi",
+		Status:        "ok",
+		Version:       apiVersion,
+		Type:          "mifasol",
+		ServerVersion: version.AppVersion.String(),
+		OpenSubsonic:  true,
+	}
+}
+
+// writeResponse serializes the response in the format requested by the "f" parameter (xml by default)
+func (s *SubsonicServer) writeResponse(w http.ResponseWriter, r *http.Request, statusCode int, response *subsonicResponse) {
+	switch r.Form.Get("f") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(struct {
+			SubsonicResponse *subsonicResponse `json:"subsonic-response"`
+		}{response})
+	default:
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(statusCode)
+		w.Write([]byte(xml.Header))
+		xml.NewEncoder(w).Encode(response)
+	}
+}
+
+func (s *SubsonicServer) okResponse(w http.ResponseWriter, r *http.Request, response *subsonicResponse) {
+	s.writeResponse(w, r, http.StatusOK, response)
+}
+
+func (s *SubsonicServer) errorResponse(w http.ResponseWriter, r *http.Request, statusCode int, code int, message string) {
+	response := newSubsonicResponse()
+	response.Status = "failed"
+	response.Error = &subsonicError{Code: code, Message: message}
+	s.writeResponse(w, r, statusCode, response)
+}
+
+// tooManyRequestsResponse refuses a request, telling the client when to retry
+func (s *SubsonicServer) tooManyRequestsResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, message string) {
+	w.Header().Set("Retry-After", limiter.RetryAfter(retryAfter))
+	s.errorResponse(w, r, http.StatusTooManyRequests, errorCodeGeneric, message)
+}
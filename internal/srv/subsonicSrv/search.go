@@ -0,0 +1,97 @@
+package subsonicSrv
+
+import (
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultSearchCount = 20
+
+func (s *SubsonicServer) search3(w http.ResponseWriter, r *http.Request) {
+	// An empty query matches everything (used by clients to fetch the whole library)
+	query := tool.SearchLib(strings.Trim(r.Form.Get("query"), `"*`))
+
+	s.log.Debugf("Search: %s", query)
+
+	user := s.connectedUser(r)
+	lib := s.readLibrary(user)
+
+	result := &searchResult3{}
+
+	// Artists
+	var artists []*restApiV1.Artist
+	for _, artist := range lib.artists {
+		if strings.Contains(tool.SearchLib(artist.Name), query) {
+			artists = append(artists, artist)
+		}
+	}
+	sort.Slice(artists, func(i, j int) bool {
+		return artists[i].Name < artists[j].Name
+	})
+	from, to := pageBounds(r, "artist", len(artists))
+	for _, artist := range artists[from:to] {
+		result.Artist = append(result.Artist, lib.newArtistID3(artist))
+	}
+
+	// Albums
+	var albums []*restApiV1.Album
+	for _, album := range lib.albums {
+		if strings.Contains(tool.SearchLib(album.Name), query) {
+			albums = append(albums, album)
+		}
+	}
+	sort.Slice(albums, func(i, j int) bool {
+		return albums[i].Name < albums[j].Name
+	})
+	from, to = pageBounds(r, "album", len(albums))
+	for _, album := range albums[from:to] {
+		result.Album = append(result.Album, lib.newAlbumID3(album))
+	}
+
+	// Songs
+	songs, err := s.store.ReadSongs(nil, &restApiV1.SongFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read songs: %v", err)
+	}
+	var matchingSongs []*restApiV1.Song
+	for ind := range songs {
+		if isVisible(user, &songs[ind]) && strings.Contains(tool.SearchLib(songs[ind].Name), query) {
+			matchingSongs = append(matchingSongs, &songs[ind])
+		}
+	}
+	sort.Slice(matchingSongs, func(i, j int) bool {
+		return matchingSongs[i].Name < matchingSongs[j].Name
+	})
+	from, to = pageBounds(r, "song", len(matchingSongs))
+	for _, song := range matchingSongs[from:to] {
+		result.Song = append(result.Song, lib.newChild(song))
+	}
+
+	response := newSubsonicResponse()
+	response.SearchResult3 = result
+	s.okResponse(w, r, response)
+}
+
+// pageBounds reads the <prefix>Count and <prefix>Offset parameters and returns the matching slice bounds
+func pageBounds(r *http.Request, prefix string, length int) (int, int) {
+	count, err := strconv.Atoi(r.Form.Get(prefix + "Count"))
+	if err != nil || count < 0 {
+		count = defaultSearchCount
+	}
+	offset, err := strconv.Atoi(r.Form.Get(prefix + "Offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	if offset > length {
+		offset = length
+	}
+	if offset+count > length {
+		return offset, length
+	}
+	return offset, offset + count
+}
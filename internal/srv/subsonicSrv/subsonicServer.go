@@ -0,0 +1,170 @@
+package subsonicSrv
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/srv/store"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+type contextKey int
+
+const contextKeyUser contextKey = iota
+
+// SubsonicServer exposes the library through the Subsonic API (http://www.subsonic.org/pages/api.jsp)
+type SubsonicServer struct {
+	store     *store.Store
+	limiter   *limiter.Limiter
+	subRouter *mux.Router
+
+	log *logrus.Entry
+}
+
+func NewSubsonicServer(store *store.Store, limiter *limiter.Limiter, subRouter *mux.Router) *SubsonicServer {
+
+	subsonicServer := &SubsonicServer{
+		store:     store,
+		limiter:   limiter,
+		subRouter: subRouter,
+		log:       logrus.WithField("origin", "subsonic"),
+	}
+
+	// System
+	subsonicServer.handleFunc("ping", subsonicServer.ping)
+	subsonicServer.handleFunc("getLicense", subsonicServer.getLicense)
+
+	// Browsing
+	subsonicServer.handleFunc("getArtists", subsonicServer.getArtists)
+	subsonicServer.handleFunc("getArtist", subsonicServer.getArtist)
+	subsonicServer.handleFunc("getAlbum", subsonicServer.getAlbum)
+	subsonicServer.handleFunc("getSong", subsonicServer.getSong)
+
+	// Searching
+	subsonicServer.handleFunc("search3", subsonicServer.search3)
+
+	// Playlists
+	subsonicServer.handleFunc("getPlaylists", subsonicServer.getPlaylists)
+	subsonicServer.handleFunc("getPlaylist", subsonicServer.getPlaylist)
+	subsonicServer.handleFunc("createPlaylist", subsonicServer.createPlaylist)
+	subsonicServer.handleFunc("updatePlaylist", subsonicServer.updatePlaylist)
+
+	// Media retrieval
+	subsonicServer.handleFunc("stream", subsonicServer.stream)
+	subsonicServer.handleFunc("download", subsonicServer.download)
+
+	// Media annotation
+	subsonicServer.handleFunc("star", subsonicServer.star)
+	subsonicServer.handleFunc("unstar", subsonicServer.unstar)
+	subsonicServer.handleFunc("getStarred2", subsonicServer.getStarred2)
+	subsonicServer.handleFunc("scrobble", subsonicServer.scrobble)
+
+	subsonicServer.subRouter.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		subsonicServer.errorResponse(w, r, http.StatusNotImplemented, errorCodeGeneric, "Not implemented")
+	})
+
+	// Compress the responses of the clients accepting it
+	subsonicServer.subRouter.Use(tool.CompressHandler)
+
+	subsonicServer.subRouter.Use(func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					subsonicServer.log.Warningln("Recovering API Call...")
+					subsonicServer.errorResponse(w, r, http.StatusOK, errorCodeGeneric, "Internal error")
+				}
+			}()
+
+			err := r.ParseForm()
+			if err != nil {
+				subsonicServer.errorResponse(w, r, http.StatusOK, errorCodeMissingParameter, "Unable to parse parameters")
+				return
+			}
+
+			// Check credentials
+			userName := r.Form.Get("u")
+			password := r.Form.Get("p")
+			token := r.Form.Get("t")
+			salt := r.Form.Get("s")
+
+			if userName == "" || (password == "" && (token == "" || salt == "")) {
+				subsonicServer.errorResponse(w, r, http.StatusOK, errorCodeMissingParameter, "Required parameter is missing")
+				return
+			}
+
+			// Subsonic clients send the credentials with each request: only the failures are tracked
+			ip := tool.ClientIp(r)
+			if retryAfter := subsonicServer.limiter.CheckLogin(ip, userName); retryAfter > 0 {
+				subsonicServer.tooManyRequestsResponse(w, r, retryAfter, "Too many failed logins")
+				return
+			}
+
+			user, err := subsonicServer.store.ReadUserByUserName(nil, userName)
+			if err != nil {
+				if err == storeerror.ErrNotFound {
+					subsonicServer.limiter.LoginFailed("subsonic", ip, userName)
+					subsonicServer.errorResponse(w, r, http.StatusOK, errorCodeWrongCredentials, "Wrong username or password")
+					return
+				}
+				subsonicServer.log.Panicf("Unable to read user: %v", err)
+			}
+
+			if !isValidPassword(user.Password, password, token, salt) {
+				subsonicServer.limiter.LoginFailed("subsonic", ip, userName)
+				subsonicServer.errorResponse(w, r, http.StatusOK, errorCodeWrongCredentials, "Wrong username or password")
+				return
+			}
+
+			subsonicServer.log.Debugln("User: " + user.Name)
+
+			if ok, retryAfter := subsonicServer.limiter.AllowRequest("subsonic", "user:"+string(user.Id)); !ok {
+				subsonicServer.tooManyRequestsResponse(w, r, retryAfter, "Too many requests")
+				return
+			}
+
+			handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyUser, user)))
+		})
+	})
+
+	return subsonicServer
+}
+
+// handleFunc registers a Subsonic method, with and without the legacy .view suffix
+func (s *SubsonicServer) handleFunc(method string, f func(http.ResponseWriter, *http.Request)) {
+	s.subRouter.HandleFunc("/"+method, f).Methods("GET", "POST")
+	s.subRouter.HandleFunc("/"+method+".view", f).Methods("GET", "POST")
+}
+
+// isValidPassword checks a clear (or hex encoded with the "enc:" prefix) password or a md5(password + salt) token
+func isValidPassword(userPassword string, password string, token string, salt string) bool {
+	if password != "" {
+		if strings.HasPrefix(password, "enc:") {
+			decodedPassword, err := hex.DecodeString(strings.TrimPrefix(password, "enc:"))
+			if err != nil {
+				return false
+			}
+			password = string(decodedPassword)
+		}
+		return password == userPassword
+	}
+
+	return authenticationToken(userPassword, salt) == strings.ToLower(token)
+}
+
+// authenticationToken returns the md5(password + salt) authentication token expected by the API
+func authenticationToken(password string, salt string) string {
+	hash := md5.Sum([]byte(password + salt))
+	return hex.EncodeToString(hash[:])
+}
+
+func (s *SubsonicServer) connectedUser(r *http.Request) *restApiV1.User {
+	return r.Context().Value(contextKeyUser).(*restApiV1.User)
+}
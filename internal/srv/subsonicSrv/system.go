@@ -0,0 +1,26 @@
+package subsonicSrv
+
+import (
+	"net/http"
+)
+
+func (s *SubsonicServer) ping(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Ping")
+
+	s.okResponse(w, r, newSubsonicResponse())
+}
+
+func (s *SubsonicServer) getLicense(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Get license")
+
+	response := newSubsonicResponse()
+	response.License = &license{Valid: true}
+	s.okResponse(w, r, response)
+}
+
+// scrobble is accepted but ignored: mifasol doesn't keep any play history
+func (s *SubsonicServer) scrobble(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Scrobble: %v", r.Form["id"])
+
+	s.okResponse(w, r, newSubsonicResponse())
+}
@@ -0,0 +1,32 @@
+package srv
+
+import (
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const trashPurgeInterval = time.Hour
+
+// autoPurgeTrash periodically removes trash items older than the configured retention
+func (s *ServerApp) autoPurgeTrash() {
+	defer s.backgroundTasks.Done()
+
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		beforeTs := time.Now().Add(-time.Duration(s.TrashRetentionDays) * 24 * time.Hour).UnixNano()
+		count, err := s.store.PurgeTrash(nil, beforeTs)
+		if err != nil {
+			logrus.Warningf("Unable to purge the trash: %v", err)
+		} else if count > 0 {
+			logrus.Printf("%d item(s) purged from the trash", count)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.stopCh:
+			return
+		}
+	}
+}
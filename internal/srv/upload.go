@@ -0,0 +1,33 @@
+package srv
+
+import (
+	"github.com/jypelle/mifasol/restApiV1"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const uploadPurgeInterval = time.Hour
+
+// autoPurgeUploads periodically removes the uploads left idle, like those abandoned by their client
+func (s *ServerApp) autoPurgeUploads() {
+	defer s.backgroundTasks.Done()
+
+	ticker := time.NewTicker(uploadPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		beforeTs := time.Now().Add(-restApiV1.UploadRetentionHours * time.Hour).UnixNano()
+		count, err := s.store.PurgeUploads(nil, beforeTs)
+		if err != nil {
+			logrus.Warningf("Unable to purge the uploads: %v", err)
+		} else if count > 0 {
+			logrus.Printf("%d idle upload(s) purged", count)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.stopCh:
+			return
+		}
+	}
+}
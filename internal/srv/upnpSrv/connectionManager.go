@@ -0,0 +1,75 @@
+package upnpSrv
+
+import (
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"strings"
+)
+
+// DLNA flags: streaming transfer mode, background transfer mode, connection stalling and DLNA v1.5
+const dlnaFlags = "DLNA.ORG_FLAGS=01700000000000000000000000000000"
+
+// contentFeatures returns the DLNA parameters of the song format
+func contentFeatures(format restApiV1.SongFormat) string {
+	features := "DLNA.ORG_OP=01;DLNA.ORG_CI=0;" + dlnaFlags
+	if format == restApiV1.SongFormatMp3 {
+		features = "DLNA.ORG_PN=MP3;" + features
+	}
+	return features
+}
+
+func protocolInfo(format restApiV1.SongFormat) string {
+	return "http-get:*:" + format.MimeType() + ":" + contentFeatures(format)
+}
+
+func (s *UpnpServer) connectionManagerActions() map[string]action {
+	return map[string]action{
+		"GetProtocolInfo":          s.getProtocolInfo,
+		"GetCurrentConnectionIDs":  s.getCurrentConnectionIds,
+		"GetCurrentConnectionInfo": s.getCurrentConnectionInfo,
+	}
+}
+
+func (s *UpnpServer) connectionManagerEventProperties() []soapArg {
+	return []soapArg{
+		{"SourceProtocolInfo", sourceProtocolInfo()},
+		{"SinkProtocolInfo", ""},
+		{"CurrentConnectionIDs", "0"},
+	}
+}
+
+func sourceProtocolInfo() string {
+	return strings.Join([]string{
+		protocolInfo(restApiV1.SongFormatMp3),
+		protocolInfo(restApiV1.SongFormatFlac),
+		protocolInfo(restApiV1.SongFormatOgg),
+	}, ",")
+}
+
+func (s *UpnpServer) getProtocolInfo(r *http.Request, args map[string]string) ([]soapArg, *upnpError) {
+	return []soapArg{
+		{"Source", sourceProtocolInfo()},
+		{"Sink", ""},
+	}, nil
+}
+
+func (s *UpnpServer) getCurrentConnectionIds(r *http.Request, args map[string]string) ([]soapArg, *upnpError) {
+	return []soapArg{{"ConnectionIDs", "0"}}, nil
+}
+
+// getCurrentConnectionInfo only knows the default connection, as connections are not managed
+func (s *UpnpServer) getCurrentConnectionInfo(r *http.Request, args map[string]string) ([]soapArg, *upnpError) {
+	if strings.TrimSpace(args["ConnectionID"]) != "0" {
+		return nil, newUpnpError(upnpErrorInvalidArgs, "Invalid Args")
+	}
+
+	return []soapArg{
+		{"RcsID", "-1"},
+		{"AVTransportID", "-1"},
+		{"ProtocolInfo", ""},
+		{"PeerConnectionManager", ""},
+		{"PeerConnectionID", "-1"},
+		{"Direction", "Output"},
+		{"Status", "OK"},
+	}, nil
+}
@@ -0,0 +1,57 @@
+package upnpSrv
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/srv/metrics"
+	"github.com/jypelle/mifasol/internal/srv/storeerror"
+	"github.com/jypelle/mifasol/internal/tool"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// readSongContent sends the original song file, the url ends with the file extension expected by some renderers
+func (s *UpnpServer) readSongContent(w http.ResponseWriter, r *http.Request) {
+	fileName := mux.Vars(r)["fileName"]
+	songId := restApiV1.SongId(strings.TrimSuffix(fileName, path.Ext(fileName)))
+
+	s.log.Debugf("Read song content: %s", songId)
+
+	song, err := s.store.ReadSong(nil, songId)
+	if err != nil {
+		if err == storeerror.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		s.log.Panicf("Unable to read song: %v", err)
+	}
+
+	// UPnP devices can't authenticate, their streams are limited per IP like the anonymous share streams.
+	// HEAD requests, sent by renderers to probe the content, don't stream anything.
+	if r.Method != http.MethodHead {
+		release, retryAfter, ok := s.limiter.AcquireStream("upnp", "ip:"+tool.ClientIp(r))
+		if !ok {
+			w.Header().Set("Retry-After", limiter.RetryAfter(retryAfter))
+			http.Error(w, "Too many concurrent streams", http.StatusTooManyRequests)
+			return
+		}
+		defer release()
+	}
+
+	songContent, err := s.store.ReadSongContent(song)
+	if err != nil {
+		s.log.Panicf("Unable to read song content: %v", err)
+	}
+	defer songContent.Close()
+
+	w.Header().Set("Content-Type", song.Format.MimeType())
+	w.Header().Set("Server", serverHeader())
+	w.Header().Set("transferMode.dlna.org", "Streaming")
+	w.Header().Set("contentFeatures.dlna.org", contentFeatures(song.Format))
+	streamWriter, streamDone := metrics.TrackStream(w, "upnp")
+	defer streamDone()
+	http.ServeContent(streamWriter, r, "", time.Unix(0, song.UpdateTs), songContent)
+}
@@ -0,0 +1,386 @@
+package upnpSrv
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Object ids of the content tree:
+//
+//	0
+//	├── artists / artist:{artistId} / artist:{artistId}/{songId}
+//	├── albums / album:{albumId} / album:{albumId}/{songId}
+//	├── playlists / playlist:{playlistId} / playlist:{playlistId}/{position}
+//	└── favorites / favorites:{userId} / favorites:{userId}/{songId}
+const (
+	rootObjectId      = "0"
+	artistsObjectId   = "artists"
+	albumsObjectId    = "albums"
+	playlistsObjectId = "playlists"
+	favoritesObjectId = "favorites"
+
+	artistObjectPrefix    = "artist:"
+	albumObjectPrefix     = "album:"
+	playlistObjectPrefix  = "playlist:"
+	favoritesObjectPrefix = "favorites:"
+)
+
+const (
+	classStorageFolder = "object.container.storageFolder"
+	classMusicArtist   = "object.container.person.musicArtist"
+	classMusicAlbum    = "object.container.album.musicAlbum"
+	classPlaylist      = "object.container.playlistContainer"
+	classMusicTrack    = "object.item.audioItem.musicTrack"
+)
+
+// Songs without album are listed in this album container
+const unknownAlbumTitle = "(Unknown album)"
+
+type didlLite struct {
+	XMLName    xml.Name        `xml:"DIDL-Lite"`
+	Xmlns      string          `xml:"xmlns,attr"`
+	XmlnsDc    string          `xml:"xmlns:dc,attr"`
+	XmlnsUpnp  string          `xml:"xmlns:upnp,attr"`
+	XmlnsDlna  string          `xml:"xmlns:dlna,attr"`
+	Containers []didlContainer `xml:"container"`
+	Items      []didlItem      `xml:"item"`
+}
+
+type didlContainer struct {
+	Id         string `xml:"id,attr"`
+	ParentId   string `xml:"parentID,attr"`
+	ChildCount int    `xml:"childCount,attr"`
+	Restricted string `xml:"restricted,attr"`
+	Searchable string `xml:"searchable,attr"`
+	Title      string `xml:"dc:title"`
+	Class      string `xml:"upnp:class"`
+	Artist     string `xml:"upnp:artist,omitempty"`
+}
+
+type didlItem struct {
+	Id          string  `xml:"id,attr"`
+	ParentId    string  `xml:"parentID,attr"`
+	Restricted  string  `xml:"restricted,attr"`
+	Title       string  `xml:"dc:title"`
+	Class       string  `xml:"upnp:class"`
+	Creator     string  `xml:"dc:creator,omitempty"`
+	Artist      string  `xml:"upnp:artist,omitempty"`
+	Album       string  `xml:"upnp:album,omitempty"`
+	TrackNumber int64   `xml:"upnp:originalTrackNumber,omitempty"`
+	Date        string  `xml:"dc:date,omitempty"`
+	Res         didlRes `xml:"res"`
+}
+
+type didlRes struct {
+	ProtocolInfo string `xml:"protocolInfo,attr"`
+	Size         int64  `xml:"size,attr"`
+	Url          string `xml:",chardata"`
+}
+
+func (s *UpnpServer) contentDirectoryActions() map[string]action {
+	return map[string]action{
+		"Browse":                s.browse,
+		"GetSearchCapabilities": s.getSearchCapabilities,
+		"GetSortCapabilities":   s.getSortCapabilities,
+		"GetSystemUpdateID":     s.getSystemUpdateId,
+	}
+}
+
+func (s *UpnpServer) contentDirectoryEventProperties() []soapArg {
+	return []soapArg{
+		{"SystemUpdateID", strconv.FormatUint(uint64(s.readLibrary().systemUpdateId()), 10)},
+	}
+}
+
+func (s *UpnpServer) getSearchCapabilities(r *http.Request, args map[string]string) ([]soapArg, *upnpError) {
+	return []soapArg{{"SearchCaps", ""}}, nil
+}
+
+func (s *UpnpServer) getSortCapabilities(r *http.Request, args map[string]string) ([]soapArg, *upnpError) {
+	return []soapArg{{"SortCaps", ""}}, nil
+}
+
+func (s *UpnpServer) getSystemUpdateId(r *http.Request, args map[string]string) ([]soapArg, *upnpError) {
+	return []soapArg{{"Id", strconv.FormatUint(uint64(s.readLibrary().systemUpdateId()), 10)}}, nil
+}
+
+// browse returns the metadata of an object or its children. Filter and sort criteria are ignored.
+func (s *UpnpServer) browse(r *http.Request, args map[string]string) ([]soapArg, *upnpError) {
+	startingIndex, upnpErr := uintArg(args, "StartingIndex")
+	if upnpErr != nil {
+		return nil, upnpErr
+	}
+	requestedCount, upnpErr := uintArg(args, "RequestedCount")
+	if upnpErr != nil {
+		return nil, upnpErr
+	}
+
+	b := &browser{
+		lib:     s.readLibrary(),
+		baseUrl: "http://" + r.Host,
+		name:    s.serverConfig.UpnpName,
+	}
+
+	result := didlLite{
+		Xmlns:     "urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/",
+		XmlnsDc:   "http://purl.org/dc/elements/1.1/",
+		XmlnsUpnp: "urn:schemas-upnp-org:metadata-1-0/upnp/",
+		XmlnsDlna: "urn:schemas-dlna-org:metadata-1-0/",
+	}
+	var totalMatches int
+
+	objectId := args["ObjectID"]
+	switch args["BrowseFlag"] {
+	case "BrowseMetadata":
+		container, item, ok := b.object(objectId)
+		if !ok {
+			return nil, newUpnpError(upnpErrorNoSuchObject, "No such object")
+		}
+		if container != nil {
+			result.Containers = append(result.Containers, *container)
+		} else {
+			result.Items = append(result.Items, *item)
+		}
+		totalMatches = 1
+
+	case "BrowseDirectChildren":
+		containers, items, ok := b.children(objectId)
+		if !ok {
+			return nil, newUpnpError(upnpErrorNoSuchObject, "No such object")
+		}
+		totalMatches = len(containers) + len(items)
+
+		// Pagination
+		endIndex := totalMatches
+		if requestedCount > 0 && startingIndex+requestedCount < endIndex {
+			endIndex = startingIndex + requestedCount
+		}
+		for ind := startingIndex; ind < endIndex; ind++ {
+			if ind < len(containers) {
+				result.Containers = append(result.Containers, containers[ind])
+			} else {
+				result.Items = append(result.Items, items[ind-len(containers)])
+			}
+		}
+
+	default:
+		return nil, newUpnpError(upnpErrorInvalidArgs, "Invalid Args")
+	}
+
+	rawResult, err := xml.Marshal(&result)
+	if err != nil {
+		s.log.Panicf("Unable to serialize browse result: %v", err)
+	}
+
+	return []soapArg{
+		{"Result", string(rawResult)},
+		{"NumberReturned", strconv.Itoa(len(result.Containers) + len(result.Items))},
+		{"TotalMatches", strconv.Itoa(totalMatches)},
+		{"UpdateID", strconv.FormatUint(uint64(b.lib.systemUpdateId()), 10)},
+	}, nil
+}
+
+// browser builds the objects of the content tree from a library snapshot
+type browser struct {
+	lib     *library
+	baseUrl string
+	name    string
+}
+
+// object returns the container or the item identified by objectId
+func (b *browser) object(objectId string) (*didlContainer, *didlItem, bool) {
+	if objectId == rootObjectId {
+		containers, _, _ := b.children(rootObjectId)
+		return b.newContainer(rootObjectId, "-1", b.name, classStorageFolder, len(containers)), nil, true
+	}
+
+	// Items are looked up in their container
+	if separatorIndex := strings.LastIndex(objectId, "/"); separatorIndex >= 0 {
+		_, items, ok := b.children(objectId[:separatorIndex])
+		if ok {
+			for ind := range items {
+				if items[ind].Id == objectId {
+					return nil, &items[ind], true
+				}
+			}
+		}
+		return nil, nil, false
+	}
+
+	// Containers are looked up in their parent container
+	var parentId string
+	switch {
+	case objectId == artistsObjectId, objectId == albumsObjectId, objectId == playlistsObjectId, objectId == favoritesObjectId:
+		parentId = rootObjectId
+	case strings.HasPrefix(objectId, artistObjectPrefix):
+		parentId = artistsObjectId
+	case strings.HasPrefix(objectId, albumObjectPrefix):
+		parentId = albumsObjectId
+	case strings.HasPrefix(objectId, playlistObjectPrefix):
+		parentId = playlistsObjectId
+	case strings.HasPrefix(objectId, favoritesObjectPrefix):
+		parentId = favoritesObjectId
+	default:
+		return nil, nil, false
+	}
+
+	containers, _, _ := b.children(parentId)
+	for ind := range containers {
+		if containers[ind].Id == objectId {
+			return &containers[ind], nil, true
+		}
+	}
+	return nil, nil, false
+}
+
+// children returns the child containers and items of a container
+func (b *browser) children(objectId string) ([]didlContainer, []didlItem, bool) {
+	var containers []didlContainer
+	var items []didlItem
+
+	lib := b.lib
+
+	switch {
+	case objectId == rootObjectId:
+		artists, _, _ := b.children(artistsObjectId)
+		albums, _, _ := b.children(albumsObjectId)
+		containers = append(containers,
+			*b.newContainer(artistsObjectId, rootObjectId, "Artists", classStorageFolder, len(artists)),
+			*b.newContainer(albumsObjectId, rootObjectId, "Albums", classStorageFolder, len(albums)),
+			*b.newContainer(playlistsObjectId, rootObjectId, "Playlists", classStorageFolder, len(lib.playlists)),
+			*b.newContainer(favoritesObjectId, rootObjectId, "Favorites", classStorageFolder, len(lib.users)),
+		)
+
+	case objectId == artistsObjectId:
+		for _, artist := range lib.artists {
+			if songs := lib.artistSongs[artist.Id]; len(songs) > 0 {
+				containers = append(containers, *b.newContainer(artistObjectPrefix+string(artist.Id), objectId, artist.Name, classMusicArtist, len(songs)))
+			}
+		}
+
+	case objectId == albumsObjectId:
+		if songs := lib.albumSongs[restApiV1.UnknownAlbumId]; len(songs) > 0 {
+			containers = append(containers, *b.newContainer(albumObjectPrefix+string(restApiV1.UnknownAlbumId), objectId, unknownAlbumTitle, classMusicAlbum, len(songs)))
+		}
+		for _, album := range lib.albums {
+			if songs := lib.albumSongs[album.Id]; len(songs) > 0 {
+				container := b.newContainer(albumObjectPrefix+string(album.Id), objectId, album.Name, classMusicAlbum, len(songs))
+				container.Artist = b.artistNames(album.ArtistIds)
+				containers = append(containers, *container)
+			}
+		}
+
+	case objectId == playlistsObjectId:
+		for ind := range lib.playlists {
+			containers = append(containers, *b.newContainer(playlistObjectPrefix+string(lib.playlists[ind].Id), objectId, lib.playlists[ind].Name, classPlaylist, len(lib.playlistSongs(&lib.playlists[ind]))))
+		}
+
+	case objectId == favoritesObjectId:
+		for _, user := range lib.users {
+			containers = append(containers, *b.newContainer(favoritesObjectPrefix+string(user.Id), objectId, user.Name, classStorageFolder, len(lib.userFavoriteSongs[user.Id])))
+		}
+
+	case strings.HasPrefix(objectId, artistObjectPrefix):
+		artistId := restApiV1.ArtistId(strings.TrimPrefix(objectId, artistObjectPrefix))
+		if _, ok := lib.artistNames[artistId]; !ok {
+			return nil, nil, false
+		}
+		for _, song := range lib.artistSongs[artistId] {
+			items = append(items, *b.newItem(objectId+"/"+string(song.Id), objectId, song))
+		}
+
+	case strings.HasPrefix(objectId, albumObjectPrefix):
+		albumId := restApiV1.AlbumId(strings.TrimPrefix(objectId, albumObjectPrefix))
+		if _, ok := lib.albumNames[albumId]; !ok && albumId != restApiV1.UnknownAlbumId {
+			return nil, nil, false
+		}
+		for _, song := range lib.albumSongs[albumId] {
+			items = append(items, *b.newItem(objectId+"/"+string(song.Id), objectId, song))
+		}
+
+	case strings.HasPrefix(objectId, playlistObjectPrefix):
+		playlistId := restApiV1.PlaylistId(strings.TrimPrefix(objectId, playlistObjectPrefix))
+		var playlist *restApiV1.Playlist
+		for ind := range lib.playlists {
+			if lib.playlists[ind].Id == playlistId {
+				playlist = &lib.playlists[ind]
+			}
+		}
+		if playlist == nil {
+			return nil, nil, false
+		}
+		// A song can be several times in a playlist, so items are identified by their position
+		for position, song := range lib.playlistSongs(playlist) {
+			items = append(items, *b.newItem(objectId+"/"+strconv.Itoa(position), objectId, song))
+		}
+
+	case strings.HasPrefix(objectId, favoritesObjectPrefix):
+		userId := restApiV1.UserId(strings.TrimPrefix(objectId, favoritesObjectPrefix))
+		found := false
+		for _, user := range lib.users {
+			found = found || user.Id == userId
+		}
+		if !found {
+			return nil, nil, false
+		}
+		for _, song := range lib.userFavoriteSongs[userId] {
+			items = append(items, *b.newItem(objectId+"/"+string(song.Id), objectId, song))
+		}
+
+	default:
+		return nil, nil, false
+	}
+
+	return containers, items, true
+}
+
+func (b *browser) newContainer(id string, parentId string, title string, class string, childCount int) *didlContainer {
+	return &didlContainer{
+		Id:         id,
+		ParentId:   parentId,
+		ChildCount: childCount,
+		Restricted: "1",
+		Searchable: "0",
+		Title:      title,
+		Class:      class,
+	}
+}
+
+func (b *browser) newItem(id string, parentId string, song *restApiV1.Song) *didlItem {
+	item := &didlItem{
+		Id:         id,
+		ParentId:   parentId,
+		Restricted: "1",
+		Title:      song.Name,
+		Class:      classMusicTrack,
+		Creator:    b.artistNames(song.ArtistIds),
+		Artist:     b.artistNames(song.ArtistIds),
+		Album:      b.lib.albumName(song.AlbumId),
+		Res: didlRes{
+			ProtocolInfo: protocolInfo(song.Format),
+			Size:         song.Size,
+			Url:          b.baseUrl + "/content/" + string(song.Id) + song.Format.Extension(),
+		},
+	}
+	if song.TrackNumber != nil {
+		item.TrackNumber = *song.TrackNumber
+	}
+	if song.PublicationYear != nil {
+		item.Date = fmt.Sprintf("%04d-01-01", *song.PublicationYear)
+	}
+	return item
+}
+
+func (b *browser) artistNames(artistIds []restApiV1.ArtistId) string {
+	var names []string
+	for _, artistId := range artistIds {
+		if name, ok := b.lib.artistNames[artistId]; ok {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
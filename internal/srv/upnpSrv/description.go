@@ -0,0 +1,162 @@
+package upnpSrv
+
+import (
+	"encoding/xml"
+	"github.com/jypelle/mifasol/internal/version"
+	"net/http"
+	"strings"
+)
+
+func (s *UpnpServer) rootDescription(w http.ResponseWriter, r *http.Request) {
+	s.log.Debugf("Read device description")
+
+	var description strings.Builder
+	description.WriteString(xml.Header)
+	description.WriteString(`<root xmlns="urn:schemas-upnp-org:device-1-0" xmlns:dlna="urn:schemas-dlna-org:device-1-0">`)
+	description.WriteString(`<specVersion><major>1</major><minor>0</minor></specVersion>`)
+	description.WriteString(`<device>`)
+	description.WriteString(`<deviceType>` + deviceType + `</deviceType>`)
+	description.WriteString(`<friendlyName>` + xmlEscape(s.serverConfig.UpnpName) + `</friendlyName>`)
+	description.WriteString(`<manufacturer>Mifasol</manufacturer>`)
+	description.WriteString(`<manufacturerURL>https://github.com/jypelle/mifasol</manufacturerURL>`)
+	description.WriteString(`<modelDescription>Mifasol music server</modelDescription>`)
+	description.WriteString(`<modelName>Mifasol</modelName>`)
+	description.WriteString(`<modelNumber>` + version.AppVersion.String() + `</modelNumber>`)
+	description.WriteString(`<UDN>` + s.udn + `</UDN>`)
+	description.WriteString(`<dlna:X_DLNADOC>DMS-1.50</dlna:X_DLNADOC>`)
+	description.WriteString(`<serviceList>`)
+	description.WriteString(`<service>`)
+	description.WriteString(`<serviceType>` + contentDirectoryServiceType + `</serviceType>`)
+	description.WriteString(`<serviceId>urn:upnp-org:serviceId:ContentDirectory</serviceId>`)
+	description.WriteString(`<SCPDURL>/ContentDirectory.xml</SCPDURL>`)
+	description.WriteString(`<controlURL>/ctl/ContentDirectory</controlURL>`)
+	description.WriteString(`<eventSubURL>/evt/ContentDirectory</eventSubURL>`)
+	description.WriteString(`</service>`)
+	description.WriteString(`<service>`)
+	description.WriteString(`<serviceType>` + connectionManagerServiceType + `</serviceType>`)
+	description.WriteString(`<serviceId>urn:upnp-org:serviceId:ConnectionManager</serviceId>`)
+	description.WriteString(`<SCPDURL>/ConnectionManager.xml</SCPDURL>`)
+	description.WriteString(`<controlURL>/ctl/ConnectionManager</controlURL>`)
+	description.WriteString(`<eventSubURL>/evt/ConnectionManager</eventSubURL>`)
+	description.WriteString(`</service>`)
+	description.WriteString(`</serviceList>`)
+	description.WriteString(`</device>`)
+	description.WriteString(`</root>`)
+
+	writeXml(w, description.String())
+}
+
+func (s *UpnpServer) serviceDescription(description string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.log.Debugf("Read service description: %s", r.URL.Path)
+		writeXml(w, xml.Header+description)
+	}
+}
+
+func writeXml(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("Server", serverHeader())
+	w.Write([]byte(content))
+}
+
+func xmlEscape(value string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
+
+const contentDirectoryDescription = `<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+<specVersion><major>1</major><minor>0</minor></specVersion>
+<actionList>
+<action>
+<name>Browse</name>
+<argumentList>
+<argument><name>ObjectID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ObjectID</relatedStateVariable></argument>
+<argument><name>BrowseFlag</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_BrowseFlag</relatedStateVariable></argument>
+<argument><name>Filter</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Filter</relatedStateVariable></argument>
+<argument><name>StartingIndex</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Index</relatedStateVariable></argument>
+<argument><name>RequestedCount</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+<argument><name>SortCriteria</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_SortCriteria</relatedStateVariable></argument>
+<argument><name>Result</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Result</relatedStateVariable></argument>
+<argument><name>NumberReturned</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+<argument><name>TotalMatches</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+<argument><name>UpdateID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_UpdateID</relatedStateVariable></argument>
+</argumentList>
+</action>
+<action>
+<name>GetSearchCapabilities</name>
+<argumentList>
+<argument><name>SearchCaps</name><direction>out</direction><relatedStateVariable>SearchCapabilities</relatedStateVariable></argument>
+</argumentList>
+</action>
+<action>
+<name>GetSortCapabilities</name>
+<argumentList>
+<argument><name>SortCaps</name><direction>out</direction><relatedStateVariable>SortCapabilities</relatedStateVariable></argument>
+</argumentList>
+</action>
+<action>
+<name>GetSystemUpdateID</name>
+<argumentList>
+<argument><name>Id</name><direction>out</direction><relatedStateVariable>SystemUpdateID</relatedStateVariable></argument>
+</argumentList>
+</action>
+</actionList>
+<serviceStateTable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_ObjectID</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_Result</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_BrowseFlag</name><dataType>string</dataType><allowedValueList><allowedValue>BrowseMetadata</allowedValue><allowedValue>BrowseDirectChildren</allowedValue></allowedValueList></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_Filter</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_SortCriteria</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_Index</name><dataType>ui4</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_Count</name><dataType>ui4</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_UpdateID</name><dataType>ui4</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>SearchCapabilities</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>SortCapabilities</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="yes"><name>SystemUpdateID</name><dataType>ui4</dataType></stateVariable>
+</serviceStateTable>
+</scpd>`
+
+const connectionManagerDescription = `<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+<specVersion><major>1</major><minor>0</minor></specVersion>
+<actionList>
+<action>
+<name>GetProtocolInfo</name>
+<argumentList>
+<argument><name>Source</name><direction>out</direction><relatedStateVariable>SourceProtocolInfo</relatedStateVariable></argument>
+<argument><name>Sink</name><direction>out</direction><relatedStateVariable>SinkProtocolInfo</relatedStateVariable></argument>
+</argumentList>
+</action>
+<action>
+<name>GetCurrentConnectionIDs</name>
+<argumentList>
+<argument><name>ConnectionIDs</name><direction>out</direction><relatedStateVariable>CurrentConnectionIDs</relatedStateVariable></argument>
+</argumentList>
+</action>
+<action>
+<name>GetCurrentConnectionInfo</name>
+<argumentList>
+<argument><name>ConnectionID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ConnectionID</relatedStateVariable></argument>
+<argument><name>RcsID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_RcsID</relatedStateVariable></argument>
+<argument><name>AVTransportID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_AVTransportID</relatedStateVariable></argument>
+<argument><name>ProtocolInfo</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ProtocolInfo</relatedStateVariable></argument>
+<argument><name>PeerConnectionManager</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ConnectionManager</relatedStateVariable></argument>
+<argument><name>PeerConnectionID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ConnectionID</relatedStateVariable></argument>
+<argument><name>Direction</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Direction</relatedStateVariable></argument>
+<argument><name>Status</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_ConnectionStatus</relatedStateVariable></argument>
+</argumentList>
+</action>
+</actionList>
+<serviceStateTable>
+<stateVariable sendEvents="yes"><name>SourceProtocolInfo</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="yes"><name>SinkProtocolInfo</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="yes"><name>CurrentConnectionIDs</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_ConnectionStatus</name><dataType>string</dataType><allowedValueList><allowedValue>OK</allowedValue><allowedValue>ContentFormatMismatch</allowedValue><allowedValue>InsufficientBandwidth</allowedValue><allowedValue>UnreliableChannel</allowedValue><allowedValue>Unknown</allowedValue></allowedValueList></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_ConnectionManager</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_Direction</name><dataType>string</dataType><allowedValueList><allowedValue>Input</allowedValue><allowedValue>Output</allowedValue></allowedValueList></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_ProtocolInfo</name><dataType>string</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_ConnectionID</name><dataType>i4</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_AVTransportID</name><dataType>i4</dataType></stateVariable>
+<stateVariable sendEvents="no"><name>A_ARG_TYPE_RcsID</name><dataType>i4</dataType></stateVariable>
+</serviceStateTable>
+</scpd>`
@@ -0,0 +1,200 @@
+package upnpSrv
+
+import (
+	"github.com/jypelle/mifasol/internal/tool"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const subscriptionDuration = 1800 * time.Second
+
+// Maximum number of active event subscriptions, renderers usually subscribing once per service
+const maxSubscriptionCount = 256
+
+// subscription is an event subscription of a control point, kept until it expires or is cancelled
+type subscription struct {
+	path       string
+	callback   string
+	expiration time.Time
+}
+
+// subscriptions tracks the active event subscriptions by SID
+type subscriptions struct {
+	mutex sync.Mutex
+	bySid map[string]*subscription
+}
+
+// subscribe accepts event subscriptions and their renewals, and sends the initial event message.
+// State variables are only sent with this initial event.
+func (s *UpnpServer) subscribe(properties func() []soapArg) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sid := r.Header.Get("SID")
+
+		// Subscription renewal
+		if sid != "" {
+			if r.Header.Get("NT") != "" || r.Header.Get("CALLBACK") != "" {
+				http.Error(w, "Incompatible header fields", http.StatusBadRequest)
+				return
+			}
+			if !s.renewSubscription(sid, r.URL.Path) {
+				http.Error(w, "Precondition Failed", http.StatusPreconditionFailed)
+				return
+			}
+			s.writeSubscriptionHeaders(w, sid)
+			return
+		}
+
+		// The events are only sent back to the subscribing device, never to another host
+		callback := clientCallback(parseCallbacks(r.Header.Get("CALLBACK")), tool.ClientIp(r))
+		if r.Header.Get("NT") != "upnp:event" || callback == "" {
+			http.Error(w, "Precondition Failed", http.StatusPreconditionFailed)
+			return
+		}
+
+		sid = "uuid:" + strings.ToLower(tool.CreateUlid())
+		if !s.addSubscription(sid, &subscription{path: r.URL.Path, callback: callback}) {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		s.log.Debugf("New event subscription %s for %s", sid, r.URL.Path)
+
+		s.writeSubscriptionHeaders(w, sid)
+
+		go s.sendEvent(callback, sid, properties())
+	}
+}
+
+func (s *UpnpServer) unsubscribe(w http.ResponseWriter, r *http.Request) {
+	sid := r.Header.Get("SID")
+	if sid == "" || !s.removeSubscription(sid, r.URL.Path) {
+		http.Error(w, "Precondition Failed", http.StatusPreconditionFailed)
+		return
+	}
+	s.log.Debugf("End of event subscription %s", sid)
+}
+
+func (s *UpnpServer) writeSubscriptionHeaders(w http.ResponseWriter, sid string) {
+	w.Header().Set("SID", sid)
+	w.Header().Set("TIMEOUT", "Second-"+strconv.Itoa(int(subscriptionDuration/time.Second)))
+	w.Header().Set("Server", serverHeader())
+}
+
+// addSubscription registers a new subscription, false when too many subscriptions are active
+func (s *UpnpServer) addSubscription(sid string, newSubscription *subscription) bool {
+	s.subscriptions.mutex.Lock()
+	defer s.subscriptions.mutex.Unlock()
+
+	now := time.Now()
+	for existingSid, existingSubscription := range s.subscriptions.bySid {
+		if now.After(existingSubscription.expiration) {
+			delete(s.subscriptions.bySid, existingSid)
+		}
+	}
+	if len(s.subscriptions.bySid) >= maxSubscriptionCount {
+		return false
+	}
+
+	newSubscription.expiration = now.Add(subscriptionDuration)
+	s.subscriptions.bySid[sid] = newSubscription
+	return true
+}
+
+// renewSubscription extends an active subscription of the service, false when unknown or expired
+func (s *UpnpServer) renewSubscription(sid string, path string) bool {
+	s.subscriptions.mutex.Lock()
+	defer s.subscriptions.mutex.Unlock()
+
+	existingSubscription, ok := s.subscriptions.bySid[sid]
+	if !ok || existingSubscription.path != path {
+		return false
+	}
+	if time.Now().After(existingSubscription.expiration) {
+		delete(s.subscriptions.bySid, sid)
+		return false
+	}
+	existingSubscription.expiration = time.Now().Add(subscriptionDuration)
+	return true
+}
+
+// removeSubscription cancels an active subscription of the service, false when unknown or expired
+func (s *UpnpServer) removeSubscription(sid string, path string) bool {
+	s.subscriptions.mutex.Lock()
+	defer s.subscriptions.mutex.Unlock()
+
+	existingSubscription, ok := s.subscriptions.bySid[sid]
+	if !ok || existingSubscription.path != path {
+		return false
+	}
+	delete(s.subscriptions.bySid, sid)
+	return !time.Now().After(existingSubscription.expiration)
+}
+
+func (s *UpnpServer) sendEvent(callback string, sid string, properties []soapArg) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0"?>`)
+	body.WriteString(`<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">`)
+	for _, property := range properties {
+		body.WriteString(`<e:property><` + property.name + `>` + xmlEscape(property.value) + `</` + property.name + `></e:property>`)
+	}
+	body.WriteString(`</e:propertyset>`)
+
+	request, err := http.NewRequest("NOTIFY", callback, strings.NewReader(body.String()))
+	if err != nil {
+		s.log.Debugf("Invalid event callback %s: %v", callback, err)
+		return
+	}
+	request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	request.Header.Set("NT", "upnp:event")
+	request.Header.Set("NTS", "upnp:propchange")
+	request.Header.Set("SID", sid)
+	request.Header.Set("SEQ", "0")
+
+	// A redirection could lead to another host than the subscribing device
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		s.log.Debugf("Unable to send event to %s: %v", callback, err)
+		return
+	}
+	response.Body.Close()
+}
+
+// parseCallbacks reads the http urls of a "<url1><url2>" CALLBACK header
+func parseCallbacks(header string) []string {
+	var callbacks []string
+	for _, callback := range strings.Split(header, "<") {
+		callback = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(callback), ">"))
+		if strings.HasPrefix(callback, "http://") {
+			callbacks = append(callbacks, callback)
+		}
+	}
+	return callbacks
+}
+
+// clientCallback returns the first callback url whose host is the IP address of the client, "" if none
+func clientCallback(callbacks []string, clientIp string) string {
+	parsedClientIp := net.ParseIP(clientIp)
+	if parsedClientIp == nil {
+		return ""
+	}
+	for _, callback := range callbacks {
+		callbackUrl, err := url.Parse(callback)
+		if err != nil {
+			continue
+		}
+		if callbackIp := net.ParseIP(callbackUrl.Hostname()); callbackIp != nil && callbackIp.Equal(parsedClientIp) {
+			return callback
+		}
+	}
+	return ""
+}
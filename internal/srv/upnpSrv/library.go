@@ -0,0 +1,168 @@
+package upnpSrv
+
+import (
+	"github.com/jypelle/mifasol/restApiV1"
+	"sort"
+)
+
+// library is a snapshot of the content exposed through the ContentDirectory service
+type library struct {
+	songs             map[restApiV1.SongId]*restApiV1.Song
+	albums            []restApiV1.Album
+	albumNames        map[restApiV1.AlbumId]string
+	albumSongs        map[restApiV1.AlbumId][]*restApiV1.Song
+	artists           []restApiV1.Artist
+	artistNames       map[restApiV1.ArtistId]string
+	artistSongs       map[restApiV1.ArtistId][]*restApiV1.Song
+	playlists         []restApiV1.Playlist
+	users             []restApiV1.User
+	userFavoriteSongs map[restApiV1.UserId][]*restApiV1.Song
+
+	// Last modification timestamp, used as system update id
+	updateTs int64
+}
+
+func (s *UpnpServer) readLibrary() *library {
+	lib := &library{
+		songs:             make(map[restApiV1.SongId]*restApiV1.Song),
+		albumNames:        make(map[restApiV1.AlbumId]string),
+		albumSongs:        make(map[restApiV1.AlbumId][]*restApiV1.Song),
+		artistNames:       make(map[restApiV1.ArtistId]string),
+		artistSongs:       make(map[restApiV1.ArtistId][]*restApiV1.Song),
+		userFavoriteSongs: make(map[restApiV1.UserId][]*restApiV1.Song),
+	}
+
+	var err error
+
+	lib.albums, err = s.store.ReadAlbums(nil, &restApiV1.AlbumFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read albums: %v", err)
+	}
+	for _, album := range lib.albums {
+		lib.albumNames[album.Id] = album.Name
+		lib.touch(album.UpdateTs)
+	}
+
+	lib.artists, err = s.store.ReadArtists(nil, &restApiV1.ArtistFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read artists: %v", err)
+	}
+	for _, artist := range lib.artists {
+		lib.artistNames[artist.Id] = artist.Name
+		lib.touch(artist.UpdateTs)
+	}
+
+	songs, err := s.store.ReadSongs(nil, &restApiV1.SongFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read songs: %v", err)
+	}
+	orderedSongs := make([]*restApiV1.Song, 0, len(songs))
+	for ind := range songs {
+		lib.songs[songs[ind].Id] = &songs[ind]
+		orderedSongs = append(orderedSongs, &songs[ind])
+		lib.touch(songs[ind].UpdateTs)
+	}
+
+	// Order songs by album, track number and name
+	sort.SliceStable(orderedSongs, func(i, j int) bool {
+		songI := orderedSongs[i]
+		songJ := orderedSongs[j]
+		if songI.AlbumId != songJ.AlbumId {
+			return lib.albumName(songI.AlbumId) < lib.albumName(songJ.AlbumId)
+		}
+		trackI := int64(0)
+		if songI.TrackNumber != nil {
+			trackI = *songI.TrackNumber
+		}
+		trackJ := int64(0)
+		if songJ.TrackNumber != nil {
+			trackJ = *songJ.TrackNumber
+		}
+		if trackI != trackJ {
+			return trackI < trackJ
+		}
+		return songI.Name < songJ.Name
+	})
+	for _, song := range orderedSongs {
+		lib.albumSongs[song.AlbumId] = append(lib.albumSongs[song.AlbumId], song)
+		for _, artistId := range song.ArtistIds {
+			lib.artistSongs[artistId] = append(lib.artistSongs[artistId], song)
+		}
+	}
+
+	lib.playlists, err = s.store.ReadPlaylists(nil, &restApiV1.PlaylistFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read playlists: %v", err)
+	}
+	for _, playlist := range lib.playlists {
+		lib.touch(playlist.ContentUpdateTs)
+	}
+
+	lib.users, err = s.store.ReadUsers(nil, &restApiV1.UserFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read users: %v", err)
+	}
+	sort.SliceStable(lib.users, func(i, j int) bool { return lib.users[i].Name < lib.users[j].Name })
+	users := make(map[restApiV1.UserId]*restApiV1.User)
+	for ind := range lib.users {
+		users[lib.users[ind].Id] = &lib.users[ind]
+	}
+
+	favoriteSongs, err := s.store.ReadFavoriteSongs(nil, &restApiV1.FavoriteSongFilter{})
+	if err != nil {
+		s.log.Panicf("Unable to read favorite songs: %v", err)
+	}
+	for _, favoriteSong := range favoriteSongs {
+		lib.touch(favoriteSong.UpdateTs)
+		user, ok := users[favoriteSong.Id.UserId]
+		if !ok {
+			continue
+		}
+		song, ok := lib.songs[favoriteSong.Id.SongId]
+		if !ok || (user.HideExplicitFg && song.ExplicitFg) {
+			continue
+		}
+		lib.userFavoriteSongs[user.Id] = append(lib.userFavoriteSongs[user.Id], song)
+	}
+	for _, favoriteSongs := range lib.userFavoriteSongs {
+		sort.SliceStable(favoriteSongs, func(i, j int) bool {
+			return favoriteSongs[i].Name < favoriteSongs[j].Name
+		})
+	}
+
+	// Order containers by name
+	sort.SliceStable(lib.albums, func(i, j int) bool { return lib.albums[i].Name < lib.albums[j].Name })
+	sort.SliceStable(lib.artists, func(i, j int) bool { return lib.artists[i].Name < lib.artists[j].Name })
+	sort.SliceStable(lib.playlists, func(i, j int) bool { return lib.playlists[i].Name < lib.playlists[j].Name })
+
+	return lib
+}
+
+func (l *library) touch(ts int64) {
+	if ts > l.updateTs {
+		l.updateTs = ts
+	}
+}
+
+// systemUpdateId returns the last library modification time, in seconds
+func (l *library) systemUpdateId() uint32 {
+	return uint32(l.updateTs / 1000000000)
+}
+
+func (l *library) albumName(albumId restApiV1.AlbumId) string {
+	if albumId == restApiV1.UnknownAlbumId {
+		return ""
+	}
+	return l.albumNames[albumId]
+}
+
+// playlistSongs returns the existing songs of the playlist
+func (l *library) playlistSongs(playlist *restApiV1.Playlist) []*restApiV1.Song {
+	var songs []*restApiV1.Song
+	for _, songId := range playlist.SongIds {
+		if song, ok := l.songs[songId]; ok {
+			songs = append(songs, song)
+		}
+	}
+	return songs
+}
@@ -0,0 +1,120 @@
+package upnpSrv
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// UPnP control error codes
+const (
+	upnpErrorInvalidAction = 401
+	upnpErrorInvalidArgs   = 402
+	upnpErrorNoSuchObject  = 701
+)
+
+type upnpError struct {
+	code        int
+	description string
+}
+
+func newUpnpError(code int, description string) *upnpError {
+	return &upnpError{code: code, description: description}
+}
+
+// soapArg is an output argument of an action: the order of the arguments is part of the service description
+type soapArg struct {
+	name  string
+	value string
+}
+
+type action func(r *http.Request, args map[string]string) ([]soapArg, *upnpError)
+
+type soapEnvelope struct {
+	Body struct {
+		Action struct {
+			XMLName xml.Name
+			Args    []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:",any"`
+	} `xml:"Body"`
+}
+
+// control calls the actions of a service
+func (s *UpnpServer) control(serviceType string, actions map[string]action) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var envelope soapEnvelope
+		err := xml.NewDecoder(r.Body).Decode(&envelope)
+		if err != nil {
+			s.log.Debugf("Unable to decode SOAP request: %v", err)
+			writeSoapError(w, newUpnpError(upnpErrorInvalidAction, "Invalid Action"))
+			return
+		}
+
+		actionName := envelope.Body.Action.XMLName.Local
+		s.log.Debugf("Call action: %s", actionName)
+
+		act, ok := actions[actionName]
+		if !ok {
+			writeSoapError(w, newUpnpError(upnpErrorInvalidAction, "Invalid Action"))
+			return
+		}
+
+		args := make(map[string]string)
+		for _, arg := range envelope.Body.Action.Args {
+			args[arg.XMLName.Local] = arg.Value
+		}
+
+		outArgs, upnpErr := act(r, args)
+		if upnpErr != nil {
+			writeSoapError(w, upnpErr)
+			return
+		}
+
+		var response strings.Builder
+		response.WriteString(xml.Header)
+		response.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+		response.WriteString(`<u:` + actionName + `Response xmlns:u="` + serviceType + `">`)
+		for _, outArg := range outArgs {
+			response.WriteString(`<` + outArg.name + `>` + xmlEscape(outArg.value) + `</` + outArg.name + `>`)
+		}
+		response.WriteString(`</u:` + actionName + `Response>`)
+		response.WriteString(`</s:Body></s:Envelope>`)
+
+		writeXml(w, response.String())
+	}
+}
+
+func writeSoapError(w http.ResponseWriter, upnpErr *upnpError) {
+	var response strings.Builder
+	response.WriteString(xml.Header)
+	response.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	response.WriteString(`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`)
+	response.WriteString(`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0">`)
+	response.WriteString(`<errorCode>` + strconv.Itoa(upnpErr.code) + `</errorCode>`)
+	response.WriteString(`<errorDescription>` + xmlEscape(upnpErr.description) + `</errorDescription>`)
+	response.WriteString(`</UPnPError>`)
+	response.WriteString(`</detail></s:Fault>`)
+	response.WriteString(`</s:Body></s:Envelope>`)
+
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("Server", serverHeader())
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(response.String()))
+}
+
+// uintArg reads an optional unsigned integer argument
+func uintArg(args map[string]string, name string) (int, *upnpError) {
+	value := strings.TrimSpace(args[name])
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, newUpnpError(upnpErrorInvalidArgs, "Invalid Args")
+	}
+	return int(number), nil
+}
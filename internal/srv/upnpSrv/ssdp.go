@@ -0,0 +1,255 @@
+package upnpSrv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	ssdpAddress = "239.255.255.250:1900"
+	ssdpMaxAge  = 1800
+)
+
+// ssdpServer answers the discovery requests and periodically announces the media server on the local network
+type ssdpServer struct {
+	server *UpnpServer
+	conn   *net.UDPConn
+	stopCh chan struct{}
+	group  sync.WaitGroup
+}
+
+func newSsdpServer(server *UpnpServer) *ssdpServer {
+	return &ssdpServer{
+		server: server,
+	}
+}
+
+func (s *ssdpServer) start() error {
+	groupAddr, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return err
+	}
+
+	// Unicast discovery requests are also received through this socket
+	conn, err := net.ListenMulticastUDP("udp4", nil, groupAddr)
+	if err != nil {
+		s.server.log.Warnf("Unable to join the SSDP multicast group, only unicast discovery requests will be answered: %v", err)
+		conn, err = net.ListenUDP("udp4", &net.UDPAddr{Port: groupAddr.Port})
+		if err != nil {
+			return err
+		}
+	}
+	s.conn = conn
+	s.stopCh = make(chan struct{})
+
+	s.group.Add(2)
+	go s.serve()
+	go s.announce()
+
+	return nil
+}
+
+func (s *ssdpServer) stop() {
+	if s.conn == nil {
+		return
+	}
+	close(s.stopCh)
+	s.conn.Close()
+	s.group.Wait()
+}
+
+// notificationTypes returns the device and service types to announce
+func (s *ssdpServer) notificationTypes() []string {
+	return []string{
+		"upnp:rootdevice",
+		s.server.udn,
+		deviceType,
+		contentDirectoryServiceType,
+		connectionManagerServiceType,
+	}
+}
+
+func (s *ssdpServer) usn(notificationType string) string {
+	if notificationType == s.server.udn {
+		return s.server.udn
+	}
+	return s.server.udn + "::" + notificationType
+}
+
+// location returns the url of the device description reachable from the local address ip, or from the bind address
+func (s *ssdpServer) location(ip net.IP) string {
+	if bindIp := s.server.bindIp(); bindIp != nil {
+		ip = bindIp
+	}
+	return "http://" + net.JoinHostPort(ip.String(), strconv.FormatInt(s.server.serverConfig.UpnpPort, 10)) + "/rootDesc.xml"
+}
+
+// serve answers the M-SEARCH requests
+func (s *ssdpServer) serve() {
+	defer s.group.Done()
+
+	buffer := make([]byte, 2048)
+	for {
+		n, remoteAddr, err := s.conn.ReadFromUDP(buffer)
+		if err != nil {
+			select {
+			case <-s.stopCh:
+				return
+			default:
+				s.server.log.Debugf("Unable to read SSDP message: %v", err)
+				continue
+			}
+		}
+
+		request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buffer[:n])))
+		if err != nil || request.Method != "M-SEARCH" || request.Header.Get("MAN") != `"ssdp:discover"` {
+			continue
+		}
+
+		searchTarget := request.Header.Get("ST")
+		var targets []string
+		for _, notificationType := range s.notificationTypes() {
+			if searchTarget == "ssdp:all" || searchTarget == notificationType {
+				targets = append(targets, notificationType)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		s.server.log.Debugf("Answer SSDP search %s from %s", searchTarget, remoteAddr)
+
+		localIp, err := localAddress(remoteAddr)
+		if err != nil {
+			s.server.log.Debugf("No route to %s: %v", remoteAddr, err)
+			continue
+		}
+
+		for _, target := range targets {
+			response := "HTTP/1.1 200 OK\r\n" +
+				"CACHE-CONTROL: max-age=" + strconv.Itoa(ssdpMaxAge) + "\r\n" +
+				"DATE: " + time.Now().UTC().Format(http.TimeFormat) + "\r\n" +
+				"EXT:\r\n" +
+				"LOCATION: " + s.location(localIp) + "\r\n" +
+				"SERVER: " + serverHeader() + "\r\n" +
+				"ST: " + target + "\r\n" +
+				"USN: " + s.usn(target) + "\r\n" +
+				"Content-Length: 0\r\n" +
+				"\r\n"
+			_, err = s.conn.WriteToUDP([]byte(response), remoteAddr)
+			if err != nil {
+				s.server.log.Debugf("Unable to answer SSDP search: %v", err)
+			}
+		}
+	}
+}
+
+// announce sends the alive notifications until the server stops
+func (s *ssdpServer) announce() {
+	defer s.group.Done()
+
+	s.notify("ssdp:alive")
+
+	ticker := time.NewTicker(ssdpMaxAge / 2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.notify("ssdp:alive")
+		case <-s.stopCh:
+			s.notify("ssdp:byebye")
+			return
+		}
+	}
+}
+
+// notify multicasts a NOTIFY message for every notification type from each local network interface,
+// or only from the bind address
+func (s *ssdpServer) notify(notificationSubType string) {
+	groupAddr, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return
+	}
+
+	bindIp := s.server.bindIp()
+	for _, ip := range multicastAddresses() {
+		if bindIp != nil && !bindIp.Equal(ip) {
+			continue
+		}
+
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: ip})
+		if err != nil {
+			s.server.log.Debugf("Unable to send SSDP notification from %s: %v", ip, err)
+			continue
+		}
+
+		for _, notificationType := range s.notificationTypes() {
+			message := "NOTIFY * HTTP/1.1\r\n" +
+				"HOST: " + ssdpAddress + "\r\n" +
+				"NT: " + notificationType + "\r\n" +
+				"NTS: " + notificationSubType + "\r\n" +
+				"USN: " + s.usn(notificationType) + "\r\n"
+			if notificationSubType == "ssdp:alive" {
+				message += "CACHE-CONTROL: max-age=" + strconv.Itoa(ssdpMaxAge) + "\r\n" +
+					"LOCATION: " + s.location(ip) + "\r\n" +
+					"SERVER: " + serverHeader() + "\r\n"
+			}
+			message += "\r\n"
+
+			_, err = conn.WriteToUDP([]byte(message), groupAddr)
+			if err != nil {
+				s.server.log.Debugf("Unable to send SSDP notification from %s: %v", ip, err)
+			}
+		}
+
+		conn.Close()
+	}
+}
+
+// multicastAddresses returns the IPv4 addresses of the active multicast network interfaces
+func multicastAddresses() []net.IP {
+	var ips []net.IP
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, ifi := range interfaces {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 || ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				ips = append(ips, ipNet.IP.To4())
+			}
+		}
+	}
+
+	return ips
+}
+
+// localAddress returns the local IP address used to reach a remote host
+func localAddress(remoteAddr *net.UDPAddr) (net.IP, error) {
+	conn, err := net.DialUDP("udp4", nil, remoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	localAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, fmt.Errorf("unexpected local address %v", conn.LocalAddr())
+	}
+	return localAddr.IP, nil
+}
@@ -0,0 +1,150 @@
+package upnpSrv
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/srv/store"
+	"github.com/jypelle/mifasol/internal/version"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const (
+	deviceType                   = "urn:schemas-upnp-org:device:MediaServer:1"
+	contentDirectoryServiceType  = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	connectionManagerServiceType = "urn:schemas-upnp-org:service:ConnectionManager:1"
+)
+
+// UpnpServer exposes the library as an UPnP/DLNA media server (ContentDirectory and ConnectionManager services)
+// announced through SSDP. It listens to its own plain http port as most renderers don't support https.
+type UpnpServer struct {
+	store        *store.Store
+	limiter      *limiter.Limiter
+	serverConfig *config.ServerConfig
+	udn          string
+	httpServer   *http.Server
+	ssdp         *ssdpServer
+
+	subscriptions subscriptions
+
+	log *logrus.Entry
+}
+
+func NewUpnpServer(store *store.Store, limiter *limiter.Limiter, serverConfig *config.ServerConfig) *UpnpServer {
+	upnpServer := &UpnpServer{
+		store:        store,
+		limiter:      limiter,
+		serverConfig: serverConfig,
+		udn:          deviceUdn(serverConfig.ConfigDir),
+		subscriptions: subscriptions{
+			bySid: make(map[string]*subscription),
+		},
+		log: logrus.WithField("origin", "upnp"),
+	}
+
+	router := mux.NewRouter()
+
+	// Description
+	router.HandleFunc("/rootDesc.xml", upnpServer.rootDescription).Methods("GET")
+	router.HandleFunc("/ContentDirectory.xml", upnpServer.serviceDescription(contentDirectoryDescription)).Methods("GET")
+	router.HandleFunc("/ConnectionManager.xml", upnpServer.serviceDescription(connectionManagerDescription)).Methods("GET")
+
+	// Control
+	router.HandleFunc("/ctl/ContentDirectory", upnpServer.control(contentDirectoryServiceType, upnpServer.contentDirectoryActions())).Methods("POST")
+	router.HandleFunc("/ctl/ConnectionManager", upnpServer.control(connectionManagerServiceType, upnpServer.connectionManagerActions())).Methods("POST")
+
+	// Eventing
+	router.HandleFunc("/evt/ContentDirectory", upnpServer.subscribe(upnpServer.contentDirectoryEventProperties)).Methods("SUBSCRIBE")
+	router.HandleFunc("/evt/ConnectionManager", upnpServer.subscribe(upnpServer.connectionManagerEventProperties)).Methods("SUBSCRIBE")
+	router.HandleFunc("/evt/{service}", upnpServer.unsubscribe).Methods("UNSUBSCRIBE")
+
+	// Song contents
+	router.HandleFunc("/content/{fileName}", upnpServer.readSongContent).Methods("GET", "HEAD")
+
+	router.Use(func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					upnpServer.log.Warningln("Recovering UPnP Call...")
+					http.Error(w, "Internal error", http.StatusInternalServerError)
+				}
+			}()
+			handler.ServeHTTP(w, r)
+		})
+	})
+
+	upnpServer.httpServer = &http.Server{
+		Addr:        net.JoinHostPort(serverConfig.BindAddress, strconv.FormatInt(serverConfig.UpnpPort, 10)),
+		Handler:     router,
+		ReadTimeout: time.Duration(serverConfig.Timeout) * time.Second,
+	}
+
+	upnpServer.ssdp = newSsdpServer(upnpServer)
+
+	return upnpServer
+}
+
+// Start serves the UPnP http requests and starts the SSDP announcements
+func (s *UpnpServer) Start() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		err := s.httpServer.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			s.log.Errorf("UPnP server stopped: %v", err)
+		}
+	}()
+
+	return s.ssdp.start()
+}
+
+// Stop sends the SSDP byebye notifications and stops serving UPnP http requests
+func (s *UpnpServer) Stop() {
+	s.ssdp.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.httpServer.Shutdown(ctx)
+}
+
+// bindIp returns the IP address the server is restricted to, nil when listening on every interface
+func (s *UpnpServer) bindIp() net.IP {
+	ip := net.ParseIP(s.serverConfig.BindAddress)
+	if ip == nil || ip.IsUnspecified() {
+		return nil
+	}
+	return ip
+}
+
+// serverHeader returns the SERVER header value expected by UPnP clients
+func serverHeader() string {
+	return runtime.GOOS + "/" + runtime.Version() + " UPnP/1.0 Mifasol/" + version.AppVersion.String()
+}
+
+// deviceUdn returns a device unique name which doesn't change between two server launches
+func deviceUdn(configDir string) string {
+	hostname, _ := os.Hostname()
+	absConfigDir, err := filepath.Abs(configDir)
+	if err != nil {
+		absConfigDir = configDir
+	}
+	hash := md5.Sum([]byte(hostname + absConfigDir))
+
+	// Name based UUID (version 3)
+	hash[6] = (hash[6] & 0x0f) | 0x30
+	hash[8] = (hash[8] & 0x3f) | 0x80
+	return fmt.Sprintf("uuid:%x-%x-%x-%x-%x", hash[0:4], hash[4:6], hash[6:8], hash[8:10], hash[10:16])
+}
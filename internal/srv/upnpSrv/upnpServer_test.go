@@ -0,0 +1,190 @@
+package upnpSrv
+
+import (
+	"bufio"
+	"encoding/xml"
+	"github.com/jypelle/mifasol/internal/srv/config"
+	"github.com/jypelle/mifasol/internal/srv/limiter"
+	"github.com/jypelle/mifasol/internal/srv/store"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestUpnpServer starts the UPnP http server on a loopback httptest server, with an empty library
+func newTestUpnpServer(t *testing.T) (*UpnpServer, *httptest.Server) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen on loopback: %v", err)
+	}
+
+	serverConfig := &config.ServerConfig{
+		ConfigDir:            t.TempDir(),
+		ServerEditableConfig: config.NewServerEditableConfig(nil),
+	}
+	serverConfig.BindAddress = "127.0.0.1"
+	serverConfig.UpnpPort = int64(listener.Addr().(*net.TCPAddr).Port)
+
+	st := store.NewStore(serverConfig)
+	t.Cleanup(func() { st.Close() })
+
+	upnpServer := NewUpnpServer(st, limiter.NewLimiter(serverConfig), serverConfig)
+
+	httpServer := httptest.NewUnstartedServer(upnpServer.httpServer.Handler)
+	httpServer.Listener.Close()
+	httpServer.Listener = listener
+	httpServer.Start()
+	t.Cleanup(httpServer.Close)
+
+	return upnpServer, httpServer
+}
+
+// startTestSsdp answers the M-SEARCH requests received on a loopback udp port, without joining the multicast group
+func startTestSsdp(t *testing.T, upnpServer *UpnpServer) *net.UDPAddr {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Unable to listen on loopback: %v", err)
+	}
+	upnpServer.ssdp.conn = conn
+	upnpServer.ssdp.stopCh = make(chan struct{})
+	upnpServer.ssdp.group.Add(1)
+	go upnpServer.ssdp.serve()
+	t.Cleanup(upnpServer.ssdp.stop)
+
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func TestSearchAndBrowse(t *testing.T) {
+	upnpServer, _ := newTestUpnpServer(t)
+	ssdpAddr := startTestSsdp(t, upnpServer)
+
+	// Discover the media server
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Unable to listen on loopback: %v", err)
+	}
+	defer conn.Close()
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddress + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 1\r\n" +
+		"ST: " + deviceType + "\r\n" +
+		"\r\n"
+	_, err = conn.WriteToUDP([]byte(search), ssdpAddr)
+	if err != nil {
+		t.Fatalf("Unable to send M-SEARCH: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buffer := make([]byte, 2048)
+	n, _, err := conn.ReadFromUDP(buffer)
+	if err != nil {
+		t.Fatalf("No answer to M-SEARCH: %v", err)
+	}
+	searchResponse, err := http.ReadResponse(bufio.NewReader(strings.NewReader(string(buffer[:n]))), nil)
+	if err != nil {
+		t.Fatalf("Invalid answer to M-SEARCH: %v", err)
+	}
+	if st := searchResponse.Header.Get("ST"); st != deviceType {
+		t.Errorf("ST = %q, want %q", st, deviceType)
+	}
+	if usn := searchResponse.Header.Get("USN"); usn != upnpServer.udn+"::"+deviceType {
+		t.Errorf("USN = %q, want %q", usn, upnpServer.udn+"::"+deviceType)
+	}
+	location := searchResponse.Header.Get("LOCATION")
+	wantLocation := "http://127.0.0.1:" + strconv.FormatInt(upnpServer.serverConfig.UpnpPort, 10) + "/rootDesc.xml"
+	if location != wantLocation {
+		t.Fatalf("LOCATION = %q, want %q", location, wantLocation)
+	}
+
+	// Read the device description
+	descriptionResponse, err := http.Get(location)
+	if err != nil {
+		t.Fatalf("Unable to read device description: %v", err)
+	}
+	defer descriptionResponse.Body.Close()
+
+	var description struct {
+		Services []struct {
+			ServiceType string `xml:"serviceType"`
+			ControlUrl  string `xml:"controlURL"`
+		} `xml:"device>serviceList>service"`
+	}
+	err = xml.NewDecoder(descriptionResponse.Body).Decode(&description)
+	if err != nil {
+		t.Fatalf("Invalid device description: %v", err)
+	}
+	var controlUrl string
+	for _, service := range description.Services {
+		if service.ServiceType == contentDirectoryServiceType {
+			controlUrl = strings.TrimSuffix(location, "/rootDesc.xml") + service.ControlUrl
+		}
+	}
+	if controlUrl == "" {
+		t.Fatalf("No ContentDirectory service in device description")
+	}
+
+	// Browse the root container
+	browse := xml.Header +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>` +
+		`<u:Browse xmlns:u="` + contentDirectoryServiceType + `">` +
+		`<ObjectID>0</ObjectID>` +
+		`<BrowseFlag>BrowseDirectChildren</BrowseFlag>` +
+		`<Filter>*</Filter>` +
+		`<StartingIndex>0</StartingIndex>` +
+		`<RequestedCount>0</RequestedCount>` +
+		`<SortCriteria></SortCriteria>` +
+		`</u:Browse>` +
+		`</s:Body></s:Envelope>`
+	request, err := http.NewRequest(http.MethodPost, controlUrl, strings.NewReader(browse))
+	if err != nil {
+		t.Fatalf("Unable to create Browse request: %v", err)
+	}
+	request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	request.Header.Set("SOAPACTION", `"`+contentDirectoryServiceType+`#Browse"`)
+
+	browseResponse, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Unable to call Browse: %v", err)
+	}
+	defer browseResponse.Body.Close()
+	if browseResponse.StatusCode != http.StatusOK {
+		t.Fatalf("Browse status = %d, want %d", browseResponse.StatusCode, http.StatusOK)
+	}
+
+	var envelope struct {
+		Result         string `xml:"Body>BrowseResponse>Result"`
+		NumberReturned int    `xml:"Body>BrowseResponse>NumberReturned"`
+		TotalMatches   int    `xml:"Body>BrowseResponse>TotalMatches"`
+	}
+	err = xml.NewDecoder(browseResponse.Body).Decode(&envelope)
+	if err != nil {
+		t.Fatalf("Invalid Browse response: %v", err)
+	}
+
+	var result struct {
+		Containers []struct {
+			Id       string `xml:"id,attr"`
+			ParentId string `xml:"parentID,attr"`
+		} `xml:"container"`
+	}
+	err = xml.Unmarshal([]byte(envelope.Result), &result)
+	if err != nil {
+		t.Fatalf("Invalid DIDL-Lite result: %v", err)
+	}
+
+	wantIds := []string{artistsObjectId, albumsObjectId, playlistsObjectId, favoritesObjectId}
+	if envelope.NumberReturned != len(wantIds) || envelope.TotalMatches != len(wantIds) || len(result.Containers) != len(wantIds) {
+		t.Fatalf("Browse returned %d/%d containers (%d in result), want %d", envelope.NumberReturned, envelope.TotalMatches, len(result.Containers), len(wantIds))
+	}
+	for ind, container := range result.Containers {
+		if container.Id != wantIds[ind] || container.ParentId != rootObjectId {
+			t.Errorf("Container %d = %s (parent %s), want %s (parent %s)", ind, container.Id, container.ParentId, wantIds[ind], rootObjectId)
+		}
+	}
+}
@@ -6,17 +6,17 @@ self.addEventListener('install', function (e) {
         caches.open(cacheName).then(function (cache) {
             return cache.addAll(
                 [
-                    '/',
-                    '/static/css/fontawesome.css',
-                    '/static/css/normalize.css',
-                    '/static/css/solid.css',
-                    '/static/css/style.css',
-                    '/static/font/Inter-ExtraBold.woff2',
-                    '/static/font/Inter-Light.woff2',
-                    '/static/font/Inter-Medium.woff2',
-                    '/static/js/mifasol.js',
-                    '/static/js/wasm_exec.js',
-                    '/clients/mifasolcliwa.wasm'
+                    './',
+                    'static/css/fontawesome.css',
+                    'static/css/normalize.css',
+                    'static/css/solid.css',
+                    'static/css/style.css',
+                    'static/font/Inter-ExtraBold.woff2',
+                    'static/font/Inter-Light.woff2',
+                    'static/font/Inter-Medium.woff2',
+                    'static/js/mifasol.js',
+                    'static/js/wasm_exec.js',
+                    'clients/mifasolcliwa.wasm'
                 ]
             );
         })
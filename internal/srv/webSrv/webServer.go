@@ -40,6 +40,13 @@ func NewWebServer(store *store.Store, router *mux.Router, serverConfig *config.S
 		log:          logrus.WithField("origin", "web"),
 	}
 
+	// Pages link their resources relatively to the base path, set by the <base> tag
+	webServer.templateHelpers = template.FuncMap{
+		"basePath": func() string {
+			return serverConfig.BasePath
+		},
+	}
+
 	// Ressources
 	//	if serverConfig.EmbeddedFs {
 	webServer.StaticFs = static.Fs
@@ -54,7 +61,7 @@ func NewWebServer(store *store.Store, router *mux.Router, serverConfig *config.S
 
 	// Static files
 	//
-	staticFileHandler := http.StripPrefix("/static", http.FileServer(
+	staticFileHandler := http.StripPrefix(serverConfig.BasePath+"/static", http.FileServer(
 		http.FS(&tool.StaticFSWrapper{
 			ReadDirFS:    webServer.StaticFs,
 			FixedModTime: time.Now(),
@@ -70,7 +77,7 @@ func NewWebServer(store *store.Store, router *mux.Router, serverConfig *config.S
 
 	// Clients binary executable files
 	//
-	clientsFileHandler := http.StripPrefix("/clients", statigz.FileServer(
+	clientsFileHandler := http.StripPrefix(serverConfig.BasePath+"/clients", statigz.FileServer(
 		&tool.StaticFSWrapper{
 			ReadDirFS:    clients.Fs,
 			FixedModTime: time.Now(),
@@ -88,6 +95,11 @@ func NewWebServer(store *store.Store, router *mux.Router, serverConfig *config.S
 		webServer.HtmlWriterRender(w, "Mifasol", "main.html")
 	}).Methods("GET").Name("start")
 
+	// Share page, opened without account
+	webServer.router.HandleFunc("/share/{shareId}", func(w http.ResponseWriter, _ *http.Request) {
+		webServer.HtmlWriterRender(w, "Mifasol", "share.html")
+	}).Methods("GET").Name("share")
+
 	// Service worker
 	webServer.router.HandleFunc("/sw.js", func(w http.ResponseWriter, _ *http.Request) {
 		webServer.JsWriterRender(w, version.AppVersion.String(), "sw.js")
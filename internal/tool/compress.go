@@ -0,0 +1,160 @@
+package tool
+
+import (
+	"compress/gzip"
+	"github.com/felixge/httpsnoop"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Responses smaller than this are not worth compressing, but their size is only known when given by Content-Length
+const compressMinSize = 1024
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// CompressHandler compresses with gzip the JSON, XML and text responses of the clients accepting it.
+// Song contents, partial contents and event streams are sent untouched.
+func CompressHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		c := &compressWriter{w: w}
+		defer c.close()
+
+		h.ServeHTTP(httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					c.start(code)
+					next(code)
+				}
+			},
+			Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					c.start(http.StatusOK)
+					if c.gzipWriter != nil {
+						return c.gzipWriter.Write(b)
+					}
+					return next(b)
+				}
+			},
+			ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+				return func(src io.Reader) (int64, error) {
+					c.start(http.StatusOK)
+					if c.gzipWriter != nil {
+						return io.Copy(c.gzipWriter, src)
+					}
+					return next(src)
+				}
+			},
+			Flush: func(next httpsnoop.FlushFunc) httpsnoop.FlushFunc {
+				return func() {
+					if c.gzipWriter != nil {
+						c.gzipWriter.Flush()
+					}
+					next()
+				}
+			},
+		}), r)
+	})
+}
+
+// compressWriter decides, when the response starts, whether its body goes through a gzip writer
+type compressWriter struct {
+	w          http.ResponseWriter
+	started    bool
+	gzipWriter *gzip.Writer
+}
+
+func (c *compressWriter) start(code int) {
+	if c.started {
+		return
+	}
+	c.started = true
+
+	header := c.w.Header()
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusPartialContent || code == http.StatusNotModified ||
+		header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" || !compressibleContentType(header.Get("Content-Type")) {
+		return
+	}
+	if contentLength, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && contentLength < compressMinSize {
+		return
+	}
+
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", "gzip")
+	c.gzipWriter = gzipWriterPool.Get().(*gzip.Writer)
+	c.gzipWriter.Reset(c.w)
+}
+
+func (c *compressWriter) close() {
+	if c.gzipWriter != nil {
+		c.gzipWriter.Close()
+		gzipWriterPool.Put(c.gzipWriter)
+		c.gzipWriter = nil
+	}
+}
+
+// acceptsGzip tells if an Accept-Encoding header accepts gzip, given by name or by the * wildcard.
+// Brotli is not offered: the standard library has no brotli encoder.
+func acceptsGzip(acceptEncoding string) bool {
+	gzipQuality := -1.0
+	wildcardQuality := -1.0
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := cutString(strings.TrimSpace(coding), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "x-gzip" && name != "*" {
+			continue
+		}
+		quality := 1.0
+		_, qualityParam, found := cutString(strings.ReplaceAll(params, " ", ""), "q=")
+		if found {
+			qualityParam, _, _ = cutString(qualityParam, ";")
+			if q, err := strconv.ParseFloat(qualityParam, 64); err == nil {
+				quality = q
+			}
+		}
+		if name == "*" {
+			wildcardQuality = quality
+		} else if quality > gzipQuality {
+			gzipQuality = quality
+		}
+	}
+	// gzip;q=0 refuses it, even with a wildcard
+	if gzipQuality >= 0 {
+		return gzipQuality > 0
+	}
+	return wildcardQuality > 0
+}
+
+func compressibleContentType(contentType string) bool {
+	mediaType, _, _ := cutString(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	switch {
+	case mediaType == "application/json", mediaType == "application/xml":
+		return true
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	}
+	return false
+}
+
+// cutString slices s around the first instance of sep (strings.Cut is not available before Go 1.18)
+func cutString(s string, sep string) (before string, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
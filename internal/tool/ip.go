@@ -0,0 +1,96 @@
+package tool
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIp returns the address of the client of the request, without port
+func ClientIp(r *http.Request) string {
+	return HostIp(r.RemoteAddr)
+}
+
+// HostIp returns the host of a "host:port" address
+func HostIp(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+// ParseNetworks interprets a list of IP addresses and CIDR networks
+func ParseNetworks(addresses []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if !strings.Contains(address, "/") {
+			ip := net.ParseIP(address)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: address}
+			}
+			if ip.To4() != nil {
+				address += "/32"
+			} else {
+				address += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(address)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// containsIp tells if one of the networks contains the ip
+func containsIp(networks []*net.IPNet, ip string) bool {
+	parsedIp := net.ParseIP(ip)
+	if parsedIp == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(parsedIp) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyHeadersHandler trusts the X-Forwarded-For and X-Forwarded-Proto headers of the requests coming from the trusted proxies
+// or from the unix socket: the client address and scheme of the request are replaced by the forwarded ones.
+// The Host header is kept, the reverse proxy having to pass the original one.
+func ProxyHeadersHandler(trustedProxies []*net.IPNet, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Unix socket peers have no IP address: any local process able to connect to the socket is trusted,
+		// the access to the socket being left to the permissions of its directory
+		fromUnixSocket := net.ParseIP(ClientIp(r)) == nil
+		if !fromUnixSocket && !containsIp(trustedProxies, ClientIp(r)) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// The client is the last address not added by a trusted proxy
+		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			addresses := strings.Split(forwardedFor, ",")
+			for i := len(addresses) - 1; i >= 0; i-- {
+				address := strings.TrimSpace(addresses[i])
+				if net.ParseIP(address) == nil {
+					break
+				}
+				r.RemoteAddr = net.JoinHostPort(address, "0")
+				if !containsIp(trustedProxies, address) {
+					break
+				}
+			}
+		}
+
+		if forwardedProto := strings.ToLower(r.Header.Get("X-Forwarded-Proto")); forwardedProto == "http" || forwardedProto == "https" {
+			r.URL.Scheme = forwardedProto
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
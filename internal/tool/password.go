@@ -0,0 +1,76 @@
+package tool
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// Passwords are hashed with PBKDF2-HMAC-SHA256, stored as pbkdf2-sha256$<iterations>$<salt>$<hash>
+const (
+	passwordHashScheme     = "pbkdf2-sha256"
+	passwordHashIterations = 100000
+	passwordHashSaltSize   = 16
+)
+
+// HashPassword returns the salted hash of password
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, passwordHashSaltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	hash := pbkdf2Sha256([]byte(password), salt, passwordHashIterations)
+
+	return passwordHashScheme + "$" + strconv.Itoa(passwordHashIterations) + "$" +
+		base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(hash), nil
+}
+
+// CheckPasswordHash tells if password matches passwordHash, given by HashPassword
+func CheckPasswordHash(passwordHash string, password string) bool {
+	parts := strings.Split(passwordHash, "$")
+	if len(parts) != 4 || parts[0] != passwordHashScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(pbkdf2Sha256([]byte(password), salt, iterations), hash) == 1
+}
+
+// pbkdf2Sha256 derives a key of one sha256 block (RFC 8018)
+func pbkdf2Sha256(password []byte, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, password)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+
+	key := make([]byte, len(u))
+	copy(key, u)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+
+	return key
+}
@@ -0,0 +1,12 @@
+package tool
+
+import "strings"
+
+// NormalizeBasePath returns the url path prefix with a leading slash and without trailing slash, "" for the root
+func NormalizeBasePath(basePath string) string {
+	basePath = strings.Trim(strings.TrimSpace(basePath), "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
@@ -0,0 +1,73 @@
+package restApiV1
+
+import "encoding/json"
+
+// Audit
+//
+// Append-only trail of the creations, updates and deletions made through the REST API.
+// Entries are deleted after the audit retention period of the server configuration.
+
+type AuditEntryId string
+
+type AuditAction string
+
+const (
+	AuditActionCreate  AuditAction = "create"
+	AuditActionUpdate  AuditAction = "update"
+	AuditActionDelete  AuditAction = "delete"
+	AuditActionRestore AuditAction = "restore"
+)
+
+type AuditEntityType string
+
+const (
+	AuditEntityTypeAlbum            AuditEntityType = "album"
+	AuditEntityTypeArtist           AuditEntityType = "artist"
+	AuditEntityTypePlaylist         AuditEntityType = "playlist"
+	AuditEntityTypeSong             AuditEntityType = "song"
+	AuditEntityTypeUser             AuditEntityType = "user"
+	AuditEntityTypeFavoritePlaylist AuditEntityType = "favoritePlaylist"
+	AuditEntityTypeFavoriteSong     AuditEntityType = "favoriteSong"
+	AuditEntityTypeShare            AuditEntityType = "share"
+	AuditEntityTypeRadioStation     AuditEntityType = "radioStation"
+	AuditEntityTypeTrashItem        AuditEntityType = "trashItem"
+)
+
+var AuditEntityTypes = []AuditEntityType{
+	AuditEntityTypeAlbum,
+	AuditEntityTypeArtist,
+	AuditEntityTypePlaylist,
+	AuditEntityTypeSong,
+	AuditEntityTypeUser,
+	AuditEntityTypeFavoritePlaylist,
+	AuditEntityTypeFavoriteSong,
+	AuditEntityTypeShare,
+	AuditEntityTypeRadioStation,
+	AuditEntityTypeTrashItem,
+}
+
+// AuditMaxEntries is the maximum number of entries returned for a filter
+const AuditMaxEntries = 1000
+
+type AuditEntry struct {
+	Id AuditEntryId `json:"id"`
+	Ts int64        `json:"ts"`
+	// Acting user, with the name of the account at that time
+	UserId     UserId          `json:"userId"`
+	UserName   string          `json:"userName"`
+	ClientIp   string          `json:"clientIp"`
+	Action     AuditAction     `json:"action"`
+	EntityType AuditEntityType `json:"entityType"`
+	EntityId   string          `json:"entityId"`
+	// Name of the entity at that time, when it has one
+	EntityName string        `json:"entityName"`
+	Changes    []AuditChange `json:"changes"`
+}
+
+// AuditChange is a meta field modified by the action, with its JSON values.
+// Before is null for a creation, After is null for a deletion.
+type AuditChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
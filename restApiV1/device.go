@@ -0,0 +1,68 @@
+package restApiV1
+
+// Remote controlled device
+
+type DeviceId string
+
+type DeviceCommandType string
+
+const (
+	// Resume the playing song
+	PlayDeviceCommandType DeviceCommandType = "play"
+	// Pause the playing song
+	PauseDeviceCommandType DeviceCommandType = "pause"
+	// Play the next song of the play queue
+	NextDeviceCommandType DeviceCommandType = "next"
+	// Go to a position in the playing song
+	SeekDeviceCommandType DeviceCommandType = "seek"
+	// Change the volume
+	VolumeDeviceCommandType DeviceCommandType = "volume"
+	// Replace the play queue and play it
+	QueueDeviceCommandType DeviceCommandType = "queue"
+)
+
+// Device is a player connected to the server, waiting for remote commands on /devices/{id}/commands
+type Device struct {
+	Id           DeviceId `json:"id"`
+	UserId       UserId   `json:"userId"`
+	ConnectionTs int64    `json:"connectionTs"`
+	DeviceMeta
+	State DeviceState `json:"state"`
+}
+
+type DeviceMeta struct {
+	Name string `json:"name"`
+	// Supported commands
+	Capabilities []DeviceCommandType `json:"capabilities"`
+}
+
+// DeviceState is the "now playing" state of a device
+type DeviceState struct {
+	UpdateTs int64   `json:"updateTs"`
+	SongId   *SongId `json:"songId"`
+	PausedFg bool    `json:"pausedFg"`
+	// Position in the playing song, in seconds
+	Position float64 `json:"position"`
+	// Volume in percent
+	Volume int64 `json:"volume"`
+}
+
+type DeviceCommand struct {
+	Type DeviceCommandType `json:"type"`
+	// Position for seek command, in seconds
+	Position float64 `json:"position,omitempty"`
+	// Volume for volume command, in percent
+	Volume int64 `json:"volume,omitempty"`
+	// Play queue for queue command
+	SongIds      []SongId `json:"songIds,omitempty"`
+	CurrentIndex int64    `json:"currentIndex,omitempty"`
+}
+
+func (d *Device) HasCapability(commandType DeviceCommandType) bool {
+	for _, capability := range d.Capabilities {
+		if capability == commandType {
+			return true
+		}
+	}
+	return false
+}
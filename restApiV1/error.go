@@ -22,8 +22,17 @@ const (
 
 	ForbiddenErrorCode ErrorCode = "forbidden"
 
+	ExpiredShareErrorCode         ErrorCode = "expired_share"
+	InvalidSharePasswordErrorCode ErrorCode = "invalid_share_password"
+
+	UploadOffsetMismatchErrorCode ErrorCode = "upload_offset_mismatch"
+	UploadBusyErrorCode           ErrorCode = "upload_busy"
+	UploadIncompleteErrorCode     ErrorCode = "upload_incomplete"
+
 	ObsoleteClientErrorCode ErrorCode = "obsolete_client"
 
+	TooManyRequestsErrorCode ErrorCode = "too_many_requests"
+
 	// Client Error
 	UnknownErrorCode ErrorCode = "unknown_error"
 	ClientErrorCode  ErrorCode = "client_error"
@@ -57,6 +66,18 @@ func (e ErrorCode) StatusCode() int {
 		return http.StatusBadRequest
 	case ForbiddenErrorCode:
 		return http.StatusForbidden
+	case ExpiredShareErrorCode:
+		return http.StatusGone
+	case InvalidSharePasswordErrorCode:
+		return http.StatusUnauthorized
+	case UploadOffsetMismatchErrorCode:
+		return http.StatusConflict
+	case UploadBusyErrorCode:
+		return http.StatusLocked
+	case UploadIncompleteErrorCode:
+		return http.StatusConflict
+	case TooManyRequestsErrorCode:
+		return http.StatusTooManyRequests
 	}
 
 	return http.StatusInternalServerError
@@ -0,0 +1,40 @@
+package restApiV1
+
+// Change event
+
+type EventType string
+
+const (
+	SongCreatedEventType             EventType = "song.created"
+	SongUpdatedEventType             EventType = "song.updated"
+	SongDeletedEventType             EventType = "song.deleted"
+	AlbumCreatedEventType            EventType = "album.created"
+	AlbumUpdatedEventType            EventType = "album.updated"
+	AlbumDeletedEventType            EventType = "album.deleted"
+	ArtistCreatedEventType           EventType = "artist.created"
+	ArtistUpdatedEventType           EventType = "artist.updated"
+	ArtistDeletedEventType           EventType = "artist.deleted"
+	PlaylistCreatedEventType         EventType = "playlist.created"
+	PlaylistUpdatedEventType         EventType = "playlist.updated"
+	PlaylistDeletedEventType         EventType = "playlist.deleted"
+	UserCreatedEventType             EventType = "user.created"
+	UserUpdatedEventType             EventType = "user.updated"
+	UserDeletedEventType             EventType = "user.deleted"
+	FavoriteSongCreatedEventType     EventType = "favoriteSong.created"
+	FavoriteSongDeletedEventType     EventType = "favoriteSong.deleted"
+	FavoritePlaylistCreatedEventType EventType = "favoritePlaylist.created"
+	FavoritePlaylistDeletedEventType EventType = "favoritePlaylist.deleted"
+)
+
+// Event notifies a change in the library, sent by /events as a server-sent event
+type Event struct {
+	Type EventType `json:"type"`
+	// Id of the song, album, artist, playlist or user
+	Id string `json:"id"`
+	// Owner of the favorite song or playlist
+	UserId UserId `json:"userId,omitempty"`
+	// Update or delete timestamp
+	Ts int64 `json:"ts"`
+	// Revision of the change, reported by the sync reports from this revision onwards
+	Revision int64 `json:"revision"`
+}
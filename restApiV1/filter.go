@@ -5,10 +5,12 @@ type ArtistFilterOrderBy string
 const ArtistFilterOrderByName ArtistFilterOrderBy = "name"
 
 type ArtistFilter struct {
-	FromTs  *int64
-	Name    *string
-	SongId  *SongId
-	OrderBy *ArtistFilterOrderBy
+	FromTs       *int64
+	FromRevision *int64
+	ToRevision   *int64
+	Name         *string
+	SongId       *SongId
+	OrderBy      *ArtistFilterOrderBy
 }
 
 type AlbumFilterOrderBy string
@@ -16,9 +18,11 @@ type AlbumFilterOrderBy string
 const AlbumFilterOrderByName AlbumFilterOrderBy = "name"
 
 type AlbumFilter struct {
-	FromTs  *int64
-	Name    *string
-	OrderBy *AlbumFilterOrderBy
+	FromTs       *int64
+	FromRevision *int64
+	ToRevision   *int64
+	Name         *string
+	OrderBy      *AlbumFilterOrderBy
 }
 
 type PlaylistFilterOrderBy string
@@ -26,10 +30,13 @@ type PlaylistFilterOrderBy string
 const PlaylistFilterOrderByName PlaylistFilterOrderBy = "name"
 
 type PlaylistFilter struct {
-	FromTs         *int64
-	FavoriteUserId *UserId
-	FavoriteFromTs *int64
-	OrderBy        *PlaylistFilterOrderBy
+	FromTs               *int64
+	FromRevision         *int64
+	ToRevision           *int64
+	FavoriteUserId       *UserId
+	FavoriteFromTs       *int64
+	FavoriteFromRevision *int64
+	OrderBy              *PlaylistFilterOrderBy
 }
 
 type SongFilterOrderBy string
@@ -37,29 +44,51 @@ type SongFilterOrderBy string
 const SongFilterOrderByName SongFilterOrderBy = "name"
 
 type SongFilter struct {
-	FromTs   *int64
-	AlbumId  *AlbumId
-	ArtistId *ArtistId
-	Favorite *SongFilterFavorite
-	OrderBy  *SongFilterOrderBy
+	FromTs       *int64
+	FromRevision *int64
+	ToRevision   *int64
+	AlbumId      *AlbumId
+	ArtistId     *ArtistId
+	Favorite     *SongFilterFavorite
+	OrderBy      *SongFilterOrderBy
 }
 
 type SongFilterFavorite struct {
-	UserId UserId
-	FromTs int64
+	UserId       UserId
+	FromTs       int64
+	FromRevision *int64
 }
 
 type UserFilter struct {
-	FromTs  *int64
-	AdminFg *bool
+	FromTs       *int64
+	FromRevision *int64
+	ToRevision   *int64
+	AdminFg      *bool
 }
 
 type FavoritePlaylistFilter struct {
-	FromTs     *int64
-	UserId     *UserId
-	PlaylistId *PlaylistId
+	FromTs       *int64
+	FromRevision *int64
+	ToRevision   *int64
+	UserId       *UserId
+	PlaylistId   *PlaylistId
 }
 
 type FavoriteSongFilter struct {
-	FromTs *int64
+	FromTs       *int64
+	FromRevision *int64
+	ToRevision   *int64
+}
+
+type ShareFilter struct {
+	OwnerUserId *UserId
+}
+
+// AuditEntryFilter selects the audit entries, newest first
+type AuditEntryFilter struct {
+	UserId     *UserId
+	EntityType *AuditEntityType
+	EntityId   *string
+	FromTs     *int64
+	ToTs       *int64
 }
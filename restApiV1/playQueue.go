@@ -0,0 +1,30 @@
+package restApiV1
+
+// Play queue
+
+type RepeatMode string
+
+const (
+	RepeatModeOff RepeatMode = "off"
+	RepeatModeAll RepeatMode = "all"
+	RepeatModeOne RepeatMode = "one"
+)
+
+// PlayQueue is the "now playing" state of a user, shared between all the user devices
+type PlayQueue struct {
+	UserId   UserId `json:"userId"`
+	UpdateTs int64  `json:"updateTs"`
+	PlayQueueMeta
+}
+
+type PlayQueueMeta struct {
+	SongIds []SongId `json:"songIds"`
+	// Index of the current song in SongIds, -1 when no song is playing
+	CurrentIndex int64 `json:"currentIndex"`
+	// Position in the current song, in seconds
+	Position   float64    `json:"position"`
+	ShuffleFg  bool       `json:"shuffleFg"`
+	RepeatMode RepeatMode `json:"repeatMode"`
+	// Playlist loaded in the queue
+	SrcPlaylistId *PlaylistId `json:"srcPlaylistId"`
+}
@@ -28,3 +28,13 @@ func (p *PlaylistMeta) Copy() *PlaylistMeta {
 	copy(newPlaylistMeta.OwnerUserIds, p.OwnerUserIds)
 	return &newPlaylistMeta
 }
+
+// IsOwnedBy checks if the user is one of the playlist owners
+func (p *PlaylistMeta) IsOwnedBy(userId UserId) bool {
+	for _, ownerUserId := range p.OwnerUserIds {
+		if ownerUserId == userId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package restApiV1
+
+// Radio station
+
+const (
+	RadioStationMinBitrate     int64 = 32
+	RadioStationMaxBitrate     int64 = 320
+	RadioStationDefaultBitrate int64 = 128
+)
+
+type RadioStationFormat string
+
+const (
+	RadioStationFormatMp3 RadioStationFormat = "mp3"
+	RadioStationFormatOgg RadioStationFormat = "ogg"
+)
+
+func (f RadioStationFormat) MimeType() string {
+	switch f {
+	case RadioStationFormatOgg:
+		return SongMimeTypeOgg
+	}
+	return SongMimeTypeMp3
+}
+
+// SongFormat returns the song format which can be broadcast without transcoding
+func (f RadioStationFormat) SongFormat() SongFormat {
+	switch f {
+	case RadioStationFormatOgg:
+		return SongFormatOgg
+	}
+	return SongFormatMp3
+}
+
+// RadioStation broadcasts a playlist on /radio/{playlistId}
+type RadioStation struct {
+	PlaylistId PlaylistId `json:"playlistId"`
+	CreationTs int64      `json:"creationTs"`
+	UpdateTs   int64      `json:"updateTs"`
+	RadioStationMeta
+
+	// Broadcast state
+	CurrentSongId *SongId `json:"currentSongId"`
+	ListenerCount int64   `json:"listenerCount"`
+}
+
+type RadioStationMeta struct {
+	Format RadioStationFormat `json:"format"`
+	// Bitrate in kbit/s
+	Bitrate   int64 `json:"bitrate"`
+	ShuffleFg bool  `json:"shuffleFg"`
+}
+
+type RadioStationNew struct {
+	PlaylistId PlaylistId `json:"playlistId"`
+	RadioStationMeta
+}
@@ -0,0 +1,71 @@
+package restApiV1
+
+// Share
+
+type ShareId string
+
+type ShareItemType string
+
+const (
+	ShareItemTypePlaylist ShareItemType = "playlist"
+	ShareItemTypeAlbum    ShareItemType = "album"
+	ShareItemTypeSong     ShareItemType = "song"
+)
+
+// Share gives access to a playlist, an album or a song, without account, on /share/{shareId}
+type Share struct {
+	Id          ShareId `json:"id"`
+	CreationTs  int64   `json:"creationTs"`
+	OwnerUserId UserId  `json:"ownerUserId"`
+	PasswordFg  bool    `json:"passwordFg"`
+	ShareMeta
+}
+
+type ShareMeta struct {
+	ItemType ShareItemType `json:"itemType"`
+	ItemId   string        `json:"itemId"`
+	// Nil for a share which never expires
+	ExpirationTs *int64 `json:"expirationTs"`
+	DownloadFg   bool   `json:"downloadFg"`
+}
+
+type ShareNew struct {
+	ShareMeta
+	// Void for a share without password
+	Password string `json:"password"`
+}
+
+// IsExpired checks if the share has expired at ts
+func (s *Share) IsExpired(ts int64) bool {
+	return s.ExpirationTs != nil && *s.ExpirationTs <= ts
+}
+
+// ShareAccessNew opens a share protected by a password, sent in the body of POST /share/{shareId}/access
+type ShareAccessNew struct {
+	Password string `json:"password"`
+}
+
+// ShareAccess gives access for a short time to a share protected by a password
+type ShareAccess struct {
+	AccessToken  string `json:"accessToken"`
+	ExpirationTs int64  `json:"expirationTs"`
+}
+
+// ShareContent is the shared item as seen by an anonymous listener
+type ShareContent struct {
+	Id           ShareId       `json:"id"`
+	ItemType     ShareItemType `json:"itemType"`
+	Name         string        `json:"name"`
+	ExpirationTs *int64        `json:"expirationTs"`
+	DownloadFg   bool          `json:"downloadFg"`
+	Songs        []ShareSong   `json:"songs"`
+}
+
+type ShareSong struct {
+	Id          SongId     `json:"id"`
+	Name        string     `json:"name"`
+	Format      SongFormat `json:"format"`
+	TrackNumber *int64     `json:"trackNumber"`
+	AlbumName   string     `json:"albumName"`
+	ArtistNames []string   `json:"artistNames"`
+}
@@ -111,3 +111,63 @@ type SongNew struct {
 	SongMeta
 	Content []byte `json:"content"`
 }
+
+// SongsPatch describes the changes to apply to a list of songs.
+// Nil fields are left unchanged.
+type SongsPatch struct {
+	SongIds []SongId `json:"songIds"`
+	SongMetaPatch
+}
+
+type SongMetaPatch struct {
+	// Unknown album id to unlink songs from their album
+	AlbumId *AlbumId `json:"albumId,omitempty"`
+	// 0 to remove publication year
+	PublicationYear *int64 `json:"publicationYear,omitempty"`
+	ExplicitFg      *bool  `json:"explicitFg,omitempty"`
+	// Replace song artists
+	ArtistIds       []ArtistId `json:"artistIds,omitempty"`
+	AddArtistIds    []ArtistId `json:"addArtistIds,omitempty"`
+	RemoveArtistIds []ArtistId `json:"removeArtistIds,omitempty"`
+}
+
+func (p *SongMetaPatch) Apply(songMeta *SongMeta) {
+	if p.AlbumId != nil {
+		songMeta.AlbumId = *p.AlbumId
+	}
+	if p.PublicationYear != nil {
+		if *p.PublicationYear == 0 {
+			songMeta.PublicationYear = nil
+		} else {
+			publicationYear := *p.PublicationYear
+			songMeta.PublicationYear = &publicationYear
+		}
+	}
+	if p.ExplicitFg != nil {
+		songMeta.ExplicitFg = *p.ExplicitFg
+	}
+	if p.ArtistIds != nil {
+		songMeta.ArtistIds = append([]ArtistId(nil), p.ArtistIds...)
+	}
+	songMeta.ArtistIds = append(songMeta.ArtistIds, p.AddArtistIds...)
+	if len(p.RemoveArtistIds) > 0 {
+		var artistIds []ArtistId
+		for _, artistId := range songMeta.ArtistIds {
+			removed := false
+			for _, removedArtistId := range p.RemoveArtistIds {
+				if artistId == removedArtistId {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				artistIds = append(artistIds, artistId)
+			}
+		}
+		songMeta.ArtistIds = artistIds
+	}
+}
+
+func (p *SongMetaPatch) IsEmpty() bool {
+	return p.AlbumId == nil && p.PublicationYear == nil && p.ExplicitFg == nil && p.ArtistIds == nil && len(p.AddArtistIds) == 0 && len(p.RemoveArtistIds) == 0
+}
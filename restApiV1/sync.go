@@ -15,7 +15,26 @@ type SyncReport struct {
 	DeletedFavoritePlaylistIds []FavoritePlaylistId `json:"deletedFavoritePlaylistIds"`
 	FavoriteSongs              []FavoriteSong       `json:"favoriteSongs"`
 	DeletedFavoriteSongIds     []FavoriteSongId     `json:"deletedFavoriteSongIds"`
-	SyncTs                     int64                `json:"syncTs"`
+	// Revision to send back on the next synchronization
+	SyncRevision int64 `json:"syncRevision"`
+	// Deprecated: same as SyncRevision, for the clients synchronized before the revisions
+	SyncTs int64 `json:"syncTs"`
+}
+
+// Default and maximum number of changes of a sync report page
+const SyncReportPageDefaultSize = 1000
+const SyncReportPageMaxSize = 10000
+
+// SyncReportPage is a part of the changes of a sync report, applied like a sync report: its sync revision is the
+// revision of its last change, from which an interrupted synchronization can start again
+type SyncReportPage struct {
+	SyncReport
+	// Token to read the next page, empty on the last page
+	ContinuationToken string `json:"continuationToken"`
+	// Number of changes sent up to this page
+	ChangeCount int64 `json:"changeCount"`
+	// Number of changes to send on all the pages
+	TotalChangeCount int64 `json:"totalChangeCount"`
 }
 
 type FileSyncSong struct {
@@ -29,5 +48,8 @@ type FileSyncReport struct {
 	DeletedSongIds     []SongId       `json:"deletedSongIds"`
 	Playlists          []Playlist     `json:"playlists"`
 	DeletedPlaylistIds []PlaylistId   `json:"deletedPlaylistIds"`
-	SyncTs             int64          `json:"syncTs"`
+	// Revision to send back on the next synchronization
+	SyncRevision int64 `json:"syncRevision"`
+	// Deprecated: same as SyncRevision, for the clients synchronized before the revisions
+	SyncTs int64 `json:"syncTs"`
 }
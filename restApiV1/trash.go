@@ -0,0 +1,22 @@
+package restApiV1
+
+// Trash
+
+type TrashItemId string
+
+type TrashItemType string
+
+const (
+	TrashItemTypeSong     TrashItemType = "song"
+	TrashItemTypeAlbum    TrashItemType = "album"
+	TrashItemTypeArtist   TrashItemType = "artist"
+	TrashItemTypePlaylist TrashItemType = "playlist"
+)
+
+type TrashItem struct {
+	Id       TrashItemId   `json:"id"`
+	DeleteTs int64         `json:"deleteTs"`
+	ItemType TrashItemType `json:"itemType"`
+	ItemId   string        `json:"itemId"`
+	Name     string        `json:"name"`
+}
@@ -0,0 +1,48 @@
+package restApiV1
+
+// Upload
+//
+// Resumable upload of a song file, in the manner of tus:
+//
+// 1. POST /uploads with an UploadNew opens an upload session
+//
+// 2. PUT /uploads/{id}/content?offset={offset} appends a chunk of the file, offset being the number of bytes already
+// received by the server. The bytes received before a connection loss are kept: GET /uploads/{id} gives the offset
+// to resume from. A chunk sent at a wrong offset is refused with an upload_offset_mismatch error.
+//
+// 3. POST /uploads/{id}/song creates the song once the whole file is received, like POST /songContents does.
+// Calling it again returns the same song.
+//
+// DELETE /uploads/{id} aborts the upload. Uploads left idle are deleted after UploadRetentionHours.
+
+type UploadId string
+
+// UploadChunkSize is the size of the chunks sent by the clients
+const UploadChunkSize = 4 * 1024 * 1024
+
+// UploadRetentionHours is the time an idle upload is kept on the server
+const UploadRetentionHours = 24
+
+type Upload struct {
+	Id         UploadId `json:"id"`
+	CreationTs int64    `json:"creationTs"`
+	UpdateTs   int64    `json:"updateTs"`
+	UserId     UserId   `json:"userId"`
+	// Number of bytes received
+	Offset int64 `json:"offset"`
+	// Created song, once the upload is finalized
+	SongId *SongId `json:"songId"`
+	UploadMeta
+}
+
+type UploadMeta struct {
+	// Size of the whole song file
+	Size int64 `json:"size"`
+	// Album to link the song to when the album name of the tags is ambiguous
+	LastAlbumId AlbumId `json:"lastAlbumId"`
+}
+
+// IsComplete checks if the whole song file has been received
+func (u *Upload) IsComplete() bool {
+	return u.Offset == u.Size
+}
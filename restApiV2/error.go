@@ -0,0 +1,94 @@
+package restApiV2
+
+import "net/http"
+
+type ErrorCode string
+
+const (
+	NotFoundErrorCode         ErrorCode = "not_found"
+	InternalErrorCode         ErrorCode = "internal_error"
+	MethodNotAllowedErrorCode ErrorCode = "method_not_allowed"
+	InvalidTokenErrorCode     ErrorCode = "invalid_token"
+	ForbiddenErrorCode        ErrorCode = "forbidden"
+	TooManyRequestsErrorCode  ErrorCode = "too_many_requests"
+
+	// The body is not a JSON document
+	InvalidRequestErrorCode ErrorCode = "invalid_request"
+	// Some query parameters or body fields are invalid, listed in the error fields
+	ValidationErrorCode ErrorCode = "validation_failed"
+	// The If-Match header does not match the current entity tag
+	PreconditionFailedErrorCode ErrorCode = "precondition_failed"
+
+	DeleteArtistWithSongsErrorCode ErrorCode = "delete_artist_with_songs"
+	DeleteAlbumWithSongsErrorCode  ErrorCode = "delete_album_with_songs"
+)
+
+func (e ErrorCode) StatusCode() int {
+	switch e {
+	case NotFoundErrorCode:
+		return http.StatusNotFound
+	case InternalErrorCode:
+		return http.StatusInternalServerError
+	case MethodNotAllowedErrorCode:
+		return http.StatusMethodNotAllowed
+	case InvalidTokenErrorCode:
+		return http.StatusUnauthorized
+	case ForbiddenErrorCode:
+		return http.StatusForbidden
+	case TooManyRequestsErrorCode:
+		return http.StatusTooManyRequests
+	case InvalidRequestErrorCode:
+		return http.StatusBadRequest
+	case ValidationErrorCode:
+		return http.StatusBadRequest
+	case PreconditionFailedErrorCode:
+		return http.StatusPreconditionFailed
+	case DeleteArtistWithSongsErrorCode:
+		return http.StatusConflict
+	case DeleteAlbumWithSongsErrorCode:
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
+
+func (e ErrorCode) String() string {
+	return string(e)
+}
+
+// FieldLocation tells where an invalid field was sent
+type FieldLocation string
+
+const (
+	FieldLocationQuery FieldLocation = "query"
+	FieldLocationBody  FieldLocation = "body"
+)
+
+// FieldError describes why a query parameter or a body field is invalid
+type FieldError struct {
+	Field    string        `json:"field"`
+	Location FieldLocation `json:"location"`
+	Message  string        `json:"message"`
+}
+
+type ApiError struct {
+	ErrorCode        ErrorCode    `json:"error"`
+	ErrorDescription string       `json:"error_description,omitempty"`
+	Fields           []FieldError `json:"fields,omitempty"`
+}
+
+func (a *ApiError) Code() ErrorCode {
+	return a.ErrorCode
+}
+
+func (a *ApiError) Description() string {
+	return a.ErrorDescription
+}
+
+func (a *ApiError) Error() string {
+	if a.ErrorDescription != "" {
+		return string(a.ErrorCode) + ":" + a.ErrorDescription
+	} else {
+		return string(a.ErrorCode)
+	}
+}
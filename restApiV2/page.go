@@ -0,0 +1,51 @@
+package restApiV2
+
+import "github.com/jypelle/mifasol/restApiV1"
+
+// Default and maximum number of items returned in a page
+const (
+	DefaultPageLimit int64 = 100
+	MaxPageLimit     int64 = 1000
+)
+
+// Page locates the items of a list response among the Total items matching the filter
+type Page struct {
+	Offset int64 `json:"offset"`
+	Limit  int64 `json:"limit"`
+	Total  int64 `json:"total"`
+}
+
+type AlbumPage struct {
+	Items []restApiV1.Album `json:"items"`
+	Page
+}
+
+type ArtistPage struct {
+	Items []restApiV1.Artist `json:"items"`
+	Page
+}
+
+type PlaylistPage struct {
+	Items []restApiV1.Playlist `json:"items"`
+	Page
+}
+
+type SongPage struct {
+	Items []restApiV1.Song `json:"items"`
+	Page
+}
+
+type UserPage struct {
+	Items []User `json:"items"`
+	Page
+}
+
+type FavoritePlaylistPage struct {
+	Items []restApiV1.FavoritePlaylist `json:"items"`
+	Page
+}
+
+type FavoriteSongPage struct {
+	Items []restApiV1.FavoriteSong `json:"items"`
+	Page
+}
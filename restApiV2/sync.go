@@ -0,0 +1,39 @@
+package restApiV2
+
+import "github.com/jypelle/mifasol/restApiV1"
+
+// SyncReport is the api v1 sync report whose users come without their password
+type SyncReport struct {
+	restApiV1.SyncReport
+	// Hides the users of the embedded report
+	Users []User `json:"users"`
+}
+
+func NewSyncReport(syncReport *restApiV1.SyncReport) *SyncReport {
+	newSyncReport := &SyncReport{SyncReport: *syncReport, Users: []User{}}
+	newSyncReport.SyncReport.Users = nil
+	for ind := range syncReport.Users {
+		newSyncReport.Users = append(newSyncReport.Users, NewUser(&syncReport.Users[ind]))
+	}
+	return newSyncReport
+}
+
+// SyncReportPage is the api v1 sync report page whose users come without their password
+type SyncReportPage struct {
+	SyncReport
+	// Token to read the next page, empty on the last page
+	ContinuationToken string `json:"continuationToken"`
+	// Number of changes sent up to this page
+	ChangeCount int64 `json:"changeCount"`
+	// Number of changes to send on all the pages
+	TotalChangeCount int64 `json:"totalChangeCount"`
+}
+
+func NewSyncReportPage(syncReportPage *restApiV1.SyncReportPage) *SyncReportPage {
+	return &SyncReportPage{
+		SyncReport:        *NewSyncReport(&syncReportPage.SyncReport),
+		ContinuationToken: syncReportPage.ContinuationToken,
+		ChangeCount:       syncReportPage.ChangeCount,
+		TotalChangeCount:  syncReportPage.TotalChangeCount,
+	}
+}
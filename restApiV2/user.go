@@ -0,0 +1,20 @@
+package restApiV2
+
+import "github.com/jypelle/mifasol/restApiV1"
+
+// User is the api v1 user without its password
+type User struct {
+	Id         restApiV1.UserId `json:"id"`
+	CreationTs int64            `json:"creationTs"`
+	UpdateTs   int64            `json:"updateTs"`
+	restApiV1.UserMeta
+}
+
+func NewUser(user *restApiV1.User) User {
+	return User{
+		Id:         user.Id,
+		CreationTs: user.CreationTs,
+		UpdateTs:   user.UpdateTs,
+		UserMeta:   user.UserMeta,
+	}
+}
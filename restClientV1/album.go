@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/jypelle/mifasol/restApiV1"
+	"io"
 )
 
 func (c *RestClient) CreateAlbum(albumMeta *restApiV1.AlbumMeta) (*restApiV1.Album, ClientError) {
@@ -42,6 +43,17 @@ func (c *RestClient) ReadAlbums(albumFilter *restApiV1.AlbumFilter) ([]restApiV1
 	return albumList, nil
 }
 
+// ReadAlbumArchive returns the zip archive of the album songs
+func (c *RestClient) ReadAlbumArchive(albumId restApiV1.AlbumId) (io.ReadCloser, ClientError) {
+
+	response, cliErr := c.doGetRequest("/albums/" + string(albumId) + "/archive")
+	if cliErr != nil {
+		return nil, cliErr
+	}
+
+	return response.Body, nil
+}
+
 func (c *RestClient) UpdateAlbum(albumId restApiV1.AlbumId, albumMeta *restApiV1.AlbumMeta) (*restApiV1.Album, ClientError) {
 	var album *restApiV1.Album
 
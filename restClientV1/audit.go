@@ -0,0 +1,26 @@
+package restClientV1
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jypelle/mifasol/restApiV1"
+)
+
+// ReadAuditEntries returns the audit trail entries selected by the filter, newest first (admin only)
+func (c *RestClient) ReadAuditEntries(auditEntryFilter *restApiV1.AuditEntryFilter) ([]restApiV1.AuditEntry, ClientError) {
+	var auditEntryList []restApiV1.AuditEntry
+
+	encodedAuditEntryFilter, _ := json.Marshal(auditEntryFilter)
+
+	response, cliErr := c.doGetRequestWithBody("/auditEntries", JsonContentType, bytes.NewBuffer(encodedAuditEntryFilter))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&auditEntryList); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return auditEntryList, nil
+}
@@ -5,6 +5,7 @@ type RestConfig interface {
 	SetCert(cert []byte) error
 	GetServerHostname() string
 	GetServerPort() int64
+	GetServerBasePath() string
 	GetServerSsl() bool
 	GetServerSelfSigned() bool
 	GetTimeout() int64
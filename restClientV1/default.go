@@ -2,6 +2,7 @@ package restClientV1
 
 const DefaultServerHostname = "localhost"
 const DefaultServerPort = 6620
+const DefaultServerBasePath = ""
 const DefaultServerSsl = true
 const DefaultServerSelfSigned = true
 const DefaultUsername = "mifasol"
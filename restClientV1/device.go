@@ -0,0 +1,98 @@
+package restClientV1
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jypelle/mifasol/restApiV1"
+	"net/url"
+	"strings"
+)
+
+// DeviceCommandStream reads the remote commands sent to a device
+type DeviceCommandStream struct {
+	serverSentEventStream
+}
+
+func (c *RestClient) ReadDevices() ([]restApiV1.Device, ClientError) {
+	var deviceList []restApiV1.Device
+
+	response, cliErr := c.doGetRequest("/devices")
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&deviceList); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return deviceList, nil
+}
+
+// OpenDeviceCommandStream announces a device to the server and connects to its remote commands stream
+func (c *RestClient) OpenDeviceCommandStream(deviceId restApiV1.DeviceId, deviceMeta *restApiV1.DeviceMeta) (*DeviceCommandStream, ClientError) {
+	var capabilities []string
+	for _, capability := range deviceMeta.Capabilities {
+		capabilities = append(capabilities, string(capability))
+	}
+
+	query := url.Values{}
+	query.Set("name", deviceMeta.Name)
+	query.Set("capabilities", strings.Join(capabilities, ","))
+
+	stream, cliErr := c.openServerSentEventStream("/devices/" + url.PathEscape(string(deviceId)) + "/commands?" + query.Encode())
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	return &DeviceCommandStream{*stream}, nil
+}
+
+// Next waits for the next remote command, returning an error when the stream is closed
+func (s *DeviceCommandStream) Next() (*restApiV1.DeviceCommand, ClientError) {
+	data, cliErr := s.nextData()
+	if cliErr != nil {
+		return nil, cliErr
+	}
+
+	var command restApiV1.DeviceCommand
+	if err := json.Unmarshal([]byte(data), &command); err != nil {
+		return nil, NewClientError(err)
+	}
+	return &command, nil
+}
+
+func (c *RestClient) CreateDeviceCommand(deviceId restApiV1.DeviceId, command *restApiV1.DeviceCommand) (*restApiV1.Device, ClientError) {
+	var device *restApiV1.Device
+
+	encodedCommand, _ := json.Marshal(command)
+
+	response, cliErr := c.doPostRequest("/devices/"+url.PathEscape(string(deviceId))+"/commands", JsonContentType, bytes.NewBuffer(encodedCommand))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&device); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return device, nil
+}
+
+func (c *RestClient) UpdateDeviceState(deviceId restApiV1.DeviceId, deviceState *restApiV1.DeviceState) (*restApiV1.Device, ClientError) {
+	var device *restApiV1.Device
+
+	encodedDeviceState, _ := json.Marshal(deviceState)
+
+	response, cliErr := c.doPutRequest("/devices/"+url.PathEscape(string(deviceId))+"/state", JsonContentType, bytes.NewBuffer(encodedDeviceState))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&device); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return device, nil
+}
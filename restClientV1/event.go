@@ -0,0 +1,110 @@
+package restClientV1
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/jypelle/mifasol/internal/version"
+	"github.com/jypelle/mifasol/restApiV1"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// EventStream reads the library change events sent by the server
+type EventStream struct {
+	serverSentEventStream
+}
+
+// OpenEventStream connects to the server-sent events stream
+func (c *RestClient) OpenEventStream() (*EventStream, ClientError) {
+	stream, cliErr := c.openServerSentEventStream("/events")
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	return &EventStream{*stream}, nil
+}
+
+// Next waits for the next event, returning an error when the stream is closed
+func (e *EventStream) Next() (*restApiV1.Event, ClientError) {
+	data, cliErr := e.nextData()
+	if cliErr != nil {
+		return nil, cliErr
+	}
+
+	var event restApiV1.Event
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return nil, NewClientError(err)
+	}
+	return &event, nil
+}
+
+type serverSentEventStream struct {
+	response *http.Response
+	scanner  *bufio.Scanner
+}
+
+func (c *RestClient) openServerSentEventStream(path string) (*serverSentEventStream, ClientError) {
+
+	_, cliErr := c.GetToken()
+	if cliErr != nil {
+		return nil, cliErr
+	}
+
+	req, err := http.NewRequest("GET", c.getServerApiUrl()+path, nil)
+	if err != nil {
+		return nil, NewClientError(err)
+	}
+	req.Header.Add("Authorization", "Bearer "+c.token.AccessToken)
+	req.Header.Add("x-mifasol-client-version", version.AppVersion.String())
+	req.Header.Add("Accept", "text/event-stream")
+
+	// The stream never ends: don't apply the client timeout
+	streamClient := &http.Client{Transport: c.httpClient.Transport}
+
+	response, err := streamClient.Do(req)
+	if err != nil {
+		return nil, NewClientError(err)
+	}
+
+	cliErr = checkStatusCode(response)
+	if cliErr != nil {
+		response.Body.Close()
+		// Is the token expired ?
+		if cliErr.Code() == restApiV1.InvalidTokenErrorCode {
+			c.token = nil
+			return c.openServerSentEventStream(path)
+		}
+		return nil, cliErr
+	}
+
+	return &serverSentEventStream{response: response, scanner: bufio.NewScanner(response.Body)}, nil
+}
+
+// nextData waits for the data of the next event
+func (e *serverSentEventStream) nextData() (string, ClientError) {
+	var data strings.Builder
+
+	for e.scanner.Scan() {
+		line := e.scanner.Text()
+		switch {
+		case line == "":
+			// End of the event
+			if data.Len() == 0 {
+				continue
+			}
+			return data.String(), nil
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// Comments, event types and retry delays are ignored
+	}
+
+	if err := e.scanner.Err(); err != nil {
+		return "", NewClientError(err)
+	}
+	return "", NewClientError(io.EOF)
+}
+
+func (e *serverSentEventStream) Close() {
+	e.response.Body.Close()
+}
@@ -0,0 +1,41 @@
+package restClientV1
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jypelle/mifasol/restApiV1"
+)
+
+func (c *RestClient) ReadPlayQueue() (*restApiV1.PlayQueue, ClientError) {
+	var playQueue *restApiV1.PlayQueue
+
+	response, cliErr := c.doGetRequest("/playQueue")
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&playQueue); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return playQueue, nil
+}
+
+func (c *RestClient) UpdatePlayQueue(playQueueMeta *restApiV1.PlayQueueMeta) (*restApiV1.PlayQueue, ClientError) {
+	var playQueue *restApiV1.PlayQueue
+
+	encodedPlayQueueMeta, _ := json.Marshal(playQueueMeta)
+
+	response, cliErr := c.doPutRequest("/playQueue", JsonContentType, bytes.NewBuffer(encodedPlayQueueMeta))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&playQueue); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return playQueue, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/jypelle/mifasol/restApiV1"
+	"io"
 )
 
 func (c *RestClient) CreatePlaylist(playListMeta *restApiV1.PlaylistMeta) (*restApiV1.Playlist, ClientError) {
@@ -43,6 +44,17 @@ func (c *RestClient) ReadPlaylists(playlistFilter *restApiV1.PlaylistFilter) ([]
 	return playlistList, nil
 }
 
+// ReadPlaylistArchive returns the zip archive of the playlist songs
+func (c *RestClient) ReadPlaylistArchive(playlistId restApiV1.PlaylistId) (io.ReadCloser, ClientError) {
+
+	response, cliErr := c.doGetRequest("/playlists/" + string(playlistId) + "/archive")
+	if cliErr != nil {
+		return nil, cliErr
+	}
+
+	return response.Body, nil
+}
+
 func (c *RestClient) UpdatePlaylist(playlistId restApiV1.PlaylistId, playlistMeta *restApiV1.PlaylistMeta) (*restApiV1.Playlist, ClientError) {
 	var playlist *restApiV1.Playlist
 
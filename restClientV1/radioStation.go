@@ -0,0 +1,57 @@
+package restClientV1
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jypelle/mifasol/restApiV1"
+)
+
+func (c *RestClient) ReadRadioStations() ([]restApiV1.RadioStation, ClientError) {
+	var radioStationList []restApiV1.RadioStation
+
+	response, cliErr := c.doGetRequest("/radioStations")
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&radioStationList); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return radioStationList, nil
+}
+
+func (c *RestClient) CreateRadioStation(radioStationNew *restApiV1.RadioStationNew) (*restApiV1.RadioStation, ClientError) {
+	var radioStation *restApiV1.RadioStation
+
+	encodedRadioStationNew, _ := json.Marshal(radioStationNew)
+
+	response, cliErr := c.doPostRequest("/radioStations", JsonContentType, bytes.NewBuffer(encodedRadioStationNew))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&radioStation); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return radioStation, nil
+}
+
+func (c *RestClient) DeleteRadioStation(playlistId restApiV1.PlaylistId) (*restApiV1.RadioStation, ClientError) {
+	var radioStation *restApiV1.RadioStation
+
+	response, cliErr := c.doDeleteRequest("/radioStations/" + string(playlistId))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&radioStation); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return radioStation, nil
+}
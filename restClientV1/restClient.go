@@ -159,9 +159,9 @@ func NewRestClient(clientConfig RestConfig, webassemblyEnabled bool) (*RestClien
 
 func getServerUrl(restConfig RestConfig) string {
 	if restConfig.GetServerSsl() {
-		return "https://" + restConfig.GetServerHostname() + ":" + strconv.FormatInt(restConfig.GetServerPort(), 10)
+		return "https://" + restConfig.GetServerHostname() + ":" + strconv.FormatInt(restConfig.GetServerPort(), 10) + restConfig.GetServerBasePath()
 	} else {
-		return "http://" + restConfig.GetServerHostname() + ":" + strconv.FormatInt(restConfig.GetServerPort(), 10)
+		return "http://" + restConfig.GetServerHostname() + ":" + strconv.FormatInt(restConfig.GetServerPort(), 10) + restConfig.GetServerBasePath()
 	}
 }
 
@@ -243,6 +243,10 @@ func (c *RestClient) doPutRequest(relativeUrl string, contentType string, body i
 	return c.doRequest("PUT", relativeUrl, contentType, body)
 }
 
+func (c *RestClient) doPatchRequest(relativeUrl string, contentType string, body io.Reader) (*http.Response, ClientError) {
+	return c.doRequest("PATCH", relativeUrl, contentType, body)
+}
+
 func checkStatusCode(response *http.Response) ClientError {
 
 	if response.StatusCode >= 400 {
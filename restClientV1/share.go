@@ -0,0 +1,57 @@
+package restClientV1
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jypelle/mifasol/restApiV1"
+)
+
+func (c *RestClient) ReadShares() ([]restApiV1.Share, ClientError) {
+	var shareList []restApiV1.Share
+
+	response, cliErr := c.doGetRequest("/shares")
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&shareList); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return shareList, nil
+}
+
+func (c *RestClient) CreateShare(shareNew *restApiV1.ShareNew) (*restApiV1.Share, ClientError) {
+	var share *restApiV1.Share
+
+	encodedShareNew, _ := json.Marshal(shareNew)
+
+	response, cliErr := c.doPostRequest("/shares", JsonContentType, bytes.NewBuffer(encodedShareNew))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&share); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return share, nil
+}
+
+func (c *RestClient) DeleteShare(shareId restApiV1.ShareId) (*restApiV1.Share, ClientError) {
+	var share *restApiV1.Share
+
+	response, cliErr := c.doDeleteRequest("/shares/" + string(shareId))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&share); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return share, nil
+}
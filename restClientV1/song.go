@@ -51,26 +51,22 @@ func (c *RestClient) ReadSongContent(songId restApiV1.SongId) (io.ReadCloser, in
 	return response.Body, response.ContentLength, nil
 }
 
-func (c *RestClient) CreateSongContent(format restApiV1.SongFormat, readerSource io.Reader) (*restApiV1.Song, ClientError) {
-	var song *restApiV1.Song
-
-	response, cliErr := c.doPostRequest("/songContents", format.MimeType(), readerSource)
-	if cliErr != nil {
-		return nil, cliErr
-	}
-	defer response.Body.Close()
-
-	if err := json.NewDecoder(response.Body).Decode(&song); err != nil {
-		return nil, NewClientError(err)
-	}
+// CreateSongContent uploads a song file with a resumable upload, then creates the song
+func (c *RestClient) CreateSongContent(content io.ReaderAt, size int64, progress UploadProgressFunc) (*restApiV1.Song, ClientError) {
+	return c.uploadSongContent(content, size, restApiV1.UnknownAlbumId, progress)
+}
 
-	return song, nil
+// CreateSongContentForAlbum uploads a song file with a resumable upload, then creates the song and tries to link it to albumId
+func (c *RestClient) CreateSongContentForAlbum(content io.ReaderAt, size int64, albumId restApiV1.AlbumId, progress UploadProgressFunc) (*restApiV1.Song, ClientError) {
+	return c.uploadSongContent(content, size, albumId, progress)
 }
 
-func (c *RestClient) CreateSongContentForAlbum(format restApiV1.SongFormat, readerSource io.Reader, albumId restApiV1.AlbumId) (*restApiV1.Song, ClientError) {
+func (c *RestClient) UpdateSong(songId restApiV1.SongId, songMeta *restApiV1.SongMeta) (*restApiV1.Song, ClientError) {
 	var song *restApiV1.Song
 
-	response, cliErr := c.doPostRequest("/songContentsForAlbum/"+string(albumId), format.MimeType(), readerSource)
+	encodedSongMeta, _ := json.Marshal(songMeta)
+
+	response, cliErr := c.doPutRequest("/songs/"+string(songId), JsonContentType, bytes.NewBuffer(encodedSongMeta))
 	if cliErr != nil {
 		return nil, cliErr
 	}
@@ -83,22 +79,22 @@ func (c *RestClient) CreateSongContentForAlbum(format restApiV1.SongFormat, read
 	return song, nil
 }
 
-func (c *RestClient) UpdateSong(songId restApiV1.SongId, songMeta *restApiV1.SongMeta) (*restApiV1.Song, ClientError) {
-	var song *restApiV1.Song
+func (c *RestClient) UpdateSongs(songsPatch *restApiV1.SongsPatch) ([]restApiV1.Song, ClientError) {
+	var songs []restApiV1.Song
 
-	encodedSongMeta, _ := json.Marshal(songMeta)
+	encodedSongsPatch, _ := json.Marshal(songsPatch)
 
-	response, cliErr := c.doPutRequest("/songs/"+string(songId), JsonContentType, bytes.NewBuffer(encodedSongMeta))
+	response, cliErr := c.doPatchRequest("/songs", JsonContentType, bytes.NewBuffer(encodedSongsPatch))
 	if cliErr != nil {
 		return nil, cliErr
 	}
 	defer response.Body.Close()
 
-	if err := json.NewDecoder(response.Body).Decode(&song); err != nil {
+	if err := json.NewDecoder(response.Body).Decode(&songs); err != nil {
 		return nil, NewClientError(err)
 	}
 
-	return song, nil
+	return songs, nil
 }
 
 func (c *RestClient) DeleteSong(songId restApiV1.SongId) (*restApiV1.Song, ClientError) {
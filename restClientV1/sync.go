@@ -3,14 +3,15 @@ package restClientV1
 import (
 	"encoding/json"
 	"github.com/jypelle/mifasol/restApiV1"
+	"net/url"
 	"strconv"
 )
 
-func (c *RestClient) ReadSyncReport(fromTs int64) (*restApiV1.SyncReport, ClientError) {
+func (c *RestClient) ReadSyncReport(fromRevision int64) (*restApiV1.SyncReport, ClientError) {
 
 	var syncReport *restApiV1.SyncReport
 
-	response, cliErr := c.doGetRequest("/syncReport/" + strconv.FormatInt(fromTs, 10))
+	response, cliErr := c.doGetRequest("/syncReport/" + strconv.FormatInt(fromRevision, 10))
 
 	if cliErr != nil {
 		return nil, cliErr
@@ -24,11 +25,36 @@ func (c *RestClient) ReadSyncReport(fromTs int64) (*restApiV1.SyncReport, Client
 	return syncReport, nil
 }
 
-func (c *RestClient) ReadFileSyncReport(fromTs int64, userId restApiV1.UserId) (*restApiV1.FileSyncReport, ClientError) {
+// ReadSyncReportPage reads the first page of the changes made after the from revision, without continuation token,
+// otherwise the next page
+func (c *RestClient) ReadSyncReportPage(fromRevision int64, continuationToken string) (*restApiV1.SyncReportPage, ClientError) {
+
+	var syncReportPage *restApiV1.SyncReportPage
+
+	query := url.Values{}
+	if continuationToken != "" {
+		query.Set("continuationToken", continuationToken)
+	}
+
+	response, cliErr := c.doGetRequest("/syncReportPage/" + strconv.FormatInt(fromRevision, 10) + "?" + query.Encode())
+
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&syncReportPage); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return syncReportPage, nil
+}
+
+func (c *RestClient) ReadFileSyncReport(fromRevision int64, userId restApiV1.UserId) (*restApiV1.FileSyncReport, ClientError) {
 
 	var fileSyncReport *restApiV1.FileSyncReport
 
-	response, cliErr := c.doGetRequest("/fileSyncReport/" + strconv.FormatInt(fromTs, 10) + "/" + string(userId))
+	response, cliErr := c.doGetRequest("/fileSyncReport/" + strconv.FormatInt(fromRevision, 10) + "/" + string(userId))
 
 	if cliErr != nil {
 		return nil, cliErr
@@ -5,25 +5,28 @@ import (
 	"github.com/jypelle/mifasol/internal/version"
 	"github.com/jypelle/mifasol/restApiV1"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 func (c *RestClient) refreshToken() ClientError {
 	c.token = nil
 
-	req, err := http.NewRequest("POST", c.getServerApiUrl()+"/token", nil)
+	// Credentials are sent in the body, to keep them out of the server and proxy logs
+	form := url.Values{}
+	form.Add("grant_type", "password")
+	form.Add("username", c.ClientConfig.GetUsername())
+	form.Add("password", c.ClientConfig.GetPassword())
+
+	req, err := http.NewRequest("POST", c.getServerApiUrl()+"/token", strings.NewReader(form.Encode()))
 	if err != nil {
 		return NewClientError(err)
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	// And rest client revision
 	req.Header.Add("x-mifasol-client-version", version.AppVersion.String())
 
-	query := req.URL.Query()
-	query.Add("grant_type", "password")
-	query.Add("username", c.ClientConfig.GetUsername())
-	query.Add("password", c.ClientConfig.GetPassword())
-	req.URL.RawQuery = query.Encode()
-
 	response, err := c.httpClient.Do(req)
 	if err != nil {
 		return NewClientError(err)
@@ -0,0 +1,54 @@
+package restClientV1
+
+import (
+	"encoding/json"
+	"github.com/jypelle/mifasol/restApiV1"
+)
+
+func (c *RestClient) ReadTrashItems() ([]restApiV1.TrashItem, ClientError) {
+	var trashItemList []restApiV1.TrashItem
+
+	response, cliErr := c.doGetRequest("/trashItems")
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&trashItemList); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return trashItemList, nil
+}
+
+func (c *RestClient) RestoreTrashItem(trashItemId restApiV1.TrashItemId) (*restApiV1.TrashItem, ClientError) {
+	var trashItem *restApiV1.TrashItem
+
+	response, cliErr := c.doPostRequest("/trashItems/"+string(trashItemId)+"/restore", JsonContentType, nil)
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&trashItem); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return trashItem, nil
+}
+
+func (c *RestClient) DeleteTrashItem(trashItemId restApiV1.TrashItemId) (*restApiV1.TrashItem, ClientError) {
+	var trashItem *restApiV1.TrashItem
+
+	response, cliErr := c.doDeleteRequest("/trashItems/" + string(trashItemId))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&trashItem); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return trashItem, nil
+}
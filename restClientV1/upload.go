@@ -0,0 +1,208 @@
+package restClientV1
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jypelle/mifasol/restApiV1"
+	"io"
+	"strconv"
+	"time"
+)
+
+const uploadContentType = "application/offset+octet-stream"
+
+// Number of consecutive failures before giving up an upload
+const uploadMaxRetries = 10
+
+// Delay before resuming an upload, multiplied by the number of consecutive failures
+const uploadRetryDelay = 2 * time.Second
+
+// UploadProgressFunc receives the number of bytes of the song file sent to the server
+type UploadProgressFunc func(offset int64)
+
+func (c *RestClient) ReadUpload(uploadId restApiV1.UploadId) (*restApiV1.Upload, ClientError) {
+	var upload *restApiV1.Upload
+
+	response, cliErr := c.doGetRequest("/uploads/" + string(uploadId))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&upload); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return upload, nil
+}
+
+func (c *RestClient) CreateUpload(uploadMeta *restApiV1.UploadMeta) (*restApiV1.Upload, ClientError) {
+	var upload *restApiV1.Upload
+
+	encodedUploadMeta, _ := json.Marshal(uploadMeta)
+
+	response, cliErr := c.doPostRequest("/uploads", JsonContentType, bytes.NewBuffer(encodedUploadMeta))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&upload); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return upload, nil
+}
+
+// UpdateUploadContent sends a chunk of the song file, offset being the number of bytes already received by the server
+func (c *RestClient) UpdateUploadContent(uploadId restApiV1.UploadId, offset int64, chunk io.Reader) (*restApiV1.Upload, ClientError) {
+	var upload *restApiV1.Upload
+
+	response, cliErr := c.doPutRequest("/uploads/"+string(uploadId)+"/content?offset="+strconv.FormatInt(offset, 10), uploadContentType, chunk)
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&upload); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return upload, nil
+}
+
+// CreateUploadSong creates the song from a completed upload
+func (c *RestClient) CreateUploadSong(uploadId restApiV1.UploadId) (*restApiV1.Song, ClientError) {
+	var song *restApiV1.Song
+
+	response, cliErr := c.doPostRequest("/uploads/"+string(uploadId)+"/song", JsonContentType, nil)
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&song); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return song, nil
+}
+
+func (c *RestClient) DeleteUpload(uploadId restApiV1.UploadId) (*restApiV1.Upload, ClientError) {
+	var upload *restApiV1.Upload
+
+	response, cliErr := c.doDeleteRequest("/uploads/" + string(uploadId))
+	if cliErr != nil {
+		return nil, cliErr
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(&upload); err != nil {
+		return nil, NewClientError(err)
+	}
+
+	return upload, nil
+}
+
+// uploadSongContent sends the song file in chunks, resumes the upload after a failure, then creates the song
+func (c *RestClient) uploadSongContent(content io.ReaderAt, size int64, lastAlbumId restApiV1.AlbumId, progress UploadProgressFunc) (*restApiV1.Song, ClientError) {
+	upload, cliErr := c.CreateUpload(&restApiV1.UploadMeta{Size: size, LastAlbumId: lastAlbumId})
+	if cliErr != nil {
+		return nil, cliErr
+	}
+
+	song, cliErr := c.sendUpload(upload, content, progress)
+	if cliErr != nil {
+		// Let the server free the upload
+		c.DeleteUpload(upload.Id)
+		return nil, cliErr
+	}
+
+	return song, nil
+}
+
+func (c *RestClient) sendUpload(upload *restApiV1.Upload, content io.ReaderAt, progress UploadProgressFunc) (*restApiV1.Song, ClientError) {
+	offset := upload.Offset
+	failures := 0
+
+	// Wait before retrying a failed request, or give up
+	retry := func(cliErr ClientError) ClientError {
+		switch cliErr.Code() {
+		case restApiV1.ClientErrorCode,
+			restApiV1.UnknownErrorCode,
+			restApiV1.InternalErrorCode,
+			restApiV1.UploadOffsetMismatchErrorCode,
+			restApiV1.UploadBusyErrorCode,
+			restApiV1.TooManyRequestsErrorCode:
+		default:
+			return cliErr
+		}
+		failures++
+		if failures > uploadMaxRetries {
+			return cliErr
+		}
+		time.Sleep(time.Duration(failures) * uploadRetryDelay)
+		return nil
+	}
+
+	for offset < upload.Size {
+		chunkSize := upload.Size - offset
+		if chunkSize > restApiV1.UploadChunkSize {
+			chunkSize = restApiV1.UploadChunkSize
+		}
+		chunk := io.NewSectionReader(content, offset, chunkSize)
+
+		var chunkReader io.Reader = chunk
+		if progress != nil {
+			chunkReader = &uploadProgressReader{reader: chunk, offset: offset, progress: progress}
+		}
+
+		updatedUpload, cliErr := c.UpdateUploadContent(upload.Id, offset, chunkReader)
+		if cliErr != nil {
+			if retryErr := retry(cliErr); retryErr != nil {
+				return nil, retryErr
+			}
+
+			// Resume from the bytes really received by the server
+			updatedUpload, cliErr = c.ReadUpload(upload.Id)
+			if cliErr != nil {
+				continue
+			}
+		} else {
+			failures = 0
+		}
+
+		offset = updatedUpload.Offset
+		if progress != nil {
+			progress(offset)
+		}
+	}
+
+	for {
+		song, cliErr := c.CreateUploadSong(upload.Id)
+		if cliErr == nil {
+			return song, nil
+		}
+		// An unreadable song file won't get better
+		if cliErr.Code() == restApiV1.InternalErrorCode {
+			return nil, cliErr
+		}
+		if retryErr := retry(cliErr); retryErr != nil {
+			return nil, retryErr
+		}
+	}
+}
+
+// uploadProgressReader reports the bytes of a chunk read by the http client
+type uploadProgressReader struct {
+	reader   io.Reader
+	offset   int64
+	progress UploadProgressFunc
+}
+
+func (r *uploadProgressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+	r.progress(r.offset)
+	return n, err
+}